@@ -0,0 +1,348 @@
+// Command blogctl manages the blog's posts from the terminal through its
+// JSON API, so that changes made with it are announced, sent to webhooks
+// and committed to the content directory as changes made in the admin
+// are.
+//
+// Usage:
+//
+//	blogctl [-api URL] COMMAND [ARGS]
+//
+// The API is at $BLOG_API_URL, or http://localhost:8000, and a token from
+// the Tokens page of the admin is read from $BLOG_API_TOKEN; listing and
+// showing drafts needs a read token, and the other commands a write token.
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"srv.exe.dev/srv/frontmatter"
+)
+
+const usage = `usage: blogctl [-api URL] COMMAND [ARGS]
+
+Commands:
+  list [-status published|draft|all] [-tag TAG] [-n N]
+                  list posts, newest first
+  show SLUG       print a post as Markdown with front matter
+  new -title TITLE [-file FILE] [-slug SLUG] [-tags A,B] [-publish]
+                  create a post, a draft unless -publish is given, from
+                  FILE (- for stdin), which may start with front matter
+  publish SLUG    publish a post now
+  unpublish SLUG  take a post back to a draft
+  delete SLUG     move a post to the trash
+`
+
+// post is a post as the API gives it.
+type post struct {
+	Slug      string     `json:"slug"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Excerpt   string     `json:"excerpt"`
+	Published bool       `json:"published"`
+	PublishAt *time.Time `json:"publish_at"`
+	Tags      []string   `json:"tags"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// newPost is the body of a request to create a post.
+type newPost struct {
+	Slug      string   `json:"slug,omitempty"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Published bool     `json:"published"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+func main() {
+	api := flag.String("api", cmp.Or(os.Getenv("BLOG_API_URL"), "http://localhost:8000"), "the blog's address, from $BLOG_API_URL if set")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c := &client{
+		base:  strings.TrimSuffix(*api, "/"),
+		token: os.Getenv("BLOG_API_TOKEN"),
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+	if err := run(c, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(c *client, command string, args []string) error {
+	switch command {
+	case "list":
+		return list(c, args)
+	case "show":
+		slug, err := slugArg(command, args)
+		if err != nil {
+			return err
+		}
+		var p post
+		if err := c.do(http.MethodGet, "/api/v1/posts/"+url.PathEscape(slug), nil, &p); err != nil {
+			return err
+		}
+		fmt.Print(postFile(p))
+		return nil
+	case "new":
+		return create(c, args)
+	case "publish", "unpublish":
+		slug, err := slugArg(command, args)
+		if err != nil {
+			return err
+		}
+		var p post
+		if err := c.do(http.MethodPost, "/api/v1/posts/"+url.PathEscape(slug)+"/"+command, nil, &p); err != nil {
+			return err
+		}
+		fmt.Printf("%s is now %s\n", p.Slug, status(p))
+		return nil
+	case "delete":
+		slug, err := slugArg(command, args)
+		if err != nil {
+			return err
+		}
+		if err := c.do(http.MethodDelete, "/api/v1/posts/"+url.PathEscape(slug), nil, nil); err != nil {
+			return err
+		}
+		fmt.Printf("%s moved to the trash\n", slug)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", command)
+}
+
+// slugArg returns the one argument of a command that takes a slug.
+func slugArg(command string, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("usage: blogctl %s SLUG", command)
+	}
+	return args[0], nil
+}
+
+// list prints the posts with the given status and tag, newest first, a
+// page of the API at a time until n are printed or there are no more.
+func list(c *client, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	postStatus := fs.String("status", "", "published, draft or all; all if there is a token and published otherwise")
+	tag := fs.String("tag", "", "only posts with this tag")
+	n := fs.Int("n", 20, "list at most `N` posts, or all of them if 0")
+	fs.Parse(args)
+	if *postStatus == "" {
+		*postStatus = "published"
+		if c.token != "" {
+			*postStatus = "all"
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SLUG\tSTATUS\tDATE\tTITLE")
+	listed := 0
+	cursor := ""
+	for {
+		query := url.Values{"status": {*postStatus}, "limit": {"100"}}
+		if *tag != "" {
+			query.Set("tag", *tag)
+		}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var page struct {
+			Posts      []post `json:"posts"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := c.do(http.MethodGet, "/api/v1/posts?"+query.Encode(), nil, &page); err != nil {
+			return err
+		}
+		for _, p := range page.Posts {
+			if *n > 0 && listed == *n {
+				return w.Flush()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Slug, status(p), p.CreatedAt.Local().Format(time.DateOnly), p.Title)
+			listed++
+		}
+		if page.NextCursor == "" {
+			return w.Flush()
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// status describes whether p is published, a draft, or scheduled.
+func status(p post) string {
+	switch {
+	case p.Published:
+		return "published"
+	case p.PublishAt != nil:
+		return "scheduled for " + p.PublishAt.Local().Format("Jan 2 15:04")
+	}
+	return "draft"
+}
+
+// create makes a post from the flags in args and the file they name.
+// Front matter at the top of the file gives the title, slug, tags and
+// whether to publish, as in the content directory; flags take precedence.
+func create(c *client, args []string) error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	title := fs.String("title", "", "the post's title")
+	file := fs.String("file", "", "read the post's Markdown from `FILE`, or stdin if -")
+	slug := fs.String("slug", "", "the post's slug, made from the title if not given")
+	tagList := fs.String("tags", "", "comma-separated tags")
+	publish := fs.Bool("publish", false, "publish the post now rather than saving a draft")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	var in newPost
+	if *file != "" {
+		var src []byte
+		var err error
+		if *file == "-" {
+			src, err = io.ReadAll(os.Stdin)
+		} else {
+			src, err = os.ReadFile(*file)
+		}
+		if err != nil {
+			return err
+		}
+		fields, body, err := frontmatter.Parse(string(src))
+		if err != nil {
+			return fmt.Errorf("%s: %w", *file, err)
+		}
+		in.Content = strings.TrimLeft(body, "\n")
+		in.Title, _ = fields["title"].(string)
+		in.Slug, _ = fields["slug"].(string)
+		switch tags := fields["tags"].(type) {
+		case []string:
+			in.Tags = tags
+		case string:
+			in.Tags = splitTags(tags)
+		}
+		published, _ := fields["published"].(bool)
+		draft, _ := fields["draft"].(bool)
+		in.Published = published && !draft
+	}
+	if *title != "" {
+		in.Title = *title
+	}
+	if *slug != "" {
+		in.Slug = *slug
+	}
+	if *tagList != "" {
+		in.Tags = splitTags(*tagList)
+	}
+	if *publish {
+		in.Published = true
+	}
+	if in.Title == "" {
+		return errors.New("a title is needed: give -title or a title in the file's front matter")
+	}
+
+	var p post
+	if err := c.do(http.MethodPost, "/api/v1/posts", in, &p); err != nil {
+		return err
+	}
+	fmt.Printf("Created %s (%s): %s\n", p.Slug, status(p), p.URL)
+	return nil
+}
+
+// splitTags splits a comma-separated list of tags.
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// postFile returns p as a Markdown file with front matter, which new
+// reads back.
+func postFile(p post) string {
+	fields := []frontmatter.Field{
+		{Key: "title", Value: p.Title},
+		{Key: "slug", Value: p.Slug},
+		{Key: "date", Value: p.CreatedAt.UTC().Format(time.RFC3339)},
+		{Key: "published", Value: p.Published},
+	}
+	if len(p.Tags) > 0 {
+		fields = append(fields, frontmatter.Field{Key: "tags", Value: p.Tags})
+	}
+	content := p.Content
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return frontmatter.Format(fields, content)
+}
+
+// client makes requests to the blog's API.
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// do sends a request to path with body, if not nil, as JSON, and decodes
+// the response into out, if not nil. A response that is not a 2xx status
+// is an error, with the reason the API gives.
+func (c *client) do(method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = http.StatusText(resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+			apiErr.Error += " (set BLOG_API_TOKEN to a token from the admin)"
+		}
+		return fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
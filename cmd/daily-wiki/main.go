@@ -1,20 +1,26 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
-	"regexp"
 	"strings"
 	"time"
 
 	"srv.exe.dev/db"
 	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/slug"
+	"srv.exe.dev/srv/tags"
 )
 
+// wikiTag is the tag every post created by daily-wiki gets.
+const wikiTag = "wikipedia"
+
 // WikiSummary represents the response from Wikipedia's summary API
 type WikiSummary struct {
 	Title       string `json:"title"`
@@ -28,13 +34,24 @@ type WikiSummary struct {
 }
 
 func main() {
-	if err := run(); err != nil {
+	publishAt := flag.String("publish-at", "", "schedule the post for the next `HH:MM` local time instead of publishing it now")
+	api := flag.String("api", "", "create the post through the blog's JSON API at `URL`, with the write token in $BLOG_API_TOKEN, instead of in db.sqlite3")
+	flag.Parse()
+	if err := run(*publishAt, *api); err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
+func run(publishAt, api string) error {
+	var at time.Time
+	if publishAt != "" {
+		var err error
+		if at, err = nextTimeOfDay(publishAt, time.Now()); err != nil {
+			return fmt.Errorf("parse -publish-at: %w", err)
+		}
+	}
+
 	// Fetch random Wikipedia article summary
 	summary, err := fetchRandomWikiSummary()
 	if err != nil {
@@ -43,22 +60,51 @@ func run() error {
 
 	fmt.Printf("Found article: %s\n", summary.Title)
 
-	// Connect to database
-	wdb, err := db.Open("db.sqlite3")
-	if err != nil {
-		return fmt.Errorf("open db: %w", err)
-	}
-	defer wdb.Close()
+	post := newWikiPost(summary, at)
+	if api != "" {
+		token := os.Getenv("BLOG_API_TOKEN")
+		if token == "" {
+			return fmt.Errorf("-api needs a write token in BLOG_API_TOKEN")
+		}
+		if err := postToAPI(strings.TrimSuffix(api, "/"), token, post); err != nil {
+			return fmt.Errorf("create post: %w", err)
+		}
+	} else {
+		// Connect to database
+		wdb, err := db.Open("db.sqlite3")
+		if err != nil {
+			return fmt.Errorf("open db: %w", err)
+		}
+		defer wdb.Close()
 
-	// Create the blog post
-	if err := createPost(wdb, summary); err != nil {
-		return fmt.Errorf("create post: %w", err)
+		// Create the blog post
+		if err := createPost(wdb, post); err != nil {
+			return fmt.Errorf("create post: %w", err)
+		}
 	}
 
-	fmt.Println("Post created successfully!")
+	if at.IsZero() {
+		fmt.Println("Post created successfully!")
+	} else {
+		fmt.Printf("Post scheduled for %s\n", at.Format("Jan 2 15:04"))
+	}
 	return nil
 }
 
+// nextTimeOfDay returns the first time after now at the clock time given
+// as HH:MM, in now's time zone.
+func nextTimeOfDay(clock string, now time.Time) (time.Time, error) {
+	c, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), c.Hour(), c.Minute(), 0, 0, now.Location())
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
 func fetchRandomWikiSummary() (*WikiSummary, error) {
 	// Wikipedia REST API for random article summary
 	url := "https://en.wikipedia.org/api/rest_v1/page/random/summary"
@@ -89,57 +135,122 @@ func fetchRandomWikiSummary() (*WikiSummary, error) {
 	return &summary, nil
 }
 
-func createPost(wdb *sql.DB, summary *WikiSummary) error {
-	q := dbgen.New(wdb)
+// wikiPost is the post made for an article.
+type wikiPost struct {
+	Slug      string     `json:"slug"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Published bool       `json:"published"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	Tags      []string   `json:"tags"`
+	// NumberSlug has the server number Slug if another post has it, as
+	// slug.Unique does when writing to the database directly.
+	NumberSlug bool `json:"number_slug,omitempty"`
+}
 
-	// Generate slug from title
-	slug := generateSlug(summary.Title)
-	// Add date to make it unique
-	dateStr := time.Now().Format("2006-01-02")
-	slug = fmt.Sprintf("wiki-%s-%s", dateStr, slug)
+// newWikiPost returns the post for summary. If at is not zero, the post
+// is a draft that the server publishes at that time.
+func newWikiPost(summary *WikiSummary, at time.Time) wikiPost {
+	// Prefix the slug with the date; it is numbered if another article
+	// was already posted that day
+	day := time.Now()
+	if !at.IsZero() {
+		day = at
+	}
+	dateStr := day.Format("2006-01-02")
 
 	// Build content
 	var content strings.Builder
 	content.WriteString(fmt.Sprintf("Today's random Wikipedia discovery: **%s**\n\n", summary.Title))
-	
+
 	if summary.Description != "" {
 		content.WriteString(fmt.Sprintf("*%s*\n\n", summary.Description))
 	}
-	
-	content.WriteString(summary.Extract)
+
+	content.WriteString(blockquote(summary.Extract))
 	content.WriteString("\n\n")
 	content.WriteString(fmt.Sprintf("Read more on Wikipedia: %s", summary.ContentURLs.Desktop.Page))
 
-	// Create the post using context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	_, err := q.CreatePost(ctx, dbgen.CreatePostParams{
-		Slug:      slug,
+	post := wikiPost{
+		Slug:      fmt.Sprintf("wiki-%s-%s", dateStr, slug.Generate(summary.Title)),
 		Title:     fmt.Sprintf("Wiki Discovery: %s", summary.Title),
 		Content:   content.String(),
+		Published: true,
+		Tags:      []string{wikiTag},
+	}
+	if !at.IsZero() {
+		utc := at.UTC()
+		post.Published = false
+		post.PublishAt = &utc
+	}
+	return post
+}
+
+// createPost writes post to the database.
+func createPost(wdb *sql.DB, post wikiPost) error {
+	q := dbgen.New(wdb)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	postSlug, err := slug.Unique(ctx, q, post.Slug)
+	if err != nil {
+		return err
+	}
+
+	// Create the post
+	params := dbgen.CreatePostParams{
+		Slug:      postSlug,
+		Title:     post.Title,
+		Content:   post.Content,
 		Published: 1,
-	})
+		PublishAt: post.PublishAt,
+	}
+	if !post.Published {
+		params.Published = 0
+	}
+	created, err := q.CreatePost(ctx, params)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return tags.Set(ctx, q, created.ID, post.Tags)
 }
 
-func generateSlug(title string) string {
-	// Convert to lowercase
-	slug := strings.ToLower(title)
-	// Replace spaces with hyphens
-	slug = strings.ReplaceAll(slug, " ", "-")
-	// Remove non-alphanumeric characters except hyphens
-	reg := regexp.MustCompile(`[^a-z0-9-]`)
-	slug = reg.ReplaceAllString(slug, "")
-	// Remove multiple consecutive hyphens
-	reg = regexp.MustCompile(`-+`)
-	slug = reg.ReplaceAllString(slug, "-")
-	// Trim hyphens from ends
-	slug = strings.Trim(slug, "-")
-	// Limit length
-	if len(slug) > 50 {
-		slug = slug[:50]
-	}
-	return slug
+// postToAPI creates post through the JSON API at base, which numbers its
+// slug if another post, even one in the trash, has it.
+func postToAPI(base, token string, post wikiPost) error {
+	post.NumberSlug = true
+	body, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, base+"/api/v1/posts", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, apiErr.Error)
+	}
+	return nil
+}
+
+// blockquote prefixes each line of s with "> " so the Markdown renderer
+// shows it as a quotation.
+func blockquote(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
 }
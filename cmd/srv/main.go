@@ -1,14 +1,28 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"srv.exe.dev/srv"
 )
 
-var flagListenAddr = flag.String("listen", ":8000", "address to listen on")
+var (
+	flagListenAddr = flag.String("listen", ":8000", "address to listen on")
+	flagMediaDir   = flag.String("media-dir", "media", "directory to store uploaded images in")
+	flagContentDir = flag.String("content-dir", "", "directory of Markdown files to sync posts from every few minutes and when /api/v1/hooks/sync is called")
+	flagCommit     = flag.Bool("content-commit", false, "write changes made on the server back to -content-dir, a Git checkout, as commits, and push them")
+	flagSetPass    = flag.String("set-password", "", "set the admin password of this email address, read from stdin, and exit")
+	flagExport     = flag.String("export-markdown", "", "write every post to this directory as a Markdown file with front matter, and exit")
+	flagImport     = flag.String("import-markdown", "", "save the Markdown files with front matter in this directory as posts, and exit")
+	flagHugo       = flag.String("export-hugo", "", "write the posts and media to this directory as the source of a Hugo site, and exit")
+	flagBackup     = flag.String("backup", "", "write a JSON backup of the posts, tags, media records and settings to this file, and exit")
+	flagRestore    = flag.String("restore", "", "rebuild an empty database from the JSON backup in this file, and exit")
+)
 
 func main() {
 	if err := run(); err != nil {
@@ -26,5 +40,85 @@ func run() error {
 	if err != nil {
 		return fmt.Errorf("create server: %w", err)
 	}
+	server.MediaDir = *flagMediaDir
+	server.ContentDir = *flagContentDir
+	server.ContentCommit = *flagCommit
+	if *flagSetPass != "" {
+		return setPassword(server, *flagSetPass)
+	}
+	if *flagExport != "" {
+		n, err := server.ExportMarkdown(context.Background(), *flagExport)
+		if err != nil {
+			return fmt.Errorf("export posts: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d posts to %s.\n", n, *flagExport)
+		return nil
+	}
+	if *flagHugo != "" {
+		n, err := server.ExportHugo(context.Background(), *flagHugo)
+		if err != nil {
+			return fmt.Errorf("export Hugo site: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d files to %s.\n", n, *flagHugo)
+		return nil
+	}
+	if *flagBackup != "" {
+		return backup(server, *flagBackup)
+	}
+	if *flagRestore != "" {
+		f, err := os.Open(*flagRestore)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := server.Restore(context.Background(), f); err != nil {
+			return fmt.Errorf("restore %s: %w", *flagRestore, err)
+		}
+		fmt.Fprintf(os.Stderr, "Restored %s.\n", *flagRestore)
+		return nil
+	}
+	if *flagImport != "" {
+		result, err := server.ImportMarkdown(context.Background(), *flagImport)
+		if err != nil {
+			return fmt.Errorf("import posts: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Imported %s: %d new, %d changed, %d unchanged, %d failed.\n",
+			*flagImport, result.Created, result.Updated, result.Unchanged, result.Failed)
+		return nil
+	}
 	return server.Serve(*flagListenAddr)
 }
+
+// setPassword reads a password from the first line of stdin and sets it as
+// the password of email.
+func setPassword(server *srv.Server, email string) error {
+	fmt.Fprintf(os.Stderr, "New password for %s: ", email)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("read password: %w", err)
+	}
+	if err := server.SetPassword(context.Background(), email, strings.TrimRight(line, "\r\n")); err != nil {
+		return fmt.Errorf("set password: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Password set.")
+	return nil
+}
+
+// backup writes a backup of server to the file at path, leaving no file
+// behind if it fails.
+func backup(server *srv.Server, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = server.Backup(context.Background(), f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("back up: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Backed up to %s.\n", path)
+	return nil
+}
@@ -0,0 +1,66 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: autosaves.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const deletePostAutosave = `-- name: DeletePostAutosave :exec
+DELETE FROM post_autosaves
+WHERE post_id = ?
+`
+
+func (q *Queries) DeletePostAutosave(ctx context.Context, postID int64) error {
+	_, err := q.db.ExecContext(ctx, deletePostAutosave, postID)
+	return err
+}
+
+const getPostAutosave = `-- name: GetPostAutosave :one
+SELECT post_id, title, content, excerpt, saved_at
+FROM post_autosaves
+WHERE post_id = ?
+`
+
+func (q *Queries) GetPostAutosave(ctx context.Context, postID int64) (PostAutosave, error) {
+	row := q.db.QueryRowContext(ctx, getPostAutosave, postID)
+	var i PostAutosave
+	err := row.Scan(
+		&i.PostID,
+		&i.Title,
+		&i.Content,
+		&i.Excerpt,
+		&i.SavedAt,
+	)
+	return i, err
+}
+
+const upsertPostAutosave = `-- name: UpsertPostAutosave :exec
+INSERT INTO post_autosaves (post_id, title, content, excerpt, saved_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (post_id) DO UPDATE
+SET title = excluded.title, content = excluded.content, excerpt = excluded.excerpt, saved_at = excluded.saved_at
+`
+
+type UpsertPostAutosaveParams struct {
+	PostID  int64     `json:"post_id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Excerpt string    `json:"excerpt"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+func (q *Queries) UpsertPostAutosave(ctx context.Context, arg UpsertPostAutosaveParams) error {
+	_, err := q.db.ExecContext(ctx, upsertPostAutosave,
+		arg.PostID,
+		arg.Title,
+		arg.Content,
+		arg.Excerpt,
+		arg.SavedAt,
+	)
+	return err
+}
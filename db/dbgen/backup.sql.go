@@ -0,0 +1,674 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: backup.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const countRestoreTargets = `-- name: CountRestoreTargets :one
+SELECT
+  (SELECT COUNT(*) FROM posts) AS posts,
+  (SELECT COUNT(*) FROM tags) AS tags,
+  (SELECT COUNT(*) FROM categories) AS categories,
+  (SELECT COUNT(*) FROM series) AS series,
+  (SELECT COUNT(*) FROM media) AS media
+`
+
+type CountRestoreTargetsRow struct {
+	Posts      int64 `json:"posts"`
+	Tags       int64 `json:"tags"`
+	Categories int64 `json:"categories"`
+	Series     int64 `json:"series"`
+	Media      int64 `json:"media"`
+}
+
+// The rows a restore would collide with; a restore needs them all to be 0.
+func (q *Queries) CountRestoreTargets(ctx context.Context) (CountRestoreTargetsRow, error) {
+	row := q.db.QueryRowContext(ctx, countRestoreTargets)
+	var i CountRestoreTargetsRow
+	err := row.Scan(
+		&i.Posts,
+		&i.Tags,
+		&i.Categories,
+		&i.Series,
+		&i.Media,
+	)
+	return i, err
+}
+
+const listAllComments = `-- name: ListAllComments :many
+SELECT id, post_id, author_name, author_email, author_url, body, status, ip, created_at, updated_at, parent_comment_id
+FROM comments
+ORDER BY id
+`
+
+func (q *Queries) ListAllComments(ctx context.Context) ([]Comment, error) {
+	rows, err := q.db.QueryContext(ctx, listAllComments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Comment{}
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.AuthorName,
+			&i.AuthorEmail,
+			&i.AuthorUrl,
+			&i.Body,
+			&i.Status,
+			&i.Ip,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ParentCommentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllContactMessages = `-- name: ListAllContactMessages :many
+SELECT id, name, email, subject, body, sender, read_at, created_at FROM contact_messages ORDER BY id
+`
+
+func (q *Queries) ListAllContactMessages(ctx context.Context) ([]ContactMessage, error) {
+	rows, err := q.db.QueryContext(ctx, listAllContactMessages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ContactMessage{}
+	for rows.Next() {
+		var i ContactMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Subject,
+			&i.Body,
+			&i.Sender,
+			&i.ReadAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllFollowers = `-- name: ListAllFollowers :many
+SELECT id, actor, inbox, shared_inbox, created_at FROM followers ORDER BY id
+`
+
+func (q *Queries) ListAllFollowers(ctx context.Context) ([]Follower, error) {
+	rows, err := q.db.QueryContext(ctx, listAllFollowers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Follower{}
+	for rows.Next() {
+		var i Follower
+		if err := rows.Scan(
+			&i.ID,
+			&i.Actor,
+			&i.Inbox,
+			&i.SharedInbox,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllPostTags = `-- name: ListAllPostTags :many
+SELECT post_id, tag_id
+FROM post_tags
+ORDER BY post_id, tag_id
+`
+
+func (q *Queries) ListAllPostTags(ctx context.Context) ([]PostTag, error) {
+	rows, err := q.db.QueryContext(ctx, listAllPostTags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []PostTag{}
+	for rows.Next() {
+		var i PostTag
+		if err := rows.Scan(&i.PostID, &i.TagID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllSubscribers = `-- name: ListAllSubscribers :many
+SELECT id, email, token, created_at, confirmed_at FROM subscribers ORDER BY id
+`
+
+func (q *Queries) ListAllSubscribers(ctx context.Context) ([]Subscriber, error) {
+	rows, err := q.db.QueryContext(ctx, listAllSubscribers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Subscriber{}
+	for rows.Next() {
+		var i Subscriber
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Token,
+			&i.CreatedAt,
+			&i.ConfirmedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllTags = `-- name: ListAllTags :many
+SELECT id, name, slug
+FROM tags
+ORDER BY id
+`
+
+func (q *Queries) ListAllTags(ctx context.Context) ([]Tag, error) {
+	rows, err := q.db.QueryContext(ctx, listAllTags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Tag{}
+	for rows.Next() {
+		var i Tag
+		if err := rows.Scan(&i.ID, &i.Name, &i.Slug); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllWebmentions = `-- name: ListAllWebmentions :many
+SELECT id, post_id, source, target, status, title, needs_check, verified_at, created_at, updated_at FROM webmentions ORDER BY id
+`
+
+func (q *Queries) ListAllWebmentions(ctx context.Context) ([]Webmention, error) {
+	rows, err := q.db.QueryContext(ctx, listAllWebmentions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Webmention{}
+	for rows.Next() {
+		var i Webmention
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.Source,
+			&i.Target,
+			&i.Status,
+			&i.Title,
+			&i.NeedsCheck,
+			&i.VerifiedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBackupPosts = `-- name: ListBackupPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+ORDER BY id
+`
+
+// Every post, trashed or not, for a backup.
+func (q *Queries) ListBackupPosts(ctx context.Context) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listBackupPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreBackupPost = `-- name: RestoreBackupPost :exec
+INSERT INTO posts (id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost)
+VALUES (
+  ?1, ?2, ?3, ?4, ?5,
+  CAST(?6 AS TEXT), CAST(?7 AS TEXT),
+  ?8, ?9, ?10, ?11,
+  ?12, ?13, ?14, ?15,
+  CAST(?16 AS TEXT), ?17, ?18, ?19
+)
+`
+
+type RestoreBackupPostParams struct {
+	ID              int64      `json:"id"`
+	Slug            string     `json:"slug"`
+	Title           string     `json:"title"`
+	Content         string     `json:"content"`
+	Published       int64      `json:"published"`
+	CreatedAt       string     `json:"created_at"`
+	UpdatedAt       string     `json:"updated_at"`
+	AllowHtml       int64      `json:"allow_html"`
+	MetaDescription string     `json:"meta_description"`
+	OgImage         string     `json:"og_image"`
+	CoverImage      string     `json:"cover_image"`
+	SeriesID        *int64     `json:"series_id"`
+	SeriesOrder     int64      `json:"series_order"`
+	CategoryID      *int64     `json:"category_id"`
+	PublishAt       *time.Time `json:"publish_at"`
+	DeletedAt       *string    `json:"deleted_at"`
+	Excerpt         string     `json:"excerpt"`
+	CommentsClosed  int64      `json:"comments_closed"`
+	NoCrosspost     int64      `json:"no_crosspost"`
+}
+
+func (q *Queries) RestoreBackupPost(ctx context.Context, arg RestoreBackupPostParams) error {
+	_, err := q.db.ExecContext(ctx, restoreBackupPost,
+		arg.ID,
+		arg.Slug,
+		arg.Title,
+		arg.Content,
+		arg.Published,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.AllowHtml,
+		arg.MetaDescription,
+		arg.OgImage,
+		arg.CoverImage,
+		arg.SeriesID,
+		arg.SeriesOrder,
+		arg.CategoryID,
+		arg.PublishAt,
+		arg.DeletedAt,
+		arg.Excerpt,
+		arg.CommentsClosed,
+		arg.NoCrosspost,
+	)
+	return err
+}
+
+const restoreCategory = `-- name: RestoreCategory :exec
+INSERT INTO categories (id, slug, name, description, created_at)
+VALUES (?1, ?2, ?3, ?4, CAST(?5 AS TEXT))
+`
+
+type RestoreCategoryParams struct {
+	ID          int64  `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func (q *Queries) RestoreCategory(ctx context.Context, arg RestoreCategoryParams) error {
+	_, err := q.db.ExecContext(ctx, restoreCategory,
+		arg.ID,
+		arg.Slug,
+		arg.Name,
+		arg.Description,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const restoreComment = `-- name: RestoreComment :exec
+INSERT INTO comments (id, post_id, parent_comment_id, author_name, author_email, author_url, body, status, ip, created_at, updated_at)
+VALUES (
+  ?1, ?2, ?3, ?4, ?5, ?6,
+  ?7, ?8, ?9, CAST(?10 AS TEXT), CAST(?11 AS TEXT)
+)
+`
+
+type RestoreCommentParams struct {
+	ID              int64  `json:"id"`
+	PostID          int64  `json:"post_id"`
+	ParentCommentID *int64 `json:"parent_comment_id"`
+	AuthorName      string `json:"author_name"`
+	AuthorEmail     string `json:"author_email"`
+	AuthorUrl       string `json:"author_url"`
+	Body            string `json:"body"`
+	Status          string `json:"status"`
+	Ip              string `json:"ip"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+func (q *Queries) RestoreComment(ctx context.Context, arg RestoreCommentParams) error {
+	_, err := q.db.ExecContext(ctx, restoreComment,
+		arg.ID,
+		arg.PostID,
+		arg.ParentCommentID,
+		arg.AuthorName,
+		arg.AuthorEmail,
+		arg.AuthorUrl,
+		arg.Body,
+		arg.Status,
+		arg.Ip,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const restoreContactMessage = `-- name: RestoreContactMessage :exec
+INSERT INTO contact_messages (id, name, email, subject, body, sender, read_at, created_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6,
+  CAST(?7 AS TEXT), CAST(?8 AS TEXT))
+`
+
+type RestoreContactMessageParams struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Email     string  `json:"email"`
+	Subject   string  `json:"subject"`
+	Body      string  `json:"body"`
+	Sender    string  `json:"sender"`
+	ReadAt    *string `json:"read_at"`
+	CreatedAt string  `json:"created_at"`
+}
+
+func (q *Queries) RestoreContactMessage(ctx context.Context, arg RestoreContactMessageParams) error {
+	_, err := q.db.ExecContext(ctx, restoreContactMessage,
+		arg.ID,
+		arg.Name,
+		arg.Email,
+		arg.Subject,
+		arg.Body,
+		arg.Sender,
+		arg.ReadAt,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const restoreFollower = `-- name: RestoreFollower :exec
+INSERT INTO followers (id, actor, inbox, shared_inbox, created_at)
+VALUES (?1, ?2, ?3, ?4, CAST(?5 AS TEXT))
+`
+
+type RestoreFollowerParams struct {
+	ID          int64  `json:"id"`
+	Actor       string `json:"actor"`
+	Inbox       string `json:"inbox"`
+	SharedInbox string `json:"shared_inbox"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func (q *Queries) RestoreFollower(ctx context.Context, arg RestoreFollowerParams) error {
+	_, err := q.db.ExecContext(ctx, restoreFollower,
+		arg.ID,
+		arg.Actor,
+		arg.Inbox,
+		arg.SharedInbox,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const restoreMedia = `-- name: RestoreMedia :exec
+INSERT INTO media (id, filename, original_name, content_type, size, width, height, created_at, sha256)
+VALUES (
+  ?1, ?2, ?3, ?4, ?5,
+  ?6, ?7, CAST(?8 AS TEXT), ?9
+)
+`
+
+type RestoreMediaParams struct {
+	ID           int64   `json:"id"`
+	Filename     string  `json:"filename"`
+	OriginalName string  `json:"original_name"`
+	ContentType  string  `json:"content_type"`
+	Size         int64   `json:"size"`
+	Width        int64   `json:"width"`
+	Height       int64   `json:"height"`
+	CreatedAt    string  `json:"created_at"`
+	Sha256       *string `json:"sha256"`
+}
+
+func (q *Queries) RestoreMedia(ctx context.Context, arg RestoreMediaParams) error {
+	_, err := q.db.ExecContext(ctx, restoreMedia,
+		arg.ID,
+		arg.Filename,
+		arg.OriginalName,
+		arg.ContentType,
+		arg.Size,
+		arg.Width,
+		arg.Height,
+		arg.CreatedAt,
+		arg.Sha256,
+	)
+	return err
+}
+
+const restorePostTag = `-- name: RestorePostTag :exec
+INSERT INTO post_tags (post_id, tag_id)
+VALUES (?, ?)
+`
+
+type RestorePostTagParams struct {
+	PostID int64 `json:"post_id"`
+	TagID  int64 `json:"tag_id"`
+}
+
+func (q *Queries) RestorePostTag(ctx context.Context, arg RestorePostTagParams) error {
+	_, err := q.db.ExecContext(ctx, restorePostTag, arg.PostID, arg.TagID)
+	return err
+}
+
+const restoreRedirect = `-- name: RestoreRedirect :exec
+INSERT INTO redirects (old_slug, new_slug, created_at)
+VALUES (?1, ?2, CAST(?3 AS TEXT))
+`
+
+type RestoreRedirectParams struct {
+	OldSlug   string `json:"old_slug"`
+	NewSlug   string `json:"new_slug"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (q *Queries) RestoreRedirect(ctx context.Context, arg RestoreRedirectParams) error {
+	_, err := q.db.ExecContext(ctx, restoreRedirect, arg.OldSlug, arg.NewSlug, arg.CreatedAt)
+	return err
+}
+
+const restoreSeries = `-- name: RestoreSeries :exec
+INSERT INTO series (id, slug, title, description, created_at)
+VALUES (?1, ?2, ?3, ?4, CAST(?5 AS TEXT))
+`
+
+type RestoreSeriesParams struct {
+	ID          int64  `json:"id"`
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func (q *Queries) RestoreSeries(ctx context.Context, arg RestoreSeriesParams) error {
+	_, err := q.db.ExecContext(ctx, restoreSeries,
+		arg.ID,
+		arg.Slug,
+		arg.Title,
+		arg.Description,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const restoreSubscriber = `-- name: RestoreSubscriber :exec
+INSERT INTO subscribers (id, email, token, created_at, confirmed_at)
+VALUES (?1, ?2, ?3, CAST(?4 AS TEXT), CAST(?5 AS TEXT))
+`
+
+type RestoreSubscriberParams struct {
+	ID          int64   `json:"id"`
+	Email       string  `json:"email"`
+	Token       string  `json:"token"`
+	CreatedAt   string  `json:"created_at"`
+	ConfirmedAt *string `json:"confirmed_at"`
+}
+
+func (q *Queries) RestoreSubscriber(ctx context.Context, arg RestoreSubscriberParams) error {
+	_, err := q.db.ExecContext(ctx, restoreSubscriber,
+		arg.ID,
+		arg.Email,
+		arg.Token,
+		arg.CreatedAt,
+		arg.ConfirmedAt,
+	)
+	return err
+}
+
+const restoreTag = `-- name: RestoreTag :exec
+INSERT INTO tags (id, name, slug)
+VALUES (?, ?, ?)
+`
+
+type RestoreTagParams struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+func (q *Queries) RestoreTag(ctx context.Context, arg RestoreTagParams) error {
+	_, err := q.db.ExecContext(ctx, restoreTag, arg.ID, arg.Name, arg.Slug)
+	return err
+}
+
+const restoreWebmention = `-- name: RestoreWebmention :exec
+INSERT INTO webmentions (id, post_id, source, target, status, title, needs_check, verified_at, created_at, updated_at)
+VALUES (
+  ?1, ?2, ?3, ?4, ?5, ?6,
+  ?7, CAST(?8 AS TEXT), CAST(?9 AS TEXT), CAST(?10 AS TEXT)
+)
+`
+
+type RestoreWebmentionParams struct {
+	ID         int64   `json:"id"`
+	PostID     int64   `json:"post_id"`
+	Source     string  `json:"source"`
+	Target     string  `json:"target"`
+	Status     string  `json:"status"`
+	Title      string  `json:"title"`
+	NeedsCheck int64   `json:"needs_check"`
+	VerifiedAt *string `json:"verified_at"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+func (q *Queries) RestoreWebmention(ctx context.Context, arg RestoreWebmentionParams) error {
+	_, err := q.db.ExecContext(ctx, restoreWebmention,
+		arg.ID,
+		arg.PostID,
+		arg.Source,
+		arg.Target,
+		arg.Status,
+		arg.Title,
+		arg.NeedsCheck,
+		arg.VerifiedAt,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	)
+	return err
+}
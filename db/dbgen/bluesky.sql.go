@@ -0,0 +1,111 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: bluesky.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const getBlueskyPost = `-- name: GetBlueskyPost :one
+SELECT post_id, attempts, error, uri, created_at, posted_at, next_attempt_at FROM bluesky_posts WHERE post_id = ?
+`
+
+func (q *Queries) GetBlueskyPost(ctx context.Context, postID int64) (BlueskyPost, error) {
+	row := q.db.QueryRowContext(ctx, getBlueskyPost, postID)
+	var i BlueskyPost
+	err := row.Scan(
+		&i.PostID,
+		&i.Attempts,
+		&i.Error,
+		&i.Uri,
+		&i.CreatedAt,
+		&i.PostedAt,
+		&i.NextAttemptAt,
+	)
+	return i, err
+}
+
+const listPendingBlueskyPosts = `-- name: ListPendingBlueskyPosts :many
+SELECT post_id, attempts, error, uri, created_at, posted_at, next_attempt_at FROM bluesky_posts
+WHERE next_attempt_at IS NOT NULL
+ORDER BY post_id
+`
+
+func (q *Queries) ListPendingBlueskyPosts(ctx context.Context) ([]BlueskyPost, error) {
+	rows, err := q.db.QueryContext(ctx, listPendingBlueskyPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BlueskyPost{}
+	for rows.Next() {
+		var i BlueskyPost
+		if err := rows.Scan(
+			&i.PostID,
+			&i.Attempts,
+			&i.Error,
+			&i.Uri,
+			&i.CreatedAt,
+			&i.PostedAt,
+			&i.NextAttemptAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queueBlueskyPost = `-- name: QueueBlueskyPost :exec
+INSERT OR IGNORE INTO bluesky_posts (post_id, next_attempt_at)
+VALUES (?, ?)
+`
+
+type QueueBlueskyPostParams struct {
+	PostID        int64      `json:"post_id"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+}
+
+func (q *Queries) QueueBlueskyPost(ctx context.Context, arg QueueBlueskyPostParams) error {
+	_, err := q.db.ExecContext(ctx, queueBlueskyPost, arg.PostID, arg.NextAttemptAt)
+	return err
+}
+
+const recordBlueskyAttempt = `-- name: RecordBlueskyAttempt :exec
+UPDATE bluesky_posts
+SET attempts = attempts + 1,
+    error = ?,
+    uri = ?,
+    posted_at = ?,
+    next_attempt_at = ?
+WHERE post_id = ?
+`
+
+type RecordBlueskyAttemptParams struct {
+	Error         string     `json:"error"`
+	Uri           string     `json:"uri"`
+	PostedAt      *time.Time `json:"posted_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	PostID        int64      `json:"post_id"`
+}
+
+func (q *Queries) RecordBlueskyAttempt(ctx context.Context, arg RecordBlueskyAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, recordBlueskyAttempt,
+		arg.Error,
+		arg.Uri,
+		arg.PostedAt,
+		arg.NextAttemptAt,
+		arg.PostID,
+	)
+	return err
+}
@@ -0,0 +1,68 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: bots.sql
+
+package dbgen
+
+import (
+	"context"
+)
+
+const createBotPattern = `-- name: CreateBotPattern :exec
+INSERT INTO bot_patterns (pattern, note)
+VALUES (?, ?)
+ON CONFLICT (pattern) DO UPDATE SET note = excluded.note
+`
+
+type CreateBotPatternParams struct {
+	Pattern string `json:"pattern"`
+	Note    string `json:"note"`
+}
+
+func (q *Queries) CreateBotPattern(ctx context.Context, arg CreateBotPatternParams) error {
+	_, err := q.db.ExecContext(ctx, createBotPattern, arg.Pattern, arg.Note)
+	return err
+}
+
+const deleteBotPattern = `-- name: DeleteBotPattern :exec
+DELETE FROM bot_patterns WHERE id = ?
+`
+
+func (q *Queries) DeleteBotPattern(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteBotPattern, id)
+	return err
+}
+
+const listBotPatterns = `-- name: ListBotPatterns :many
+SELECT id, pattern, note, created_at FROM bot_patterns
+ORDER BY pattern COLLATE NOCASE, id
+`
+
+func (q *Queries) ListBotPatterns(ctx context.Context) ([]BotPattern, error) {
+	rows, err := q.db.QueryContext(ctx, listBotPatterns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BotPattern{}
+	for rows.Next() {
+		var i BotPattern
+		if err := rows.Scan(
+			&i.ID,
+			&i.Pattern,
+			&i.Note,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,93 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: indieauth.sql
+
+package dbgen
+
+import (
+	"context"
+)
+
+const createIndieAuthCode = `-- name: CreateIndieAuthCode :exec
+INSERT INTO indieauth_codes (code_hash, client_id, redirect_uri, scope, code_challenge)
+VALUES (?, ?, ?, ?, ?)
+`
+
+type CreateIndieAuthCodeParams struct {
+	CodeHash      string `json:"code_hash"`
+	ClientID      string `json:"client_id"`
+	RedirectUri   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	CodeChallenge string `json:"code_challenge"`
+}
+
+func (q *Queries) CreateIndieAuthCode(ctx context.Context, arg CreateIndieAuthCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createIndieAuthCode,
+		arg.CodeHash,
+		arg.ClientID,
+		arg.RedirectUri,
+		arg.Scope,
+		arg.CodeChallenge,
+	)
+	return err
+}
+
+const createIndieAuthToken = `-- name: CreateIndieAuthToken :exec
+INSERT INTO api_tokens (name, token_hash, scope, client_id)
+VALUES (?, ?, ?, ?)
+`
+
+type CreateIndieAuthTokenParams struct {
+	Name      string `json:"name"`
+	TokenHash string `json:"token_hash"`
+	Scope     string `json:"scope"`
+	ClientID  string `json:"client_id"`
+}
+
+func (q *Queries) CreateIndieAuthToken(ctx context.Context, arg CreateIndieAuthTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createIndieAuthToken,
+		arg.Name,
+		arg.TokenHash,
+		arg.Scope,
+		arg.ClientID,
+	)
+	return err
+}
+
+const deleteAPITokenByHash = `-- name: DeleteAPITokenByHash :exec
+DELETE FROM api_tokens WHERE token_hash = ?
+`
+
+func (q *Queries) DeleteAPITokenByHash(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteAPITokenByHash, tokenHash)
+	return err
+}
+
+const pruneIndieAuthCodes = `-- name: PruneIndieAuthCodes :exec
+DELETE FROM indieauth_codes WHERE created_at < CAST(?1 AS TEXT)
+`
+
+func (q *Queries) PruneIndieAuthCodes(ctx context.Context, before string) error {
+	_, err := q.db.ExecContext(ctx, pruneIndieAuthCodes, before)
+	return err
+}
+
+const takeIndieAuthCode = `-- name: TakeIndieAuthCode :one
+DELETE FROM indieauth_codes WHERE code_hash = ?
+RETURNING code_hash, client_id, redirect_uri, scope, code_challenge, created_at
+`
+
+func (q *Queries) TakeIndieAuthCode(ctx context.Context, codeHash string) (IndieauthCode, error) {
+	row := q.db.QueryRowContext(ctx, takeIndieAuthCode, codeHash)
+	var i IndieauthCode
+	err := row.Scan(
+		&i.CodeHash,
+		&i.ClientID,
+		&i.RedirectUri,
+		&i.Scope,
+		&i.CodeChallenge,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,57 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: locks.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const getPostLock = `-- name: GetPostLock :one
+SELECT post_id, editor, expires_at
+FROM post_locks
+WHERE post_id = ?
+`
+
+func (q *Queries) GetPostLock(ctx context.Context, postID int64) (PostLock, error) {
+	row := q.db.QueryRowContext(ctx, getPostLock, postID)
+	var i PostLock
+	err := row.Scan(&i.PostID, &i.Editor, &i.ExpiresAt)
+	return i, err
+}
+
+const releasePostLock = `-- name: ReleasePostLock :exec
+DELETE FROM post_locks
+WHERE post_id = ? AND editor = ?
+`
+
+type ReleasePostLockParams struct {
+	PostID int64  `json:"post_id"`
+	Editor string `json:"editor"`
+}
+
+func (q *Queries) ReleasePostLock(ctx context.Context, arg ReleasePostLockParams) error {
+	_, err := q.db.ExecContext(ctx, releasePostLock, arg.PostID, arg.Editor)
+	return err
+}
+
+const upsertPostLock = `-- name: UpsertPostLock :exec
+INSERT INTO post_locks (post_id, editor, expires_at)
+VALUES (?, ?, ?)
+ON CONFLICT (post_id) DO UPDATE
+SET editor = excluded.editor, expires_at = excluded.expires_at
+`
+
+type UpsertPostLockParams struct {
+	PostID    int64     `json:"post_id"`
+	Editor    string    `json:"editor"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) UpsertPostLock(ctx context.Context, arg UpsertPostLockParams) error {
+	_, err := q.db.ExecContext(ctx, upsertPostLock, arg.PostID, arg.Editor, arg.ExpiresAt)
+	return err
+}
@@ -0,0 +1,117 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: mail.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const listPendingMail = `-- name: ListPendingMail :many
+SELECT id, recipient, subject, text_body, html_body, headers, attempts, error, created_at, sent_at, next_attempt_at FROM mail_queue
+WHERE next_attempt_at IS NOT NULL
+ORDER BY id
+`
+
+func (q *Queries) ListPendingMail(ctx context.Context) ([]MailQueue, error) {
+	rows, err := q.db.QueryContext(ctx, listPendingMail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []MailQueue{}
+	for rows.Next() {
+		var i MailQueue
+		if err := rows.Scan(
+			&i.ID,
+			&i.Recipient,
+			&i.Subject,
+			&i.TextBody,
+			&i.HtmlBody,
+			&i.Headers,
+			&i.Attempts,
+			&i.Error,
+			&i.CreatedAt,
+			&i.SentAt,
+			&i.NextAttemptAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneMailQueue = `-- name: PruneMailQueue :exec
+DELETE FROM mail_queue
+WHERE next_attempt_at IS NULL AND created_at < CAST(?1 AS TEXT)
+`
+
+func (q *Queries) PruneMailQueue(ctx context.Context, before string) error {
+	_, err := q.db.ExecContext(ctx, pruneMailQueue, before)
+	return err
+}
+
+const queueMail = `-- name: QueueMail :one
+INSERT INTO mail_queue (recipient, subject, text_body, html_body, headers, next_attempt_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id
+`
+
+type QueueMailParams struct {
+	Recipient     string     `json:"recipient"`
+	Subject       string     `json:"subject"`
+	TextBody      string     `json:"text_body"`
+	HtmlBody      string     `json:"html_body"`
+	Headers       string     `json:"headers"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+}
+
+func (q *Queries) QueueMail(ctx context.Context, arg QueueMailParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, queueMail,
+		arg.Recipient,
+		arg.Subject,
+		arg.TextBody,
+		arg.HtmlBody,
+		arg.Headers,
+		arg.NextAttemptAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const recordMailAttempt = `-- name: RecordMailAttempt :exec
+UPDATE mail_queue
+SET attempts = attempts + 1,
+    error = ?,
+    sent_at = ?,
+    next_attempt_at = ?
+WHERE id = ?
+`
+
+type RecordMailAttemptParams struct {
+	Error         string     `json:"error"`
+	SentAt        *time.Time `json:"sent_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	ID            int64      `json:"id"`
+}
+
+func (q *Queries) RecordMailAttempt(ctx context.Context, arg RecordMailAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, recordMailAttempt,
+		arg.Error,
+		arg.SentAt,
+		arg.NextAttemptAt,
+		arg.ID,
+	)
+	return err
+}
@@ -8,25 +8,371 @@ import (
 	"time"
 )
 
+type ActivityDelivery struct {
+	ID            int64      `json:"id"`
+	Inbox         string     `json:"inbox"`
+	Activity      string     `json:"activity"`
+	Attempts      int64      `json:"attempts"`
+	StatusCode    int64      `json:"status_code"`
+	Error         string     `json:"error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+}
+
+type ApiToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"token_hash"`
+	Scope      string     `json:"scope"`
+	ClientID   string     `json:"client_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+type BackupCode struct {
+	UserID   int64  `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+type BlueskyPost struct {
+	PostID        int64      `json:"post_id"`
+	Attempts      int64      `json:"attempts"`
+	Error         string     `json:"error"`
+	Uri           string     `json:"uri"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PostedAt      *time.Time `json:"posted_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+}
+
+type BotPattern struct {
+	ID        int64     `json:"id"`
+	Pattern   string    `json:"pattern"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Category struct {
+	ID          int64     `json:"id"`
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Comment struct {
+	ID              int64     `json:"id"`
+	PostID          int64     `json:"post_id"`
+	AuthorName      string    `json:"author_name"`
+	AuthorEmail     string    `json:"author_email"`
+	AuthorUrl       string    `json:"author_url"`
+	Body            string    `json:"body"`
+	Status          string    `json:"status"`
+	Ip              string    `json:"ip"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	ParentCommentID *int64    `json:"parent_comment_id"`
+}
+
+type ContactMessage struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Sender    string     `json:"sender"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type DailyVisitor struct {
+	Day     string `json:"day"`
+	Visitor string `json:"visitor"`
+}
+
+type Follower struct {
+	ID          int64     `json:"id"`
+	Actor       string    `json:"actor"`
+	Inbox       string    `json:"inbox"`
+	SharedInbox string    `json:"shared_inbox"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type IndieauthCode struct {
+	CodeHash      string    `json:"code_hash"`
+	ClientID      string    `json:"client_id"`
+	RedirectUri   string    `json:"redirect_uri"`
+	Scope         string    `json:"scope"`
+	CodeChallenge string    `json:"code_challenge"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type IpBan struct {
+	ID        int64     `json:"id"`
+	Prefix    string    `json:"prefix"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LinkClick struct {
+	PostID int64  `json:"post_id"`
+	Day    string `json:"day"`
+	Target string `json:"target"`
+	Clicks int64  `json:"clicks"`
+}
+
+type LoginChallenge struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int64     `json:"attempts"`
+}
+
+type LoginFailure struct {
+	Ip            string     `json:"ip"`
+	Failures      int64      `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+type LoginLink struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    int64     `json:"user_id"`
+	Redirect  string    `json:"redirect"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type MailQueue struct {
+	ID            int64      `json:"id"`
+	Recipient     string     `json:"recipient"`
+	Subject       string     `json:"subject"`
+	TextBody      string     `json:"text_body"`
+	HtmlBody      string     `json:"html_body"`
+	Headers       string     `json:"headers"`
+	Attempts      int64      `json:"attempts"`
+	Error         string     `json:"error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+}
+
+type Media struct {
+	ID           int64     `json:"id"`
+	Filename     string    `json:"filename"`
+	OriginalName string    `json:"original_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int64     `json:"width"`
+	Height       int64     `json:"height"`
+	CreatedAt    time.Time `json:"created_at"`
+	Sha256       *string   `json:"sha256"`
+}
+
 type Migration struct {
 	MigrationNumber int64     `json:"migration_number"`
 	MigrationName   string    `json:"migration_name"`
 	ExecutedAt      time.Time `json:"executed_at"`
 }
 
+type OembedCache struct {
+	Url       string    `json:"url"`
+	Html      string    `json:"html"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+type PageView struct {
+	Day   string `json:"day"`
+	Path  string `json:"path"`
+	Views int64  `json:"views"`
+}
+
 type Post struct {
+	ID              int64      `json:"id"`
+	Slug            string     `json:"slug"`
+	Title           string     `json:"title"`
+	Content         string     `json:"content"`
+	Published       int64      `json:"published"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	AllowHtml       int64      `json:"allow_html"`
+	MetaDescription string     `json:"meta_description"`
+	OgImage         string     `json:"og_image"`
+	CoverImage      string     `json:"cover_image"`
+	SeriesID        *int64     `json:"series_id"`
+	SeriesOrder     int64      `json:"series_order"`
+	CategoryID      *int64     `json:"category_id"`
+	PublishAt       *time.Time `json:"publish_at"`
+	DeletedAt       *time.Time `json:"deleted_at"`
+	Excerpt         string     `json:"excerpt"`
+	CommentsClosed  int64      `json:"comments_closed"`
+	NoCrosspost     int64      `json:"no_crosspost"`
+}
+
+type PostAnnouncement struct {
+	PostID      int64     `json:"post_id"`
+	AnnouncedAt time.Time `json:"announced_at"`
+}
+
+type PostAutosave struct {
+	PostID  int64     `json:"post_id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Excerpt string    `json:"excerpt"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+type PostLike struct {
+	PostID    int64     `json:"post_id"`
+	Visitor   string    `json:"visitor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PostLock struct {
+	PostID    int64     `json:"post_id"`
+	Editor    string    `json:"editor"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type PostReferrer struct {
+	PostID int64  `json:"post_id"`
+	Day    string `json:"day"`
+	Host   string `json:"host"`
+	Visits int64  `json:"visits"`
+}
+
+type PostRevision struct {
+	ID      int64     `json:"id"`
+	PostID  int64     `json:"post_id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+type PostTag struct {
+	PostID int64 `json:"post_id"`
+	TagID  int64 `json:"tag_id"`
+}
+
+type PostView struct {
+	PostID int64  `json:"post_id"`
+	Day    string `json:"day"`
+	Views  int64  `json:"views"`
+}
+
+type PostsFt struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+type PreviewLink struct {
 	ID        int64     `json:"id"`
-	Slug      string    `json:"slug"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Published int64     `json:"published"`
+	PostID    int64     `json:"post_id"`
+	Token     string    `json:"token"`
 	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type Redirect struct {
+	OldSlug   string    `json:"old_slug"`
+	NewSlug   string    `json:"new_slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Series struct {
+	ID          int64     `json:"id"`
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Session struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type Setting struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+type Subscriber struct {
+	ID          int64      `json:"id"`
+	Email       string     `json:"email"`
+	Token       string     `json:"token"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+}
+
+type Tag struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type User struct {
+	ID           int64     `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+	TotpSecret   string    `json:"totp_secret"`
+	TotpLastStep int64     `json:"totp_last_step"`
+}
+
 type Visitor struct {
 	ID        string    `json:"id"`
 	ViewCount int64     `json:"view_count"`
 	CreatedAt time.Time `json:"created_at"`
 	LastSeen  time.Time `json:"last_seen"`
 }
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    string    `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID            int64      `json:"id"`
+	WebhookID     int64      `json:"webhook_id"`
+	Event         string     `json:"event"`
+	Payload       string     `json:"payload"`
+	Attempts      int64      `json:"attempts"`
+	StatusCode    int64      `json:"status_code"`
+	Error         string     `json:"error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+}
+
+type Webmention struct {
+	ID         int64      `json:"id"`
+	PostID     int64      `json:"post_id"`
+	Source     string     `json:"source"`
+	Target     string     `json:"target"`
+	Status     string     `json:"status"`
+	Title      string     `json:"title"`
+	NeedsCheck int64      `json:"needs_check"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type WebmentionSend struct {
+	ID            int64      `json:"id"`
+	PostID        int64      `json:"post_id"`
+	Source        string     `json:"source"`
+	Target        string     `json:"target"`
+	Endpoint      string     `json:"endpoint"`
+	Attempts      int64      `json:"attempts"`
+	StatusCode    int64      `json:"status_code"`
+	Error         string     `json:"error"`
+	QueuedAt      time.Time  `json:"queued_at"`
+	SentAt        *time.Time `json:"sent_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+}
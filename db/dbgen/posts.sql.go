@@ -10,17 +10,132 @@ import (
 	"time"
 )
 
+const countAdminPosts = `-- name: CountAdminPosts :one
+SELECT COUNT(*)
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(?1 AS INTEGER) IS NULL OR published = CAST(?1 AS INTEGER))
+  AND (CAST(?2 AS TEXT) = '' OR instr(lower(title), lower(CAST(?2 AS TEXT))) > 0 OR instr(slug, lower(CAST(?2 AS TEXT))) > 0)
+`
+
+type CountAdminPostsParams struct {
+	Published *int64 `json:"published"`
+	Search    string `json:"search"`
+}
+
+func (q *Queries) CountAdminPosts(ctx context.Context, arg CountAdminPostsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAdminPosts, arg.Published, arg.Search)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countPostsByStatus = `-- name: CountPostsByStatus :many
+SELECT published, COUNT(*) AS post_count
+FROM posts
+WHERE deleted_at IS NULL
+GROUP BY published
+`
+
+type CountPostsByStatusRow struct {
+	Published int64 `json:"published"`
+	PostCount int64 `json:"post_count"`
+}
+
+func (q *Queries) CountPostsByStatus(ctx context.Context) ([]CountPostsByStatusRow, error) {
+	rows, err := q.db.QueryContext(ctx, countPostsByStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountPostsByStatusRow{}
+	for rows.Next() {
+		var i CountPostsByStatusRow
+		if err := rows.Scan(&i.Published, &i.PostCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countPublishedPosts = `-- name: CountPublishedPosts :one
+SELECT COUNT(*)
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL
+`
+
+func (q *Queries) CountPublishedPosts(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPublishedPosts)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countPublishedPostsByWeek = `-- name: CountPublishedPostsByWeek :many
+SELECT CAST(date(created_at, 'weekday 0', '-6 days') AS TEXT) AS week_start, COUNT(*) AS post_count
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL AND created_at >= CAST(?1 AS TEXT)
+GROUP BY week_start
+ORDER BY week_start
+`
+
+type CountPublishedPostsByWeekRow struct {
+	WeekStart string `json:"week_start"`
+	PostCount int64  `json:"post_count"`
+}
+
+func (q *Queries) CountPublishedPostsByWeek(ctx context.Context, since string) ([]CountPublishedPostsByWeekRow, error) {
+	rows, err := q.db.QueryContext(ctx, countPublishedPostsByWeek, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountPublishedPostsByWeekRow{}
+	for rows.Next() {
+		var i CountPublishedPostsByWeekRow
+		if err := rows.Scan(&i.WeekStart, &i.PostCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (slug, title, content, published, created_at, updated_at)
-VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-RETURNING id, slug, title, content, published, created_at, updated_at
+INSERT INTO posts (slug, title, content, excerpt, published, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, comments_closed, no_crosspost, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+RETURNING id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
 `
 
 type CreatePostParams struct {
-	Slug      string `json:"slug"`
-	Title     string `json:"title"`
-	Content   string `json:"content"`
-	Published int64  `json:"published"`
+	Slug            string     `json:"slug"`
+	Title           string     `json:"title"`
+	Content         string     `json:"content"`
+	Excerpt         string     `json:"excerpt"`
+	Published       int64      `json:"published"`
+	AllowHtml       int64      `json:"allow_html"`
+	MetaDescription string     `json:"meta_description"`
+	OgImage         string     `json:"og_image"`
+	CoverImage      string     `json:"cover_image"`
+	SeriesID        *int64     `json:"series_id"`
+	SeriesOrder     int64      `json:"series_order"`
+	CategoryID      *int64     `json:"category_id"`
+	PublishAt       *time.Time `json:"publish_at"`
+	CommentsClosed  int64      `json:"comments_closed"`
+	NoCrosspost     int64      `json:"no_crosspost"`
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -28,7 +143,18 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Slug,
 		arg.Title,
 		arg.Content,
+		arg.Excerpt,
 		arg.Published,
+		arg.AllowHtml,
+		arg.MetaDescription,
+		arg.OgImage,
+		arg.CoverImage,
+		arg.SeriesID,
+		arg.SeriesOrder,
+		arg.CategoryID,
+		arg.PublishAt,
+		arg.CommentsClosed,
+		arg.NoCrosspost,
 	)
 	var i Post
 	err := row.Scan(
@@ -39,43 +165,48 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Published,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.AllowHtml,
+		&i.MetaDescription,
+		&i.OgImage,
+		&i.CoverImage,
+		&i.SeriesID,
+		&i.SeriesOrder,
+		&i.CategoryID,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.Excerpt,
+		&i.CommentsClosed,
+		&i.NoCrosspost,
 	)
 	return i, err
 }
 
-const deletePost = `-- name: DeletePost :exec
-DELETE FROM posts WHERE id = ?
+const getArchiveMonths = `-- name: GetArchiveMonths :many
+SELECT CAST(strftime('%Y', created_at) AS INTEGER) AS year,
+       CAST(strftime('%m', created_at) AS INTEGER) AS month,
+       COUNT(*) AS post_count
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL
+GROUP BY year, month
+ORDER BY year DESC, month DESC
 `
 
-func (q *Queries) DeletePost(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deletePost, id)
-	return err
+type GetArchiveMonthsRow struct {
+	Year      int64 `json:"year"`
+	Month     int64 `json:"month"`
+	PostCount int64 `json:"post_count"`
 }
 
-const getAllPosts = `-- name: GetAllPosts :many
-SELECT id, slug, title, content, published, created_at, updated_at
-FROM posts
-ORDER BY created_at DESC
-`
-
-func (q *Queries) GetAllPosts(ctx context.Context) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getAllPosts)
+func (q *Queries) GetArchiveMonths(ctx context.Context) ([]GetArchiveMonthsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getArchiveMonths)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Post{}
+	items := []GetArchiveMonthsRow{}
 	for rows.Next() {
-		var i Post
-		if err := rows.Scan(
-			&i.ID,
-			&i.Slug,
-			&i.Title,
-			&i.Content,
-			&i.Published,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-		); err != nil {
+		var i GetArchiveMonthsRow
+		if err := rows.Scan(&i.Year, &i.Month, &i.PostCount); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -89,8 +220,62 @@ func (q *Queries) GetAllPosts(ctx context.Context) ([]Post, error) {
 	return items, nil
 }
 
+const getNextPost = `-- name: GetNextPost :one
+SELECT p.slug, p.title
+FROM posts p, posts cur
+WHERE cur.id = ? AND p.published = 1 AND p.deleted_at IS NULL
+  AND (p.created_at > cur.created_at OR (p.created_at = cur.created_at AND p.id > cur.id))
+ORDER BY p.created_at, p.id
+LIMIT 1
+`
+
+type GetNextPostRow struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+func (q *Queries) GetNextPost(ctx context.Context, id int64) (GetNextPostRow, error) {
+	row := q.db.QueryRowContext(ctx, getNextPost, id)
+	var i GetNextPostRow
+	err := row.Scan(&i.Slug, &i.Title)
+	return i, err
+}
+
+const getPostByID = `-- name: GetPostByID :one
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE id = ?
+`
+
+func (q *Queries) GetPostByID(ctx context.Context, id int64) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByID, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Slug,
+		&i.Title,
+		&i.Content,
+		&i.Published,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AllowHtml,
+		&i.MetaDescription,
+		&i.OgImage,
+		&i.CoverImage,
+		&i.SeriesID,
+		&i.SeriesOrder,
+		&i.CategoryID,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.Excerpt,
+		&i.CommentsClosed,
+		&i.NoCrosspost,
+	)
+	return i, err
+}
+
 const getPostBySlug = `-- name: GetPostBySlug :one
-SELECT id, slug, title, content, published, created_at, updated_at
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
 FROM posts
 WHERE slug = ?
 `
@@ -106,42 +291,179 @@ func (q *Queries) GetPostBySlug(ctx context.Context, slug string) (Post, error)
 		&i.Published,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.AllowHtml,
+		&i.MetaDescription,
+		&i.OgImage,
+		&i.CoverImage,
+		&i.SeriesID,
+		&i.SeriesOrder,
+		&i.CategoryID,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.Excerpt,
+		&i.CommentsClosed,
+		&i.NoCrosspost,
 	)
 	return i, err
 }
 
+const getPreviousPost = `-- name: GetPreviousPost :one
+SELECT p.slug, p.title
+FROM posts p, posts cur
+WHERE cur.id = ? AND p.published = 1 AND p.deleted_at IS NULL
+  AND (p.created_at < cur.created_at OR (p.created_at = cur.created_at AND p.id < cur.id))
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT 1
+`
+
+type GetPreviousPostRow struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+func (q *Queries) GetPreviousPost(ctx context.Context, id int64) (GetPreviousPostRow, error) {
+	row := q.db.QueryRowContext(ctx, getPreviousPost, id)
+	var i GetPreviousPostRow
+	err := row.Scan(&i.Slug, &i.Title)
+	return i, err
+}
+
 const getPublishedPosts = `-- name: GetPublishedPosts :many
-SELECT id, slug, title, content, created_at, updated_at
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
 FROM posts
-WHERE published = 1
+WHERE published = 1 AND deleted_at IS NULL
 ORDER BY created_at DESC
 `
 
-type GetPublishedPostsRow struct {
-	ID        int64     `json:"id"`
-	Slug      string    `json:"slug"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+func (q *Queries) GetPublishedPosts(ctx context.Context) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPublishedPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPublishedPostsInMonth = `-- name: GetPublishedPostsInMonth :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL AND strftime('%Y-%m', created_at) = CAST(?1 AS TEXT)
+ORDER BY created_at DESC, id DESC
+`
+
+func (q *Queries) GetPublishedPostsInMonth(ctx context.Context, month string) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPublishedPostsInMonth, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-func (q *Queries) GetPublishedPosts(ctx context.Context) ([]GetPublishedPostsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPublishedPosts)
+const getPublishedPostsInYear = `-- name: GetPublishedPostsInYear :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL AND strftime('%Y', created_at) = CAST(?1 AS TEXT)
+ORDER BY created_at DESC, id DESC
+`
+
+func (q *Queries) GetPublishedPostsInYear(ctx context.Context, year string) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPublishedPostsInYear, year)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []GetPublishedPostsRow{}
+	items := []Post{}
 	for rows.Next() {
-		var i GetPublishedPostsRow
+		var i Post
 		if err := rows.Scan(
 			&i.ID,
 			&i.Slug,
 			&i.Title,
 			&i.Content,
+			&i.Published,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
 		); err != nil {
 			return nil, err
 		}
@@ -156,24 +478,665 @@ func (q *Queries) GetPublishedPosts(ctx context.Context) ([]GetPublishedPostsRow
 	return items, nil
 }
 
-const updatePost = `-- name: UpdatePost :exec
-UPDATE posts
-SET title = ?, content = ?, published = ?, updated_at = CURRENT_TIMESTAMP
-WHERE id = ?
+const getPublishedPostsPage = `-- name: GetPublishedPostsPage :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT ? OFFSET ?
 `
 
-type UpdatePostParams struct {
-	Title     string `json:"title"`
-	Content   string `json:"content"`
-	Published int64  `json:"published"`
-	ID        int64  `json:"id"`
+type GetPublishedPostsPageParams struct {
+	Limit  int64 `json:"limit"`
+	Offset int64 `json:"offset"`
 }
 
-func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) error {
-	_, err := q.db.ExecContext(ctx, updatePost,
+func (q *Queries) GetPublishedPostsPage(ctx context.Context, arg GetPublishedPostsPageParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPublishedPostsPage, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTrashedPosts = `-- name: GetTrashedPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+`
+
+func (q *Queries) GetTrashedPosts(ctx context.Context) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getTrashedPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAPIPosts = `-- name: ListAPIPosts :many
+
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(?1 AS INTEGER) IS NULL OR published = CAST(?1 AS INTEGER))
+  AND (CAST(?2 AS TEXT) = '' OR id IN (
+    SELECT post_tags.post_id
+    FROM post_tags
+    JOIN tags ON tags.id = post_tags.tag_id
+    WHERE tags.slug = CAST(?2 AS TEXT)))
+  AND updated_at >= CAST(?3 AS TEXT)
+  AND (CAST(?4 AS INTEGER) = 0
+    OR created_at < CAST(?5 AS TEXT)
+    OR (created_at = CAST(?5 AS TEXT) AND id < CAST(?4 AS INTEGER)))
+ORDER BY created_at DESC, id DESC
+LIMIT ?6
+`
+
+type ListAPIPostsParams struct {
+	Published    *int64 `json:"published"`
+	Tag          string `json:"tag"`
+	Since        string `json:"since"`
+	AfterID      int64  `json:"after_id"`
+	AfterCreated string `json:"after_created"`
+	Limit        int64  `json:"limit"`
+}
+
+// The posts API lists posts newest first, filtered by status (NULL for
+// any), tag slug (” for any) and the time of their last change, a page
+// at a time after a cursor: the created_at and id of the last post of the
+// page before, or an id of 0 for the first page.
+func (q *Queries) ListAPIPosts(ctx context.Context, arg ListAPIPostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listAPIPosts,
+		arg.Published,
+		arg.Tag,
+		arg.Since,
+		arg.AfterID,
+		arg.AfterCreated,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAdminPostsByCreated = `-- name: ListAdminPostsByCreated :many
+
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(?1 AS INTEGER) IS NULL OR published = CAST(?1 AS INTEGER))
+  AND (CAST(?2 AS TEXT) = '' OR instr(lower(title), lower(CAST(?2 AS TEXT))) > 0 OR instr(slug, lower(CAST(?2 AS TEXT))) > 0)
+ORDER BY created_at DESC, id DESC
+LIMIT ?4 OFFSET ?3
+`
+
+type ListAdminPostsByCreatedParams struct {
+	Published *int64 `json:"published"`
+	Search    string `json:"search"`
+	Offset    int64  `json:"offset"`
+	Limit     int64  `json:"limit"`
+}
+
+// The admin post list is filtered by status (NULL for any) and by a search
+// matching anywhere in the title or slug, and sorted one of three ways.
+func (q *Queries) ListAdminPostsByCreated(ctx context.Context, arg ListAdminPostsByCreatedParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listAdminPostsByCreated,
+		arg.Published,
+		arg.Search,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAdminPostsByTitle = `-- name: ListAdminPostsByTitle :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(?1 AS INTEGER) IS NULL OR published = CAST(?1 AS INTEGER))
+  AND (CAST(?2 AS TEXT) = '' OR instr(lower(title), lower(CAST(?2 AS TEXT))) > 0 OR instr(slug, lower(CAST(?2 AS TEXT))) > 0)
+ORDER BY title COLLATE NOCASE, id
+LIMIT ?4 OFFSET ?3
+`
+
+type ListAdminPostsByTitleParams struct {
+	Published *int64 `json:"published"`
+	Search    string `json:"search"`
+	Offset    int64  `json:"offset"`
+	Limit     int64  `json:"limit"`
+}
+
+func (q *Queries) ListAdminPostsByTitle(ctx context.Context, arg ListAdminPostsByTitleParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listAdminPostsByTitle,
+		arg.Published,
+		arg.Search,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAdminPostsByUpdated = `-- name: ListAdminPostsByUpdated :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(?1 AS INTEGER) IS NULL OR published = CAST(?1 AS INTEGER))
+  AND (CAST(?2 AS TEXT) = '' OR instr(lower(title), lower(CAST(?2 AS TEXT))) > 0 OR instr(slug, lower(CAST(?2 AS TEXT))) > 0)
+ORDER BY updated_at DESC, id DESC
+LIMIT ?4 OFFSET ?3
+`
+
+type ListAdminPostsByUpdatedParams struct {
+	Published *int64 `json:"published"`
+	Search    string `json:"search"`
+	Offset    int64  `json:"offset"`
+	Limit     int64  `json:"limit"`
+}
+
+func (q *Queries) ListAdminPostsByUpdated(ctx context.Context, arg ListAdminPostsByUpdatedParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listAdminPostsByUpdated,
+		arg.Published,
+		arg.Search,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllPosts = `-- name: ListAllPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost FROM posts
+WHERE deleted_at IS NULL
+ORDER BY created_at, id
+`
+
+// Every post not in the trash, oldest first, for exports.
+func (q *Queries) ListAllPosts(ctx context.Context) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listAllPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledPosts = `-- name: ListScheduledPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 0 AND deleted_at IS NULL AND publish_at IS NOT NULL
+ORDER BY datetime(publish_at)
+`
+
+func (q *Queries) ListScheduledPosts(ctx context.Context) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Post{}
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Content,
+			&i.Published,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AllowHtml,
+			&i.MetaDescription,
+			&i.OgImage,
+			&i.CoverImage,
+			&i.SeriesID,
+			&i.SeriesOrder,
+			&i.CategoryID,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.Excerpt,
+			&i.CommentsClosed,
+			&i.NoCrosspost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const publishDuePosts = `-- name: PublishDuePosts :many
+UPDATE posts
+SET published = 1, created_at = datetime(publish_at), updated_at = CURRENT_TIMESTAMP, publish_at = NULL
+WHERE published = 0 AND deleted_at IS NULL AND publish_at IS NOT NULL AND datetime(publish_at) <= datetime('now')
+RETURNING id
+`
+
+func (q *Queries) PublishDuePosts(ctx context.Context) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, publishDuePosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgePost = `-- name: PurgePost :exec
+DELETE FROM posts WHERE id = ? AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) PurgePost(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, purgePost, id)
+	return err
+}
+
+const restorePost = `-- name: RestorePost :exec
+UPDATE posts SET deleted_at = NULL WHERE id = ?
+`
+
+func (q *Queries) RestorePost(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, restorePost, id)
+	return err
+}
+
+const setPostCreatedAt = `-- name: SetPostCreatedAt :exec
+UPDATE posts
+SET created_at = CAST(?1 AS TEXT)
+WHERE id = ?2
+`
+
+type SetPostCreatedAtParams struct {
+	CreatedAt string `json:"created_at"`
+	ID        int64  `json:"id"`
+}
+
+func (q *Queries) SetPostCreatedAt(ctx context.Context, arg SetPostCreatedAtParams) error {
+	_, err := q.db.ExecContext(ctx, setPostCreatedAt, arg.CreatedAt, arg.ID)
+	return err
+}
+
+const setPostPublished = `-- name: SetPostPublished :exec
+UPDATE posts
+SET published = ?, publish_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND deleted_at IS NULL
+`
+
+type SetPostPublishedParams struct {
+	Published int64 `json:"published"`
+	ID        int64 `json:"id"`
+}
+
+func (q *Queries) SetPostPublished(ctx context.Context, arg SetPostPublishedParams) error {
+	_, err := q.db.ExecContext(ctx, setPostPublished, arg.Published, arg.ID)
+	return err
+}
+
+const suggestPosts = `-- name: SuggestPosts :many
+SELECT slug, title
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL AND title >= ?1 COLLATE NOCASE AND title < ?2 COLLATE NOCASE
+ORDER BY title COLLATE NOCASE
+LIMIT ?3
+`
+
+type SuggestPostsParams struct {
+	Prefix    string `json:"prefix"`
+	PrefixEnd string `json:"prefix_end"`
+	Limit     int64  `json:"limit"`
+}
+
+type SuggestPostsRow struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+func (q *Queries) SuggestPosts(ctx context.Context, arg SuggestPostsParams) ([]SuggestPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, suggestPosts, arg.Prefix, arg.PrefixEnd, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SuggestPostsRow{}
+	for rows.Next() {
+		var i SuggestPostsRow
+		if err := rows.Scan(&i.Slug, &i.Title); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const trashPost = `-- name: TrashPost :exec
+UPDATE posts SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?
+`
+
+func (q *Queries) TrashPost(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, trashPost, id)
+	return err
+}
+
+const updatePost = `-- name: UpdatePost :exec
+UPDATE posts
+SET slug = ?, title = ?, content = ?, excerpt = ?, published = ?, allow_html = ?, meta_description = ?, og_image = ?, cover_image = ?, series_id = ?, series_order = ?, category_id = ?, publish_at = ?, comments_closed = ?, no_crosspost = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type UpdatePostParams struct {
+	Slug            string     `json:"slug"`
+	Title           string     `json:"title"`
+	Content         string     `json:"content"`
+	Excerpt         string     `json:"excerpt"`
+	Published       int64      `json:"published"`
+	AllowHtml       int64      `json:"allow_html"`
+	MetaDescription string     `json:"meta_description"`
+	OgImage         string     `json:"og_image"`
+	CoverImage      string     `json:"cover_image"`
+	SeriesID        *int64     `json:"series_id"`
+	SeriesOrder     int64      `json:"series_order"`
+	CategoryID      *int64     `json:"category_id"`
+	PublishAt       *time.Time `json:"publish_at"`
+	CommentsClosed  int64      `json:"comments_closed"`
+	NoCrosspost     int64      `json:"no_crosspost"`
+	ID              int64      `json:"id"`
+}
+
+func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) error {
+	_, err := q.db.ExecContext(ctx, updatePost,
+		arg.Slug,
 		arg.Title,
 		arg.Content,
+		arg.Excerpt,
 		arg.Published,
+		arg.AllowHtml,
+		arg.MetaDescription,
+		arg.OgImage,
+		arg.CoverImage,
+		arg.SeriesID,
+		arg.SeriesOrder,
+		arg.CategoryID,
+		arg.PublishAt,
+		arg.CommentsClosed,
+		arg.NoCrosspost,
 		arg.ID,
 	)
 	return err
@@ -0,0 +1,106 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: previews.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const createPreviewLink = `-- name: CreatePreviewLink :one
+INSERT INTO preview_links (post_id, token, expires_at)
+VALUES (?, ?, ?)
+RETURNING id, post_id, token, created_at, expires_at
+`
+
+type CreatePreviewLinkParams struct {
+	PostID    int64     `json:"post_id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreatePreviewLink(ctx context.Context, arg CreatePreviewLinkParams) (PreviewLink, error) {
+	row := q.db.QueryRowContext(ctx, createPreviewLink, arg.PostID, arg.Token, arg.ExpiresAt)
+	var i PreviewLink
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.Token,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const deletePreviewLink = `-- name: DeletePreviewLink :exec
+DELETE FROM preview_links
+WHERE id = ? AND post_id = ?
+`
+
+type DeletePreviewLinkParams struct {
+	ID     int64 `json:"id"`
+	PostID int64 `json:"post_id"`
+}
+
+func (q *Queries) DeletePreviewLink(ctx context.Context, arg DeletePreviewLinkParams) error {
+	_, err := q.db.ExecContext(ctx, deletePreviewLink, arg.ID, arg.PostID)
+	return err
+}
+
+const getPreviewLink = `-- name: GetPreviewLink :one
+SELECT id, post_id, token, created_at, expires_at
+FROM preview_links
+WHERE token = ?
+`
+
+func (q *Queries) GetPreviewLink(ctx context.Context, token string) (PreviewLink, error) {
+	row := q.db.QueryRowContext(ctx, getPreviewLink, token)
+	var i PreviewLink
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.Token,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const listPreviewLinks = `-- name: ListPreviewLinks :many
+SELECT id, post_id, token, created_at, expires_at
+FROM preview_links
+WHERE post_id = ?
+ORDER BY id DESC
+`
+
+func (q *Queries) ListPreviewLinks(ctx context.Context, postID int64) ([]PreviewLink, error) {
+	rows, err := q.db.QueryContext(ctx, listPreviewLinks, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []PreviewLink{}
+	for rows.Next() {
+		var i PreviewLink
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.Token,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,94 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: redirects.sql
+
+package dbgen
+
+import (
+	"context"
+)
+
+const deleteRedirect = `-- name: DeleteRedirect :exec
+DELETE FROM redirects WHERE old_slug = ?
+`
+
+func (q *Queries) DeleteRedirect(ctx context.Context, oldSlug string) error {
+	_, err := q.db.ExecContext(ctx, deleteRedirect, oldSlug)
+	return err
+}
+
+const getRedirect = `-- name: GetRedirect :one
+SELECT new_slug
+FROM redirects
+WHERE old_slug = ?
+`
+
+func (q *Queries) GetRedirect(ctx context.Context, oldSlug string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getRedirect, oldSlug)
+	var new_slug string
+	err := row.Scan(&new_slug)
+	return new_slug, err
+}
+
+const getRedirects = `-- name: GetRedirects :many
+SELECT old_slug, new_slug, created_at
+FROM redirects
+ORDER BY created_at DESC, old_slug
+`
+
+func (q *Queries) GetRedirects(ctx context.Context) ([]Redirect, error) {
+	rows, err := q.db.QueryContext(ctx, getRedirects)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Redirect{}
+	for rows.Next() {
+		var i Redirect
+		if err := rows.Scan(&i.OldSlug, &i.NewSlug, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const retargetRedirects = `-- name: RetargetRedirects :exec
+UPDATE redirects
+SET new_slug = ?1
+WHERE new_slug = ?2
+`
+
+type RetargetRedirectsParams struct {
+	NewSlug string `json:"new_slug"`
+	OldSlug string `json:"old_slug"`
+}
+
+func (q *Queries) RetargetRedirects(ctx context.Context, arg RetargetRedirectsParams) error {
+	_, err := q.db.ExecContext(ctx, retargetRedirects, arg.NewSlug, arg.OldSlug)
+	return err
+}
+
+const upsertRedirect = `-- name: UpsertRedirect :exec
+INSERT INTO redirects (old_slug, new_slug, created_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (old_slug) DO UPDATE
+SET new_slug = excluded.new_slug, created_at = excluded.created_at
+`
+
+type UpsertRedirectParams struct {
+	OldSlug string `json:"old_slug"`
+	NewSlug string `json:"new_slug"`
+}
+
+func (q *Queries) UpsertRedirect(ctx context.Context, arg UpsertRedirectParams) error {
+	_, err := q.db.ExecContext(ctx, upsertRedirect, arg.OldSlug, arg.NewSlug)
+	return err
+}
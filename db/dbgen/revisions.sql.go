@@ -0,0 +1,112 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: revisions.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const getPostRevision = `-- name: GetPostRevision :one
+SELECT id, post_id, title, content, saved_at
+FROM post_revisions
+WHERE id = ? AND post_id = ?
+`
+
+type GetPostRevisionParams struct {
+	ID     int64 `json:"id"`
+	PostID int64 `json:"post_id"`
+}
+
+func (q *Queries) GetPostRevision(ctx context.Context, arg GetPostRevisionParams) (PostRevision, error) {
+	row := q.db.QueryRowContext(ctx, getPostRevision, arg.ID, arg.PostID)
+	var i PostRevision
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.Title,
+		&i.Content,
+		&i.SavedAt,
+	)
+	return i, err
+}
+
+const getPostRevisions = `-- name: GetPostRevisions :many
+SELECT id, post_id, title, saved_at
+FROM post_revisions
+WHERE post_id = ?
+ORDER BY id DESC
+`
+
+type GetPostRevisionsRow struct {
+	ID      int64     `json:"id"`
+	PostID  int64     `json:"post_id"`
+	Title   string    `json:"title"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+func (q *Queries) GetPostRevisions(ctx context.Context, postID int64) ([]GetPostRevisionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostRevisions, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetPostRevisionsRow{}
+	for rows.Next() {
+		var i GetPostRevisionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.Title,
+			&i.SavedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restorePostRevision = `-- name: RestorePostRevision :exec
+UPDATE posts
+SET title = ?, content = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type RestorePostRevisionParams struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	ID      int64  `json:"id"`
+}
+
+func (q *Queries) RestorePostRevision(ctx context.Context, arg RestorePostRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, restorePostRevision, arg.Title, arg.Content, arg.ID)
+	return err
+}
+
+const savePostRevision = `-- name: SavePostRevision :exec
+INSERT INTO post_revisions (post_id, title, content, saved_at)
+SELECT posts.id, posts.title, posts.content, posts.updated_at
+FROM posts
+WHERE posts.id = ?1 AND (posts.title != ?2 OR posts.content != ?3)
+`
+
+type SavePostRevisionParams struct {
+	PostID  int64  `json:"post_id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+func (q *Queries) SavePostRevision(ctx context.Context, arg SavePostRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, savePostRevision, arg.PostID, arg.Title, arg.Content)
+	return err
+}
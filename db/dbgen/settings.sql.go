@@ -0,0 +1,90 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: settings.sql
+
+package dbgen
+
+import (
+	"context"
+)
+
+const getSetting = `-- name: GetSetting :one
+SELECT value
+FROM settings
+WHERE key = ?
+`
+
+func (q *Queries) GetSetting(ctx context.Context, key string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getSetting, key)
+	var value string
+	err := row.Scan(&value)
+	return value, err
+}
+
+const getSettings = `-- name: GetSettings :many
+SELECT key, value
+FROM settings
+`
+
+type GetSettingsRow struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (q *Queries) GetSettings(ctx context.Context) ([]GetSettingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSettings)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetSettingsRow{}
+	for rows.Next() {
+		var i GetSettingsRow
+		if err := rows.Scan(&i.Key, &i.Value); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertSettingIfAbsent = `-- name: InsertSettingIfAbsent :exec
+INSERT INTO settings (key, value, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (key) DO NOTHING
+`
+
+type InsertSettingIfAbsentParams struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Sets a setting only if it has no value yet, for keys made on first use.
+func (q *Queries) InsertSettingIfAbsent(ctx context.Context, arg InsertSettingIfAbsentParams) error {
+	_, err := q.db.ExecContext(ctx, insertSettingIfAbsent, arg.Key, arg.Value)
+	return err
+}
+
+const upsertSetting = `-- name: UpsertSetting :exec
+INSERT INTO settings (key, value, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (key) DO UPDATE
+SET value = excluded.value, updated_at = excluded.updated_at
+`
+
+type UpsertSettingParams struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (q *Queries) UpsertSetting(ctx context.Context, arg UpsertSettingParams) error {
+	_, err := q.db.ExecContext(ctx, upsertSetting, arg.Key, arg.Value)
+	return err
+}
@@ -0,0 +1,105 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: tokens.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const createAPIToken = `-- name: CreateAPIToken :exec
+INSERT INTO api_tokens (name, token_hash, scope)
+VALUES (?, ?, ?)
+`
+
+type CreateAPITokenParams struct {
+	Name      string `json:"name"`
+	TokenHash string `json:"token_hash"`
+	Scope     string `json:"scope"`
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) error {
+	_, err := q.db.ExecContext(ctx, createAPIToken, arg.Name, arg.TokenHash, arg.Scope)
+	return err
+}
+
+const deleteAPIToken = `-- name: DeleteAPIToken :exec
+DELETE FROM api_tokens WHERE id = ?
+`
+
+func (q *Queries) DeleteAPIToken(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteAPIToken, id)
+	return err
+}
+
+const getAPIToken = `-- name: GetAPIToken :one
+SELECT id, name, token_hash, scope, client_id, created_at, last_used_at FROM api_tokens WHERE token_hash = ?
+`
+
+func (q *Queries) GetAPIToken(ctx context.Context, tokenHash string) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, getAPIToken, tokenHash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.ClientID,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const listAPITokens = `-- name: ListAPITokens :many
+SELECT id, name, token_hash, scope, client_id, created_at, last_used_at FROM api_tokens
+ORDER BY created_at DESC, id DESC
+`
+
+func (q *Queries) ListAPITokens(ctx context.Context) ([]ApiToken, error) {
+	rows, err := q.db.QueryContext(ctx, listAPITokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ApiToken{}
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Scope,
+			&i.ClientID,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const touchAPIToken = `-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = ? WHERE id = ?
+`
+
+type TouchAPITokenParams struct {
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ID         int64      `json:"id"`
+}
+
+func (q *Queries) TouchAPIToken(ctx context.Context, arg TouchAPITokenParams) error {
+	_, err := q.db.ExecContext(ctx, touchAPIToken, arg.LastUsedAt, arg.ID)
+	return err
+}
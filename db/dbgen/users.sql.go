@@ -0,0 +1,345 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: users.sql
+
+package dbgen
+
+import (
+	"context"
+	"time"
+)
+
+const countBackupCodes = `-- name: CountBackupCodes :one
+SELECT COUNT(*) FROM backup_codes
+WHERE user_id = ?
+`
+
+func (q *Queries) CountBackupCodes(ctx context.Context, userID int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countBackupCodes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countLoginChallengeAttempt = `-- name: CountLoginChallengeAttempt :exec
+UPDATE login_challenges SET attempts = attempts + 1
+WHERE token_hash = ?
+`
+
+func (q *Queries) CountLoginChallengeAttempt(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, countLoginChallengeAttempt, tokenHash)
+	return err
+}
+
+const countLoginLinks = `-- name: CountLoginLinks :one
+SELECT COUNT(*) FROM login_links
+WHERE user_id = ? AND expires_at > ?
+`
+
+type CountLoginLinksParams struct {
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CountLoginLinks(ctx context.Context, arg CountLoginLinksParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countLoginLinks, arg.UserID, arg.ExpiresAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createBackupCode = `-- name: CreateBackupCode :exec
+INSERT INTO backup_codes (user_id, code_hash)
+VALUES (?, ?)
+`
+
+type CreateBackupCodeParams struct {
+	UserID   int64  `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) CreateBackupCode(ctx context.Context, arg CreateBackupCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createBackupCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const createLoginChallenge = `-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges (token_hash, user_id, expires_at)
+VALUES (?, ?, ?)
+`
+
+type CreateLoginChallengeParams struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateLoginChallenge(ctx context.Context, arg CreateLoginChallengeParams) error {
+	_, err := q.db.ExecContext(ctx, createLoginChallenge, arg.TokenHash, arg.UserID, arg.ExpiresAt)
+	return err
+}
+
+const createLoginLink = `-- name: CreateLoginLink :exec
+INSERT INTO login_links (token_hash, user_id, redirect, expires_at)
+VALUES (?, ?, ?, ?)
+`
+
+type CreateLoginLinkParams struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    int64     `json:"user_id"`
+	Redirect  string    `json:"redirect"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateLoginLink(ctx context.Context, arg CreateLoginLinkParams) error {
+	_, err := q.db.ExecContext(ctx, createLoginLink,
+		arg.TokenHash,
+		arg.UserID,
+		arg.Redirect,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, expires_at)
+VALUES (?, ?, ?)
+`
+
+type CreateSessionParams struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.ExecContext(ctx, createSession, arg.TokenHash, arg.UserID, arg.ExpiresAt)
+	return err
+}
+
+const deleteBackupCodes = `-- name: DeleteBackupCodes :exec
+DELETE FROM backup_codes
+WHERE user_id = ?
+`
+
+func (q *Queries) DeleteBackupCodes(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteBackupCodes, userID)
+	return err
+}
+
+const deleteLoginChallenge = `-- name: DeleteLoginChallenge :exec
+DELETE FROM login_challenges
+WHERE token_hash = ?
+`
+
+func (q *Queries) DeleteLoginChallenge(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteLoginChallenge, tokenHash)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = ?
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteSession, tokenHash)
+	return err
+}
+
+const deleteUserSessions = `-- name: DeleteUserSessions :exec
+DELETE FROM sessions
+WHERE user_id = ?
+`
+
+func (q *Queries) DeleteUserSessions(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteUserSessions, userID)
+	return err
+}
+
+const getLoginChallenge = `-- name: GetLoginChallenge :one
+SELECT token_hash, user_id, expires_at, attempts
+FROM login_challenges
+WHERE token_hash = ?
+`
+
+func (q *Queries) GetLoginChallenge(ctx context.Context, tokenHash string) (LoginChallenge, error) {
+	row := q.db.QueryRowContext(ctx, getLoginChallenge, tokenHash)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.Attempts,
+	)
+	return i, err
+}
+
+const getSessionUser = `-- name: GetSessionUser :one
+SELECT users.id, users.email, sessions.expires_at
+FROM sessions
+JOIN users ON users.id = sessions.user_id
+WHERE sessions.token_hash = ?
+`
+
+type GetSessionUserRow struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) GetSessionUser(ctx context.Context, tokenHash string) (GetSessionUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getSessionUser, tokenHash)
+	var i GetSessionUserRow
+	err := row.Scan(&i.ID, &i.Email, &i.ExpiresAt)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, email, password_hash, created_at, totp_secret, totp_last_step
+FROM users
+WHERE email = ?
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.TotpSecret,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, email, password_hash, created_at, totp_secret, totp_last_step
+FROM users
+WHERE id = ?
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.TotpSecret,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const pruneLoginLinks = `-- name: PruneLoginLinks :exec
+DELETE FROM login_links
+WHERE expires_at < ?
+`
+
+func (q *Queries) PruneLoginLinks(ctx context.Context, expiresAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, pruneLoginLinks, expiresAt)
+	return err
+}
+
+const setUserTOTP = `-- name: SetUserTOTP :exec
+UPDATE users SET totp_secret = ?, totp_last_step = ?
+WHERE id = ?
+`
+
+type SetUserTOTPParams struct {
+	TotpSecret   string `json:"totp_secret"`
+	TotpLastStep int64  `json:"totp_last_step"`
+	ID           int64  `json:"id"`
+}
+
+func (q *Queries) SetUserTOTP(ctx context.Context, arg SetUserTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTOTP, arg.TotpSecret, arg.TotpLastStep, arg.ID)
+	return err
+}
+
+const takeLoginLink = `-- name: TakeLoginLink :one
+DELETE FROM login_links
+WHERE token_hash = ?
+RETURNING user_id, redirect, expires_at
+`
+
+type TakeLoginLinkRow struct {
+	UserID    int64     `json:"user_id"`
+	Redirect  string    `json:"redirect"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) TakeLoginLink(ctx context.Context, tokenHash string) (TakeLoginLinkRow, error) {
+	row := q.db.QueryRowContext(ctx, takeLoginLink, tokenHash)
+	var i TakeLoginLinkRow
+	err := row.Scan(&i.UserID, &i.Redirect, &i.ExpiresAt)
+	return i, err
+}
+
+const upsertUser = `-- name: UpsertUser :exec
+INSERT INTO users (email, password_hash)
+VALUES (?, ?)
+ON CONFLICT (email) DO UPDATE SET password_hash = excluded.password_hash
+`
+
+type UpsertUserParams struct {
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+}
+
+func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) error {
+	_, err := q.db.ExecContext(ctx, upsertUser, arg.Email, arg.PasswordHash)
+	return err
+}
+
+const useBackupCode = `-- name: UseBackupCode :execrows
+DELETE FROM backup_codes
+WHERE user_id = ? AND code_hash = ?
+`
+
+type UseBackupCodeParams struct {
+	UserID   int64  `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) UseBackupCode(ctx context.Context, arg UseBackupCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useBackupCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users SET totp_last_step = ?1
+WHERE id = ?2 AND totp_last_step < ?1
+`
+
+type UseTOTPStepParams struct {
+	Step int64 `json:"step"`
+	ID   int64 `json:"id"`
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.Step, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,10 @@
+-- Embed markup resolved through oEmbed, keyed by the embedded URL
+CREATE TABLE IF NOT EXISTS oembed_cache (
+    url TEXT PRIMARY KEY,
+    html TEXT NOT NULL,
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (004, '004-oembed');
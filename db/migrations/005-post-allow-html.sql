@@ -0,0 +1,6 @@
+-- Per-post opt-in to raw HTML, which is sanitized rather than escaped
+ALTER TABLE posts ADD COLUMN allow_html INTEGER NOT NULL DEFAULT 0;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (005, '005-post-allow-html');
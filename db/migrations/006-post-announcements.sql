@@ -0,0 +1,13 @@
+-- Posts whose publish hooks (hub notifications, search engine pings) have run
+CREATE TABLE IF NOT EXISTS post_announcements (
+    post_id INTEGER PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    announced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Posts published before the hooks existed are not announced again
+INSERT OR IGNORE INTO post_announcements (post_id)
+SELECT id FROM posts WHERE published = 1;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (006, '006-post-announcements');
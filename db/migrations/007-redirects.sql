@@ -0,0 +1,10 @@
+-- Old post slugs that permanently redirect to a post's current slug
+CREATE TABLE IF NOT EXISTS redirects (
+    old_slug TEXT PRIMARY KEY,
+    new_slug TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (007, '007-redirects');
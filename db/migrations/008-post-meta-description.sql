@@ -0,0 +1,7 @@
+-- Per-post description for search results and link previews; empty means
+-- use a summary of the content
+ALTER TABLE posts ADD COLUMN meta_description TEXT NOT NULL DEFAULT '';
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (008, '008-post-meta-description');
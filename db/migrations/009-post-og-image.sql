@@ -0,0 +1,7 @@
+-- Per-post image for link previews; empty means use the first image in the
+-- content or the site default
+ALTER TABLE posts ADD COLUMN og_image TEXT NOT NULL DEFAULT '';
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (009, '009-post-og-image');
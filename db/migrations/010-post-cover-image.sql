@@ -0,0 +1,6 @@
+-- Hero image shown above the post and next to it in listings
+ALTER TABLE posts ADD COLUMN cover_image TEXT NOT NULL DEFAULT '';
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (010, '010-post-cover-image');
@@ -0,0 +1,7 @@
+-- Case-insensitive index on published titles for prefix lookups by the
+-- suggest API
+CREATE INDEX IF NOT EXISTS idx_posts_published_title ON posts(published, title COLLATE NOCASE);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (015, '015-title-index');
@@ -0,0 +1,6 @@
+-- The terms of the full-text index, for correcting misspelled searches
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts_vocab USING fts5vocab(posts_fts, 'row');
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (016, '016-search-vocab');
@@ -0,0 +1,9 @@
+-- Drafts with publish_at set are published by the server when that time
+-- arrives
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts(publish_at) WHERE publish_at IS NOT NULL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (017, '017-publish-at');
@@ -0,0 +1,7 @@
+-- Deleted posts go to the trash, from which they can be restored, until
+-- they are purged
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (019, '019-post-trash');
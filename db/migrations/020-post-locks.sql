@@ -0,0 +1,11 @@
+-- Soft locks on posts open in the editor, so admins see who else is
+-- editing; a lock lapses at expires_at unless the editor renews it
+CREATE TABLE IF NOT EXISTS post_locks (
+    post_id INTEGER PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    editor TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (020, '020-post-locks');
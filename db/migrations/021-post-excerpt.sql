@@ -0,0 +1,7 @@
+-- Optional hand-written summary of a post, shown in listings and feeds
+-- and used as its description instead of the start of the content
+ALTER TABLE posts ADD COLUMN excerpt TEXT NOT NULL DEFAULT '';
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (021, '021-post-excerpt');
@@ -0,0 +1,13 @@
+-- Unsaved changes to posts, stored periodically by the editor so they can
+-- be recovered if the page is closed before the post is updated
+CREATE TABLE IF NOT EXISTS post_autosaves (
+    post_id INTEGER PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    excerpt TEXT NOT NULL,
+    saved_at TIMESTAMP NOT NULL
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (022, '022-post-autosaves');
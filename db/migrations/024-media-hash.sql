@@ -0,0 +1,8 @@
+-- SHA-256 of each uploaded file, so that uploading the same image again
+-- reuses the stored copy. Files uploaded before this have none
+ALTER TABLE media ADD COLUMN sha256 TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_sha256 ON media(sha256);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (024, '024-media-hash');
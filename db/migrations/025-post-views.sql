@@ -0,0 +1,12 @@
+-- Page views of published posts, counted per post and day (in UTC) so that
+-- views can be totalled over any window without keeping every request
+CREATE TABLE IF NOT EXISTS post_views (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    day TEXT NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (post_id, day)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (025, '025-post-views');
@@ -0,0 +1,16 @@
+-- Links that show a post, published or not, to anyone who has one, so
+-- drafts can be shared with reviewers; each lapses at expires_at and can
+-- be revoked before then by deleting it
+CREATE TABLE IF NOT EXISTS preview_links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_preview_links_post ON preview_links(post_id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (026, '026-preview-links');
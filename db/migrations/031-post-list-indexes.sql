@@ -0,0 +1,8 @@
+-- Indexes for listing posts through the API: newest first whatever their
+-- status, and changed since a given time
+CREATE INDEX IF NOT EXISTS idx_posts_created ON posts(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_updated ON posts(updated_at);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (031, '031-post-list-indexes');
@@ -0,0 +1,22 @@
+-- Readers' comments on posts. Only approved comments are shown; posts
+-- with comments_closed set show those they have but take no more
+ALTER TABLE posts ADD COLUMN comments_closed INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    author_name TEXT NOT NULL,
+    author_email TEXT NOT NULL DEFAULT '', -- never shown
+    author_url TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    status TEXT NOT NULL, -- approved
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- last change, which the post page's ETag follows
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_post ON comments(post_id, status, created_at);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (033, '033-comments');
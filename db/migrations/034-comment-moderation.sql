@@ -0,0 +1,7 @@
+-- New comments wait in the admin's moderation queue as pending until they
+-- are approved, rejected or marked as spam; the queue lists them by status
+CREATE INDEX IF NOT EXISTS idx_comments_status ON comments(status, created_at);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (034, '034-comment-moderation');
@@ -0,0 +1,9 @@
+-- Replies to comments. A reply is on the same post as the comment it
+-- answers, and goes with it when it is deleted
+ALTER TABLE comments ADD COLUMN parent_comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_comment_id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (035, '035-comment-replies');
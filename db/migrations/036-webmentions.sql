@@ -0,0 +1,23 @@
+-- Webmentions received for posts: other pages that say they link to one.
+-- Each is checked by fetching its source before it can be approved and
+-- shown under the post
+CREATE TABLE IF NOT EXISTS webmentions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    source TEXT NOT NULL, -- the page that mentions the post
+    target TEXT NOT NULL, -- the post's URL as the sender gave it
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, approved or rejected
+    title TEXT NOT NULL DEFAULT '', -- the source's title when it was last checked
+    needs_check INTEGER NOT NULL DEFAULT 1, -- sent again since it was last checked
+    verified_at TIMESTAMP, -- when the source was last found to link to the target
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- last change, which the post page's ETag follows
+    UNIQUE (source, target)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webmentions_post ON webmentions(post_id, status);
+CREATE INDEX IF NOT EXISTS idx_webmentions_check ON webmentions(needs_check);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (036, '036-webmentions');
@@ -0,0 +1,23 @@
+-- Webmentions sent to the pages posts link to, one row for each post and
+-- link with the outcome of the latest send, which the admin shows
+CREATE TABLE IF NOT EXISTS webmention_sends (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    source TEXT NOT NULL, -- the post's URL
+    target TEXT NOT NULL, -- the page it links to
+    endpoint TEXT NOT NULL DEFAULT '', -- the target's Webmention endpoint, once found
+    attempts INTEGER NOT NULL DEFAULT 0,
+    status_code INTEGER NOT NULL DEFAULT 0, -- of the last attempt, 0 if it got no response
+    error TEXT NOT NULL DEFAULT '',
+    queued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    next_attempt_at TIMESTAMP, -- NULL once sent or given up on
+    UNIQUE (post_id, target)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webmention_sends_pending ON webmention_sends(next_attempt_at)
+    WHERE next_attempt_at IS NOT NULL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (037, '037-webmention-sends');
@@ -0,0 +1,29 @@
+-- Fediverse accounts following the blog, and the activities waiting to be
+-- delivered to their inboxes
+CREATE TABLE IF NOT EXISTS followers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor TEXT NOT NULL UNIQUE, -- the follower's ActivityPub id
+    inbox TEXT NOT NULL,
+    shared_inbox TEXT NOT NULL, -- its server's shared inbox, or its own
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS activity_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    inbox TEXT NOT NULL,
+    activity TEXT NOT NULL, -- the JSON delivered
+    attempts INTEGER NOT NULL DEFAULT 0,
+    status_code INTEGER NOT NULL DEFAULT 0, -- of the last attempt, 0 if it got no response
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    next_attempt_at TIMESTAMP -- NULL once delivered or given up on
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_deliveries_pending ON activity_deliveries(next_attempt_at)
+    WHERE next_attempt_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_activity_deliveries_created ON activity_deliveries(created_at);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (038, '038-activitypub');
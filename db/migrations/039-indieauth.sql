@@ -0,0 +1,36 @@
+-- IndieAuth lets IndieWeb clients ask the admin for a token. The tokens it
+-- grants live with the API tokens but carry IndieAuth scopes such as
+-- "create media", so api_tokens is rebuilt without its check on scope and
+-- records the client each token was granted to
+CREATE TABLE IF NOT EXISTS api_tokens_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scope TEXT NOT NULL,
+    client_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+INSERT INTO api_tokens_new (id, name, token_hash, scope, created_at, last_used_at)
+SELECT id, name, token_hash, scope, created_at, last_used_at FROM api_tokens;
+
+DROP TABLE api_tokens;
+
+ALTER TABLE api_tokens_new RENAME TO api_tokens;
+
+-- Authorization codes handed to a client's redirect_uri, each redeemable
+-- once, shortly after it was created, by a client holding the PKCE
+-- verifier of code_challenge
+CREATE TABLE IF NOT EXISTS indieauth_codes (
+    code_hash TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    code_challenge TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (039, '039-indieauth');
@@ -0,0 +1,7 @@
+-- Posts are shared on the blog's social accounts, such as Mastodon, when
+-- they are published, unless no_crosspost is set
+ALTER TABLE posts ADD COLUMN no_crosspost INTEGER NOT NULL DEFAULT 0;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (040, '040-crosspost');
@@ -0,0 +1,19 @@
+-- Posts waiting to be, or already, posted to the Bluesky account, one row
+-- each, with the state of the attempts so that temporary failures are
+-- retried
+CREATE TABLE IF NOT EXISTS bluesky_posts (
+    post_id INTEGER PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '', -- of the last attempt
+    uri TEXT NOT NULL DEFAULT '', -- at:// URI of the Bluesky post, once made
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    posted_at TIMESTAMP,
+    next_attempt_at TIMESTAMP -- NULL once posted or given up on
+);
+
+CREATE INDEX IF NOT EXISTS idx_bluesky_posts_pending ON bluesky_posts(next_attempt_at)
+    WHERE next_attempt_at IS NOT NULL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (041, '041-bluesky');
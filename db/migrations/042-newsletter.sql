@@ -0,0 +1,13 @@
+-- Readers who asked to get new posts by email. A subscription counts once
+-- its address is confirmed, by following the link sent to it.
+CREATE TABLE IF NOT EXISTS subscribers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    token TEXT NOT NULL UNIQUE, -- in the confirmation and unsubscribe links
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- until confirmed, when the link was last sent
+    confirmed_at TIMESTAMP
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (042, '042-newsletter');
@@ -0,0 +1,23 @@
+-- Email waiting to be, or already, sent, with the state of the attempts
+-- so that temporary failures are retried. Sent mail is kept for a while
+-- to show what went out.
+CREATE TABLE IF NOT EXISTS mail_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL DEFAULT '',
+    headers TEXT NOT NULL DEFAULT '{}', -- JSON object of further headers
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '', -- of the last attempt
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    next_attempt_at TIMESTAMP -- NULL once sent or given up on
+);
+
+CREATE INDEX IF NOT EXISTS idx_mail_queue_pending ON mail_queue(next_attempt_at)
+    WHERE next_attempt_at IS NOT NULL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (043, '043-mail-queue');
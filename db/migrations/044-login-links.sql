@@ -0,0 +1,12 @@
+-- Single-use links, sent by email, that sign a user in without their
+-- password
+CREATE TABLE IF NOT EXISTS login_links (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect TEXT NOT NULL DEFAULT '/admin', -- where to go once signed in
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (044, '044-login-links');
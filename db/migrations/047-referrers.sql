@@ -0,0 +1,16 @@
+-- Where readers of posts came from: the host in the Referer header of
+-- post page views, counted per post and day (in UTC) like post_views.
+-- Views from the blog's own pages are not counted.
+CREATE TABLE IF NOT EXISTS post_referrers (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    day TEXT NOT NULL,
+    host TEXT NOT NULL,
+    visits INTEGER NOT NULL,
+    PRIMARY KEY (post_id, day, host)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_referrers_day ON post_referrers(day);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (047, '047-referrers');
@@ -0,0 +1,21 @@
+-- Page views of the public site, counted per path and day (in UTC)
+CREATE TABLE IF NOT EXISTS page_views (
+    day TEXT NOT NULL,
+    path TEXT NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (day, path)
+);
+
+-- The visitors of the public site each day. A visitor is known only by a
+-- hash of their address and browser salted with a value that changes
+-- every day and is then forgotten, so they cannot be followed from one
+-- day to the next or their address found again.
+CREATE TABLE IF NOT EXISTS daily_visitors (
+    day TEXT NOT NULL,
+    visitor TEXT NOT NULL,
+    PRIMARY KEY (day, visitor)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (048, '048-analytics');
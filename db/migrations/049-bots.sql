@@ -0,0 +1,44 @@
+-- Parts of user agents that mark a request as a bot's, so that it is left
+-- out of view counts and analytics. Matched without regard to case; the
+-- admin can add to and remove from the list, which starts with the usual
+-- crawlers, link previewers, monitors and HTTP libraries.
+CREATE TABLE IF NOT EXISTS bot_patterns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pattern TEXT NOT NULL UNIQUE,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO bot_patterns (pattern, note) VALUES
+    ('bot', 'Googlebot, bingbot, GPTBot and most other crawlers'),
+    ('crawl', 'Crawlers'),
+    ('spider', 'Baiduspider and others'),
+    ('slurp', 'Yahoo'),
+    ('ia_archiver', 'Alexa and the Wayback Machine'),
+    ('facebookexternalhit', 'Facebook link previews'),
+    ('meta-externalagent', 'Meta'),
+    ('anthropic-ai', 'Anthropic'),
+    ('ChatGPT-User', 'OpenAI'),
+    ('Google-Extended', 'Google AI training'),
+    ('Bytespider', 'ByteDance'),
+    ('embedly', 'Link previews'),
+    ('preview', 'Link previews'),
+    ('HeadlessChrome', 'Headless browsers'),
+    ('Lighthouse', 'Page speed tests'),
+    ('Pingdom', 'Uptime monitors'),
+    ('monitor', 'Uptime monitors'),
+    ('curl/', 'HTTP libraries and tools'),
+    ('Wget/', 'HTTP libraries and tools'),
+    ('python-', 'HTTP libraries and tools'),
+    ('Go-http-client', 'HTTP libraries and tools'),
+    ('Java/', 'HTTP libraries and tools'),
+    ('okhttp', 'HTTP libraries and tools'),
+    ('libwww', 'HTTP libraries and tools'),
+    ('HttpClient', 'HTTP libraries and tools'),
+    ('axios/', 'HTTP libraries and tools'),
+    ('node-fetch', 'HTTP libraries and tools'),
+    ('Scrapy', 'Scrapers');
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (049, '049-bots');
@@ -0,0 +1,15 @@
+-- Clicks on links to other sites in posts, counted per post, day (in UTC)
+-- and link when readers are sent through /out to follow them
+CREATE TABLE IF NOT EXISTS link_clicks (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    day TEXT NOT NULL,
+    target TEXT NOT NULL,
+    clicks INTEGER NOT NULL,
+    PRIMARY KEY (post_id, day, target)
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_clicks_day ON link_clicks(day);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (050, '050-link-clicks');
@@ -0,0 +1,39 @@
+-- name: UpsertFollower :exec
+INSERT INTO followers (actor, inbox, shared_inbox)
+VALUES (?, ?, ?)
+ON CONFLICT (actor) DO UPDATE SET
+  inbox = excluded.inbox, shared_inbox = excluded.shared_inbox;
+
+-- name: DeleteFollower :exec
+DELETE FROM followers WHERE actor = ?;
+
+-- name: CountFollowers :one
+SELECT COUNT(*) FROM followers;
+
+-- name: ListFollowerInboxes :many
+-- Lists the inboxes to deliver to so that every follower is reached, each
+-- server's shared inbox once.
+SELECT DISTINCT shared_inbox FROM followers
+ORDER BY shared_inbox;
+
+-- name: CreateActivityDelivery :exec
+INSERT INTO activity_deliveries (inbox, activity, next_attempt_at)
+VALUES (?, ?, ?);
+
+-- name: ListPendingActivityDeliveries :many
+SELECT * FROM activity_deliveries
+WHERE next_attempt_at IS NOT NULL
+ORDER BY id;
+
+-- name: RecordActivityDeliveryAttempt :exec
+UPDATE activity_deliveries
+SET attempts = attempts + 1,
+    status_code = ?,
+    error = ?,
+    delivered_at = ?,
+    next_attempt_at = ?
+WHERE id = ?;
+
+-- name: PruneActivityDeliveries :exec
+DELETE FROM activity_deliveries
+WHERE next_attempt_at IS NULL AND created_at < CAST(sqlc.arg(before) AS TEXT);
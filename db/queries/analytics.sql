@@ -0,0 +1,34 @@
+-- name: AddPageViews :exec
+INSERT INTO page_views (day, path, views)
+VALUES (?, ?, ?)
+ON CONFLICT (day, path) DO UPDATE SET views = views + excluded.views;
+
+-- name: AddDailyVisitor :exec
+INSERT INTO daily_visitors (day, visitor)
+VALUES (?, ?)
+ON CONFLICT (day, visitor) DO NOTHING;
+
+-- name: ListDailyPageViews :many
+-- The page views of each day on and since a day that had any.
+SELECT day, CAST(SUM(views) AS INTEGER) AS views
+FROM page_views
+WHERE day >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY day
+ORDER BY day;
+
+-- name: ListDailyVisitorCounts :many
+-- The number of visitors of each day on and since a day that had any.
+SELECT day, COUNT(*) AS visitors
+FROM daily_visitors
+WHERE day >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY day
+ORDER BY day;
+
+-- name: ListTopPages :many
+-- The paths viewed most on and since a day.
+SELECT path, CAST(SUM(views) AS INTEGER) AS views
+FROM page_views
+WHERE day >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY path
+ORDER BY views DESC, path
+LIMIT sqlc.arg(max_pages);
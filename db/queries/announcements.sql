@@ -0,0 +1,10 @@
+-- name: GetUnannouncedPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM post_announcements WHERE post_id = posts.id)
+ORDER BY created_at;
+
+-- name: MarkPostAnnounced :exec
+INSERT OR IGNORE INTO post_announcements (post_id, announced_at)
+VALUES (?, CURRENT_TIMESTAMP);
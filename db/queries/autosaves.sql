@@ -0,0 +1,14 @@
+-- name: GetPostAutosave :one
+SELECT *
+FROM post_autosaves
+WHERE post_id = ?;
+
+-- name: UpsertPostAutosave :exec
+INSERT INTO post_autosaves (post_id, title, content, excerpt, saved_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (post_id) DO UPDATE
+SET title = excluded.title, content = excluded.content, excerpt = excluded.excerpt, saved_at = excluded.saved_at;
+
+-- name: DeletePostAutosave :exec
+DELETE FROM post_autosaves
+WHERE post_id = ?;
@@ -0,0 +1,105 @@
+-- name: ListBackupPosts :many
+-- Every post, trashed or not, for a backup.
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+ORDER BY id;
+
+-- name: ListAllTags :many
+SELECT id, name, slug
+FROM tags
+ORDER BY id;
+
+-- name: ListAllPostTags :many
+SELECT post_id, tag_id
+FROM post_tags
+ORDER BY post_id, tag_id;
+
+-- name: CountRestoreTargets :one
+-- The rows a restore would collide with; a restore needs them all to be 0.
+SELECT
+  (SELECT COUNT(*) FROM posts) AS posts,
+  (SELECT COUNT(*) FROM tags) AS tags,
+  (SELECT COUNT(*) FROM categories) AS categories,
+  (SELECT COUNT(*) FROM series) AS series,
+  (SELECT COUNT(*) FROM media) AS media;
+
+-- name: RestoreBackupPost :exec
+INSERT INTO posts (id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost)
+VALUES (
+  sqlc.arg(id), sqlc.arg(slug), sqlc.arg(title), sqlc.arg(content), sqlc.arg(published),
+  CAST(sqlc.arg(created_at) AS TEXT), CAST(sqlc.arg(updated_at) AS TEXT),
+  sqlc.arg(allow_html), sqlc.arg(meta_description), sqlc.arg(og_image), sqlc.arg(cover_image),
+  sqlc.narg(series_id), sqlc.arg(series_order), sqlc.narg(category_id), sqlc.narg(publish_at),
+  CAST(sqlc.narg(deleted_at) AS TEXT), sqlc.arg(excerpt), sqlc.arg(comments_closed), sqlc.arg(no_crosspost)
+);
+
+-- name: RestoreTag :exec
+INSERT INTO tags (id, name, slug)
+VALUES (?, ?, ?);
+
+-- name: RestorePostTag :exec
+INSERT INTO post_tags (post_id, tag_id)
+VALUES (?, ?);
+
+-- name: RestoreCategory :exec
+INSERT INTO categories (id, slug, name, description, created_at)
+VALUES (sqlc.arg(id), sqlc.arg(slug), sqlc.arg(name), sqlc.arg(description), CAST(sqlc.arg(created_at) AS TEXT));
+
+-- name: RestoreSeries :exec
+INSERT INTO series (id, slug, title, description, created_at)
+VALUES (sqlc.arg(id), sqlc.arg(slug), sqlc.arg(title), sqlc.arg(description), CAST(sqlc.arg(created_at) AS TEXT));
+
+-- name: RestoreRedirect :exec
+INSERT INTO redirects (old_slug, new_slug, created_at)
+VALUES (sqlc.arg(old_slug), sqlc.arg(new_slug), CAST(sqlc.arg(created_at) AS TEXT));
+
+-- name: RestoreMedia :exec
+INSERT INTO media (id, filename, original_name, content_type, size, width, height, created_at, sha256)
+VALUES (
+  sqlc.arg(id), sqlc.arg(filename), sqlc.arg(original_name), sqlc.arg(content_type), sqlc.arg(size),
+  sqlc.arg(width), sqlc.arg(height), CAST(sqlc.arg(created_at) AS TEXT), sqlc.narg(sha256)
+);
+
+-- name: ListAllComments :many
+SELECT id, post_id, author_name, author_email, author_url, body, status, ip, created_at, updated_at, parent_comment_id
+FROM comments
+ORDER BY id;
+
+-- name: RestoreComment :exec
+INSERT INTO comments (id, post_id, parent_comment_id, author_name, author_email, author_url, body, status, ip, created_at, updated_at)
+VALUES (
+  sqlc.arg(id), sqlc.arg(post_id), sqlc.arg(parent_comment_id), sqlc.arg(author_name), sqlc.arg(author_email), sqlc.arg(author_url),
+  sqlc.arg(body), sqlc.arg(status), sqlc.arg(ip), CAST(sqlc.arg(created_at) AS TEXT), CAST(sqlc.arg(updated_at) AS TEXT)
+);
+
+-- name: ListAllWebmentions :many
+SELECT * FROM webmentions ORDER BY id;
+
+-- name: RestoreWebmention :exec
+INSERT INTO webmentions (id, post_id, source, target, status, title, needs_check, verified_at, created_at, updated_at)
+VALUES (
+  sqlc.arg(id), sqlc.arg(post_id), sqlc.arg(source), sqlc.arg(target), sqlc.arg(status), sqlc.arg(title),
+  sqlc.arg(needs_check), CAST(sqlc.narg(verified_at) AS TEXT), CAST(sqlc.arg(created_at) AS TEXT), CAST(sqlc.arg(updated_at) AS TEXT)
+);
+
+-- name: ListAllFollowers :many
+SELECT * FROM followers ORDER BY id;
+
+-- name: RestoreFollower :exec
+INSERT INTO followers (id, actor, inbox, shared_inbox, created_at)
+VALUES (sqlc.arg(id), sqlc.arg(actor), sqlc.arg(inbox), sqlc.arg(shared_inbox), CAST(sqlc.arg(created_at) AS TEXT));
+
+-- name: ListAllSubscribers :many
+SELECT * FROM subscribers ORDER BY id;
+
+-- name: RestoreSubscriber :exec
+INSERT INTO subscribers (id, email, token, created_at, confirmed_at)
+VALUES (sqlc.arg(id), sqlc.arg(email), sqlc.arg(token), CAST(sqlc.arg(created_at) AS TEXT), CAST(sqlc.narg(confirmed_at) AS TEXT));
+
+-- name: ListAllContactMessages :many
+SELECT * FROM contact_messages ORDER BY id;
+
+-- name: RestoreContactMessage :exec
+INSERT INTO contact_messages (id, name, email, subject, body, sender, read_at, created_at)
+VALUES (sqlc.arg(id), sqlc.arg(name), sqlc.arg(email), sqlc.arg(subject), sqlc.arg(body), sqlc.arg(sender),
+  CAST(sqlc.narg(read_at) AS TEXT), CAST(sqlc.arg(created_at) AS TEXT));
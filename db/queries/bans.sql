@@ -0,0 +1,29 @@
+-- name: GetLoginFailure :one
+SELECT * FROM login_failures WHERE ip = ?;
+
+-- name: UpsertLoginFailure :exec
+INSERT INTO login_failures (ip, failures, last_failure_at, locked_until)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (ip) DO UPDATE
+SET failures = excluded.failures,
+    last_failure_at = excluded.last_failure_at,
+    locked_until = excluded.locked_until;
+
+-- name: DeleteLoginFailure :exec
+DELETE FROM login_failures WHERE ip = ?;
+
+-- name: ListLoginFailures :many
+SELECT * FROM login_failures
+ORDER BY last_failure_at DESC;
+
+-- name: ListIPBans :many
+SELECT * FROM ip_bans
+ORDER BY created_at DESC, id DESC;
+
+-- name: CreateIPBan :exec
+INSERT INTO ip_bans (prefix, reason)
+VALUES (?, ?)
+ON CONFLICT (prefix) DO UPDATE SET reason = excluded.reason;
+
+-- name: DeleteIPBan :exec
+DELETE FROM ip_bans WHERE id = ?;
@@ -0,0 +1,20 @@
+-- name: QueueBlueskyPost :exec
+INSERT OR IGNORE INTO bluesky_posts (post_id, next_attempt_at)
+VALUES (?, ?);
+
+-- name: ListPendingBlueskyPosts :many
+SELECT * FROM bluesky_posts
+WHERE next_attempt_at IS NOT NULL
+ORDER BY post_id;
+
+-- name: GetBlueskyPost :one
+SELECT * FROM bluesky_posts WHERE post_id = ?;
+
+-- name: RecordBlueskyAttempt :exec
+UPDATE bluesky_posts
+SET attempts = attempts + 1,
+    error = ?,
+    uri = ?,
+    posted_at = ?,
+    next_attempt_at = ?
+WHERE post_id = ?;
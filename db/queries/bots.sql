@@ -0,0 +1,11 @@
+-- name: ListBotPatterns :many
+SELECT * FROM bot_patterns
+ORDER BY pattern COLLATE NOCASE, id;
+
+-- name: CreateBotPattern :exec
+INSERT INTO bot_patterns (pattern, note)
+VALUES (?, ?)
+ON CONFLICT (pattern) DO UPDATE SET note = excluded.note;
+
+-- name: DeleteBotPattern :exec
+DELETE FROM bot_patterns WHERE id = ?;
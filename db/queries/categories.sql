@@ -0,0 +1,34 @@
+-- name: GetCategories :many
+SELECT id, slug, name, description, created_at
+FROM categories
+ORDER BY name COLLATE NOCASE;
+
+-- name: GetCategoryByID :one
+SELECT id, slug, name, description, created_at
+FROM categories
+WHERE id = ?;
+
+-- name: GetCategoryBySlug :one
+SELECT id, slug, name, description, created_at
+FROM categories
+WHERE slug = ?;
+
+-- name: CreateCategory :one
+INSERT INTO categories (slug, name, description, created_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+RETURNING *;
+
+-- name: DeleteCategory :exec
+DELETE FROM categories WHERE id = ?;
+
+-- name: GetCategoryPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE category_id = ? AND published = 1 AND deleted_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT ? OFFSET ?;
+
+-- name: CountCategoryPosts :one
+SELECT COUNT(*)
+FROM posts
+WHERE category_id = ? AND published = 1 AND deleted_at IS NULL;
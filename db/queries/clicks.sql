@@ -0,0 +1,24 @@
+-- name: AddLinkClicks :exec
+INSERT INTO link_clicks (post_id, day, target, clicks)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (post_id, day, target) DO UPDATE SET clicks = clicks + excluded.clicks;
+
+-- name: ListTopLinkTargets :many
+-- The links to other sites followed most from posts on and since a day.
+SELECT target, CAST(SUM(clicks) AS INTEGER) AS clicks
+FROM link_clicks
+WHERE day >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY target
+ORDER BY clicks DESC, target
+LIMIT sqlc.arg(max_targets);
+
+-- name: ListTopPostLinkClicks :many
+-- The posts and links to other sites in them followed most on and since
+-- a day.
+SELECT posts.id, posts.slug, posts.title, link_clicks.target, CAST(SUM(link_clicks.clicks) AS INTEGER) AS clicks
+FROM link_clicks
+JOIN posts ON posts.id = link_clicks.post_id
+WHERE link_clicks.day >= CAST(sqlc.arg(since) AS TEXT) AND posts.deleted_at IS NULL
+GROUP BY posts.id, link_clicks.target
+ORDER BY clicks DESC, posts.id DESC, link_clicks.target
+LIMIT sqlc.arg(max_rows);
@@ -0,0 +1,60 @@
+-- name: CreateComment :one
+INSERT INTO comments (post_id, parent_comment_id, author_name, author_email, author_url, body, status, ip)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetComment :one
+SELECT * FROM comments WHERE id = ?;
+
+-- name: ListPostComments :many
+-- The approved comments on a post in thread order: each comment is
+-- followed by its replies, and comments with the same parent are oldest
+-- first. Depth is 0 for a comment on the post, 1 for a reply to one, and
+-- so on. Replies to a comment that is not approved are left out with it.
+WITH RECURSIVE thread (id, depth, path) AS (
+  SELECT comments.id, 0, printf('%020d', comments.id)
+  FROM comments
+  WHERE comments.post_id = ? AND comments.parent_comment_id IS NULL AND comments.status = 'approved'
+  UNION ALL
+  SELECT comments.id, thread.depth + 1, thread.path || '/' || printf('%020d', comments.id)
+  FROM comments
+  JOIN thread ON comments.parent_comment_id = thread.id
+  WHERE comments.status = 'approved'
+)
+SELECT comments.id, comments.post_id, comments.parent_comment_id, comments.author_name, comments.author_url,
+  comments.body, comments.created_at, CAST(thread.depth AS INTEGER) AS depth
+FROM thread
+JOIN comments ON comments.id = thread.id
+ORDER BY thread.path;
+
+-- name: GetPostCommentsChangedAt :one
+-- When a comment on the post last changed, as text, or '' if it has none.
+SELECT CAST(COALESCE(MAX(updated_at), '') AS TEXT)
+FROM comments
+WHERE post_id = ?;
+
+-- name: ListCommentsByStatus :many
+-- A page of the comments with the given status, newest first, with the
+-- posts they are on and who wrote the comments they reply to.
+SELECT comments.id, comments.post_id, comments.author_name, comments.author_email, comments.author_url,
+  comments.body, comments.status, comments.ip, comments.created_at, posts.title AS post_title, posts.slug AS post_slug,
+  parent.author_name AS parent_author_name
+FROM comments
+JOIN posts ON posts.id = comments.post_id
+LEFT JOIN comments AS parent ON parent.id = comments.parent_comment_id
+WHERE comments.status = ?
+ORDER BY comments.created_at DESC, comments.id DESC
+LIMIT ? OFFSET ?;
+
+-- name: CountCommentsByStatus :many
+SELECT status, COUNT(*) AS comment_count
+FROM comments
+GROUP BY status;
+
+-- name: SetCommentStatus :exec
+UPDATE comments
+SET status = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: DeleteComment :exec
+DELETE FROM comments WHERE id = ?;
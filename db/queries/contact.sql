@@ -0,0 +1,30 @@
+-- name: CreateContactMessage :one
+INSERT INTO contact_messages (name, email, subject, body, sender)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: CountContactMessagesFrom :one
+-- How many messages were sent from an address since a time, for the
+-- form's rate limit.
+SELECT COUNT(*) FROM contact_messages
+WHERE sender = sqlc.arg(sender) AND created_at >= CAST(sqlc.arg(since) AS TEXT);
+
+-- name: ListContactMessages :many
+-- A page of the messages, newest first, optionally only the unread ones.
+SELECT * FROM contact_messages
+WHERE CAST(sqlc.arg(unread_only) AS INTEGER) = 0 OR read_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_messages) OFFSET sqlc.arg(skip);
+
+-- name: CountContactMessages :one
+SELECT COUNT(*) AS total, CAST(COALESCE(SUM(read_at IS NULL), 0) AS INTEGER) AS unread
+FROM contact_messages;
+
+-- name: MarkContactMessageRead :exec
+UPDATE contact_messages SET read_at = CURRENT_TIMESTAMP WHERE id = ? AND read_at IS NULL;
+
+-- name: MarkContactMessageUnread :exec
+UPDATE contact_messages SET read_at = NULL WHERE id = ?;
+
+-- name: DeleteContactMessage :exec
+DELETE FROM contact_messages WHERE id = ?;
@@ -0,0 +1,17 @@
+-- name: CreateIndieAuthCode :exec
+INSERT INTO indieauth_codes (code_hash, client_id, redirect_uri, scope, code_challenge)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: TakeIndieAuthCode :one
+DELETE FROM indieauth_codes WHERE code_hash = ?
+RETURNING *;
+
+-- name: PruneIndieAuthCodes :exec
+DELETE FROM indieauth_codes WHERE created_at < CAST(sqlc.arg(before) AS TEXT);
+
+-- name: CreateIndieAuthToken :exec
+INSERT INTO api_tokens (name, token_hash, scope, client_id)
+VALUES (?, ?, ?, ?);
+
+-- name: DeleteAPITokenByHash :exec
+DELETE FROM api_tokens WHERE token_hash = ?;
@@ -0,0 +1,48 @@
+-- name: AddPostLike :execrows
+-- Records a reader's like of a post, unless they have liked it already.
+INSERT INTO post_likes (post_id, visitor)
+VALUES (?, ?)
+ON CONFLICT (post_id, visitor) DO NOTHING;
+
+-- name: CountPostLikes :one
+SELECT COUNT(*) FROM post_likes WHERE post_id = ?;
+
+-- name: GetPostLikesChangedAt :one
+-- When the post was last liked, as text, or '' if it never was.
+SELECT CAST(COALESCE(MAX(created_at), '') AS TEXT)
+FROM post_likes
+WHERE post_id = ?;
+
+-- name: CountLikes :one
+-- The number of likes of posts not in the trash, and of posts liked.
+SELECT COUNT(*) AS likes, COUNT(DISTINCT post_likes.post_id) AS posts
+FROM post_likes
+JOIN posts ON posts.id = post_likes.post_id
+WHERE posts.deleted_at IS NULL;
+
+-- name: ListMostLikedPosts :many
+SELECT posts.id, posts.slug, posts.title, posts.published, posts.created_at, COUNT(*) AS likes
+FROM post_likes
+JOIN posts ON posts.id = post_likes.post_id
+WHERE posts.deleted_at IS NULL
+GROUP BY posts.id
+ORDER BY likes DESC, posts.id DESC
+LIMIT ? OFFSET ?;
+
+-- name: ListLikedPostsByCreated :many
+SELECT posts.id, posts.slug, posts.title, posts.published, posts.created_at, COUNT(*) AS likes
+FROM post_likes
+JOIN posts ON posts.id = post_likes.post_id
+WHERE posts.deleted_at IS NULL
+GROUP BY posts.id
+ORDER BY posts.created_at DESC, posts.id DESC
+LIMIT ? OFFSET ?;
+
+-- name: ListLikedPostsByTitle :many
+SELECT posts.id, posts.slug, posts.title, posts.published, posts.created_at, COUNT(*) AS likes
+FROM post_likes
+JOIN posts ON posts.id = post_likes.post_id
+WHERE posts.deleted_at IS NULL
+GROUP BY posts.id
+ORDER BY posts.title COLLATE NOCASE, posts.id
+LIMIT ? OFFSET ?;
@@ -0,0 +1,14 @@
+-- name: GetPostLock :one
+SELECT *
+FROM post_locks
+WHERE post_id = ?;
+
+-- name: UpsertPostLock :exec
+INSERT INTO post_locks (post_id, editor, expires_at)
+VALUES (?, ?, ?)
+ON CONFLICT (post_id) DO UPDATE
+SET editor = excluded.editor, expires_at = excluded.expires_at;
+
+-- name: ReleasePostLock :exec
+DELETE FROM post_locks
+WHERE post_id = ? AND editor = ?;
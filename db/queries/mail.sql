@@ -0,0 +1,22 @@
+-- name: QueueMail :one
+INSERT INTO mail_queue (recipient, subject, text_body, html_body, headers, next_attempt_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id;
+
+-- name: ListPendingMail :many
+SELECT * FROM mail_queue
+WHERE next_attempt_at IS NOT NULL
+ORDER BY id;
+
+-- name: RecordMailAttempt :exec
+UPDATE mail_queue
+SET attempts = attempts + 1,
+    error = ?,
+    sent_at = ?,
+    next_attempt_at = ?
+WHERE id = ?;
+
+-- name: PruneMailQueue :exec
+DELETE FROM mail_queue
+WHERE next_attempt_at IS NULL AND created_at < CAST(sqlc.arg(before) AS TEXT);
+
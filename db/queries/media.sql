@@ -0,0 +1,53 @@
+-- name: CreateMedia :one
+INSERT INTO media (filename, original_name, content_type, size, width, height, sha256)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetMediaByFilename :one
+SELECT *
+FROM media
+WHERE filename = ?;
+
+-- name: GetMediaBySHA256 :one
+SELECT *
+FROM media
+WHERE sha256 = ?;
+
+-- name: GetMediaByID :one
+SELECT *
+FROM media
+WHERE id = ?;
+
+-- name: ListMedia :many
+SELECT *
+FROM media
+ORDER BY created_at DESC, id DESC
+LIMIT ?;
+
+-- name: ListAllMedia :many
+-- Every uploaded file, oldest first, for exports.
+SELECT *
+FROM media
+ORDER BY created_at, id;
+
+-- name: DeleteMedia :exec
+DELETE FROM media
+WHERE id = ?;
+
+-- name: ListMediaUsage :many
+-- Posts, trashed ones included, whose content, cover image or share image
+-- refers to an uploaded file.
+SELECT media.id AS media_id, posts.id AS post_id, posts.title AS post_title
+FROM media
+JOIN posts ON instr(posts.content, '/media/' || media.filename) > 0
+    OR posts.cover_image = '/media/' || media.filename
+    OR posts.og_image = '/media/' || media.filename
+ORDER BY media.id, posts.title COLLATE NOCASE;
+
+-- name: CountMediaUsage :one
+SELECT COUNT(*)
+FROM media
+JOIN posts ON instr(posts.content, '/media/' || media.filename) > 0
+    OR posts.cover_image = '/media/' || media.filename
+    OR posts.og_image = '/media/' || media.filename
+WHERE media.id = ?;
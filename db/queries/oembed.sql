@@ -0,0 +1,10 @@
+-- name: GetOEmbed :one
+SELECT html
+FROM oembed_cache
+WHERE url = ?;
+
+-- name: UpsertOEmbed :exec
+INSERT INTO oembed_cache (url, html, fetched_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (url) DO UPDATE
+SET html = excluded.html, fetched_at = excluded.fetched_at;
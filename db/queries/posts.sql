@@ -1,28 +1,196 @@
 -- name: GetPublishedPosts :many
-SELECT id, slug, title, content, created_at, updated_at
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
 FROM posts
-WHERE published = 1
+WHERE published = 1 AND deleted_at IS NULL
 ORDER BY created_at DESC;
 
+-- name: GetPublishedPostsPage :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT ? OFFSET ?;
+
+-- name: CountPublishedPosts :one
+SELECT COUNT(*)
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL;
+
 -- name: GetPostBySlug :one
-SELECT id, slug, title, content, published, created_at, updated_at
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
 FROM posts
 WHERE slug = ?;
 
--- name: GetAllPosts :many
-SELECT id, slug, title, content, published, created_at, updated_at
+-- name: GetPostByID :one
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
 FROM posts
-ORDER BY created_at DESC;
+WHERE id = ?;
+
+-- The admin post list is filtered by status (NULL for any) and by a search
+-- matching anywhere in the title or slug, and sorted one of three ways.
+
+-- name: ListAdminPostsByCreated :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(sqlc.narg(published) AS INTEGER) IS NULL OR published = CAST(sqlc.narg(published) AS INTEGER))
+  AND (CAST(sqlc.arg(search) AS TEXT) = '' OR instr(lower(title), lower(CAST(sqlc.arg(search) AS TEXT))) > 0 OR instr(slug, lower(CAST(sqlc.arg(search) AS TEXT))) > 0)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: ListAdminPostsByUpdated :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(sqlc.narg(published) AS INTEGER) IS NULL OR published = CAST(sqlc.narg(published) AS INTEGER))
+  AND (CAST(sqlc.arg(search) AS TEXT) = '' OR instr(lower(title), lower(CAST(sqlc.arg(search) AS TEXT))) > 0 OR instr(slug, lower(CAST(sqlc.arg(search) AS TEXT))) > 0)
+ORDER BY updated_at DESC, id DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: ListAdminPostsByTitle :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(sqlc.narg(published) AS INTEGER) IS NULL OR published = CAST(sqlc.narg(published) AS INTEGER))
+  AND (CAST(sqlc.arg(search) AS TEXT) = '' OR instr(lower(title), lower(CAST(sqlc.arg(search) AS TEXT))) > 0 OR instr(slug, lower(CAST(sqlc.arg(search) AS TEXT))) > 0)
+ORDER BY title COLLATE NOCASE, id
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: CountAdminPosts :one
+SELECT COUNT(*)
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(sqlc.narg(published) AS INTEGER) IS NULL OR published = CAST(sqlc.narg(published) AS INTEGER))
+  AND (CAST(sqlc.arg(search) AS TEXT) = '' OR instr(lower(title), lower(CAST(sqlc.arg(search) AS TEXT))) > 0 OR instr(slug, lower(CAST(sqlc.arg(search) AS TEXT))) > 0);
+
+-- name: CountPostsByStatus :many
+SELECT published, COUNT(*) AS post_count
+FROM posts
+WHERE deleted_at IS NULL
+GROUP BY published;
 
 -- name: CreatePost :one
-INSERT INTO posts (slug, title, content, published, created_at, updated_at)
-VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+INSERT INTO posts (slug, title, content, excerpt, published, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, comments_closed, no_crosspost, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 RETURNING *;
 
 -- name: UpdatePost :exec
 UPDATE posts
-SET title = ?, content = ?, published = ?, updated_at = CURRENT_TIMESTAMP
+SET slug = ?, title = ?, content = ?, excerpt = ?, published = ?, allow_html = ?, meta_description = ?, og_image = ?, cover_image = ?, series_id = ?, series_order = ?, category_id = ?, publish_at = ?, comments_closed = ?, no_crosspost = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ?;
 
--- name: DeletePost :exec
-DELETE FROM posts WHERE id = ?;
+-- name: SetPostPublished :exec
+UPDATE posts
+SET published = ?, publish_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND deleted_at IS NULL;
+
+-- name: TrashPost :exec
+UPDATE posts SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?;
+
+-- name: RestorePost :exec
+UPDATE posts SET deleted_at = NULL WHERE id = ?;
+
+-- name: GetTrashedPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: PurgePost :exec
+DELETE FROM posts WHERE id = ? AND deleted_at IS NOT NULL;
+
+-- name: GetPreviousPost :one
+SELECT p.slug, p.title
+FROM posts p, posts cur
+WHERE cur.id = ? AND p.published = 1 AND p.deleted_at IS NULL
+  AND (p.created_at < cur.created_at OR (p.created_at = cur.created_at AND p.id < cur.id))
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT 1;
+
+-- name: GetNextPost :one
+SELECT p.slug, p.title
+FROM posts p, posts cur
+WHERE cur.id = ? AND p.published = 1 AND p.deleted_at IS NULL
+  AND (p.created_at > cur.created_at OR (p.created_at = cur.created_at AND p.id > cur.id))
+ORDER BY p.created_at, p.id
+LIMIT 1;
+
+-- name: GetArchiveMonths :many
+SELECT CAST(strftime('%Y', created_at) AS INTEGER) AS year,
+       CAST(strftime('%m', created_at) AS INTEGER) AS month,
+       COUNT(*) AS post_count
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL
+GROUP BY year, month
+ORDER BY year DESC, month DESC;
+
+-- name: GetPublishedPostsInYear :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL AND strftime('%Y', created_at) = CAST(sqlc.arg(year) AS TEXT)
+ORDER BY created_at DESC, id DESC;
+
+-- name: GetPublishedPostsInMonth :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL AND strftime('%Y-%m', created_at) = CAST(sqlc.arg(month) AS TEXT)
+ORDER BY created_at DESC, id DESC;
+
+-- name: SuggestPosts :many
+SELECT slug, title
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL AND title >= sqlc.arg(prefix) COLLATE NOCASE AND title < sqlc.arg(prefix_end) COLLATE NOCASE
+ORDER BY title COLLATE NOCASE
+LIMIT sqlc.arg(limit);
+
+-- name: PublishDuePosts :many
+UPDATE posts
+SET published = 1, created_at = datetime(publish_at), updated_at = CURRENT_TIMESTAMP, publish_at = NULL
+WHERE published = 0 AND deleted_at IS NULL AND publish_at IS NOT NULL AND datetime(publish_at) <= datetime('now')
+RETURNING id;
+
+-- name: SetPostCreatedAt :exec
+UPDATE posts
+SET created_at = CAST(sqlc.arg(created_at) AS TEXT)
+WHERE id = sqlc.arg(id);
+
+-- name: CountPublishedPostsByWeek :many
+SELECT CAST(date(created_at, 'weekday 0', '-6 days') AS TEXT) AS week_start, COUNT(*) AS post_count
+FROM posts
+WHERE published = 1 AND deleted_at IS NULL AND created_at >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY week_start
+ORDER BY week_start;
+
+-- name: ListScheduledPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE published = 0 AND deleted_at IS NULL AND publish_at IS NOT NULL
+ORDER BY datetime(publish_at);
+
+-- The posts API lists posts newest first, filtered by status (NULL for
+-- any), tag slug ('' for any) and the time of their last change, a page
+-- at a time after a cursor: the created_at and id of the last post of the
+-- page before, or an id of 0 for the first page.
+
+-- name: ListAPIPosts :many
+SELECT id, slug, title, content, published, created_at, updated_at, allow_html, meta_description, og_image, cover_image, series_id, series_order, category_id, publish_at, deleted_at, excerpt, comments_closed, no_crosspost
+FROM posts
+WHERE deleted_at IS NULL
+  AND (CAST(sqlc.narg(published) AS INTEGER) IS NULL OR published = CAST(sqlc.narg(published) AS INTEGER))
+  AND (CAST(sqlc.arg(tag) AS TEXT) = '' OR id IN (
+    SELECT post_tags.post_id
+    FROM post_tags
+    JOIN tags ON tags.id = post_tags.tag_id
+    WHERE tags.slug = CAST(sqlc.arg(tag) AS TEXT)))
+  AND updated_at >= CAST(sqlc.arg(since) AS TEXT)
+  AND (CAST(sqlc.arg(after_id) AS INTEGER) = 0
+    OR created_at < CAST(sqlc.arg(after_created) AS TEXT)
+    OR (created_at = CAST(sqlc.arg(after_created) AS TEXT) AND id < CAST(sqlc.arg(after_id) AS INTEGER)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: ListAllPosts :many
+-- Every post not in the trash, oldest first, for exports.
+SELECT * FROM posts
+WHERE deleted_at IS NULL
+ORDER BY created_at, id;
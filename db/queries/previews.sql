@@ -0,0 +1,19 @@
+-- name: CreatePreviewLink :one
+INSERT INTO preview_links (post_id, token, expires_at)
+VALUES (?, ?, ?)
+RETURNING id, post_id, token, created_at, expires_at;
+
+-- name: GetPreviewLink :one
+SELECT id, post_id, token, created_at, expires_at
+FROM preview_links
+WHERE token = ?;
+
+-- name: ListPreviewLinks :many
+SELECT id, post_id, token, created_at, expires_at
+FROM preview_links
+WHERE post_id = ?
+ORDER BY id DESC;
+
+-- name: DeletePreviewLink :exec
+DELETE FROM preview_links
+WHERE id = ? AND post_id = ?;
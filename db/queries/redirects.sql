@@ -0,0 +1,23 @@
+-- name: GetRedirect :one
+SELECT new_slug
+FROM redirects
+WHERE old_slug = ?;
+
+-- name: GetRedirects :many
+SELECT old_slug, new_slug, created_at
+FROM redirects
+ORDER BY created_at DESC, old_slug;
+
+-- name: UpsertRedirect :exec
+INSERT INTO redirects (old_slug, new_slug, created_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (old_slug) DO UPDATE
+SET new_slug = excluded.new_slug, created_at = excluded.created_at;
+
+-- name: RetargetRedirects :exec
+UPDATE redirects
+SET new_slug = sqlc.arg(new_slug)
+WHERE new_slug = sqlc.arg(old_slug);
+
+-- name: DeleteRedirect :exec
+DELETE FROM redirects WHERE old_slug = ?;
@@ -0,0 +1,24 @@
+-- name: AddPostReferrals :exec
+INSERT INTO post_referrers (post_id, day, host, visits)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (post_id, day, host) DO UPDATE SET visits = visits + excluded.visits;
+
+-- name: ListTopReferrers :many
+-- The hosts that sent the most visits to posts on and since a day.
+SELECT host, CAST(SUM(visits) AS INTEGER) AS visits
+FROM post_referrers
+WHERE day >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY host
+ORDER BY visits DESC, host
+LIMIT sqlc.arg(max_hosts);
+
+-- name: ListTopPostReferrers :many
+-- The posts and hosts with the most visits from one to the other on and
+-- since a day.
+SELECT posts.id, posts.slug, posts.title, post_referrers.host, CAST(SUM(post_referrers.visits) AS INTEGER) AS visits
+FROM post_referrers
+JOIN posts ON posts.id = post_referrers.post_id
+WHERE post_referrers.day >= CAST(sqlc.arg(since) AS TEXT) AND posts.deleted_at IS NULL
+GROUP BY posts.id, post_referrers.host
+ORDER BY visits DESC, posts.id DESC, post_referrers.host
+LIMIT sqlc.arg(max_rows);
@@ -0,0 +1,21 @@
+-- name: SavePostRevision :exec
+INSERT INTO post_revisions (post_id, title, content, saved_at)
+SELECT posts.id, posts.title, posts.content, posts.updated_at
+FROM posts
+WHERE posts.id = sqlc.arg(post_id) AND (posts.title != sqlc.arg(title) OR posts.content != sqlc.arg(content));
+
+-- name: GetPostRevisions :many
+SELECT id, post_id, title, saved_at
+FROM post_revisions
+WHERE post_id = ?
+ORDER BY id DESC;
+
+-- name: GetPostRevision :one
+SELECT *
+FROM post_revisions
+WHERE id = ? AND post_id = ?;
+
+-- name: RestorePostRevision :exec
+UPDATE posts
+SET title = ?, content = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
@@ -0,0 +1,28 @@
+-- name: GetAllSeries :many
+SELECT id, slug, title, description, created_at
+FROM series
+ORDER BY title;
+
+-- name: GetSeriesByID :one
+SELECT id, slug, title, description, created_at
+FROM series
+WHERE id = ?;
+
+-- name: GetSeriesBySlug :one
+SELECT id, slug, title, description, created_at
+FROM series
+WHERE slug = ?;
+
+-- name: CreateSeries :one
+INSERT INTO series (slug, title, description, created_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+RETURNING *;
+
+-- name: DeleteSeries :exec
+DELETE FROM series WHERE id = ?;
+
+-- name: GetSeriesPosts :many
+SELECT id, slug, title, series_order, created_at
+FROM posts
+WHERE series_id = ? AND published = 1 AND deleted_at IS NULL
+ORDER BY series_order, created_at, id;
@@ -0,0 +1,20 @@
+-- name: GetSettings :many
+SELECT key, value
+FROM settings;
+
+-- name: GetSetting :one
+SELECT value
+FROM settings
+WHERE key = ?;
+
+-- name: UpsertSetting :exec
+INSERT INTO settings (key, value, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (key) DO UPDATE
+SET value = excluded.value, updated_at = excluded.updated_at;
+
+-- name: InsertSettingIfAbsent :exec
+-- Sets a setting only if it has no value yet, for keys made on first use.
+INSERT INTO settings (key, value, updated_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (key) DO NOTHING;
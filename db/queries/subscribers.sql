@@ -0,0 +1,32 @@
+-- name: CreateSubscriber :one
+INSERT INTO subscribers (email, token)
+VALUES (?, ?)
+RETURNING *;
+
+-- name: GetSubscriberByEmail :one
+SELECT * FROM subscribers WHERE email = ?;
+
+-- name: GetSubscriberByToken :one
+SELECT * FROM subscribers WHERE token = ?;
+
+-- name: RenewSubscriber :exec
+UPDATE subscribers SET created_at = CURRENT_TIMESTAMP WHERE id = ?;
+
+-- name: ConfirmSubscriber :exec
+UPDATE subscribers SET confirmed_at = CURRENT_TIMESTAMP
+WHERE id = ? AND confirmed_at IS NULL;
+
+-- name: DeleteSubscriberByToken :execrows
+DELETE FROM subscribers WHERE token = ?;
+
+-- name: ListConfirmedSubscribers :many
+SELECT * FROM subscribers
+WHERE confirmed_at IS NOT NULL
+ORDER BY id;
+
+-- name: CountConfirmedSubscribers :one
+SELECT COUNT(*) FROM subscribers WHERE confirmed_at IS NOT NULL;
+
+-- name: PruneUnconfirmedSubscribers :exec
+DELETE FROM subscribers
+WHERE confirmed_at IS NULL AND created_at < CAST(sqlc.arg(before) AS TEXT);
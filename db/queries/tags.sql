@@ -0,0 +1,51 @@
+-- name: UpsertTag :one
+INSERT INTO tags (name, slug)
+VALUES (?, ?)
+ON CONFLICT (slug) DO UPDATE SET name = tags.name
+RETURNING *;
+
+-- name: AddPostTag :exec
+INSERT OR IGNORE INTO post_tags (post_id, tag_id)
+VALUES (?, ?);
+
+-- name: DeletePostTags :exec
+DELETE FROM post_tags WHERE post_id = ?;
+
+-- name: DeleteUnusedTags :exec
+DELETE FROM tags
+WHERE NOT EXISTS (SELECT 1 FROM post_tags WHERE tag_id = tags.id);
+
+-- name: GetPostTags :many
+SELECT tags.id, tags.name, tags.slug
+FROM tags
+JOIN post_tags ON post_tags.tag_id = tags.id
+WHERE post_tags.post_id = ?
+ORDER BY tags.name COLLATE NOCASE;
+
+-- name: GetTagBySlug :one
+SELECT id, name, slug
+FROM tags
+WHERE slug = ?;
+
+-- name: GetTagPosts :many
+SELECT posts.id, posts.slug, posts.title, posts.content, posts.published, posts.created_at, posts.updated_at, posts.allow_html, posts.meta_description, posts.og_image, posts.cover_image, posts.series_id, posts.series_order, posts.category_id, posts.publish_at, posts.deleted_at, posts.excerpt, posts.comments_closed, posts.no_crosspost
+FROM posts
+JOIN post_tags ON post_tags.post_id = posts.id
+WHERE post_tags.tag_id = ? AND posts.published = 1 AND posts.deleted_at IS NULL
+ORDER BY posts.created_at DESC, posts.id DESC
+LIMIT ? OFFSET ?;
+
+-- name: CountTagPosts :one
+SELECT COUNT(*)
+FROM posts
+JOIN post_tags ON post_tags.post_id = posts.id
+WHERE post_tags.tag_id = ? AND posts.published = 1 AND posts.deleted_at IS NULL;
+
+-- name: GetTagCounts :many
+SELECT tags.id, tags.name, tags.slug, COUNT(*) AS post_count
+FROM tags
+JOIN post_tags ON post_tags.tag_id = tags.id
+JOIN posts ON posts.id = post_tags.post_id
+WHERE posts.published = 1 AND posts.deleted_at IS NULL
+GROUP BY tags.id
+ORDER BY tags.name COLLATE NOCASE;
@@ -0,0 +1,16 @@
+-- name: CreateAPIToken :exec
+INSERT INTO api_tokens (name, token_hash, scope)
+VALUES (?, ?, ?);
+
+-- name: GetAPIToken :one
+SELECT * FROM api_tokens WHERE token_hash = ?;
+
+-- name: ListAPITokens :many
+SELECT * FROM api_tokens
+ORDER BY created_at DESC, id DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = ? WHERE id = ?;
+
+-- name: DeleteAPIToken :exec
+DELETE FROM api_tokens WHERE id = ?;
@@ -0,0 +1,93 @@
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: GetUserByEmail :one
+SELECT id, email, password_hash, created_at, totp_secret, totp_last_step
+FROM users
+WHERE email = ?;
+
+-- name: GetUserByID :one
+SELECT id, email, password_hash, created_at, totp_secret, totp_last_step
+FROM users
+WHERE id = ?;
+
+-- name: UpsertUser :exec
+INSERT INTO users (email, password_hash)
+VALUES (?, ?)
+ON CONFLICT (email) DO UPDATE SET password_hash = excluded.password_hash;
+
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, expires_at)
+VALUES (?, ?, ?);
+
+-- name: GetSessionUser :one
+SELECT users.id, users.email, sessions.expires_at
+FROM sessions
+JOIN users ON users.id = sessions.user_id
+WHERE sessions.token_hash = ?;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = ?;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions
+WHERE user_id = ?;
+
+-- name: SetUserTOTP :exec
+UPDATE users SET totp_secret = ?, totp_last_step = ?
+WHERE id = ?;
+
+-- name: UseTOTPStep :execrows
+UPDATE users SET totp_last_step = sqlc.arg(step)
+WHERE id = sqlc.arg(id) AND totp_last_step < sqlc.arg(step);
+
+-- name: DeleteBackupCodes :exec
+DELETE FROM backup_codes
+WHERE user_id = ?;
+
+-- name: CreateBackupCode :exec
+INSERT INTO backup_codes (user_id, code_hash)
+VALUES (?, ?);
+
+-- name: UseBackupCode :execrows
+DELETE FROM backup_codes
+WHERE user_id = ? AND code_hash = ?;
+
+-- name: CountBackupCodes :one
+SELECT COUNT(*) FROM backup_codes
+WHERE user_id = ?;
+
+-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges (token_hash, user_id, expires_at)
+VALUES (?, ?, ?);
+
+-- name: GetLoginChallenge :one
+SELECT token_hash, user_id, expires_at, attempts
+FROM login_challenges
+WHERE token_hash = ?;
+
+-- name: CountLoginChallengeAttempt :exec
+UPDATE login_challenges SET attempts = attempts + 1
+WHERE token_hash = ?;
+
+-- name: DeleteLoginChallenge :exec
+DELETE FROM login_challenges
+WHERE token_hash = ?;
+
+-- name: CreateLoginLink :exec
+INSERT INTO login_links (token_hash, user_id, redirect, expires_at)
+VALUES (?, ?, ?, ?);
+
+-- name: TakeLoginLink :one
+DELETE FROM login_links
+WHERE token_hash = ?
+RETURNING user_id, redirect, expires_at;
+
+-- name: PruneLoginLinks :exec
+DELETE FROM login_links
+WHERE expires_at < ?;
+
+-- name: CountLoginLinks :one
+SELECT COUNT(*) FROM login_links
+WHERE user_id = ? AND expires_at > ?;
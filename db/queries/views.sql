@@ -0,0 +1,35 @@
+-- name: AddPostViews :exec
+INSERT INTO post_views (post_id, day, views)
+VALUES (?, ?, ?)
+ON CONFLICT (post_id, day) DO UPDATE SET views = views + excluded.views;
+
+-- name: ListTopViewedPosts :many
+SELECT posts.id, posts.slug, posts.title, CAST(SUM(post_views.views) AS INTEGER) AS views
+FROM post_views
+JOIN posts ON posts.id = post_views.post_id
+WHERE posts.published = 1 AND posts.deleted_at IS NULL AND post_views.day >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY posts.id
+ORDER BY views DESC, posts.id DESC
+LIMIT sqlc.arg(max_posts);
+
+-- name: ListPostViewTotals :many
+-- Every post's views of all time.
+SELECT post_id, CAST(SUM(views) AS INTEGER) AS views
+FROM post_views
+GROUP BY post_id;
+
+-- name: CountViewsSince :one
+-- The views of all posts on and since a day.
+SELECT CAST(COALESCE(SUM(views), 0) AS INTEGER)
+FROM post_views
+WHERE day >= CAST(sqlc.arg(since) AS TEXT);
+
+-- name: ListPopularPosts :many
+-- The published posts most viewed on and since a day, most viewed first.
+SELECT posts.id, posts.slug, posts.title, posts.cover_image, posts.created_at, CAST(SUM(post_views.views) AS INTEGER) AS views
+FROM post_views
+JOIN posts ON posts.id = post_views.post_id
+WHERE posts.published = 1 AND posts.deleted_at IS NULL AND post_views.day >= CAST(sqlc.arg(since) AS TEXT)
+GROUP BY posts.id
+ORDER BY views DESC, posts.id DESC
+LIMIT sqlc.arg(max_posts);
@@ -0,0 +1,54 @@
+-- name: CreateWebhook :exec
+INSERT INTO webhooks (url, secret, events)
+VALUES (?, ?, ?);
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks
+ORDER BY created_at, id;
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks WHERE id = ?;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+VALUES (?, ?, ?, ?);
+
+-- name: ListPendingWebhookDeliveries :many
+SELECT webhook_deliveries.id, webhook_deliveries.event, webhook_deliveries.payload,
+       webhook_deliveries.attempts, webhook_deliveries.next_attempt_at,
+       webhooks.url, webhooks.secret
+FROM webhook_deliveries
+JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+WHERE webhook_deliveries.next_attempt_at IS NOT NULL
+ORDER BY webhook_deliveries.id;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    status_code = ?,
+    error = ?,
+    delivered_at = ?,
+    next_attempt_at = ?
+WHERE id = ?;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET next_attempt_at = ?
+WHERE id = ? AND next_attempt_at IS NULL AND delivered_at IS NULL;
+
+-- name: ListWebhookDeliveries :many
+SELECT webhook_deliveries.id, webhook_deliveries.event, webhook_deliveries.attempts,
+       webhook_deliveries.status_code, webhook_deliveries.error,
+       webhook_deliveries.created_at, webhook_deliveries.delivered_at,
+       webhook_deliveries.next_attempt_at, webhooks.url
+FROM webhook_deliveries
+JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+ORDER BY webhook_deliveries.id DESC
+LIMIT ?;
+
+-- Deliveries are kept for a while after they are done with, for the log
+-- in the admin.
+
+-- name: PruneWebhookDeliveries :exec
+DELETE FROM webhook_deliveries
+WHERE next_attempt_at IS NULL AND created_at < CAST(sqlc.arg(before) AS TEXT);
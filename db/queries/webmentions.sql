@@ -0,0 +1,89 @@
+-- name: ReceiveWebmention :exec
+-- Records a Webmention to be checked, or marks one sent before to be
+-- checked again, keeping whether it was approved.
+INSERT INTO webmentions (post_id, source, target)
+VALUES (?, ?, ?)
+ON CONFLICT (source, target) DO UPDATE SET needs_check = 1;
+
+-- name: ListWebmentionsToCheck :many
+SELECT * FROM webmentions
+WHERE needs_check = 1
+ORDER BY id
+LIMIT ?;
+
+-- name: SetWebmentionVerified :exec
+-- Records that a Webmention's source links to its target, and its title;
+-- a change of title counts as a change to the post page.
+UPDATE webmentions
+SET needs_check = 0, verified_at = CURRENT_TIMESTAMP,
+  updated_at = CASE WHEN title = sqlc.arg(title) THEN updated_at ELSE CURRENT_TIMESTAMP END,
+  title = sqlc.arg(title)
+WHERE id = sqlc.arg(id);
+
+-- name: SetWebmentionChecked :exec
+-- Marks a Webmention checked without changing it, when its source could
+-- not be fetched.
+UPDATE webmentions SET needs_check = 0 WHERE id = ?;
+
+-- name: DeleteWebmention :exec
+DELETE FROM webmentions WHERE id = ?;
+
+-- name: ListPostWebmentions :many
+-- The approved Webmentions of a post, oldest first.
+SELECT id, source, title, created_at
+FROM webmentions
+WHERE post_id = ? AND status = 'approved'
+ORDER BY created_at, id;
+
+-- name: GetPostWebmentionsChangedAt :one
+-- When a Webmention of the post last changed, as text, or '' if it has
+-- none.
+SELECT CAST(COALESCE(MAX(updated_at), '') AS TEXT)
+FROM webmentions
+WHERE post_id = ?;
+
+-- name: ListWebmentionsByStatus :many
+-- A page of the checked Webmentions with the given status, newest first,
+-- with the posts they mention.
+SELECT webmentions.id, webmentions.source, webmentions.title, webmentions.status, webmentions.verified_at,
+  webmentions.created_at, posts.title AS post_title, posts.slug AS post_slug
+FROM webmentions
+JOIN posts ON posts.id = webmentions.post_id
+WHERE webmentions.status = ? AND webmentions.verified_at IS NOT NULL
+ORDER BY webmentions.created_at DESC, webmentions.id DESC
+LIMIT ? OFFSET ?;
+
+-- name: CountWebmentionsByStatus :many
+SELECT status, COUNT(*) AS mention_count
+FROM webmentions
+WHERE verified_at IS NOT NULL
+GROUP BY status;
+
+-- name: SetWebmentionStatus :exec
+UPDATE webmentions
+SET status = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: QueueWebmentionSend :exec
+-- Queues a Webmention from a post to a page it links to, or to one it no
+-- longer does, starting over if one was sent before.
+INSERT INTO webmention_sends (post_id, source, target, next_attempt_at)
+VALUES (sqlc.arg(post_id), sqlc.arg(source), sqlc.arg(target), sqlc.arg(next_attempt_at))
+ON CONFLICT (post_id, target) DO UPDATE SET
+  source = excluded.source, endpoint = '', attempts = 0, status_code = 0, error = '',
+  queued_at = CURRENT_TIMESTAMP, sent_at = NULL, next_attempt_at = excluded.next_attempt_at;
+
+-- name: ListPendingWebmentionSends :many
+SELECT * FROM webmention_sends
+WHERE next_attempt_at IS NOT NULL
+ORDER BY next_attempt_at, id;
+
+-- name: RecordWebmentionSend :exec
+UPDATE webmention_sends
+SET endpoint = ?, attempts = attempts + 1, status_code = ?, error = ?, sent_at = ?, next_attempt_at = ?
+WHERE id = ?;
+
+-- name: ListPostWebmentionSends :many
+SELECT * FROM webmention_sends
+WHERE post_id = ?
+ORDER BY queued_at DESC, target;
@@ -0,0 +1,354 @@
+// Package activitypub implements the parts of ActivityPub that let a blog
+// be followed from Mastodon and other fediverse servers: the documents
+// describing an actor, its posts and its collections, WebFinger, which
+// finds an actor from a handle such as @blog@example.com, and the HTTP
+// Signatures that servers sign their requests to each other with.
+//
+// Only what Mastodon relies on is covered. Documents are read and written
+// as plain JSON with the ActivityStreams context rather than processed as
+// JSON-LD, and signatures are those of draft-cavage-http-signatures with
+// rsa-sha256 keys.
+package activitypub
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// ContentType is the media type of ActivityPub documents.
+	ContentType = "application/activity+json"
+	// JRDType is the media type of WebFinger answers.
+	JRDType = "application/jrd+json"
+	// Public addresses an activity to everyone.
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+	// Context is the JSON-LD context of documents other than actors.
+	Context = "https://www.w3.org/ns/activitystreams"
+)
+
+// ldType is the other media type servers accept ActivityPub documents as.
+const ldType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+// ActorContext is the JSON-LD context of an actor, which also defines its
+// public key.
+var ActorContext = []string{Context, "https://w3id.org/security/v1"}
+
+// maxDocument is the most of a document from another server that is read.
+const maxDocument = 1 << 20
+
+// Actor describes an account that can be followed: the blog, or one of
+// its followers.
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         *PublicKey `json:"publicKey,omitempty"`
+}
+
+// Endpoints are an actor's endpoints other than its inbox and outbox.
+type Endpoints struct {
+	// SharedInbox takes activities for any of the actors on its server,
+	// so that one delivery reaches all the followers there.
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// SharedInbox returns the actor's server's shared inbox, or the actor's
+// own inbox if the server has none.
+func (a *Actor) SharedInbox() string {
+	if a.Endpoints != nil && a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+// PublicKey is the key that an actor's requests are signed with.
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Object is a post, as an Article.
+type Object struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	AttributedTo string     `json:"attributedTo,omitempty"`
+	Name         string     `json:"name,omitempty"`
+	Content      string     `json:"content,omitempty"`
+	URL          string     `json:"url,omitempty"`
+	Published    *time.Time `json:"published,omitempty"`
+	Updated      *time.Time `json:"updated,omitempty"`
+	To           []string   `json:"to,omitempty"`
+	CC           []string   `json:"cc,omitempty"`
+	Tag          []Tag      `json:"tag,omitempty"`
+}
+
+// Tag is a hashtag on an Object.
+type Tag struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Href string `json:"href,omitempty"`
+}
+
+// Activity is something an actor did, such as creating an Object or
+// following another actor.
+type Activity struct {
+	Context   any        `json:"@context,omitempty"`
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Actor     string     `json:"actor"`
+	Published *time.Time `json:"published,omitempty"`
+	To        []string   `json:"to,omitempty"`
+	CC        []string   `json:"cc,omitempty"`
+	// Object is what the activity acts on. Activities received have it as
+	// a string, for just its id, or a map of the whole object.
+	Object any `json:"object"`
+}
+
+// ObjectRef returns the id of the activity's object and, if the object is
+// given in full, its type.
+func (a *Activity) ObjectRef() (id, typ string) {
+	switch o := a.Object.(type) {
+	case string:
+		return o, ""
+	case map[string]any:
+		id, _ = o["id"].(string)
+		typ, _ = o["type"].(string)
+		return id, typ
+	}
+	return "", ""
+}
+
+// Collection is an ordered collection, such as an actor's outbox or its
+// followers. Items may be left out, leaving only the count.
+type Collection struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	TotalItems   int64      `json:"totalItems"`
+	OrderedItems []Activity `json:"orderedItems,omitempty"`
+}
+
+// JRD is a WebFinger answer, a JSON Resource Descriptor.
+type JRD struct {
+	Subject string   `json:"subject"`
+	Aliases []string `json:"aliases,omitempty"`
+	Links   []Link   `json:"links"`
+}
+
+// Link is a link in a JRD.
+type Link struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// ParseAccount splits a WebFinger resource of the form acct:user@host,
+// which may start with an @ as handles are written.
+func ParseAccount(resource string) (user, host string, ok bool) {
+	acct, ok := strings.CutPrefix(resource, "acct:")
+	if !ok {
+		return "", "", false
+	}
+	user, host, ok = strings.Cut(strings.TrimPrefix(acct, "@"), "@")
+	if !ok || user == "" || host == "" {
+		return "", "", false
+	}
+	return user, host, true
+}
+
+// GenerateKey makes a key for an actor to sign its requests with.
+func GenerateKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+// MarshalPrivateKey encodes key as a PKCS #8 PEM block.
+func MarshalPrivateKey(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParsePrivateKey decodes an RSA key encoded by MarshalPrivateKey.
+func ParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("activitypub: no PEM block in private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("activitypub: private key is a %T, not an RSA key", key)
+	}
+	return rsaKey, nil
+}
+
+// MarshalPublicKey encodes key as a PKIX PEM block, as publicKeyPem holds
+// it.
+func MarshalPublicKey(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// ParsePublicKey decodes an RSA public key from a PKIX or PKCS #1 PEM
+// block.
+func ParsePublicKey(s string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("activitypub: no PEM block in public key")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("activitypub: public key is a %T, not an RSA key", key)
+	}
+	return rsaKey, nil
+}
+
+// Client fetches documents from other servers and delivers activities to
+// their inboxes. If it has a key, each request is signed with it, as
+// servers that only answer known servers expect.
+type Client struct {
+	HTTP  *http.Client
+	KeyID string
+	Key   *rsa.PrivateKey
+}
+
+// FetchActor fetches the actor with the given id.
+func (c *Client) FetchActor(ctx context.Context, id string) (*Actor, error) {
+	var a Actor
+	if err := c.fetch(ctx, id, &a); err != nil {
+		return nil, err
+	}
+	if !sameHost(a.ID, id) || a.Inbox == "" {
+		return nil, fmt.Errorf("activitypub: %s is not an actor", id)
+	}
+	return &a, nil
+}
+
+// PublicKey fetches the public key named keyID and returns it with the
+// actor that owns it. The key is usually named by its actor's id and a
+// fragment, so the document fetched is the actor, holding the key, but it
+// may be a document of the key alone.
+func (c *Client) PublicKey(ctx context.Context, keyID string) (owner string, key *rsa.PublicKey, err error) {
+	u, err := url.Parse(keyID)
+	if err != nil {
+		return "", nil, err
+	}
+	u.Fragment = ""
+	var doc struct {
+		PublicKey
+		Key *PublicKey `json:"publicKey"`
+	}
+	if err := c.fetch(ctx, u.String(), &doc); err != nil {
+		return "", nil, err
+	}
+	pk := doc.PublicKey
+	if doc.Key != nil {
+		pk = *doc.Key
+	}
+	if pk.ID != keyID || !sameHost(pk.Owner, keyID) {
+		return "", nil, fmt.Errorf("activitypub: %s does not hold the key %s", u, keyID)
+	}
+	key, err = ParsePublicKey(pk.PublicKeyPem)
+	if err != nil {
+		return "", nil, err
+	}
+	return pk.Owner, key, nil
+}
+
+// fetch GETs the document at id and decodes it into v.
+func (c *Client) fetch(ctx context.Context, id string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", ContentType+", "+ldType)
+	if c.Key != nil {
+		if err := Sign(req, nil, c.KeyID, c.Key); err != nil {
+			return err
+		}
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("activitypub: fetching %s: unexpected status %s", id, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocument)).Decode(v); err != nil {
+		return fmt.Errorf("activitypub: fetching %s: %w", id, err)
+	}
+	return nil
+}
+
+// Deliver POSTs an activity, encoded as JSON, to inbox. It returns the
+// status the inbox answered with, failing unless that is a 2xx status.
+func (c *Client) Deliver(ctx context.Context, inbox string, activity []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, strings.NewReader(string(activity)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if c.Key != nil {
+		if err := Sign(req, activity, c.KeyID, c.Key); err != nil {
+			return 0, err
+		}
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("activitypub: unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// sameHost reports whether the URLs a and b are on the same host, so that
+// one server cannot speak for another's actors.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil || ua.Host == "" {
+		return false
+	}
+	ub, err := url.Parse(b)
+	return err == nil && strings.EqualFold(ua.Host, ub.Host)
+}
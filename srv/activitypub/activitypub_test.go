@@ -0,0 +1,237 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+)
+
+// newTestKey returns a key shared by the tests, as making one is slow.
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	testKeyOnce.Do(func() {
+		key, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testKey = key
+	})
+	return testKey
+}
+
+func TestParseAccount(t *testing.T) {
+	tests := []struct {
+		resource   string
+		user, host string
+		ok         bool
+	}{
+		{"acct:blog@example.com", "blog", "example.com", true},
+		{"acct:@blog@example.com", "blog", "example.com", true},
+		{"acct:blog", "", "", false},
+		{"acct:@example.com", "", "", false},
+		{"https://example.com/activitypub/actor", "", "", false},
+	}
+	for _, test := range tests {
+		user, host, ok := ParseAccount(test.resource)
+		if user != test.user || host != test.host || ok != test.ok {
+			t.Errorf("ParseAccount(%q) = %q, %q, %v, expected %q, %q, %v", test.resource, user, host, ok, test.user, test.host, test.ok)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	key := newTestKey(t)
+	private, err := MarshalPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePrivateKey(private)
+	if err != nil || !parsed.Equal(key) {
+		t.Fatalf("private key did not survive encoding: %v", err)
+	}
+	public, err := MarshalPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(public, "-----BEGIN PUBLIC KEY-----") {
+		t.Errorf("public key is not a PKIX PEM block:\n%s", public)
+	}
+	parsedPublic, err := ParsePublicKey(public)
+	if err != nil || !parsedPublic.Equal(&key.PublicKey) {
+		t.Fatalf("public key did not survive encoding: %v", err)
+	}
+	if _, err := ParsePublicKey("not a key"); err == nil {
+		t.Error("parsed a public key from garbage")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := newTestKey(t)
+	const keyID = "https://social.example/users/alice#main-key"
+	lookup := func(id string) (*rsa.PublicKey, error) {
+		if id != keyID {
+			t.Errorf("looked up key %q, expected %q", id, keyID)
+		}
+		return &key.PublicKey, nil
+	}
+
+	var gotKey string
+	var gotErr error
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if tamper := r.Header.Get("X-Tamper"); tamper != "" {
+			body = []byte(tamper)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		gotKey, gotErr = Verify(r, body, lookup)
+	}))
+	defer ts.Close()
+
+	send := func(body string, change func(*http.Request)) (string, error) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/inbox?x=1", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Sign(req, []byte(body), keyID, key); err != nil {
+			t.Fatal(err)
+		}
+		if change != nil {
+			change(req)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		return gotKey, gotErr
+	}
+
+	if id, err := send(`{"type":"Follow"}`, nil); err != nil || id != keyID {
+		t.Errorf("signed request: got %q, %v, expected %q", id, err, keyID)
+	}
+	if _, err := send(`{"type":"Follow"}`, func(r *http.Request) { r.Header.Set("X-Tamper", `{"type":"Undo"}`) }); err == nil {
+		t.Error("verified a request whose body changed")
+	}
+	if _, err := send(`{"type":"Follow"}`, func(r *http.Request) { r.URL.Path = "/other" }); err == nil {
+		t.Error("verified a request sent to another path")
+	}
+	if _, err := send(`{"type":"Follow"}`, func(r *http.Request) {
+		r.Header.Set("Date", time.Now().Add(-13*time.Hour).UTC().Format(http.TimeFormat))
+	}); err == nil {
+		t.Error("verified a request signed too long ago")
+	}
+	if _, err := send(`{"type":"Follow"}`, func(r *http.Request) { r.Header.Del("Signature") }); err != ErrNotSigned {
+		t.Errorf("unsigned request: got %v, expected ErrNotSigned", err)
+	}
+	if _, err := send(`{"type":"Follow"}`, func(r *http.Request) {
+		r.Header.Set("Signature", strings.Replace(r.Header.Get("Signature"), " digest", "", 1))
+	}); err == nil {
+		t.Error("verified a signature that does not cover the digest")
+	}
+}
+
+func TestClient(t *testing.T) {
+	key := newTestKey(t)
+	public, err := MarshalPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts *httptest.Server
+	var delivered []byte
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			if _, err := Verify(r, body, func(string) (*rsa.PublicKey, error) { return &key.PublicKey, nil }); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			delivered = body
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if r.Header.Get("Signature") == "" {
+			http.Error(w, "sign your requests", http.StatusUnauthorized)
+			return
+		}
+		id := ts.URL + r.URL.Path
+		owner := id
+		if r.URL.Path == "/users/mallory" {
+			owner = "https://elsewhere.example/users/mallory"
+		}
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Actor{
+			Context:   ActorContext,
+			ID:        id,
+			Type:      "Person",
+			Inbox:     id + "/inbox",
+			Endpoints: &Endpoints{SharedInbox: ts.URL + "/inbox"},
+			PublicKey: &PublicKey{ID: id + "#main-key", Owner: owner, PublicKeyPem: public},
+		})
+	}))
+	defer ts.Close()
+
+	c := &Client{HTTP: ts.Client(), KeyID: "https://blog.example/activitypub/actor#main-key", Key: key}
+	ctx := context.Background()
+
+	actor, err := c.FetchActor(ctx, ts.URL+"/users/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actor.Inbox != ts.URL+"/users/alice/inbox" || actor.SharedInbox() != ts.URL+"/inbox" {
+		t.Errorf("inboxes = %q and %q", actor.Inbox, actor.SharedInbox())
+	}
+
+	owner, got, err := c.PublicKey(ctx, ts.URL+"/users/alice#main-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != ts.URL+"/users/alice" || !got.Equal(&key.PublicKey) {
+		t.Errorf("PublicKey returned owner %q and a different key", owner)
+	}
+	if _, _, err := c.PublicKey(ctx, ts.URL+"/users/alice#other-key"); err == nil {
+		t.Error("found a key the actor does not hold")
+	}
+	if _, _, err := c.PublicKey(ctx, ts.URL+"/users/mallory#main-key"); err == nil {
+		t.Error("accepted a key whose owner is on another server")
+	}
+
+	if status, err := c.Deliver(ctx, ts.URL+"/inbox", []byte(`{"type":"Create"}`)); err != nil || status != http.StatusAccepted {
+		t.Fatalf("Deliver = %d, %v", status, err)
+	}
+	if string(delivered) != `{"type":"Create"}` {
+		t.Errorf("delivered %q", delivered)
+	}
+}
+
+func TestObjectRef(t *testing.T) {
+	var a Activity
+	if err := json.Unmarshal([]byte(`{"type":"Undo","object":{"id":"https://x.example/1","type":"Follow"}}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	if id, typ := a.ObjectRef(); id != "https://x.example/1" || typ != "Follow" {
+		t.Errorf("ObjectRef() = %q, %q", id, typ)
+	}
+	if err := json.Unmarshal([]byte(`{"type":"Follow","object":"https://blog.example/activitypub/actor"}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	if id, typ := a.ObjectRef(); id != "https://blog.example/activitypub/actor" || typ != "" {
+		t.Errorf("ObjectRef() = %q, %q", id, typ)
+	}
+}
@@ -0,0 +1,149 @@
+package activitypub
+
+import (
+	"cmp"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxClockSkew is how far from now the Date of a signed request may be.
+// Mastodon allows as much, as deliveries are often retried.
+const maxClockSkew = 12 * time.Hour
+
+// ErrNotSigned is returned by Verify for a request with no signature.
+var ErrNotSigned = errors.New("activitypub: request is not signed")
+
+// Sign signs r, whose body is body, or nil for a request without one, as
+// the key named keyID. It sets the request's Date and, if it has a body,
+// its Digest, and signs those along with its method, path and host.
+func Sign(r *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		r.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+	signed, err := signingString(r, headers)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+var reSignatureParam = regexp.MustCompile(`(\w+)\s*=\s*"([^"]*)"`)
+
+// Verify checks the signature of r, a request received with body, and
+// returns the keyId it names. publicKey is called to look up that key. The
+// signature must cover the request's method and path, host and date and,
+// if it has a body, its digest, which must match the body, and the date
+// must be within maxClockSkew of now.
+func Verify(r *http.Request, body []byte, publicKey func(keyID string) (*rsa.PublicKey, error)) (string, error) {
+	header := r.Header.Get("Signature")
+	if header == "" {
+		return "", ErrNotSigned
+	}
+	params := map[string]string{}
+	for _, m := range reSignatureParam.FindAllStringSubmatch(header, -1) {
+		params[m[1]] = m[2]
+	}
+	keyID := params["keyId"]
+	sig, err := base64.StdEncoding.DecodeString(params["signature"])
+	if keyID == "" || err != nil || len(sig) == 0 {
+		return "", errors.New("activitypub: malformed signature")
+	}
+	if alg := params["algorithm"]; alg != "" && alg != "rsa-sha256" && alg != "hs2019" {
+		return "", fmt.Errorf("activitypub: unsupported signature algorithm %q", alg)
+	}
+	headers := strings.Fields(strings.ToLower(cmp.Or(params["headers"], "date")))
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, h := range required {
+		if !slices.Contains(headers, h) {
+			return "", fmt.Errorf("activitypub: signature does not cover %s", h)
+		}
+	}
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("activitypub: bad Date: %w", err)
+	}
+	if skew := time.Since(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", errors.New("activitypub: signed too long ago or in the future")
+	}
+	if len(body) > 0 && !hasDigest(r.Header.Get("Digest"), digest(body)) {
+		return "", errors.New("activitypub: Digest does not match the body")
+	}
+	signed, err := signingString(r, headers)
+	if err != nil {
+		return "", err
+	}
+	key, err := publicKey(keyID)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(signed))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig); err != nil {
+		return "", errors.New("activitypub: signature does not verify")
+	}
+	return keyID, nil
+}
+
+// signingString returns what is signed for r: each of headers, lowercase,
+// with its value, one to a line. The pseudo-header (request-target) is the
+// method and path.
+func signingString(r *http.Request, headers []string) (string, error) {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		var v string
+		switch h {
+		case "(request-target)":
+			v = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case "host":
+			v = cmp.Or(r.Host, r.URL.Host)
+		default:
+			values := r.Header.Values(h)
+			if len(values) == 0 {
+				return "", fmt.Errorf("activitypub: signed header %s is missing", h)
+			}
+			v = strings.Join(values, ", ")
+		}
+		lines[i] = h + ": " + v
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// digest returns the Digest header of a request with body.
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// hasDigest reports whether the Digest header, which may list digests
+// made with several algorithms, includes want.
+func hasDigest(header, want string) bool {
+	for d := range strings.SplitSeq(header, ",") {
+		alg, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(alg, "SHA-256") && "SHA-256="+value == want {
+			return true
+		}
+	}
+	return false
+}
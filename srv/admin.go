@@ -1,14 +1,19 @@
 package srv
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/diff"
 )
 
 // AdminEmails contains emails allowed to access admin
@@ -17,25 +22,37 @@ var AdminEmails = []string{
 }
 
 func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	next = s.requireCSRF(next)
+	return s.refuseBanned(func(w http.ResponseWriter, r *http.Request) {
 		// Skip auth in dev mode
 		if os.Getenv("DEV_MODE") == "1" {
 			next(w, r)
 			return
 		}
-		
-		email := strings.TrimSpace(r.Header.Get("X-ExeDev-Email"))
-		
+
+		if email := s.sessionUser(r); email != "" {
+			next(w, r.WithContext(context.WithValue(r.Context(), adminKey{}, email)))
+			return
+		}
+		// Once anyone can sign in with a password, the exe.dev header is
+		// no longer trusted, in case the proxy is bypassed.
+		if s.hasUsers(r.Context()) {
+			http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+
+		email := adminEmail(r)
+
 		// If no admin emails configured, allow any authenticated user
 		if len(AdminEmails) == 0 {
 			if email == "" {
-				http.Redirect(w, r, "/__exe.dev/login?redirect="+r.URL.Path, http.StatusFound)
+				http.Redirect(w, r, "/__exe.dev/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
 				return
 			}
 			next(w, r)
 			return
 		}
-		
+
 		// Check if email is in admin list
 		for _, admin := range AdminEmails {
 			if strings.EqualFold(email, admin) {
@@ -43,22 +60,99 @@ func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 		}
-		
+
 		if email == "" {
-			http.Redirect(w, r, "/__exe.dev/login?redirect="+r.URL.Path, http.StatusFound)
+			http.Redirect(w, r, "/__exe.dev/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
 			return
 		}
-		
+
 		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
+// StatusCounts is the number of posts in each status, for the filter
+// links of the admin post list.
+type StatusCounts struct {
+	All       int64
+	Published int64
+	Drafts    int64
+}
+
+// statusCounts counts the posts that are not in the trash.
+func statusCounts(ctx context.Context, q *dbgen.Queries) StatusCounts {
+	var counts StatusCounts
+	rows, err := q.CountPostsByStatus(ctx)
+	if err != nil {
+		slog.Error("count posts", "error", err)
+	}
+	for _, row := range rows {
+		if row.Published == 1 {
+			counts.Published += row.PostCount
+		} else {
+			counts.Drafts += row.PostCount
+		}
+		counts.All += row.PostCount
+	}
+	return counts
+}
+
+// adminPageSize is the number of posts on each page of the admin post list.
+const adminPageSize = 25
+
+// listAdminPosts returns a page of the posts matching filter, sorted by
+// "title", "updated" or, for anything else, newest first.
+func listAdminPosts(ctx context.Context, q *dbgen.Queries, sort string, filter dbgen.ListAdminPostsByCreatedParams) ([]dbgen.Post, error) {
+	switch sort {
+	case "title":
+		return q.ListAdminPostsByTitle(ctx, dbgen.ListAdminPostsByTitleParams(filter))
+	case "updated":
+		return q.ListAdminPostsByUpdated(ctx, dbgen.ListAdminPostsByUpdatedParams(filter))
+	default:
+		return q.ListAdminPostsByCreated(ctx, filter)
 	}
 }
 
+// HandleAdminList lists posts a page at a time. The status, q and sort
+// query parameters filter the list by status, filter it by a search of
+// titles and slugs, and sort it by "created", "updated" or "title".
 func (s *Server) HandleAdminList(w http.ResponseWriter, r *http.Request) {
 	q := dbgen.New(s.DB)
-	posts, err := q.GetAllPosts(r.Context())
+	status := r.URL.Query().Get("status")
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	sort := r.URL.Query().Get("sort")
+	if sort != "updated" && sort != "title" {
+		sort = "created"
+	}
+	page := pageNumber(r)
+
+	filter := dbgen.ListAdminPostsByCreatedParams{
+		Search: search,
+		Limit:  adminPageSize,
+		Offset: int64((page - 1) * adminPageSize),
+	}
+	switch status {
+	case "published":
+		published := int64(1)
+		filter.Published = &published
+	case "draft":
+		filter.Published = new(int64)
+	default:
+		status = "all"
+	}
+	posts, err := listAdminPosts(r.Context(), q, sort, filter)
 	if err != nil {
-		slog.Error("get all posts", "error", err)
+		slog.Error("get posts", "error", err)
 	}
+	total, err := q.CountAdminPosts(r.Context(), dbgen.CountAdminPostsParams{
+		Published: filter.Published,
+		Search:    search,
+	})
+	if err != nil {
+		slog.Error("count posts", "error", err)
+	}
+
+	counts := statusCounts(r.Context(), q)
+	views := postViewTotals(r.Context(), q)
 
 	var postViews []PostView
 	for _, p := range posts {
@@ -67,23 +161,27 @@ func (s *Server) HandleAdminList(w http.ResponseWriter, r *http.Request) {
 			Slug:      p.Slug,
 			Title:     p.Title,
 			Published: p.Published == 1,
+			PublishAt: derefTime(p.PublishAt),
 			CreatedAt: p.CreatedAt,
 			UpdatedAt: p.UpdatedAt,
+			Views:     views[p.ID],
 		})
 	}
 
 	s.render(w, "admin.html", map[string]any{
-		"Posts": postViews,
-		"Year":  time.Now().Year(),
+		"Posts":      postViews,
+		"Status":     status,
+		"Search":     search,
+		"Sort":       sort,
+		"Counts":     counts,
+		"Pagination": paginate(page, adminPageSize, total),
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
 	})
 }
 
 func (s *Server) HandleAdminNew(w http.ResponseWriter, r *http.Request) {
-	s.render(w, "admin_edit.html", map[string]any{
-		"IsNew": true,
-		"Post":  PostView{},
-		"Year":  time.Now().Year(),
-	})
+	s.renderEdit(w, r, PostView{}, "")
 }
 
 func (s *Server) HandleAdminCreate(w http.ResponseWriter, r *http.Request) {
@@ -91,53 +189,123 @@ func (s *Server) HandleAdminCreate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
-
-	slug := strings.TrimSpace(r.FormValue("slug"))
-	title := strings.TrimSpace(r.FormValue("title"))
-	content := r.FormValue("content")
-	published := r.FormValue("published") == "on"
-
-	if slug == "" || title == "" {
-		s.render(w, "admin_edit.html", map[string]any{
-			"IsNew": true,
-			"Post": PostView{
-				Slug:    slug,
-				Title:   title,
-				Content: content,
-			},
-			"Error": "Slug and title are required",
-			"Year":  time.Now().Year(),
-		})
+	post := readPostForm(r)
+	if err := s.createPost(r.Context(), &post); err != nil {
+		var perr postError
+		if errors.As(err, &perr) {
+			s.renderEdit(w, r, post, perr.Error())
+			return
+		}
+		slog.Error("create post", "error", err)
+		s.renderEdit(w, r, post, "Failed to create post: "+err.Error())
 		return
 	}
 
+	http.Redirect(w, r, "/admin/posts", http.StatusFound)
+}
+
+// readPostForm returns the post submitted by the edit form.
+func readPostForm(r *http.Request) PostView {
+	return PostView{
+		Slug:            strings.TrimSpace(r.FormValue("slug")),
+		Title:           strings.TrimSpace(r.FormValue("title")),
+		Content:         r.FormValue("content"),
+		Excerpt:         strings.TrimSpace(r.FormValue("excerpt")),
+		Published:       r.FormValue("published") == "on",
+		AllowHTML:       r.FormValue("allow_html") == "on",
+		MetaDescription: strings.TrimSpace(r.FormValue("meta_description")),
+		OGImage:         strings.TrimSpace(r.FormValue("og_image")),
+		CoverImage:      strings.TrimSpace(r.FormValue("cover_image")),
+		SeriesID:        formInt(r, "series_id"),
+		SeriesOrder:     formInt(r, "series_order"),
+		CategoryID:      formInt(r, "category_id"),
+		PublishAt:       formTime(r, "publish_at"),
+		CommentsClosed:  r.FormValue("comments") != "on",
+		NoCrosspost:     r.FormValue("crosspost") != "on",
+		TagList:         r.FormValue("tags"),
+		UpdatedAt:       formVersion(r),
+	}
+}
+
+// formVersion returns the updated_at of the post as it was when the edit
+// form was loaded, or the zero time if the form did not send it.
+func formVersion(r *http.Request) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, r.FormValue("updated_at"))
+	return t
+}
+
+// formInt returns the form value key as an integer, or 0 if it is missing
+// or not a number.
+func formInt(r *http.Request, key string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(r.FormValue(key)), 10, 64)
+	return n
+}
+
+// formTime returns the form value key, as sent by a datetime-local input,
+// as a time in the server's time zone, or the zero time if it is missing
+// or malformed.
+func formTime(r *http.Request, key string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02T15:04", strings.TrimSpace(r.FormValue(key)), time.Local)
+	return t
+}
+
+// checkPostImages returns an error message if an image field of post is
+// neither a URL nor a path on this site.
+func checkPostImages(post PostView) string {
+	if !isImageRef(post.OGImage) {
+		return "Share image must be an http or https URL or a path on this site"
+	}
+	if !isImageRef(post.CoverImage) {
+		return "Cover image must be an http or https URL or a path on this site"
+	}
+	return ""
+}
+
+// renderEdit shows the edit form for post, with errMsg if it is not empty.
+// A post without an ID is a new one.
+func (s *Server) renderEdit(w http.ResponseWriter, r *http.Request, post PostView, errMsg string) {
+	s.render(w, "admin_edit.html", s.editData(r, post, errMsg))
+}
+
+// renderConflict shows the edit form again when post was saved by someone
+// else since the editor loaded it. The form keeps the submitted version,
+// with a diff from the saved one, and saving it again replaces current.
+func (s *Server) renderConflict(w http.ResponseWriter, r *http.Request, post PostView, current dbgen.Post) {
+	post.UpdatedAt = current.UpdatedAt
+	data := s.editData(r, post, "")
+	data["Conflict"] = map[string]any{
+		"SavedAt": current.UpdatedAt,
+		"Title":   current.Title,
+		"Diff":    diff.Lines(current.Content, post.Content),
+	}
+	s.renderStatus(w, http.StatusConflict, "admin_edit.html", data)
+}
+
+// editData returns the template data for the edit form.
+func (s *Server) editData(r *http.Request, post PostView, errMsg string) map[string]any {
 	q := dbgen.New(s.DB)
-	var pub int64
-	if published {
-		pub = 1
-	}
-	_, err := q.CreatePost(r.Context(), dbgen.CreatePostParams{
-		Slug:      slug,
-		Title:     title,
-		Content:   content,
-		Published: pub,
-	})
+	series, err := q.GetAllSeries(r.Context())
 	if err != nil {
-		slog.Error("create post", "error", err)
-		s.render(w, "admin_edit.html", map[string]any{
-			"IsNew": true,
-			"Post": PostView{
-				Slug:    slug,
-				Title:   title,
-				Content: content,
-			},
-			"Error": "Failed to create post: " + err.Error(),
-			"Year":  time.Now().Year(),
-		})
-		return
+		slog.Error("get series", "error", err)
+	}
+	categories, err := q.GetCategories(r.Context())
+	if err != nil {
+		slog.Error("get categories", "error", err)
+	}
+	media, err := s.listMedia(r.Context(), mediaPickerSize)
+	if err != nil {
+		slog.Error("list media", "error", err)
+	}
+	return map[string]any{
+		"IsNew":      post.ID == 0,
+		"Post":       post,
+		"Series":     series,
+		"Categories": categories,
+		"Media":      media,
+		"Error":      errMsg,
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
 	}
-
-	http.Redirect(w, r, "/admin", http.StatusFound)
 }
 
 func (s *Server) HandleAdminEdit(w http.ResponseWriter, r *http.Request) {
@@ -149,36 +317,50 @@ func (s *Server) HandleAdminEdit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	q := dbgen.New(s.DB)
-	posts, err := q.GetAllPosts(r.Context())
+	post, err := q.GetPostByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
+		slog.Error("get post", "error", err)
 		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
 	}
-
-	var post *dbgen.Post
-	for _, p := range posts {
-		if p.ID == id {
-			post = &p
-			break
-		}
-	}
-	if post == nil {
+	if post.DeletedAt != nil {
+		// Trashed posts must be restored before they can be edited.
 		http.NotFound(w, r)
 		return
 	}
 
-	s.render(w, "admin_edit.html", map[string]any{
-		"IsNew": false,
-		"Post": PostView{
-			ID:        post.ID,
-			Slug:      post.Slug,
-			Title:     post.Title,
-			Content:   post.Content,
-			Published: post.Published == 1,
-			CreatedAt: post.CreatedAt,
-		},
-		"Year": time.Now().Year(),
-	})
+	postTags, err := q.GetPostTags(r.Context(), id)
+	if err != nil {
+		slog.Error("get post tags", "error", err)
+	}
+	lockedBy, err := s.lockPost(r.Context(), id, adminEmail(r), r.URL.Query().Has("takeover"))
+	if err != nil {
+		slog.Error("lock post", "error", err)
+	}
+
+	autosave, err := pendingAutosave(r.Context(), q, post)
+	if err != nil {
+		slog.Error("get post autosave", "error", err)
+	}
+
+	view := postView(post, postTags)
+	// The form keeps the post's updated_at, so saving recovered changes
+	// still fails if someone else has saved the post meanwhile.
+	recovered := autosave != nil && r.URL.Query().Has("recover")
+	if recovered {
+		view.Title, view.Content, view.Excerpt = autosave.Title, autosave.Content, autosave.Excerpt
+	}
+	data := s.editData(r, view, "")
+	data["LockedBy"] = lockedBy
+	data["Autosave"] = autosave
+	data["Recovered"] = recovered
+	data["PreviewLinks"] = s.previewLinks(r, id)
+	data["PreviewLinkDays"] = s.previewLinkDays(r)
+	s.render(w, "admin_edit.html", data)
 }
 
 func (s *Server) HandleAdminUpdate(w http.ResponseWriter, r *http.Request) {
@@ -194,30 +376,33 @@ func (s *Server) HandleAdminUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := strings.TrimSpace(r.FormValue("title"))
-	content := r.FormValue("content")
-	published := r.FormValue("published") == "on"
-
-	q := dbgen.New(s.DB)
-	var pub int64
-	if published {
-		pub = 1
-	}
-	err = q.UpdatePost(r.Context(), dbgen.UpdatePostParams{
-		Title:     title,
-		Content:   content,
-		Published: pub,
-		ID:        id,
-	})
-	if err != nil {
+	post := readPostForm(r)
+	post.ID = id
+	// A form without updated_at, from a client that does not track
+	// versions, overwrites unconditionally.
+	err = s.updatePost(r.Context(), &post, adminEmail(r))
+	var perr postError
+	var conflict *editConflict
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.NotFound(w, r)
+		return
+	case errors.As(err, &conflict):
+		s.renderConflict(w, r, post, conflict.current)
+		return
+	case errors.As(err, &perr):
+		s.renderEdit(w, r, post, perr.Error())
+		return
+	case err != nil:
 		slog.Error("update post", "error", err)
 		http.Error(w, "Failed to update", http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, "/admin", http.StatusFound)
+	http.Redirect(w, r, "/admin/posts", http.StatusFound)
 }
 
+// HandleAdminDelete moves a post to the trash.
 func (s *Server) HandleAdminDelete(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -227,10 +412,56 @@ func (s *Server) HandleAdminDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	q := dbgen.New(s.DB)
-	err = q.DeletePost(r.Context(), id)
+	post, err := q.GetPostByID(r.Context(), id)
 	if err != nil {
-		slog.Error("delete post", "error", err)
+		http.NotFound(w, r)
+		return
 	}
+	err = q.TrashPost(r.Context(), id)
+	if err != nil {
+		slog.Error("trash post", "error", err)
+	}
+	s.renders.remove(id)
+	s.postChanged(r.Context(), &post, id)
+
+	http.Redirect(w, r, "/admin/posts", http.StatusFound)
+}
 
-	http.Redirect(w, r, "/admin", http.StatusFound)
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// nullInt64 maps 0, which no row ID uses, to NULL.
+func nullInt64(n int64) *int64 {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
+
+func derefInt64(n *int64) int64 {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
+// scheduledAt returns when post is due to be published, or nil if it is
+// already published or not scheduled.
+func scheduledAt(post PostView) *time.Time {
+	if post.Published || post.PublishAt.IsZero() {
+		return nil
+	}
+	t := post.PublishAt.UTC()
+	return &t
+}
+
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
 }
@@ -0,0 +1,249 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// analyticsWindows are the windows, in days up to and including today,
+// the analytics page can show. The first is shown unless another is
+// asked for.
+var analyticsWindows = []int{30, 7, 90, 365}
+
+// settingVisitorSalt is the setting holding the day, in UTC, and the
+// salt visitors are hashed with on it, separated by a space. It is
+// replaced on the first visit of each day, so that no hash can be linked
+// to the next day's or, once the salt is gone, to an address. Not being in
+// settingDefs, it is not shown in the admin.
+const settingVisitorSalt = "visitor_salt"
+
+const (
+	// analyticsPages is the most pages the analytics page lists.
+	analyticsPages = 20
+	// analyticsHosts is the most referring hosts the analytics page lists.
+	analyticsHosts = 20
+	// analyticsPostReferrers is the most pairs of a post and a host linking
+	// to it the analytics page lists.
+	analyticsPostReferrers = 50
+	// analyticsLinks is the most links to other sites, and
+	// analyticsPostLinks the most pairs of a post and such a link in it,
+	// the analytics page lists by clicks.
+	analyticsLinks     = 20
+	analyticsPostLinks = 50
+)
+
+// pageKey is what page views are counted by: a day, in UTC, and a path.
+type pageKey struct {
+	day  string
+	path string
+}
+
+// visitorKey is a visitor of the site on a day, in UTC, known only by the
+// hash of their address and browser with that day's salt.
+type visitorKey struct {
+	day     string
+	visitor string
+}
+
+// DayCount is the page views and visitors of a day on the analytics page.
+type DayCount struct {
+	Day      time.Time
+	Views    int64
+	Visitors int64
+	Percent  int64 // of the most viewed day charted, for the height of its bar
+}
+
+// statusRecorder is a ResponseWriter that remembers the status written to
+// it, 0 if none was and so it is 200.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// track counts the page next serves as viewed, by whoever asked for it,
+// when it is served in full to a GET and not taken for a bot's by
+// classifyBot. As with views of posts, 304s, errors, redirects and HEAD
+// requests are not counted. Nothing is stored in the reader's browser and
+// no script is needed.
+func (s *Server) track(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = withBot(r, s.classifyBot(r))
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+		if r.Method == http.MethodGet && (rec.status == 0 || rec.status == http.StatusOK) && !isBot(r) {
+			s.countPageView(r)
+		}
+	}
+}
+
+// visitor returns who r's sender counts as today: a hash of their address
+// and browser with today's salt.
+func (s *Server) visitor(r *http.Request) (visitorKey, error) {
+	day := time.Now().UTC().Format(time.DateOnly)
+	salt, err := s.dailySalt(r.Context(), day)
+	if err != nil {
+		return visitorKey{}, err
+	}
+	sum := sha256.Sum256([]byte(salt + "\n" + clientIP(r).String() + "\n" + r.UserAgent()))
+	return visitorKey{day, hex.EncodeToString(sum[:16])}, nil
+}
+
+// countPageView counts a view of the page at r's path, and r's sender as
+// a visitor today. Like views of posts, they are kept in memory for
+// viewLoop to write.
+func (s *Server) countPageView(r *http.Request) {
+	k, err := s.visitor(r)
+	if err != nil {
+		slog.Error("load visitor salt", "error", err)
+		return
+	}
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+	views := s.viewBuffer()
+	views.pages[pageKey{k.day, r.URL.Path}]++
+	views.visitors[k] = true
+}
+
+// dailySalt returns the salt visitors are hashed with on day, replacing
+// the stored one, and so forgetting it, if it is an earlier day's.
+func (s *Server) dailySalt(ctx context.Context, day string) (string, error) {
+	s.saltMu.Lock()
+	defer s.saltMu.Unlock()
+	if s.saltDay == day {
+		return s.visitorSalt, nil
+	}
+	q := dbgen.New(s.DB)
+	stored, err := q.GetSetting(ctx, settingVisitorSalt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	saltDay, salt, _ := strings.Cut(stored, " ")
+	if saltDay != day || salt == "" {
+		salt = rand.Text()
+		err := q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingVisitorSalt, Value: day + " " + salt})
+		if err != nil {
+			return "", err
+		}
+	}
+	s.saltDay, s.visitorSalt = day, salt
+	return salt, nil
+}
+
+// dailyCounts returns the page views and visitors of each of the days up
+// to and including today from since, oldest first, including days with
+// none.
+func dailyCounts(ctx context.Context, q *dbgen.Queries, since time.Time, days int) []DayCount {
+	counts := make([]DayCount, days)
+	index := make(map[string]int, days)
+	for i := range counts {
+		counts[i].Day = since.AddDate(0, 0, i)
+		index[counts[i].Day.Format(time.DateOnly)] = i
+	}
+
+	from := since.Format(time.DateOnly)
+	views, err := q.ListDailyPageViews(ctx, from)
+	if err != nil {
+		slog.Error("list daily page views", "error", err)
+	}
+	var busiest int64
+	for _, row := range views {
+		if i, ok := index[row.Day]; ok {
+			counts[i].Views = row.Views
+			busiest = max(busiest, row.Views)
+		}
+	}
+	visitors, err := q.ListDailyVisitorCounts(ctx, from)
+	if err != nil {
+		slog.Error("list daily visitors", "error", err)
+	}
+	for _, row := range visitors {
+		if i, ok := index[row.Day]; ok {
+			counts[i].Visitors = row.Visitors
+		}
+	}
+	if busiest > 0 {
+		for i := range counts {
+			counts[i].Percent = counts[i].Views * 100 / busiest
+		}
+	}
+	return counts
+}
+
+// HandleAdminAnalytics shows the site's page views and visitors each day,
+// its most viewed pages, where readers of posts came from and the links
+// to other sites they followed from them, over the window of days in the
+// days query parameter, one of analyticsWindows.
+func (s *Server) HandleAdminAnalytics(w http.ResponseWriter, r *http.Request) {
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if !slices.Contains(analyticsWindows, days) {
+		days = analyticsWindows[0]
+	}
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1-days)
+	since := start.Format(time.DateOnly)
+	q := dbgen.New(s.DB)
+	daily := dailyCounts(r.Context(), q, start, days)
+	var views, visitors int64
+	for _, d := range daily {
+		views += d.Views
+		visitors += d.Visitors
+	}
+	pages, err := q.ListTopPages(r.Context(), dbgen.ListTopPagesParams{Since: since, MaxPages: analyticsPages})
+	if err != nil {
+		slog.Error("list top pages", "error", err)
+	}
+	hosts, err := q.ListTopReferrers(r.Context(), dbgen.ListTopReferrersParams{Since: since, MaxHosts: analyticsHosts})
+	if err != nil {
+		slog.Error("list top referrers", "error", err)
+	}
+	postReferrers, err := q.ListTopPostReferrers(r.Context(), dbgen.ListTopPostReferrersParams{Since: since, MaxRows: analyticsPostReferrers})
+	if err != nil {
+		slog.Error("list top post referrers", "error", err)
+	}
+	links, err := q.ListTopLinkTargets(r.Context(), dbgen.ListTopLinkTargetsParams{Since: since, MaxTargets: analyticsLinks})
+	if err != nil {
+		slog.Error("list top link targets", "error", err)
+	}
+	postLinks, err := q.ListTopPostLinkClicks(r.Context(), dbgen.ListTopPostLinkClicksParams{Since: since, MaxRows: analyticsPostLinks})
+	if err != nil {
+		slog.Error("list top post link clicks", "error", err)
+	}
+	s.render(w, "admin_analytics.html", map[string]any{
+		"Days":          days,
+		"Windows":       analyticsWindows,
+		"Daily":         daily,
+		"Views":         views,
+		"Visitors":      visitors,
+		"Pages":         pages,
+		"Referrers":     hosts,
+		"PostReferrers": postReferrers,
+		"Links":         links,
+		"PostLinks":     postLinks,
+		"TrackLinks":    s.settingBool(r.Context(), settingTrackLinks),
+		"CSRFToken":     csrfToken(r),
+		"Year":          time.Now().Year(),
+	})
+}
@@ -0,0 +1,200 @@
+package srv
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// apiError is the body of an API error response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("write json", "error", err)
+	}
+}
+
+// apiAuth is what an API endpoint asks of the requests it answers.
+type apiAuth int
+
+const (
+	// authNone endpoints answer anyone the same.
+	authNone apiAuth = iota
+	// authOptional endpoints answer anyone, and show more to requests
+	// with an API token.
+	authOptional
+	// authWrite endpoints need a token with the write scope, or an admin
+	// session.
+	authWrite
+	// authHook endpoints are called by other services' webhooks. They
+	// take what authWrite ones do, or a request signed with the sync
+	// secret.
+	authHook
+)
+
+// apiParam is a query parameter of an API endpoint.
+type apiParam struct {
+	name        string
+	typ         string // JSON schema type
+	format      string // JSON schema format, if any
+	required    bool
+	description string
+}
+
+// apiRoute is an endpoint of the public API. Serve registers the endpoints
+// from apiRoutes, and the OpenAPI document describes the same list, so
+// the two cannot disagree.
+type apiRoute struct {
+	pattern     string // as for http.ServeMux, with a method
+	operationID string
+	summary     string
+	auth        apiAuth
+	params      []apiParam
+	body        any // value of the type of the request body, if any
+	status      int // of a successful response
+	response    any // value of the type of a successful response body, if any
+	handler     http.HandlerFunc
+}
+
+// postsPage is a page of the posts list API.
+type postsPage struct {
+	Posts      []apiPost `json:"posts"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// suggestResponse is the body of a suggest API response.
+type suggestResponse struct {
+	Query       string                  `json:"query"`
+	Suggestions []dbgen.SuggestPostsRow `json:"suggestions"`
+}
+
+// apiRoutes returns the endpoints of the public API.
+func (s *Server) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{
+			pattern:     "GET /api/v1/search",
+			operationID: "searchPosts",
+			summary:     "Search published posts",
+			params: []apiParam{
+				{name: "q", typ: "string", required: true, description: "What to search for"},
+				{name: "limit", typ: "integer", description: "The most results to return"},
+			},
+			status:   http.StatusOK,
+			response: searchResponse{},
+			handler:  s.HandleSearchAPI,
+		},
+		{
+			pattern:     "GET /api/v1/suggest",
+			operationID: "suggestPosts",
+			summary:     "Suggest published posts whose titles start with a prefix",
+			params: []apiParam{
+				{name: "q", typ: "string", required: true, description: "The start of a title"},
+			},
+			status:   http.StatusOK,
+			response: suggestResponse{},
+			handler:  s.HandleSuggestAPI,
+		},
+		{
+			pattern:     "GET /api/v1/posts",
+			operationID: "listPosts",
+			summary:     "List posts, newest first",
+			auth:        authOptional,
+			params: []apiParam{
+				{name: "limit", typ: "integer", description: "Posts per page, 20 by default and at most 100"},
+				{name: "cursor", typ: "string", description: "The next_cursor of the previous page"},
+				{name: "status", typ: "string", description: "published (the default), or draft or all with an API token"},
+				{name: "tag", typ: "string", description: "Only posts with this tag"},
+				{name: "since", typ: "string", format: "date-time", description: "Only posts changed since this time"},
+			},
+			status:   http.StatusOK,
+			response: postsPage{},
+			handler:  s.HandlePostsAPI,
+		},
+		{
+			pattern:     "GET /api/v1/posts/{slug}",
+			operationID: "getPost",
+			summary:     "Get a post; drafts need an API token",
+			auth:        authOptional,
+			status:      http.StatusOK,
+			response:    apiPost{},
+			handler:     s.HandlePostAPI,
+		},
+		{
+			pattern:     "POST /api/v1/posts",
+			operationID: "createPost",
+			summary:     "Create a post",
+			auth:        authWrite,
+			body:        apiPostCreate{},
+			status:      http.StatusCreated,
+			response:    apiPost{},
+			handler:     s.HandleCreatePostAPI,
+		},
+		{
+			pattern:     "PATCH /api/v1/posts/{slug}",
+			operationID: "updatePost",
+			summary:     "Change the given fields of a post",
+			auth:        authWrite,
+			body:        apiPostInput{},
+			status:      http.StatusOK,
+			response:    apiPost{},
+			handler:     s.HandleUpdatePostAPI,
+		},
+		{
+			pattern:     "DELETE /api/v1/posts/{slug}",
+			operationID: "deletePost",
+			summary:     "Move a post to the trash",
+			auth:        authWrite,
+			status:      http.StatusNoContent,
+			handler:     s.HandleDeletePostAPI,
+		},
+		{
+			pattern:     "POST /api/v1/posts/{slug}/publish",
+			operationID: "publishPost",
+			summary:     "Publish a post now",
+			auth:        authWrite,
+			status:      http.StatusOK,
+			response:    apiPost{},
+			handler:     s.HandlePublishPostAPI,
+		},
+		{
+			pattern:     "POST /api/v1/posts/{slug}/unpublish",
+			operationID: "unpublishPost",
+			summary:     "Take a post back to a draft",
+			auth:        authWrite,
+			status:      http.StatusOK,
+			response:    apiPost{},
+			handler:     s.HandlePublishPostAPI,
+		},
+		{
+			pattern:     "POST /api/v1/hooks/sync",
+			operationID: "syncContent",
+			summary:     "Import posts from the content directory, pulling it first if it is a Git checkout",
+			auth:        authHook,
+			status:      http.StatusAccepted,
+			response:    syncResponse{},
+			handler:     s.HandleSyncHook,
+		},
+	}
+}
+
+// apiHandler returns the handler for route, behind the checks its auth
+// asks for.
+func (s *Server) apiHandler(route apiRoute) http.HandlerFunc {
+	switch route.auth {
+	case authOptional:
+		return s.allowToken(route.handler)
+	case authWrite:
+		return s.requireWriteToken(route.handler)
+	case authHook:
+		return s.requireSyncAuth(route.handler)
+	}
+	return route.handler
+}
@@ -0,0 +1,134 @@
+package srv
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// ArchiveYear is a year of the archive index with the months that have
+// posts.
+type ArchiveYear struct {
+	Year   int
+	Count  int64
+	Months []ArchiveMonth
+}
+
+// ArchiveMonth is a month of the archive. Months in the index carry only
+// a count; months in a listing carry their posts.
+type ArchiveMonth struct {
+	Year  int
+	Month time.Month
+	Count int64
+	Posts []PostView
+}
+
+// HandleArchive lists published posts grouped by month. With a year, and
+// optionally a month, in the path it lists only the posts of that period;
+// without, it also shows an index of every year and month.
+func (s *Server) HandleArchive(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	var (
+		dbPosts []dbgen.Post
+		period  string
+		index   []ArchiveYear
+		err     error
+	)
+	switch year, month, ok := archivePeriod(r); {
+	case !ok:
+		http.NotFound(w, r)
+		return
+	case month != 0:
+		period = fmt.Sprintf("%s %d", month, year)
+		dbPosts, err = q.GetPublishedPostsInMonth(r.Context(), fmt.Sprintf("%04d-%02d", year, month))
+	case year != 0:
+		period = strconv.Itoa(year)
+		dbPosts, err = q.GetPublishedPostsInYear(r.Context(), fmt.Sprintf("%04d", year))
+	default:
+		dbPosts, err = q.GetPublishedPosts(r.Context())
+		if err == nil {
+			index, err = s.archiveIndex(r)
+		}
+	}
+	if err != nil {
+		slog.Error("get archive", "error", err)
+	}
+	if period != "" && len(dbPosts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.render(w, "base.html", map[string]any{
+		"Months": groupByMonth(dbPosts),
+		"Index":  index,
+		"Period": period,
+		"Year":   time.Now().Year(),
+		"Page":   "archive",
+	})
+}
+
+// archivePeriod returns the year and month in the request path, each 0 if
+// absent. ok is false if either is present but not a valid number.
+func archivePeriod(r *http.Request) (year int, month time.Month, ok bool) {
+	if v := r.PathValue("year"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 9999 {
+			return 0, 0, false
+		}
+		year = n
+	}
+	if v := r.PathValue("month"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 12 {
+			return 0, 0, false
+		}
+		month = time.Month(n)
+	}
+	return year, month, true
+}
+
+// archiveIndex returns the number of published posts in each year and
+// month, newest first.
+func (s *Server) archiveIndex(r *http.Request) ([]ArchiveYear, error) {
+	rows, err := dbgen.New(s.DB).GetArchiveMonths(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	var years []ArchiveYear
+	for _, row := range rows {
+		year := int(row.Year)
+		if len(years) == 0 || years[len(years)-1].Year != year {
+			years = append(years, ArchiveYear{Year: year})
+		}
+		y := &years[len(years)-1]
+		y.Count += row.PostCount
+		y.Months = append(y.Months, ArchiveMonth{Year: year, Month: time.Month(row.Month), Count: row.PostCount})
+	}
+	return years, nil
+}
+
+// groupByMonth groups posts, which are sorted newest first, by the month
+// they were written in.
+func groupByMonth(posts []dbgen.Post) []ArchiveMonth {
+	var months []ArchiveMonth
+	for _, p := range posts {
+		year, month := p.CreatedAt.Year(), p.CreatedAt.Month()
+		if len(months) == 0 || months[len(months)-1].Year != year || months[len(months)-1].Month != month {
+			months = append(months, ArchiveMonth{Year: year, Month: month})
+		}
+		m := &months[len(months)-1]
+		m.Count++
+		m.Posts = append(m.Posts, PostView{
+			Slug:       p.Slug,
+			Title:      p.Title,
+			CoverImage: p.CoverImage,
+			CoverSrc:   sizedImage(p.CoverImage, 96, 96),
+			CreatedAt:  p.CreatedAt,
+		})
+	}
+	return months
+}
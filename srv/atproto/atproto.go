@@ -0,0 +1,222 @@
+// Package atproto is a small AT Protocol client, enough to post to a
+// Bluesky account: it signs in with an app password, uploads images and
+// creates app.bsky.feed.post records with links and link cards.
+//
+// Requests are XRPC calls to the account's PDS, its Personal Data Server,
+// which for most accounts is Bluesky's own, DefaultService.
+package atproto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultService is the PDS of accounts hosted by Bluesky.
+const DefaultService = "https://bsky.social"
+
+// MaxPostLength is the most characters, strictly graphemes, that the text
+// of a post may have.
+const MaxPostLength = 300
+
+// MaxBlobSize is the largest image a post or link card may have.
+const MaxBlobSize = 1_000_000
+
+// Session is a signed-in account. Its access token lasts a few minutes,
+// long enough for the calls made straight after signing in.
+type Session struct {
+	DID       string `json:"did"`
+	Handle    string `json:"handle"`
+	AccessJWT string `json:"accessJwt"`
+}
+
+// Error is an error answer to an XRPC call. Name is the error's name, such
+// as AuthenticationRequired or RateLimitExceeded.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Name       string `json:"error"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("atproto: %d %s", e.StatusCode, e.Name)
+	}
+	return fmt.Sprintf("atproto: %d %s: %s", e.StatusCode, e.Name, e.Message)
+}
+
+// Temporary reports whether the call may succeed if made again later: the
+// server failed or asked the client to slow down.
+func (e *Error) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Blob is an uploaded file, as a record refers to it.
+type Blob struct {
+	Type     string  `json:"$type"`
+	Ref      CIDLink `json:"ref"`
+	MimeType string  `json:"mimeType"`
+	Size     int64   `json:"size"`
+}
+
+// CIDLink is a link to content by its CID.
+type CIDLink struct {
+	Link string `json:"$link"`
+}
+
+// Post is an app.bsky.feed.post record.
+type Post struct {
+	Type      string         `json:"$type"`
+	Text      string         `json:"text"`
+	CreatedAt string         `json:"createdAt"`
+	Facets    []Facet        `json:"facets,omitempty"`
+	Embed     *ExternalEmbed `json:"embed,omitempty"`
+}
+
+// NewPost returns a post of text made at t.
+func NewPost(text string, t time.Time) Post {
+	return Post{
+		Type:      "app.bsky.feed.post",
+		Text:      text,
+		CreatedAt: t.UTC().Format(time.RFC3339),
+	}
+}
+
+// LinkURL makes the first occurrence of uri in the post's text a link.
+// Bluesky does not find links in the text by itself.
+func (p *Post) LinkURL(uri string) {
+	start := strings.Index(p.Text, uri)
+	if start < 0 {
+		return
+	}
+	p.Facets = append(p.Facets, Facet{
+		Index:    ByteSlice{ByteStart: start, ByteEnd: start + len(uri)},
+		Features: []Feature{{Type: "app.bsky.richtext.facet#link", URI: uri}},
+	})
+}
+
+// Facet marks up part of a post's text, such as a link.
+type Facet struct {
+	Index    ByteSlice `json:"index"`
+	Features []Feature `json:"features"`
+}
+
+// ByteSlice is a range of a post's text, in bytes of its UTF-8.
+type ByteSlice struct {
+	ByteStart int `json:"byteStart"`
+	ByteEnd   int `json:"byteEnd"`
+}
+
+// Feature is what a facet makes its text. Only links are used.
+type Feature struct {
+	Type string `json:"$type"`
+	URI  string `json:"uri"`
+}
+
+// ExternalEmbed is a link card shown under a post.
+type ExternalEmbed struct {
+	Type     string   `json:"$type"`
+	External External `json:"external"`
+}
+
+// External is the page a link card is for.
+type External struct {
+	URI         string `json:"uri"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Thumb       *Blob  `json:"thumb,omitempty"`
+}
+
+// NewLinkCard returns a link card for the page at uri, with thumb as its
+// image if it is not nil.
+func NewLinkCard(uri, title, description string, thumb *Blob) *ExternalEmbed {
+	return &ExternalEmbed{
+		Type:     "app.bsky.embed.external",
+		External: External{URI: uri, Title: title, Description: description, Thumb: thumb},
+	}
+}
+
+// Client makes XRPC calls to a PDS.
+type Client struct {
+	HTTP    *http.Client
+	Service string // the PDS's address, such as DefaultService
+}
+
+// CreateSession signs in as identifier, a handle or DID, with password,
+// which should be an app password rather than the account's own.
+func (c *Client) CreateSession(ctx context.Context, identifier, password string) (*Session, error) {
+	body, err := json.Marshal(map[string]string{"identifier": identifier, "password": password})
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := c.call(ctx, nil, "com.atproto.server.createSession", "application/json", body, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UploadBlob uploads data, a file of type mimeType, for a record of the
+// session's account to use.
+func (c *Client) UploadBlob(ctx context.Context, s *Session, data []byte, mimeType string) (*Blob, error) {
+	var out struct {
+		Blob Blob `json:"blob"`
+	}
+	if err := c.call(ctx, s, "com.atproto.repo.uploadBlob", mimeType, data, &out); err != nil {
+		return nil, err
+	}
+	return &out.Blob, nil
+}
+
+// CreateRecord adds record to the collection, such as app.bsky.feed.post,
+// of the session's account and returns its at:// URI.
+func (c *Client) CreateRecord(ctx context.Context, s *Session, collection string, record any) (string, error) {
+	body, err := json.Marshal(map[string]any{"repo": s.DID, "collection": collection, "record": record})
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		URI string `json:"uri"`
+	}
+	if err := c.call(ctx, s, "com.atproto.repo.createRecord", "application/json", body, &out); err != nil {
+		return "", err
+	}
+	return out.URI, nil
+}
+
+// call POSTs body, of contentType, to the XRPC procedure method, as the
+// session's account unless s is nil, and decodes the answer into out. An
+// answer other than a 200 is returned as an *Error.
+func (c *Client) call(ctx context.Context, s *Session, method, contentType string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.Service, "/")+"/xrpc/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s != nil {
+		req.Header.Set("Authorization", "Bearer "+s.AccessJWT)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode}
+		json.Unmarshal(data, e)
+		if e.Name == "" {
+			e.Name = http.StatusText(resp.StatusCode)
+		}
+		return e
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,99 @@
+package atproto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLinkURL(t *testing.T) {
+	p := NewPost("Café notes\n\nhttps://blog.example/post/cafe", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	p.LinkURL("https://blog.example/post/cafe")
+	p.LinkURL("https://elsewhere.example/")
+	if len(p.Facets) != 1 {
+		t.Fatalf("expected one facet, got %+v", p.Facets)
+	}
+	// é is two bytes, so the link starts a byte later than its index in
+	// characters.
+	if got := p.Facets[0].Index; got != (ByteSlice{ByteStart: 13, ByteEnd: 43}) {
+		t.Errorf("link facet covers %+v", got)
+	}
+	if p.Text[p.Facets[0].Index.ByteStart:p.Facets[0].Index.ByteEnd] != "https://blog.example/post/cafe" {
+		t.Error("facet does not cover the link")
+	}
+	if p.CreatedAt != "2026-01-02T03:04:05Z" {
+		t.Errorf("createdAt = %q", p.CreatedAt)
+	}
+}
+
+func TestClient(t *testing.T) {
+	var record map[string]any
+	var blobType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/com.atproto.server.createSession" && r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"AuthenticationRequired","message":"Invalid identifier or password"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			var in map[string]string
+			json.NewDecoder(r.Body).Decode(&in)
+			if in["identifier"] != "blog.example" || in["password"] != "app-password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"AuthenticationRequired","message":"Invalid identifier or password"}`))
+				return
+			}
+			w.Write([]byte(`{"did":"did:plc:abc","handle":"blog.example","accessJwt":"access","refreshJwt":"refresh"}`))
+		case "/xrpc/com.atproto.repo.uploadBlob":
+			blobType = r.Header.Get("Content-Type")
+			data, _ := io.ReadAll(r.Body)
+			json.NewEncoder(w).Encode(map[string]any{"blob": Blob{Type: "blob", Ref: CIDLink{Link: "bafk"}, MimeType: blobType, Size: int64(len(data))}})
+		case "/xrpc/com.atproto.repo.createRecord":
+			json.NewDecoder(r.Body).Decode(&record)
+			w.Write([]byte(`{"uri":"at://did:plc:abc/app.bsky.feed.post/1","cid":"bafy"}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+	c := &Client{HTTP: ts.Client(), Service: ts.URL + "/"}
+	ctx := context.Background()
+
+	_, err := c.CreateSession(ctx, "blog.example", "wrong")
+	var xe *Error
+	if !errors.As(err, &xe) || xe.StatusCode != http.StatusUnauthorized || xe.Name != "AuthenticationRequired" || xe.Temporary() {
+		t.Fatalf("wrong password: got %v", err)
+	}
+	s, err := c.CreateSession(ctx, "blog.example", "app-password")
+	if err != nil || s.DID != "did:plc:abc" {
+		t.Fatalf("CreateSession = %+v, %v", s, err)
+	}
+	blob, err := c.UploadBlob(ctx, s, []byte("jpeg"), "image/jpeg")
+	if err != nil || blob.Ref.Link != "bafk" || blob.Size != 4 || blobType != "image/jpeg" {
+		t.Fatalf("UploadBlob = %+v, %v", blob, err)
+	}
+	post := NewPost("Hello", time.Now())
+	post.Embed = NewLinkCard("https://blog.example/post/hello", "Hello", "A post.", blob)
+	uri, err := c.CreateRecord(ctx, s, "app.bsky.feed.post", post)
+	if err != nil || uri != "at://did:plc:abc/app.bsky.feed.post/1" {
+		t.Fatalf("CreateRecord = %q, %v", uri, err)
+	}
+	if record["repo"] != "did:plc:abc" || record["collection"] != "app.bsky.feed.post" {
+		t.Errorf("created %v", record)
+	}
+	embed := record["record"].(map[string]any)["embed"].(map[string]any)
+	if embed["$type"] != "app.bsky.embed.external" || embed["external"].(map[string]any)["thumb"] == nil {
+		t.Errorf("link card = %v", embed)
+	}
+
+	err = c.call(ctx, s, "com.example.broken", "application/json", nil, nil)
+	if !errors.As(err, &xe) || !xe.Temporary() {
+		t.Errorf("a 502 is not temporary: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// sessionCookie is the name of the cookie holding a session token.
+	sessionCookie = "session"
+	// sessionTTL is how long a session lasts after signing in.
+	sessionTTL = 30 * 24 * time.Hour
+	// minPasswordLength is the shortest password SetPassword accepts.
+	minPasswordLength = 10
+)
+
+// adminKey is the context key under which requireAdmin stores the email of
+// a user signed in with a session.
+type adminKey struct{}
+
+// dummyHash is compared against when signing in as an unknown user, so
+// that failing takes as long whether or not the account exists.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// hashToken returns the form a session token is stored in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetPassword sets the password of the user with the given email, adding
+// the user if there is none. It also signs the user out everywhere.
+func (s *Server) SetPassword(ctx context.Context, email, password string) error {
+	email = strings.TrimSpace(email)
+	if !strings.Contains(email, "@") {
+		return errors.New("users are named by email address")
+	}
+	if len(password) < minPasswordLength {
+		return errors.New("passwords must be at least 10 characters long")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	q := dbgen.New(s.DB)
+	if err := q.UpsertUser(ctx, dbgen.UpsertUserParams{Email: email, PasswordHash: string(hash)}); err != nil {
+		return err
+	}
+	user, err := q.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	return q.DeleteUserSessions(ctx, user.ID)
+}
+
+// hasUsers reports whether any user can sign in with a password. Until
+// one can, the admin is left to the exe.dev proxy.
+func (s *Server) hasUsers(ctx context.Context) bool {
+	n, err := dbgen.New(s.DB).CountUsers(ctx)
+	if err != nil {
+		slog.Error("count users", "error", err)
+		// Fail closed: sessions are still checked, the proxy header is not.
+		return true
+	}
+	return n > 0
+}
+
+// sessionUser returns the email of the user whose session cookie r
+// carries, or "" if it carries none that is current.
+func (s *Server) sessionUser(r *http.Request) string {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return ""
+	}
+	q := dbgen.New(s.DB)
+	session, err := q.GetSessionUser(r.Context(), hashToken(c.Value))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("get session", "error", err)
+		}
+		return ""
+	}
+	if session.ExpiresAt.Before(time.Now()) {
+		if err := q.DeleteSession(r.Context(), hashToken(c.Value)); err != nil {
+			slog.Error("delete expired session", "error", err)
+		}
+		return ""
+	}
+	return session.Email
+}
+
+// sessionEmail returns the email of the user signed in to r with a
+// session, or "" if it was let through some other way.
+func sessionEmail(r *http.Request) string {
+	email, _ := r.Context().Value(adminKey{}).(string)
+	return email
+}
+
+// localRedirect returns target if it is a path on this site, and /admin
+// otherwise, so that the login form cannot send people elsewhere.
+func localRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/admin"
+	}
+	return target
+}
+
+func (s *Server) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "login.html", map[string]any{
+		"Redirect":   localRedirect(r.URL.Query().Get("redirect")),
+		"LoginLinks": s.mailConfigured(r.Context()),
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
+	})
+}
+
+func (s *Server) HandleLoginSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	email := strings.TrimSpace(r.PostFormValue("email"))
+	redirect := localRedirect(r.PostFormValue("redirect"))
+
+	q := dbgen.New(s.DB)
+	user, err := q.GetUserByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("get user", "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	known := err == nil
+	hash := dummyHash()
+	if known {
+		hash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(r.PostFormValue("password"))) != nil || !known {
+		s.loginFailed(r)
+		s.renderStatus(w, http.StatusUnauthorized, "login.html", map[string]any{
+			"Email":     email,
+			"Redirect":  redirect,
+			"Error":     "The email address or password is not right.",
+			"CSRFToken": csrfToken(r),
+			"Year":      time.Now().Year(),
+		})
+		return
+	}
+
+	if user.TotpSecret != "" {
+		s.startLoginChallenge(w, r, user.ID, redirect)
+		return
+	}
+	s.startSession(w, r, user.ID, redirect)
+}
+
+// startSession signs the user in and sends them on to redirect.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, userID int64, redirect string) {
+	token := rand.Text()
+	expires := time.Now().Add(sessionTTL)
+	err := dbgen.New(s.DB).CreateSession(r.Context(), dbgen.CreateSessionParams{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: expires.UTC(),
+	})
+	if err != nil {
+		slog.Error("create session", "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	s.loginSucceeded(r)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// HandleLogout ends the session the request carries, if any.
+func (s *Server) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if err := dbgen.New(s.DB).DeleteSession(r.Context(), hashToken(c.Value)); err != nil {
+			slog.Error("delete session", "error", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,93 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// autosaveRequest is the body of an autosave API request: the fields of
+// the edit form that hold most of the writing.
+type autosaveRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Excerpt string `json:"excerpt"`
+}
+
+// HandleAutosaveAPI answers PUT /admin/api/posts/{id}/autosave by storing
+// the editor's unsaved changes to a post, apart from the post itself, so
+// they can be recovered the next time it is edited.
+func (s *Server) HandleAutosaveAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "post not found"})
+		return
+	}
+	var req autosaveRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body"})
+		return
+	}
+
+	q := dbgen.New(s.DB)
+	post, err := q.GetPostByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && post.DeletedAt != nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "post not found"})
+		return
+	}
+	savedAt := time.Now().UTC()
+	if err == nil {
+		err = q.UpsertPostAutosave(r.Context(), dbgen.UpsertPostAutosaveParams{
+			PostID:  id,
+			Title:   req.Title,
+			Content: req.Content,
+			Excerpt: req.Excerpt,
+			SavedAt: savedAt,
+		})
+	}
+	if err != nil {
+		slog.Error("autosave post", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "autosave failed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"saved_at": savedAt})
+}
+
+// HandleAutosaveDiscardAPI answers DELETE /admin/api/posts/{id}/autosave
+// by throwing away the autosaved changes to a post.
+func (s *Server) HandleAutosaveDiscardAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "post not found"})
+		return
+	}
+	if err := dbgen.New(s.DB).DeletePostAutosave(r.Context(), id); err != nil {
+		slog.Error("delete post autosave", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "discard failed"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pendingAutosave returns the autosaved changes to post, or nil if there
+// are none or the post has been updated since they were saved.
+func pendingAutosave(ctx context.Context, q *dbgen.Queries, post dbgen.Post) (*dbgen.PostAutosave, error) {
+	a, err := q.GetPostAutosave(ctx, post.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.SavedAt.Before(post.UpdatedAt) || a.Title == post.Title && a.Content == post.Content && a.Excerpt == post.Excerpt {
+		return nil, nil
+	}
+	return &a, nil
+}
@@ -0,0 +1,340 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// backupVersion is the version of the backup format written by Backup.
+// Restore refuses backups of any other version.
+const backupVersion = 1
+
+// backup is a JSON archive of the blog's content: every post, including
+// those in the trash, with the tags, categories, series, redirects,
+// comments and Webmentions that go with them, the blog's fediverse
+// followers and email subscribers, the messages sent with the contact
+// form, the records of uploaded media and the settings. IDs are kept, so
+// links between rows survive a restore. The media files themselves,
+// accounts, API tokens, post history and the day's salt for visitors are
+// not included.
+type backup struct {
+	Version     int                    `json:"version"`
+	CreatedAt   time.Time              `json:"created_at"`
+	Posts       []dbgen.Post           `json:"posts"`
+	Tags        []dbgen.Tag            `json:"tags"`
+	PostTags    []dbgen.PostTag        `json:"post_tags"`
+	Categories  []dbgen.Category       `json:"categories"`
+	Series      []dbgen.Series         `json:"series"`
+	Redirects   []dbgen.Redirect       `json:"redirects"`
+	Comments    []dbgen.Comment        `json:"comments"`
+	Mentions    []dbgen.Webmention     `json:"webmentions"`
+	Followers   []dbgen.Follower       `json:"followers"`
+	Subscribers []dbgen.Subscriber     `json:"subscribers"`
+	Messages    []dbgen.ContactMessage `json:"contact_messages"`
+	Media       []dbgen.Media          `json:"media"`
+	Settings    []dbgen.GetSettingsRow `json:"settings"`
+}
+
+// Backup writes the blog's content to w as a JSON archive that Restore can
+// rebuild it from.
+func (s *Server) Backup(ctx context.Context, w io.Writer) error {
+	b, err := s.backup(ctx)
+	if err != nil {
+		return err
+	}
+	return writeBackup(w, b)
+}
+
+// backup reads the content a backup holds from the database.
+func (s *Server) backup(ctx context.Context) (backup, error) {
+	q := dbgen.New(s.DB)
+	b := backup{Version: backupVersion, CreatedAt: time.Now().UTC()}
+	var err error
+	if b.Posts, err = q.ListBackupPosts(ctx); err != nil {
+		return b, fmt.Errorf("list posts: %w", err)
+	}
+	if b.Tags, err = q.ListAllTags(ctx); err != nil {
+		return b, fmt.Errorf("list tags: %w", err)
+	}
+	if b.PostTags, err = q.ListAllPostTags(ctx); err != nil {
+		return b, fmt.Errorf("list post tags: %w", err)
+	}
+	if b.Categories, err = q.GetCategories(ctx); err != nil {
+		return b, fmt.Errorf("list categories: %w", err)
+	}
+	if b.Series, err = q.GetAllSeries(ctx); err != nil {
+		return b, fmt.Errorf("list series: %w", err)
+	}
+	if b.Redirects, err = q.GetRedirects(ctx); err != nil {
+		return b, fmt.Errorf("list redirects: %w", err)
+	}
+	if b.Comments, err = q.ListAllComments(ctx); err != nil {
+		return b, fmt.Errorf("list comments: %w", err)
+	}
+	if b.Mentions, err = q.ListAllWebmentions(ctx); err != nil {
+		return b, fmt.Errorf("list webmentions: %w", err)
+	}
+	if b.Followers, err = q.ListAllFollowers(ctx); err != nil {
+		return b, fmt.Errorf("list followers: %w", err)
+	}
+	if b.Subscribers, err = q.ListAllSubscribers(ctx); err != nil {
+		return b, fmt.Errorf("list subscribers: %w", err)
+	}
+	if b.Messages, err = q.ListAllContactMessages(ctx); err != nil {
+		return b, fmt.Errorf("list contact messages: %w", err)
+	}
+	if b.Media, err = q.ListAllMedia(ctx); err != nil {
+		return b, fmt.Errorf("list media: %w", err)
+	}
+	if b.Settings, err = q.GetSettings(ctx); err != nil {
+		return b, fmt.Errorf("list settings: %w", err)
+	}
+	b.Settings = slices.DeleteFunc(b.Settings, func(st dbgen.GetSettingsRow) bool {
+		return st.Key == settingVisitorSalt
+	})
+	return b, nil
+}
+
+// writeBackup writes b to w as indented JSON.
+func writeBackup(w io.Writer, b backup) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// Restore rebuilds the blog's content from a backup written by Backup. It
+// only restores into a database without posts, tags, categories, series
+// or media, so nothing is overwritten, and either all of the backup is
+// restored or none of it.
+func (s *Server) Restore(ctx context.Context, r io.Reader) error {
+	var b backup
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	if b.Version != backupVersion {
+		return fmt.Errorf("backup is version %d; only version %d can be restored", b.Version, backupVersion)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	counts, err := q.CountRestoreTargets(ctx)
+	if err != nil {
+		return err
+	}
+	if counts != (dbgen.CountRestoreTargetsRow{}) {
+		return errors.New("the database already has content; restore into a new one")
+	}
+
+	for _, c := range b.Categories {
+		err := q.RestoreCategory(ctx, dbgen.RestoreCategoryParams{
+			ID:          c.ID,
+			Slug:        c.Slug,
+			Name:        c.Name,
+			Description: c.Description,
+			CreatedAt:   dbTime(c.CreatedAt),
+		})
+		if err != nil {
+			return fmt.Errorf("restore category %s: %w", c.Slug, err)
+		}
+	}
+	for _, sr := range b.Series {
+		err := q.RestoreSeries(ctx, dbgen.RestoreSeriesParams{
+			ID:          sr.ID,
+			Slug:        sr.Slug,
+			Title:       sr.Title,
+			Description: sr.Description,
+			CreatedAt:   dbTime(sr.CreatedAt),
+		})
+		if err != nil {
+			return fmt.Errorf("restore series %s: %w", sr.Slug, err)
+		}
+	}
+	for _, p := range b.Posts {
+		params := dbgen.RestoreBackupPostParams{
+			ID:              p.ID,
+			Slug:            p.Slug,
+			Title:           p.Title,
+			Content:         p.Content,
+			Published:       p.Published,
+			CreatedAt:       dbTime(p.CreatedAt),
+			UpdatedAt:       dbTime(p.UpdatedAt),
+			AllowHtml:       p.AllowHtml,
+			MetaDescription: p.MetaDescription,
+			OgImage:         p.OgImage,
+			CoverImage:      p.CoverImage,
+			SeriesID:        p.SeriesID,
+			SeriesOrder:     p.SeriesOrder,
+			CategoryID:      p.CategoryID,
+			PublishAt:       p.PublishAt,
+			Excerpt:         p.Excerpt,
+			CommentsClosed:  p.CommentsClosed,
+			NoCrosspost:     p.NoCrosspost,
+		}
+		if p.DeletedAt != nil {
+			deleted := dbTime(*p.DeletedAt)
+			params.DeletedAt = &deleted
+		}
+		if err := q.RestoreBackupPost(ctx, params); err != nil {
+			return fmt.Errorf("restore post %s: %w", p.Slug, err)
+		}
+	}
+	for _, t := range b.Tags {
+		if err := q.RestoreTag(ctx, dbgen.RestoreTagParams{ID: t.ID, Name: t.Name, Slug: t.Slug}); err != nil {
+			return fmt.Errorf("restore tag %s: %w", t.Slug, err)
+		}
+	}
+	for _, pt := range b.PostTags {
+		if err := q.RestorePostTag(ctx, dbgen.RestorePostTagParams{PostID: pt.PostID, TagID: pt.TagID}); err != nil {
+			return fmt.Errorf("restore tag %d of post %d: %w", pt.TagID, pt.PostID, err)
+		}
+	}
+	for _, rd := range b.Redirects {
+		err := q.RestoreRedirect(ctx, dbgen.RestoreRedirectParams{
+			OldSlug:   rd.OldSlug,
+			NewSlug:   rd.NewSlug,
+			CreatedAt: dbTime(rd.CreatedAt),
+		})
+		if err != nil {
+			return fmt.Errorf("restore redirect %s: %w", rd.OldSlug, err)
+		}
+	}
+	for _, c := range b.Comments {
+		err := q.RestoreComment(ctx, dbgen.RestoreCommentParams{
+			ID:              c.ID,
+			PostID:          c.PostID,
+			ParentCommentID: c.ParentCommentID,
+			AuthorName:      c.AuthorName,
+			AuthorEmail:     c.AuthorEmail,
+			AuthorUrl:       c.AuthorUrl,
+			Body:            c.Body,
+			Status:          c.Status,
+			Ip:              c.Ip,
+			CreatedAt:       dbTime(c.CreatedAt),
+			UpdatedAt:       dbTime(c.UpdatedAt),
+		})
+		if err != nil {
+			return fmt.Errorf("restore comment %d: %w", c.ID, err)
+		}
+	}
+	for _, m := range b.Mentions {
+		params := dbgen.RestoreWebmentionParams{
+			ID:         m.ID,
+			PostID:     m.PostID,
+			Source:     m.Source,
+			Target:     m.Target,
+			Status:     m.Status,
+			Title:      m.Title,
+			NeedsCheck: m.NeedsCheck,
+			CreatedAt:  dbTime(m.CreatedAt),
+			UpdatedAt:  dbTime(m.UpdatedAt),
+		}
+		if m.VerifiedAt != nil {
+			verified := dbTime(*m.VerifiedAt)
+			params.VerifiedAt = &verified
+		}
+		if err := q.RestoreWebmention(ctx, params); err != nil {
+			return fmt.Errorf("restore webmention %s: %w", m.Source, err)
+		}
+	}
+	for _, f := range b.Followers {
+		err := q.RestoreFollower(ctx, dbgen.RestoreFollowerParams{
+			ID:          f.ID,
+			Actor:       f.Actor,
+			Inbox:       f.Inbox,
+			SharedInbox: f.SharedInbox,
+			CreatedAt:   dbTime(f.CreatedAt),
+		})
+		if err != nil {
+			return fmt.Errorf("restore follower %s: %w", f.Actor, err)
+		}
+	}
+	for _, sub := range b.Subscribers {
+		params := dbgen.RestoreSubscriberParams{
+			ID:        sub.ID,
+			Email:     sub.Email,
+			Token:     sub.Token,
+			CreatedAt: dbTime(sub.CreatedAt),
+		}
+		if sub.ConfirmedAt != nil {
+			confirmed := dbTime(*sub.ConfirmedAt)
+			params.ConfirmedAt = &confirmed
+		}
+		if err := q.RestoreSubscriber(ctx, params); err != nil {
+			return fmt.Errorf("restore subscriber %d: %w", sub.ID, err)
+		}
+	}
+	for _, m := range b.Messages {
+		params := dbgen.RestoreContactMessageParams{
+			ID:        m.ID,
+			Name:      m.Name,
+			Email:     m.Email,
+			Subject:   m.Subject,
+			Body:      m.Body,
+			Sender:    m.Sender,
+			CreatedAt: dbTime(m.CreatedAt),
+		}
+		if m.ReadAt != nil {
+			read := dbTime(*m.ReadAt)
+			params.ReadAt = &read
+		}
+		if err := q.RestoreContactMessage(ctx, params); err != nil {
+			return fmt.Errorf("restore contact message %d: %w", m.ID, err)
+		}
+	}
+	for _, m := range b.Media {
+		err := q.RestoreMedia(ctx, dbgen.RestoreMediaParams{
+			ID:           m.ID,
+			Filename:     m.Filename,
+			OriginalName: m.OriginalName,
+			ContentType:  m.ContentType,
+			Size:         m.Size,
+			Width:        m.Width,
+			Height:       m.Height,
+			CreatedAt:    dbTime(m.CreatedAt),
+			Sha256:       m.Sha256,
+		})
+		if err != nil {
+			return fmt.Errorf("restore media %s: %w", m.Filename, err)
+		}
+	}
+	for _, st := range b.Settings {
+		if err := q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: st.Key, Value: st.Value}); err != nil {
+			return fmt.Errorf("restore setting %s: %w", st.Key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// dbTime formats t as SQLite's CURRENT_TIMESTAMP does, so that restored
+// times compare and sort with the ones the database writes itself.
+func dbTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+// HandleAdminExport downloads a backup of the blog as Backup writes it.
+func (s *Server) HandleAdminExport(w http.ResponseWriter, r *http.Request) {
+	b, err := s.backup(r.Context())
+	if err != nil {
+		slog.Error("backup", "error", err)
+		http.Error(w, "Failed to back up", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="backup-%s.json"`, time.Now().Format(time.DateOnly)))
+	if err := writeBackup(w, b); err != nil {
+		slog.Error("write backup", "error", err)
+	}
+}
@@ -0,0 +1,291 @@
+package srv
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// freeLoginFailures is how many failed sign-ins from an address are
+	// allowed before it is locked out.
+	freeLoginFailures = 5
+	// firstLockout is how long the first lockout lasts. Each failure after
+	// it doubles the next one, up to maxLockout.
+	firstLockout = time.Minute
+	maxLockout   = 24 * time.Hour
+	// failureMemory is how long failures are remembered after the last.
+	failureMemory = 24 * time.Hour
+)
+
+// clientIP returns the address r came from. Behind a proxy on the same
+// machine that is the last one the proxy added to X-Forwarded-For, as
+// earlier ones can be made up by the client.
+func clientIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() {
+		if hops := r.Header.Values("X-Forwarded-For"); len(hops) > 0 {
+			last := hops[len(hops)-1]
+			last = strings.TrimSpace(last[strings.LastIndex(last, ",")+1:])
+			if forwarded, err := netip.ParseAddr(last); err == nil {
+				addr = forwarded.Unmap()
+			}
+		}
+	}
+	return addr
+}
+
+// throttleKey returns what failed sign-ins from r are counted against:
+// its address, or for IPv6 its /64, since one machine usually has a
+// whole /64 to choose addresses from.
+func throttleKey(r *http.Request) string {
+	addr := clientIP(r)
+	if addr.Is6() {
+		prefix, _ := addr.Prefix(64)
+		return prefix.String()
+	}
+	return addr.String()
+}
+
+// parseBan returns the network a ban given as an address or in CIDR
+// notation covers.
+func parseBan(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// banned reports whether r comes from a banned address.
+func (s *Server) banned(r *http.Request) bool {
+	addr := clientIP(r)
+	if !addr.IsValid() {
+		return false
+	}
+	bans, err := dbgen.New(s.DB).ListIPBans(r.Context())
+	if err != nil {
+		slog.Error("list IP bans", "error", err)
+		return false
+	}
+	for _, ban := range bans {
+		if prefix, err := netip.ParsePrefix(ban.Prefix); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// refuseBanned turns away banned addresses before anything else is done
+// for them.
+func (s *Server) refuseBanned(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.banned(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// throttleLogin turns away attempts to sign in from banned addresses and
+// from those locked out after too many failures.
+func (s *Server) throttleLogin(next http.HandlerFunc) http.HandlerFunc {
+	return s.refuseBanned(func(w http.ResponseWriter, r *http.Request) {
+		wait := s.lockedOut(r)
+		if wait <= 0 {
+			next(w, r)
+			return
+		}
+		minutes := int(wait.Round(time.Minute) / time.Minute)
+		if minutes < 1 {
+			minutes = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		s.renderStatus(w, http.StatusTooManyRequests, "login.html", map[string]any{
+			"Error":     fmt.Sprintf("Too many failed attempts to sign in. Try again in %d minutes.", minutes),
+			"Redirect":  "/admin",
+			"CSRFToken": csrfToken(r),
+			"Year":      time.Now().Year(),
+		})
+	})
+}
+
+// lockedOut returns how long r's address is still locked out for.
+func (s *Server) lockedOut(r *http.Request) time.Duration {
+	failure, err := dbgen.New(s.DB).GetLoginFailure(r.Context(), throttleKey(r))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("get login failures", "error", err)
+		}
+		return 0
+	}
+	if failure.LockedUntil == nil {
+		return 0
+	}
+	return time.Until(*failure.LockedUntil)
+}
+
+// lockoutAfter returns how long an address is locked out for after its
+// nth failure in a row.
+func lockoutAfter(n int64) time.Duration {
+	if n < freeLoginFailures {
+		return 0
+	}
+	lockout := firstLockout
+	for i := int64(freeLoginFailures); i < n && lockout < maxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, maxLockout)
+}
+
+// loginFailed counts a failed attempt to sign in from r's address, and
+// locks it out if there have been too many.
+func (s *Server) loginFailed(r *http.Request) {
+	q := dbgen.New(s.DB)
+	key := throttleKey(r)
+	now := time.Now()
+	var failures int64
+	failure, err := q.GetLoginFailure(r.Context(), key)
+	if err == nil && now.Sub(failure.LastFailureAt) < failureMemory {
+		failures = failure.Failures
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("get login failures", "error", err)
+	}
+	failures++
+	params := dbgen.UpsertLoginFailureParams{Ip: key, Failures: failures, LastFailureAt: now.UTC()}
+	if lockout := lockoutAfter(failures); lockout > 0 {
+		until := now.Add(lockout).UTC()
+		params.LockedUntil = &until
+		slog.Warn("locking out address after failed sign-ins", "ip", key, "failures", failures, "for", lockout)
+	}
+	if err := q.UpsertLoginFailure(r.Context(), params); err != nil {
+		slog.Error("record login failure", "error", err)
+	}
+}
+
+// loginSucceeded forgets the failed attempts from r's address.
+func (s *Server) loginSucceeded(r *http.Request) {
+	if err := dbgen.New(s.DB).DeleteLoginFailure(r.Context(), throttleKey(r)); err != nil {
+		slog.Error("clear login failures", "error", err)
+	}
+}
+
+// LockoutView is an address with recent failed sign-ins, for the admin.
+type LockoutView struct {
+	IP            string
+	Failures      int64
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+	Locked        bool
+}
+
+func (s *Server) HandleAdminBans(w http.ResponseWriter, r *http.Request) {
+	s.renderBans(w, r, "")
+}
+
+func (s *Server) renderBans(w http.ResponseWriter, r *http.Request, errMsg string) {
+	q := dbgen.New(s.DB)
+	bans, err := q.ListIPBans(r.Context())
+	if err != nil {
+		slog.Error("list IP bans", "error", err)
+	}
+	failures, err := q.ListLoginFailures(r.Context())
+	if err != nil {
+		slog.Error("list login failures", "error", err)
+	}
+	now := time.Now()
+	var lockouts []LockoutView
+	for _, f := range failures {
+		if now.Sub(f.LastFailureAt) >= failureMemory {
+			continue
+		}
+		v := LockoutView{IP: f.Ip, Failures: f.Failures, LastFailureAt: f.LastFailureAt}
+		if f.LockedUntil != nil && f.LockedUntil.After(now) {
+			v.LockedUntil = *f.LockedUntil
+			v.Locked = true
+		}
+		lockouts = append(lockouts, v)
+	}
+	s.render(w, "admin_bans.html", map[string]any{
+		"Bans":      bans,
+		"Lockouts":  lockouts,
+		"ClientIP":  clientIP(r).String(),
+		"Error":     errMsg,
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+func (s *Server) HandleAdminBanCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	prefix, err := parseBan(r.FormValue("prefix"))
+	if err != nil {
+		s.renderBans(w, r, "Enter an address, such as 203.0.113.7, or a network, such as 203.0.113.0/24")
+		return
+	}
+	if prefix.Contains(clientIP(r)) {
+		s.renderBans(w, r, "That would ban your own address")
+		return
+	}
+	err = dbgen.New(s.DB).CreateIPBan(r.Context(), dbgen.CreateIPBanParams{
+		Prefix: prefix.String(),
+		Reason: strings.TrimSpace(r.FormValue("reason")),
+	})
+	if err != nil {
+		slog.Error("create IP ban", "error", err)
+		s.renderBans(w, r, "Failed to add ban: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/admin/bans", http.StatusFound)
+}
+
+func (s *Server) HandleAdminBanDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := dbgen.New(s.DB).DeleteIPBan(r.Context(), id); err != nil {
+		slog.Error("delete IP ban", "error", err)
+	}
+	http.Redirect(w, r, "/admin/bans", http.StatusFound)
+}
+
+// HandleAdminUnlock lets an address locked out after failed sign-ins try
+// again straight away.
+func (s *Server) HandleAdminUnlock(w http.ResponseWriter, r *http.Request) {
+	if err := dbgen.New(s.DB).DeleteLoginFailure(r.Context(), r.PostFormValue("ip")); err != nil {
+		slog.Error("clear login failures", "error", err)
+	}
+	http.Redirect(w, r, "/admin/bans", http.StatusFound)
+}
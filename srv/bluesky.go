@@ -0,0 +1,197 @@
+package srv
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/atproto"
+)
+
+// blueskyInterval is how often the server looks for posts due to be tried
+// on Bluesky again.
+const blueskyInterval = time.Minute
+
+// blueskyRetries are the waits before each retry of a post that Bluesky
+// could not take for the moment. Once they are used up, it is given up on.
+var blueskyRetries = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// errNotOnSite is the error recorded for a post queued for Bluesky that
+// was taken down before it could be posted.
+var errNotOnSite = errors.New("the post is no longer on the site")
+
+// blueskyAccount returns the handle and app password of the Bluesky
+// account to post to, and whether one is set up.
+func (s *Server) blueskyAccount(ctx context.Context) (handle, password string, ok bool) {
+	handle, password = s.setting(ctx, settingBlueskyHandle), s.setting(ctx, settingBlueskyPassword)
+	return handle, password, handle != "" && password != ""
+}
+
+// queueBlueskyPost queues p to be posted to the Bluesky account, unless
+// there is none or sharing was turned off for p.
+func (s *Server) queueBlueskyPost(ctx context.Context, base string, p dbgen.Post) error {
+	if _, _, ok := s.blueskyAccount(ctx); !ok || p.NoCrosspost == 1 {
+		return nil
+	}
+	now := time.Now().UTC()
+	if err := dbgen.New(s.DB).QueueBlueskyPost(ctx, dbgen.QueueBlueskyPostParams{PostID: p.ID, NextAttemptAt: &now}); err != nil {
+		return err
+	}
+	s.requestBluesky()
+	return nil
+}
+
+// blueskyLoop posts queued posts to Bluesky every blueskyInterval and
+// whenever one is queued.
+func (s *Server) blueskyLoop(ctx context.Context) {
+	ticker := time.NewTicker(blueskyInterval)
+	defer ticker.Stop()
+	for {
+		s.postToBluesky(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.bluesky:
+		}
+	}
+}
+
+// requestBluesky wakes blueskyLoop without waiting for it.
+func (s *Server) requestBluesky() {
+	select {
+	case s.bluesky <- struct{}{}:
+	default:
+	}
+}
+
+// postToBluesky posts the queued posts that are due to the Bluesky
+// account. When Bluesky fails or asks to slow down, or cannot be reached,
+// a post is tried again after the next of blueskyRetries; any other
+// refusal, such as a wrong app password, is final. Nothing is posted
+// while the site URL or the account is unset.
+func (s *Server) postToBluesky(ctx context.Context) {
+	base := s.setting(ctx, settingSiteURL)
+	handle, password, ok := s.blueskyAccount(ctx)
+	if base == "" || !ok {
+		return
+	}
+	q := dbgen.New(s.DB)
+	pending, err := q.ListPendingBlueskyPosts(ctx)
+	if err != nil {
+		slog.Error("list pending bluesky posts", "error", err)
+		return
+	}
+	var due []dbgen.BlueskyPost
+	for _, bp := range pending {
+		if !bp.NextAttemptAt.After(time.Now()) {
+			due = append(due, bp)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	client := &atproto.Client{HTTP: publishClient, Service: cmp.Or(s.setting(ctx, settingBlueskyService), atproto.DefaultService)}
+	session, sessionErr := client.CreateSession(ctx, handle, password)
+	for _, bp := range due {
+		var uri string
+		err := sessionErr
+		if err == nil {
+			uri, err = s.sendToBluesky(ctx, client, session, base, bp.PostID)
+		}
+		now := time.Now().UTC()
+		params := dbgen.RecordBlueskyAttemptParams{Uri: uri, PostID: bp.PostID}
+		if err == nil {
+			params.PostedAt = &now
+		} else {
+			slog.Warn("post to bluesky", "post", bp.PostID, "attempt", bp.Attempts+1, "error", err)
+			params.Error = err.Error()
+			var xe *atproto.Error
+			temporary := !errors.Is(err, errNotOnSite) && (!errors.As(err, &xe) || xe.Temporary())
+			if temporary && int(bp.Attempts) < len(blueskyRetries) {
+				next := now.Add(blueskyRetries[bp.Attempts])
+				params.NextAttemptAt = &next
+			}
+		}
+		if err := q.RecordBlueskyAttempt(ctx, params); err != nil {
+			slog.Error("record bluesky attempt", "error", err)
+		}
+	}
+}
+
+// sendToBluesky posts the post with the given ID as session's account: its
+// title and link, with a link card showing its description and image.
+// It returns the at:// URI of the Bluesky post.
+func (s *Server) sendToBluesky(ctx context.Context, client *atproto.Client, session *atproto.Session, base string, id int64) (string, error) {
+	p, err := dbgen.New(s.DB).GetPostByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if !onSite(&p) {
+		return "", errNotOnSite
+	}
+	link := base + "/post/" + p.Slug
+	post := atproto.NewPost(blueskyText(p.Title, link), time.Now())
+	post.LinkURL(link)
+	rp := s.renderPost(ctx, p)
+	description := cmp.Or(p.MetaDescription, p.Excerpt, rp.summary)
+	image := cmp.Or(p.OgImage, p.CoverImage, rp.image, s.setting(ctx, settingDefaultOGImage))
+	post.Embed = atproto.NewLinkCard(link, p.Title, description, s.blueskyThumb(ctx, client, session, base, image))
+	return client.CreateRecord(ctx, session, "app.bsky.feed.post", post)
+}
+
+// blueskyText returns the text of the Bluesky post sharing a post: its
+// title, shortened if need be to fit atproto.MaxPostLength, and its link.
+func blueskyText(title, link string) string {
+	room := atproto.MaxPostLength - utf8.RuneCountInString(link) - 2
+	// excerpt may add "..." to what it keeps.
+	if utf8.RuneCountInString(title) > room {
+		title = excerpt(title, room-3)
+	}
+	return title + "\n\n" + link
+}
+
+// blueskyThumb uploads image, the post's preview image, for its link card
+// and returns the blob, or nil if there is to be no image. Only images
+// uploaded to the site are used, cropped to a card's shape; an image
+// elsewhere, or one that fails to upload, leaves the card without one.
+func (s *Server) blueskyThumb(ctx context.Context, client *atproto.Client, session *atproto.Session, base, image string) *atproto.Blob {
+	name, ok := strings.CutPrefix(strings.TrimPrefix(image, base), "/media/")
+	if !ok {
+		return nil
+	}
+	name, _, _ = strings.Cut(name, "?")
+	m, err := dbgen.New(s.DB).GetMediaByFilename(ctx, name)
+	if err != nil {
+		return nil
+	}
+	store := s.mediaStore(ctx)
+	key, err := s.mediaVariant(ctx, store, m, 1200, 630)
+	if err != nil {
+		slog.Warn("make bluesky thumbnail", "file", m.Filename, "error", err)
+		return nil
+	}
+	rc, err := store.Open(ctx, key)
+	if err != nil {
+		slog.Warn("open bluesky thumbnail", "key", key, "error", err)
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, atproto.MaxBlobSize+1))
+	rc.Close()
+	if err != nil || len(data) > atproto.MaxBlobSize {
+		return nil
+	}
+	blob, err := client.UploadBlob(ctx, session, data, m.ContentType)
+	if err != nil {
+		slog.Warn("upload bluesky thumbnail", "file", m.Filename, "error", err)
+		return nil
+	}
+	return blob
+}
@@ -0,0 +1,237 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// botPageRate is the most pages a visitor can view in botPaceWindow
+	// before they are taken for a bot for the rest of the day.
+	botPageRate   = 30
+	botPaceWindow = time.Minute
+)
+
+// The reasons a request is taken for a bot's other than its user agent
+// matching a pattern, which is its own reason.
+const (
+	botNoUserAgent = "no user agent"
+	botNoLanguage  = "no Accept-Language header"
+	botRobots      = "asked for robots.txt"
+	botTooFast     = "too many pages a minute"
+)
+
+// botHeuristics are the reasons other than patterns, in the order the
+// admin lists them.
+var botHeuristics = []string{botNoUserAgent, botNoLanguage, botRobots, botTooFast}
+
+// botKey is the context key track stores why a request was taken for a
+// bot's under, "" if it was not.
+type botKey struct{}
+
+// pace is how fast a visitor is going through the site today, and why
+// they were taken for a bot, if they were.
+type pace struct {
+	start time.Time // when the current botPaceWindow began
+	pages int       // pages asked for in it
+	bot   string
+}
+
+// BotPatternView is a pattern in the admin, with the requests it has
+// matched since the server started.
+type BotPatternView struct {
+	dbgen.BotPattern
+	Matches int64
+}
+
+// BotHeuristicView is a reason other than a pattern for taking a request
+// for a bot's, with the requests it has matched since the server started.
+type BotHeuristicView struct {
+	Reason  string
+	Matches int64
+}
+
+// isBot reports whether track took r for a bot's. Requests that did not
+// come through track are not.
+func isBot(r *http.Request) bool {
+	reason, _ := r.Context().Value(botKey{}).(string)
+	return reason != ""
+}
+
+// withBot returns r carrying why it was taken for a bot's, for isBot.
+func withBot(r *http.Request, reason string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), botKey{}, reason))
+}
+
+// classifyBot returns why r is taken for a bot's, or "" if it is not: its
+// user agent matches one of the bot patterns, it has no user agent or no
+// Accept-Language, which browsers always send, or its sender asked for
+// robots.txt today or is going through pages faster than anyone reads.
+// Each call counts as a page asked for by r's sender.
+func (s *Server) classifyBot(r *http.Request) string {
+	reason := s.botReason(r)
+	if reason != "" {
+		s.botMu.Lock()
+		if s.botMatches == nil {
+			s.botMatches = make(map[string]int64)
+		}
+		s.botMatches[reason]++
+		s.botMu.Unlock()
+	}
+	return reason
+}
+
+// botReason is classifyBot without the counting of matches.
+func (s *Server) botReason(r *http.Request) string {
+	ua := strings.TrimSpace(r.UserAgent())
+	if ua == "" {
+		return botNoUserAgent
+	}
+	patterns, err := dbgen.New(s.DB).ListBotPatterns(r.Context())
+	if err != nil {
+		slog.Error("list bot patterns", "error", err)
+	}
+	lower := strings.ToLower(ua)
+	for _, p := range patterns {
+		if strings.Contains(lower, strings.ToLower(p.Pattern)) {
+			return p.Pattern
+		}
+	}
+	if r.Header.Get("Accept-Language") == "" {
+		return botNoLanguage
+	}
+
+	k, err := s.visitor(r)
+	if err != nil {
+		slog.Error("load visitor salt", "error", err)
+		return ""
+	}
+	now := time.Now()
+	s.botMu.Lock()
+	defer s.botMu.Unlock()
+	p := s.pace(k)
+	if p.bot != "" {
+		return p.bot
+	}
+	if now.Sub(p.start) >= botPaceWindow {
+		p.start, p.pages = now, 0
+	}
+	p.pages++
+	if p.pages > botPageRate {
+		p.bot = botTooFast
+	}
+	return p.bot
+}
+
+// pace returns how fast the visitor k is going today, forgetting every
+// visitor of an earlier day. s.botMu must be held.
+func (s *Server) pace(k visitorKey) *pace {
+	if s.paceDay != k.day {
+		s.paces = make(map[string]*pace)
+		s.paceDay = k.day
+	}
+	p := s.paces[k.visitor]
+	if p == nil {
+		p = &pace{start: time.Now()}
+		s.paces[k.visitor] = p
+	}
+	return p
+}
+
+// markBot takes r's sender for a bot for the rest of the day, for reason.
+func (s *Server) markBot(r *http.Request, reason string) {
+	k, err := s.visitor(r)
+	if err != nil {
+		slog.Error("load visitor salt", "error", err)
+		return
+	}
+	s.botMu.Lock()
+	defer s.botMu.Unlock()
+	if p := s.pace(k); p.bot == "" {
+		p.bot = reason
+	}
+}
+
+// botMatchCounts returns how many requests have been taken for bots' for
+// each reason since the server started.
+func (s *Server) botMatchCounts() map[string]int64 {
+	s.botMu.Lock()
+	defer s.botMu.Unlock()
+	counts := make(map[string]int64, len(s.botMatches))
+	for reason, n := range s.botMatches {
+		counts[reason] = n
+	}
+	return counts
+}
+
+func (s *Server) HandleAdminBots(w http.ResponseWriter, r *http.Request) {
+	s.renderBots(w, r, "")
+}
+
+func (s *Server) renderBots(w http.ResponseWriter, r *http.Request, errMsg string) {
+	patterns, err := dbgen.New(s.DB).ListBotPatterns(r.Context())
+	if err != nil {
+		slog.Error("list bot patterns", "error", err)
+	}
+	matches := s.botMatchCounts()
+	views := make([]BotPatternView, len(patterns))
+	for i, p := range patterns {
+		views[i] = BotPatternView{BotPattern: p, Matches: matches[p.Pattern]}
+	}
+	heuristics := make([]BotHeuristicView, len(botHeuristics))
+	for i, reason := range botHeuristics {
+		heuristics[i] = BotHeuristicView{Reason: reason, Matches: matches[reason]}
+	}
+	s.render(w, "admin_bots.html", map[string]any{
+		"Patterns":   views,
+		"Heuristics": heuristics,
+		"UserAgent":  r.UserAgent(),
+		"Error":      errMsg,
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
+	})
+}
+
+func (s *Server) HandleAdminBotCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	pattern := strings.TrimSpace(r.FormValue("pattern"))
+	if len(pattern) < 3 {
+		s.renderBots(w, r, "Enter at least three characters of a user agent, such as Googlebot")
+		return
+	}
+	if strings.Contains(strings.ToLower(r.UserAgent()), strings.ToLower(pattern)) {
+		s.renderBots(w, r, "That would match your own browser")
+		return
+	}
+	err := dbgen.New(s.DB).CreateBotPattern(r.Context(), dbgen.CreateBotPatternParams{
+		Pattern: pattern,
+		Note:    strings.TrimSpace(r.FormValue("note")),
+	})
+	if err != nil {
+		slog.Error("create bot pattern", "error", err)
+		s.renderBots(w, r, "Failed to add pattern: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/admin/bots", http.StatusFound)
+}
+
+func (s *Server) HandleAdminBotDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := dbgen.New(s.DB).DeleteBotPattern(r.Context(), id); err != nil {
+		slog.Error("delete bot pattern", "error", err)
+	}
+	http.Redirect(w, r, "/admin/bots", http.StatusFound)
+}
@@ -0,0 +1,107 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/tags"
+)
+
+// HandleAdminBulk applies one action to the posts selected in the admin
+// post list: "publish", "unpublish", "delete" (to the trash) or "tag",
+// which adds the comma-separated tags in the tags field. Either every
+// selected post is changed or, on an error, none is.
+func (s *Server) HandleAdminBulk(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	var ids []int64
+	for _, v := range r.PostForm["id"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Bad post ID "+v, http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	action := r.PostFormValue("action")
+	names := tags.Parse(r.PostFormValue("tags"))
+	switch action {
+	case "publish", "unpublish", "delete":
+	case "tag":
+		if len(names) == 0 {
+			http.Error(w, "No tags given", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Unknown action "+action, http.StatusBadRequest)
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.Error("begin bulk action", "error", err)
+		http.Error(w, "Failed to update", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	var before []dbgen.Post
+	for _, id := range ids {
+		var post dbgen.Post
+		if post, err = bulkApply(r.Context(), q, action, id, names); err != nil {
+			break
+		}
+		before = append(before, post)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("bulk action", "action", action, "error", err)
+		http.Error(w, "Failed to update", http.StatusInternalServerError)
+		return
+	}
+	for _, post := range before {
+		s.renders.remove(post.ID)
+		s.postChanged(r.Context(), &post, post.ID)
+	}
+	if action == "publish" && len(ids) > 0 {
+		s.requestAnnounce()
+	}
+
+	http.Redirect(w, r, "/admin/posts", http.StatusFound)
+}
+
+// bulkApply applies a bulk action to one post, returning the post as it
+// was before. It returns sql.ErrNoRows if there is no such post.
+func bulkApply(ctx context.Context, q *dbgen.Queries, action string, id int64, names []string) (dbgen.Post, error) {
+	post, err := q.GetPostByID(ctx, id)
+	if err != nil {
+		return post, err
+	}
+	switch action {
+	case "publish":
+		err = q.SetPostPublished(ctx, dbgen.SetPostPublishedParams{Published: 1, ID: id})
+	case "unpublish":
+		err = q.SetPostPublished(ctx, dbgen.SetPostPublishedParams{Published: 0, ID: id})
+	case "delete":
+		err = q.TrashPost(ctx, id)
+	case "tag":
+		err = tags.Add(ctx, q, id, names)
+	default:
+		err = fmt.Errorf("unknown action %q", action)
+	}
+	return post, err
+}
@@ -0,0 +1,118 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// categoryPageSize is the number of posts on each page of a category.
+const categoryPageSize = 20
+
+// postCategory returns the category p is in, or nil if it has none.
+func (s *Server) postCategory(ctx context.Context, p dbgen.Post) *dbgen.Category {
+	if p.CategoryID == nil {
+		return nil
+	}
+	category, err := dbgen.New(s.DB).GetCategoryByID(ctx, *p.CategoryID)
+	if err != nil {
+		slog.Error("get category", "error", err)
+		return nil
+	}
+	return &category
+}
+
+func (s *Server) HandleCategory(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	category, err := q.GetCategoryBySlug(r.Context(), r.PathValue("slug"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	total, err := q.CountCategoryPosts(r.Context(), &category.ID)
+	if err != nil {
+		slog.Error("count category posts", "error", err)
+	}
+	page := pageNumber(r)
+	dbPosts, err := q.GetCategoryPosts(r.Context(), dbgen.GetCategoryPostsParams{
+		CategoryID: &category.ID,
+		Limit:      categoryPageSize,
+		Offset:     int64(page-1) * categoryPageSize,
+	})
+	if err != nil {
+		slog.Error("get category posts", "error", err)
+	}
+
+	posts := make([]PostView, 0, len(dbPosts))
+	for _, p := range dbPosts {
+		posts = append(posts, previewView(p))
+	}
+
+	s.render(w, "base.html", map[string]any{
+		"Category":   category,
+		"Posts":      posts,
+		"Pagination": paginate(page, categoryPageSize, total),
+		"Year":       time.Now().Year(),
+		"Page":       "category",
+	})
+}
+
+func (s *Server) HandleAdminCategories(w http.ResponseWriter, r *http.Request) {
+	s.renderAdminCategories(w, r, "")
+}
+
+func (s *Server) renderAdminCategories(w http.ResponseWriter, r *http.Request, errMsg string) {
+	categories, err := dbgen.New(s.DB).GetCategories(r.Context())
+	if err != nil {
+		slog.Error("get categories", "error", err)
+	}
+	s.render(w, "admin_categories.html", map[string]any{
+		"Categories": categories,
+		"Error":      errMsg,
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
+	})
+}
+
+func (s *Server) HandleAdminCategoryCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || !validSlug(slug) {
+		s.renderAdminCategories(w, r, "A name and a slug of lowercase letters, digits and hyphens are required")
+		return
+	}
+
+	_, err := dbgen.New(s.DB).CreateCategory(r.Context(), dbgen.CreateCategoryParams{
+		Slug:        slug,
+		Name:        name,
+		Description: strings.TrimSpace(r.FormValue("description")),
+	})
+	if err != nil {
+		slog.Error("create category", "error", err)
+		s.renderAdminCategories(w, r, "Failed to create category: "+err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/admin/categories", http.StatusFound)
+}
+
+func (s *Server) HandleAdminCategoryDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := dbgen.New(s.DB).DeleteCategory(r.Context(), id); err != nil {
+		slog.Error("delete category", "error", err)
+	}
+	http.Redirect(w, r, "/admin/categories", http.StatusFound)
+}
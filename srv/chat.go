@@ -0,0 +1,51 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// slackEscaper escapes the characters Slack reads as markup in message
+// text.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// discordEscaper escapes the characters that would end the text of a
+// Markdown link early in a Discord message.
+var discordEscaper = strings.NewReplacer("[", `\[`, "]", `\]`)
+
+// notifyChat posts a message about p to the Slack or Discord webhook in
+// the settings, if there is one, so that the admin hears of every post
+// published, scheduled and daily-wiki's included.
+func (s *Server) notifyChat(ctx context.Context, base string, p dbgen.Post) error {
+	hook := s.setting(ctx, settingChatWebhook)
+	if hook == "" {
+		return nil
+	}
+	body, err := json.Marshal(chatMessage(hook, p.Title, base+"/post/"+p.Slug))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doHookRequest(req)
+}
+
+// chatMessage returns the body of the message posted to hook about the
+// post titled title at link. Discord's webhooks, under /api/webhooks/,
+// take Markdown as content; Slack's, and those of services compatible with
+// them such as Mattermost, take their own markup as text.
+func chatMessage(hook, title, link string) map[string]string {
+	if u, err := url.Parse(hook); err == nil && strings.HasPrefix(u.Path, "/api/webhooks/") {
+		return map[string]string{"content": "Published: [" + discordEscaper.Replace(title) + "](" + link + ")"}
+	}
+	return map[string]string{"text": "Published: <" + link + "|" + slackEscaper.Replace(title) + ">"}
+}
@@ -0,0 +1,415 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The states a comment can be in. New comments are pending until they
+// are moderated, and only approved ones are shown.
+const (
+	commentPending  = "pending"
+	commentApproved = "approved"
+	commentRejected = "rejected"
+	commentSpam     = "spam"
+)
+
+const (
+	// maxCommentName is the longest name, in characters, a commenter can
+	// give.
+	maxCommentName = 100
+	// maxCommentBody is the longest comment, in characters.
+	maxCommentBody = 5000
+	// commentPageSize is the number of comments on each page of the
+	// moderation queue.
+	commentPageSize = 50
+	// maxCommentDepth is how deeply replies are nested. A reply to a
+	// comment already this deep is filed beside it, under its parent.
+	maxCommentDepth = 2
+)
+
+// CommentView is a comment as the post page shows it.
+type CommentView struct {
+	ID         int64
+	AuthorName string
+	AuthorURL  string // the commenter's website, possibly empty
+	BodyHTML   template.HTML
+	CreatedAt  time.Time
+	Depth      int // 0 for a comment on the post, 1 for a reply to one, and so on
+}
+
+// CommentForm is what a reader entered in the comment form, kept to fill
+// it in again when the comment is turned away.
+type CommentForm struct {
+	Name  string
+	Email string
+	URL   string
+	Body  string
+	Error string
+}
+
+// postComments returns the approved comments on the post with the given
+// ID in thread order, each followed by its replies.
+func (s *Server) postComments(ctx context.Context, postID int64) []CommentView {
+	comments, err := dbgen.New(s.DB).ListPostComments(ctx, postID)
+	if err != nil {
+		slog.Error("list post comments", "error", err)
+		return nil
+	}
+	views := make([]CommentView, len(comments))
+	for i, c := range comments {
+		views[i] = CommentView{
+			ID:         c.ID,
+			AuthorName: c.AuthorName,
+			AuthorURL:  c.AuthorUrl,
+			BodyHTML:   commentHTML(c.Body),
+			CreatedAt:  c.CreatedAt,
+			Depth:      int(c.Depth),
+		}
+	}
+	return views
+}
+
+// replyingTo returns the comment in comments with the ID given by the
+// reply form value, which a reply link on the post page sets, or nil if
+// there is none.
+func replyingTo(r *http.Request, comments []CommentView) *CommentView {
+	id, err := strconv.ParseInt(r.FormValue("reply"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	for i := range comments {
+		if comments[i].ID == id {
+			return &comments[i]
+		}
+	}
+	return nil
+}
+
+// replyParent returns the comment a reply to the comment with the given
+// ID is filed under: that comment or, if it is already nested
+// maxCommentDepth deep, the ancestor beside which the reply is no deeper.
+// The comment must be an approved one on the post with the given ID.
+func replyParent(ctx context.Context, q *dbgen.Queries, postID, id int64) (int64, error) {
+	c, err := q.GetComment(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if c.PostID != postID || c.Status != commentApproved {
+		return 0, sql.ErrNoRows
+	}
+	// chain runs from the comment up to the one on the post it is
+	// under, which is len(chain)-1 comments further up.
+	chain := []int64{c.ID}
+	for c.ParentCommentID != nil {
+		if c, err = q.GetComment(ctx, *c.ParentCommentID); err != nil {
+			return 0, err
+		}
+		chain = append(chain, c.ID)
+	}
+	if len(chain) <= maxCommentDepth {
+		return id, nil
+	}
+	return chain[len(chain)-maxCommentDepth], nil
+}
+
+// commentsChangedAt returns when a comment on the post with the given ID
+// last changed, or the zero time if it has none.
+func (s *Server) commentsChangedAt(ctx context.Context, postID int64) time.Time {
+	changed, err := dbgen.New(s.DB).GetPostCommentsChangedAt(ctx, postID)
+	if err != nil {
+		slog.Error("get post comments changed at", "error", err)
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.DateTime, changed)
+	return t
+}
+
+// commentHTML returns the plain text of a comment as HTML: escaped, with
+// blank lines between paragraphs and other line breaks kept.
+func commentHTML(body string) template.HTML {
+	var sb strings.Builder
+	for _, para := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		lines := strings.Split(para, "\n")
+		for i, line := range lines {
+			lines[i] = template.HTMLEscapeString(strings.TrimSpace(line))
+		}
+		sb.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	return template.HTML(sb.String())
+}
+
+// readCommentForm returns the comment submitted by the form on a post
+// page, with Error set if it cannot be taken as it is.
+func readCommentForm(r *http.Request) CommentForm {
+	form := CommentForm{
+		Name:  strings.TrimSpace(r.FormValue("name")),
+		Email: strings.TrimSpace(r.FormValue("email")),
+		URL:   strings.TrimSpace(r.FormValue("url")),
+		Body:  strings.TrimSpace(r.FormValue("body")),
+	}
+	switch {
+	case form.Name == "":
+		form.Error = "Please give your name"
+	case utf8.RuneCountInString(form.Name) > maxCommentName:
+		form.Error = "Your name can be at most " + strconv.Itoa(maxCommentName) + " characters"
+	case form.Body == "":
+		form.Error = "Please write a comment"
+	case utf8.RuneCountInString(form.Body) > maxCommentBody:
+		form.Error = "Comments can be at most " + strconv.Itoa(maxCommentBody) + " characters"
+	}
+	if form.Error != "" {
+		return form
+	}
+	if form.Email != "" {
+		if addr, err := mail.ParseAddress(form.Email); err != nil || addr.Name != "" {
+			form.Error = "That email address doesn't look right"
+			return form
+		}
+	}
+	if form.URL != "" {
+		if !strings.Contains(form.URL, "://") {
+			form.URL = "https://" + form.URL
+		}
+		if u, err := url.Parse(form.URL); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			form.Error = "Enter your website as an http:// or https:// address"
+		}
+	}
+	return form
+}
+
+// HandleCommentSubmit takes a comment on a post from the form on its
+// page. The form has no CSRF token, as post pages are the same for
+// everyone and anyone may comment; banned addresses are refused before
+// this is reached.
+func (s *Server) HandleCommentSubmit(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	p, err := q.GetPostBySlug(r.Context(), r.PathValue("slug"))
+	if err != nil || p.Published == 0 || p.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if p.CommentsClosed == 1 {
+		http.Error(w, "Comments on this post are closed", http.StatusForbidden)
+		return
+	}
+	form := readCommentForm(r)
+	var parentID *int64
+	if v := r.FormValue("reply"); v != "" && form.Error == "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			id, err = replyParent(r.Context(), q, p.ID, id)
+		}
+		if err != nil {
+			form.Error = "The comment you are replying to is no longer here"
+		}
+		parentID = &id
+	}
+	if form.Error != "" {
+		data := s.postPage(r, p)
+		data["CommentForm"] = form
+		s.render(w, "base.html", data)
+		return
+	}
+	status := commentPending
+	reason := s.commentSpamReason(r, p, form)
+	if reason != "" {
+		status = commentSpam
+	}
+	var ip string
+	if addr := clientIP(r); addr.IsValid() {
+		ip = addr.String()
+	}
+	c, err := q.CreateComment(r.Context(), dbgen.CreateCommentParams{
+		PostID:          p.ID,
+		ParentCommentID: parentID,
+		AuthorName:      form.Name,
+		AuthorEmail:     form.Email,
+		AuthorUrl:       form.URL,
+		Body:            form.Body,
+		Status:          status,
+		Ip:              ip,
+	})
+	if err != nil {
+		slog.Error("create comment", "error", err)
+		form.Error = "Your comment could not be saved; please try again"
+		data := s.postPage(r, p)
+		data["CommentForm"] = form
+		s.render(w, "base.html", data)
+		return
+	}
+	// Spam is answered as any other comment is, so as not to tell its
+	// sender what gave it away.
+	if reason != "" {
+		slog.Info("comment filed as spam", "id", c.ID, "post", p.Slug, "reason", reason)
+	} else {
+		slog.Info("comment held for moderation", "id", c.ID, "post", p.Slug)
+		s.emailComment(r, p, c)
+	}
+	http.Redirect(w, r, "/post/"+p.Slug+"?comment=held#comments", http.StatusFound)
+}
+
+// emailComment queues an email about c, a comment on p held for
+// moderation, to the address in the settings, if there is one.
+func (s *Server) emailComment(r *http.Request, p dbgen.Post, c dbgen.Comment) {
+	to := s.setting(r.Context(), settingCommentEmail)
+	if to == "" {
+		return
+	}
+	base := s.baseURL(r)
+	m, err := s.mailMessage("comment", to, map[string]any{
+		"PostTitle": p.Title,
+		"PostLink":  base + "/post/" + p.Slug,
+		"Author":    c.AuthorName,
+		"Email":     c.AuthorEmail,
+		"URL":       c.AuthorUrl,
+		"Body":      c.Body,
+		"Moderate":  base + "/admin/comments",
+	})
+	if err == nil {
+		err = s.queueMail(r.Context(), m)
+	}
+	if err != nil {
+		slog.Error("queue comment email", "comment", c.ID, "error", err)
+	}
+}
+
+// CommentCounts is the number of comments in each state.
+type CommentCounts struct {
+	Pending  int64
+	Approved int64
+	Rejected int64
+	Spam     int64
+}
+
+// commentCounts counts the comments in each state.
+func commentCounts(ctx context.Context, q *dbgen.Queries) CommentCounts {
+	var counts CommentCounts
+	rows, err := q.CountCommentsByStatus(ctx)
+	if err != nil {
+		slog.Error("count comments", "error", err)
+	}
+	for _, row := range rows {
+		switch row.Status {
+		case commentPending:
+			counts.Pending = row.CommentCount
+		case commentApproved:
+			counts.Approved = row.CommentCount
+		case commentRejected:
+			counts.Rejected = row.CommentCount
+		case commentSpam:
+			counts.Spam = row.CommentCount
+		}
+	}
+	return counts
+}
+
+// HandleAdminComments shows the comments in the state given by the status
+// query parameter, newest first, pending ones by default.
+func (s *Server) HandleAdminComments(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case commentApproved, commentRejected, commentSpam:
+	default:
+		status = commentPending
+	}
+	q := dbgen.New(s.DB)
+	counts := commentCounts(r.Context(), q)
+	total := map[string]int64{
+		commentPending:  counts.Pending,
+		commentApproved: counts.Approved,
+		commentRejected: counts.Rejected,
+		commentSpam:     counts.Spam,
+	}[status]
+	page := pageNumber(r)
+	comments, err := q.ListCommentsByStatus(r.Context(), dbgen.ListCommentsByStatusParams{
+		Status: status,
+		Limit:  commentPageSize,
+		Offset: int64((page - 1) * commentPageSize),
+	})
+	if err != nil {
+		slog.Error("list comments", "error", err)
+	}
+	s.render(w, "admin_comments.html", map[string]any{
+		"Comments":        comments,
+		"Counts":          counts,
+		"Status":          status,
+		"Pagination":      paginate(page, commentPageSize, total),
+		"PendingMentions": mentionCounts(r.Context(), q).Pending,
+		"CSRFToken":       csrfToken(r),
+		"Year":            time.Now().Year(),
+	})
+}
+
+// HandleAdminCommentModerate applies one action to the selected comments:
+// "approve", "reject", "spam" or "delete", which cannot be undone. Either
+// every selected comment is changed or, on an error, none is.
+func (s *Server) HandleAdminCommentModerate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	var ids []int64
+	for _, v := range r.PostForm["id"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Bad comment ID "+v, http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	action := r.PostFormValue("action")
+	status, ok := map[string]string{
+		"approve": commentApproved,
+		"reject":  commentRejected,
+		"spam":    commentSpam,
+		"delete":  "",
+	}[action]
+	if !ok {
+		http.Error(w, "Unknown action "+action, http.StatusBadRequest)
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.Error("begin comment moderation", "error", err)
+		http.Error(w, "Failed to update", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	for _, id := range ids {
+		if action == "delete" {
+			err = q.DeleteComment(r.Context(), id)
+		} else {
+			err = q.SetCommentStatus(r.Context(), dbgen.SetCommentStatusParams{Status: status, ID: id})
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.Error("moderate comments", "action", action, "error", err)
+		http.Error(w, "Failed to update", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/comments?status="+url.QueryEscape(r.PostFormValue("status")), http.StatusFound)
+}
@@ -0,0 +1,149 @@
+package srv
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// commentHoneypot is a field of the comment form that people do not
+	// see, so only bots fill it in.
+	commentHoneypot = "website"
+	// commentTokenField is the field of the comment form holding when
+	// the form was served, signed by commentToken.
+	commentTokenField = "token"
+	// minCommentTime is the least time it takes a person to write a
+	// comment after the post page is served. Comments sent sooner are
+	// from bots.
+	minCommentTime = 3 * time.Second
+)
+
+// spamCheckClient makes the requests to the spam check service.
+var spamCheckClient = &http.Client{Timeout: 5 * time.Second}
+
+// akismetEndpoint is the spam check service used when the setting for
+// its address is empty.
+var akismetEndpoint = "https://rest.akismet.com/1.1"
+
+// commentToken returns a token for the comment form recording that it was
+// served at t, signed so that it cannot be forged.
+func (s *Server) commentToken(t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return ts + "." + s.signCommentToken(ts)
+}
+
+// signCommentToken returns the signature of a comment token's time.
+func (s *Server) signCommentToken(ts string) string {
+	mac := hmac.New(sha256.New, s.commentKey)
+	mac.Write([]byte(ts))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// commentTokenTime returns when the comment form that sent token was
+// served, and false if the token is missing or not one commentToken made.
+// Tokens are signed with a key made when the server starts, so forms
+// served before a restart have none that verify.
+func (s *Server) commentTokenTime(token string) (time.Time, bool) {
+	ts, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.signCommentToken(ts))) {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// commentSpamReason returns why the comment r submits on p looks like
+// spam, or "" if it does not. The form's honeypot and token are checked
+// first, then the spam check service if a key for it is set. When the
+// service cannot be reached the comment is let through, as it still waits
+// for moderation.
+func (s *Server) commentSpamReason(r *http.Request, p dbgen.Post, form CommentForm) string {
+	if r.FormValue(commentHoneypot) != "" {
+		return "honeypot filled in"
+	}
+	served, ok := s.commentTokenTime(r.FormValue(commentTokenField))
+	if !ok {
+		return "no valid form token"
+	}
+	if time.Since(served) < minCommentTime {
+		return "sent too soon after the form was served"
+	}
+	spam, err := s.checkSpamService(r, p, form)
+	if err != nil {
+		slog.Warn("check comment for spam", "post", p.Slug, "error", err)
+		return ""
+	}
+	if spam {
+		return "spam check service"
+	}
+	return ""
+}
+
+// checkSpamService asks the Akismet-compatible spam check service whether
+// the comment r submits on p is spam. It reports false without asking if
+// no key is set.
+func (s *Server) checkSpamService(r *http.Request, p dbgen.Post, form CommentForm) (bool, error) {
+	key := s.setting(r.Context(), settingSpamCheckKey)
+	if key == "" {
+		return false, nil
+	}
+	base := s.baseURL(r)
+	var ip string
+	if addr := clientIP(r); addr.IsValid() {
+		ip = addr.String()
+	}
+	params := url.Values{
+		"api_key":              {key},
+		"blog":                 {base},
+		"permalink":            {base + "/post/" + p.Slug},
+		"user_ip":              {ip},
+		"user_agent":           {r.UserAgent()},
+		"referrer":             {r.Referer()},
+		"comment_type":         {"comment"},
+		"comment_author":       {form.Name},
+		"comment_author_email": {form.Email},
+		"comment_author_url":   {form.URL},
+		"comment_content":      {form.Body},
+		"blog_charset":         {"UTF-8"},
+	}
+	endpoint := strings.TrimRight(cmp.Or(s.setting(r.Context(), settingSpamCheckURL), akismetEndpoint), "/")
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, endpoint+"/comment-check", strings.NewReader(params.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := spamCheckClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return false, err
+	}
+	switch answer := strings.TrimSpace(string(body)); {
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("spam check service answered %s", resp.Status)
+	case answer == "true":
+		return true, nil
+	case answer == "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("spam check service answered %q: %s", answer, resp.Header.Get("X-akismet-debug-help"))
+	}
+}
@@ -0,0 +1,55 @@
+package srv
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// notModified sets the validators of a response for a resource last
+// changed at modified, and reports whether the request already has that
+// version, in which case it has answered with 304 Not Modified. Kind tells
+// apart representations of the same resource, such as a post's page and
+// its JSON.
+//
+// Caches are asked to revalidate every time, since the response can depend
+// on more than the resource, and a Last-Modified alone would let them
+// guess how long to keep it.
+func notModified(w http.ResponseWriter, r *http.Request, kind string, id int64, modified time.Time) bool {
+	modified = modified.UTC().Truncate(time.Second)
+	etag := fmt.Sprintf(`W/"%s-%d-%d"`, kind, id, modified.Unix())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match takes precedence, and If-Modified-Since is ignored
+	// when it is present (RFC 9110, section 13.2.2).
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil || modified.After(ims) {
+		return false
+	}
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether the If-None-Match header value list names
+// etag, using the weak comparison that the header calls for.
+func etagMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
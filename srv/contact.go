@@ -0,0 +1,244 @@
+package srv
+
+import (
+	"cmp"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// maxContactMessages is the most messages the contact form takes from
+	// one address in contactWindow.
+	maxContactMessages = 3
+	contactWindow      = time.Hour
+	// maxContactSubject and maxContactBody are the longest subject and
+	// message, in characters, the contact form takes.
+	maxContactSubject = 200
+	maxContactBody    = 10000
+	// messagePageSize is the number of messages on each page of the
+	// admin inbox.
+	messagePageSize = 50
+)
+
+// ContactForm is what was entered in the contact form, and what is wrong
+// with it, to show it again when it cannot be sent.
+type ContactForm struct {
+	Name    string
+	Email   string
+	Subject string
+	Body    string
+	Error   string
+}
+
+// readContactForm returns the message submitted by the contact form, with
+// Error set if it cannot be taken as it is.
+func readContactForm(r *http.Request) ContactForm {
+	form := ContactForm{
+		Name:    strings.TrimSpace(r.FormValue("name")),
+		Email:   strings.TrimSpace(r.FormValue("email")),
+		Subject: strings.Join(strings.Fields(r.FormValue("subject")), " "),
+		Body:    strings.TrimSpace(r.FormValue("body")),
+	}
+	switch {
+	case form.Name == "":
+		form.Error = "Please give your name"
+	case utf8.RuneCountInString(form.Name) > maxCommentName:
+		form.Error = "Your name can be at most " + strconv.Itoa(maxCommentName) + " characters"
+	case form.Email == "":
+		form.Error = "Please give your email address, so that you can be answered"
+	case utf8.RuneCountInString(form.Subject) > maxContactSubject:
+		form.Error = "The subject can be at most " + strconv.Itoa(maxContactSubject) + " characters"
+	case form.Body == "":
+		form.Error = "Please write a message"
+	case utf8.RuneCountInString(form.Body) > maxContactBody:
+		form.Error = "Messages can be at most " + strconv.Itoa(maxContactBody) + " characters"
+	}
+	if form.Error != "" {
+		return form
+	}
+	if addr, err := mail.ParseAddress(form.Email); err != nil || addr.Name != "" {
+		form.Error = "That email address doesn't look right"
+	}
+	return form
+}
+
+// renderContact renders the contact page with the form, filled in as
+// form, or saying the message was sent.
+func (s *Server) renderContact(w http.ResponseWriter, status int, form ContactForm, sent bool) {
+	s.renderStatus(w, status, "base.html", map[string]any{
+		"Page":         "contact",
+		"ContactForm":  form,
+		"ContactSent":  sent,
+		"CommentToken": s.commentToken(time.Now()),
+		"Year":         time.Now().Year(),
+	})
+}
+
+// HandleContact shows the contact form, or, after a message is sent,
+// that it was.
+func (s *Server) HandleContact(w http.ResponseWriter, r *http.Request) {
+	s.renderContact(w, http.StatusOK, ContactForm{}, r.URL.Query().Get("sent") == "1")
+}
+
+// HandleContactSubmit takes a message for the admin from the contact
+// form, keeps it for the inbox in the admin and emails it on. Like the
+// comment form, the form has no CSRF token, and has the comment form's
+// honeypot and token; a message that gives itself away as a bot's by
+// them is dropped, but answered as if it were sent. Each address may send
+// maxContactMessages in contactWindow.
+func (s *Server) HandleContactSubmit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	form := readContactForm(r)
+	if form.Error != "" {
+		s.renderContact(w, http.StatusBadRequest, form, false)
+		return
+	}
+	if reason := s.contactSpamReason(r); reason != "" {
+		slog.Info("contact message dropped", "reason", reason)
+		http.Redirect(w, r, "/contact?sent=1", http.StatusSeeOther)
+		return
+	}
+
+	q := dbgen.New(s.DB)
+	sender := throttleKey(r)
+	n, err := q.CountContactMessagesFrom(ctx, dbgen.CountContactMessagesFromParams{
+		Sender: sender,
+		Since:  dbTime(time.Now().Add(-contactWindow)),
+	})
+	if err != nil {
+		slog.Error("count contact messages", "error", err)
+	}
+	if n >= maxContactMessages {
+		w.Header().Set("Retry-After", strconv.Itoa(int(contactWindow.Seconds())))
+		form.Error = "You have sent several messages already. Please wait a while before sending another."
+		s.renderContact(w, http.StatusTooManyRequests, form, false)
+		return
+	}
+
+	msg, err := q.CreateContactMessage(ctx, dbgen.CreateContactMessageParams{
+		Name:    form.Name,
+		Email:   form.Email,
+		Subject: form.Subject,
+		Body:    form.Body,
+		Sender:  sender,
+	})
+	if err != nil {
+		slog.Error("create contact message", "error", err)
+		form.Error = "Your message could not be sent; please try again"
+		s.renderContact(w, http.StatusInternalServerError, form, false)
+		return
+	}
+	slog.Info("contact message received", "id", msg.ID)
+	s.emailContactMessage(r, msg)
+	http.Redirect(w, r, "/contact?sent=1", http.StatusSeeOther)
+}
+
+// contactSpamReason returns why the message r submits from the contact
+// form was sent by a bot, or "" if nothing says it was.
+func (s *Server) contactSpamReason(r *http.Request) string {
+	if r.FormValue(commentHoneypot) != "" {
+		return "honeypot filled in"
+	}
+	served, ok := s.commentTokenTime(r.FormValue(commentTokenField))
+	if !ok {
+		return "no valid form token"
+	}
+	if time.Since(served) < minCommentTime {
+		return "sent too soon after the form was served"
+	}
+	return ""
+}
+
+// emailContactMessage queues an email of msg to the address in the
+// settings, if there is one, for replies to go to its sender.
+func (s *Server) emailContactMessage(r *http.Request, msg dbgen.ContactMessage) {
+	to := s.setting(r.Context(), settingContactEmail)
+	if to == "" {
+		return
+	}
+	m, err := s.mailMessage("contact", to, map[string]any{
+		"Name":    msg.Name,
+		"Email":   msg.Email,
+		"Subject": cmp.Or(msg.Subject, "Message from "+msg.Name),
+		"Body":    msg.Body,
+		"Inbox":   s.baseURL(r) + "/admin/messages",
+	})
+	if err == nil {
+		m.Header = map[string]string{"Reply-To": (&mail.Address{Name: msg.Name, Address: msg.Email}).String()}
+		err = s.queueMail(r.Context(), m)
+	}
+	if err != nil {
+		slog.Error("queue contact email", "message", msg.ID, "error", err)
+	}
+}
+
+// HandleAdminMessages lists the messages sent with the contact form,
+// newest first: the unread ones, or all of them with ?show=all.
+func (s *Server) HandleAdminMessages(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	all := r.URL.Query().Get("show") == "all"
+	counts, err := q.CountContactMessages(r.Context())
+	if err != nil {
+		slog.Error("count contact messages", "error", err)
+	}
+	total, unreadOnly := counts.Unread, int64(1)
+	if all {
+		total, unreadOnly = counts.Total, 0
+	}
+	page := pageNumber(r)
+	messages, err := q.ListContactMessages(r.Context(), dbgen.ListContactMessagesParams{
+		UnreadOnly:  unreadOnly,
+		MaxMessages: messagePageSize,
+		Skip:        int64((page - 1) * messagePageSize),
+	})
+	if err != nil {
+		slog.Error("list contact messages", "error", err)
+	}
+	s.render(w, "admin_messages.html", map[string]any{
+		"Messages":   messages,
+		"Counts":     counts,
+		"All":        all,
+		"Pagination": paginate(page, messagePageSize, total),
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
+	})
+}
+
+// HandleAdminMessageAction marks the message with the ID in the id form
+// value read or unread, or deletes it, as the action form value says.
+func (s *Server) HandleAdminMessageAction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PostFormValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad message ID", http.StatusBadRequest)
+		return
+	}
+	q := dbgen.New(s.DB)
+	switch action := r.PostFormValue("action"); action {
+	case "read":
+		err = q.MarkContactMessageRead(r.Context(), id)
+	case "unread":
+		err = q.MarkContactMessageUnread(r.Context(), id)
+	case "delete":
+		err = q.DeleteContactMessage(r.Context(), id)
+	default:
+		http.Error(w, "Unknown action "+action, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("update contact message", "id", id, "error", err)
+		http.Error(w, "Failed to update", http.StatusInternalServerError)
+		return
+	}
+	target := "/admin/messages"
+	if r.PostFormValue("show") == "all" {
+		target += "?show=all"
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
@@ -0,0 +1,109 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// dashboardWeeks is how many weeks, up to and including this one, the
+	// dashboard charts posts for.
+	dashboardWeeks = 12
+	// dashboardListSize is the most posts each dashboard list shows.
+	dashboardListSize = 5
+	// topViewedDays is the window, in days up to and including today, that
+	// the dashboard ranks posts by views over.
+	topViewedDays = 30
+)
+
+// WeekCount is the number of posts published in the week starting on a
+// Monday.
+type WeekCount struct {
+	Start   time.Time
+	Count   int64
+	Percent int64 // of the busiest week charted, for the height of its bar
+}
+
+func (s *Server) HandleAdminDashboard(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	now := time.Now().UTC()
+
+	recent, err := q.ListAdminPostsByUpdated(r.Context(), dbgen.ListAdminPostsByUpdatedParams{Limit: dashboardListSize})
+	if err != nil {
+		slog.Error("list recent edits", "error", err)
+	}
+	scheduled, err := q.ListScheduledPosts(r.Context())
+	if err != nil {
+		slog.Error("list scheduled posts", "error", err)
+	}
+	topViewed, err := q.ListTopViewedPosts(r.Context(), dbgen.ListTopViewedPostsParams{
+		Since:    now.AddDate(0, 0, 1-topViewedDays).Format(time.DateOnly),
+		MaxPosts: dashboardListSize,
+	})
+	if err != nil {
+		slog.Error("list top viewed posts", "error", err)
+	}
+	views, err := q.CountViewsSince(r.Context(), now.AddDate(0, 0, 1-topViewedDays).Format(time.DateOnly))
+	if err != nil {
+		slog.Error("count views", "error", err)
+	}
+	messages, err := q.CountContactMessages(r.Context())
+	if err != nil {
+		slog.Error("count contact messages", "error", err)
+	}
+	likes, err := q.CountLikes(r.Context())
+	if err != nil {
+		slog.Error("count likes", "error", err)
+	}
+
+	s.render(w, "admin_dashboard.html", map[string]any{
+		"Counts":        statusCounts(r.Context(), q),
+		"CommentCounts": commentCounts(r.Context(), q),
+		"Messages":      messages,
+		"Likes":         likes,
+		"Weeks":         weeklyPostCounts(r.Context(), q, now),
+		"Recent":        recent,
+		"Scheduled":     scheduled,
+		"TopViewed":     topViewed,
+		"Views":         views,
+		"TopViewedDays": topViewedDays,
+		"User":          sessionEmail(r),
+		"CSRFToken":     csrfToken(r),
+		"Year":          time.Now().Year(),
+	})
+}
+
+// weeklyPostCounts returns the number of posts published in each of the
+// last dashboardWeeks weeks, oldest first, including weeks with none.
+func weeklyPostCounts(ctx context.Context, q *dbgen.Queries, now time.Time) []WeekCount {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	weeks := make([]WeekCount, dashboardWeeks)
+	index := make(map[string]int, dashboardWeeks)
+	for i := range weeks {
+		weeks[i].Start = monday.AddDate(0, 0, 7*(i-dashboardWeeks+1))
+		index[weeks[i].Start.Format(time.DateOnly)] = i
+	}
+
+	rows, err := q.CountPublishedPostsByWeek(ctx, weeks[0].Start.Format(time.DateOnly))
+	if err != nil {
+		slog.Error("count posts by week", "error", err)
+	}
+	var busiest int64
+	for _, row := range rows {
+		if i, ok := index[row.WeekStart]; ok {
+			weeks[i].Count = row.PostCount
+			busiest = max(busiest, row.PostCount)
+		}
+	}
+	if busiest > 0 {
+		for i := range weeks {
+			weeks[i].Percent = weeks[i].Count * 100 / busiest
+		}
+	}
+	return weeks
+}
@@ -0,0 +1,43 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected []Line
+	}{
+		{"", "", nil},
+		{"one\n", "one", []Line{{Equal, "one"}}},
+		{"one\r\ntwo", "one\ntwo\n", []Line{{Equal, "one"}, {Equal, "two"}}},
+		{"", "new", []Line{{Insert, "new"}}},
+		{"old", "", []Line{{Delete, "old"}}},
+		{
+			"a\nb\nc\nd",
+			"a\nB\nc\nd\ne",
+			[]Line{{Equal, "a"}, {Delete, "b"}, {Insert, "B"}, {Equal, "c"}, {Equal, "d"}, {Insert, "e"}},
+		},
+		{
+			"x\na\nb",
+			"a\nb\nx",
+			[]Line{{Delete, "x"}, {Equal, "a"}, {Equal, "b"}, {Insert, "x"}},
+		},
+	}
+	for _, tt := range tests {
+		if result := Lines(tt.a, tt.b); !slices.Equal(result, tt.expected) {
+			t.Errorf("Lines(%q, %q) = %v, expected %v", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	if Changed(Lines("same\ntext", "same\ntext\n")) {
+		t.Error("expected equal texts to be unchanged")
+	}
+	if !Changed(Lines("same", "different")) {
+		t.Error("expected different texts to be changed")
+	}
+}
@@ -0,0 +1,59 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log/slog"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/markdown"
+)
+
+// cachedEmbed looks up embeds resolved by resolveEmbeds. It never fetches,
+// so rendering a post does not wait on a provider; URLs that have not been
+// resolved stay as plain text.
+func (s *Server) cachedEmbed(ctx context.Context) func(string) (template.HTML, bool) {
+	q := dbgen.New(s.DB)
+	return func(url string) (template.HTML, bool) {
+		html, err := q.GetOEmbed(ctx, url)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				slog.Error("get oembed", "url", url, "error", err)
+			}
+			return "", false
+		}
+		return template.HTML(html), true
+	}
+}
+
+// resolveEmbeds fetches and caches the embed for each bare provider URL in
+// content that is not cached yet. It is called when a post is saved.
+func (s *Server) resolveEmbeds(ctx context.Context, content string) {
+	if s.OEmbed == nil {
+		return
+	}
+	var urls []string
+	markdown.Options{Embed: func(url string) (template.HTML, bool) {
+		if _, ok := s.OEmbed.Match(url); ok {
+			urls = append(urls, url)
+		}
+		return "", false
+	}}.Parse(content)
+
+	q := dbgen.New(s.DB)
+	for _, url := range urls {
+		if _, err := q.GetOEmbed(ctx, url); err == nil {
+			continue
+		}
+		html, err := s.OEmbed.Embed(ctx, url)
+		if err != nil {
+			slog.Error("resolve oembed", "url", url, "error", err)
+			continue
+		}
+		if err := q.UpsertOEmbed(ctx, dbgen.UpsertOEmbedParams{Url: url, Html: string(html)}); err != nil {
+			slog.Error("cache oembed", "url", url, "error", err)
+		}
+	}
+}
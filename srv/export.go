@@ -0,0 +1,100 @@
+package srv
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// exportFiles calls put with the path and contents of each file of an
+// export, stopping at the first error put returns.
+type exportFiles func(ctx context.Context, put func(name string, data []byte) error) error
+
+// markdownFiles puts each post not in the trash, oldest first, as a
+// Markdown file with front matter.
+func (s *Server) markdownFiles(ctx context.Context, put func(name string, data []byte) error) error {
+	q := dbgen.New(s.DB)
+	posts, err := q.ListAllPosts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range posts {
+		postTags, err := q.GetPostTags(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if err := put(p.Slug+".md", []byte(postFile(p, postTags))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeExport writes the files of an export under dir, returning how
+// many it wrote.
+func writeExport(ctx context.Context, dir string, files exportFiles) (int, error) {
+	n := 0
+	err := files(ctx, func(name string, data []byte) error {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		n++
+		return os.WriteFile(path, data, 0o644)
+	})
+	return n, err
+}
+
+// serveExport answers r with the files of an export as a zip download
+// named name-DATE.zip.
+func serveExport(w http.ResponseWriter, r *http.Request, name string, files exportFiles) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.zip"`, name, time.Now().Format(time.DateOnly)))
+	zw := zip.NewWriter(w)
+	err := files(r.Context(), func(name string, data []byte) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		// The response has started, so the zip is left unfinished, which
+		// unzipping it reports.
+		slog.Error("export", "name", name, "error", err)
+	}
+}
+
+// ExportMarkdown writes each post not in the trash to dir as SLUG.md,
+// with front matter giving its slug, title, date, whether it is
+// published and its tags. It returns the number of posts written.
+func (s *Server) ExportMarkdown(ctx context.Context, dir string) (int, error) {
+	return writeExport(ctx, dir, s.markdownFiles)
+}
+
+// ImportMarkdown saves the Markdown files under dir as posts, as a sync of
+// the content directory does: each over the post with its slug, or as a
+// new post.
+func (s *Server) ImportMarkdown(ctx context.Context, dir string) (ImportResult, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return ImportResult{}, err
+	}
+	return s.importPostFiles(ctx, dir)
+}
+
+// HandleAdminExportMarkdown downloads every post not in the trash as a zip
+// of Markdown files, as ExportMarkdown writes them.
+func (s *Server) HandleAdminExportMarkdown(w http.ResponseWriter, r *http.Request) {
+	serveExport(w, r, "posts", s.markdownFiles)
+}
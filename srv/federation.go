@@ -0,0 +1,519 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/activitypub"
+)
+
+// settingActorKey is the setting holding the PEM private key the blog's
+// ActivityPub actor signs its requests with. It is made the first time it
+// is needed and, not being in settingDefs, is not shown in the admin.
+const settingActorKey = "activitypub_private_key"
+
+const (
+	// federationInterval is how often the server looks for activities due
+	// to be delivered again.
+	federationInterval = 30 * time.Second
+	// federationLogAge is how long deliveries are kept once they are done
+	// with.
+	federationLogAge = 7 * 24 * time.Hour
+	// outboxSize is the number of recent posts the outbox lists.
+	outboxSize = 20
+	// maxInboxActivity is the largest activity the inbox reads.
+	maxInboxActivity = 1 << 20
+)
+
+// federationRetries are the waits before each retry of an activity that
+// could not be delivered. Once they are used up, it is given up on.
+var federationRetries = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// federationClient fetches actors and their keys from fediverse servers
+// and delivers activities to their inboxes. Those addresses come from
+// whoever sends the inbox an activity, so, as webmentionClient does, it
+// only connects to public addresses.
+var federationClient = &http.Client{Timeout: 10 * time.Second, Transport: webmentionClient.Transport}
+
+// The addresses of the blog's ActivityPub actor and its collections under
+// the site URL.
+func actorID(base string) string         { return base + "/activitypub/actor" }
+func actorKeyID(base string) string      { return actorID(base) + "#main-key" }
+func articleID(base, slug string) string { return base + "/activitypub/posts/" + slug }
+
+// fediverseUser returns the name the blog can be followed by.
+func (s *Server) fediverseUser(ctx context.Context) string {
+	if user := s.setting(ctx, settingFediverseUser); user != "" {
+		return user
+	}
+	def, _ := lookupSetting(settingFediverseUser)
+	return def.Default
+}
+
+// loadActorKey returns the key the blog's actor signs with, making and
+// storing one if there is none yet.
+func (s *Server) loadActorKey(ctx context.Context) (*rsa.PrivateKey, error) {
+	s.actorMu.Lock()
+	defer s.actorMu.Unlock()
+	if s.actorKey != nil {
+		return s.actorKey, nil
+	}
+	encoded := s.setting(ctx, settingActorKey)
+	if encoded == "" {
+		key, err := activitypub.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		if encoded, err = activitypub.MarshalPrivateKey(key); err != nil {
+			return nil, err
+		}
+		err = dbgen.New(s.DB).UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingActorKey, Value: encoded})
+		if err != nil {
+			return nil, err
+		}
+	}
+	key, err := activitypub.ParsePrivateKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	s.actorKey = key
+	return key, nil
+}
+
+// activityClient returns a client that signs its requests as the blog's
+// actor under base.
+func (s *Server) activityClient(ctx context.Context, base string) (*activitypub.Client, error) {
+	key, err := s.loadActorKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &activitypub.Client{HTTP: federationClient, KeyID: actorKeyID(base), Key: key}, nil
+}
+
+// federationBase returns the site URL, which the blog's actor and posts
+// are addressed under, answering 404 Not Found if it is not set: the
+// fediverse needs addresses that do not depend on how a request arrived.
+func (s *Server) federationBase(w http.ResponseWriter, r *http.Request) (string, bool) {
+	base := s.setting(r.Context(), settingSiteURL)
+	if base == "" {
+		http.NotFound(w, r)
+		return "", false
+	}
+	return base, true
+}
+
+// writeActivityJSON writes v as an ActivityPub document.
+func writeActivityJSON(w http.ResponseWriter, contentType string, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("encode activitypub document", "error", err)
+	}
+}
+
+// HandleWebFinger finds the blog's actor from its handle, as in
+// acct:blog@example.com, or from its id.
+func (s *Server) HandleWebFinger(w http.ResponseWriter, r *http.Request) {
+	base, ok := s.federationBase(w, r)
+	if !ok {
+		return
+	}
+	site, err := url.Parse(base)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	user := s.fediverseUser(r.Context())
+	resource := r.URL.Query().Get("resource")
+	if name, host, ok := activitypub.ParseAccount(resource); ok {
+		if !strings.EqualFold(name, user) || !strings.EqualFold(host, site.Host) {
+			http.NotFound(w, r)
+			return
+		}
+	} else if resource != actorID(base) {
+		http.NotFound(w, r)
+		return
+	}
+	writeActivityJSON(w, activitypub.JRDType, activitypub.JRD{
+		Subject: "acct:" + user + "@" + site.Host,
+		Aliases: []string{actorID(base), base + "/"},
+		Links: []activitypub.Link{
+			{Rel: "self", Type: activitypub.ContentType, Href: actorID(base)},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: base + "/"},
+		},
+	})
+}
+
+// HandleActor serves the blog's actor, with the public key its requests
+// are signed with.
+func (s *Server) HandleActor(w http.ResponseWriter, r *http.Request) {
+	base, ok := s.federationBase(w, r)
+	if !ok {
+		return
+	}
+	key, err := s.loadActorKey(r.Context())
+	if err != nil {
+		slog.Error("load activitypub key", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	public, err := activitypub.MarshalPublicKey(&key.PublicKey)
+	if err != nil {
+		slog.Error("encode activitypub key", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	writeActivityJSON(w, activitypub.ContentType, activitypub.Actor{
+		Context:           activitypub.ActorContext,
+		ID:                actorID(base),
+		Type:              "Person",
+		PreferredUsername: s.fediverseUser(r.Context()),
+		Name:              siteTitle,
+		Summary:           "<p>" + html.EscapeString(siteTagline) + "</p>",
+		URL:               base + "/",
+		Inbox:             base + "/activitypub/inbox",
+		Outbox:            base + "/activitypub/outbox",
+		Followers:         base + "/activitypub/followers",
+		Endpoints:         &activitypub.Endpoints{SharedInbox: base + "/activitypub/inbox"},
+		PublicKey: &activitypub.PublicKey{
+			ID:           actorKeyID(base),
+			Owner:        actorID(base),
+			PublicKeyPem: public,
+		},
+	})
+}
+
+// HandleOutbox lists the Create activities of the most recent posts.
+func (s *Server) HandleOutbox(w http.ResponseWriter, r *http.Request) {
+	base, ok := s.federationBase(w, r)
+	if !ok {
+		return
+	}
+	q := dbgen.New(s.DB)
+	count, err := q.CountPublishedPosts(r.Context())
+	if err != nil {
+		slog.Error("count posts", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	posts, err := q.GetPublishedPostsPage(r.Context(), dbgen.GetPublishedPostsPageParams{Limit: outboxSize})
+	if err != nil {
+		slog.Error("get posts", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	outbox := activitypub.Collection{
+		Context:    activitypub.Context,
+		ID:         base + "/activitypub/outbox",
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	}
+	for _, p := range posts {
+		create := s.createActivity(r.Context(), base, p)
+		create.Context = nil
+		outbox.OrderedItems = append(outbox.OrderedItems, create)
+	}
+	writeActivityJSON(w, activitypub.ContentType, outbox)
+}
+
+// HandleFollowers gives the number of the blog's followers, but not who
+// they are.
+func (s *Server) HandleFollowers(w http.ResponseWriter, r *http.Request) {
+	base, ok := s.federationBase(w, r)
+	if !ok {
+		return
+	}
+	count, err := dbgen.New(s.DB).CountFollowers(r.Context())
+	if err != nil {
+		slog.Error("count followers", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	writeActivityJSON(w, activitypub.ContentType, activitypub.Collection{
+		Context:    activitypub.Context,
+		ID:         base + "/activitypub/followers",
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	})
+}
+
+// HandleActivityPost serves a published post as an ActivityPub Article, at
+// the id it is delivered to followers with.
+func (s *Server) HandleActivityPost(w http.ResponseWriter, r *http.Request) {
+	base, ok := s.federationBase(w, r)
+	if !ok {
+		return
+	}
+	p, err := dbgen.New(s.DB).GetPostBySlug(r.Context(), r.PathValue("slug"))
+	if err != nil || !onSite(&p) {
+		http.NotFound(w, r)
+		return
+	}
+	article := s.postArticle(r.Context(), base, p)
+	article.Context = activitypub.Context
+	writeActivityJSON(w, activitypub.ContentType, article)
+}
+
+// postArticle returns p as an ActivityPub Article, addressed to everyone
+// and the blog's followers. Mastodon shows an Article's title and a link
+// to its page; other servers may show the content as well.
+func (s *Server) postArticle(ctx context.Context, base string, p dbgen.Post) activitypub.Object {
+	published := p.CreatedAt.UTC()
+	article := activitypub.Object{
+		ID:           articleID(base, p.Slug),
+		Type:         "Article",
+		AttributedTo: actorID(base),
+		Name:         p.Title,
+		Content:      string(s.renderPost(ctx, p).html),
+		URL:          base + "/post/" + p.Slug,
+		Published:    &published,
+		To:           []string{activitypub.Public},
+		CC:           []string{base + "/activitypub/followers"},
+	}
+	if p.UpdatedAt.After(p.CreatedAt) {
+		updated := p.UpdatedAt.UTC()
+		article.Updated = &updated
+	}
+	postTags, err := dbgen.New(s.DB).GetPostTags(ctx, p.ID)
+	if err != nil {
+		slog.Error("get post tags", "slug", p.Slug, "error", err)
+	}
+	for _, t := range postTags {
+		article.Tag = append(article.Tag, activitypub.Tag{
+			Type: "Hashtag",
+			Name: "#" + strings.ReplaceAll(t.Name, " ", ""),
+			Href: base + "/tag/" + t.Slug,
+		})
+	}
+	return article
+}
+
+// createActivity returns the activity of the blog creating p.
+func (s *Server) createActivity(ctx context.Context, base string, p dbgen.Post) activitypub.Activity {
+	article := s.postArticle(ctx, base, p)
+	return activitypub.Activity{
+		Context:   activitypub.Context,
+		ID:        article.ID + "#create",
+		Type:      "Create",
+		Actor:     actorID(base),
+		Published: article.Published,
+		To:        article.To,
+		CC:        article.CC,
+		Object:    article,
+	}
+}
+
+// HandleInbox receives activities from fediverse servers. Each must be
+// signed by the actor it is from. Follows of the blog add their actor to
+// its followers, who are sent an Accept, and undoing one removes them;
+// anything else is accepted and ignored.
+func (s *Server) HandleInbox(w http.ResponseWriter, r *http.Request) {
+	base, ok := s.federationBase(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboxActivity))
+	if err != nil {
+		http.Error(w, "Activity too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var activity activitypub.Activity
+	if err := json.Unmarshal(body, &activity); err != nil || activity.Actor == "" {
+		http.Error(w, "Not an activity", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	client, err := s.activityClient(ctx, base)
+	if err != nil {
+		slog.Error("load activitypub key", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	var signer string
+	_, err = activitypub.Verify(r, body, func(keyID string) (*rsa.PublicKey, error) {
+		owner, key, err := client.PublicKey(ctx, keyID)
+		signer = owner
+		return key, err
+	})
+	if err != nil {
+		if activity.Type == "Delete" {
+			// Servers announce deleted accounts to everyone they know of,
+			// signed with keys that are gone by the time they arrive.
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		slog.Warn("verify activity", "actor", activity.Actor, "type", activity.Type, "error", err)
+		http.Error(w, "Signature does not verify", http.StatusUnauthorized)
+		return
+	}
+	if signer != activity.Actor {
+		http.Error(w, "Activity is not signed by its actor", http.StatusUnauthorized)
+		return
+	}
+	switch id, typ := activity.ObjectRef(); {
+	case activity.Type == "Follow" && id == actorID(base):
+		if err := s.acceptFollow(ctx, client, base, activity); err != nil {
+			slog.Warn("accept follow", "actor", activity.Actor, "error", err)
+			http.Error(w, "Could not fetch the follower", http.StatusBadGateway)
+			return
+		}
+	case activity.Type == "Undo" && typ == "Follow":
+		if err := dbgen.New(s.DB).DeleteFollower(ctx, activity.Actor); err != nil {
+			slog.Error("delete follower", "actor", activity.Actor, "error", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// acceptFollow adds the actor of follow to the blog's followers and queues
+// an Accept of it to their inbox.
+func (s *Server) acceptFollow(ctx context.Context, client *activitypub.Client, base string, follow activitypub.Activity) error {
+	actor, err := client.FetchActor(ctx, follow.Actor)
+	if err != nil {
+		return err
+	}
+	err = dbgen.New(s.DB).UpsertFollower(ctx, dbgen.UpsertFollowerParams{
+		Actor:       actor.ID,
+		Inbox:       actor.Inbox,
+		SharedInbox: actor.SharedInbox(),
+	})
+	if err != nil {
+		return err
+	}
+	s.queueActivity(ctx, actor.Inbox, activitypub.Activity{
+		Context: activitypub.Context,
+		ID:      actorID(base) + "#accepts/" + rand.Text(),
+		Type:    "Accept",
+		Actor:   actorID(base),
+		Object: activitypub.Activity{
+			ID:     follow.ID,
+			Type:   follow.Type,
+			Actor:  follow.Actor,
+			Object: actorID(base),
+		},
+	})
+	s.requestFederation()
+	return nil
+}
+
+// federatePost is a publish hook that delivers a post, once it is first
+// published, to the blog's followers, to each of their servers once.
+func (s *Server) federatePost(ctx context.Context, base string, p dbgen.Post) error {
+	inboxes, err := dbgen.New(s.DB).ListFollowerInboxes(ctx)
+	if err != nil || len(inboxes) == 0 {
+		return err
+	}
+	create := s.createActivity(ctx, base, p)
+	for _, inbox := range inboxes {
+		s.queueActivity(ctx, inbox, create)
+	}
+	s.requestFederation()
+	return nil
+}
+
+// queueActivity queues activity for delivery to inbox.
+func (s *Server) queueActivity(ctx context.Context, inbox string, activity activitypub.Activity) {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		slog.Error("encode activity", "error", err)
+		return
+	}
+	now := time.Now().UTC()
+	err = dbgen.New(s.DB).CreateActivityDelivery(ctx, dbgen.CreateActivityDeliveryParams{
+		Inbox:         inbox,
+		Activity:      string(body),
+		NextAttemptAt: &now,
+	})
+	if err != nil {
+		slog.Error("queue activity", "inbox", inbox, "error", err)
+	}
+}
+
+// federationLoop delivers queued activities every federationInterval and
+// whenever one is queued.
+func (s *Server) federationLoop(ctx context.Context) {
+	ticker := time.NewTicker(federationInterval)
+	defer ticker.Stop()
+	for {
+		s.deliverActivities(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.federation:
+		}
+	}
+}
+
+// requestFederation wakes federationLoop without waiting for it.
+func (s *Server) requestFederation() {
+	select {
+	case s.federation <- struct{}{}:
+	default:
+	}
+}
+
+// deliverActivities delivers the queued activities that are due, signed as
+// the blog's actor, scheduling a retry of each that fails until
+// federationRetries are used up. Deliveries done with for longer than
+// federationLogAge are then deleted. Nothing is delivered while the site
+// URL is unset, as the blog's actor has no address to sign as.
+func (s *Server) deliverActivities(ctx context.Context) {
+	base := s.setting(ctx, settingSiteURL)
+	if base == "" {
+		return
+	}
+	q := dbgen.New(s.DB)
+	pending, err := q.ListPendingActivityDeliveries(ctx)
+	if err != nil {
+		slog.Error("list pending activity deliveries", "error", err)
+		return
+	}
+	var client *activitypub.Client
+	for _, d := range pending {
+		if d.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		if client == nil {
+			if client, err = s.activityClient(ctx, base); err != nil {
+				slog.Error("load activitypub key", "error", err)
+				return
+			}
+		}
+		status, err := client.Deliver(ctx, d.Inbox, []byte(d.Activity))
+		now := time.Now().UTC()
+		params := dbgen.RecordActivityDeliveryAttemptParams{StatusCode: int64(status), ID: d.ID}
+		if err == nil {
+			params.DeliveredAt = &now
+		} else {
+			slog.Warn("deliver activity", "inbox", d.Inbox, "attempt", d.Attempts+1, "error", err)
+			params.Error = err.Error()
+			// Inboxes that are gone, or refuse the delivery outright, will
+			// not take it later either.
+			refused := status >= 400 && status < 500 && status != http.StatusTooManyRequests
+			if !refused && int(d.Attempts) < len(federationRetries) {
+				next := now.Add(federationRetries[d.Attempts])
+				params.NextAttemptAt = &next
+			}
+		}
+		if err := q.RecordActivityDeliveryAttempt(ctx, params); err != nil {
+			slog.Error("record activity delivery", "error", err)
+		}
+	}
+	before := time.Now().UTC().Add(-federationLogAge).Format(time.DateTime)
+	if err := q.PruneActivityDeliveries(ctx, before); err != nil {
+		slog.Error("prune activity deliveries", "error", err)
+	}
+}
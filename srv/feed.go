@@ -0,0 +1,117 @@
+package srv
+
+import (
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// feedSize is the number of most recent posts included in feeds.
+const feedSize = 20
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   atomAuthor  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string   `xml:"title"`
+	Link      atomLink `xml:"link"`
+	ID        string   `xml:"id"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+	Summary   atomText `xml:"summary"`
+	Content   atomText `xml:"content"`
+}
+
+func (s *Server) HandleAtom(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	posts, err := q.GetPublishedPosts(r.Context())
+	if err != nil {
+		slog.Error("get posts", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if len(posts) > feedSize {
+		posts = posts[:feedSize]
+	}
+
+	base := s.baseURL(r)
+	feed := atomFeed{
+		Title:    siteTitle,
+		Subtitle: siteTagline,
+		ID:       base + "/",
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + "/atom.xml"},
+			{Rel: "alternate", Type: "text/html", Href: base + "/"},
+		},
+		Author: atomAuthor{Name: siteTitle},
+	}
+	if hub := s.setting(r.Context(), settingWebSubHub); hub != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "hub", Href: hub})
+	}
+
+	var updated time.Time
+	for _, p := range posts {
+		if p.UpdatedAt.After(updated) {
+			updated = p.UpdatedAt
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:     p.Title,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: base + "/post/" + p.Slug},
+			ID:        entryID(base, p),
+			Published: p.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   p.UpdatedAt.UTC().Format(time.RFC3339),
+			Summary:   atomText{Body: postExcerpt(p)},
+			Content:   atomText{Type: "html", Body: string(s.renderPost(r.Context(), p).html)},
+		})
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		slog.Error("encode atom feed", "error", err)
+	}
+}
+
+// entryID returns a tag URI (RFC 4151) for p. It depends only on the
+// site's host, the post's slug and its creation date, so it stays the same
+// when the post is edited.
+func entryID(base string, p dbgen.Post) string {
+	host := base
+	if u, err := url.Parse(base); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return "tag:" + host + "," + p.CreatedAt.UTC().Format("2006-01-02") + ":/post/" + p.Slug
+}
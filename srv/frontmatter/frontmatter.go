@@ -0,0 +1,277 @@
+// Package frontmatter reads and writes the YAML front matter at the top of
+// Markdown files, as static site generators and editors write it.
+//
+// Only the part of YAML that front matter uses is understood: one key and
+// value per line, where a value is a plain, single- or double-quoted
+// string, true or false, or a list of strings written as [a, b] or as
+// lines starting with "- ". Anything else is an error rather than a
+// guess.
+package frontmatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse splits src into its front matter, between "---" lines at the very
+// start, and the body after it. Values are strings, bools or []strings;
+// numbers and dates are left as strings for the caller to read. A src
+// without front matter is all body.
+func Parse(src string) (map[string]any, string, error) {
+	src = strings.TrimPrefix(strings.ReplaceAll(src, "\r\n", "\n"), "\ufeff")
+	fields := make(map[string]any)
+	if !strings.HasPrefix(src, "---\n") {
+		return fields, src, nil
+	}
+	rest := src[len("---\n"):]
+	var head, body string
+	for offset := 0; ; {
+		end := strings.Index(rest[offset:], "\n")
+		line := rest[offset:]
+		if end >= 0 {
+			line = rest[offset : offset+end]
+		}
+		if line == "---" || line == "..." {
+			head = rest[:offset]
+			if end >= 0 {
+				body = rest[offset+end+1:]
+			}
+			break
+		}
+		if end < 0 {
+			return nil, "", fmt.Errorf("front matter is not closed with ---")
+		}
+		offset += end + 1
+	}
+
+	var list string // the key whose list items are being read
+	for i, line := range strings.Split(head, "\n") {
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("front matter line %d: %s", i+2, fmt.Sprintf(format, args...))
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "-"); ok && (item == "" || item[0] == ' ') {
+			if list == "" {
+				return nil, "", fail("list item without a key")
+			}
+			v, err := scalar(strings.TrimSpace(item))
+			if err != nil {
+				return nil, "", fail("%v", err)
+			}
+			s, ok := v.(string)
+			if !ok {
+				s = strconv.FormatBool(v.(bool))
+			}
+			items, _ := fields[list].([]string)
+			fields[list] = append(items, s)
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, "", fail("nested values are not supported")
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || key == "" || strings.ContainsAny(key, " \t\"'") || value != "" && value[0] != ' ' {
+			return nil, "", fail("expected key: value")
+		}
+		value = strings.TrimSpace(value)
+		list = ""
+		if _, seen := fields[key]; seen {
+			return nil, "", fail("%s is given twice", key)
+		}
+		switch {
+		case value == "" || strings.HasPrefix(value, "#"):
+			// Either a list follows, or the value is empty.
+			list = key
+			fields[key] = ""
+		case value[0] == '[':
+			items, err := flowList(value)
+			if err != nil {
+				return nil, "", fail("%v", err)
+			}
+			fields[key] = items
+		case value[0] == '|' || value[0] == '>':
+			return nil, "", fail("block scalars are not supported")
+		case value[0] == '{':
+			return nil, "", fail("nested values are not supported")
+		default:
+			v, err := scalar(value)
+			if err != nil {
+				return nil, "", fail("%v", err)
+			}
+			fields[key] = v
+		}
+	}
+	return fields, body, nil
+}
+
+// scalar reads a single value: a quoted string, true or false, or a plain
+// string, which ends at a comment.
+func scalar(v string) (any, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := closingQuote(v)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string %s", v)
+		}
+		s, err := strconv.Unquote(v[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", v[:end+1])
+		}
+		return s, trailing(v[end+1:])
+	case strings.HasPrefix(v, "'"):
+		end := closingQuote(v)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string %s", v)
+		}
+		return strings.ReplaceAll(v[1:end], "''", "'"), trailing(v[end+1:])
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	switch v {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	return v, nil
+}
+
+// closingQuote returns the index of the quote that ends the string at the
+// start of v, or -1. Double-quoted strings escape with a backslash and
+// single-quoted ones by doubling the quote.
+func closingQuote(v string) int {
+	quote := v[0]
+	for i := 1; i < len(v); i++ {
+		switch {
+		case quote == '"' && v[i] == '\\':
+			i++
+		case v[i] == quote && quote == '\'' && i+1 < len(v) && v[i+1] == '\'':
+			i++
+		case v[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// trailing checks that only a comment follows a quoted string.
+func trailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %s after string", rest)
+	}
+	return nil
+}
+
+// flowList reads a list written as [a, "b", 'c'].
+func flowList(v string) ([]string, error) {
+	end := strings.LastIndex(v, "]")
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated list %s", v)
+	}
+	if err := trailing(v[end+1:]); err != nil {
+		return nil, err
+	}
+	inner := strings.TrimSpace(v[1:end])
+	items := []string{}
+	for inner != "" {
+		var item string
+		if inner[0] == '"' || inner[0] == '\'' {
+			close := closingQuote(inner)
+			if close < 0 {
+				return nil, fmt.Errorf("unterminated string %s", inner)
+			}
+			item, inner = inner[:close+1], strings.TrimSpace(inner[close+1:])
+		} else {
+			i := strings.Index(inner, ",")
+			if i < 0 {
+				i = len(inner)
+			}
+			item, inner = strings.TrimSpace(inner[:i]), inner[i:]
+		}
+		if strings.ContainsAny(item, "[]{}") && item[0] != '"' && item[0] != '\'' {
+			return nil, fmt.Errorf("nested values are not supported")
+		}
+		if rest, ok := strings.CutPrefix(inner, ","); ok {
+			inner = strings.TrimSpace(rest)
+		} else if inner != "" {
+			return nil, fmt.Errorf("expected , in list")
+		}
+		value, err := scalar(item)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := value.(string); ok {
+			items = append(items, s)
+		} else {
+			items = append(items, strconv.FormatBool(value.(bool)))
+		}
+	}
+	return items, nil
+}
+
+// Field is a key and value of front matter to write. The value is a
+// string, bool or []string.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Format writes fields as front matter, in order, followed by a blank line
+// and body. Strings are quoted where YAML would otherwise read them as
+// something else.
+func Format(fields []Field, body string) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	for _, f := range fields {
+		sb.WriteString(f.Key + ":")
+		switch v := f.Value.(type) {
+		case string:
+			sb.WriteString(" " + quote(v, false))
+		case bool:
+			sb.WriteString(" " + strconv.FormatBool(v))
+		case []string:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = quote(item, true)
+			}
+			sb.WriteString(" [" + strings.Join(items, ", ") + "]")
+		default:
+			panic(fmt.Sprintf("frontmatter: cannot write %T", f.Value))
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("---\n\n")
+	sb.WriteString(body)
+	return sb.String()
+}
+
+// quote returns v as a plain string if it would be read back as one, and
+// otherwise double-quoted. In lists, commas and brackets need quoting too.
+func quote(v string, inList bool) string {
+	plain := v != "" && v == strings.TrimSpace(v) &&
+		!strings.ContainsAny(v[:1], "-?:,[]{}#&*!|>'\"%@`") &&
+		!strings.Contains(v, ": ") && !strings.Contains(v, " #") && !strings.HasSuffix(v, ":") &&
+		!(inList && strings.ContainsAny(v, ",[]{}"))
+	for _, r := range v {
+		if r < ' ' || r == 0x7f {
+			plain = false
+		}
+	}
+	switch strings.ToLower(v) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		plain = false
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		plain = false
+	}
+	if plain {
+		return v
+	}
+	return strconv.Quote(v)
+}
@@ -0,0 +1,101 @@
+package frontmatter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src    string
+		fields map[string]any
+		body   string
+	}{
+		{"Just a body.\n", map[string]any{}, "Just a body.\n"},
+		{
+			"---\ntitle: Hello: World # greeting\npublished: true\ndate: 2024-05-01\n---\nBody.\n",
+			map[string]any{"title": "Hello: World", "published": true, "date": "2024-05-01"},
+			"Body.\n",
+		},
+		{
+			"\ufeff---\r\ntitle: \"Say \\\"hi\\\"\"\r\nslug: 'it''s'\r\n---\r\n\r\nBody.\r\n",
+			map[string]any{"title": `Say "hi"`, "slug": "it's"},
+			"\nBody.\n",
+		},
+		{
+			"---\ntags: [go, \"new, york\", 'a']\nempty: []\n---\n",
+			map[string]any{"tags": []string{"go", "new, york", "a"}, "empty": []string{}},
+			"",
+		},
+		{
+			"---\n# comment\ntags:\n  - go\n  - \"wiki\"\n- true\ndescription:\n...\nBody",
+			map[string]any{"tags": []string{"go", "wiki", "true"}, "description": ""},
+			"Body",
+		},
+		{"---\n---\n", map[string]any{}, ""},
+	}
+	for _, tt := range tests {
+		fields, body, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(fields, tt.fields) || body != tt.body {
+			t.Errorf("Parse(%q) = %#v, %q, expected %#v, %q", tt.src, fields, body, tt.fields, tt.body)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"---\ntitle: open\n",
+		"---\n- orphan\n---\n",
+		"---\nauthor:\n  name: Ann\n---\n",
+		"---\ntitle: one\ntitle: two\n---\n",
+		"---\ntitle: \"open\n---\n",
+		"---\ntitle: \"done\" extra\n---\n",
+		"---\ndescription: |\n  text\n---\n",
+		"---\ntags: [a, [b]]\n---\n",
+		"---\nno value here\n---\n",
+	} {
+		if _, _, err := Parse(src); err == nil {
+			t.Errorf("expected Parse(%q) to fail", src)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	fields := []Field{
+		{"title", `Say "hi": a #1 guide`},
+		{"slug", "say-hi"},
+		{"date", "2024-05-01T09:30:00Z"},
+		{"published", false},
+		{"tags", []string{"go", "new, york", "2024"}},
+		{"description", "yes"},
+	}
+	expected := "---\n" +
+		"title: \"Say \\\"hi\\\": a #1 guide\"\n" +
+		"slug: say-hi\n" +
+		"date: 2024-05-01T09:30:00Z\n" +
+		"published: false\n" +
+		"tags: [go, \"new, york\", \"2024\"]\n" +
+		"description: \"yes\"\n" +
+		"---\n\nBody.\n"
+	src := Format(fields, "Body.\n")
+	if src != expected {
+		t.Errorf("Format = %q, expected %q", src, expected)
+	}
+
+	parsed, body, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fields {
+		if !reflect.DeepEqual(parsed[f.Key], f.Value) {
+			t.Errorf("%s read back as %#v, expected %#v", f.Key, parsed[f.Key], f.Value)
+		}
+	}
+	if body != "\nBody.\n" {
+		t.Errorf("body read back as %q", body)
+	}
+}
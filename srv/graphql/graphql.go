@@ -0,0 +1,835 @@
+// Package graphql answers GraphQL queries against a schema whose fields
+// are resolved by Go functions.
+//
+// Only what a read-only API needs is supported: queries with variables,
+// aliases, fragments and the @skip and @include directives, over object,
+// list and scalar types. There are no mutations or subscriptions, no
+// interfaces, unions, enums or input objects, and no introspection but
+// __typename; Schema.String prints the schema for client tooling instead.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a field or argument: a *Scalar, an *Object, or a
+// List or NonNull of another type.
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type.
+type Scalar struct {
+	Name        string
+	Description string
+	// Serialize converts a resolved value to what is written as JSON.
+	Serialize func(v any) (any, error)
+	// Parse converts an argument value, as written in the query or given
+	// as a JSON variable, to what resolvers are passed.
+	Parse func(v any) (any, error)
+}
+
+func (s *Scalar) String() string {
+	return s.Name
+}
+
+// The built-in scalars. Int arguments are passed to resolvers as int,
+// Float as float64 and ID as string.
+var (
+	Int = &Scalar{
+		Name: "Int",
+		Serialize: func(v any) (any, error) {
+			switch n := v.(type) {
+			case int:
+				return n, nil
+			case int32:
+				return n, nil
+			case int64:
+				return n, nil
+			}
+			return nil, fmt.Errorf("Int cannot represent %T", v)
+		},
+		Parse: func(v any) (any, error) {
+			switch n := v.(type) {
+			case int64:
+				if n >= math.MinInt32 && n <= math.MaxInt32 {
+					return int(n), nil
+				}
+			case float64:
+				if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+					return int(n), nil
+				}
+			}
+			return nil, fmt.Errorf("Int cannot represent %s", describe(v))
+		},
+	}
+	Float = &Scalar{
+		Name: "Float",
+		Serialize: func(v any) (any, error) {
+			switch n := v.(type) {
+			case float64:
+				return n, nil
+			case float32:
+				return n, nil
+			case int, int32, int64:
+				return n, nil
+			}
+			return nil, fmt.Errorf("Float cannot represent %T", v)
+		},
+		Parse: func(v any) (any, error) {
+			switch n := v.(type) {
+			case float64:
+				return n, nil
+			case int64:
+				return float64(n), nil
+			}
+			return nil, fmt.Errorf("Float cannot represent %s", describe(v))
+		},
+	}
+	String = &Scalar{
+		Name: "String",
+		Serialize: func(v any) (any, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent %T", v)
+		},
+		Parse: func(v any) (any, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent %s", describe(v))
+		},
+	}
+	Boolean = &Scalar{
+		Name: "Boolean",
+		Serialize: func(v any) (any, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %T", v)
+		},
+		Parse: func(v any) (any, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %s", describe(v))
+		},
+	}
+	ID = &Scalar{
+		Name: "ID",
+		Serialize: func(v any) (any, error) {
+			switch id := v.(type) {
+			case string:
+				return id, nil
+			case int:
+				return strconv.Itoa(id), nil
+			case int64:
+				return strconv.FormatInt(id, 10), nil
+			}
+			return nil, fmt.Errorf("ID cannot represent %T", v)
+		},
+		Parse: func(v any) (any, error) {
+			switch id := v.(type) {
+			case string:
+				return id, nil
+			case int64:
+				return strconv.FormatInt(id, 10), nil
+			case float64:
+				if id == math.Trunc(id) {
+					return strconv.FormatFloat(id, 'f', -1, 64), nil
+				}
+			}
+			return nil, fmt.Errorf("ID cannot represent %s", describe(v))
+		},
+	}
+)
+
+var builtinScalars = []*Scalar{Int, Float, String, Boolean, ID}
+
+// describe returns v as it would be written in a query, for errors.
+func describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case enumValue:
+		return string(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// Object is a type with fields.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+func (o *Object) String() string {
+	return o.Name
+}
+
+// field returns the field with the given name, or nil if there is none.
+func (o *Object) field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Field is a field of an object type.
+type Field struct {
+	Name        string
+	Description string
+	Args        []*Arg
+	Type        Type
+	// Resolve returns the value of the field of source, the value of the
+	// object the field is on, given the field's arguments by name. If it
+	// is nil, the value is the entry of source if it is a map, or the
+	// field of source if it is a struct, whose name matches, ignoring
+	// case.
+	Resolve func(ctx context.Context, source any, args map[string]any) (any, error)
+}
+
+// Arg is an argument of a field.
+type Arg struct {
+	Name        string
+	Description string
+	Type        Type
+	// Default is the value a resolver is passed if the argument is left
+	// out, or nil for none.
+	Default any
+}
+
+// List is a list of values of another type.
+type List struct {
+	Of Type
+}
+
+func (l List) String() string {
+	return "[" + l.Of.String() + "]"
+}
+
+// NonNull is another type whose values cannot be null.
+type NonNull struct {
+	Of Type
+}
+
+func (n NonNull) String() string {
+	return n.Of.String() + "!"
+}
+
+// named returns t without any List or NonNull around it.
+func named(t Type) Type {
+	for {
+		switch u := t.(type) {
+		case List:
+			t = u.Of
+		case NonNull:
+			t = u.Of
+		default:
+			return t
+		}
+	}
+}
+
+// Schema is the types a query can ask about.
+type Schema struct {
+	Query *Object
+	// MaxDepth is how deeply selections can nest, or 0 for no limit.
+	MaxDepth int
+}
+
+// String returns the schema in the GraphQL schema definition language.
+func (s *Schema) String() string {
+	var b strings.Builder
+	objects := []*Object{s.Query}
+	var scalars []*Scalar
+	seen := map[string]bool{s.Query.Name: true}
+	for _, scalar := range builtinScalars {
+		seen[scalar.Name] = true
+	}
+	add := func(t Type) {
+		switch t := named(t).(type) {
+		case *Object:
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				objects = append(objects, t)
+			}
+		case *Scalar:
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				scalars = append(scalars, t)
+			}
+		}
+	}
+	for i := 0; i < len(objects); i++ {
+		for _, f := range objects[i].Fields {
+			add(f.Type)
+			for _, a := range f.Args {
+				add(a.Type)
+			}
+		}
+	}
+
+	for _, scalar := range scalars {
+		writeDescription(&b, "", scalar.Description)
+		fmt.Fprintf(&b, "scalar %s\n\n", scalar.Name)
+	}
+	for i, o := range objects {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeDescription(&b, "", o.Description)
+		fmt.Fprintf(&b, "type %s {\n", o.Name)
+		for _, f := range o.Fields {
+			writeDescription(&b, "  ", f.Description)
+			b.WriteString("  " + f.Name)
+			if len(f.Args) > 0 {
+				args := make([]string, len(f.Args))
+				for j, a := range f.Args {
+					args[j] = a.Name + ": " + a.Type.String()
+					if a.Default != nil {
+						args[j] += " = " + describe(a.Default)
+					}
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.Type.String() + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, indent, description string) {
+	if description != "" {
+		b.WriteString(indent + strconv.Quote(description) + "\n")
+	}
+}
+
+// Request is a GraphQL request, as sent in the body of a POST.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is left out if the request
+// could not be run at all.
+type Response struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is an error running a request. Path leads to the field that
+// failed, if it was a field.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// Execute runs a query against the schema.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	fail := func(format string, args ...any) *Response {
+		return &Response{Errors: []Error{{Message: fmt.Sprintf(format, args...)}}}
+	}
+	doc, err := parse(req.Query)
+	if err != nil {
+		return fail("%v", err)
+	}
+	var op *operation
+	for _, o := range doc.operations {
+		if o.name == req.OperationName || req.OperationName == "" && len(doc.operations) == 1 {
+			op = o
+		}
+	}
+	switch {
+	case op == nil && req.OperationName == "":
+		return fail("Must provide operation name if query contains multiple operations.")
+	case op == nil:
+		return fail("Unknown operation named %q.", req.OperationName)
+	case op.kind != "query":
+		return fail("Only queries are supported, not %ss.", op.kind)
+	}
+	if errs := s.validate(doc, op); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	vars := map[string]any{}
+	for _, v := range op.vars {
+		val, ok := req.Variables[v.name]
+		switch {
+		case ok:
+			vars[v.name] = val
+		case v.hasDefault:
+			vars[v.name] = constant(v.def)
+		case v.nonNull:
+			return fail("Variable \"$%s\" of required type %q was not provided.", v.name, v.typ)
+		}
+	}
+
+	e := &executor{ctx: ctx, doc: doc, vars: vars}
+	data, ok := e.selectObject(s.Query, nil, op.selections, nil)
+	resp := &Response{Errors: e.errors}
+	if ok {
+		resp.Data = data
+	} else {
+		resp.Data = json.RawMessage("null")
+	}
+	return resp
+}
+
+// validator checks a query against the schema before it is run.
+type validator struct {
+	schema   *Schema
+	doc      *document
+	vars     map[string]bool
+	errors   []Error
+	tooDeep  bool
+	visiting map[string]bool // fragments being checked
+}
+
+func (s *Schema) validate(doc *document, op *operation) []Error {
+	v := &validator{schema: s, doc: doc, vars: map[string]bool{}, visiting: map[string]bool{}}
+	for _, d := range op.vars {
+		v.vars[d.name] = true
+	}
+	v.selections(s.Query, op.selections, 1)
+	return v.errors
+}
+
+func (v *validator) errorf(format string, args ...any) {
+	v.errors = append(v.errors, Error{Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) selections(t *Object, sels []selection, depth int) {
+	if v.schema.MaxDepth > 0 && depth > v.schema.MaxDepth {
+		if !v.tooDeep {
+			v.tooDeep = true
+			v.errorf("The query is nested more than %d levels deep.", v.schema.MaxDepth)
+		}
+		return
+	}
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *field:
+			v.field(t, sel, depth)
+		case *fragmentSpread:
+			v.directives(sel.directives)
+			f := v.doc.fragments[sel.name]
+			switch {
+			case f == nil:
+				v.errorf("Unknown fragment %q.", sel.name)
+			case v.visiting[f.name]:
+				v.errorf("Cannot spread fragment %q within itself.", f.name)
+			case f.typeCond != t.Name:
+				v.errorf("Fragment %q cannot be spread here as objects of type %q can never be of type %q.", f.name, t.Name, f.typeCond)
+			default:
+				v.visiting[f.name] = true
+				v.selections(t, f.selections, depth)
+				delete(v.visiting, f.name)
+			}
+		case *inlineFragment:
+			v.directives(sel.directives)
+			if sel.typeCond != "" && sel.typeCond != t.Name {
+				v.errorf("Fragment cannot be spread here as objects of type %q can never be of type %q.", t.Name, sel.typeCond)
+				continue
+			}
+			v.selections(t, sel.selections, depth)
+		}
+	}
+}
+
+func (v *validator) field(t *Object, f *field, depth int) {
+	v.directives(f.directives)
+	if f.name == "__typename" {
+		if f.selections != nil {
+			v.errorf("Field \"__typename\" must not have a selection since type \"String!\" has no subfields.")
+		}
+		return
+	}
+	def := t.field(f.name)
+	if def == nil {
+		v.errorf("Cannot query field %q on type %q.", f.name, t.Name)
+		return
+	}
+	for _, a := range f.args {
+		if !slices.ContainsFunc(def.Args, func(d *Arg) bool { return d.Name == a.name }) {
+			v.errorf("Unknown argument %q on field \"%s.%s\".", a.name, t.Name, f.name)
+		}
+		v.value(a.value)
+	}
+	for _, d := range def.Args {
+		_, required := d.Type.(NonNull)
+		given := slices.ContainsFunc(f.args, func(a *argument) bool { return a.name == d.Name })
+		if required && d.Default == nil && !given {
+			v.errorf("Field \"%s.%s\" argument %q of type %q is required, but it was not provided.", t.Name, f.name, d.Name, d.Type)
+		}
+	}
+	switch ft := named(def.Type).(type) {
+	case *Object:
+		if f.selections == nil {
+			v.errorf("Field %q of type %q must have a selection of subfields.", f.name, def.Type)
+			return
+		}
+		v.selections(ft, f.selections, depth+1)
+	default:
+		if f.selections != nil {
+			v.errorf("Field %q must not have a selection since type %q has no subfields.", f.name, def.Type)
+		}
+	}
+}
+
+func (v *validator) directives(ds []*directive) {
+	for _, d := range ds {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf("Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		if len(d.args) != 1 || d.args[0].name != "if" {
+			v.errorf("Directive \"@%s\" takes one argument, \"if\".", d.name)
+			continue
+		}
+		v.value(d.args[0].value)
+	}
+}
+
+// value checks that the variables val refers to are defined.
+func (v *validator) value(val value) {
+	switch val := val.(type) {
+	case variable:
+		if !v.vars[string(val)] {
+			v.errorf("Variable \"$%s\" is not defined.", val)
+		}
+	case []value:
+		for _, item := range val {
+			v.value(item)
+		}
+	}
+}
+
+// executor runs a validated query.
+type executor struct {
+	ctx    context.Context
+	doc    *document
+	vars   map[string]any
+	errors []Error
+}
+
+func (e *executor) errorf(path []any, format string, args ...any) {
+	e.errors = append(e.errors, Error{Message: fmt.Sprintf(format, args...), Path: slices.Clone(path)})
+}
+
+// object is a JSON object that keeps its members in the order the query
+// asked for them, as the response must.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// selectObject returns the fields of source, of type t, that sels select.
+// It returns false if a non-null field was null, making the object null
+// too.
+func (e *executor) selectObject(t *Object, source any, sels []selection, path []any) (any, bool) {
+	var keys []string
+	groups := map[string][]*field{}
+	e.collect(t, sels, &keys, groups, map[string]bool{})
+
+	obj := make(object, 0, len(keys))
+	for _, key := range keys {
+		fieldPath := append(path[:len(path):len(path)], key)
+		f := groups[key][0]
+		if f.name == "__typename" {
+			obj = append(obj, member{key, t.Name})
+			continue
+		}
+		v, ok := e.resolve(t.field(f.name), source, groups[key], fieldPath)
+		if !ok {
+			return nil, false
+		}
+		obj = append(obj, member{key, v})
+	}
+	return obj, true
+}
+
+// collect groups the fields that sels select on t by their key in the
+// response, in order, leaving out those skipped by directives.
+func (e *executor) collect(t *Object, sels []selection, keys *[]string, groups map[string][]*field, spread map[string]bool) {
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *field:
+			if !e.included(sel.directives) {
+				continue
+			}
+			key := sel.key()
+			if groups[key] == nil {
+				*keys = append(*keys, key)
+			}
+			groups[key] = append(groups[key], sel)
+		case *fragmentSpread:
+			if spread[sel.name] || !e.included(sel.directives) {
+				continue
+			}
+			spread[sel.name] = true
+			e.collect(t, e.doc.fragments[sel.name].selections, keys, groups, spread)
+		case *inlineFragment:
+			if e.included(sel.directives) {
+				e.collect(t, sel.selections, keys, groups, spread)
+			}
+		}
+	}
+}
+
+// included reports whether a selection with the given directives is
+// included, by @skip and @include.
+func (e *executor) included(ds []*directive) bool {
+	for _, d := range ds {
+		v, _ := e.value(d.args[0].value)
+		if cond, _ := v.(bool); cond == (d.name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// value returns the value of val, substituting variables. It returns false
+// for a variable that was not given.
+func (e *executor) value(val value) (any, bool) {
+	switch val := val.(type) {
+	case variable:
+		v, ok := e.vars[string(val)]
+		return v, ok
+	case []value:
+		list := make([]any, len(val))
+		for i, item := range val {
+			list[i], _ = e.value(item)
+		}
+		return list, true
+	}
+	return val, true
+}
+
+// constant returns the value of val, which has no variables.
+func constant(val value) any {
+	if list, ok := val.([]value); ok {
+		items := make([]any, len(list))
+		for i, item := range list {
+			items[i] = constant(item)
+		}
+		return items
+	}
+	return val
+}
+
+// resolve returns the value of a field of source, as the fields that ask
+// for it select. It returns false if the field is non-null but its value
+// is null.
+func (e *executor) resolve(def *Field, source any, fields []*field, path []any) (any, bool) {
+	_, nonNull := def.Type.(NonNull)
+	args, err := e.args(def, fields[0].args)
+	if err != nil {
+		e.errorf(path, "%v", err)
+		return nil, !nonNull
+	}
+	resolve := def.Resolve
+	if resolve == nil {
+		resolve = func(ctx context.Context, source any, args map[string]any) (any, error) {
+			return fieldOf(source, def.Name)
+		}
+	}
+	v, err := resolve(e.ctx, source, args)
+	if err != nil {
+		e.errorf(path, "%v", err)
+		return nil, !nonNull
+	}
+	var sels []selection
+	for _, f := range fields {
+		sels = append(sels, f.selections...)
+	}
+	return e.complete(def.Type, v, sels, path)
+}
+
+// args returns the arguments of a field, from those given in the query
+// and the defaults of the rest.
+func (e *executor) args(def *Field, given []*argument) (map[string]any, error) {
+	args := map[string]any{}
+	for _, a := range def.Args {
+		var v any
+		ok := false
+		if i := slices.IndexFunc(given, func(g *argument) bool { return g.name == a.Name }); i >= 0 {
+			v, ok = e.value(given[i].value)
+		}
+		if !ok {
+			if a.Default != nil {
+				args[a.Name] = a.Default
+			} else if _, required := a.Type.(NonNull); required {
+				return nil, fmt.Errorf("Argument %q of required type %q was not provided.", a.Name, a.Type)
+			}
+			continue
+		}
+		c, err := coerce(a.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %q has an invalid value: %v.", a.Name, err)
+		}
+		args[a.Name] = c
+	}
+	return args, nil
+}
+
+// coerce converts an argument value to type t.
+func coerce(t Type, v any) (any, error) {
+	if nn, ok := t.(NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected a non-null %s", nn.Of)
+		}
+		return coerce(nn.Of, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case List:
+		items, ok := v.([]any)
+		if !ok {
+			items = []any{v}
+		}
+		list := make([]any, len(items))
+		for i, item := range items {
+			c, err := coerce(t.Of, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = c
+		}
+		return list, nil
+	case *Scalar:
+		return t.Parse(v)
+	}
+	return nil, fmt.Errorf("%s cannot be an argument", t)
+}
+
+// complete turns the resolved value v of a field of type t into what the
+// response holds. It returns false if t is non-null but v is null.
+func (e *executor) complete(t Type, v any, sels []selection, path []any) (any, bool) {
+	if nn, ok := t.(NonNull); ok {
+		v, ok := e.completeNullable(nn.Of, v, sels, path)
+		if ok && v == nil {
+			e.errorf(path, "Cannot return null for non-nullable field.")
+		}
+		return v, ok && v != nil
+	}
+	v, ok := e.completeNullable(t, v, sels, path)
+	if !ok {
+		// The error has been reported where the null was found; it
+		// stops here, where nulls are allowed.
+		return nil, true
+	}
+	return v, true
+}
+
+// completeNullable is complete for a type that is not NonNull. It returns
+// false if a non-null value inside v was null.
+func (e *executor) completeNullable(t Type, v any, sels []selection, path []any) (any, bool) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, true
+	}
+	switch t := t.(type) {
+	case List:
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.errorf(path, "Expected a list, but got %T.", v)
+			return nil, false
+		}
+		list := make([]any, rv.Len())
+		for i := range list {
+			item, ok := e.complete(t.Of, rv.Index(i).Interface(), sels, append(path[:len(path):len(path)], i))
+			if !ok {
+				return nil, false
+			}
+			list[i] = item
+		}
+		return list, true
+	case *Object:
+		return e.selectObject(t, v, sels, path)
+	case *Scalar:
+		for rv.Kind() == reflect.Pointer {
+			rv = rv.Elem()
+		}
+		out, err := t.Serialize(rv.Interface())
+		if err != nil {
+			e.errorf(path, "%v", err)
+			return nil, false
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// fieldOf returns the map entry or struct field of source named name,
+// ignoring case.
+func fieldOf(source any, name string) (any, error) {
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			if v := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key())); v.IsValid() {
+				return v.Interface(), nil
+			}
+			return nil, nil
+		}
+	case reflect.Struct:
+		f := rv.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		if f.IsValid() && f.CanInterface() {
+			return f.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("%T has no field %s", source, name)
+}
@@ -0,0 +1,228 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type book struct {
+	ID      int64
+	Title   string
+	Author  *author
+	Subject *string
+}
+
+type author struct {
+	Name  string
+	Books []book
+}
+
+func testSchema() *Schema {
+	history := "history"
+	ann := &author{Name: "Ann"}
+	books := []book{
+		{ID: 1, Title: "First", Author: ann, Subject: &history},
+		{ID: 2, Title: "Second", Author: ann},
+	}
+	ann.Books = books
+
+	bookType := &Object{Name: "Book", Description: "A book."}
+	authorType := &Object{Name: "Author"}
+	bookType.Fields = []*Field{
+		{Name: "id", Type: NonNull{ID}},
+		{Name: "title", Type: NonNull{String}},
+		{Name: "subject", Type: String},
+		{Name: "author", Type: NonNull{authorType}},
+		{
+			Name: "broken",
+			Type: NonNull{String},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return nil, errors.New("it broke")
+			},
+		},
+	}
+	authorType.Fields = []*Field{
+		{Name: "name", Type: NonNull{String}},
+		{Name: "books", Type: NonNull{List{NonNull{bookType}}}},
+	}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{
+			Name: "books",
+			Args: []*Arg{{Name: "first", Type: Int, Default: 10}},
+			Type: NonNull{List{NonNull{bookType}}},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return books[:min(args["first"].(int), len(books))], nil
+			},
+		},
+		{
+			Name: "book",
+			Args: []*Arg{{Name: "id", Type: NonNull{ID}}},
+			Type: bookType,
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				for _, b := range books {
+					if args["id"] == strconv.FormatInt(b.ID, 10) {
+						return b, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		{
+			Name: "echo",
+			Args: []*Arg{{Name: "words", Type: List{NonNull{String}}}},
+			Type: List{String},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return args["words"], nil
+			},
+		},
+	}}
+	return &Schema{Query: query, MaxDepth: 4}
+}
+
+func run(t *testing.T, req Request) string {
+	t.Helper()
+	b, err := json.Marshal(testSchema().Execute(context.Background(), req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      Request
+		expected string
+	}{
+		{
+			"fields in query order",
+			Request{Query: `{ books { title id } }`},
+			`{"data":{"books":[{"title":"First","id":"1"},{"title":"Second","id":"2"}]}}`,
+		},
+		{
+			"arguments, aliases and nulls",
+			Request{Query: `query { one: books(first: 1) { subject } two: book(id: 2) { subject author { name } } none: book(id: 9) { title } }`},
+			`{"data":{"one":[{"subject":"history"}],"two":{"subject":null,"author":{"name":"Ann"}},"none":null}}`,
+		},
+		{
+			"variables and defaults",
+			Request{Query: `query Q($n: Int = 2, $id: ID!) { books(first: $n) { id } book(id: $id) { title } }`, Variables: map[string]any{"id": 1.0}},
+			`{"data":{"books":[{"id":"1"},{"id":"2"}],"book":{"title":"First"}}}`,
+		},
+		{
+			"fragments, directives and __typename",
+			Request{
+				Query:     `query($yes: Boolean!) { book(id: "1") { ...parts ... on Book { id @skip(if: $yes) } __typename } } fragment parts on Book { title subject @include(if: $yes) }`,
+				Variables: map[string]any{"yes": true},
+			},
+			`{"data":{"book":{"title":"First","subject":"history","__typename":"Book"}}}`,
+		},
+		{
+			"merged selections",
+			Request{Query: `{ book(id: "1") { author { name } author { books { id } } } }`},
+			`{"data":{"book":{"author":{"name":"Ann","books":[{"id":"1"},{"id":"2"}]}}}}`,
+		},
+		{
+			"lists from single values",
+			Request{Query: `{ a: echo(words: ["x", "y"]) b: echo(words: "z") c: echo }`},
+			`{"data":{"a":["x","y"],"b":["z"],"c":null}}`,
+		},
+		{
+			"an error nulls the nearest nullable field",
+			Request{Query: `{ book(id: "1") { title broken } }`},
+			`{"data":{"book":null},"errors":[{"message":"it broke","path":["book","broken"]}]}`,
+		},
+		{
+			"an error in a non-null list nulls the data",
+			Request{Query: `{ books(first: 1) { broken } }`},
+			`{"data":null,"errors":[{"message":"it broke","path":["books",0,"broken"]}]}`,
+		},
+		{
+			"bad argument",
+			Request{Query: `{ books(first: "two") { id } }`},
+			`{"data":null,"errors":[{"message":"Argument \"first\" has an invalid value: Int cannot represent \"two\".","path":["books"]}]}`,
+		},
+		{
+			"named operation",
+			Request{Query: `query A { books { id } } query B { book(id: 2) { id } }`, OperationName: "B"},
+			`{"data":{"book":{"id":"2"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		if result := run(t, tt.req); result != tt.expected {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, result, tt.expected)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`{ books { id `, "Syntax Error: unexpected end of document at line 1, column 14."},
+		{`{ books(first: {a: 1}) { id } }`, "input objects are not supported"},
+		{`mutation { books { id } }`, "Only queries are supported, not mutations."},
+		{`query A { books { id } } query B { books { id } }`, "Must provide operation name"},
+		{`{ books { isbn } }`, `Cannot query field "isbn" on type "Book".`},
+		{`{ books }`, `Field "books" of type "[Book!]!" must have a selection of subfields.`},
+		{`{ books { title { x } } }`, `Field "title" must not have a selection`},
+		{`{ book { id } }`, `Field "Query.book" argument "id" of type "ID!" is required`},
+		{`{ books(last: 1) { id } }`, `Unknown argument "last" on field "Query.books".`},
+		{`{ books(first: $n) { id } }`, `Variable "$n" is not defined.`},
+		{`query($id: ID!) { book(id: $id) { id } }`, `Variable "$id" of required type "ID!" was not provided.`},
+		{`{ books { ...f } } fragment f on Book { ...f }`, `Cannot spread fragment "f" within itself.`},
+		{`{ books { ...f } } fragment f on Author { name }`, `can never be of type "Author"`},
+		{`{ books { id @defer } }`, `Unknown directive "@defer".`},
+		{`{ books { author { books { author { name } } } } }`, "nested more than 4 levels deep"},
+	}
+	for _, tt := range tests {
+		resp := testSchema().Execute(context.Background(), Request{Query: tt.query})
+		if resp.Data != nil || len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.expected) {
+			t.Errorf("%s: got %+v, expected an error with %q", tt.query, resp, tt.expected)
+		}
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected value
+	}{
+		{`"a\"b\u00e9\n"`, "a\"bé\n"},
+		{"\"\"\"\n    block\n      indented \\\"\"\"\n  \"\"\"", "block\n  indented \"\"\""},
+		{`-12`, int64(-12)},
+		{`1.5e3`, 1500.0},
+		{`null`, nil},
+		{`RED`, enumValue("RED")},
+	}
+	for _, tt := range tests {
+		p := &parser{src: tt.src}
+		p.next()
+		if result := p.value(true); result != tt.expected {
+			t.Errorf("value(%q) = %#v, expected %#v", tt.src, result, tt.expected)
+		}
+	}
+	for _, src := range []string{`01`, `1.`, `1x`, `"open`, `"\q"`} {
+		if _, err := parse("{ f(a: " + src + ") }"); err == nil {
+			t.Errorf("expected %s to fail to parse", src)
+		}
+	}
+}
+
+func TestSchemaString(t *testing.T) {
+	s := testSchema().String()
+	for _, want := range []string{
+		"type Query {\n  books(first: Int = 10): [Book!]!\n  book(id: ID!): Book\n",
+		"\"A book.\"\ntype Book {\n  id: ID!\n",
+		"type Author {\n  name: String!\n  books: [Book!]!\n}\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("expected schema to contain %q, got:\n%s", want, s)
+		}
+	}
+}
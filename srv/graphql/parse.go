@@ -0,0 +1,579 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed GraphQL request document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is a query, mutation or subscription in a document.
+type operation struct {
+	kind       string
+	name       string
+	vars       []*varDef
+	selections []selection
+}
+
+// varDef declares a variable of an operation.
+type varDef struct {
+	name       string
+	typ        string // as written, such as [String!]!
+	nonNull    bool
+	def        value
+	hasDefault bool
+}
+
+// selection is a *field, *fragmentSpread or *inlineFragment.
+type selection any
+
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+}
+
+// key returns the name of f in the response.
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type argument struct {
+	name  string
+	value value
+}
+
+type directive struct {
+	name string
+	args []*argument
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+}
+
+type inlineFragment struct {
+	typeCond   string // or "" for none
+	directives []*directive
+	selections []selection
+}
+
+type fragment struct {
+	name       string
+	typeCond   string
+	selections []selection
+}
+
+// value is a value written in a document: a variable, enumValue, int64,
+// float64, string, bool, []value, or nil for null.
+type value any
+
+// variable is a reference to a variable by name, without the $.
+type variable string
+
+type enumValue string
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	text string // for strings, the value without quotes or escapes
+	pos  int
+}
+
+// syntaxError is the panic value with which the parser gives up.
+type syntaxError struct {
+	msg string
+}
+
+func (e syntaxError) Error() string {
+	return e.msg
+}
+
+type parser struct {
+	src string
+	pos int // of the next token
+	tok token
+}
+
+// parse parses a request document.
+func parse(src string) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, e
+		}
+	}()
+	p := &parser{src: src}
+	p.next()
+	doc = &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.is(tokPunct, "{"):
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: p.selectionSet()})
+		case p.is(tokName, "query"), p.is(tokName, "mutation"), p.is(tokName, "subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.is(tokName, "fragment"):
+			f := p.fragment()
+			if doc.fragments[f.name] != nil {
+				p.errorf("there can be only one fragment named %q", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		p.errorf("the document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) errorf(format string, args ...any) {
+	line, col := 1, 1
+	for _, r := range p.src[:p.tok.pos] {
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	panic(syntaxError{fmt.Sprintf("Syntax Error: %s at line %d, column %d.", fmt.Sprintf(format, args...), line, col)})
+}
+
+func (p *parser) unexpected() {
+	if p.tok.kind == tokEOF {
+		p.errorf("unexpected end of document")
+	}
+	p.errorf("unexpected %q", p.src[p.tok.pos:p.pos])
+}
+
+// is reports whether the current token is of the given kind and text.
+func (p *parser) is(kind tokenKind, text string) bool {
+	return p.tok.kind == kind && p.tok.text == text
+}
+
+// skip moves past the current token if it is the punctuator text, and
+// reports whether it did.
+func (p *parser) skip(text string) bool {
+	if p.is(tokPunct, text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) {
+	if !p.skip(text) {
+		p.unexpected()
+	}
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokName {
+		p.unexpected()
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+func (p *parser) keyword(word string) {
+	if !p.is(tokName, word) {
+		p.unexpected()
+	}
+	p.next()
+}
+
+func (p *parser) operation() *operation {
+	op := &operation{kind: p.name()}
+	if p.tok.kind == tokName {
+		op.name = p.name()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			v := &varDef{name: p.name()}
+			p.expect(":")
+			v.typ = p.typeRef()
+			v.nonNull = strings.HasSuffix(v.typ, "!")
+			if p.skip("=") {
+				v.def, v.hasDefault = p.value(true), true
+			}
+			p.directives()
+			op.vars = append(op.vars, v)
+		}
+	}
+	p.directives()
+	op.selections = p.selectionSet()
+	return op
+}
+
+// typeRef parses a type reference, returning it as written.
+func (p *parser) typeRef() string {
+	var typ string
+	if p.skip("[") {
+		typ = "[" + p.typeRef() + "]"
+		p.expect("]")
+	} else {
+		typ = p.name()
+	}
+	if p.skip("!") {
+		typ += "!"
+	}
+	return typ
+}
+
+func (p *parser) fragment() *fragment {
+	p.keyword("fragment")
+	f := &fragment{name: p.name()}
+	if f.name == "on" {
+		p.errorf("a fragment cannot be named \"on\"")
+	}
+	p.keyword("on")
+	f.typeCond = p.name()
+	p.directives()
+	f.selections = p.selectionSet()
+	return f
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect("{")
+	var sels []selection
+	for !p.skip("}") {
+		sels = append(sels, p.selection())
+	}
+	if len(sels) == 0 {
+		p.errorf("empty selection set")
+	}
+	return sels
+}
+
+func (p *parser) selection() selection {
+	if p.skip("...") {
+		if p.tok.kind == tokName && p.tok.text != "on" {
+			return &fragmentSpread{name: p.name(), directives: p.directives()}
+		}
+		f := &inlineFragment{}
+		if p.is(tokName, "on") {
+			p.next()
+			f.typeCond = p.name()
+		}
+		f.directives = p.directives()
+		f.selections = p.selectionSet()
+		return f
+	}
+	f := &field{name: p.name()}
+	if p.skip(":") {
+		f.alias, f.name = f.name, p.name()
+	}
+	f.args = p.arguments()
+	f.directives = p.directives()
+	if p.is(tokPunct, "{") {
+		f.selections = p.selectionSet()
+	}
+	return f
+}
+
+func (p *parser) arguments() []*argument {
+	var args []*argument
+	if !p.skip("(") {
+		return nil
+	}
+	for !p.skip(")") {
+		a := &argument{name: p.name()}
+		p.expect(":")
+		a.value = p.value(false)
+		args = append(args, a)
+	}
+	return args
+}
+
+func (p *parser) directives() []*directive {
+	var ds []*directive
+	for p.skip("@") {
+		ds = append(ds, &directive{name: p.name(), args: p.arguments()})
+	}
+	return ds
+}
+
+// value parses a value. Constant values, such as variable defaults,
+// cannot refer to variables.
+func (p *parser) value(constant bool) value {
+	tok := p.tok
+	switch tok.kind {
+	case tokPunct:
+		switch tok.text {
+		case "$":
+			if constant {
+				p.unexpected()
+			}
+			p.next()
+			return variable(p.name())
+		case "[":
+			p.next()
+			list := []value{}
+			for !p.skip("]") {
+				list = append(list, p.value(constant))
+			}
+			return list
+		case "{":
+			p.errorf("input objects are not supported")
+		}
+	case tokInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			p.errorf("integer %s out of range", tok.text)
+		}
+		return n
+	case tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			p.errorf("float %s out of range", tok.text)
+		}
+		return f
+	case tokString:
+		p.next()
+		return tok.text
+	case tokName:
+		p.next()
+		switch tok.text {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enumValue(tok.text)
+	}
+	p.unexpected()
+	return nil
+}
+
+// next reads the next token into p.tok.
+func (p *parser) next() {
+	p.skipIgnored()
+	p.tok = token{pos: p.pos}
+	if p.pos >= len(p.src) {
+		p.tok.kind = tokEOF
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok.kind, p.tok.text = tokPunct, "..."
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.text = tokPunct, string(c)
+	case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		end := p.pos + 1
+		for end < len(p.src) && isNameChar(p.src[end]) {
+			end++
+		}
+		p.tok.kind, p.tok.text = tokName, p.src[p.pos:end]
+		p.pos = end
+	case c == '-' || '0' <= c && c <= '9':
+		p.number()
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		p.blockString()
+	case c == '"':
+		p.string()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.errorf("unexpected character %q", r)
+	}
+}
+
+func (p *parser) skipIgnored() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.src[p.pos:], "\ufeff"):
+			p.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func (p *parser) number() {
+	start, end := p.pos, p.pos
+	digits := func() {
+		if end >= len(p.src) || !isDigit(p.src[end]) {
+			p.pos = end
+			p.errorf("invalid number")
+		}
+		for end < len(p.src) && isDigit(p.src[end]) {
+			end++
+		}
+	}
+	if p.src[end] == '-' {
+		end++
+	}
+	if end < len(p.src) && p.src[end] == '0' && end+1 < len(p.src) && isDigit(p.src[end+1]) {
+		p.errorf("invalid number, unexpected digit after 0")
+	}
+	digits()
+	kind := tokInt
+	if end < len(p.src) && p.src[end] == '.' {
+		end++
+		digits()
+		kind = tokFloat
+	}
+	if end < len(p.src) && (p.src[end] == 'e' || p.src[end] == 'E') {
+		end++
+		if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+			end++
+		}
+		digits()
+		kind = tokFloat
+	}
+	if end < len(p.src) && (isNameChar(p.src[end]) || p.src[end] == '.') {
+		p.pos = end
+		p.errorf("invalid number")
+	}
+	p.tok.kind, p.tok.text = kind, p.src[start:end]
+	p.pos = end
+}
+
+func (p *parser) string() {
+	var b strings.Builder
+	i := p.pos + 1
+	for {
+		if i >= len(p.src) || p.src[i] == '\n' || p.src[i] == '\r' {
+			p.errorf("unterminated string")
+		}
+		c := p.src[i]
+		if c == '"' {
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 >= len(p.src) {
+			p.errorf("unterminated string")
+		}
+		switch esc := p.src[i+1]; esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if i+6 > len(p.src) {
+				p.errorf("invalid unicode escape")
+			}
+			n, err := strconv.ParseUint(p.src[i+2:i+6], 16, 32)
+			if err != nil {
+				p.errorf("invalid unicode escape \\u%s", p.src[i+2:i+6])
+			}
+			b.WriteRune(rune(n))
+			i += 4
+		default:
+			p.errorf("invalid escape \\%c", esc)
+		}
+		i += 2
+	}
+	p.tok.kind, p.tok.text = tokString, b.String()
+	p.pos = i + 1
+}
+
+func (p *parser) blockString() {
+	rest := p.src[p.pos+3:]
+	var raw strings.Builder
+	for {
+		i := strings.IndexAny(rest, `"\`)
+		if i < 0 {
+			p.errorf("unterminated string")
+		}
+		switch {
+		case strings.HasPrefix(rest[i:], `"""`):
+			raw.WriteString(rest[:i])
+			p.pos = len(p.src) - len(rest) + i + 3
+			p.tok.kind, p.tok.text = tokString, blockStringValue(raw.String())
+			return
+		case strings.HasPrefix(rest[i:], `\"""`):
+			raw.WriteString(rest[:i] + `"""`)
+			rest = rest[i+4:]
+		default:
+			raw.WriteString(rest[:i+1])
+			rest = rest[i+1:]
+		}
+	}
+}
+
+// blockStringValue removes the indentation that the lines of a block
+// string after the first have in common, and blank lines at its start and
+// end.
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(raw), "\n")
+	common := -1
+	for _, line := range lines[1:] {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < len(line) && (common < 0 || indent < common) {
+			common = indent
+		}
+	}
+	if common > 0 {
+		for i := 1; i < len(lines); i++ {
+			lines[i] = lines[i][min(common, len(lines[i])):]
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,327 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/graphql"
+	"srv.exe.dev/srv/tags"
+)
+
+// graphQLDepth is how deeply GraphQL queries can nest: enough to go from a
+// post to its tags and their posts, but not round and round.
+const graphQLDepth = 6
+
+// graphTag is a tag as the GraphQL API resolves it. Its post count is
+// looked up when asked for, unless it is already known.
+type graphTag struct {
+	dbgen.Tag
+	postCount *int64
+}
+
+// dateTime is the GraphQL scalar for times, written as in RFC 3339.
+var dateTime = &graphql.Scalar{
+	Name:        "DateTime",
+	Description: "A time, as in RFC 3339.",
+	Serialize: func(v any) (any, error) {
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("DateTime cannot represent %T", v)
+		}
+		return t.UTC().Format(time.RFC3339), nil
+	},
+	Parse: func(v any) (any, error) {
+		str, _ := v.(string)
+		t, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			return nil, fmt.Errorf("DateTime cannot represent %v", v)
+		}
+		return t, nil
+	},
+}
+
+// graphQLSchema returns the schema of the GraphQL API for r. Only
+// published posts can be seen through it.
+func (s *Server) graphQLSchema(r *http.Request) *graphql.Schema {
+	q := dbgen.New(s.DB)
+	post := &graphql.Object{Name: "Post", Description: "A published post."}
+	tag := &graphql.Object{Name: "Tag"}
+	page := &graphql.Object{
+		Name:        "PostPage",
+		Description: "A page of posts, newest first.",
+		Fields: []*graphql.Field{
+			{Name: "posts", Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: post}}}},
+			{Name: "nextCursor", Type: graphql.String, Description: "Pass as after for the next page; null on the last."},
+		},
+	}
+	pageArgs := []*graphql.Arg{
+		{Name: "first", Type: graphql.Int, Default: apiPageSize, Description: fmt.Sprintf("Posts per page, at most %d.", maxAPIPageSize)},
+		{Name: "after", Type: graphql.String, Description: "The nextCursor of the page before."},
+	}
+	posts := func(ctx context.Context, tagSlug string, args map[string]any) (any, error) {
+		published := int64(1)
+		params := dbgen.ListAPIPostsParams{Published: &published, Tag: tagSlug}
+		first, ok := args["first"].(int)
+		if !ok {
+			first = apiPageSize
+		}
+		if first < 1 {
+			return nil, errors.New("first must be a positive number")
+		}
+		limit := min(first, maxAPIPageSize)
+		if after, _ := args["after"].(string); after != "" {
+			created, id, err := decodeCursor(after)
+			if err != nil {
+				return nil, errors.New("invalid cursor")
+			}
+			params.AfterCreated, params.AfterID = created, id
+		}
+		// One more than a page is fetched to tell whether there is another.
+		params.Limit = int64(limit) + 1
+		rows, err := q.ListAPIPosts(ctx, params)
+		if err != nil {
+			slog.Error("list posts", "error", err)
+			return nil, errors.New("failed to list posts")
+		}
+		result := map[string]any{"posts": rows, "nextCursor": nil}
+		if len(rows) > limit {
+			rows = rows[:limit]
+			result["posts"], result["nextCursor"] = rows, encodeCursor(rows[limit-1])
+		}
+		return result, nil
+	}
+	postBySlug := func(ctx context.Context, slug string) (any, error) {
+		p, err := q.GetPostBySlug(ctx, slug)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && (p.Published == 0 || p.DeletedAt != nil) {
+			return nil, nil
+		}
+		if err != nil {
+			slog.Error("get post", "error", err)
+			return nil, errors.New("failed to get post")
+		}
+		return p, nil
+	}
+	str := graphql.NonNull{Of: graphql.String}
+
+	post.Fields = []*graphql.Field{
+		{Name: "id", Type: graphql.NonNull{Of: graphql.ID}},
+		{Name: "slug", Type: str},
+		{Name: "title", Type: str},
+		{Name: "content", Type: str, Description: "The Markdown source."},
+		{
+			Name:        "html",
+			Type:        str,
+			Description: "The content rendered as HTML.",
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return string(s.renderPost(ctx, source.(dbgen.Post)).html), nil
+			},
+		},
+		{
+			Name:        "excerpt",
+			Type:        str,
+			Description: "The excerpt written for the post, or else its start.",
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return postExcerpt(source.(dbgen.Post)), nil
+			},
+		},
+		{
+			Name:        "readingTime",
+			Type:        graphql.NonNull{Of: graphql.Int},
+			Description: "Minutes to read the post.",
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return readingTime(s.renderPost(ctx, source.(dbgen.Post)).words), nil
+			},
+		},
+		{Name: "coverImage", Type: str},
+		{
+			Name: "url",
+			Type: str,
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return s.baseURL(r) + "/post/" + source.(dbgen.Post).Slug, nil
+			},
+		},
+		{Name: "createdAt", Type: graphql.NonNull{Of: dateTime}},
+		{Name: "updatedAt", Type: graphql.NonNull{Of: dateTime}},
+		{
+			Name: "tags",
+			Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: tag}}},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				rows, err := q.GetPostTags(ctx, source.(dbgen.Post).ID)
+				if err != nil {
+					slog.Error("get post tags", "error", err)
+					return nil, errors.New("failed to get tags")
+				}
+				result := make([]graphTag, len(rows))
+				for i, t := range rows {
+					result[i] = graphTag{Tag: t}
+				}
+				return result, nil
+			},
+		},
+	}
+
+	tag.Fields = []*graphql.Field{
+		{Name: "name", Type: str},
+		{Name: "slug", Type: str},
+		{
+			Name:        "postCount",
+			Type:        graphql.NonNull{Of: graphql.Int},
+			Description: "The number of published posts with the tag.",
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				t := source.(graphTag)
+				if t.postCount != nil {
+					return *t.postCount, nil
+				}
+				n, err := q.CountTagPosts(ctx, t.ID)
+				if err != nil {
+					slog.Error("count tag posts", "error", err)
+					return nil, errors.New("failed to count posts")
+				}
+				return n, nil
+			},
+		},
+		{
+			Name: "posts",
+			Args: pageArgs,
+			Type: graphql.NonNull{Of: page},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return posts(ctx, source.(graphTag).Slug, args)
+			},
+		},
+	}
+
+	searchResult := &graphql.Object{
+		Name:        "SearchResult",
+		Description: "A published post matching a search.",
+		Fields: []*graphql.Field{
+			{Name: "slug", Type: str},
+			{Name: "title", Type: str},
+			{Name: "snippet", Type: str, Description: "HTML, with the matches in <mark>."},
+			{Name: "score", Type: graphql.NonNull{Of: graphql.Float}, Description: "Higher for better matches."},
+			{
+				Name: "post",
+				Type: post,
+				Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+					return postBySlug(ctx, source.(SearchResult).Slug)
+				},
+			},
+		},
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{
+			Name: "post",
+			Args: []*graphql.Arg{{Name: "slug", Type: str}},
+			Type: post,
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				return postBySlug(ctx, args["slug"].(string))
+			},
+		},
+		{
+			Name: "posts",
+			Args: append(pageArgs, &graphql.Arg{Name: "tag", Type: graphql.String, Description: "Only posts with this tag."}),
+			Type: graphql.NonNull{Of: page},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				tagName, _ := args["tag"].(string)
+				return posts(ctx, tags.Slug(tagName), args)
+			},
+		},
+		{
+			Name: "tag",
+			Args: []*graphql.Arg{{Name: "slug", Type: str}},
+			Type: tag,
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				t, err := q.GetTagBySlug(ctx, args["slug"].(string))
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil, nil
+				}
+				if err != nil {
+					slog.Error("get tag", "error", err)
+					return nil, errors.New("failed to get tag")
+				}
+				return graphTag{Tag: t}, nil
+			},
+		},
+		{
+			Name:        "tags",
+			Type:        graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: tag}}},
+			Description: "The tags of published posts, by name.",
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				rows, err := q.GetTagCounts(ctx)
+				if err != nil {
+					slog.Error("get tag counts", "error", err)
+					return nil, errors.New("failed to get tags")
+				}
+				result := make([]graphTag, len(rows))
+				for i, row := range rows {
+					result[i] = graphTag{Tag: dbgen.Tag{ID: row.ID, Name: row.Name, Slug: row.Slug}, postCount: &row.PostCount}
+				}
+				return result, nil
+			},
+		},
+		{
+			Name: "search",
+			Args: []*graphql.Arg{
+				{Name: "query", Type: str},
+				{Name: "first", Type: graphql.Int, Default: searchLimit, Description: fmt.Sprintf("The most results, up to %d.", searchLimit)},
+			},
+			Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: searchResult}}},
+			Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+				first, ok := args["first"].(int)
+				if !ok || first < 1 {
+					first = searchLimit
+				}
+				results, err := s.searchPosts(ctx, args["query"].(string), min(first, searchLimit))
+				if err != nil {
+					slog.Error("search posts", "error", err)
+					return nil, errors.New("search failed")
+				}
+				return results, nil
+			},
+		},
+	}}
+	return &graphql.Schema{Query: query, MaxDepth: graphQLDepth}
+}
+
+// HandleGraphQL answers GraphQL queries about published posts, their tags
+// and search, sent as JSON in the body of a POST or in the query string
+// of a GET.
+func (s *Server) HandleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	fail := func(msg string) {
+		writeJSON(w, http.StatusBadRequest, graphql.Response{Errors: []graphql.Error{{Message: msg}}})
+	}
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query, req.OperationName = query.Get("query"), query.Get("operationName")
+		if v := query.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				fail("variables must be a JSON object")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		fail("invalid JSON body: " + err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		fail("missing query")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.graphQLSchema(r).Execute(r.Context(), req))
+}
+
+// HandleGraphQLSchema answers GET /api/graphql/schema.graphql with the
+// GraphQL schema, for tools that generate clients from it.
+func (s *Server) HandleGraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, s.graphQLSchema(r))
+}
@@ -0,0 +1,213 @@
+// Package highlight implements lightweight server-side syntax
+// highlighting for code blocks.
+//
+// Each supported language is described by its comment and string syntax
+// and its keyword lists; a single lexer splits source into tokens using
+// those rules. The output is HTML with tokens wrapped in spans whose
+// classes are styled by style.css.
+package highlight
+
+import (
+	"html/template"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind classifies a lexed token.
+type TokenKind int
+
+const (
+	Plain TokenKind = iota
+	Keyword
+	Builtin
+	String
+	Number
+	Comment
+	Function
+)
+
+var classes = map[TokenKind]string{
+	Keyword:  "hl-kw",
+	Builtin:  "hl-bi",
+	String:   "hl-str",
+	Number:   "hl-num",
+	Comment:  "hl-com",
+	Function: "hl-fn",
+}
+
+// Token is a run of source text of a single kind.
+type Token struct {
+	Kind TokenKind
+	Text string
+}
+
+type stringSyntax struct {
+	open, close string
+	escapes     bool // backslash escapes the next character
+	multiline   bool
+}
+
+type language struct {
+	lineComments    []string
+	blockComments   [][2]string
+	strings         []stringSyntax
+	keywords        map[string]bool
+	builtins        map[string]bool
+	identStart      string // extra identifier start characters
+	caseInsensitive bool
+}
+
+// Lex splits code into tokens using the rules for lang. It reports false
+// if lang is not a supported language.
+func Lex(lang, code string) ([]Token, bool) {
+	l, ok := lookup(lang)
+	if !ok {
+		return nil, false
+	}
+	return l.lex(code), true
+}
+
+// HTML returns code as escaped HTML with highlighted tokens. It reports
+// false if lang is not a supported language.
+func HTML(lang, code string) (string, bool) {
+	tokens, ok := Lex(lang, code)
+	if !ok {
+		return "", false
+	}
+	var sb strings.Builder
+	for _, t := range tokens {
+		text := template.HTMLEscapeString(t.Text)
+		if class, ok := classes[t.Kind]; ok {
+			sb.WriteString(`<span class="` + class + `">` + text + `</span>`)
+		} else {
+			sb.WriteString(text)
+		}
+	}
+	return sb.String(), true
+}
+
+func lookup(lang string) (*language, bool) {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if name, ok := aliases[lang]; ok {
+		lang = name
+	}
+	l, ok := languages[lang]
+	return l, ok
+}
+
+func (l *language) lex(src string) []Token {
+	var tokens []Token
+	emit := func(kind TokenKind, text string) {
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind && kind == Plain {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, Token{Kind: kind, Text: text})
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		if n := l.matchComment(rest); n > 0 {
+			emit(Comment, rest[:n])
+			i += n
+			continue
+		}
+		if n := l.matchString(rest); n > 0 {
+			emit(String, rest[:n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case unicode.IsDigit(r) && (i == 0 || !isIdent(src[i-1])):
+			n := 0
+			for n < len(rest) && (isIdent(rest[n]) || rest[n] == '.' && n+1 < len(rest) && isDigit(rest[n+1])) {
+				n++
+			}
+			emit(Number, rest[:n])
+			i += n
+		case unicode.IsLetter(r) || r == '_' || strings.ContainsRune(l.identStart, r):
+			n := size
+			for n < len(rest) {
+				r, size := utf8.DecodeRuneInString(rest[n:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				n += size
+			}
+			word := rest[:n]
+			emit(l.classify(word, rest[n:]), word)
+			i += n
+		default:
+			emit(Plain, rest[:size])
+			i += size
+		}
+	}
+	return tokens
+}
+
+func (l *language) classify(word, after string) TokenKind {
+	key := word
+	if l.caseInsensitive {
+		key = strings.ToLower(word)
+	}
+	switch {
+	case l.keywords[key]:
+		return Keyword
+	case l.builtins[key]:
+		return Builtin
+	case strings.HasPrefix(strings.TrimLeft(after, " "), "("):
+		return Function
+	}
+	return Plain
+}
+
+func (l *language) matchComment(s string) int {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	for _, delims := range l.blockComments {
+		if strings.HasPrefix(s, delims[0]) {
+			if end := strings.Index(s[len(delims[0]):], delims[1]); end >= 0 {
+				return len(delims[0]) + end + len(delims[1])
+			}
+			return len(s)
+		}
+	}
+	return 0
+}
+
+func (l *language) matchString(s string) int {
+	for _, syn := range l.strings {
+		if !strings.HasPrefix(s, syn.open) {
+			continue
+		}
+		for i := len(syn.open); i < len(s); i++ {
+			switch {
+			case syn.escapes && s[i] == '\\':
+				i++
+			case strings.HasPrefix(s[i:], syn.close):
+				return i + len(syn.close)
+			case s[i] == '\n' && !syn.multiline:
+				return i
+			}
+		}
+		return len(s)
+	}
+	return 0
+}
+
+func isIdent(b byte) bool {
+	return b == '_' || isDigit(b) || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= utf8.RuneSelf
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
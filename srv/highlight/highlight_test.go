@@ -0,0 +1,67 @@
+package highlight
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		lang     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "go function",
+			lang:     "go",
+			input:    `func main() { fmt.Println("hi <3") }`,
+			expected: `<span class="hl-kw">func</span> <span class="hl-fn">main</span>() { fmt.<span class="hl-fn">Println</span>(<span class="hl-str">&#34;hi &lt;3&#34;</span>) }`,
+		},
+		{
+			name:     "go comment and number",
+			lang:     "golang",
+			input:    "x := 0x1F // hex\nvar y int",
+			expected: "x := <span class=\"hl-num\">0x1F</span> <span class=\"hl-com\">// hex</span>\n<span class=\"hl-kw\">var</span> y <span class=\"hl-bi\">int</span>",
+		},
+		{
+			name:     "escaped quote stays in string",
+			lang:     "js",
+			input:    `let s = "a\"b";`,
+			expected: `<span class="hl-kw">let</span> s = <span class="hl-str">&#34;a\&#34;b&#34;</span>;`,
+		},
+		{
+			name:     "unterminated string stops at newline",
+			lang:     "python",
+			input:    "x = 'oops\nprint(x)",
+			expected: "x = <span class=\"hl-str\">&#39;oops</span>\n<span class=\"hl-bi\">print</span>(x)",
+		},
+		{
+			name:     "case insensitive sql keywords",
+			lang:     "sql",
+			input:    "SELECT id FROM posts -- all",
+			expected: `<span class="hl-kw">SELECT</span> id <span class="hl-kw">FROM</span> posts <span class="hl-com">-- all</span>`,
+		},
+		{
+			name:     "digits inside identifiers",
+			lang:     "c",
+			input:    "int32_t x1 = 2;",
+			expected: `int32_t x1 = <span class="hl-num">2</span>;`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, ok := HTML(test.lang, test.input)
+			if !ok {
+				t.Fatalf("HTML(%q) reported unsupported language", test.lang)
+			}
+			if result != test.expected {
+				t.Errorf("HTML(%q, %q) = %q, expected %q", test.lang, test.input, result, test.expected)
+			}
+		})
+	}
+
+	t.Run("unknown language", func(t *testing.T) {
+		if _, ok := HTML("brainfuck", "+++"); ok {
+			t.Error("expected unknown language to be unsupported")
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package highlight
+
+import "strings"
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	doubleQuoted = stringSyntax{open: `"`, close: `"`, escapes: true}
+	singleQuoted = stringSyntax{open: `'`, close: `'`, escapes: true}
+)
+
+var languages = map[string]*language{
+	"go": {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings: []stringSyntax{
+			doubleQuoted,
+			singleQuoted,
+			{open: "`", close: "`", multiline: true},
+		},
+		keywords: words(`break case chan const continue default defer else fallthrough
+			for func go goto if import interface map package range return select
+			struct switch type var`),
+		builtins: words(`any bool byte comparable complex64 complex128 error float32
+			float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32
+			uint64 uintptr true false nil iota append cap clear close complex copy
+			delete imag len make max min new panic print println real recover`),
+	},
+	"javascript": {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings: []stringSyntax{
+			doubleQuoted,
+			singleQuoted,
+			{open: "`", close: "`", escapes: true, multiline: true},
+		},
+		keywords: words(`async await break case catch class const continue debugger
+			default delete do else enum export extends finally for from function if
+			implements import in instanceof interface let new of return static super
+			switch this throw try type typeof var void while with yield`),
+		builtins: words(`true false null undefined NaN Infinity Array Boolean Date
+			Error JSON Map Math Number Object Promise RegExp Set String Symbol
+			console document window`),
+		identStart: "$",
+	},
+	"python": {
+		lineComments: []string{"#"},
+		strings: []stringSyntax{
+			{open: `"""`, close: `"""`, escapes: true, multiline: true},
+			{open: `'''`, close: `'''`, escapes: true, multiline: true},
+			doubleQuoted,
+			singleQuoted,
+		},
+		keywords: words(`and as assert async await break case class continue def del
+			elif else except finally for from global if import in is lambda match
+			nonlocal not or pass raise return try while with yield`),
+		builtins: words(`True False None self abs all any bool bytes dict enumerate
+			filter float int isinstance len list map max min open print range repr
+			set sorted str sum super tuple type zip`),
+	},
+	"bash": {
+		lineComments: []string{"#"},
+		strings: []stringSyntax{
+			{open: `"`, close: `"`, escapes: true, multiline: true},
+			{open: `'`, close: `'`, multiline: true},
+		},
+		keywords: words(`if then else elif fi for while until do done case esac in
+			function return local export select`),
+		builtins: words(`alias cd echo eval exec exit printf pwd read set shift
+			source test trap unset true false sudo`),
+		identStart: "$",
+	},
+	"sql": {
+		lineComments:  []string{"--"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings:       []stringSyntax{{open: `'`, close: `'`, multiline: true}},
+		keywords: words(`add all alter and as asc begin between by case check column
+			commit conflict create default delete desc distinct do drop else end
+			exists foreign from group having if in index inner insert into is join
+			key left like limit not nothing null offset on or order outer primary
+			references replace returning rollback select set table then trigger
+			union unique update using values view virtual when where with`),
+		builtins: words(`integer int text real blob boolean timestamp avg coalesce
+			count current_timestamp date datetime max min strftime sum`),
+		caseInsensitive: true,
+	},
+	"json": {
+		strings:  []stringSyntax{doubleQuoted},
+		builtins: words(`true false null`),
+	},
+	"c": {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings:       []stringSyntax{doubleQuoted, singleQuoted},
+		keywords: words(`auto break case class const continue default delete do else
+			enum extern for goto if inline namespace new private protected public
+			register return sizeof static struct switch template this typedef
+			union using virtual volatile while`),
+		builtins: words(`bool char double float int long short signed unsigned void
+			size_t true false nullptr NULL`),
+	},
+	"rust": {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings:       []stringSyntax{doubleQuoted},
+		keywords: words(`as async await break const continue crate dyn else enum extern
+			fn for if impl in let loop match mod move mut pub ref return self Self
+			static struct super trait type unsafe use where while`),
+		builtins: words(`bool char f32 f64 i8 i16 i32 i64 i128 isize str u8 u16 u32
+			u64 u128 usize String Vec Option Result Some None Ok Err true false`),
+	},
+}
+
+var aliases = map[string]string{
+	"golang":     "go",
+	"js":         "javascript",
+	"ts":         "javascript",
+	"typescript": "javascript",
+	"py":         "python",
+	"sh":         "bash",
+	"shell":      "bash",
+	"zsh":        "bash",
+	"sqlite":     "sql",
+	"cpp":        "c",
+	"c++":        "c",
+	"h":          "c",
+	"rs":         "rust",
+}
@@ -0,0 +1,145 @@
+package srv
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/frontmatter"
+)
+
+// hugoFiles puts the files of a Hugo site with the blog's content:
+// hugo.toml, a page under content/posts for each post not in the trash,
+// with its tags and category as taxonomies, and the uploaded media under
+// static/media, so that links to them keep working. Posts keep their
+// /post/SLUG addresses, and posts whose slug was changed list the old
+// addresses as aliases. Layouts are left to a theme.
+func (s *Server) hugoFiles(ctx context.Context, put func(name string, data []byte) error) error {
+	q := dbgen.New(s.DB)
+	if err := put("hugo.toml", []byte(hugoConfig(s.setting(ctx, settingSiteURL)))); err != nil {
+		return err
+	}
+
+	categories, err := q.GetCategories(ctx)
+	if err != nil {
+		return err
+	}
+	categoryNames := make(map[int64]string)
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+	redirects, err := q.GetRedirects(ctx)
+	if err != nil {
+		return err
+	}
+	aliases := make(map[string][]string)
+	for _, r := range redirects {
+		aliases[r.NewSlug] = append(aliases[r.NewSlug], "/post/"+r.OldSlug)
+	}
+	posts, err := q.ListAllPosts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range posts {
+		postTags, err := q.GetPostTags(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		fields := []frontmatter.Field{
+			{Key: "title", Value: p.Title},
+			{Key: "slug", Value: p.Slug},
+			{Key: "date", Value: p.CreatedAt.UTC().Format(time.RFC3339)},
+			{Key: "lastmod", Value: p.UpdatedAt.UTC().Format(time.RFC3339)},
+			{Key: "draft", Value: p.Published == 0},
+		}
+		if len(postTags) > 0 {
+			names := make([]string, len(postTags))
+			for i, t := range postTags {
+				names[i] = t.Name
+			}
+			fields = append(fields, frontmatter.Field{Key: "tags", Value: names})
+		}
+		if p.CategoryID != nil && categoryNames[*p.CategoryID] != "" {
+			fields = append(fields, frontmatter.Field{Key: "categories", Value: []string{categoryNames[*p.CategoryID]}})
+		}
+		if p.Excerpt != "" {
+			fields = append(fields, frontmatter.Field{Key: "summary", Value: p.Excerpt})
+		}
+		if p.MetaDescription != "" {
+			fields = append(fields, frontmatter.Field{Key: "description", Value: p.MetaDescription})
+		}
+		if image := cmp.Or(p.OgImage, p.CoverImage); image != "" {
+			fields = append(fields, frontmatter.Field{Key: "images", Value: []string{image}})
+		}
+		if len(aliases[p.Slug]) > 0 {
+			fields = append(fields, frontmatter.Field{Key: "aliases", Value: aliases[p.Slug]})
+		}
+		if err := put("content/posts/"+p.Slug+".md", []byte(frontmatter.Format(fields, p.Content))); err != nil {
+			return err
+		}
+	}
+
+	media, err := q.ListAllMedia(ctx)
+	if err != nil {
+		return err
+	}
+	store := s.mediaStore(ctx)
+	for _, m := range media {
+		rc, err := store.Open(ctx, m.Filename)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", m.Filename, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("read %s: %w", m.Filename, err)
+		}
+		if err := put("static/media/"+m.Filename, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hugoConfig returns the hugo.toml of an exported site at baseURL, or at
+// / if that is not known.
+func hugoConfig(baseURL string) string {
+	if baseURL == "" {
+		baseURL = "/"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "baseURL = %s\n", strconv.Quote(strings.TrimRight(baseURL, "/")+"/"))
+	fmt.Fprintf(&sb, "title = %s\n", strconv.Quote(siteTitle))
+	sb.WriteString(`languageCode = "en"
+
+[permalinks]
+  posts = "/post/:slug/"
+
+[taxonomies]
+  tag = "tags"
+  category = "categories"
+
+# Some posts may allow raw HTML, which Hugo leaves out unless told not to.
+[markup.goldmark.renderer]
+  unsafe = true
+`)
+	return sb.String()
+}
+
+// ExportHugo writes the blog to dir as the source of a Hugo site, as
+// hugoFiles describes, returning the number of files written.
+func (s *Server) ExportHugo(ctx context.Context, dir string) (int, error) {
+	return writeExport(ctx, dir, s.hugoFiles)
+}
+
+// HandleAdminExportHugo downloads the blog as the source of a Hugo site,
+// zipped.
+func (s *Server) HandleAdminExportHugo(w http.ResponseWriter, r *http.Request) {
+	serveExport(w, r, "hugo-site", s.hugoFiles)
+}
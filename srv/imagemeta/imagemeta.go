@@ -0,0 +1,236 @@
+// Package imagemeta removes metadata, such as the camera details and GPS
+// coordinates of EXIF, from uploaded images.
+//
+// Metadata is cut out of the file rather than the image being decoded and
+// encoded again, so the image itself is unchanged. The exception is a JPEG
+// whose EXIF orientation says it is stored rotated or mirrored: since the
+// orientation goes with the rest of the EXIF, the pixels are turned upright
+// and the image is encoded again.
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+var errTruncated = errors.New("image is truncated or malformed")
+
+// Strip returns data, a JPEG, PNG or WebP image, without its metadata.
+// Images in other formats are returned unchanged.
+func Strip(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8")):
+		return stripJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNG(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return stripWebP(data)
+	}
+	return data, nil
+}
+
+// JPEG markers.
+const (
+	markerAPP1  = 0xe1 // EXIF and XMP
+	markerAPP13 = 0xed // IPTC, in Photoshop's resources
+	markerCOM   = 0xfe // comments, where some cameras write their details
+	markerSOS   = 0xda // start of the image data, which runs to the end
+)
+
+// jpegQuality is the quality a JPEG is encoded with again after being
+// turned upright.
+const jpegQuality = 90
+
+// stripJPEG drops the segments of a JPEG that hold metadata. Colour
+// profiles, in APP2, and the APP0 and APP14 segments that say how to
+// decode the image are kept.
+func stripJPEG(data []byte) ([]byte, error) {
+	out := append(make([]byte, 0, len(data)), data[:2]...)
+	orientation := 1
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xff {
+			return nil, errTruncated
+		}
+		marker := data[i+1]
+		if marker == 0xff {
+			// Fill byte before a marker.
+			i++
+			continue
+		}
+		if marker == markerSOS {
+			out = append(out, data[i:]...)
+			break
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return nil, errTruncated
+		}
+		segment := data[i:end]
+		switch marker {
+		case markerAPP1:
+			if o, ok := exifOrientation(segment[4:]); ok {
+				orientation = o
+			}
+		case markerAPP13, markerCOM:
+		default:
+			out = append(out, segment...)
+		}
+		i = end
+	}
+	if orientation == 1 {
+		return out, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, orient(img, orientation), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exifOrientation returns the orientation recorded in the payload of an
+// APP1 segment, if it is EXIF and has one: a number from 1, upright, to 8.
+func exifOrientation(payload []byte) (int, bool) {
+	tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 0, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0, false
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := range entries {
+		e := ifd + 2 + n*12
+		if e+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			o := int(order.Uint16(tiff[e+8:]))
+			return o, o >= 1 && o <= 8
+		}
+	}
+	return 0, false
+}
+
+// orient turns img, stored with the given EXIF orientation, upright.
+func orient(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Rect, img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if orientation >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // flip horizontally
+				dx, dy = w-1-x, y
+			case 3: // turn half round
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip vertically
+				dx, dy = x, h-1-y
+			case 5: // flip along the diagonal from the top left
+				dx, dy = y, x
+			case 6: // turn a quarter clockwise
+				dx, dy = h-1-y, x
+			case 7: // flip along the diagonal from the top right
+				dx, dy = h-1-y, w-1-x
+			case 8: // turn a quarter anticlockwise
+				dx, dy = y, w-1-x
+			default:
+				return img
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):])
+		}
+	}
+	return dst
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadata are the PNG chunks that hold metadata: EXIF, text, which is
+// also where XMP goes, and the time of the last change.
+var pngMetadata = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// stripPNG drops the metadata chunks of a PNG. Each chunk has its own
+// checksum, so the others can be copied as they are.
+func stripPNG(data []byte) ([]byte, error) {
+	out := append(make([]byte, 0, len(data)), pngSignature...)
+	for i := len(pngSignature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, errTruncated
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, errTruncated
+		}
+		if !pngMetadata[string(data[i+4:i+8])] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// VP8X flags saying that a WebP has EXIF and XMP chunks.
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// stripWebP drops the EXIF and XMP chunks of a WebP and clears the flags
+// in its VP8X header that announce them.
+func stripWebP(data []byte) ([]byte, error) {
+	out := append(make([]byte, 0, len(data)), data[:12]...)
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errTruncated
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end > len(data) || end < i {
+			return nil, errTruncated
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if size > 0 {
+				out[start+8] &^= webpFlagEXIF | webpFlagXMP
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
@@ -0,0 +1,188 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// exifSegment returns a JPEG APP1 segment with EXIF giving the orientation
+// and a stand-in for GPS coordinates.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1) // one entry
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, "GPS 51.5N 0.1W"...)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xff, markerAPP1}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// testJPEG returns a width by height JPEG, white apart from a black top
+// left pixel, with extra inserted after its start marker.
+func testJPEG(t *testing.T, width, height int, extra ...[]byte) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	// A block of black, so that it survives compression.
+	for y := range 8 {
+		for x := range 8 {
+			img.SetGray(x, y, color.Gray{0})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	for _, e := range extra {
+		out = append(out, e...)
+	}
+	return append(out, data[2:]...)
+}
+
+func TestStripJPEG(t *testing.T) {
+	comment := []byte("\xff\xfe\x00\x0aCanon\x00\x00\x00")
+	data := testJPEG(t, 32, 16, exifSegment(1), comment)
+	stripped, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"Exif", "GPS", "Canon"} {
+		if bytes.Contains(stripped, []byte(leak)) {
+			t.Errorf("expected %q removed", leak)
+		}
+	}
+	if !bytes.Equal(stripped, testJPEG(t, 32, 16)) {
+		t.Error("expected the rest of the JPEG left as it was")
+	}
+}
+
+func TestStripJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		orientation    uint16
+		width, height  int
+		blackX, blackY int // where the black corner ends up
+	}{
+		{3, 32, 16, 31, 15},
+		{6, 16, 32, 15, 0},
+		{8, 16, 32, 0, 31},
+	}
+	for _, tt := range tests {
+		stripped, err := Strip(testJPEG(t, 32, 16, exifSegment(tt.orientation)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(stripped, []byte("Exif")) {
+			t.Errorf("orientation %d: expected EXIF removed", tt.orientation)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(stripped))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != tt.width || b.Dy() != tt.height {
+			t.Errorf("orientation %d: expected %dx%d, got %dx%d", tt.orientation, tt.width, tt.height, b.Dx(), b.Dy())
+			continue
+		}
+		if r, _, _, _ := img.At(tt.blackX, tt.blackY).RGBA(); r>>8 > 64 {
+			t.Errorf("orientation %d: expected black at %d,%d", tt.orientation, tt.blackX, tt.blackY)
+		}
+	}
+}
+
+// pngChunk returns a PNG chunk of the given type.
+func pngChunk(typ, data string) []byte {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	c = append(c, typ+data...)
+	return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE([]byte(typ+data)))
+}
+
+func TestStripPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	clean := buf.Bytes()
+	// After the signature and the IHDR chunk.
+	at := len(pngSignature) + 25
+	data := append([]byte{}, clean[:at]...)
+	data = append(data, pngChunk("tEXt", "Author\x00Jo")...)
+	data = append(data, pngChunk("eXIf", "MM\x00\x2aGPS")...)
+	data = append(data, clean[at:]...)
+
+	stripped, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, clean) {
+		t.Error("expected the text and EXIF chunks removed and nothing else")
+	}
+	if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("stripped PNG does not decode: %v", err)
+	}
+}
+
+// webpChunk returns a RIFF chunk of the given type.
+func webpChunk(typ, data string) []byte {
+	c := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	c = append(c, data...)
+	if len(data)%2 == 1 {
+		c = append(c, 0)
+	}
+	return c
+}
+
+func TestStripWebP(t *testing.T) {
+	webp := func(chunks ...[]byte) []byte {
+		body := []byte("WEBP")
+		for _, c := range chunks {
+			body = append(body, c...)
+		}
+		return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+	}
+	data := webp(
+		webpChunk("VP8X", "\x0c\x00\x00\x00\x03\x00\x00\x03\x00\x00"),
+		webpChunk("VP8L", "pixels"),
+		webpChunk("EXIF", "GPS"),
+		webpChunk("XMP ", "<x:xmpmeta/>"),
+	)
+	expected := webp(
+		webpChunk("VP8X", "\x00\x00\x00\x00\x03\x00\x00\x03\x00\x00"),
+		webpChunk("VP8L", "pixels"),
+	)
+	stripped, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, expected) {
+		t.Errorf("Strip(%q) = %q, expected %q", data, stripped, expected)
+	}
+}
+
+func TestStripOther(t *testing.T) {
+	gif := []byte("GIF89a\x01\x00\x01\x00")
+	if stripped, err := Strip(gif); err != nil || !bytes.Equal(stripped, gif) {
+		t.Errorf("expected a GIF left alone, got %q (%v)", stripped, err)
+	}
+	for _, truncated := range [][]byte{
+		[]byte("\xff\xd8\xff\xe1\x10\x00Exif"),
+		append(append([]byte{}, pngSignature...), 0, 0, 1, 0),
+	} {
+		if _, err := Strip(truncated); err == nil {
+			t.Errorf("expected an error for truncated %q", truncated)
+		}
+	}
+}
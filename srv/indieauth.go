@@ -0,0 +1,350 @@
+package srv
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// indieAuthCodeLifetime is how long a client has to redeem an
+// authorization code.
+const indieAuthCodeLifetime = 10 * time.Minute
+
+// indieAuthScopes are the scopes a client may be granted, with what each
+// lets it do as shown on the consent page. profile only signs the admin
+// in to the client; the others are for the Micropub endpoints.
+var indieAuthScopes = []struct{ Name, Description string }{
+	{"profile", "See the blog's name"},
+	{"create", "Publish new posts"},
+	{"update", "Change posts"},
+	{"delete", "Delete and restore posts"},
+	{"media", "Upload images"},
+}
+
+// oauthError is the body of an error answer from the IndieAuth endpoints.
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// indieAuthMe returns the URL the site is known by when signing in with
+// it, its home page.
+func indieAuthMe(base string) string {
+	return base + "/"
+}
+
+// authRequest is an authorization request from an IndieAuth client, as
+// sent to the authorization endpoint and carried through the consent page.
+type authRequest struct {
+	ClientID      string
+	RedirectURI   string
+	State         string
+	CodeChallenge string
+	Scopes        []string
+}
+
+// parseAuthRequest reads and checks the authorization request in v.
+// PKCE with S256 is required. The redirect_uri must be on the client_id's
+// host, as the client's page is not fetched to find others it allows.
+// Scopes that cannot be granted are dropped.
+func parseAuthRequest(v url.Values) (authRequest, error) {
+	req := authRequest{
+		ClientID:      v.Get("client_id"),
+		RedirectURI:   v.Get("redirect_uri"),
+		State:         v.Get("state"),
+		CodeChallenge: v.Get("code_challenge"),
+	}
+	if rt := v.Get("response_type"); rt != "code" {
+		return req, errors.New("response_type must be code")
+	}
+	client, err := url.Parse(req.ClientID)
+	if err != nil || !isClientURL(client) {
+		return req, errors.New("client_id must be an http or https URL")
+	}
+	redirect, err := url.Parse(req.RedirectURI)
+	if err != nil || !isClientURL(redirect) {
+		return req, errors.New("redirect_uri must be an http or https URL")
+	}
+	if redirect.Scheme != client.Scheme || redirect.Host != client.Host {
+		return req, errors.New("redirect_uri must be on the same host as client_id")
+	}
+	if req.State == "" {
+		return req, errors.New("state is required")
+	}
+	if req.CodeChallenge == "" || v.Get("code_challenge_method") != "S256" {
+		return req, errors.New("a code_challenge made with the S256 method is required")
+	}
+	requested := strings.Fields(strings.Join(v["scope"], " "))
+	for _, scope := range indieAuthScopes {
+		if slices.Contains(requested, scope.Name) {
+			req.Scopes = append(req.Scopes, scope.Name)
+		}
+	}
+	return req, nil
+}
+
+// isClientURL reports whether u can identify an IndieAuth client or be
+// sent back to: an absolute http or https URL without a fragment or
+// credentials.
+func isClientURL(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Fragment == "" && u.User == nil
+}
+
+// redirectAuth sends the browser back to the client of req with params,
+// along with its state and the issuer.
+func redirectAuth(w http.ResponseWriter, r *http.Request, req authRequest, base string, params url.Values) {
+	u, _ := url.Parse(req.RedirectURI)
+	q := u.Query()
+	for name, values := range params {
+		q[name] = values
+	}
+	q.Set("state", req.State)
+	q.Set("iss", indieAuthMe(base))
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// HandleIndieAuthMetadata answers GET /.well-known/oauth-authorization-server
+// with where the IndieAuth endpoints are and what they support.
+func (s *Server) HandleIndieAuthMetadata(w http.ResponseWriter, r *http.Request) {
+	base := s.baseURL(r)
+	scopes := make([]string, len(indieAuthScopes))
+	for i, scope := range indieAuthScopes {
+		scopes[i] = scope.Name
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                         indieAuthMe(base),
+		"authorization_endpoint":                         base + "/indieauth/auth",
+		"token_endpoint":                                 base + "/indieauth/token",
+		"revocation_endpoint":                            base + "/indieauth/revoke",
+		"scopes_supported":                               scopes,
+		"response_types_supported":                       []string{"code"},
+		"grant_types_supported":                          []string{"authorization_code"},
+		"code_challenge_methods_supported":               []string{"S256"},
+		"authorization_response_iss_parameter_supported": true,
+	})
+}
+
+// HandleIndieAuthAuthorize answers GET /indieauth/auth, where a client
+// sends the admin to sign in with the site, by asking them whether to let
+// it and with which scopes.
+func (s *Server) HandleIndieAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, err := parseAuthRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid authorization request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var scopes []struct{ Name, Description string }
+	for _, scope := range indieAuthScopes {
+		if slices.Contains(req.Scopes, scope.Name) {
+			scopes = append(scopes, scope)
+		}
+	}
+	client, _ := url.Parse(req.ClientID)
+	// The page is not to be framed by a client hoping for a blind click.
+	w.Header().Set("X-Frame-Options", "DENY")
+	s.render(w, "admin_indieauth.html", map[string]any{
+		"Request":    req,
+		"ClientHost": client.Host,
+		"Scopes":     scopes,
+		"Me":         indieAuthMe(s.baseURL(r)),
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
+	})
+}
+
+// HandleIndieAuthApprove answers the consent page. If the admin allowed
+// the client, it is sent an authorization code for the scopes they left
+// ticked; otherwise it is told access was denied.
+func (s *Server) HandleIndieAuthApprove(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	req, err := parseAuthRequest(r.PostForm)
+	if err != nil {
+		http.Error(w, "Invalid authorization request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	base := s.baseURL(r)
+	if r.PostFormValue("decision") != "approve" {
+		redirectAuth(w, r, req, base, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	q := dbgen.New(s.DB)
+	if err := q.PruneIndieAuthCodes(r.Context(), dbTime(time.Now().Add(-indieAuthCodeLifetime))); err != nil {
+		slog.Error("prune IndieAuth codes", "error", err)
+	}
+	code := rand.Text()
+	err = q.CreateIndieAuthCode(r.Context(), dbgen.CreateIndieAuthCodeParams{
+		CodeHash:      hashToken(code),
+		ClientID:      req.ClientID,
+		RedirectUri:   req.RedirectURI,
+		Scope:         strings.Join(req.Scopes, " "),
+		CodeChallenge: req.CodeChallenge,
+	})
+	if err != nil {
+		slog.Error("create IndieAuth code", "error", err)
+		http.Error(w, "Failed to authorize", http.StatusInternalServerError)
+		return
+	}
+	redirectAuth(w, r, req, base, url.Values{"code": {code}})
+}
+
+// redeemIndieAuthCode takes the authorization code a client sent to the
+// authorization or token endpoint, checking that it was issued to that
+// client and redirect_uri and that the client holds its PKCE verifier. It
+// writes an error and returns false if it cannot be redeemed.
+func (s *Server) redeemIndieAuthCode(w http.ResponseWriter, r *http.Request) (dbgen.IndieauthCode, bool) {
+	if gt := r.PostFormValue("grant_type"); gt != "" && gt != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "unsupported_grant_type"})
+		return dbgen.IndieauthCode{}, false
+	}
+	code, err := dbgen.New(s.DB).TakeIndieAuthCode(r.Context(), hashToken(r.PostFormValue("code")))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("take IndieAuth code", "error", err)
+		}
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_grant", Description: "unknown or already used code"})
+		return code, false
+	}
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(verifier[:])
+	switch {
+	case time.Since(code.CreatedAt) > indieAuthCodeLifetime:
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_grant", Description: "the code has expired"})
+	case r.PostFormValue("client_id") != code.ClientID || r.PostFormValue("redirect_uri") != code.RedirectUri:
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_grant", Description: "the code was issued to another client_id or redirect_uri"})
+	case subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1:
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_grant", Description: "code_verifier does not match the code_challenge"})
+	default:
+		return code, true
+	}
+	return code, false
+}
+
+// HandleIndieAuthRedeem answers POST /indieauth/auth, where a client that
+// only signs people in redeems its code for the URL they are known by.
+func (s *Server) HandleIndieAuthRedeem(w http.ResponseWriter, r *http.Request) {
+	code, ok := s.redeemIndieAuthCode(w, r)
+	if !ok {
+		return
+	}
+	s.writeIndieAuthProfile(w, r, code.Scope, nil)
+}
+
+// writeIndieAuthProfile answers a redeemed code with the site's URL and,
+// if the profile scope was granted, its name, adding extra to the answer.
+func (s *Server) writeIndieAuthProfile(w http.ResponseWriter, r *http.Request, scope string, extra map[string]any) {
+	me := indieAuthMe(s.baseURL(r))
+	resp := map[string]any{"me": me}
+	if slices.Contains(strings.Fields(scope), "profile") {
+		resp["profile"] = map[string]string{"name": siteTitle, "url": me}
+	}
+	for k, v := range extra {
+		resp[k] = v
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleIndieAuthToken answers POST /indieauth/token, where a client
+// redeems its code for an access token. The token is stored with the API
+// tokens, named after the client's host, and grants the scopes the admin
+// approved. Older clients revoke tokens here too, with action=revoke.
+func (s *Server) HandleIndieAuthToken(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("action") == "revoke" {
+		s.HandleIndieAuthRevoke(w, r)
+		return
+	}
+	code, ok := s.redeemIndieAuthCode(w, r)
+	if !ok {
+		return
+	}
+	scopes := slices.DeleteFunc(strings.Fields(code.Scope), func(scope string) bool { return scope == "profile" })
+	if len(scopes) == 0 {
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_grant", Description: "the code grants no scope that needs a token; redeem it at the authorization endpoint"})
+		return
+	}
+	client, _ := url.Parse(code.ClientID)
+	token := rand.Text()
+	err := dbgen.New(s.DB).CreateIndieAuthToken(r.Context(), dbgen.CreateIndieAuthTokenParams{
+		Name:      client.Host,
+		TokenHash: hashToken(token),
+		Scope:     code.Scope,
+		ClientID:  code.ClientID,
+	})
+	if err != nil {
+		slog.Error("create IndieAuth token", "error", err)
+		writeJSON(w, http.StatusInternalServerError, oauthError{Error: "server_error"})
+		return
+	}
+	s.writeIndieAuthProfile(w, r, code.Scope, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"scope":        code.Scope,
+	})
+}
+
+// HandleIndieAuthTokenInfo answers GET /indieauth/token, where older
+// Micropub servers check a bearer token, with what the token is for.
+func (s *Server) HandleIndieAuthTokenInfo(w http.ResponseWriter, r *http.Request) {
+	bearer, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, oauthError{Error: "unauthorized"})
+		return
+	}
+	if r, ok = s.checkToken(w, r, bearer); !ok {
+		return
+	}
+	token := apiToken(r)
+	writeJSON(w, http.StatusOK, map[string]string{
+		"me":        indieAuthMe(s.baseURL(r)),
+		"client_id": token.ClientID,
+		"scope":     token.Scope,
+	})
+}
+
+// HandleIndieAuthRevoke answers POST /indieauth/revoke by deleting the
+// token sent, whatever it is. As the spec asks, it answers 200 even if
+// the token was not known.
+func (s *Server) HandleIndieAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	if token := r.PostFormValue("token"); token != "" {
+		if err := dbgen.New(s.DB).DeleteAPITokenByHash(r.Context(), hashToken(token)); err != nil {
+			slog.Error("revoke IndieAuth token", "error", err)
+			writeJSON(w, http.StatusInternalServerError, oauthError{Error: "server_error"})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// relMe returns the profiles the admin listed as theirs, linked with
+// rel=me from the home page.
+func (s *Server) relMe(r *http.Request) []string {
+	return strings.Fields(s.setting(r.Context(), settingRelMe))
+}
+
+// normalizeRelMe cleans up the rel=me profiles, one URL to a line.
+func normalizeRelMe(v string) (string, error) {
+	urls := strings.Fields(v)
+	for _, u := range urls {
+		if !isHTTPURL(u) {
+			return "", errors.New("profiles must be absolute http or https URLs, one to a line")
+		}
+	}
+	return strings.Join(urls, "\n"), nil
+}
@@ -0,0 +1,50 @@
+package srv
+
+import (
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"time"
+)
+
+// blogPosting is schema.org BlogPosting structured data for a post page.
+type blogPosting struct {
+	Context          string       `json:"@context"`
+	Type             string       `json:"@type"`
+	Headline         string       `json:"headline"`
+	Description      string       `json:"description,omitempty"`
+	URL              string       `json:"url"`
+	MainEntityOfPage string       `json:"mainEntityOfPage"`
+	Image            string       `json:"image,omitempty"`
+	DatePublished    string       `json:"datePublished"`
+	DateModified     string       `json:"dateModified"`
+	Author           schemaPerson `json:"author"`
+}
+
+type schemaPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// postJSONLD returns the JSON-LD for post, for a
+// <script type="application/ld+json"> element. encoding/json escapes <, >
+// and &, so the output cannot close the element early.
+func postJSONLD(post PostView) template.JS {
+	b, err := json.Marshal(blogPosting{
+		Context:          "https://schema.org",
+		Type:             "BlogPosting",
+		Headline:         post.Title,
+		Description:      post.Description,
+		URL:              post.URL,
+		MainEntityOfPage: post.URL,
+		Image:            post.Image,
+		DatePublished:    post.CreatedAt.UTC().Format(time.RFC3339),
+		DateModified:     post.UpdatedAt.UTC().Format(time.RFC3339),
+		Author:           schemaPerson{Type: "Person", Name: siteTitle},
+	})
+	if err != nil {
+		slog.Error("marshal json-ld", "error", err)
+		return ""
+	}
+	return template.JS(b)
+}
@@ -0,0 +1,135 @@
+package srv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// settingLikeKey is the setting holding the key readers' addresses are
+// hashed with when they like a post. It is made the first time it is
+// needed and, not being in settingDefs, is not shown in the admin.
+const settingLikeKey = "like_key"
+
+// likePageSize is the number of posts on each page of the admin's most
+// liked report.
+const likePageSize = 50
+
+// LikedPost is a post in the most liked report, with its number of likes.
+type LikedPost = dbgen.ListMostLikedPostsRow
+
+// liker returns who r counts as when liking a post: a hash of its
+// address, or IPv6 /64, keyed so that the address cannot be found again
+// by hashing every one.
+func (s *Server) liker(r *http.Request) (string, error) {
+	key, err := s.storedKey(r.Context(), settingLikeKey)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(throttleKey(r)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// HandleLike records a reader's like of a post, from the ♥ button under
+// it, and sends them back to the post. Each reader counts once per post;
+// liking it again changes nothing. Like the comment form, the button has
+// no CSRF token.
+func (s *Server) HandleLike(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	p, err := q.GetPostBySlug(r.Context(), r.PathValue("slug"))
+	if err != nil || p.Published == 0 || p.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+	visitor, err := s.liker(r)
+	if err == nil {
+		_, err = q.AddPostLike(r.Context(), dbgen.AddPostLikeParams{PostID: p.ID, Visitor: visitor})
+	}
+	if err != nil {
+		slog.Error("add post like", "post", p.Slug, "error", err)
+		http.Error(w, "Failed to record your like", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/post/"+p.Slug+"?liked=1#likes", http.StatusSeeOther)
+}
+
+// postLikes returns the number of likes of the post with the given ID.
+func (s *Server) postLikes(ctx context.Context, postID int64) int64 {
+	n, err := dbgen.New(s.DB).CountPostLikes(ctx, postID)
+	if err != nil {
+		slog.Error("count post likes", "error", err)
+	}
+	return n
+}
+
+// likesChangedAt returns when the post with the given ID was last liked,
+// or the zero time if it never was.
+func (s *Server) likesChangedAt(ctx context.Context, postID int64) time.Time {
+	changed, err := dbgen.New(s.DB).GetPostLikesChangedAt(ctx, postID)
+	if err != nil {
+		slog.Error("get post likes changed at", "error", err)
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.DateTime, changed)
+	return t
+}
+
+// listLikedPosts returns a page of the liked posts, sorted by "title",
+// "created" or, for anything else, most liked first.
+func listLikedPosts(ctx context.Context, q *dbgen.Queries, sort string, page dbgen.ListMostLikedPostsParams) ([]LikedPost, error) {
+	var posts []LikedPost
+	switch sort {
+	case "title":
+		rows, err := q.ListLikedPostsByTitle(ctx, dbgen.ListLikedPostsByTitleParams(page))
+		for _, row := range rows {
+			posts = append(posts, LikedPost(row))
+		}
+		return posts, err
+	case "created":
+		rows, err := q.ListLikedPostsByCreated(ctx, dbgen.ListLikedPostsByCreatedParams(page))
+		for _, row := range rows {
+			posts = append(posts, LikedPost(row))
+		}
+		return posts, err
+	default:
+		return q.ListMostLikedPosts(ctx, page)
+	}
+}
+
+// HandleAdminLikes shows the most liked report: the posts readers have
+// liked, with their likes, a page at a time. The sort query parameter
+// sorts it by "likes", "created" or "title".
+func (s *Server) HandleAdminLikes(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	sort := r.URL.Query().Get("sort")
+	if sort != "created" && sort != "title" {
+		sort = "likes"
+	}
+	page := pageNumber(r)
+	counts, err := q.CountLikes(r.Context())
+	if err != nil {
+		slog.Error("count likes", "error", err)
+	}
+	posts, err := listLikedPosts(r.Context(), q, sort, dbgen.ListMostLikedPostsParams{
+		Limit:  likePageSize,
+		Offset: int64((page - 1) * likePageSize),
+	})
+	if err != nil {
+		slog.Error("list liked posts", "error", err)
+	}
+	s.render(w, "admin_likes.html", map[string]any{
+		"Posts":      posts,
+		"Counts":     counts,
+		"Sort":       sort,
+		"Pagination": paginate(page, likePageSize, counts.Posts),
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
+	})
+}
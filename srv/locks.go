@@ -0,0 +1,70 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// editLockTTL is how long an edit lock lasts without being renewed. The
+// editor page renews its lock well within this while it stays open.
+const editLockTTL = 10 * time.Minute
+
+// adminEmail returns the email of the signed-in admin, or "" in dev mode.
+func adminEmail(r *http.Request) string {
+	if email := sessionEmail(r); email != "" {
+		return email
+	}
+	return strings.TrimSpace(r.Header.Get("X-ExeDev-Email"))
+}
+
+// lockPost takes or renews editor's lock on post id. If someone else holds
+// an unexpired lock, it is left alone and their name is returned, unless
+// takeover is set.
+func (s *Server) lockPost(ctx context.Context, id int64, editor string, takeover bool) (string, error) {
+	q := dbgen.New(s.DB)
+	lock, err := q.GetPostLock(ctx, id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return "", err
+	case lock.Editor != editor && lock.ExpiresAt.After(time.Now()) && !takeover:
+		if lock.Editor == "" {
+			return "another admin", nil
+		}
+		return lock.Editor, nil
+	}
+	return "", q.UpsertPostLock(ctx, dbgen.UpsertPostLockParams{
+		PostID:    id,
+		Editor:    editor,
+		ExpiresAt: time.Now().Add(editLockTTL).UTC(),
+	})
+}
+
+// HandleAdminEditLock renews the caller's lock on a post. It answers 409
+// Conflict if someone else has taken the lock over.
+func (s *Server) HandleAdminEditLock(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	holder, err := s.lockPost(r.Context(), id, adminEmail(r), false)
+	if err != nil {
+		slog.Error("lock post", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to lock post"})
+		return
+	}
+	if holder != "" {
+		writeJSON(w, http.StatusConflict, apiError{Error: "now being edited by " + holder})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package srv
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// loginLinkTTL is how long a sign-in link sent by email works.
+	loginLinkTTL = 15 * time.Minute
+	// maxLoginLinks is the most sign-in links a user may have working at
+	// once, so that asking for them cannot flood their inbox.
+	maxLoginLinks = 3
+)
+
+// HandleLoginLinkRequest emails a link that signs the user in to the
+// address given, if it is a user's. The answer is the same either way,
+// and asking for an unknown address counts as a failed sign-in.
+func (s *Server) HandleLoginLinkRequest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	email := strings.TrimSpace(r.PostFormValue("email"))
+	redirect := localRedirect(r.PostFormValue("redirect"))
+	if !s.mailConfigured(ctx) {
+		http.NotFound(w, r)
+		return
+	}
+	q := dbgen.New(s.DB)
+	if err := q.PruneLoginLinks(ctx, time.Now().UTC()); err != nil {
+		slog.Error("prune login links", "error", err)
+	}
+	user, err := q.GetUserByEmail(ctx, email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		s.loginFailed(r)
+	case err != nil:
+		slog.Error("get user", "error", err)
+		http.Error(w, "Failed to send a sign-in link", http.StatusInternalServerError)
+		return
+	default:
+		s.sendLoginLink(r, user, redirect)
+	}
+	s.render(w, "login.html", map[string]any{
+		"LinkSent":  true,
+		"Minutes":   int(loginLinkTTL / time.Minute),
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// sendLoginLink queues an email to user with a link that signs them in
+// and sends them on to redirect, unless they have maxLoginLinks working
+// already.
+func (s *Server) sendLoginLink(r *http.Request, user dbgen.User, redirect string) {
+	ctx := r.Context()
+	q := dbgen.New(s.DB)
+	now := time.Now().UTC()
+	n, err := q.CountLoginLinks(ctx, dbgen.CountLoginLinksParams{UserID: user.ID, ExpiresAt: now})
+	if err != nil {
+		slog.Error("count login links", "error", err)
+		return
+	}
+	if n >= maxLoginLinks {
+		slog.Warn("not sending another login link", "user", user.ID)
+		return
+	}
+	token := rand.Text()
+	err = q.CreateLoginLink(ctx, dbgen.CreateLoginLinkParams{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Redirect:  redirect,
+		ExpiresAt: now.Add(loginLinkTTL),
+	})
+	if err != nil {
+		slog.Error("create login link", "error", err)
+		return
+	}
+	m, err := s.mailMessage("login-link", user.Email, map[string]any{
+		"Link":    s.baseURL(r) + "/login/link?token=" + url.QueryEscape(token),
+		"Minutes": int(loginLinkTTL / time.Minute),
+	})
+	if err == nil {
+		err = s.queueMail(ctx, m)
+	}
+	if err != nil {
+		slog.Error("queue login link", "user", user.ID, "error", err)
+	}
+}
+
+// HandleLoginLink shows a button that signs in with the link followed.
+// The link is only used up once the button is pressed, as mail filters
+// may follow the links in an email.
+func (s *Server) HandleLoginLink(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "login.html", map[string]any{
+		"LinkToken": r.URL.Query().Get("token"),
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// HandleLoginLinkUse signs in with a link sent by email, or, for a user
+// with two-factor authentication, goes on to ask for their code.
+func (s *Server) HandleLoginLinkUse(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	link, err := q.TakeLoginLink(r.Context(), hashToken(r.PostFormValue("token")))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("take login link", "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || link.ExpiresAt.Before(time.Now()) {
+		s.loginFailed(r)
+		s.renderStatus(w, http.StatusUnauthorized, "login.html", map[string]any{
+			"Error":      "That sign-in link has expired or been used. Ask for another below.",
+			"Redirect":   "/admin",
+			"LoginLinks": s.mailConfigured(r.Context()),
+			"CSRFToken":  csrfToken(r),
+			"Year":       time.Now().Year(),
+		})
+		return
+	}
+	user, err := q.GetUserByID(r.Context(), link.UserID)
+	if err != nil {
+		slog.Error("get user", "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	if user.TotpSecret != "" {
+		s.startLoginChallenge(w, r, user.ID, link.Redirect)
+		return
+	}
+	s.startSession(w, r, user.ID, link.Redirect)
+}
@@ -0,0 +1,229 @@
+// Package mail sends email through an SMTP server. Messages have a plain
+// text part and, optionally, an HTML one, and are usually made from a
+// pair of templates loaded with LoadTemplates.
+//
+// Sending is one attempt; whoever sends keeps the message and, when
+// Temporary says the failure may pass, tries again after the next of
+// Retries.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Timeout bounds the whole conversation with the server for one message.
+const Timeout = 30 * time.Second
+
+// Retries are the waits before each retry of a message the server could
+// not take for the moment. Once they are used up, it is given up on.
+var Retries = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// ErrNotConfigured is returned by Send when the config has no server or
+// no address to send from.
+var ErrNotConfigured = errors.New("mail: no server or sender is set up")
+
+// headerEscaper keeps line breaks out of header values.
+var headerEscaper = strings.NewReplacer("\r", " ", "\n", " ")
+
+// TLS is how the connection to the server is secured.
+type TLS string
+
+const (
+	// StartTLS upgrades a plain connection with STARTTLS and refuses a
+	// server that does not offer it. Servers take it on port 587.
+	StartTLS TLS = "starttls"
+	// Implicit connects over TLS from the start, usually on port 465.
+	Implicit TLS = "tls"
+	// None leaves the connection in the clear, for a relay on the same
+	// machine or network. Passwords are still only sent to localhost.
+	None TLS = "none"
+)
+
+// Config says how to reach the SMTP server and who mail is from.
+type Config struct {
+	Server   string // host:port
+	Username string // empty if the server takes mail without signing in
+	Password string
+	TLS      TLS    // StartTLS if empty
+	From     string // an address, with or without a name
+}
+
+// Configured reports whether c has enough to send mail with.
+func (c Config) Configured() bool {
+	return c.Server != "" && c.From != ""
+}
+
+// Message is an email to send.
+type Message struct {
+	To      string // a bare address
+	Subject string
+	Text    string
+	HTML    string            // empty for a plain-text email
+	Header  map[string]string // further headers, such as List-Unsubscribe
+}
+
+// Send sends m through the server in c.
+func Send(ctx context.Context, c Config, m Message) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return fmt.Errorf("mail: sender: %w", err)
+	}
+	host, _, err := net.SplitHostPort(c.Server)
+	if err != nil {
+		return fmt.Errorf("mail: server: %w", err)
+	}
+	msg, err := m.Bytes(from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	tlsConfig := &tls.Config{ServerName: host}
+	var conn net.Conn
+	if c.TLS == Implicit {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", c.Server)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", c.Server)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if c.TLS == StartTLS || c.TLS == "" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("mail: the server does not offer STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Temporary reports whether a message that failed to send with err may be
+// taken if sent again later: the server could not be reached or answered
+// with a 4xx code. Refusals with a 5xx code, such as a wrong password or
+// an unknown recipient, are final.
+func Temporary(err error) bool {
+	if errors.Is(err, ErrNotConfigured) {
+		return false
+	}
+	var te *textproto.Error
+	if errors.As(err, &te) {
+		return te.Code < 500
+	}
+	return true
+}
+
+// Bytes returns m, from from and sent at now, as it is handed to the
+// server: its headers, then its text, or its text and HTML as
+// alternatives, as quoted-printable.
+func (m Message) Bytes(from *mail.Address, now time.Time) ([]byte, error) {
+	_, domain, _ := strings.Cut(from.Address, "@")
+	header := map[string]string{
+		"From":         from.String(),
+		"To":           m.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", headerEscaper.Replace(m.Subject)),
+		"Date":         now.Format(time.RFC1123Z),
+		"Message-ID":   "<" + rand.Text() + "@" + domain + ">",
+		"MIME-Version": "1.0",
+	}
+	maps.Copy(header, m.Header)
+
+	var body bytes.Buffer
+	if m.HTML == "" {
+		header["Content-Type"] = "text/plain; charset=utf-8"
+		header["Content-Transfer-Encoding"] = "quoted-printable"
+		if err := writeQuotedPrintable(&body, m.Text); err != nil {
+			return nil, err
+		}
+	} else {
+		mw := multipart.NewWriter(&body)
+		header["Content-Type"] = "multipart/alternative; boundary=" + mw.Boundary()
+		for _, part := range []struct{ contentType, content string }{
+			{"text/plain; charset=utf-8", m.Text},
+			{"text/html; charset=utf-8", m.HTML},
+		} {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := writeQuotedPrintable(pw, part.content); err != nil {
+				return nil, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	for _, k := range slices.Sorted(maps.Keys(header)) {
+		b.WriteString(k + ": " + headerEscaper.Replace(header[k]) + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.Bytes(), nil
+}
+
+// writeQuotedPrintable writes text to w as quoted-printable, with CRLF
+// line endings.
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
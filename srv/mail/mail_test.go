@@ -0,0 +1,200 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBytes(t *testing.T) {
+	from := &mail.Address{Name: "Citizen of the World", Address: "blog@example.com"}
+	m := Message{
+		To:      "reader@example.net",
+		Subject: "Café notes\r\nBcc: someone@example.org",
+		Text:    "Hello,\nthere.",
+		Header:  map[string]string{"List-Unsubscribe": "<https://blog.example/unsubscribe?token=abc>"},
+	}
+	data, err := m.Bytes(from, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Café notes  Bcc: someone@example.org" || msg.Header.Get("Bcc") != "" {
+		t.Errorf("subject = %q, %v", subject, err)
+	}
+	if msg.Header.Get("From") != `"Citizen of the World" <blog@example.com>` || msg.Header.Get("Date") != "Fri, 02 Jan 2026 03:04:05 +0000" {
+		t.Errorf("headers = %v", msg.Header)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-Id"), "@example.com>") || msg.Header.Get("List-Unsubscribe") == "" {
+		t.Errorf("headers = %v", msg.Header)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	if string(body) != "Hello,\r\nthere." {
+		t.Errorf("body = %q", body)
+	}
+
+	m.HTML = "<p>Hello, there.</p>"
+	data, err = m.Bytes(from, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ = mail.ReadMessage(bytes.NewReader(data))
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q", msg.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	for {
+		// NextPart decodes quoted-printable parts.
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type")+": "+string(content))
+	}
+	if len(types) != 2 || types[0] != "text/plain; charset=utf-8: Hello,\r\nthere." || types[1] != "text/html; charset=utf-8: <p>Hello, there.</p>" {
+		t.Errorf("parts = %q", types)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"welcome.txt":  "Welcome to {{.Site}}\n\nHi {{.Name}}, & welcome.\n",
+		"welcome.html": "<p>Hi {{.Name}}, &amp; welcome.</p>",
+		"plain.txt":    "Just text\n\nNothing more.",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmpl, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := tmpl.Message("welcome", "ann@example.net", map[string]string{"Site": "The Blog", "Name": "<Ann>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Message{To: "ann@example.net", Subject: "Welcome to The Blog", Text: "Hi <Ann>, & welcome.\n", HTML: "<p>Hi &lt;Ann&gt;, &amp; welcome.</p>"}
+	if m.To != want.To || m.Subject != want.Subject || m.Text != want.Text || m.HTML != want.HTML {
+		t.Errorf("Message = %+v, want %+v", m, want)
+	}
+	if m, err := tmpl.Message("plain", "ann@example.net", nil); err != nil || m.Subject != "Just text" || m.HTML != "" {
+		t.Errorf("plain Message = %+v, %v", m, err)
+	}
+	if _, err := tmpl.Message("missing", "ann@example.net", nil); err == nil {
+		t.Error("expected an error for a missing template")
+	}
+}
+
+func TestTemporary(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{&textproto.Error{Code: 421, Msg: "busy"}, true},
+		{&textproto.Error{Code: 535, Msg: "bad password"}, false},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{ErrNotConfigured, false},
+	} {
+		if got := Temporary(tt.err); got != tt.want {
+			t.Errorf("Temporary(%v) = %v", tt.err, got)
+		}
+	}
+}
+
+// fakeServer answers SMTP on a local port, taking mail for anyone but
+// unknown@example.net, and returns its address and the messages it takes.
+func fakeServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			tc := textproto.NewConn(conn)
+			tc.PrintfLine("220 localhost ready")
+			for {
+				line, err := tc.ReadLine()
+				if err != nil {
+					break
+				}
+				switch verb, _, _ := strings.Cut(line, " "); strings.ToUpper(verb) {
+				case "EHLO":
+					tc.PrintfLine("250-localhost")
+					tc.PrintfLine("250 AUTH PLAIN")
+				case "AUTH":
+					tc.PrintfLine("235 2.7.0 Authenticated")
+				case "RCPT":
+					if strings.Contains(line, "unknown@") {
+						tc.PrintfLine("550 5.1.1 No such user")
+					} else {
+						tc.PrintfLine("250 OK")
+					}
+				case "DATA":
+					tc.PrintfLine("354 Go ahead")
+					data, _ := tc.ReadDotBytes()
+					messages <- string(data)
+					tc.PrintfLine("250 OK")
+				case "QUIT":
+					tc.PrintfLine("221 Bye")
+				default:
+					tc.PrintfLine("250 OK")
+				}
+			}
+			tc.Close()
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func TestSend(t *testing.T) {
+	addr, messages := fakeServer(t)
+	ctx := context.Background()
+	c := Config{Server: addr, Username: "blog", Password: "secret", TLS: None, From: "Blog <blog@example.com>"}
+	if err := Send(ctx, c, Message{To: "ann@example.net", Subject: "Hello", Text: "Hi."}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-messages; !strings.Contains(msg, "Subject: Hello\n") {
+		t.Errorf("server took %q", msg)
+	}
+
+	err := Send(ctx, c, Message{To: "unknown@example.net", Subject: "Hello", Text: "Hi."})
+	if err == nil || Temporary(err) {
+		t.Errorf("sending to an unknown address: %v", err)
+	}
+	c.TLS = StartTLS
+	if err := Send(ctx, c, Message{To: "ann@example.net", Subject: "Hello", Text: "Hi."}); err == nil {
+		t.Error("expected a server without STARTTLS refused")
+	}
+	if err := Send(ctx, Config{}, Message{To: "ann@example.net"}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("sending without a server: %v", err)
+	}
+}
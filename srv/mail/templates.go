@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Templates are the emails a program sends. Each is a pair of files in
+// one directory: NAME.txt, a text template whose first line is the subject
+// and whose rest, after a blank line, is the text, and NAME.html, an HTML
+// template for the HTML part, which may be left out. Both are executed
+// with the same data.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the templates in dir.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		if t.text[name], err = texttemplate.ParseFiles(path); err != nil {
+			return nil, err
+		}
+		htmlPath := filepath.Join(dir, name+".html")
+		if _, err := os.Stat(htmlPath); err != nil {
+			continue
+		}
+		if t.html[name], err = htmltemplate.ParseFiles(htmlPath); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Message returns the email named name, to to, made with data.
+func (t *Templates) Message(name, to string, data any) (Message, error) {
+	tt, ok := t.text[name]
+	if !ok {
+		return Message{}, fmt.Errorf("mail: no template %q", name)
+	}
+	var b bytes.Buffer
+	if err := tt.Execute(&b, data); err != nil {
+		return Message{}, err
+	}
+	subject, text, _ := strings.Cut(b.String(), "\n")
+	m := Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject),
+		Text:    strings.TrimLeft(text, "\n"),
+	}
+	if ht, ok := t.html[name]; ok {
+		b.Reset()
+		if err := ht.Execute(&b, data); err != nil {
+			return Message{}, err
+		}
+		m.HTML = b.String()
+	}
+	return m, nil
+}
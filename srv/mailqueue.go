@@ -0,0 +1,136 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/mail"
+)
+
+const (
+	// mailInterval is how often the server looks for email due to be
+	// sent, or tried again.
+	mailInterval = time.Minute
+	// mailKeep is how long sent email, and email given up on, stays in
+	// the queue.
+	mailKeep = 30 * 24 * time.Hour
+)
+
+// mailConfig returns the mail server settings.
+func (s *Server) mailConfig(ctx context.Context) mail.Config {
+	return mail.Config{
+		Server:   s.setting(ctx, settingSMTPServer),
+		Username: s.setting(ctx, settingSMTPUsername),
+		Password: s.setting(ctx, settingSMTPPassword),
+		TLS:      mail.TLS(s.setting(ctx, settingSMTPTLS)),
+		From:     s.setting(ctx, settingMailFrom),
+	}
+}
+
+// mailConfigured reports whether the settings say how to send email.
+func (s *Server) mailConfigured(ctx context.Context) bool {
+	return s.mailConfig(ctx).Configured()
+}
+
+// mailMessage returns the email made from the templates named name, to
+// to. data is what they are executed with, along with the site's title
+// as Site.
+func (s *Server) mailMessage(name, to string, data map[string]any) (mail.Message, error) {
+	data["Site"] = siteTitle
+	return s.mailTemplates.Message(name, to, data)
+}
+
+// queueMail queues m to be sent by mailLoop.
+func (s *Server) queueMail(ctx context.Context, m mail.Message) error {
+	headers, err := json.Marshal(m.Header)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err = dbgen.New(s.DB).QueueMail(ctx, dbgen.QueueMailParams{
+		Recipient:     m.To,
+		Subject:       m.Subject,
+		TextBody:      m.Text,
+		HtmlBody:      m.HTML,
+		Headers:       string(headers),
+		NextAttemptAt: &now,
+	})
+	if err != nil {
+		return err
+	}
+	s.requestMail()
+	return nil
+}
+
+// mailLoop sends queued email every mailInterval and whenever some is
+// queued.
+func (s *Server) mailLoop(ctx context.Context) {
+	ticker := time.NewTicker(mailInterval)
+	defer ticker.Stop()
+	for {
+		s.sendQueuedMail(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.mail:
+		}
+	}
+}
+
+// requestMail wakes mailLoop without waiting for it.
+func (s *Server) requestMail() {
+	select {
+	case s.mail <- struct{}{}:
+	default:
+	}
+}
+
+// sendQueuedMail sends the queued email that is due. Email the server
+// could not take for the moment is tried again after the next of
+// mail.Retries; a refusal, such as of an unknown address, is final.
+// Nothing is sent while the mail server is unset, so email waits until it
+// is set.
+func (s *Server) sendQueuedMail(ctx context.Context) {
+	q := dbgen.New(s.DB)
+	if err := q.PruneMailQueue(ctx, dbTime(time.Now().Add(-mailKeep))); err != nil {
+		slog.Error("prune mail queue", "error", err)
+	}
+	config := s.mailConfig(ctx)
+	if !config.Configured() {
+		return
+	}
+	pending, err := q.ListPendingMail(ctx)
+	if err != nil {
+		slog.Error("list pending mail", "error", err)
+		return
+	}
+	for _, qm := range pending {
+		if qm.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		m := mail.Message{To: qm.Recipient, Subject: qm.Subject, Text: qm.TextBody, HTML: qm.HtmlBody}
+		err := json.Unmarshal([]byte(qm.Headers), &m.Header)
+		if err == nil {
+			err = s.sendMail(ctx, config, m)
+		}
+		now := time.Now().UTC()
+		params := dbgen.RecordMailAttemptParams{ID: qm.ID}
+		if err == nil {
+			params.SentAt = &now
+		} else {
+			slog.Warn("send mail", "id", qm.ID, "attempt", qm.Attempts+1, "error", err)
+			params.Error = err.Error()
+			if mail.Temporary(err) && int(qm.Attempts) < len(mail.Retries) {
+				next := now.Add(mail.Retries[qm.Attempts])
+				params.NextAttemptAt = &next
+			}
+		}
+		if err := q.RecordMailAttempt(ctx, params); err != nil {
+			slog.Error("record mail attempt", "error", err)
+		}
+	}
+}
@@ -0,0 +1,682 @@
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The block parser follows the two-phase strategy described in the
+// CommonMark spec: each line first continues or closes the open container
+// blocks, then may start new blocks; inline content is parsed once the
+// block structure is complete.
+
+type continuation int
+
+const (
+	matched continuation = iota
+	notMatched
+	lineDone
+)
+
+type startResult int
+
+const (
+	noStart startResult = iota
+	containerStarted
+	leafStarted
+)
+
+var (
+	reMaybeSpecial    = regexp.MustCompile(`^[#` + "`" + `~*+_=<>|:$\[0-9-]`)
+	reATXHeading      = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	reATXClosing      = regexp.MustCompile(`(?:^|[ \t]+)#+[ \t]*$`)
+	reCodeFence       = regexp.MustCompile("^`{3,}[^`]*$|^~{3,}|^\\$\\$[ \t]*$")
+	reClosingFence    = regexp.MustCompile("^(?:`{3,}|~{3,}|\\$\\$)[ \t]*$")
+	reSetextHeading   = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	reThematicBreak   = regexp.MustCompile(`^(?:\*[ \t]*){3,}$|^(?:_[ \t]*){3,}$|^(?:-[ \t]*){3,}$`)
+	reOrderedListItem = regexp.MustCompile(`^(\d{1,9})([.)])`)
+	reFootnoteDef     = regexp.MustCompile(`^\[\^([^\]\s]+)\]:[ \t]*`)
+	reTableDelimiter  = regexp.MustCompile(`^\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+type blockParser struct {
+	html        bool // recognize HTML blocks
+	doc         *Node
+	tip         *Node
+	oldTip      *Node
+	lastMatched *Node
+	allClosed   bool
+
+	line         string
+	lineNumber   int
+	offset       int
+	nextNonspace int
+	indent       int
+	indented     bool
+	blank        bool
+}
+
+// blockStarts are tried in order at each position where a new block
+// could begin.
+var blockStarts = []func(*blockParser, *Node) startResult{
+	startBlockQuote,
+	startATXHeading,
+	startFencedCode,
+	startHTMLBlock,
+	startTable,
+	startSetextHeading,
+	startThematicBreak,
+	startListItem,
+	startFootnoteDef,
+	startIndentedCode,
+}
+
+func parseBlocks(src string, html bool) *Node {
+	doc := &Node{Kind: Document, open: true}
+	p := &blockParser{html: html, doc: doc, tip: doc, oldTip: doc, lastMatched: doc, allClosed: true}
+
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	lines := strings.Split(src, "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	for _, line := range lines {
+		p.lineNumber++
+		p.incorporateLine(expandLeadingTabs(line))
+	}
+	for p.tip != nil {
+		p.finalize(p.tip)
+	}
+	return doc
+}
+
+// expandLeadingTabs replaces tabs in the leading whitespace of line with
+// spaces up to the next multiple of four columns, so that indentation can
+// be measured in bytes.
+func expandLeadingTabs(line string) string {
+	end := 0
+	for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+		end++
+	}
+	if !strings.Contains(line[:end], "\t") {
+		return line
+	}
+	var sb strings.Builder
+	col := 0
+	for i := 0; i < end; i++ {
+		if line[i] == '\t' {
+			n := 4 - col%4
+			sb.WriteString(strings.Repeat(" ", n))
+			col += n
+		} else {
+			sb.WriteByte(' ')
+			col++
+		}
+	}
+	sb.WriteString(line[end:])
+	return sb.String()
+}
+
+func (p *blockParser) incorporateLine(line string) {
+	p.line = line
+	p.offset = 0
+	p.oldTip = p.tip
+
+	container := p.doc
+	for container.LastChild != nil && container.LastChild.open {
+		child := container.LastChild
+		p.findNextNonspace()
+		c := p.continues(child)
+		if c == lineDone {
+			return
+		}
+		if c == notMatched {
+			break
+		}
+		container = child
+	}
+
+	p.allClosed = container == p.oldTip
+	p.lastMatched = container
+
+	// Paragraphs can be interrupted by new blocks; code and HTML blocks
+	// cannot.
+	matchedLeaf := container.Kind == CodeBlock || container.Kind == HTMLBlock
+	for !matchedLeaf {
+		p.findNextNonspace()
+		if !p.indented && !reMaybeSpecial.MatchString(p.line[p.nextNonspace:]) {
+			p.advanceNextNonspace()
+			break
+		}
+		result := noStart
+		for _, start := range blockStarts {
+			if result = start(p, container); result != noStart {
+				break
+			}
+		}
+		if result == noStart {
+			p.advanceNextNonspace()
+			break
+		}
+		container = p.tip
+		if result == leafStarted {
+			matchedLeaf = true
+		}
+	}
+
+	if !p.allClosed && !p.blank && p.tip.Kind == Paragraph {
+		// Lazy paragraph continuation.
+		p.addLine()
+		return
+	}
+
+	p.closeUnmatchedBlocks()
+	if p.blank && container.LastChild != nil {
+		container.LastChild.lastLineBlank = true
+	}
+
+	lastLineBlank := p.blank &&
+		!(container.Kind == BlockQuote ||
+			(container.Kind == CodeBlock && container.fenced) ||
+			(container.Kind == Item && container.FirstChild == nil && container.startLine == p.lineNumber))
+	for c := container; c != nil; c = c.Parent {
+		c.lastLineBlank = lastLineBlank
+	}
+
+	switch {
+	case acceptsLines(container.Kind):
+		p.addLine()
+		if container.Kind == HTMLBlock && htmlBlockEnds(container.htmlType, p.line[p.offset:]) {
+			p.finalize(container)
+		}
+	case p.offset < len(p.line) && !p.blank:
+		p.addChild(Paragraph)
+		p.advanceNextNonspace()
+		p.addLine()
+	}
+}
+
+// continues reports whether the open block n continues on the current
+// line, consuming any container markers.
+func (p *blockParser) continues(n *Node) continuation {
+	switch n.Kind {
+	case BlockQuote:
+		if !p.indented && p.peek(p.nextNonspace) == '>' {
+			p.advanceNextNonspace()
+			p.advanceOffset(1)
+			if p.peek(p.offset) == ' ' || p.peek(p.offset) == '\t' {
+				p.advanceOffset(1)
+			}
+			return matched
+		}
+		return notMatched
+	case Item:
+		if p.blank {
+			if n.FirstChild == nil {
+				return notMatched
+			}
+			p.advanceNextNonspace()
+			return matched
+		}
+		if p.indent >= n.List.markerOffset+n.List.padding {
+			p.advanceOffset(n.List.markerOffset + n.List.padding)
+			return matched
+		}
+		return notMatched
+	case CodeBlock:
+		if n.fenced {
+			rest := p.line[p.nextNonspace:]
+			if !p.indented && len(rest) > 0 && rest[0] == n.fenceChar && reClosingFence.MatchString(rest) &&
+				len(strings.TrimRight(rest, " \t")) >= n.fenceLength {
+				p.finalize(n)
+				return lineDone
+			}
+			for i := n.fenceOffset; i > 0 && p.peek(p.offset) == ' '; i-- {
+				p.advanceOffset(1)
+			}
+			return matched
+		}
+		if p.indent >= 4 {
+			p.advanceOffset(4)
+			return matched
+		}
+		if p.blank {
+			p.advanceNextNonspace()
+			return matched
+		}
+		return notMatched
+	case Paragraph:
+		if p.blank {
+			return notMatched
+		}
+		return matched
+	case Table:
+		if p.blank {
+			return notMatched
+		}
+		return matched
+	case FootnoteDef:
+		if p.blank {
+			p.advanceNextNonspace()
+			return matched
+		}
+		if p.indent >= 4 {
+			p.advanceOffset(4)
+			return matched
+		}
+		return notMatched
+	case HTMLBlock:
+		if p.blank && n.htmlType >= 6 {
+			return notMatched
+		}
+		return matched
+	case Heading, ThematicBreak:
+		return notMatched
+	}
+	return matched
+}
+
+func (p *blockParser) peek(i int) byte {
+	if i < len(p.line) {
+		return p.line[i]
+	}
+	return 0
+}
+
+func (p *blockParser) findNextNonspace() {
+	i := p.offset
+	for i < len(p.line) && (p.line[i] == ' ' || p.line[i] == '\t') {
+		i++
+	}
+	p.nextNonspace = i
+	p.indent = i - p.offset
+	p.indented = p.indent >= 4
+	p.blank = i == len(p.line)
+}
+
+func (p *blockParser) advanceNextNonspace() {
+	p.offset = p.nextNonspace
+}
+
+func (p *blockParser) advanceOffset(n int) {
+	p.offset = min(p.offset+n, len(p.line))
+}
+
+func (p *blockParser) addLine() {
+	p.tip.lines = append(p.tip.lines, p.line[p.offset:])
+}
+
+func (p *blockParser) addChild(kind Kind) *Node {
+	for !canContain(p.tip.Kind, kind) {
+		p.finalize(p.tip)
+	}
+	n := &Node{Kind: kind, open: true, startLine: p.lineNumber}
+	p.tip.AppendChild(n)
+	p.tip = n
+	return n
+}
+
+func (p *blockParser) closeUnmatchedBlocks() {
+	if p.allClosed {
+		return
+	}
+	for p.oldTip != p.lastMatched {
+		parent := p.oldTip.Parent
+		p.finalize(p.oldTip)
+		p.oldTip = parent
+	}
+	p.allClosed = true
+}
+
+// finalize closes block n and makes its parent the tip.
+func (p *blockParser) finalize(n *Node) {
+	n.open = false
+	switch n.Kind {
+	case Paragraph:
+		for i, l := range n.lines {
+			n.lines[i] = strings.TrimLeft(l, " \t")
+		}
+		n.content = strings.TrimRight(strings.Join(n.lines, "\n"), " \t")
+	case CodeBlock:
+		if n.fenced {
+			if len(n.lines) > 0 {
+				n.Info = unescapeString(strings.TrimSpace(n.lines[0]))
+				n.lines = n.lines[1:]
+			}
+		} else {
+			for len(n.lines) > 0 && strings.TrimSpace(n.lines[len(n.lines)-1]) == "" {
+				n.lines = n.lines[:len(n.lines)-1]
+			}
+		}
+		if len(n.lines) > 0 {
+			n.Literal = strings.Join(n.lines, "\n") + "\n"
+		}
+		// $$ fences and ```math blocks hold display math.
+		if n.fenceChar == '$' || n.Info == "math" {
+			n.Kind = MathBlock
+		}
+	case HTMLBlock:
+		n.Literal = strings.TrimRight(strings.Join(n.lines, "\n"), "\n ")
+	case List:
+		n.List.Tight = listIsTight(n)
+	case Table:
+		// The first line is the consumed remainder of the delimiter row.
+		for _, line := range n.lines[1:] {
+			addTableRow(n, splitTableRow(line))
+		}
+	}
+	n.lines = nil
+	p.tip = n.Parent
+}
+
+func endsWithBlankLine(n *Node) bool {
+	for n != nil {
+		if n.lastLineBlank {
+			return true
+		}
+		if n.Kind != List && n.Kind != Item {
+			break
+		}
+		n = n.LastChild
+	}
+	return false
+}
+
+func listIsTight(list *Node) bool {
+	for item := list.FirstChild; item != nil; item = item.Next {
+		if endsWithBlankLine(item) && item.Next != nil {
+			return false
+		}
+		for sub := item.FirstChild; sub != nil; sub = sub.Next {
+			if endsWithBlankLine(sub) && (item.Next != nil || sub.Next != nil) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func startBlockQuote(p *blockParser, _ *Node) startResult {
+	if p.indented || p.peek(p.nextNonspace) != '>' {
+		return noStart
+	}
+	p.advanceNextNonspace()
+	p.advanceOffset(1)
+	if p.peek(p.offset) == ' ' || p.peek(p.offset) == '\t' {
+		p.advanceOffset(1)
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(BlockQuote)
+	return containerStarted
+}
+
+func startATXHeading(p *blockParser, _ *Node) startResult {
+	if p.indented {
+		return noStart
+	}
+	rest := p.line[p.nextNonspace:]
+	m := reATXHeading.FindString(rest)
+	if m == "" {
+		return noStart
+	}
+	p.closeUnmatchedBlocks()
+	h := p.addChild(Heading)
+	h.Level = strings.Count(strings.TrimRight(m, " \t"), "#")
+	text := strings.TrimSpace(rest[len(m):])
+	text = strings.TrimSpace(reATXClosing.ReplaceAllString(text, ""))
+	h.content = text
+	p.offset = len(p.line)
+	return leafStarted
+}
+
+func startFencedCode(p *blockParser, _ *Node) startResult {
+	if p.indented {
+		return noStart
+	}
+	rest := p.line[p.nextNonspace:]
+	if !reCodeFence.MatchString(rest) {
+		return noStart
+	}
+	n := 0
+	for n < len(rest) && rest[n] == rest[0] {
+		n++
+	}
+	p.closeUnmatchedBlocks()
+	c := p.addChild(CodeBlock)
+	c.fenced = true
+	c.fenceChar = rest[0]
+	c.fenceLength = n
+	c.fenceOffset = p.indent
+	p.advanceNextNonspace()
+	p.advanceOffset(n)
+	return leafStarted
+}
+
+func startSetextHeading(p *blockParser, container *Node) startResult {
+	if p.indented || container.Kind != Paragraph || !reSetextHeading.MatchString(p.line[p.nextNonspace:]) {
+		return noStart
+	}
+	p.closeUnmatchedBlocks()
+	h := &Node{Kind: Heading, Level: 2, startLine: container.startLine}
+	if p.line[p.nextNonspace] == '=' {
+		h.Level = 1
+	}
+	lines := make([]string, len(container.lines))
+	for i, l := range container.lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	h.content = strings.Join(lines, "\n")
+	container.InsertAfter(h)
+	container.Unlink()
+	p.tip = h
+	p.offset = len(p.line)
+	return leafStarted
+}
+
+func startThematicBreak(p *blockParser, _ *Node) startResult {
+	if p.indented || !reThematicBreak.MatchString(p.line[p.nextNonspace:]) {
+		return noStart
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(ThematicBreak)
+	p.offset = len(p.line)
+	return leafStarted
+}
+
+func startListItem(p *blockParser, container *Node) startResult {
+	data := p.parseListMarker(container)
+	if data == nil {
+		return noStart
+	}
+	p.closeUnmatchedBlocks()
+	if p.tip.Kind != List || !listsMatch(p.tip.List, data) {
+		list := p.addChild(List)
+		list.List = &ListData{
+			Ordered:   data.Ordered,
+			Bullet:    data.Bullet,
+			Delimiter: data.Delimiter,
+			Start:     data.Start,
+		}
+	}
+	item := p.addChild(Item)
+	item.List = data
+	return containerStarted
+}
+
+func listsMatch(list, item *ListData) bool {
+	return list.Ordered == item.Ordered && list.Bullet == item.Bullet && list.Delimiter == item.Delimiter
+}
+
+// parseListMarker recognizes a bullet or ordered list marker at the next
+// non-space position and consumes it along with the following spaces.
+func (p *blockParser) parseListMarker(container *Node) *ListData {
+	if p.indented {
+		return nil
+	}
+	rest := p.line[p.nextNonspace:]
+	data := &ListData{markerOffset: p.indent}
+	markerLen := 0
+	switch {
+	case len(rest) > 0 && strings.IndexByte("*+-", rest[0]) >= 0:
+		data.Bullet = rest[0]
+		markerLen = 1
+	default:
+		m := reOrderedListItem.FindStringSubmatch(rest)
+		if m == nil {
+			return nil
+		}
+		start, _ := strconv.Atoi(m[1])
+		if container.Kind == Paragraph && start != 1 {
+			return nil
+		}
+		data.Ordered = true
+		data.Start = start
+		data.Delimiter = m[2][0]
+		markerLen = len(m[0])
+	}
+
+	// The marker must be followed by whitespace or the end of the line.
+	next := p.peek(p.nextNonspace + markerLen)
+	if next != 0 && next != ' ' && next != '\t' {
+		return nil
+	}
+	// An empty list item cannot interrupt a paragraph.
+	if container.Kind == Paragraph && strings.TrimSpace(rest[markerLen:]) == "" {
+		return nil
+	}
+
+	p.advanceNextNonspace()
+	p.advanceOffset(markerLen)
+	spacesStart := p.offset
+	for p.offset-spacesStart < 5 && (p.peek(p.offset) == ' ' || p.peek(p.offset) == '\t') {
+		p.advanceOffset(1)
+	}
+	spaces := p.offset - spacesStart
+	blankItem := p.offset == len(p.line)
+	if spaces >= 5 || spaces < 1 || blankItem {
+		data.padding = markerLen + 1
+		p.offset = spacesStart
+		if p.peek(p.offset) == ' ' || p.peek(p.offset) == '\t' {
+			p.advanceOffset(1)
+		}
+	} else {
+		data.padding = markerLen + spaces
+	}
+	return data
+}
+
+func startIndentedCode(p *blockParser, _ *Node) startResult {
+	if !p.indented || p.tip.Kind == Paragraph || p.blank {
+		return noStart
+	}
+	p.advanceOffset(4)
+	p.closeUnmatchedBlocks()
+	p.addChild(CodeBlock)
+	return leafStarted
+}
+
+// startTable turns the last line of a paragraph into a table header when
+// it is followed by a delimiter row with the same number of cells.
+func startTable(p *blockParser, container *Node) startResult {
+	rest := p.line[p.nextNonspace:]
+	if p.indented || container.Kind != Paragraph || !strings.Contains(rest, "|") || !reTableDelimiter.MatchString(rest) {
+		return noStart
+	}
+	header := splitTableRow(container.lines[len(container.lines)-1])
+	delims := splitTableRow(rest)
+	if len(header) != len(delims) {
+		return noStart
+	}
+
+	p.closeUnmatchedBlocks()
+	table := &Node{Kind: Table, open: true, startLine: p.lineNumber}
+	container.InsertAfter(table)
+	container.lines = container.lines[:len(container.lines)-1]
+	if len(container.lines) == 0 {
+		container.Unlink()
+	} else {
+		p.finalize(container)
+	}
+
+	aligns := make([]string, len(delims))
+	for i, d := range delims {
+		left, right := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":")
+		switch {
+		case left && right:
+			aligns[i] = "center"
+		case left:
+			aligns[i] = "left"
+		case right:
+			aligns[i] = "right"
+		}
+	}
+	table.aligns = aligns
+	addTableRow(table, header)
+
+	p.tip = table
+	p.offset = len(p.line)
+	return leafStarted
+}
+
+// addTableRow appends a row to table, padding or truncating cells to the
+// number of columns in the header.
+func addTableRow(table *Node, cells []string) {
+	row := &Node{Kind: TableRow}
+	for i, align := range table.aligns {
+		cell := &Node{Kind: TableCell, Align: align}
+		if i < len(cells) {
+			cell.content = cells[i]
+		}
+		row.AppendChild(cell)
+	}
+	table.AppendChild(row)
+}
+
+// splitTableRow splits a table row on unescaped pipes, ignoring a leading
+// and trailing pipe. Escaped pipes become literal pipes.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// startFootnoteDef opens a footnote definition. Its content continues on
+// lines indented by four spaces.
+func startFootnoteDef(p *blockParser, container *Node) startResult {
+	if p.indented || container.Kind == Paragraph {
+		return noStart
+	}
+	m := reFootnoteDef.FindStringSubmatch(p.line[p.nextNonspace:])
+	if m == nil {
+		return noStart
+	}
+	p.closeUnmatchedBlocks()
+	def := p.addChild(FootnoteDef)
+	def.Label = normalizeLabel(m[1])
+	p.advanceNextNonspace()
+	p.advanceOffset(len(m[0]))
+	return containerStarted
+}
+
+func normalizeLabel(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
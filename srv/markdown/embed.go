@@ -0,0 +1,32 @@
+package markdown
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+var reBareURL = regexp.MustCompile(`^https?://[^\s<>"]+$`)
+
+// embedURLs replaces each paragraph holding only a bare URL with the HTML
+// returned by embed, where it accepts the URL.
+func embedURLs(doc *Node, embed func(string) (template.HTML, bool)) {
+	doc.Walk(func(n *Node) bool {
+		if n.Kind != Paragraph {
+			return true
+		}
+		text := n.FirstChild
+		if text == nil || text != n.LastChild || text.Kind != Text {
+			return false
+		}
+		url := strings.TrimSpace(text.Literal)
+		if !reBareURL.MatchString(url) {
+			return false
+		}
+		if html, ok := embed(url); ok {
+			n.InsertBefore(&Node{Kind: Embed, Literal: string(html), Dest: url})
+			n.Unlink()
+		}
+		return false
+	})
+}
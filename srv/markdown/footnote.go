@@ -0,0 +1,46 @@
+package markdown
+
+// collectFootnotes numbers footnote references in document order and
+// moves the referenced definitions into a Footnotes section at the end of
+// doc. Definitions that are never referenced are dropped.
+func collectFootnotes(doc *Node) {
+	defs := make(map[string]*Node)
+	doc.Walk(func(n *Node) bool {
+		if n.Kind == FootnoteDef && defs[n.Label] == nil {
+			defs[n.Label] = n
+		}
+		return true
+	})
+
+	var order []*Node
+	doc.Walk(func(n *Node) bool {
+		if n.Kind != FootnoteRef {
+			return true
+		}
+		def := defs[n.Label]
+		if def.Index == 0 {
+			order = append(order, def)
+			def.Index = len(order)
+		}
+		def.Ref++
+		n.Index = def.Index
+		n.Ref = def.Ref
+		return true
+	})
+
+	doc.Walk(func(n *Node) bool {
+		if n.Kind == FootnoteDef {
+			n.Unlink()
+			return false
+		}
+		return true
+	})
+	if len(order) == 0 {
+		return
+	}
+	section := &Node{Kind: Footnotes}
+	for _, def := range order {
+		section.AppendChild(def)
+	}
+	doc.AppendChild(section)
+}
@@ -0,0 +1,307 @@
+package markdown
+
+import (
+	"html/template"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/srv/highlight"
+	"srv.exe.dev/srv/sanitize"
+)
+
+type htmlRenderer struct {
+	sb strings.Builder
+}
+
+func (r *htmlRenderer) children(n *Node) {
+	for c := n.FirstChild; c != nil; c = c.Next {
+		r.render(c)
+	}
+}
+
+func (r *htmlRenderer) text(s string) {
+	r.sb.WriteString(template.HTMLEscapeString(s))
+}
+
+func (r *htmlRenderer) render(n *Node) {
+	switch n.Kind {
+	case Document:
+		r.children(n)
+	case Paragraph:
+		if item := n.Parent; item != nil && item.Kind == Item && item.Parent.List.Tight {
+			r.children(n)
+			if n.Next != nil {
+				r.sb.WriteString("\n")
+			}
+			return
+		}
+		if img := n.FirstChild; img != nil && img == n.LastChild && img.Kind == Image && img.Title != "" {
+			r.figure(img)
+			return
+		}
+		if m := n.FirstChild; m != nil && m == n.LastChild && m.Kind == DisplayMath {
+			r.math("div", true, m.Literal)
+			r.sb.WriteString("\n")
+			return
+		}
+		if sc := n.FirstChild; sc != nil && sc == n.LastChild && sc.Kind == Shortcode {
+			if embed, ok := expandShortcode(sc); ok {
+				r.sb.WriteString(string(embed) + "\n")
+				return
+			}
+		}
+		r.sb.WriteString("<p>")
+		r.children(n)
+		r.sb.WriteString("</p>\n")
+	case Heading:
+		tag := "h" + strconv.Itoa(headingLevel(n))
+		r.sb.WriteString("<" + tag)
+		if n.ID != "" {
+			r.sb.WriteString(` id="`)
+			r.text(n.ID)
+			r.sb.WriteString(`"`)
+		}
+		r.sb.WriteString(">")
+		r.children(n)
+		r.sb.WriteString("</" + tag + ">\n")
+	case ThematicBreak:
+		r.sb.WriteString("<hr>\n")
+	case Embed:
+		r.sb.WriteString(n.Literal + "\n")
+	case CodeBlock:
+		r.sb.WriteString("<pre><code")
+		lang, _, _ := strings.Cut(n.Info, " ")
+		if lang != "" {
+			r.sb.WriteString(` class="language-`)
+			r.text(lang)
+			r.sb.WriteString(`"`)
+		}
+		r.sb.WriteString(">")
+		if code, ok := highlight.HTML(lang, n.Literal); ok {
+			r.sb.WriteString(code)
+		} else {
+			r.text(n.Literal)
+		}
+		r.sb.WriteString("</code></pre>\n")
+	case HTMLBlock:
+		if html := sanitize.HTML(n.Literal); strings.TrimSpace(html) != "" {
+			r.sb.WriteString(html + "\n")
+		}
+	case MathBlock:
+		r.math("div", true, strings.TrimSuffix(n.Literal, "\n"))
+		r.sb.WriteString("\n")
+	case BlockQuote:
+		r.sb.WriteString("<blockquote>\n")
+		r.children(n)
+		r.sb.WriteString("</blockquote>\n")
+	case List:
+		tag := "ul"
+		if n.List.Ordered {
+			tag = "ol"
+		}
+		r.sb.WriteString("<" + tag)
+		if n.List.Ordered && n.List.Start != 1 {
+			r.sb.WriteString(` start="` + strconv.Itoa(n.List.Start) + `"`)
+		}
+		r.sb.WriteString(">\n")
+		r.children(n)
+		r.sb.WriteString("</" + tag + ">\n")
+	case Item:
+		r.sb.WriteString("<li>")
+		if n.FirstChild != nil && (n.FirstChild.Kind != Paragraph || !n.Parent.List.Tight) {
+			r.sb.WriteString("\n")
+		}
+		r.children(n)
+		r.sb.WriteString("</li>\n")
+	case Table:
+		r.sb.WriteString("<table>\n<thead>\n")
+		r.render(n.FirstChild)
+		r.sb.WriteString("</thead>\n")
+		if n.FirstChild.Next != nil {
+			r.sb.WriteString("<tbody>\n")
+			for row := n.FirstChild.Next; row != nil; row = row.Next {
+				r.render(row)
+			}
+			r.sb.WriteString("</tbody>\n")
+		}
+		r.sb.WriteString("</table>\n")
+	case TableRow:
+		r.sb.WriteString("<tr>\n")
+		r.children(n)
+		r.sb.WriteString("</tr>\n")
+	case TableCell:
+		tag := "td"
+		if n.Parent == n.Parent.Parent.FirstChild {
+			tag = "th"
+		}
+		r.sb.WriteString("<" + tag)
+		if n.Align != "" {
+			r.sb.WriteString(` style="text-align: ` + n.Align + `"`)
+		}
+		r.sb.WriteString(">")
+		r.children(n)
+		r.sb.WriteString("</" + tag + ">\n")
+	case Footnotes:
+		r.sb.WriteString("<section class=\"footnotes\">\n<ol>\n")
+		r.children(n)
+		r.sb.WriteString("</ol>\n</section>\n")
+	case FootnoteDef:
+		r.sb.WriteString(`<li id="fn-` + strconv.Itoa(n.Index) + `">` + "\n")
+		for c := n.FirstChild; c != nil; c = c.Next {
+			if c == n.LastChild && c.Kind == Paragraph {
+				r.sb.WriteString("<p>")
+				r.children(c)
+				r.backrefs(n)
+				r.sb.WriteString("</p>\n")
+			} else {
+				r.render(c)
+			}
+		}
+		if n.LastChild == nil || n.LastChild.Kind != Paragraph {
+			r.backrefs(n)
+			r.sb.WriteString("\n")
+		}
+		r.sb.WriteString("</li>\n")
+	case FootnoteRef:
+		id := footnoteRefID(n.Index, n.Ref)
+		num := strconv.Itoa(n.Index)
+		r.sb.WriteString(`<sup class="footnote-ref" id="` + id + `"><a href="#fn-` + num + `">` + num + `</a></sup>`)
+	case Text:
+		r.text(n.Literal)
+	case Shortcode:
+		if embed, ok := expandShortcode(n); ok {
+			r.sb.WriteString(string(embed))
+		} else {
+			r.text(n.Literal)
+		}
+	case Code:
+		r.sb.WriteString("<code>")
+		r.text(n.Literal)
+		r.sb.WriteString("</code>")
+	case RawHTML:
+		r.sb.WriteString(sanitize.HTML(n.Literal))
+	case Math:
+		r.math("span", false, n.Literal)
+	case DisplayMath:
+		r.math("span", true, n.Literal)
+	case Emph:
+		r.sb.WriteString("<em>")
+		r.children(n)
+		r.sb.WriteString("</em>")
+	case Strong:
+		r.sb.WriteString("<strong>")
+		r.children(n)
+		r.sb.WriteString("</strong>")
+	case Strikethrough:
+		r.sb.WriteString("<del>")
+		r.children(n)
+		r.sb.WriteString("</del>")
+	case Link:
+		href, ok := safeURL(n.Dest)
+		if !ok {
+			r.children(n)
+			return
+		}
+		r.sb.WriteString(`<a href="`)
+		r.text(href)
+		r.sb.WriteString(`"`)
+		if n.Title != "" {
+			r.sb.WriteString(` title="`)
+			r.text(n.Title)
+			r.sb.WriteString(`"`)
+		}
+		if isExternal(href) {
+			r.sb.WriteString(` rel="noopener"`)
+		}
+		r.sb.WriteString(">")
+		r.children(n)
+		r.sb.WriteString("</a>")
+	case Image:
+		r.image(n, true)
+	case HardBreak:
+		r.sb.WriteString("<br>\n")
+	case SoftBreak:
+		r.sb.WriteString("\n")
+	}
+}
+
+// figure renders a titled image that stands alone in its paragraph, using
+// the title as the caption.
+func (r *htmlRenderer) figure(img *Node) {
+	r.sb.WriteString("<figure>")
+	r.image(img, false)
+	r.sb.WriteString("<figcaption>")
+	r.text(img.Title)
+	r.sb.WriteString("</figcaption></figure>\n")
+}
+
+func (r *htmlRenderer) image(n *Node, withTitle bool) {
+	src, ok := safeURL(n.Dest)
+	if !ok {
+		r.text(plainText(n))
+		return
+	}
+	r.sb.WriteString(`<img src="`)
+	r.text(src)
+	r.sb.WriteString(`" alt="`)
+	r.text(plainText(n))
+	r.sb.WriteString(`"`)
+	if withTitle && n.Title != "" {
+		r.sb.WriteString(` title="`)
+		r.text(n.Title)
+		r.sb.WriteString(`"`)
+	}
+	if n.Width > 0 {
+		r.sb.WriteString(` width="` + strconv.Itoa(n.Width) + `"`)
+	}
+	if n.Height > 0 {
+		r.sb.WriteString(` height="` + strconv.Itoa(n.Height) + `"`)
+	}
+	r.sb.WriteString(` loading="lazy">`)
+}
+
+// math writes TeX source wrapped in the \( \) or \[ \] delimiters that
+// the browser-side typesetter looks for.
+func (r *htmlRenderer) math(tag string, display bool, tex string) {
+	class, open, close := "math-inline", `\(`, `\)`
+	if display {
+		class, open, close = "math-display", `\[`, `\]`
+	}
+	r.sb.WriteString("<" + tag + ` class="math ` + class + `">` + open)
+	r.text(tex)
+	r.sb.WriteString(close + "</" + tag + ">")
+}
+
+// plainText returns the text content of n's descendants.
+func plainText(n *Node) string {
+	var sb strings.Builder
+	n.Walk(func(c *Node) bool {
+		switch c.Kind {
+		case Text, Code:
+			sb.WriteString(c.Literal)
+		case SoftBreak, HardBreak:
+			sb.WriteString(" ")
+		}
+		return true
+	})
+	return sb.String()
+}
+
+func footnoteRefID(index, ref int) string {
+	id := "fnref-" + strconv.Itoa(index)
+	if ref > 1 {
+		id += "-" + strconv.Itoa(ref)
+	}
+	return id
+}
+
+// backrefs writes a link back to each reference to footnote def.
+func (r *htmlRenderer) backrefs(def *Node) {
+	for i := 1; i <= def.Ref; i++ {
+		r.sb.WriteString(` <a href="#` + footnoteRefID(def.Index, i) + `" class="footnote-backref">↩`)
+		if i > 1 {
+			r.sb.WriteString("<sup>" + strconv.Itoa(i) + "</sup>")
+		}
+		r.sb.WriteString("</a>")
+	}
+}
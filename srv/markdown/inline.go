@@ -0,0 +1,605 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const asciiPunct = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+var reEntity = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+
+// delimiter is an entry on the emphasis delimiter stack.
+type delimiter struct {
+	node      *Node
+	char      byte
+	count     int
+	origCount int
+	canOpen   bool
+	canClose  bool
+	prev      *delimiter
+	next      *delimiter
+}
+
+// bracket is an entry on the stack of unmatched link openers.
+type bracket struct {
+	node      *Node
+	prevDelim *delimiter
+	image     bool
+	active    bool
+	prev      *bracket
+}
+
+type inlineParser struct {
+	src       string
+	pos       int
+	block     *Node
+	delims    *delimiter
+	brackets  *bracket
+	footnotes map[string]bool
+	html      bool // recognize inline tags
+}
+
+// parseInlines parses the raw content of every paragraph, heading and
+// table cell in doc into inline nodes.
+func parseInlines(doc *Node, html bool) {
+	footnotes := make(map[string]bool)
+	doc.Walk(func(n *Node) bool {
+		if n.Kind == FootnoteDef {
+			footnotes[n.Label] = true
+		}
+		return true
+	})
+
+	doc.Walk(func(n *Node) bool {
+		if n.Kind != Paragraph && n.Kind != Heading && n.Kind != TableCell {
+			return true
+		}
+		p := &inlineParser{src: n.content, block: n, footnotes: footnotes, html: html}
+		p.parse()
+		n.content = ""
+		return false
+	})
+}
+
+func (p *inlineParser) parse() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\n':
+			p.parseNewline()
+		case '\\':
+			p.parseBackslash()
+		case '`':
+			p.parseBackticks()
+		case '*', '_', '~':
+			p.parseDelimiterRun()
+		case '&':
+			p.parseEntity()
+		case '!':
+			p.parseBang()
+		case '[':
+			p.parseOpenBracket()
+		case ']':
+			p.parseCloseBracket()
+		case '{':
+			p.parseShortcode()
+		case '$':
+			p.parseMath()
+		case '<':
+			p.parseHTML()
+		default:
+			p.parseText()
+		}
+	}
+	p.processEmphasis(nil)
+	mergeText(p.block)
+}
+
+func (p *inlineParser) appendNode(kind Kind, literal string) *Node {
+	n := &Node{Kind: kind, Literal: literal}
+	p.block.AppendChild(n)
+	return n
+}
+
+func (p *inlineParser) parseText() {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("\n\\`*_~&[]!{$<", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	p.appendNode(Text, p.src[start:p.pos])
+}
+
+// parseNewline emits a hard break when the line ended in two or more
+// spaces and a soft break otherwise.
+func (p *inlineParser) parseNewline() {
+	p.pos++
+	kind := SoftBreak
+	if last := p.block.LastChild; last != nil && last.Kind == Text {
+		if strings.HasSuffix(last.Literal, "  ") {
+			kind = HardBreak
+		}
+		last.Literal = strings.TrimRight(last.Literal, " ")
+	}
+	p.appendNode(kind, "")
+	p.skipSpaces()
+}
+
+func (p *inlineParser) skipSpaces() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *inlineParser) parseBackslash() {
+	p.pos++
+	switch {
+	case p.pos < len(p.src) && p.src[p.pos] == '\n':
+		p.pos++
+		p.appendNode(HardBreak, "")
+		p.skipSpaces()
+	case p.pos < len(p.src) && strings.IndexByte(asciiPunct, p.src[p.pos]) >= 0:
+		p.appendNode(Text, p.src[p.pos:p.pos+1])
+		p.pos++
+	default:
+		p.appendNode(Text, "\\")
+	}
+}
+
+func (p *inlineParser) parseBackticks() {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == '`' {
+		p.pos++
+	}
+	ticks := p.pos - start
+	for i := p.pos; i < len(p.src); {
+		j := strings.IndexByte(p.src[i:], '`')
+		if j < 0 {
+			break
+		}
+		j += i
+		k := j
+		for k < len(p.src) && p.src[k] == '`' {
+			k++
+		}
+		if k-j == ticks {
+			content := strings.ReplaceAll(p.src[p.pos:j], "\n", " ")
+			if len(content) >= 2 && content[0] == ' ' && content[len(content)-1] == ' ' && strings.Trim(content, " ") != "" {
+				content = content[1 : len(content)-1]
+			}
+			p.appendNode(Code, content)
+			p.pos = k
+			return
+		}
+		i = k
+	}
+	p.appendNode(Text, p.src[start:p.pos])
+}
+
+func (p *inlineParser) parseEntity() {
+	if m := reEntity.FindString(p.src[p.pos:]); m != "" {
+		if s := html.UnescapeString(m); s != m {
+			p.appendNode(Text, s)
+			p.pos += len(m)
+			return
+		}
+	}
+	p.appendNode(Text, "&")
+	p.pos++
+}
+
+// parseDelimiterRun scans a run of '*', '_' or '~' and pushes it onto the
+// delimiter stack, classifying it by the flanking rules of CommonMark.
+func (p *inlineParser) parseDelimiterRun() {
+	c := p.src[p.pos]
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+	}
+
+	before, after := '\n', '\n'
+	if start > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:start])
+	}
+	if p.pos < len(p.src) {
+		after, _ = utf8.DecodeRuneInString(p.src[p.pos:])
+	}
+	beforeSpace, afterSpace := unicode.IsSpace(before), unicode.IsSpace(after)
+	beforePunct, afterPunct := isPunct(before), isPunct(after)
+
+	left := !afterSpace && (!afterPunct || beforeSpace || beforePunct)
+	right := !beforeSpace && (!beforePunct || afterSpace || afterPunct)
+	canOpen, canClose := left, right
+	if c == '_' {
+		canOpen = left && (!right || beforePunct)
+		canClose = right && (!left || afterPunct)
+	}
+
+	n := p.pos - start
+	d := &delimiter{
+		node:      p.appendNode(Text, p.src[start:p.pos]),
+		char:      c,
+		count:     n,
+		origCount: n,
+		canOpen:   canOpen,
+		canClose:  canClose,
+		prev:      p.delims,
+	}
+	if p.delims != nil {
+		p.delims.next = d
+	}
+	p.delims = d
+}
+
+// maxFootnoteLabel is the longest footnote label, in bytes, a reference
+// can have. Like CommonMark's bound on link labels, it keeps a run of "[^"
+// from making every '[' scan to the end of the input.
+const maxFootnoteLabel = 999
+
+// maxLinkParens is how deeply parentheses can nest in a link destination
+// that is not in angle brackets. As in CommonMark, the bound keeps a run
+// of "[a](" from making every '(' scan to the end of the input.
+const maxLinkParens = 32
+
+// footnoteRef returns the label of the footnote reference, such as [^1],
+// that s starts with and the reference's length, or ok false if s does
+// not start with one.
+func footnoteRef(s string) (label string, n int, ok bool) {
+	if !strings.HasPrefix(s, "[^") {
+		return "", 0, false
+	}
+	for i := 2; i < len(s) && i <= maxFootnoteLabel+2; i++ {
+		switch s[i] {
+		case ']':
+			return s[2:i], i + 1, i > 2
+		case ' ', '\t', '\n', '\f', '\r':
+			return "", 0, false
+		}
+	}
+	return "", 0, false
+}
+
+// delimitersMatch reports whether opener and closer, which use the same
+// character, can delimit emphasis. Strikethrough needs exactly two tildes
+// on each side; '*' and '_' follow CommonMark's "multiple of 3" rule.
+func delimitersMatch(opener, closer *delimiter) bool {
+	if closer.char == '~' {
+		return opener.count == 2 && closer.count == 2
+	}
+	oddMatch := (closer.canOpen || opener.canClose) && closer.origCount%3 != 0 &&
+		(opener.origCount+closer.origCount)%3 == 0
+	return !oddMatch
+}
+
+func (p *inlineParser) parseOpenBracket() {
+	if label, n, ok := footnoteRef(p.src[p.pos:]); ok && p.footnotes[normalizeLabel(label)] {
+		ref := p.appendNode(FootnoteRef, "")
+		ref.Label = normalizeLabel(label)
+		p.pos += n
+		return
+	}
+	n := p.appendNode(Text, "[")
+	p.pos++
+	p.brackets = &bracket{node: n, prevDelim: p.delims, active: true, prev: p.brackets}
+}
+
+// parseBang opens an image if '!' is followed by '['.
+func (p *inlineParser) parseBang() {
+	if p.pos+1 < len(p.src) && p.src[p.pos+1] == '[' {
+		n := p.appendNode(Text, "![")
+		p.pos += 2
+		p.brackets = &bracket{node: n, prevDelim: p.delims, image: true, active: true, prev: p.brackets}
+		return
+	}
+	p.appendNode(Text, "!")
+	p.pos++
+}
+
+// parseCloseBracket closes the innermost open bracket as a link if it is
+// followed by a destination, and as literal text otherwise.
+func (p *inlineParser) parseCloseBracket() {
+	p.pos++
+	opener := p.brackets
+	if opener == nil {
+		p.appendNode(Text, "]")
+		return
+	}
+	p.brackets = opener.prev
+	if !opener.active {
+		p.appendNode(Text, "]")
+		return
+	}
+	dest, title, ok := p.parseLinkTail()
+	if !ok {
+		p.appendNode(Text, "]")
+		return
+	}
+
+	link := &Node{Kind: Link, Dest: dest, Title: title}
+	if opener.image {
+		link.Kind = Image
+		link.Width, link.Height = p.parseImageSize()
+	}
+	for n := opener.node.Next; n != nil; {
+		next := n.Next
+		link.AppendChild(n)
+		n = next
+	}
+	p.block.AppendChild(link)
+	p.processEmphasis(opener.prevDelim)
+	opener.node.Unlink()
+
+	// Links may not contain other links.
+	if !opener.image {
+		for b := p.brackets; b != nil; b = b.prev {
+			b.active = false
+		}
+	}
+}
+
+var reImageSize = regexp.MustCompile(`^\{\s*((?:(?:width|height)=\d+\s*)+)\}`)
+
+// parseImageSize parses an optional {width=N height=N} attribute block
+// following an image.
+func (p *inlineParser) parseImageSize() (width, height int) {
+	m := reImageSize.FindStringSubmatch(p.src[p.pos:])
+	if m == nil {
+		return 0, 0
+	}
+	p.pos += len(m[0])
+	for _, attr := range strings.Fields(m[1]) {
+		name, value, _ := strings.Cut(attr, "=")
+		n, _ := strconv.Atoi(value)
+		if name == "width" {
+			width = n
+		} else {
+			height = n
+		}
+	}
+	return width, height
+}
+
+// parseLinkTail parses an inline link destination and optional title in
+// parentheses, restoring the position if there is none.
+func (p *inlineParser) parseLinkTail() (dest, title string, ok bool) {
+	start := p.pos
+	if p.pos >= len(p.src) || p.src[p.pos] != '(' {
+		return "", "", false
+	}
+	p.pos++
+	p.skipWhitespace()
+	if dest, ok = p.parseLinkDestination(); !ok {
+		p.pos = start
+		return "", "", false
+	}
+	beforeTitle := p.pos
+	p.skipWhitespace()
+	if p.pos > beforeTitle {
+		var hasTitle bool
+		if title, hasTitle = p.parseLinkTitle(); hasTitle {
+			p.skipWhitespace()
+		}
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+		p.pos = start
+		return "", "", false
+	}
+	p.pos++
+	return unescapeString(dest), unescapeString(title), true
+}
+
+func (p *inlineParser) skipWhitespace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *inlineParser) parseLinkDestination() (string, bool) {
+	if p.pos < len(p.src) && p.src[p.pos] == '<' {
+		for i := p.pos + 1; i < len(p.src); i++ {
+			switch p.src[i] {
+			case '\\':
+				i++
+			case '>':
+				dest := p.src[p.pos+1 : i]
+				p.pos = i + 1
+				return dest, true
+			case '<', '\n':
+				return "", false
+			}
+		}
+		return "", false
+	}
+
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.src) && strings.IndexByte(asciiPunct, p.src[p.pos+1]) >= 0:
+			p.pos++
+		case c == '(':
+			if depth++; depth > maxLinkParens {
+				return "", false
+			}
+		case c == ')':
+			if depth == 0 {
+				return p.src[start:p.pos], true
+			}
+			depth--
+		case c <= ' ':
+			return p.src[start:p.pos], depth == 0
+		}
+	}
+	return p.src[start:p.pos], depth == 0
+}
+
+func (p *inlineParser) parseLinkTitle() (string, bool) {
+	if p.pos >= len(p.src) {
+		return "", false
+	}
+	closer := p.src[p.pos]
+	switch closer {
+	case '"', '\'':
+	case '(':
+		closer = ')'
+	default:
+		return "", false
+	}
+	for i := p.pos + 1; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case closer:
+			title := p.src[p.pos+1 : i]
+			p.pos = i + 1
+			return title, true
+		}
+	}
+	return "", false
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+func (p *inlineParser) removeDelimiter(d *delimiter) {
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+	if d.next != nil {
+		d.next.prev = d.prev
+	} else {
+		p.delims = d.prev
+	}
+}
+
+type openerKey struct {
+	char    byte
+	mod     int
+	canOpen bool
+}
+
+// processEmphasis matches openers and closers above bottom on the
+// delimiter stack and wraps the nodes between them.
+func (p *inlineParser) processEmphasis(bottom *delimiter) {
+	openersBottom := make(map[openerKey]*delimiter)
+
+	closer := p.delims
+	for closer != nil && closer.prev != bottom {
+		closer = closer.prev
+	}
+	for closer != nil {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+		key := openerKey{closer.char, closer.origCount % 3, closer.canOpen}
+		opener := closer.prev
+		found := false
+		for opener != nil && opener != bottom && opener != openersBottom[key] {
+			if opener.char == closer.char && opener.canOpen && delimitersMatch(opener, closer) {
+				found = true
+				break
+			}
+			opener = opener.prev
+		}
+
+		if !found {
+			openersBottom[key] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				p.removeDelimiter(closer)
+			}
+			closer = next
+			continue
+		}
+
+		emph := &Node{Kind: Emph}
+		use := 1
+		switch {
+		case closer.char == '~':
+			emph.Kind = Strikethrough
+			use = 2
+		case opener.count >= 2 && closer.count >= 2:
+			emph.Kind = Strong
+			use = 2
+		}
+		opener.count -= use
+		closer.count -= use
+		opener.node.Literal = opener.node.Literal[:opener.count]
+		closer.node.Literal = closer.node.Literal[:closer.count]
+
+		for n := opener.node.Next; n != nil && n != closer.node; {
+			next := n.Next
+			emph.AppendChild(n)
+			n = next
+		}
+		opener.node.InsertAfter(emph)
+
+		opener.next = closer
+		closer.prev = opener
+
+		if opener.count == 0 {
+			opener.node.Unlink()
+			p.removeDelimiter(opener)
+		}
+		if closer.count == 0 {
+			next := closer.next
+			closer.node.Unlink()
+			p.removeDelimiter(closer)
+			closer = next
+		}
+	}
+
+	for p.delims != nil && p.delims != bottom {
+		p.removeDelimiter(p.delims)
+	}
+}
+
+// mergeText joins adjacent text nodes below n.
+func mergeText(n *Node) {
+	for c := n.FirstChild; c != nil; c = c.Next {
+		if c.Kind == Text {
+			if c.Next == nil || c.Next.Kind != Text {
+				continue
+			}
+			var sb strings.Builder
+			sb.WriteString(c.Literal)
+			for c.Next != nil && c.Next.Kind == Text {
+				sb.WriteString(c.Next.Literal)
+				c.Next.Unlink()
+			}
+			c.Literal = sb.String()
+			continue
+		}
+		mergeText(c)
+	}
+}
+
+// unescapeString resolves backslash escapes and entity references in s.
+func unescapeString(s string) string {
+	if !strings.ContainsAny(s, "\\&") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && strings.IndexByte(asciiPunct, s[i+1]) >= 0:
+			sb.WriteByte(s[i+1])
+			i++
+		case s[i] == '&':
+			if m := reEntity.FindString(s[i:]); m != "" {
+				sb.WriteString(html.UnescapeString(m))
+				i += len(m) - 1
+				continue
+			}
+			sb.WriteByte('&')
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
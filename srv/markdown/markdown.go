@@ -0,0 +1,82 @@
+// Package markdown renders post content to HTML.
+//
+// The dialect follows the CommonMark block and inline structure, with a
+// few extensions:
+//
+//   - GitHub-style pipe tables with column alignment.
+//   - ~~Strikethrough~~ alongside *emphasis* and **strong** text.
+//   - Fenced code blocks tagged with a supported language are syntax
+//     highlighted.
+//   - Images load lazily, accept a {width=N height=N} attribute block, and
+//     become a captioned figure when they have a title and stand alone in
+//     a paragraph.
+//   - Footnote references ([^label]) and definitions ([^label]: text) are
+//     collected into a numbered list at the end of the document.
+//   - $inline$ and $$display$$ math, as well as $$ and ```math blocks, are
+//     passed through as TeX for the browser to typeset; HasMath reports
+//     whether a document needs the typesetter.
+//   - Shortcodes such as {{youtube ID}} expand into embeds. The youtube,
+//     vimeo and gist shortcodes are built in; RegisterShortcode adds more.
+//
+// Headings carry unique id attributes derived from their text, and TOC
+// lists them for building a table of contents.
+//
+// Options enables features that are off by default. The typographer turns
+// straight quotes into curly quotes, -- and --- into en and em dashes, and
+// ... into an ellipsis, leaving code untouched. An Embed function lets the
+// caller replace paragraphs that hold nothing but a URL with embed markup.
+//
+// Link and image destinations are limited to http, https, mailto and
+// relative URLs. Raw HTML in the source is escaped unless Options.HTML is
+// set, in which case it is filtered through package sanitize.
+package markdown
+
+import "html/template"
+
+// Options configures optional parsing features.
+type Options struct {
+	// Typographer replaces straight quotes, dashes and ellipses with
+	// their typographic forms.
+	Typographer bool
+
+	// HTML passes raw HTML in the source through sanitize.HTML instead
+	// of escaping it.
+	HTML bool
+
+	// Embed, if set, is called with the URL of each paragraph that
+	// consists of nothing but a bare http or https URL. If it returns
+	// true, the paragraph is replaced by the returned HTML.
+	Embed func(url string) (template.HTML, bool)
+}
+
+// Parse parses src into a document tree using the default options.
+func Parse(src string) *Node {
+	return Options{}.Parse(src)
+}
+
+// Parse parses src into a document tree.
+func (o Options) Parse(src string) *Node {
+	doc := parseBlocks(src, o.HTML)
+	parseInlines(doc, o.HTML)
+	if o.Embed != nil {
+		embedURLs(doc, o.Embed)
+	}
+	if o.Typographer {
+		smarten(doc)
+	}
+	collectFootnotes(doc)
+	assignHeadingIDs(doc)
+	return doc
+}
+
+// RenderHTML renders a document tree produced by Parse.
+func RenderHTML(doc *Node) template.HTML {
+	var r htmlRenderer
+	r.render(doc)
+	return template.HTML(r.sb.String())
+}
+
+// Render parses src and renders it to HTML.
+func Render(src string) template.HTML {
+	return RenderHTML(Parse(src))
+}
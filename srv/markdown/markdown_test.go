@@ -0,0 +1,646 @@
+package markdown
+
+import (
+	"html/template"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "paragraphs",
+			input:    "one\ntwo\n\nthree",
+			expected: "<p>one\ntwo</p>\n<p>three</p>\n",
+		},
+		{
+			name:     "escapes html",
+			input:    "<script>alert(1)</script> & co",
+			expected: "<p>&lt;script&gt;alert(1)&lt;/script&gt; &amp; co</p>\n",
+		},
+		{
+			name:     "headings start at h2",
+			input:    "# Title\n## Section\n### Sub",
+			expected: "<h2 id=\"title\">Title</h2>\n<h2 id=\"section\">Section</h2>\n<h3 id=\"sub\">Sub</h3>\n",
+		},
+		{
+			name:     "setext heading",
+			input:    "Section\n-------",
+			expected: "<h2 id=\"section\">Section</h2>\n",
+		},
+		{
+			name:     "hard breaks",
+			input:    "line one  \nline two\\\nline three",
+			expected: "<p>line one<br>\nline two<br>\nline three</p>\n",
+		},
+		{
+			name:     "ordered list",
+			input:    "1. first\n2. second",
+			expected: "<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n",
+		},
+		{
+			name:     "ordered list start",
+			input:    "3) third\n4) fourth",
+			expected: "<ol start=\"3\">\n<li>third</li>\n<li>fourth</li>\n</ol>\n",
+		},
+		{
+			name:     "nested lists",
+			input:    "- a\n  - b\n    1. c\n- d",
+			expected: "<ul>\n<li>a\n<ul>\n<li>b\n<ol>\n<li>c</li>\n</ol>\n</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n",
+		},
+		{
+			name:     "loose list",
+			input:    "- a\n\n- b",
+			expected: "<ul>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n</ul>\n",
+		},
+		{
+			name:     "list directly after paragraph",
+			input:    "Shopping:\n- eggs\n- milk",
+			expected: "<p>Shopping:</p>\n<ul>\n<li>eggs</li>\n<li>milk</li>\n</ul>\n",
+		},
+		{
+			name:     "changing bullet starts a new list",
+			input:    "- a\n* b",
+			expected: "<ul>\n<li>a</li>\n</ul>\n<ul>\n<li>b</li>\n</ul>\n",
+		},
+		{
+			name:     "blockquote",
+			input:    "> quoted\ncontinued\n\nafter",
+			expected: "<blockquote>\n<p>quoted\ncontinued</p>\n</blockquote>\n<p>after</p>\n",
+		},
+		{
+			name:     "nested blockquote with list",
+			input:    "> outer\n>> inner\n> - item",
+			expected: "<blockquote>\n<p>outer</p>\n<blockquote>\n<p>inner</p>\n</blockquote>\n<ul>\n<li>item</li>\n</ul>\n</blockquote>\n",
+		},
+		{
+			name:     "quote with lazy continuation and lists",
+			input:    "> The owl is a bird.\nIt hunts at night.\n>\n> - small\n>   - tiny\n> 1. one",
+			expected: "<blockquote>\n<p>The owl is a bird.\nIt hunts at night.</p>\n<ul>\n<li>small\n<ul>\n<li>tiny</li>\n</ul>\n</li>\n</ul>\n<ol>\n<li>one</li>\n</ol>\n</blockquote>\n",
+		},
+		{
+			name:     "deeply nested mixed lists",
+			input:    "1. one\n   - a\n     1. deep\n        - deeper\n2. two",
+			expected: "<ol>\n<li>one\n<ul>\n<li>a\n<ol>\n<li>deep\n<ul>\n<li>deeper</li>\n</ul>\n</li>\n</ol>\n</li>\n</ul>\n</li>\n<li>two</li>\n</ol>\n",
+		},
+		{
+			name:     "blockquote inside list item",
+			input:    "- item\n\n  > quoted\n- next",
+			expected: "<ul>\n<li>\n<p>item</p>\n<blockquote>\n<p>quoted</p>\n</blockquote>\n</li>\n<li>\n<p>next</p>\n</li>\n</ul>\n",
+		},
+		{
+			name:     "insufficient indent does not nest",
+			input:    "1. one\n  - two",
+			expected: "<ol>\n<li>one</li>\n</ol>\n<ul>\n<li>two</li>\n</ul>\n",
+		},
+		{
+			name:     "indented code is not formatted",
+			input:    "    x := **y**\n    `z`",
+			expected: "<pre><code>x := **y**\n`z`\n</code></pre>\n",
+		},
+		{
+			name:     "code block inside list item",
+			input:    "- step\n\n      make build",
+			expected: "<ul>\n<li>\n<p>step</p>\n<pre><code>make build\n</code></pre>\n</li>\n</ul>\n",
+		},
+		{
+			name:     "fenced code keeps indentation",
+			input:    "```\nif x {\n\n    return\n}\n```",
+			expected: "<pre><code>if x {\n\n    return\n}\n</code></pre>\n",
+		},
+		{
+			name:     "fenced code with language",
+			input:    "~~~ go title=x\nreturn nil\n~~~",
+			expected: "<pre><code class=\"language-go\"><span class=\"hl-kw\">return</span> <span class=\"hl-bi\">nil</span>\n</code></pre>\n",
+		},
+		{
+			name:     "unclosed fence runs to end",
+			input:    "```sh\n$ make",
+			expected: "<pre><code class=\"language-sh\">$ make\n</code></pre>\n",
+		},
+		{
+			name:     "fenced code in list item",
+			input:    "1. run:\n   ```\n   make\n   ```",
+			expected: "<ol>\n<li>run:\n<pre><code>make\n</code></pre>\n</li>\n</ol>\n",
+		},
+		{
+			name:     "thematic break",
+			input:    "a\n\n***\n\nb",
+			expected: "<p>a</p>\n<hr>\n<p>b</p>\n",
+		},
+		{
+			name:     "strong and inline code",
+			input:    "**bold** and `**not bold**`",
+			expected: "<p><strong>bold</strong> and <code>**not bold**</code></p>\n",
+		},
+		{
+			name:     "emphasis",
+			input:    "*italic* _also italic_ ***both*** ~~struck~~",
+			expected: "<p><em>italic</em> <em>also italic</em> <em><strong>both</strong></em> <del>struck</del></p>\n",
+		},
+		{
+			name:     "nested emphasis",
+			input:    "*a **b** c* and **a *b* c** and ~~old *text*~~",
+			expected: "<p><em>a <strong>b</strong> c</em> and <strong>a <em>b</em> c</strong> and <del>old <em>text</em></del></p>\n",
+		},
+		{
+			name:     "intraword underscores are literal",
+			input:    "snake_case_name and 2*3*4",
+			expected: "<p>snake_case_name and 2<em>3</em>4</p>\n",
+		},
+		{
+			name:     "whitespace around delimiters",
+			input:    "a * b * c and _ x _ and ~~ y ~~",
+			expected: "<p>a * b * c and _ x _ and ~~ y ~~</p>\n",
+		},
+		{
+			name:     "single and triple tildes are literal",
+			input:    "~one~ and ~~~three~~~",
+			expected: "<p>~one~ and ~~~three~~~</p>\n",
+		},
+		{
+			name:     "emphasis content is escaped",
+			input:    "*<i>&</i>*",
+			expected: "<p><em>&lt;i&gt;&amp;&lt;/i&gt;</em></p>\n",
+		},
+		{
+			name:     "unmatched delimiters are literal",
+			input:    "*open and close_",
+			expected: "<p>*open and close_</p>\n",
+		},
+		{
+			name:     "unmatched strong is literal",
+			input:    "2 ** 3",
+			expected: "<p>2 ** 3</p>\n",
+		},
+		{
+			name:     "backslash escapes",
+			input:    `\*\*not bold\*\* \# \\`,
+			expected: "<p>**not bold** # \\</p>\n",
+		},
+		{
+			name:     "entities",
+			input:    "caf&eacute; &bogus; &#169;",
+			expected: "<p>café &amp;bogus; ©</p>\n",
+		},
+		{
+			name:     "external link",
+			input:    "See [the *article*](https://en.wikipedia.org/wiki/Owl \"Owls\").",
+			expected: "<p>See <a href=\"https://en.wikipedia.org/wiki/Owl\" title=\"Owls\" rel=\"noopener\">the <em>article</em></a>.</p>\n",
+		},
+		{
+			name:     "relative link",
+			input:    "[archive](/archive)",
+			expected: "<p><a href=\"/archive\">archive</a></p>\n",
+		},
+		{
+			name:     "link with formatting and parens",
+			input:    "[**Go** `code`](https://en.wikipedia.org/wiki/Go_(programming_language))",
+			expected: "<p><a href=\"https://en.wikipedia.org/wiki/Go_(programming_language)\" rel=\"noopener\"><strong>Go</strong> <code>code</code></a></p>\n",
+		},
+		{
+			name:     "link url and label are escaped",
+			input:    `[<b>"x"</b>](<https://example.com/a b?q="1"&r=2>)`,
+			expected: "<p><a href=\"https://example.com/a%20b?q=%221%22&amp;r=2\" rel=\"noopener\">&lt;b&gt;&#34;x&#34;&lt;/b&gt;</a></p>\n",
+		},
+		{
+			name:     "javascript link is dropped",
+			input:    "[click](javascript:alert(1))",
+			expected: "<p>click</p>\n",
+		},
+		{
+			name:     "nested links are not allowed",
+			input:    "[a [b](/b)](/a)",
+			expected: "<p>[a <a href=\"/b\">b</a>](/a)</p>\n",
+		},
+		{
+			name:     "brackets without destination are literal",
+			input:    "[citation needed] and [x] (y)",
+			expected: "<p>[citation needed] and [x] (y)</p>\n",
+		},
+		{
+			name:     "inline image",
+			input:    "An owl: ![a **barn** owl](/static/owl.jpg \"Tyto\") here",
+			expected: "<p>An owl: <img src=\"/static/owl.jpg\" alt=\"a barn owl\" title=\"Tyto\" loading=\"lazy\"> here</p>\n",
+		},
+		{
+			name:     "image with size",
+			input:    "![map](https://example.com/map.png){width=640 height=480}",
+			expected: "<p><img src=\"https://example.com/map.png\" alt=\"map\" width=\"640\" height=\"480\" loading=\"lazy\"></p>\n",
+		},
+		{
+			name:     "titled image becomes a figure",
+			input:    "![Owl](/owl.jpg \"A barn owl in flight\")",
+			expected: "<figure><img src=\"/owl.jpg\" alt=\"Owl\" loading=\"lazy\"><figcaption>A barn owl in flight</figcaption></figure>\n",
+		},
+		{
+			name:     "image inside link",
+			input:    "[![logo](/logo.png)](https://exe.dev)",
+			expected: "<p><a href=\"https://exe.dev\" rel=\"noopener\"><img src=\"/logo.png\" alt=\"logo\" loading=\"lazy\"></a></p>\n",
+		},
+		{
+			name:     "unsafe image source renders alt text",
+			input:    "![x\"y](javascript:alert(1))",
+			expected: "<p>x&#34;y</p>\n",
+		},
+		{
+			name:     "bang without bracket",
+			input:    "Wow! [no link]",
+			expected: "<p>Wow! [no link]</p>\n",
+		},
+		{
+			name:     "table with alignment",
+			input:    "| Name | Count | Note |\n| :--- | ---: | :-: |\n| **owl** | 3 | `a\\|b` |\n| cat |",
+			expected: "<table>\n<thead>\n<tr>\n<th style=\"text-align: left\">Name</th>\n<th style=\"text-align: right\">Count</th>\n<th style=\"text-align: center\">Note</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td style=\"text-align: left\"><strong>owl</strong></td>\n<td style=\"text-align: right\">3</td>\n<td style=\"text-align: center\"><code>a|b</code></td>\n</tr>\n<tr>\n<td style=\"text-align: left\">cat</td>\n<td style=\"text-align: right\"></td>\n<td style=\"text-align: center\"></td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:     "table after paragraph ends at blank line",
+			input:    "Counts:\na | b\n--|--\n1 | 2\n\nafter",
+			expected: "<p>Counts:</p>\n<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n</tbody>\n</table>\n<p>after</p>\n",
+		},
+		{
+			name:     "mismatched delimiter row is not a table",
+			input:    "| a | b |\n| --- |",
+			expected: "<p>| a | b |\n| --- |</p>\n",
+		},
+		{
+			name:  "footnotes",
+			input: "Owls[^owl] hunt at night[^n].\n\n[^n]: Mostly.\n[^owl]: See [Owl](https://en.wikipedia.org/wiki/Owl).\n\n    Second paragraph.\n[^unused]: Dropped.\n\nAgain[^OWL].",
+			expected: "<p>Owls<sup class=\"footnote-ref\" id=\"fnref-1\"><a href=\"#fn-1\">1</a></sup> hunt at night<sup class=\"footnote-ref\" id=\"fnref-2\"><a href=\"#fn-2\">2</a></sup>.</p>\n" +
+				"<p>Again<sup class=\"footnote-ref\" id=\"fnref-1-2\"><a href=\"#fn-1\">1</a></sup>.</p>\n" +
+				"<section class=\"footnotes\">\n<ol>\n" +
+				"<li id=\"fn-1\">\n<p>See <a href=\"https://en.wikipedia.org/wiki/Owl\" rel=\"noopener\">Owl</a>.</p>\n<p>Second paragraph. <a href=\"#fnref-1\" class=\"footnote-backref\">↩</a> <a href=\"#fnref-1-2\" class=\"footnote-backref\">↩<sup>2</sup></a></p>\n</li>\n" +
+				"<li id=\"fn-2\">\n<p>Mostly. <a href=\"#fnref-2\" class=\"footnote-backref\">↩</a></p>\n</li>\n" +
+				"</ol>\n</section>\n",
+		},
+		{
+			name:     "undefined footnote is literal",
+			input:    "See [^nope].",
+			expected: "<p>See [^nope].</p>\n",
+		},
+		{
+			name:     "youtube shortcode on its own line",
+			input:    "Watch:\n\n{{ youtube dQw4w9WgXcQ }}",
+			expected: "<p>Watch:</p>\n<div class=\"embed embed-youtube\"><iframe src=\"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ\" title=\"YouTube video\" loading=\"lazy\" allow=\"fullscreen; picture-in-picture\" allowfullscreen></iframe></div>\n",
+		},
+		{
+			name:     "gist shortcode",
+			input:    "{{gist octocat/6cad326836d38bd3a7ae}}",
+			expected: "<div class=\"embed embed-gist\"><script src=\"https://gist.github.com/octocat/6cad326836d38bd3a7ae.js\"></script></div>\n",
+		},
+		{
+			name:     "shortcode with invalid arguments is literal",
+			input:    `{{youtube "><script>}}`,
+			expected: "<p>{{youtube &#34;&gt;&lt;script&gt;}}</p>\n",
+		},
+		{
+			name:     "inline math",
+			input:    `Euler: $e^{i\pi} + 1 = 0$ and $a_1 * b_2 < c$.`,
+			expected: "<p>Euler: <span class=\"math math-inline\">\\(e^{i\\pi} + 1 = 0\\)</span> and <span class=\"math math-inline\">\\(a_1 * b_2 &lt; c\\)</span>.</p>\n",
+		},
+		{
+			name:     "dollar amounts are not math",
+			input:    "It costs $5 and $10 today. $ x $\n\n\\$y$ is escaped",
+			expected: "<p>It costs $5 and $10 today. $ x $</p>\n<p>$y$ is escaped</p>\n",
+		},
+		{
+			name:     "display math on one line",
+			input:    "$$ \\sum_{i=1}^n i $$",
+			expected: "<div class=\"math math-display\">\\[\\sum_{i=1}^n i\\]</div>\n",
+		},
+		{
+			name:     "display math block",
+			input:    "$$\n\\begin{aligned}\na &= b \\\\\n\nc &= d\n\\end{aligned}\n$$\nafter",
+			expected: "<div class=\"math math-display\">\\[\\begin{aligned}\na &amp;= b \\\\\n\nc &amp;= d\n\\end{aligned}\\]</div>\n<p>after</p>\n",
+		},
+		{
+			name:     "math fenced code",
+			input:    "```math\nx^2\n```",
+			expected: "<div class=\"math math-display\">\\[x^2\\]</div>\n",
+		},
+		{
+			name:     "unknown and escaped shortcodes are literal",
+			input:    "{{nope x}} and \\{{vimeo 1}} and `{{vimeo 1}}`",
+			expected: "<p>{{nope x}} and {{vimeo 1}} and <code>{{vimeo 1}}</code></p>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := string(Render(test.input))
+			if result != test.expected {
+				t.Errorf("Render(%q) = %q, expected %q", test.input, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestRenderFootnoteRefRun(t *testing.T) {
+	src := strings.Repeat("[^", 20000) + "\n\n[^1]: Note."
+	start := time.Now()
+	html := string(Render(src))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rendering a run of %d footnote openers took %v", 20000, elapsed)
+	}
+	if !strings.HasPrefix(html, "<p>[^[^") {
+		t.Errorf("expected the run rendered as text, got %.40q", html)
+	}
+}
+
+func TestRenderShortcodeOpenerRun(t *testing.T) {
+	src := strings.Repeat("{{youtube ", 20000)
+	start := time.Now()
+	html := string(Render(src))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rendering a run of %d shortcode openers took %v", 20000, elapsed)
+	}
+	if !strings.HasPrefix(html, "<p>{{youtube {{youtube") {
+		t.Errorf("expected the run rendered as text, got %.40q", html)
+	}
+}
+
+func TestRenderLinkOpenerRun(t *testing.T) {
+	src := strings.Repeat("[a](", 20000)
+	start := time.Now()
+	html := string(Render(src))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rendering a run of %d link openers took %v", 20000, elapsed)
+	}
+	if !strings.HasPrefix(html, "<p>[a]([a](") {
+		t.Errorf("expected the run rendered as text, got %.40q", html)
+	}
+	if html := string(Render("[a](/x(((y))))")); !strings.Contains(html, `href="/x(((y)))"`) {
+		t.Errorf("expected nested parentheses kept in the destination, got %q", html)
+	}
+}
+
+func TestTOC(t *testing.T) {
+	doc := Parse("# Intro\n\ntext\n\n## Details & *more*\n\n### Intro\n\n#### Deep\n\n## Intro")
+	expected := []TOCEntry{
+		{Level: 2, ID: "intro", Text: "Intro"},
+		{Level: 2, ID: "details--more", Text: "Details & more"},
+		{Level: 3, ID: "intro-1", Text: "Intro"},
+		{Level: 2, ID: "intro-2", Text: "Intro"},
+	}
+
+	toc := TOC(doc)
+	if len(toc) != len(expected) {
+		t.Fatalf("TOC() returned %d entries, expected %d: %v", len(toc), len(expected), toc)
+	}
+	for i := range expected {
+		if toc[i] != expected[i] {
+			t.Errorf("TOC()[%d] = %+v, expected %+v", i, toc[i], expected[i])
+		}
+	}
+
+	html := string(RenderHTML(doc))
+	if !strings.Contains(html, `<h4 id="deep">Deep</h4>`) {
+		t.Errorf("expected deeper headings to have ids, got %s", html)
+	}
+}
+
+func TestTypographer(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "double quotes",
+			input:    `She said "hello" (and "*bye*").`,
+			expected: "<p>She said “hello” (and “<em>bye</em>”).</p>\n",
+		},
+		{
+			name:     "single quotes and apostrophes",
+			input:    `It's 'quoted' in the '90s`,
+			expected: "<p>It’s ‘quoted’ in the ‘90s</p>\n",
+		},
+		{
+			name:     "dashes and ellipsis",
+			input:    "pages 10--12 --- and so on...",
+			expected: "<p>pages 10–12 — and so on…</p>\n",
+		},
+		{
+			name:     "code is untouched",
+			input:    "Use `\"a\" -- b...` here\n\n```\nx = \"y\" -- z\n```",
+			expected: "<p>Use <code>&#34;a&#34; -- b...</code> here</p>\n<pre><code>x = &#34;y&#34; -- z\n</code></pre>\n",
+		},
+		{
+			name:     "quote after inline code closes",
+			input:    "`x`'s value",
+			expected: "<p><code>x</code>’s value</p>\n",
+		},
+	}
+
+	opts := Options{Typographer: true}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := string(RenderHTML(opts.Parse(test.input)))
+			if result != test.expected {
+				t.Errorf("Parse(%q) = %q, expected %q", test.input, result, test.expected)
+			}
+		})
+	}
+
+	if result := string(Render(`"plain"`)); result != "<p>&#34;plain&#34;</p>\n" {
+		t.Errorf("expected quotes to stay straight by default, got %q", result)
+	}
+}
+
+func TestRegisterShortcode(t *testing.T) {
+	RegisterShortcode("test-badge", func(args []string) (template.HTML, bool) {
+		if len(args) == 0 {
+			return "", false
+		}
+		return template.HTML(`<span class="badge">` + template.HTMLEscapeString(strings.Join(args, "|")) + `</span>`), true
+	})
+
+	result := string(Render(`Status: {{test-badge "build passing" <b>}} today`))
+	expected := "<p>Status: <span class=\"badge\">build passing|&lt;b&gt;</span> today</p>\n"
+	if result != expected {
+		t.Errorf("Render() = %q, expected %q", result, expected)
+	}
+
+	if result := string(Render("{{test-badge}}")); result != "<p>{{test-badge}}</p>\n" {
+		t.Errorf("expected rejected shortcode to stay literal, got %q", result)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	var seen []string
+	opts := Options{Embed: func(url string) (template.HTML, bool) {
+		seen = append(seen, url)
+		if url == "https://vimeo.com/1" {
+			return `<div class="embed">player</div>`, true
+		}
+		return "", false
+	}}
+
+	input := "Intro\n\nhttps://vimeo.com/1\n\nhttps://example.com/x\n\nsee https://vimeo.com/1\n\n- https://vimeo.com/1"
+	result := string(RenderHTML(opts.Parse(input)))
+	expected := "<p>Intro</p>\n<div class=\"embed\">player</div>\n<p>https://example.com/x</p>\n<p>see https://vimeo.com/1</p>\n<ul>\n<li>\n<div class=\"embed\">player</div>\n</li>\n</ul>\n"
+	if result != expected {
+		t.Errorf("Parse(%q) = %q, expected %q", input, result, expected)
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected Embed to be called for the 3 bare URLs, got %q", seen)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxLen   int
+		expected string
+	}{
+		{"Hello **world**.", 100, "Hello world."},
+		{"# Title\n\nFirst\nline.\n\n```\ncode\n```\n\n> Quoted [link](/x).", 100, "First line. Quoted link."},
+		{"Text with a note.[^1]\n\n[^1]: The note.", 100, "Text with a note."},
+		{"One two three, four five.", 16, "One two three…"},
+		{"Café crème brûlée", 12, "Café crème…"},
+	}
+	for _, tt := range tests {
+		if result := Summary(Parse(tt.input), tt.maxLen); result != tt.expected {
+			t.Errorf("Summary(%q, %d) = %q, expected %q", tt.input, tt.maxLen, result, tt.expected)
+		}
+	}
+}
+
+func TestWordCount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", 0},
+		{"One **two** three.\n\n> Four `five`", 5},
+		{"Words here.\n\n```go\nfunc main() {}\n```\n\n$$x + y$$ and $z$ too", 4},
+	}
+	for _, tt := range tests {
+		if result := WordCount(Parse(tt.input)); result != tt.expected {
+			t.Errorf("WordCount(%q) = %d, expected %d", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestFirstImage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"No images.", ""},
+		{"Text\n\n> ![a](/a.png) ![b](/b.png)\n\n![c](/c.png)", "/a.png"},
+	}
+	for _, tt := range tests {
+		if result := FirstImage(Parse(tt.input)); result != tt.expected {
+			t.Errorf("FirstImage(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestRawHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "html block",
+			input:    "<div class=\"note\">\n*not emphasis*\n</div>\n\nafter *this*",
+			expected: "<div class=\"note\">\n*not emphasis*\n</div>\n<p>after <em>this</em></p>\n",
+		},
+		{
+			name:     "inline tags",
+			input:    "H<sub>2</sub>O is <span onclick=\"x()\">**wet**</span>",
+			expected: "<p>H<sub>2</sub>O is <span><strong>wet</strong></span></p>\n",
+		},
+		{
+			name:     "script block is removed",
+			input:    "<script>\nalert(1)\n\n</script>\ntext",
+			expected: "<p>text</p>\n",
+		},
+		{
+			name:     "comment block is removed",
+			input:    "<!-- note\n\nto self -->\nshown",
+			expected: "<p>shown</p>\n",
+		},
+		{
+			name:     "open tag cannot interrupt a paragraph",
+			input:    "text\n<custom>",
+			expected: "<p>text\n</p>\n",
+		},
+		{
+			name:     "html in code stays escaped",
+			input:    "`<b>x</b>`\n\n    <div>",
+			expected: "<p><code>&lt;b&gt;x&lt;/b&gt;</code></p>\n<pre><code>&lt;div&gt;\n</code></pre>\n",
+		},
+	}
+
+	opts := Options{HTML: true}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := string(RenderHTML(opts.Parse(test.input)))
+			if result != test.expected {
+				t.Errorf("Parse(%q) = %q, expected %q", test.input, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"No links here.", nil},
+		{"[One](https://a.example/) and [two](https://b.example/ \"Two\"), then [one](https://a.example/) again", []string{"https://a.example/", "https://b.example/"}},
+		{"![An image](/a.png) is not a link, but [this](/about) is", []string{"/about"}},
+		{`<p><a href="https://c.example/?x=1&amp;y=2">c</a> <a name=top></a></p>` + "\n\nInline <A HREF=https://d.example/>d</A>.", []string{"https://c.example/?x=1&y=2", "https://d.example/"}},
+	}
+	for _, tt := range tests {
+		result := Links(Options{HTML: true}.Parse(tt.input))
+		if !slices.Equal(result, tt.expected) {
+			t.Errorf("Links(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	doc := Options{HTML: true}.Parse("[Out](https://a.example/x) and [in](/about), ![image](https://c.example/i.png) and <a href=\"https://d.example/\">raw</a>")
+	RewriteLinks(doc, func(dest string) string {
+		if strings.HasPrefix(dest, "/") {
+			return dest
+		}
+		return "/out?u=" + url.QueryEscape(dest)
+	})
+	expected := `<p><a href="/out?u=https%3A%2F%2Fa.example%2Fx">Out</a> and <a href="/about">in</a>, <img src="https://c.example/i.png" alt="image" loading="lazy"> and <a href="https://d.example/" rel="noopener nofollow">raw</a></p>
+`
+	if got := string(RenderHTML(doc)); got != expected {
+		t.Errorf("RewriteLinks gave\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+func TestImagesWithoutAlt(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"![A harbour](/a.png)", nil},
+		{"![](/a.png) and ![ ](/b.png) but ![c](/c.png)", []string{"/a.png", "/b.png"}},
+		{"> * ![](/d.png)", []string{"/d.png"}},
+		{`<img src="/e.png" alt="">` + "\n\n" + `<p><img alt="Fine" src="/f.png"><img src='/g.png'></p>`, []string{"/e.png", "/g.png"}},
+		{`Inline <img src=/h.png alt=Fine> and <IMG SRC="/i.png">.`, []string{"/i.png"}},
+	}
+	for _, tt := range tests {
+		result := ImagesWithoutAlt(Options{HTML: true}.Parse(tt.input))
+		if !slices.Equal(result, tt.expected) {
+			t.Errorf("ImagesWithoutAlt(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
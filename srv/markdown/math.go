@@ -0,0 +1,56 @@
+package markdown
+
+import "strings"
+
+// parseMath parses $inline$ or $$display$$ math. The content is kept as
+// written for the browser to typeset. An inline opener must be followed
+// by a non-space, and its closer preceded by a non-space and not followed
+// by a digit, so prices like "$5 and $10" stay as text.
+func (p *inlineParser) parseMath() {
+	rest := p.src[p.pos:]
+	if strings.HasPrefix(rest, "$$") {
+		if end := strings.Index(rest[2:], "$$"); end >= 0 && strings.TrimSpace(rest[2:2+end]) != "" {
+			p.appendNode(DisplayMath, strings.TrimSpace(rest[2:2+end]))
+			p.pos += 2 + end + 2
+			return
+		}
+		p.appendNode(Text, "$$")
+		p.pos += 2
+		return
+	}
+
+	if len(rest) > 1 && !isMathSpace(rest[1]) {
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				i++
+			case '$':
+				if isMathSpace(rest[i-1]) || i+1 < len(rest) && rest[i+1] >= '0' && rest[i+1] <= '9' {
+					continue
+				}
+				p.appendNode(Math, rest[1:i])
+				p.pos += i + 1
+				return
+			}
+		}
+	}
+	p.appendNode(Text, "$")
+	p.pos++
+}
+
+func isMathSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '$'
+}
+
+// HasMath reports whether doc contains any math, so that pages can load
+// the typesetting script only when it is needed.
+func HasMath(doc *Node) bool {
+	found := false
+	doc.Walk(func(n *Node) bool {
+		if n.Kind == Math || n.Kind == DisplayMath || n.Kind == MathBlock {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
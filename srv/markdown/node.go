@@ -0,0 +1,175 @@
+package markdown
+
+// Kind identifies the type of a Node.
+type Kind int
+
+const (
+	Document Kind = iota
+	Paragraph
+	Heading
+	ThematicBreak
+	CodeBlock
+	MathBlock
+	HTMLBlock
+	BlockQuote
+	List
+	Item
+	Table
+	TableRow
+	TableCell
+	FootnoteDef
+	Footnotes
+	Embed
+
+	Text
+	Code
+	Math
+	DisplayMath
+	Emph
+	Strong
+	Strikethrough
+	Link
+	Image
+	FootnoteRef
+	Shortcode
+	RawHTML
+	HardBreak
+	SoftBreak
+)
+
+// ListData describes a list or list item marker.
+type ListData struct {
+	Ordered   bool
+	Bullet    byte // '-', '+' or '*' for bullet lists
+	Delimiter byte // '.' or ')' for ordered lists
+	Start     int
+	Tight     bool
+
+	markerOffset int
+	padding      int
+}
+
+// Node is an element of the parsed document tree. Block nodes contain
+// other blocks or inlines; inline nodes hold the rendered text.
+type Node struct {
+	Kind Kind
+
+	Parent     *Node
+	FirstChild *Node
+	LastChild  *Node
+	Prev       *Node
+	Next       *Node
+
+	Literal string    // Text, Code, CodeBlock and math content; Shortcode source; Embed, HTMLBlock and RawHTML markup
+	Level   int       // Heading level, 1-6
+	ID      string    // Heading anchor
+	Info    string    // CodeBlock fence info string
+	Dest    string    // Link and Image destination; embedded URL
+	Title   string    // Link and Image title
+	Width   int       // Image width, if given
+	Height  int       // Image height, if given
+	List    *ListData // List and Item marker details
+	Align   string    // TableCell alignment: "left", "center", "right" or ""
+	Label   string    // FootnoteDef and FootnoteRef label; Shortcode name
+	Index   int       // FootnoteDef and FootnoteRef number, from 1
+	Ref     int       // FootnoteRef occurrence, or FootnoteDef reference count
+	Args    []string  // Shortcode arguments
+
+	// Parser state.
+	open          bool
+	lastLineBlank bool
+	startLine     int
+	lines         []string
+	content       string
+	fenced        bool
+	fenceChar     byte
+	fenceLength   int
+	fenceOffset   int
+	htmlType      int
+	aligns        []string
+}
+
+// AppendChild adds c as the last child of n.
+func (n *Node) AppendChild(c *Node) {
+	c.Unlink()
+	c.Parent = n
+	if n.LastChild != nil {
+		n.LastChild.Next = c
+		c.Prev = n.LastChild
+	} else {
+		n.FirstChild = c
+	}
+	n.LastChild = c
+}
+
+// InsertAfter adds s as the next sibling of n.
+func (n *Node) InsertAfter(s *Node) {
+	s.Unlink()
+	s.Next = n.Next
+	if s.Next != nil {
+		s.Next.Prev = s
+	}
+	s.Prev = n
+	n.Next = s
+	s.Parent = n.Parent
+	if s.Next == nil && s.Parent != nil {
+		s.Parent.LastChild = s
+	}
+}
+
+// InsertBefore adds s as the previous sibling of n.
+func (n *Node) InsertBefore(s *Node) {
+	s.Unlink()
+	s.Prev = n.Prev
+	if s.Prev != nil {
+		s.Prev.Next = s
+	}
+	s.Next = n
+	n.Prev = s
+	s.Parent = n.Parent
+	if s.Prev == nil && s.Parent != nil {
+		s.Parent.FirstChild = s
+	}
+}
+
+// Unlink removes n from its parent and siblings.
+func (n *Node) Unlink() {
+	if n.Prev != nil {
+		n.Prev.Next = n.Next
+	} else if n.Parent != nil {
+		n.Parent.FirstChild = n.Next
+	}
+	if n.Next != nil {
+		n.Next.Prev = n.Prev
+	} else if n.Parent != nil {
+		n.Parent.LastChild = n.Prev
+	}
+	n.Parent, n.Prev, n.Next = nil, nil, nil
+}
+
+// Walk calls fn for n and each of its descendants in document order.
+// Returning false from fn skips the node's children.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; {
+		next := c.Next
+		c.Walk(fn)
+		c = next
+	}
+}
+
+func canContain(parent, child Kind) bool {
+	switch parent {
+	case Document, BlockQuote, Item, FootnoteDef:
+		return child != Item
+	case List:
+		return child == Item
+	}
+	return false
+}
+
+func acceptsLines(k Kind) bool {
+	return k == Paragraph || k == CodeBlock || k == HTMLBlock || k == Table
+}
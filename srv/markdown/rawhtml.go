@@ -0,0 +1,67 @@
+package markdown
+
+import "regexp"
+
+// HTML block start and end conditions, numbered as in the CommonMark
+// spec. Types 3 to 5 (processing instructions, declarations and CDATA)
+// are not recognized and fall through to other blocks.
+var (
+	reHTMLBlockStart = map[int]*regexp.Regexp{
+		1: regexp.MustCompile(`(?i)^<(?:script|pre|style|textarea)(?:\s|>|$)`),
+		2: regexp.MustCompile(`^<!--`),
+		6: regexp.MustCompile(`(?i)^</?(?:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[1-6]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:\s|/?>|$)`),
+		7: regexp.MustCompile(`^(?:` + openTag + `|` + closeTag + `)\s*$`),
+	}
+	reHTMLBlockEnd = map[int]*regexp.Regexp{
+		1: regexp.MustCompile(`(?i)</(?:script|pre|style|textarea)>`),
+		2: regexp.MustCompile(`-->`),
+	}
+	reInlineHTML = regexp.MustCompile(`^(?:` + openTag + `|` + closeTag + `|<!--[\s\S]*?-->)`)
+)
+
+const (
+	attribute = `(?:\s+[a-zA-Z_:][a-zA-Z0-9_.:-]*(?:\s*=\s*(?:[^"'=<>` + "`" + `\x00-\x20]+|'[^']*'|"[^"]*"))?)`
+	openTag   = `<[A-Za-z][A-Za-z0-9-]*` + attribute + `*\s*/?>`
+	closeTag  = `</[A-Za-z][A-Za-z0-9-]*\s*>`
+)
+
+func startHTMLBlock(p *blockParser, container *Node) startResult {
+	if !p.html || p.indented || p.peek(p.nextNonspace) != '<' {
+		return noStart
+	}
+	rest := p.line[p.nextNonspace:]
+	for _, typ := range []int{1, 2, 6, 7} {
+		if !reHTMLBlockStart[typ].MatchString(rest) {
+			continue
+		}
+		// Type 7 cannot interrupt a paragraph.
+		if typ == 7 && (container.Kind == Paragraph || !p.allClosed && p.tip.Kind == Paragraph) {
+			return noStart
+		}
+		p.closeUnmatchedBlocks()
+		b := p.addChild(HTMLBlock)
+		b.htmlType = typ
+		return leafStarted
+	}
+	return noStart
+}
+
+// htmlBlockEnds reports whether line closes an HTML block of type typ.
+// Types 6 and 7 end at a blank line instead.
+func htmlBlockEnds(typ int, line string) bool {
+	re, ok := reHTMLBlockEnd[typ]
+	return ok && re.MatchString(line)
+}
+
+// parseHTML parses an inline tag or comment, if raw HTML is enabled.
+func (p *inlineParser) parseHTML() {
+	if p.html {
+		if m := reInlineHTML.FindString(p.src[p.pos:]); m != "" {
+			p.appendNode(RawHTML, m)
+			p.pos += len(m)
+			return
+		}
+	}
+	p.appendNode(Text, "<")
+	p.pos++
+}
@@ -0,0 +1,143 @@
+package markdown
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A ShortcodeFunc expands the arguments of a shortcode into HTML. It
+// reports false if the arguments are not valid, in which case the
+// shortcode is shown as written.
+//
+// The returned HTML is inserted into the page verbatim, so a ShortcodeFunc
+// must validate or escape anything it takes from args.
+type ShortcodeFunc func(args []string) (template.HTML, bool)
+
+var (
+	shortcodesMu sync.RWMutex
+	shortcodes   = make(map[string]ShortcodeFunc)
+)
+
+// maxShortcode is the longest shortcode, in bytes, that is expanded.
+const maxShortcode = 1000
+
+var (
+	reShortcodeName = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+	reShortcode     = regexp.MustCompile(`^\{\{[ \t]*([a-z][a-z0-9_-]*)((?:[ \t]+(?:"[^"\n]*"|[^\s"}]+))*)[ \t]*\}\}`)
+	reShortcodeArg  = regexp.MustCompile(`"[^"\n]*"|[^\s"}]+`)
+)
+
+// RegisterShortcode makes fn available as {{name args...}} in post
+// content, replacing any shortcode already registered under name. Names
+// are lowercase letters, digits, '-' and '_', starting with a letter.
+func RegisterShortcode(name string, fn ShortcodeFunc) {
+	if !reShortcodeName.MatchString(name) {
+		panic("markdown: invalid shortcode name " + name)
+	}
+	shortcodesMu.Lock()
+	defer shortcodesMu.Unlock()
+	shortcodes[name] = fn
+}
+
+func lookupShortcode(name string) (ShortcodeFunc, bool) {
+	shortcodesMu.RLock()
+	defer shortcodesMu.RUnlock()
+	fn, ok := shortcodes[name]
+	return fn, ok
+}
+
+// parseShortcode parses {{name args...}} for a registered name. Anything
+// else starting with '{' is literal text.
+func (p *inlineParser) parseShortcode() {
+	var m []string
+	if n := shortcodeLen(p.src[p.pos:]); n > 0 {
+		m = reShortcode.FindStringSubmatch(p.src[p.pos : p.pos+n])
+	}
+	if m == nil {
+		p.appendNode(Text, "{")
+		p.pos++
+		return
+	}
+	if _, ok := lookupShortcode(m[1]); !ok {
+		p.appendNode(Text, "{")
+		p.pos++
+		return
+	}
+	var args []string
+	for _, arg := range reShortcodeArg.FindAllString(m[2], -1) {
+		args = append(args, strings.Trim(arg, `"`))
+	}
+	n := p.appendNode(Shortcode, m[0])
+	n.Label = m[1]
+	n.Args = args
+	p.pos += len(m[0])
+}
+
+// shortcodeLen returns the length of the {{...}} that s starts with, up to
+// and including the first "}}" outside quotes, or 0 if s does not start
+// with "{{" or there is no such "}}" on the line within maxShortcode bytes.
+// Bounding the span keeps a run of unclosed "{{name " from making every
+// '{' scan to the end of the line.
+func shortcodeLen(s string) int {
+	if !strings.HasPrefix(s, "{{") {
+		return 0
+	}
+	quoted := false
+	for i := 2; i < len(s) && i < maxShortcode; i++ {
+		switch {
+		case s[i] == '\n':
+			return 0
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == '}' && i+1 < len(s) && s[i+1] == '}':
+			return i + 2
+		}
+	}
+	return 0
+}
+
+// expandShortcode returns the HTML for a shortcode node, or false if it
+// should be shown as written.
+func expandShortcode(n *Node) (template.HTML, bool) {
+	fn, ok := lookupShortcode(n.Label)
+	if !ok {
+		return "", false
+	}
+	return fn(n.Args)
+}
+
+var (
+	reYouTubeID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	reVimeoID   = regexp.MustCompile(`^[0-9]+$`)
+	reGist      = regexp.MustCompile(`^[A-Za-z0-9-]+/[0-9a-f]+$`)
+)
+
+func init() {
+	RegisterShortcode("youtube", func(args []string) (template.HTML, bool) {
+		if len(args) != 1 || !reYouTubeID.MatchString(args[0]) {
+			return "", false
+		}
+		return embedFrame("youtube", "https://www.youtube-nocookie.com/embed/"+args[0], "YouTube video"), true
+	})
+	RegisterShortcode("vimeo", func(args []string) (template.HTML, bool) {
+		if len(args) != 1 || !reVimeoID.MatchString(args[0]) {
+			return "", false
+		}
+		return embedFrame("vimeo", "https://player.vimeo.com/video/"+args[0], "Vimeo video"), true
+	})
+	RegisterShortcode("gist", func(args []string) (template.HTML, bool) {
+		if len(args) != 1 || !reGist.MatchString(args[0]) {
+			return "", false
+		}
+		return template.HTML(`<div class="embed embed-gist"><script src="https://gist.github.com/` + args[0] + `.js"></script></div>`), true
+	})
+}
+
+// embedFrame returns a responsive iframe for a video player. src must
+// already be safe to place in an attribute.
+func embedFrame(provider, src, title string) template.HTML {
+	return template.HTML(`<div class="embed embed-` + provider + `"><iframe src="` + src +
+		`" title="` + title + `" loading="lazy" allow="fullscreen; picture-in-picture" allowfullscreen></iframe></div>`)
+}
@@ -0,0 +1,141 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Summary returns the plain text of doc's paragraphs, cut at a word
+// boundary to at most maxLen bytes with an ellipsis added when shortened.
+// It is meant for descriptions in page metadata.
+func Summary(doc *Node, maxLen int) string {
+	var parts []string
+	doc.Walk(func(n *Node) bool {
+		switch n.Kind {
+		case Paragraph:
+			if text := strings.Join(strings.Fields(plainText(n)), " "); text != "" {
+				parts = append(parts, text)
+			}
+			return false
+		case Footnotes, CodeBlock, MathBlock, HTMLBlock, Table:
+			return false
+		}
+		return true
+	})
+	text := strings.Join(parts, " ")
+	if len(text) <= maxLen {
+		return text
+	}
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if i := strings.LastIndex(text[:cut+1], " "); i > 0 {
+		cut = i
+	}
+	text = text[:cut]
+	return strings.TrimRight(text, " ,;:.") + "…"
+}
+
+// FirstImage returns the destination of the first image in doc, or "" if
+// it has none.
+func FirstImage(doc *Node) string {
+	var dest string
+	doc.Walk(func(n *Node) bool {
+		if n.Kind == Image && dest == "" {
+			dest = n.Dest
+		}
+		return dest == ""
+	})
+	return dest
+}
+
+var (
+	htmlImage  = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	htmlAlt    = regexp.MustCompile(`(?i)\salt\s*=\s*("\s*[^"\s][^"]*"|'\s*[^'\s][^']*'|[^\s"'>]+)`)
+	htmlSrc    = regexp.MustCompile(`(?i)\ssrc\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	htmlAnchor = regexp.MustCompile(`(?i)<a\b[^>]*>`)
+	htmlHref   = regexp.MustCompile(`(?i)\shref\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+)
+
+// ImagesWithoutAlt returns the destinations of the images in doc that have
+// no alternative text, including <img> tags in raw HTML.
+func ImagesWithoutAlt(doc *Node) []string {
+	var dests []string
+	doc.Walk(func(n *Node) bool {
+		switch n.Kind {
+		case Image:
+			if strings.TrimSpace(plainText(n)) == "" {
+				dests = append(dests, n.Dest)
+			}
+			return false
+		case HTMLBlock, RawHTML:
+			for _, tag := range htmlImage.FindAllString(n.Literal, -1) {
+				if !htmlAlt.MatchString(tag) {
+					var src string
+					if m := htmlSrc.FindStringSubmatch(tag); m != nil {
+						src = strings.Trim(m[1], `"'`)
+					}
+					dests = append(dests, src)
+				}
+			}
+		}
+		return true
+	})
+	return dests
+}
+
+// Links returns the destinations of the links in doc, including <a> tags
+// in raw HTML, in the order they first appear.
+func Links(doc *Node) []string {
+	var dests []string
+	add := func(dest string) {
+		if dest != "" && !slices.Contains(dests, dest) {
+			dests = append(dests, dest)
+		}
+	}
+	doc.Walk(func(n *Node) bool {
+		switch n.Kind {
+		case Link:
+			add(n.Dest)
+		case HTMLBlock, RawHTML:
+			for _, tag := range htmlAnchor.FindAllString(n.Literal, -1) {
+				if m := htmlHref.FindStringSubmatch(tag); m != nil {
+					add(html.UnescapeString(strings.Trim(m[1], `"'`)))
+				}
+			}
+		}
+		return true
+	})
+	return dests
+}
+
+// RewriteLinks replaces the destination of each link in doc with what
+// rewrite returns for it. Links in raw HTML are left as they are.
+func RewriteLinks(doc *Node, rewrite func(dest string) string) {
+	doc.Walk(func(n *Node) bool {
+		if n.Kind == Link {
+			n.Dest = rewrite(n.Dest)
+		}
+		return true
+	})
+}
+
+// WordCount returns the number of words in doc's prose, leaving out code
+// blocks, math and raw HTML.
+func WordCount(doc *Node) int {
+	n := 0
+	doc.Walk(func(c *Node) bool {
+		switch c.Kind {
+		case CodeBlock, MathBlock, HTMLBlock, Math, DisplayMath, RawHTML:
+			return false
+		case Text, Code:
+			n += len(strings.Fields(c.Literal))
+		}
+		return true
+	})
+	return n
+}
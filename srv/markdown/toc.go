@@ -0,0 +1,71 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TOCEntry is a heading listed in a document's table of contents.
+type TOCEntry struct {
+	Level int // rendered heading level, 2 or 3
+	ID    string
+	Text  string
+}
+
+// TOC returns the <h2> and <h3> headings of doc in document order.
+func TOC(doc *Node) []TOCEntry {
+	var toc []TOCEntry
+	doc.Walk(func(n *Node) bool {
+		if n.Kind == Heading {
+			if level := headingLevel(n); level <= 3 {
+				toc = append(toc, TOCEntry{Level: level, ID: n.ID, Text: plainText(n)})
+			}
+			return false
+		}
+		return true
+	})
+	return toc
+}
+
+// headingLevel returns the HTML heading level for n. The post title is
+// the page's <h1>, so content headings start at <h2>.
+func headingLevel(n *Node) int {
+	return min(max(n.Level, 2), 6)
+}
+
+// assignHeadingIDs gives each heading a slug of its text, adding a numeric
+// suffix to repeated slugs so that IDs are unique within the document.
+func assignHeadingIDs(doc *Node) {
+	seen := make(map[string]int)
+	doc.Walk(func(n *Node) bool {
+		if n.Kind != Heading {
+			return true
+		}
+		base := headingSlug(plainText(n))
+		id := base
+		for seen[id] > 0 {
+			id = base + "-" + strconv.Itoa(seen[base])
+			seen[base]++
+		}
+		seen[id]++
+		n.ID = id
+		return false
+	})
+}
+
+func headingSlug(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-':
+			sb.WriteRune(r)
+		case unicode.IsSpace(r):
+			sb.WriteByte('-')
+		}
+	}
+	if sb.Len() == 0 {
+		return "section"
+	}
+	return sb.String()
+}
@@ -0,0 +1,78 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var dashReplacer = strings.NewReplacer("---", "—", "--", "–", "...", "…")
+
+// smarten applies typographic replacements to the text of every block that
+// holds inline content. Quotes are resolved against the preceding
+// character, which may belong to an earlier node in the same block, so
+// "*word*" still gets an opening quote before the emphasis.
+func smarten(doc *Node) {
+	doc.Walk(func(n *Node) bool {
+		switch n.Kind {
+		case Paragraph, Heading, TableCell:
+		default:
+			return true
+		}
+		prev := ' '
+		n.Walk(func(c *Node) bool {
+			switch c.Kind {
+			case Text:
+				c.Literal, prev = smartenText(c.Literal, prev)
+			case Code:
+				if r, _ := utf8.DecodeLastRuneInString(c.Literal); r != utf8.RuneError {
+					prev = r
+				}
+			case SoftBreak, HardBreak:
+				prev = ' '
+			}
+			return true
+		})
+		return false
+	})
+}
+
+// smartenText replaces dashes, ellipses and straight quotes in s. prev is
+// the character that precedes s; the last character of the result is
+// returned for the next run of text.
+func smartenText(s string, prev rune) (string, rune) {
+	s = dashReplacer.Replace(s)
+	if !strings.ContainsAny(s, `"'`) {
+		if r, _ := utf8.DecodeLastRuneInString(s); r != utf8.RuneError {
+			prev = r
+		}
+		return s, prev
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '"':
+			if opensQuote(prev) {
+				r = '“'
+			} else {
+				r = '”'
+			}
+		case '\'':
+			if opensQuote(prev) {
+				r = '‘'
+			} else {
+				r = '’'
+			}
+		}
+		sb.WriteRune(r)
+		prev = r
+	}
+	return sb.String(), prev
+}
+
+// opensQuote reports whether a quote following prev starts a quotation.
+// Anything else, such as a letter in "don't", makes it a closing quote or
+// apostrophe.
+func opensQuote(prev rune) bool {
+	return unicode.IsSpace(prev) || strings.ContainsRune("([{<–—“‘", prev)
+}
@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+)
+
+// allowedSchemes lists the URL schemes permitted in link destinations.
+// Destinations without a scheme are treated as relative URLs.
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// safeURL percent-encodes dest and reports whether its scheme is allowed.
+func safeURL(dest string) (string, bool) {
+	dest = strings.TrimSpace(dest)
+	if i := strings.IndexAny(dest, ":/?#"); i > 0 && dest[i] == ':' {
+		if !allowedSchemes[strings.ToLower(dest[:i])] {
+			return "", false
+		}
+	}
+	return encodeURL(dest), true
+}
+
+// isExternal reports whether u points at another site.
+func isExternal(u string) bool {
+	lower := strings.ToLower(u)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "//")
+}
+
+func encodeURL(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteByte(c)
+		case c <= ' ' || c >= 0x7f || strings.IndexByte("%\"<>\\^`{|}", c) >= 0:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,63 @@
+package srv
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// mastodonStatusLimit is the most characters a status may have on a
+	// Mastodon server that keeps the default.
+	mastodonStatusLimit = 500
+	// mastodonLinkLength is what Mastodon counts each link in a status as,
+	// whatever its length.
+	mastodonLinkLength = 23
+)
+
+// tootPost shares p on the configured Mastodon account, unless sharing is
+// not set up or was turned off for p.
+func (s *Server) tootPost(ctx context.Context, base string, p dbgen.Post) error {
+	server, token := s.setting(ctx, settingMastodonServer), s.setting(ctx, settingMastodonToken)
+	if server == "" || token == "" || p.NoCrosspost == 1 {
+		return nil
+	}
+	var description string
+	if s.settingBool(ctx, settingMastodonExcerpt) {
+		description = cmp.Or(p.MetaDescription, p.Excerpt, s.renderPost(ctx, p).summary)
+	}
+	form := url.Values{
+		"status":     {tootText(p.Title, description, base+"/post/"+p.Slug)},
+		"visibility": {"public"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server+"/api/v1/statuses", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+	// Mastodon answers a request repeated with the same key, within the
+	// hour, with the status the first one made.
+	req.Header.Set("Idempotency-Key", "post-"+strconv.FormatInt(p.ID, 10))
+	return doHookRequest(req)
+}
+
+// tootText returns the status that shares a post: its title, then the
+// description, if any, shortened so that the status fits in
+// mastodonStatusLimit, then its link.
+func tootText(title, description, link string) string {
+	text := title
+	// Each part after the title follows a blank line, and a shortened
+	// description ends with "...".
+	room := mastodonStatusLimit - utf8.RuneCountInString(title) - mastodonLinkLength - 4 - 3
+	if description != "" && room > 0 {
+		text += "\n\n" + excerpt(description, room)
+	}
+	return text + "\n\n" + link
+}
@@ -0,0 +1,505 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // decoder for image.DecodeConfig
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/imagemeta"
+	"srv.exe.dev/srv/mediastore"
+	"srv.exe.dev/srv/resize"
+)
+
+// maxUploadSize is the largest image, in bytes, that can be uploaded.
+const maxUploadSize = 10 << 20
+
+// imageTypes maps the content types of images that can be uploaded to the
+// extension their files are stored with.
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// errNotImage is returned by saveMedia for data that is not an image of
+// one of the imageTypes.
+var errNotImage = errors.New("not a JPEG, PNG, GIF or WebP image")
+
+// uploadResponse is the body of the response to an upload: the address of
+// the image and Markdown that shows it in a post.
+type uploadResponse struct {
+	ID       int64  `json:"id"`
+	URL      string `json:"url"`
+	Markdown string `json:"markdown"`
+}
+
+// uploadRequest is the JSON body of an upload, for clients such as the
+// editor's paste handler that have the image as data rather than a file.
+// Data is base64, optionally as a data: URL.
+type uploadRequest struct {
+	Filename     string `json:"filename"`
+	Data         string `json:"data"`
+	Alt          string `json:"alt"`
+	KeepMetadata bool   `json:"keep_metadata"` // keep EXIF and similar metadata, which is removed by default
+}
+
+// HandleAdminUpload stores an uploaded image in the media directory and
+// answers with its address. The image is either the file field of a
+// multipart form or, for a JSON body, an uploadRequest. The optional alt
+// is the image's description in the returned Markdown; without it the
+// file name is used. An image that was uploaded before is not stored
+// again: the answer is the earlier upload, with status 200 instead of 201.
+//
+// Metadata such as the location a photo was taken at is removed from the
+// image before it is stored, unless the keep_metadata field is set.
+func (s *Server) HandleAdminUpload(w http.ResponseWriter, r *http.Request) {
+	// Base64 takes four bytes for every three of the image.
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize*4/3+1<<20)
+	req, data, err := readUpload(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if len(data) > maxUploadSize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: "images may be at most 10 MB"})
+		return
+	}
+	if !req.KeepMetadata {
+		if data, err = imagemeta.Strip(data); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+	}
+
+	m, created, err := s.saveMedia(r.Context(), req.Filename, data)
+	if errors.Is(err, errNotImage) {
+		writeJSON(w, http.StatusUnsupportedMediaType, apiError{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error("save media", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "upload failed"})
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		go s.encodeUpload(m)
+	}
+	writeJSON(w, status, uploadResponse{
+		ID:       m.ID,
+		URL:      mediaURL(m),
+		Markdown: mediaMarkdown(m, strings.TrimSpace(req.Alt)),
+	})
+}
+
+// readUpload returns the details and contents of the image uploaded by r.
+// The Data of the returned request is left empty.
+func readUpload(r *http.Request) (req uploadRequest, data []byte, err error) {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, nil, errors.New("invalid JSON body")
+		}
+		encoded := req.Data
+		if strings.HasPrefix(encoded, "data:") {
+			_, encoded, _ = strings.Cut(encoded, ",")
+		}
+		req.Data = ""
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(data) == 0 {
+			return req, nil, errors.New("data must be a base64 image")
+		}
+		return req, data, nil
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return req, nil, errors.New("missing file")
+	}
+	defer file.Close()
+	data, err = io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	if err != nil {
+		return req, nil, errors.New("failed to read file")
+	}
+	return uploadRequest{
+		Filename:     header.Filename,
+		Alt:          r.FormValue("alt"),
+		KeepMetadata: r.FormValue("keep_metadata") != "",
+	}, data, nil
+}
+
+// saveMedia writes data, an image uploaded under the given file name, to
+// the media store and records it in the media table. If the same
+// image was saved before, it returns that one instead, with created false.
+func (s *Server) saveMedia(ctx context.Context, name string, data []byte) (m dbgen.Media, created bool, err error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return dbgen.Media{}, false, errNotImage
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	q := dbgen.New(s.DB)
+	if m, err := q.GetMediaBySHA256(ctx, &hash); err == nil {
+		return m, false, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return dbgen.Media{}, false, err
+	}
+
+	// Go has no WebP decoder, so WebP images are stored without their size.
+	var width, height int
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		width, height = cfg.Width, cfg.Height
+	}
+
+	// Files get random names rather than ones derived from their hash, so
+	// that addresses cannot be guessed before publishing.
+	b := make([]byte, 8)
+	rand.Read(b)
+	filename := hex.EncodeToString(b) + ext
+	store := s.mediaStore(ctx)
+	if err := store.Put(ctx, filename, data, contentType); err != nil {
+		return dbgen.Media{}, false, err
+	}
+	m, err = q.CreateMedia(ctx, dbgen.CreateMediaParams{
+		Filename:     filename,
+		OriginalName: filepath.Base(name),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        int64(width),
+		Height:       int64(height),
+		Sha256:       &hash,
+	})
+	if err != nil {
+		store.Delete(ctx, filename)
+		// The same image may have been saved meanwhile by another upload.
+		if existing, lookupErr := q.GetMediaBySHA256(ctx, &hash); lookupErr == nil {
+			return existing, false, nil
+		}
+		return dbgen.Media{}, false, err
+	}
+	return m, true, nil
+}
+
+// mediaURL returns the site path of an uploaded file.
+func mediaURL(m dbgen.Media) string {
+	return "/media/" + m.Filename
+}
+
+// markdownAlt removes the characters that would end an image description
+// in Markdown early.
+var markdownAlt = strings.NewReplacer("[", "", "]", "", "\n", " ")
+
+// mediaMarkdown returns Markdown showing an uploaded image described by
+// alt, or by its original file name if alt is empty.
+func mediaMarkdown(m dbgen.Media, alt string) string {
+	if alt == "" {
+		alt = strings.TrimSuffix(m.OriginalName, filepath.Ext(m.OriginalName))
+	}
+	return "![" + markdownAlt.Replace(alt) + "](" + mediaURL(m) + ")"
+}
+
+// HandleMedia serves an uploaded file. Files never change once uploaded,
+// so browsers may cache them indefinitely.
+//
+// JPEG and PNG images can be scaled down with the query parameters w and h:
+// with one of them the image is scaled to that width or height, and with
+// both it is scaled and cropped to fill that size. Sizes are rounded up to
+// one of the variantSizes, and each variant is made once and kept in the
+// media store under variants/. Browsers that accept AVIF or WebP are sent
+// the image in that format if an encoder for it is installed; see
+// preferredFormat.
+func (s *Server) HandleMedia(w http.ResponseWriter, r *http.Request) {
+	m, err := dbgen.New(s.DB).GetMediaByFilename(r.Context(), r.PathValue("name"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	width, height, err := variantSize(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	store := s.mediaStore(r.Context())
+	key := m.Filename
+	if width > 0 || height > 0 {
+		if key, err = s.mediaVariant(r.Context(), store, m, width, height); err != nil {
+			slog.Error("make media variant", "file", m.Filename, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	key, contentType := s.preferredFormat(w, r, store, m, key)
+
+	rc, err := store.Open(r.Context(), key)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("open media", "key", key, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	// Files on disk are served with support for ranges and conditional
+	// requests; files in a bucket are streamed as they are.
+	if f, ok := rc.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil {
+			http.ServeContent(w, r, key, fi.ModTime(), f)
+			return
+		}
+	}
+	io.Copy(w, rc)
+}
+
+// mediaStore returns where uploaded files are kept: the S3 bucket given in
+// the settings, or else the media directory.
+func (s *Server) mediaStore(ctx context.Context) mediastore.Store {
+	if bucket := s.setting(ctx, settingS3Bucket); bucket != "" {
+		return mediastore.S3{
+			Endpoint:  s.setting(ctx, settingS3Endpoint),
+			Region:    s.setting(ctx, settingS3Region),
+			Bucket:    bucket,
+			AccessKey: s.setting(ctx, settingS3AccessKey),
+			SecretKey: s.setting(ctx, settingS3SecretKey),
+		}
+	}
+	return mediastore.Disk{Dir: s.MediaDir}
+}
+
+// variantSizes are the widths and heights images are scaled to. Keeping to
+// a few sizes bounds the number of variants kept of each image.
+var variantSizes = []int{96, 200, 400, 800, 1200, 1600}
+
+// variantSize returns the width and height asked for by the w and h query
+// parameters of r, rounded up to variantSizes, or 0 for those not given.
+func variantSize(r *http.Request) (width, height int, err error) {
+	for _, p := range []struct {
+		name string
+		size *int
+	}{{"w", &width}, {"h", &height}} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("%s must be a positive number of pixels", p.name)
+		}
+		i, _ := slices.BinarySearch(variantSizes, n)
+		*p.size = variantSizes[min(i, len(variantSizes)-1)]
+	}
+	return width, height, nil
+}
+
+// mediaVariant returns the key in store of the uploaded image m scaled to
+// width by height, making it if it does not exist yet. It returns the key
+// of m itself if m cannot be scaled, because it is a GIF, which may be
+// animated, or a WebP image, which Go cannot decode, or if it is already
+// small enough.
+func (s *Server) mediaVariant(ctx context.Context, store mediastore.Store, m dbgen.Media, width, height int) (string, error) {
+	if m.ContentType != "image/jpeg" && m.ContentType != "image/png" ||
+		(width == 0 || int64(width) >= m.Width) && (height == 0 || int64(height) >= m.Height) {
+		return m.Filename, nil
+	}
+	ext := filepath.Ext(m.Filename)
+	key := fmt.Sprintf("variants/%s-%dx%d%s", strings.TrimSuffix(m.Filename, ext), width, height, ext)
+	if _, err := store.Size(ctx, key); err == nil {
+		return key, nil
+	}
+
+	// Decoding a large photo takes a lot of memory, so variants are made
+	// one at a time.
+	s.variantMu.Lock()
+	defer s.variantMu.Unlock()
+	if _, err := store.Size(ctx, key); err == nil {
+		return key, nil
+	}
+	rc, err := store.Open(ctx, m.Filename)
+	if err != nil {
+		return "", err
+	}
+	img, _, err := image.Decode(rc)
+	rc.Close()
+	if err != nil {
+		return "", err
+	}
+	if width > 0 && height > 0 {
+		img = resize.Fill(img, width, height)
+	} else {
+		img = resize.Fit(img, width, height)
+	}
+
+	var buf bytes.Buffer
+	if m.ContentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return "", err
+	}
+	return key, store.Put(ctx, key, buf.Bytes(), m.ContentType)
+}
+
+// removeMediaVariants deletes the scaled and converted copies of the
+// uploaded file m.
+func (s *Server) removeMediaVariants(ctx context.Context, store mediastore.Store, m dbgen.Media) {
+	base := strings.TrimSuffix(m.Filename, filepath.Ext(m.Filename))
+	keys, err := store.List(ctx, "variants/"+base)
+	if err != nil {
+		slog.Error("list media variants", "error", err)
+	}
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			slog.Error("remove media variant", "error", err)
+		}
+	}
+}
+
+// sizedImage returns the address of the image ref scaled to width by
+// height, if it is an upload, and ref unchanged otherwise.
+func sizedImage(ref string, width, height int) string {
+	if !strings.HasPrefix(ref, "/media/") || strings.Contains(ref, "?") {
+		return ref
+	}
+	return fmt.Sprintf("%s?w=%d&h=%d", ref, width, height)
+}
+
+// mediaLibrarySize is the most files the media library lists, and
+// mediaPickerSize the most the editor offers to insert, newest first.
+const (
+	mediaLibrarySize = 500
+	mediaPickerSize  = 48
+)
+
+// MediaView is an uploaded file as listed in the admin.
+type MediaView struct {
+	dbgen.Media
+	URL      string
+	Markdown string
+	SizeText string                    // size for people, such as "1.2 MB"
+	UsedBy   []dbgen.ListMediaUsageRow // posts that refer to the file
+}
+
+// listMedia returns the newest uploaded files, at most limit of them.
+func (s *Server) listMedia(ctx context.Context, limit int64) ([]MediaView, error) {
+	files, err := dbgen.New(s.DB).ListMedia(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]MediaView, len(files))
+	for i, m := range files {
+		views[i] = MediaView{
+			Media:    m,
+			URL:      mediaURL(m),
+			Markdown: mediaMarkdown(m, ""),
+			SizeText: formatSize(m.Size),
+		}
+	}
+	return views, nil
+}
+
+// formatSize formats a number of bytes for people.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func (s *Server) HandleAdminMedia(w http.ResponseWriter, r *http.Request) {
+	s.renderAdminMedia(w, r, "")
+}
+
+func (s *Server) renderAdminMedia(w http.ResponseWriter, r *http.Request, errMsg string) {
+	files, err := s.listMedia(r.Context(), mediaLibrarySize)
+	if err != nil {
+		slog.Error("list media", "error", err)
+	}
+	usage, err := dbgen.New(s.DB).ListMediaUsage(r.Context())
+	if err != nil {
+		slog.Error("list media usage", "error", err)
+	}
+	byID := make(map[int64]*MediaView, len(files))
+	for i := range files {
+		byID[files[i].ID] = &files[i]
+	}
+	for _, u := range usage {
+		if m := byID[u.MediaID]; m != nil {
+			m.UsedBy = append(m.UsedBy, u)
+		}
+	}
+	s.render(w, "admin_media.html", map[string]any{
+		"Media":     files,
+		"Error":     errMsg,
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// HandleAdminMediaDelete deletes an uploaded file, unless a post still
+// refers to it.
+func (s *Server) HandleAdminMediaDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := dbgen.New(s.DB)
+	m, err := q.GetMediaByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	used, err := q.CountMediaUsage(r.Context(), id)
+	if err == nil && used > 0 {
+		s.renderAdminMedia(w, r, fmt.Sprintf("%s is still used by %d post(s); remove it from them first", m.OriginalName, used))
+		return
+	}
+	if err == nil {
+		err = q.DeleteMedia(r.Context(), id)
+	}
+	if err != nil {
+		slog.Error("delete media", "error", err)
+		s.renderAdminMedia(w, r, "Failed to delete "+m.OriginalName)
+		return
+	}
+	store := s.mediaStore(r.Context())
+	if err := store.Delete(r.Context(), m.Filename); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Error("remove media file", "error", err)
+	}
+	s.removeMediaVariants(r.Context(), store, m)
+	http.Redirect(w, r, "/admin/media", http.StatusFound)
+}
@@ -0,0 +1,178 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/mediastore"
+)
+
+// mediaFormat is a smaller image format that uploaded JPEG and PNG images
+// are also offered in, to browsers that accept it. Go cannot encode these
+// formats, so copies are made by running the format's usual encoder.
+type mediaFormat struct {
+	contentType string
+	ext         string
+	command     string                        // name of the encoder, looked up in PATH
+	args        func(in, out string) []string // encoder arguments to convert in to out
+}
+
+// mediaFormats are offered best first.
+var mediaFormats = []mediaFormat{
+	{"image/avif", ".avif", "avifenc", func(in, out string) []string { return []string{in, out} }},
+	{"image/webp", ".webp", "cwebp", func(in, out string) []string { return []string{"-quiet", "-q", "80", in, "-o", out} }},
+}
+
+// findImageEncoders returns the paths of the encoders of mediaFormats that
+// are installed, by content type.
+func findImageEncoders() map[string]string {
+	encoders := make(map[string]string)
+	for _, f := range mediaFormats {
+		if path, err := exec.LookPath(f.command); err == nil {
+			encoders[f.contentType] = path
+		}
+	}
+	return encoders
+}
+
+// preferredFormat returns the key in store and the content type of the
+// best version of key, the uploaded image m or a scaled copy of it, that r
+// accepts, making it if need be. Copies that came out no smaller than the
+// image are not used.
+func (s *Server) preferredFormat(w http.ResponseWriter, r *http.Request, store mediastore.Store, m dbgen.Media, key string) (string, string) {
+	if m.ContentType != "image/jpeg" && m.ContentType != "image/png" || len(s.Encoders) == 0 {
+		return key, m.ContentType
+	}
+	w.Header().Add("Vary", "Accept")
+	for _, f := range mediaFormats {
+		encoder := s.Encoders[f.contentType]
+		if encoder == "" || !accepts(r, f.contentType) {
+			continue
+		}
+		alt, err := s.encodeMedia(r.Context(), store, f, encoder, key)
+		if err != nil {
+			slog.Error("encode media", "file", m.Filename, "format", f.contentType, "error", err)
+			continue
+		}
+		if smaller(r.Context(), store, alt, key) {
+			return alt, f.contentType
+		}
+	}
+	return key, m.ContentType
+}
+
+// encodeMedia returns the key of the copy in format f of the image stored
+// under key, running encoder to make it if it does not exist yet. Copies
+// are kept with the scaled variants.
+func (s *Server) encodeMedia(ctx context.Context, store mediastore.Store, f mediaFormat, encoder, key string) (string, error) {
+	name := strings.TrimSuffix(path.Base(key), path.Ext(key)) + f.ext
+	out := "variants/" + name
+	if _, err := store.Size(ctx, out); err == nil {
+		return out, nil
+	}
+
+	s.variantMu.Lock()
+	defer s.variantMu.Unlock()
+	if _, err := store.Size(ctx, out); err == nil {
+		return out, nil
+	}
+	// Encoders work on files, so the image is copied to a temporary
+	// directory and converted there. Encoders may go by the extension of
+	// their output, so the files keep theirs.
+	dir, err := os.MkdirTemp("", "encode-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, path.Base(key))
+	if err := copyToFile(ctx, store, key, in); err != nil {
+		return "", err
+	}
+	tmp := filepath.Join(dir, name)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if output, err := exec.CommandContext(ctx, encoder, f.args(in, tmp)...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", f.command, err, strings.TrimSpace(string(output)))
+	}
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return "", err
+	}
+	return out, store.Put(ctx, out, data, f.contentType)
+}
+
+// copyToFile writes the file stored under key to path.
+func copyToFile(ctx context.Context, store mediastore.Store, key, path string) error {
+	rc, err := store.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// encodeUpload makes the copies of a newly uploaded image in the other
+// formats ahead of the first request for them.
+func (s *Server) encodeUpload(m dbgen.Media) {
+	if m.ContentType != "image/jpeg" && m.ContentType != "image/png" || len(s.Encoders) == 0 {
+		return
+	}
+	ctx := context.Background()
+	store := s.mediaStore(ctx)
+	for _, f := range mediaFormats {
+		if encoder := s.Encoders[f.contentType]; encoder != "" {
+			if _, err := s.encodeMedia(ctx, store, f, encoder, m.Filename); err != nil {
+				slog.Error("encode media", "file", m.Filename, "format", f.contentType, "error", err)
+			}
+		}
+	}
+}
+
+// smaller reports whether the file stored under key a is smaller than the
+// one under b.
+func smaller(ctx context.Context, store mediastore.Store, a, b string) bool {
+	as, err := store.Size(ctx, a)
+	if err != nil {
+		return false
+	}
+	bs, err := store.Size(ctx, b)
+	return err == nil && as < bs
+}
+
+// accepts reports whether the Accept header of r lists contentType, as
+// browsers that support WebP and AVIF do for images.
+func accepts(r *http.Request, contentType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil || mt != contentType {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err != nil || v <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+// Package mediastore stores uploaded files, either in a directory on local
+// disk or in an S3-compatible bucket, so that the blog can run on hosts
+// whose disks do not outlive a deploy.
+//
+// Files are named by keys: slash-separated paths relative to the root of
+// the store. Operations on keys that do not exist return errors that match
+// fs.ErrNotExist.
+package mediastore
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store is where uploaded files are kept.
+type Store interface {
+	// Put stores data under key, replacing any file already there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Open returns the contents of the file stored under key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Size returns the size in bytes of the file stored under key.
+	Size(ctx context.Context, key string) (int64, error)
+	// Delete removes the file stored under key.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys that start with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Disk is a Store that keeps files in a directory.
+type Disk struct {
+	Dir string
+}
+
+func (d Disk) path(key string) string {
+	return filepath.Join(d.Dir, filepath.FromSlash(key))
+}
+
+func (d Disk) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write under another name first so that a file is never read half
+	// written.
+	tmp := filepath.Join(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Open returns an *os.File, which callers may use to serve ranges.
+func (d Disk) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(d.path(key))
+}
+
+func (d Disk) Size(ctx context.Context, key string) (int64, error) {
+	fi, err := os.Stat(d.path(key))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (d Disk) Delete(ctx context.Context, key string) error {
+	return os.Remove(d.path(key))
+}
+
+func (d Disk) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	// Walk the directory the prefix is in, rather than the whole store.
+	root := d.Dir
+	if i := strings.LastIndexByte(prefix, '/'); i >= 0 {
+		root = d.path(prefix[:i])
+	}
+	err := filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Dir, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return keys, nil
+}
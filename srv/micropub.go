@@ -0,0 +1,501 @@
+package srv
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/imagemeta"
+)
+
+// maxNoteTitle is the most characters of a note's content that make up the
+// title of a post made from a note, which has no name of its own.
+const maxNoteTitle = 60
+
+// micropubError is the body of an error answer from the Micropub endpoint.
+type micropubError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// mpProps are the properties of a Micropub entry, each a list of values.
+// Values are strings or, in JSON requests, objects such as {"html": ...}
+// for content or {"value": ..., "alt": ...} for a photo.
+type mpProps map[string][]any
+
+// str returns the first value of the property name as a string: the
+// string itself, or the html or value of an object.
+func (p mpProps) str(name string) string {
+	if len(p[name]) == 0 {
+		return ""
+	}
+	text, _ := mpValue(p[name][0])
+	return text
+}
+
+// strs returns the values of the property name as strings.
+func (p mpProps) strs(name string) []string {
+	var values []string
+	for _, v := range p[name] {
+		if text, _ := mpValue(v); text != "" {
+			values = append(values, text)
+		}
+	}
+	return values
+}
+
+// mpValue returns a property value as a string, and whether it is HTML.
+func mpValue(v any) (text string, isHTML bool) {
+	switch v := v.(type) {
+	case string:
+		return v, false
+	case map[string]any:
+		if html, ok := v["html"].(string); ok {
+			return html, true
+		}
+		text, _ := v["value"].(string)
+		return text, false
+	}
+	return "", false
+}
+
+// mpRequest is the JSON body of a Micropub request: an entry to create,
+// or an action on an existing post.
+type mpRequest struct {
+	Type       []string `json:"type"`
+	Properties mpProps  `json:"properties"`
+	Action     string   `json:"action"`
+	URL        string   `json:"url"`
+	Replace    mpProps  `json:"replace"`
+	Add        mpProps  `json:"add"`
+	// Delete is a list of properties to delete, or an mpProps of values
+	// to delete from them.
+	Delete json.RawMessage `json:"delete"`
+}
+
+// requireMicropubToken protects the Micropub endpoints. Clients send an API
+// token in the Authorization header or, in a form, as access_token. Any
+// token may query; the handlers check that it grants what else is asked.
+func (s *Server) requireMicropubToken(next http.HandlerFunc) http.HandlerFunc {
+	return s.refuseBanned(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+		bearer, ok := bearerToken(r)
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); !ok && r.Method == http.MethodPost && mt != "application/json" {
+			// ParseMultipartForm parses a plain form too, before saying it
+			// is not a multipart one.
+			r.ParseMultipartForm(maxUploadSize)
+			bearer = r.PostFormValue("access_token")
+			ok = bearer != ""
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, micropubError{Error: "unauthorized", Description: "an API token is required"})
+			return
+		}
+		if r, ok = s.checkToken(w, r, bearer); !ok {
+			return
+		}
+		next(w, r)
+	})
+}
+
+// micropubAllows reports whether the token of r grants scope, the
+// IndieAuth scope for a Micropub action, answering 403 if it does not.
+func micropubAllows(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if tokenAllows(apiToken(r), scope) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+	writeJSON(w, http.StatusForbidden, micropubError{Error: "insufficient_scope", Description: "this token does not grant the " + scope + " scope"})
+	return false
+}
+
+// HandleMicropubQuery answers the queries of GET /micropub: q=config for
+// the endpoint's configuration, q=source for the properties of a post and
+// q=syndicate-to, for which there are no targets.
+func (s *Server) HandleMicropubQuery(w http.ResponseWriter, r *http.Request) {
+	base := s.baseURL(r)
+	switch r.URL.Query().Get("q") {
+	case "config":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"media-endpoint": base + "/micropub/media",
+			"syndicate-to":   []string{},
+			"q":              []string{"config", "source", "syndicate-to"},
+		})
+	case "syndicate-to":
+		writeJSON(w, http.StatusOK, map[string]any{"syndicate-to": []string{}})
+	case "source":
+		p, err := s.micropubPost(r, r.URL.Query().Get("url"))
+		if err != nil {
+			s.writeMicropubError(w, err)
+			return
+		}
+		props, err := s.entryProps(r, p)
+		if err != nil {
+			s.writeMicropubError(w, err)
+			return
+		}
+		wanted := r.URL.Query()["properties[]"]
+		if len(wanted) == 0 {
+			writeJSON(w, http.StatusOK, map[string]any{"type": []string{"h-entry"}, "properties": props})
+			return
+		}
+		maps.DeleteFunc(props, func(name string, _ []any) bool { return !slices.Contains(wanted, name) })
+		writeJSON(w, http.StatusOK, map[string]any{"properties": props})
+	default:
+		writeJSON(w, http.StatusBadRequest, micropubError{Error: "invalid_request", Description: "unknown query"})
+	}
+}
+
+// HandleMicropub answers POST /micropub. A form or JSON h-entry creates a
+// post, answering 201 with its address; a JSON update changes one, and
+// delete and undelete move one to the trash and back.
+func (s *Server) HandleMicropub(w http.ResponseWriter, r *http.Request) {
+	var req mpRequest
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, micropubError{Error: "invalid_request", Description: "invalid JSON body"})
+			return
+		}
+	} else {
+		if err := r.ParseMultipartForm(maxUploadSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			writeJSON(w, http.StatusBadRequest, micropubError{Error: "invalid_request", Description: "invalid form"})
+			return
+		}
+		req = formRequest(r)
+	}
+
+	scope := req.Action
+	switch req.Action {
+	case "":
+		scope = "create"
+	case "update", "delete":
+	case "undelete":
+		scope = "delete"
+	default:
+		s.writeMicropubError(w, mpInvalid("unknown action "+req.Action))
+		return
+	}
+	if !micropubAllows(w, r, scope) {
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "":
+		err = s.micropubCreate(w, r, req)
+	case "update":
+		err = s.micropubUpdate(w, r, req)
+	default:
+		err = s.micropubDelete(w, r, req)
+	}
+	if err != nil {
+		s.writeMicropubError(w, err)
+	}
+}
+
+// mpInvalid is a problem with a Micropub request, answered with 400
+// invalid_request.
+type mpInvalid string
+
+func (e mpInvalid) Error() string { return string(e) }
+
+// errNoMicropubPost is returned for a request about a URL that is not a
+// post's.
+var errNoMicropubPost = errors.New("there is no post at that URL")
+
+// writeMicropubError answers a Micropub request that failed with err.
+func (s *Server) writeMicropubError(w http.ResponseWriter, err error) {
+	var invalid mpInvalid
+	var perr postError
+	switch {
+	case errors.As(err, &invalid), errors.As(err, &perr), errors.Is(err, errNoMicropubPost):
+		writeJSON(w, http.StatusBadRequest, micropubError{Error: "invalid_request", Description: err.Error()})
+	default:
+		slog.Error("micropub", "error", err)
+		writeJSON(w, http.StatusInternalServerError, micropubError{Error: "server_error"})
+	}
+}
+
+// formRequest reads the Micropub request of a form. Properties with
+// several values are sent as name[]; h, action, url and the access token
+// are not properties.
+func formRequest(r *http.Request) mpRequest {
+	req := mpRequest{Properties: mpProps{}, Action: r.PostFormValue("action"), URL: r.PostFormValue("url")}
+	if h := r.PostFormValue("h"); h != "" {
+		req.Type = []string{"h-" + h}
+	}
+	for name, values := range r.PostForm {
+		name = strings.TrimSuffix(name, "[]")
+		switch name {
+		case "h", "action", "url", "access_token":
+			continue
+		}
+		for _, v := range values {
+			req.Properties[name] = append(req.Properties[name], v)
+		}
+	}
+	return req
+}
+
+// micropubCreate adds the post that req describes, along with any photos
+// uploaded with it.
+func (s *Server) micropubCreate(w http.ResponseWriter, r *http.Request, req mpRequest) error {
+	if len(req.Type) > 0 && req.Type[0] != "h-entry" {
+		return mpInvalid("only h-entry posts can be made")
+	}
+	if r.MultipartForm != nil {
+		for _, field := range []string{"photo", "photo[]"} {
+			for _, header := range r.MultipartForm.File[field] {
+				m, err := s.saveMicropubMedia(r, header)
+				if err != nil {
+					return err
+				}
+				req.Properties["photo"] = append(req.Properties["photo"], mediaURL(m))
+			}
+		}
+	}
+	var post PostView
+	applyEntry(&post, req.Properties)
+	if err := s.createPost(r.Context(), &post); err != nil {
+		return err
+	}
+	w.Header().Set("Location", s.baseURL(r)+"/post/"+post.Slug)
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// micropubUpdate changes a post as req says: replacing properties,
+// adding values to them, and deleting them or some of their values.
+func (s *Server) micropubUpdate(w http.ResponseWriter, r *http.Request, req mpRequest) error {
+	p, err := s.micropubPost(r, req.URL)
+	if err != nil {
+		return err
+	}
+	props, err := s.entryProps(r, p)
+	if err != nil {
+		return err
+	}
+	for name, values := range req.Replace {
+		props[name] = values
+	}
+	for name, values := range req.Add {
+		props[name] = append(props[name], values...)
+	}
+	if len(req.Delete) > 0 {
+		var names []string
+		var values mpProps
+		switch {
+		case json.Unmarshal(req.Delete, &names) == nil:
+			for _, name := range names {
+				delete(props, name)
+			}
+		case json.Unmarshal(req.Delete, &values) == nil:
+			for name, remove := range values {
+				props[name] = slices.DeleteFunc(props[name], func(v any) bool {
+					text, _ := mpValue(v)
+					return slices.ContainsFunc(remove, func(r any) bool {
+						other, _ := mpValue(r)
+						return other == text
+					})
+				})
+			}
+		default:
+			return mpInvalid("delete must list properties, or values of them")
+		}
+	}
+	postTags, err := dbgen.New(s.DB).GetPostTags(r.Context(), p.ID)
+	if err != nil {
+		return err
+	}
+	post := postView(p, postTags)
+	applyEntry(&post, props)
+	if err := s.updatePost(r.Context(), &post, ""); err != nil {
+		return err
+	}
+	if post.Slug != p.Slug {
+		w.Header().Set("Location", s.baseURL(r)+"/post/"+post.Slug)
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// micropubDelete moves a post to the trash, or restores it from there.
+func (s *Server) micropubDelete(w http.ResponseWriter, r *http.Request, req mpRequest) error {
+	p, err := s.micropubPost(r, req.URL)
+	if err != nil {
+		return err
+	}
+	q := dbgen.New(s.DB)
+	if req.Action == "delete" {
+		err = q.TrashPost(r.Context(), p.ID)
+	} else {
+		err = q.RestorePost(r.Context(), p.ID)
+	}
+	if err != nil {
+		return err
+	}
+	s.renders.remove(p.ID)
+	s.postChanged(r.Context(), &p, p.ID)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// micropubPost returns the post whose page is at rawURL, which may be in
+// the trash.
+func (s *Server) micropubPost(r *http.Request, rawURL string) (dbgen.Post, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return dbgen.Post{}, errNoMicropubPost
+	}
+	slug, ok := strings.CutPrefix(u.Path, "/post/")
+	if !ok {
+		return dbgen.Post{}, errNoMicropubPost
+	}
+	p, err := dbgen.New(s.DB).GetPostBySlug(r.Context(), strings.TrimSuffix(slug, "/"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, errNoMicropubPost
+	}
+	return p, err
+}
+
+// entryProps returns p as the properties of an h-entry.
+func (s *Server) entryProps(r *http.Request, p dbgen.Post) (mpProps, error) {
+	postTags, err := dbgen.New(s.DB).GetPostTags(r.Context(), p.ID)
+	if err != nil {
+		return nil, err
+	}
+	status := "published"
+	if p.Published == 0 {
+		status = "draft"
+	}
+	props := mpProps{
+		"name":        {p.Title},
+		"content":     {p.Content},
+		"post-status": {status},
+		"published":   {p.CreatedAt.UTC().Format(time.RFC3339)},
+		"url":         {s.baseURL(r) + "/post/" + p.Slug},
+	}
+	if p.Excerpt != "" {
+		props["summary"] = []any{p.Excerpt}
+	}
+	for _, t := range postTags {
+		props["category"] = append(props["category"], t.Name)
+	}
+	return props, nil
+}
+
+// applyEntry sets the fields of post from the properties of an h-entry:
+// name, content, summary, category, post-status and photo, which is added
+// to the end of the content. mp-slug chooses the slug. An entry without a
+// name, such as a note, is titled with the start of its content.
+func applyEntry(post *PostView, props mpProps) {
+	post.Title = strings.TrimSpace(props.str("name"))
+	post.Content = ""
+	if len(props["content"]) > 0 {
+		content, isHTML := mpValue(props["content"][0])
+		post.Content = content
+		if isHTML {
+			post.AllowHTML = true
+		}
+	}
+	for _, photo := range props["photo"] {
+		src, _ := mpValue(photo)
+		alt := ""
+		if obj, ok := photo.(map[string]any); ok {
+			alt, _ = obj["alt"].(string)
+		}
+		if src != "" {
+			post.Content = strings.TrimSpace(post.Content + "\n\n![" + markdownAlt.Replace(alt) + "](" + src + ")")
+		}
+	}
+	post.Excerpt = strings.TrimSpace(props.str("summary"))
+	post.TagList = strings.Join(props.strs("category"), ",")
+	if slug := strings.TrimSpace(props.str("mp-slug")); slug != "" {
+		post.Slug = slug
+	}
+	post.Published = props.str("post-status") != "draft"
+	if post.Title == "" {
+		post.Title = noteTitle(post.Content)
+	}
+}
+
+// noteTitle returns a title for a post made from a note: the start of its
+// first line, shortened at a word to at most maxNoteTitle characters.
+func noteTitle(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	title := strings.Join(strings.Fields(line), " ")
+	if utf8.RuneCountInString(title) <= maxNoteTitle {
+		return title
+	}
+	cut := string([]rune(title)[:maxNoteTitle])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, ",.;:-") + "…"
+}
+
+// HandleMicropubMedia answers POST /micropub/media, the Micropub media
+// endpoint, by storing the uploaded image as the admin's uploads are and
+// answering 201 with its address.
+func (s *Server) HandleMicropubMedia(w http.ResponseWriter, r *http.Request) {
+	if !micropubAllows(w, r, "media") {
+		return
+	}
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil || r.MultipartForm.File["file"] == nil {
+		writeJSON(w, http.StatusBadRequest, micropubError{Error: "invalid_request", Description: "upload the image as the file field of a multipart form"})
+		return
+	}
+	m, err := s.saveMicropubMedia(r, r.MultipartForm.File["file"][0])
+	if err != nil {
+		s.writeMicropubError(w, err)
+		return
+	}
+	w.Header().Set("Location", s.baseURL(r)+mediaURL(m))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// saveMicropubMedia stores an image uploaded to the Micropub endpoints,
+// without its metadata.
+func (s *Server) saveMicropubMedia(r *http.Request, header *multipart.FileHeader) (dbgen.Media, error) {
+	file, err := header.Open()
+	if err != nil {
+		return dbgen.Media{}, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	if err != nil {
+		return dbgen.Media{}, err
+	}
+	if len(data) > maxUploadSize {
+		return dbgen.Media{}, mpInvalid("images may be at most 10 MB")
+	}
+	if data, err = imagemeta.Strip(data); err != nil {
+		return dbgen.Media{}, mpInvalid(err.Error())
+	}
+	m, created, err := s.saveMedia(r.Context(), header.Filename, data)
+	if errors.Is(err, errNotImage) {
+		return m, mpInvalid(err.Error())
+	}
+	if err != nil {
+		return m, err
+	}
+	if created {
+		go s.encodeUpload(m)
+	}
+	return m, nil
+}
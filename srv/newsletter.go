@@ -0,0 +1,197 @@
+package srv
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// subscribeConfirmTTL is how long the link confirming a subscription
+	// works. Subscriptions not confirmed by then are dropped.
+	subscribeConfirmTTL = 7 * 24 * time.Hour
+	// subscribeResendAfter is how long a subscription waits for its
+	// address to be confirmed before asking again sends another link, so
+	// that the form cannot be used to flood someone's inbox.
+	subscribeResendAfter = time.Hour
+)
+
+// newsletterOn reports whether readers may subscribe to new posts by
+// email: the newsletter is turned on and the blog can send email.
+func (s *Server) newsletterOn(ctx context.Context) bool {
+	return s.settingBool(ctx, settingNewsletter) && s.mailConfigured(ctx)
+}
+
+// renderSubscription renders the subscribe page in the given state: the
+// form, or what became of a subscription.
+func (s *Server) renderSubscription(w http.ResponseWriter, status int, data map[string]any) {
+	data["Year"] = time.Now().Year()
+	data["Page"] = "subscribe"
+	s.renderStatus(w, status, "base.html", data)
+}
+
+// HandleSubscribe shows the form to subscribe to new posts by email.
+func (s *Server) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
+	if !s.newsletterOn(r.Context()) {
+		http.NotFound(w, r)
+		return
+	}
+	state := ""
+	if r.URL.Query().Get("sent") == "1" {
+		state = "sent"
+	}
+	s.renderSubscription(w, http.StatusOK, map[string]any{"Subscription": state})
+}
+
+// HandleSubscribeSubmit takes an address from the subscribe form and
+// emails it a link to confirm the subscription. Its answer is the same
+// whether or not the address was already subscribed, so that the form
+// does not tell who is. Like the comment form, it has no CSRF token;
+// nobody is subscribed without confirming.
+func (s *Server) HandleSubscribeSubmit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !s.newsletterOn(ctx) {
+		http.NotFound(w, r)
+		return
+	}
+	email := strings.TrimSpace(r.FormValue("email"))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" {
+		s.renderSubscription(w, http.StatusBadRequest, map[string]any{
+			"Email": email,
+			"Error": "That email address doesn't look right",
+		})
+		return
+	}
+
+	q := dbgen.New(s.DB)
+	if err := q.PruneUnconfirmedSubscribers(ctx, dbTime(time.Now().Add(-subscribeConfirmTTL))); err != nil {
+		slog.Error("prune unconfirmed subscribers", "error", err)
+	}
+	sub, err := q.GetSubscriberByEmail(ctx, addr.Address)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		sub, err = q.CreateSubscriber(ctx, dbgen.CreateSubscriberParams{Email: addr.Address, Token: rand.Text()})
+		if err != nil {
+			slog.Error("create subscriber", "error", err)
+			http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
+			return
+		}
+	case err != nil:
+		slog.Error("get subscriber", "error", err)
+		http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
+		return
+	case sub.ConfirmedAt != nil || time.Since(sub.CreatedAt) < subscribeResendAfter:
+		http.Redirect(w, r, "/subscribe?sent=1", http.StatusSeeOther)
+		return
+	default:
+		if err := q.RenewSubscriber(ctx, sub.ID); err != nil {
+			slog.Error("renew subscriber", "error", err)
+		}
+	}
+
+	m, err := s.mailMessage("subscribe-confirm", sub.Email, map[string]any{
+		"Link": s.baseURL(r) + "/subscribe/confirm?token=" + url.QueryEscape(sub.Token),
+	})
+	if err == nil {
+		err = s.queueMail(ctx, m)
+	}
+	if err != nil {
+		slog.Error("queue subscription confirmation", "subscriber", sub.ID, "error", err)
+		http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/subscribe?sent=1", http.StatusSeeOther)
+}
+
+// HandleSubscribeConfirm confirms the subscription whose link was
+// followed.
+func (s *Server) HandleSubscribeConfirm(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	sub, err := q.GetSubscriberByToken(r.Context(), r.URL.Query().Get("token"))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("get subscriber", "error", err)
+		http.Error(w, "Failed to confirm", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || sub.ConfirmedAt == nil && time.Since(sub.CreatedAt) > subscribeConfirmTTL {
+		s.renderSubscription(w, http.StatusNotFound, map[string]any{"Subscription": "invalid"})
+		return
+	}
+	if err := q.ConfirmSubscriber(r.Context(), sub.ID); err != nil {
+		slog.Error("confirm subscriber", "error", err)
+		http.Error(w, "Failed to confirm", http.StatusInternalServerError)
+		return
+	}
+	s.renderSubscription(w, http.StatusOK, map[string]any{"Subscription": "confirmed"})
+}
+
+// HandleUnsubscribe asks for the unsubscribe link to be confirmed with a
+// button, as mail filters may follow the links in an email.
+func (s *Server) HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if _, err := dbgen.New(s.DB).GetSubscriberByToken(r.Context(), token); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("get subscriber", "error", err)
+		}
+		s.renderSubscription(w, http.StatusNotFound, map[string]any{"Subscription": "invalid"})
+		return
+	}
+	s.renderSubscription(w, http.StatusOK, map[string]any{"Subscription": "unsubscribe", "Token": token})
+}
+
+// HandleUnsubscribeSubmit ends a subscription, from the button on the
+// unsubscribe page or straight from a mail program that offers to
+// unsubscribe in one click (RFC 8058).
+func (s *Server) HandleUnsubscribeSubmit(w http.ResponseWriter, r *http.Request) {
+	if _, err := dbgen.New(s.DB).DeleteSubscriberByToken(r.Context(), r.FormValue("token")); err != nil {
+		slog.Error("delete subscriber", "error", err)
+		http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	s.renderSubscription(w, http.StatusOK, map[string]any{"Subscription": "unsubscribed"})
+}
+
+// emailSubscribers queues an email about p to every confirmed
+// subscriber, with headers that let mail programs offer to unsubscribe.
+func (s *Server) emailSubscribers(ctx context.Context, base string, p dbgen.Post) error {
+	if !s.newsletterOn(ctx) {
+		return nil
+	}
+	subs, err := dbgen.New(s.DB).ListConfirmedSubscribers(ctx)
+	if err != nil {
+		return err
+	}
+	description := cmp.Or(p.MetaDescription, p.Excerpt, s.renderPost(ctx, p).summary)
+	for _, sub := range subs {
+		unsubscribe := base + "/unsubscribe?token=" + url.QueryEscape(sub.Token)
+		m, err := s.mailMessage("newsletter", sub.Email, map[string]any{
+			"Title":       p.Title,
+			"Description": description,
+			"Link":        base + "/post/" + p.Slug,
+			"Unsubscribe": unsubscribe,
+		})
+		if err != nil {
+			return err
+		}
+		m.Header = map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+		if err := s.queueMail(ctx, m); err != nil {
+			return fmt.Errorf("queue email to subscriber %d: %w", sub.ID, err)
+		}
+	}
+	return nil
+}
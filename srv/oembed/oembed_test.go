@@ -0,0 +1,84 @@
+package oembed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestClient(t *testing.T, body string) *Client {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "json" || r.URL.Query().Get("url") == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(ts.Close)
+
+	c := NewClient()
+	c.Providers = []Provider{{
+		Name:     "soundcloud",
+		Endpoint: ts.URL,
+		Hosts:    []string{"soundcloud.com"},
+		Players:  []string{"w.soundcloud.com"},
+	}}
+	return c
+}
+
+func TestMatch(t *testing.T) {
+	c := NewClient()
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "youtube"},
+		{"https://youtu.be/dQw4w9WgXcQ", "youtube"},
+		{"https://vimeo.com/76979871", "vimeo"},
+		{"https://soundcloud.com/artist/track", "soundcloud"},
+		{"https://example.com/watch", ""},
+		{"ftp://youtube.com/x", ""},
+	}
+	for _, test := range tests {
+		p, _ := c.Match(test.url)
+		if p.Name != test.expected {
+			t.Errorf("Match(%q) = %q, expected %q", test.url, p.Name, test.expected)
+		}
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	t.Run("rebuilds player iframe", func(t *testing.T) {
+		c := newTestClient(t, `{"type":"rich","title":"Track <1>","height":"166",`+
+			`"html":"<iframe width=\"100%\" onload=\"alert(1)\" src=\"https://w.soundcloud.com/player/?url=x&amp;auto_play=false\"></iframe><script>bad()</script>"}`)
+
+		html, err := c.Embed(context.Background(), "https://soundcloud.com/artist/track")
+		if err != nil {
+			t.Fatalf("Embed() error: %v", err)
+		}
+		expected := `<div class="embed embed-soundcloud"><iframe src="https://w.soundcloud.com/player/?url=x&amp;auto_play=false" title="Track &lt;1&gt;" height="166" loading="lazy" allow="fullscreen; picture-in-picture" allowfullscreen></iframe></div>`
+		if string(html) != expected {
+			t.Errorf("Embed() = %s, expected %s", html, expected)
+		}
+	})
+
+	t.Run("rejects foreign player", func(t *testing.T) {
+		c := newTestClient(t, `{"type":"rich","html":"<iframe src=\"https://evil.example/player\"></iframe>"}`)
+
+		_, err := c.Embed(context.Background(), "https://soundcloud.com/artist/track")
+		if err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("expected foreign player to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		_, err := NewClient().Embed(context.Background(), "https://example.com/video")
+		if err != ErrNoProvider {
+			t.Errorf("expected ErrNoProvider, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package srv
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HandleOpenAPI answers GET /api/v1/openapi.json with an OpenAPI 3
+// document describing the public API, from which clients can be
+// generated.
+func (s *Server) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.openAPI(r))
+}
+
+// openAPI returns the OpenAPI document for the endpoints in apiRoutes.
+func (s *Server) openAPI(r *http.Request) map[string]any {
+	schemas := map[string]any{}
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     jsonContent(schemaOf(reflect.TypeFor[apiError](), schemas)),
+	}
+	paths := map[string]any{}
+	for _, route := range s.apiRoutes() {
+		method, path, _ := strings.Cut(route.pattern, " ")
+		op := map[string]any{
+			"operationId": route.operationID,
+			"summary":     route.summary,
+		}
+
+		var params []any
+		for _, name := range pathParams(path) {
+			params = append(params, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		for _, p := range route.params {
+			schema := map[string]any{"type": p.typ}
+			if p.format != "" {
+				schema["format"] = p.format
+			}
+			params = append(params, map[string]any{
+				"name":        p.name,
+				"in":          "query",
+				"required":    p.required,
+				"description": p.description,
+				"schema":      schema,
+			})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+
+		if route.body != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(schemaOf(reflect.TypeOf(route.body), schemas)),
+			}
+		}
+		success := map[string]any{"description": http.StatusText(route.status)}
+		if route.response != nil {
+			success["content"] = jsonContent(schemaOf(reflect.TypeOf(route.response), schemas))
+		}
+		op["responses"] = map[string]any{
+			strconv.Itoa(route.status): success,
+			"default":                  errorResponse,
+		}
+
+		// An empty requirement lets requests without a token through.
+		// Bearer schemes have no scopes to list, so the one needed to
+		// write is only told in the description.
+		bearer := map[string]any{"bearerAuth": []string{}}
+		switch route.auth {
+		case authOptional:
+			op["security"] = []any{map[string]any{}, bearer}
+		case authWrite:
+			op["security"] = []any{bearer}
+			op["description"] = "Needs an API token with the write scope."
+		case authHook:
+			op["security"] = []any{map[string]any{}, bearer}
+			op["description"] = "Needs an API token with the write scope, or the sync secret from the admin settings: " +
+				"in X-Gitlab-Token, or as an X-Hub-Signature-256 of the body, as GitHub and Gitea send it."
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path].(map[string]any)[strings.ToLower(method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   siteTitle + " API",
+			"version": "1",
+		},
+		"servers": []any{map[string]any{"url": s.baseURL(r)}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "An API token from the Tokens page of the admin",
+				},
+			},
+		},
+	}
+}
+
+// pathParams returns the names of the wildcards in a ServeMux path.
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			names = append(names, strings.TrimSuffix(strings.TrimSuffix(name, "}"), "..."))
+		}
+	}
+	return names
+}
+
+// jsonContent returns the content of a request or response body with the
+// given JSON schema.
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// schemaOf returns the JSON schema of values of type t as encoding/json
+// writes them. Structs are added to schemas by name and referred to.
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = nil // for types that refer to themselves
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// structSchema returns the JSON schema of a struct type. Fields that are
+// always written are required.
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaOf(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName returns the name under which a struct type's schema is kept:
+// its Go name, capitalized and without an api prefix.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if rest, ok := strings.CutPrefix(name, "api"); ok && rest != "" {
+		name = rest
+	}
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(first)) + name[size:]
+}
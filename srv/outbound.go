@@ -0,0 +1,99 @@
+package srv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// settingOutKey is the setting holding the key links through /out are
+// signed with, so that /out only sends readers on to links that are in
+// posts and cannot be used to redirect anyone anywhere. It is made the
+// first time it is needed and, not being in settingDefs, is not shown in
+// the admin.
+const settingOutKey = "out_key"
+
+// clickKey is what clicks on links to other sites are counted by: a post,
+// a day, in UTC, and the link followed from it.
+type clickKey struct {
+	viewKey
+	target string
+}
+
+// outRewriter returns the function that turns the destination of a link
+// in the post with the given ID into one through /out, if it is a link to
+// another site than siteHost.
+func outRewriter(key []byte, postID int64, siteHost string) func(string) string {
+	return func(dest string) string {
+		u, err := url.Parse(dest)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return dest
+		}
+		if siteHost != "" && bareHost(u.Hostname()) == siteHost {
+			return dest
+		}
+		return "/out?u=" + url.QueryEscape(dest) + "&p=" + strconv.FormatInt(postID, 10) + "&s=" + outSignature(key, postID, dest)
+	}
+}
+
+// outSignature returns the signature of a link to target from the post
+// with the given ID.
+func outSignature(key []byte, postID int64, target string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strconv.FormatInt(postID, 10) + "\n" + target))
+	return hex.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// countClick counts a click on the link to target in the post with the
+// given ID. Like views, clicks are kept in memory for viewLoop to write.
+func (s *Server) countClick(postID int64, target string) {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+	k := viewKey{postID, time.Now().UTC().Format(time.DateOnly)}
+	s.viewBuffer().clicks[clickKey{k, target}]++
+}
+
+// siteHost returns the host of the site URL in the settings, as bareHost
+// gives it, or "" if there is none.
+func (s *Server) siteHost(ctx context.Context) string {
+	site, err := url.Parse(s.setting(ctx, settingSiteURL))
+	if err != nil {
+		return ""
+	}
+	return bareHost(site.Hostname())
+}
+
+// HandleOut sends a reader who followed a link to another site in a post
+// on to it, counting the click for the post and link, unless the request
+// is taken for a bot's. The link is the u query parameter, p is the post's
+// ID and s their signature, and a link that is not signed is refused.
+func (s *Server) HandleOut(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	target := query.Get("u")
+	postID, err := strconv.ParseInt(query.Get("p"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad link", http.StatusBadRequest)
+		return
+	}
+	key, err := s.storedKey(r.Context(), settingOutKey)
+	if err != nil {
+		slog.Error("load outbound link key", "error", err)
+		http.Error(w, "Failed to follow link", http.StatusInternalServerError)
+		return
+	}
+	if !hmac.Equal([]byte(query.Get("s")), []byte(outSignature(key, postID, target))) {
+		http.Error(w, "Bad link", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet && s.classifyBot(r) == "" {
+		s.countClick(postID, target)
+	}
+	w.Header().Set("X-Robots-Tag", "noindex")
+	http.Redirect(w, r, target, http.StatusFound)
+}
@@ -0,0 +1,36 @@
+package srv
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Pagination describes where a page sits in a paginated listing, for the
+// previous and next links.
+type Pagination struct {
+	Page       int
+	TotalPages int
+}
+
+func (p Pagination) HasPrev() bool { return p.Page > 1 }
+func (p Pagination) HasNext() bool { return p.Page < p.TotalPages }
+func (p Pagination) PrevPage() int { return p.Page - 1 }
+func (p Pagination) NextPage() int { return p.Page + 1 }
+
+// pageNumber returns the 1-based page requested by the page query
+// parameter, or 1 if it is missing or invalid.
+func pageNumber(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
+
+// paginate returns the pagination for page of a listing of total items
+// shown perPage at a time. Page numbers past the end are kept, so they
+// show an empty page with a link back.
+func paginate(page, perPage int, total int64) Pagination {
+	pages := int((total + int64(perPage) - 1) / int64(perPage))
+	return Pagination{Page: page, TotalPages: max(pages, 1)}
+}
@@ -0,0 +1,83 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// homePopular is the number of popular posts on the home page.
+	homePopular = 5
+	// popularPageSize is the number of posts on /popular.
+	popularPageSize = 20
+)
+
+// popularDays returns the window, in days up to and including today, that
+// posts are ranked by views over.
+func (s *Server) popularDays(ctx context.Context) int {
+	days, err := strconv.Atoi(s.setting(ctx, settingPopularDays))
+	if err != nil || days < 1 {
+		def, _ := lookupSetting(settingPopularDays)
+		days, _ = strconv.Atoi(def.Default)
+	}
+	return days
+}
+
+// popularPeriod names a window of days for headings, such as "this month"
+// in "Popular this month".
+func popularPeriod(days int) string {
+	switch days {
+	case 1:
+		return "today"
+	case 7:
+		return "this week"
+	case 30, 31:
+		return "this month"
+	case 365:
+		return "this year"
+	}
+	return "in the last " + strconv.Itoa(days) + " days"
+}
+
+// popularPosts returns the n published posts most viewed in the last days
+// days, most viewed first.
+func (s *Server) popularPosts(ctx context.Context, days, n int) []PostView {
+	rows, err := dbgen.New(s.DB).ListPopularPosts(ctx, dbgen.ListPopularPostsParams{
+		Since:    time.Now().UTC().AddDate(0, 0, 1-days).Format(time.DateOnly),
+		MaxPosts: int64(n),
+	})
+	if err != nil {
+		slog.Error("list popular posts", "error", err)
+	}
+	posts := make([]PostView, 0, len(rows))
+	for _, row := range rows {
+		posts = append(posts, PostView{
+			ID:         row.ID,
+			Slug:       row.Slug,
+			Title:      row.Title,
+			CoverImage: row.CoverImage,
+			CoverSrc:   sizedImage(row.CoverImage, 800, 400),
+			CreatedAt:  row.CreatedAt,
+			Views:      row.Views,
+		})
+	}
+	return posts
+}
+
+// HandlePopular lists the posts most viewed in the window set in the
+// settings.
+func (s *Server) HandlePopular(w http.ResponseWriter, r *http.Request) {
+	days := s.popularDays(r.Context())
+	s.render(w, "base.html", map[string]any{
+		"Posts":         s.popularPosts(r.Context(), days, popularPageSize),
+		"PopularPeriod": popularPeriod(days),
+		"Newsletter":    s.newsletterOn(r.Context()),
+		"Year":          time.Now().Year(),
+		"Page":          "popular",
+	})
+}
@@ -0,0 +1,204 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/slug"
+	"srv.exe.dev/srv/tags"
+)
+
+// postError is a problem with a post as submitted, to be shown to whoever
+// submitted it.
+type postError string
+
+func (e postError) Error() string { return string(e) }
+
+// editConflict is returned when a post was saved by someone else after
+// the version being saved was loaded.
+type editConflict struct {
+	current dbgen.Post
+}
+
+func (e *editConflict) Error() string {
+	return fmt.Sprintf("post %d was changed at %s", e.current.ID, e.current.UpdatedAt)
+}
+
+// postView returns p, with its tags, as the admin edits it.
+func postView(p dbgen.Post, postTags []dbgen.Tag) PostView {
+	return PostView{
+		ID:              p.ID,
+		Slug:            p.Slug,
+		Title:           p.Title,
+		Content:         p.Content,
+		Excerpt:         p.Excerpt,
+		Published:       p.Published == 1,
+		AllowHTML:       p.AllowHtml == 1,
+		MetaDescription: p.MetaDescription,
+		OGImage:         p.OgImage,
+		CoverImage:      p.CoverImage,
+		SeriesID:        derefInt64(p.SeriesID),
+		SeriesOrder:     p.SeriesOrder,
+		CategoryID:      derefInt64(p.CategoryID),
+		PublishAt:       derefTime(p.PublishAt),
+		CommentsClosed:  p.CommentsClosed == 1,
+		NoCrosspost:     p.NoCrosspost == 1,
+		TagList:         tags.Join(postTags),
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+	}
+}
+
+// createPost adds post, setting its ID and, if it had none, its slug. A
+// slug made up from the title is numbered if it is taken; one the author
+// chose is not changed, and a postError suggests a free one instead.
+func (s *Server) createPost(ctx context.Context, post *PostView) error {
+	if post.Title == "" {
+		return postError("Title is required")
+	}
+	if post.Slug != "" && !validSlug(post.Slug) {
+		return postError("Slug may only contain lowercase letters, digits and hyphens")
+	}
+	if msg := checkPostImages(*post); msg != "" {
+		return postError(msg)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	generated := post.Slug == ""
+	if generated {
+		post.Slug = slug.Generate(post.Title)
+		if post.Slug == "" {
+			return postError("Could not make a slug from the title; please enter one")
+		}
+	}
+	free, err := slug.Unique(ctx, q, post.Slug)
+	if err != nil {
+		return err
+	}
+	if free != post.Slug && !generated {
+		taken := post.Slug
+		post.Slug = free
+		return postError("Another post already uses the slug " + taken + "; " + free + " is free")
+	}
+	post.Slug = free
+	created, err := q.CreatePost(ctx, dbgen.CreatePostParams{
+		Slug:            post.Slug,
+		Title:           post.Title,
+		Content:         post.Content,
+		Excerpt:         post.Excerpt,
+		Published:       boolToInt(post.Published),
+		AllowHtml:       boolToInt(post.AllowHTML),
+		MetaDescription: post.MetaDescription,
+		OgImage:         post.OGImage,
+		CoverImage:      post.CoverImage,
+		SeriesID:        nullInt64(post.SeriesID),
+		SeriesOrder:     post.SeriesOrder,
+		CategoryID:      nullInt64(post.CategoryID),
+		PublishAt:       scheduledAt(*post),
+		CommentsClosed:  boolToInt(post.CommentsClosed),
+		NoCrosspost:     boolToInt(post.NoCrosspost),
+	})
+	if err == nil {
+		err = tags.Set(ctx, q, created.ID, tags.Parse(post.TagList))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	post.ID = created.ID
+	s.resolveEmbeds(ctx, post.Content)
+	s.postChanged(ctx, nil, post.ID)
+	if post.Published {
+		s.requestAnnounce()
+	}
+	return nil
+}
+
+// updatePost saves post over the one with its ID, keeping a revision of
+// it and redirecting its old slug if that changed. If post has an
+// UpdatedAt that is not the saved one's, it returns an *editConflict;
+// without one it overwrites unconditionally. The edit lock and autosave
+// of editor are dropped once it is saved.
+func (s *Server) updatePost(ctx context.Context, post *PostView, editor string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	current, err := q.GetPostByID(ctx, post.ID)
+	if err != nil {
+		return err
+	}
+	if !post.UpdatedAt.IsZero() && !post.UpdatedAt.Equal(current.UpdatedAt) {
+		return &editConflict{current: current}
+	}
+	oldSlug := current.Slug
+	if post.Slug == "" {
+		post.Slug = oldSlug
+	}
+	if !validSlug(post.Slug) {
+		return postError("Slug may only contain lowercase letters, digits and hyphens")
+	}
+	if other, err := q.GetPostBySlug(ctx, post.Slug); err == nil && other.ID != post.ID {
+		return postError("Another post already uses the slug " + post.Slug)
+	}
+	if msg := checkPostImages(*post); msg != "" {
+		return postError(msg)
+	}
+	err = q.SavePostRevision(ctx, dbgen.SavePostRevisionParams{PostID: post.ID, Title: post.Title, Content: post.Content})
+	if err == nil {
+		err = q.UpdatePost(ctx, dbgen.UpdatePostParams{
+			Slug:            post.Slug,
+			Title:           post.Title,
+			Content:         post.Content,
+			Excerpt:         post.Excerpt,
+			Published:       boolToInt(post.Published),
+			AllowHtml:       boolToInt(post.AllowHTML),
+			MetaDescription: post.MetaDescription,
+			OgImage:         post.OGImage,
+			CoverImage:      post.CoverImage,
+			SeriesID:        nullInt64(post.SeriesID),
+			SeriesOrder:     post.SeriesOrder,
+			CategoryID:      nullInt64(post.CategoryID),
+			PublishAt:       scheduledAt(*post),
+			CommentsClosed:  boolToInt(post.CommentsClosed),
+			NoCrosspost:     boolToInt(post.NoCrosspost),
+			ID:              post.ID,
+		})
+	}
+	if err == nil {
+		err = tags.Set(ctx, q, post.ID, tags.Parse(post.TagList))
+	}
+	if err == nil && post.Slug != oldSlug {
+		err = recordSlugChange(ctx, q, oldSlug, post.Slug)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	s.resolveEmbeds(ctx, post.Content)
+	s.renders.remove(post.ID)
+	if err := dbgen.New(s.DB).ReleasePostLock(ctx, dbgen.ReleasePostLockParams{PostID: post.ID, Editor: editor}); err != nil {
+		slog.Error("release post lock", "error", err)
+	}
+	if err := dbgen.New(s.DB).DeletePostAutosave(ctx, post.ID); err != nil {
+		slog.Error("delete post autosave", "error", err)
+	}
+	s.postChanged(ctx, &current, post.ID)
+	if post.Published {
+		s.requestAnnounce()
+	}
+	return nil
+}
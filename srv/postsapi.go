@@ -0,0 +1,435 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/slug"
+	"srv.exe.dev/srv/tags"
+)
+
+const (
+	// apiPageSize is the number of posts in each page of the posts API,
+	// unless the client asks for a different number.
+	apiPageSize = 20
+	// maxAPIPageSize is the most posts a client can ask for at once.
+	maxAPIPageSize = 100
+)
+
+// apiPost is a post as the posts API gives it.
+type apiPost struct {
+	ID              int64      `json:"id"`
+	Slug            string     `json:"slug"`
+	Title           string     `json:"title"`
+	Content         string     `json:"content"`
+	Excerpt         string     `json:"excerpt"`
+	Published       bool       `json:"published"`
+	PublishAt       *time.Time `json:"publish_at,omitempty"`
+	AllowHTML       bool       `json:"allow_html"`
+	CommentsClosed  bool       `json:"comments_closed"`
+	NoCrosspost     bool       `json:"no_crosspost"`
+	MetaDescription string     `json:"meta_description"`
+	OGImage         string     `json:"og_image"`
+	CoverImage      string     `json:"cover_image"`
+	CategoryID      int64      `json:"category_id,omitempty"`
+	SeriesID        int64      `json:"series_id,omitempty"`
+	SeriesOrder     int64      `json:"series_order,omitempty"`
+	Tags            []string   `json:"tags"`
+	URL             string     `json:"url"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// apiPostInput is the body of a request to create or update a post. On
+// an update, fields that are left out keep their value.
+type apiPostInput struct {
+	Slug            *string    `json:"slug"`
+	Title           *string    `json:"title"`
+	Content         *string    `json:"content"`
+	Excerpt         *string    `json:"excerpt"`
+	Published       *bool      `json:"published"`
+	PublishAt       *time.Time `json:"publish_at"`
+	AllowHTML       *bool      `json:"allow_html"`
+	CommentsClosed  *bool      `json:"comments_closed"`
+	NoCrosspost     *bool      `json:"no_crosspost"`
+	MetaDescription *string    `json:"meta_description"`
+	OGImage         *string    `json:"og_image"`
+	CoverImage      *string    `json:"cover_image"`
+	CategoryID      *int64     `json:"category_id"`
+	SeriesID        *int64     `json:"series_id"`
+	SeriesOrder     *int64     `json:"series_order"`
+	Tags            *[]string  `json:"tags"`
+}
+
+// apiPostCreate is the body of a request to create a post.
+type apiPostCreate struct {
+	apiPostInput
+	// NumberSlug has a slug that another post has numbered, as one made
+	// from the title is, instead of refused.
+	NumberSlug *bool `json:"number_slug"`
+}
+
+// apply sets the fields of post that in gives.
+func (in apiPostInput) apply(post *PostView) {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	set(&post.Slug, in.Slug)
+	set(&post.Title, in.Title)
+	set(&post.Excerpt, in.Excerpt)
+	set(&post.MetaDescription, in.MetaDescription)
+	set(&post.OGImage, in.OGImage)
+	set(&post.CoverImage, in.CoverImage)
+	if in.Content != nil {
+		post.Content = *in.Content
+	}
+	if in.Published != nil {
+		post.Published = *in.Published
+	}
+	if in.PublishAt != nil {
+		post.PublishAt = *in.PublishAt
+	}
+	if in.AllowHTML != nil {
+		post.AllowHTML = *in.AllowHTML
+	}
+	if in.CommentsClosed != nil {
+		post.CommentsClosed = *in.CommentsClosed
+	}
+	if in.NoCrosspost != nil {
+		post.NoCrosspost = *in.NoCrosspost
+	}
+	if in.CategoryID != nil {
+		post.CategoryID = *in.CategoryID
+	}
+	if in.SeriesID != nil {
+		post.SeriesID = *in.SeriesID
+	}
+	if in.SeriesOrder != nil {
+		post.SeriesOrder = *in.SeriesOrder
+	}
+	if in.Tags != nil {
+		post.TagList = strings.Join(*in.Tags, ",")
+	}
+}
+
+// apiPostFrom returns p as the posts API gives it.
+func (s *Server) apiPostFrom(r *http.Request, p dbgen.Post) apiPost {
+	return s.apiPostAt(r.Context(), s.baseURL(r), p)
+}
+
+// apiPostAt returns p as the posts API gives it, for a site at base.
+func (s *Server) apiPostAt(ctx context.Context, base string, p dbgen.Post) apiPost {
+	postTags, err := dbgen.New(s.DB).GetPostTags(ctx, p.ID)
+	if err != nil {
+		slog.Error("get post tags", "error", err)
+	}
+	names := make([]string, len(postTags))
+	for i, t := range postTags {
+		names[i] = t.Name
+	}
+	return apiPost{
+		ID:              p.ID,
+		Slug:            p.Slug,
+		Title:           p.Title,
+		Content:         p.Content,
+		Excerpt:         p.Excerpt,
+		Published:       p.Published == 1,
+		PublishAt:       p.PublishAt,
+		AllowHTML:       p.AllowHtml == 1,
+		CommentsClosed:  p.CommentsClosed == 1,
+		NoCrosspost:     p.NoCrosspost == 1,
+		MetaDescription: p.MetaDescription,
+		OGImage:         p.OgImage,
+		CoverImage:      p.CoverImage,
+		CategoryID:      derefInt64(p.CategoryID),
+		SeriesID:        derefInt64(p.SeriesID),
+		SeriesOrder:     p.SeriesOrder,
+		Tags:            names,
+		URL:             base + "/post/" + p.Slug,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+	}
+}
+
+// apiPostBySlug returns the post with the slug in r's path, or writes a
+// 404 and returns false if there is none or it is in the trash. Unless
+// drafts is set, unpublished posts are not found either.
+func (s *Server) apiPostBySlug(w http.ResponseWriter, r *http.Request, drafts bool) (dbgen.Post, bool) {
+	p, err := dbgen.New(s.DB).GetPostBySlug(r.Context(), r.PathValue("slug"))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("get post", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to get post"})
+		return p, false
+	}
+	if err != nil || p.DeletedAt != nil || p.Published == 0 && !drafts {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "post not found"})
+		return p, false
+	}
+	return p, true
+}
+
+// encodeCursor returns the cursor for the page of posts after p.
+func encodeCursor(p dbgen.Post) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(p.CreatedAt.UTC().Format(time.DateTime) + "|" + strconv.FormatInt(p.ID, 10)))
+}
+
+// decodeCursor returns the created_at and ID of the post a cursor made by
+// encodeCursor follows.
+func decodeCursor(cursor string) (created string, id int64, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, err
+	}
+	created, idStr, ok := strings.Cut(string(b), "|")
+	if !ok {
+		return "", 0, errors.New("missing ID")
+	}
+	if _, err := time.Parse(time.DateTime, created); err != nil {
+		return "", 0, err
+	}
+	id, err = strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, errors.New("bad ID")
+	}
+	return created, id, nil
+}
+
+// postsListParams reads the filters and page of a request to list posts.
+// It returns a message for the client if they are not valid.
+func postsListParams(r *http.Request) (dbgen.ListAPIPostsParams, string) {
+	query := r.URL.Query()
+	params := dbgen.ListAPIPostsParams{Limit: apiPageSize}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return params, "limit must be a positive number"
+		}
+		params.Limit = int64(min(n, maxAPIPageSize))
+	}
+	if v := query.Get("cursor"); v != "" {
+		created, id, err := decodeCursor(v)
+		if err != nil {
+			return params, "invalid cursor"
+		}
+		params.AfterCreated, params.AfterID = created, id
+	}
+	published, draft := int64(1), int64(0)
+	switch query.Get("status") {
+	case "", "published":
+		params.Published = &published
+	case "draft":
+		params.Published = &draft
+	case "all":
+	default:
+		return params, "status must be published, draft or all"
+	}
+	if v := query.Get("tag"); v != "" {
+		params.Tag = tags.Slug(v)
+	}
+	if v := query.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return params, "since must be an RFC 3339 time, such as 2006-01-02T15:04:05Z"
+		}
+		params.Since = since.UTC().Format(time.DateTime)
+	}
+	return params, ""
+}
+
+// HandlePostsAPI answers GET /api/v1/posts with posts, newest first, a
+// page at a time. The response has a next_cursor to pass as ?cursor= for
+// the next page, unless it is the last. Posts can be filtered by ?status=
+// (published, the default, draft or all, which need an API token), ?tag=
+// and ?since=, an RFC 3339 time they have changed since, so that a client
+// can fetch only what is new.
+func (s *Server) HandlePostsAPI(w http.ResponseWriter, r *http.Request) {
+	params, msg := postsListParams(r)
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: msg})
+		return
+	}
+	if (params.Published == nil || *params.Published == 0) && !tokenAllows(apiToken(r), scopeRead) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "listing drafts needs an API token"})
+		return
+	}
+
+	// One more than a page is fetched to tell whether there is another.
+	limit := params.Limit
+	params.Limit++
+	rows, err := dbgen.New(s.DB).ListAPIPosts(r.Context(), params)
+	if err != nil {
+		slog.Error("list posts", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to list posts"})
+		return
+	}
+	resp := postsPage{Posts: []apiPost{}}
+	if int64(len(rows)) > limit {
+		rows = rows[:limit]
+		resp.NextCursor = encodeCursor(rows[len(rows)-1])
+	}
+	for _, p := range rows {
+		resp.Posts = append(resp.Posts, s.apiPostFrom(r, p))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandlePostAPI answers GET /api/v1/posts/{slug} with a published post,
+// or with any post for requests with an API token. Clients that have the
+// post as it is get a 304 instead.
+func (s *Server) HandlePostAPI(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.apiPostBySlug(w, r, tokenAllows(apiToken(r), scopeRead))
+	if !ok || notModified(w, r, "api-post", p.ID, p.UpdatedAt) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.apiPostFrom(r, p))
+}
+
+// readPostInput decodes the body of a request to create a post, writing a
+// 400 and returning false if it is not valid.
+func readPostInput(w http.ResponseWriter, r *http.Request) (apiPostCreate, bool) {
+	var in apiPostCreate
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body: " + err.Error()})
+		return in, false
+	}
+	return in, true
+}
+
+// writePostResult answers a request that saved the post with the given
+// ID, or failed to with err.
+func (s *Server) writePostResult(w http.ResponseWriter, r *http.Request, status int, id int64, err error) {
+	var perr postError
+	var conflict *editConflict
+	switch {
+	case errors.As(err, &perr):
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: perr.Error()})
+		return
+	case errors.As(err, &conflict):
+		writeJSON(w, http.StatusConflict, apiError{Error: "the post was changed at " + conflict.current.UpdatedAt.Format(time.RFC3339Nano)})
+		return
+	case err != nil:
+		slog.Error("save post", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to save post"})
+		return
+	}
+	p, err := dbgen.New(s.DB).GetPostByID(r.Context(), id)
+	if err != nil {
+		slog.Error("get post", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to get post"})
+		return
+	}
+	post := s.apiPostFrom(r, p)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", "/api/v1/posts/"+post.Slug)
+	}
+	writeJSON(w, status, post)
+}
+
+// HandleCreatePostAPI answers POST /api/v1/posts by adding the post in the
+// body. A slug is made from the title if none is given, and one that is
+// given is numbered if it is taken and number_slug is true.
+func (s *Server) HandleCreatePostAPI(w http.ResponseWriter, r *http.Request) {
+	in, ok := readPostInput(w, r)
+	if !ok {
+		return
+	}
+	var post PostView
+	in.apply(&post)
+	if in.NumberSlug != nil && *in.NumberSlug && post.Slug != "" {
+		free, err := slug.Unique(r.Context(), dbgen.New(s.DB), post.Slug)
+		if err != nil {
+			slog.Error("find free slug", "error", err)
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to save post"})
+			return
+		}
+		post.Slug = free
+	}
+	err := s.createPost(r.Context(), &post)
+	s.writePostResult(w, r, http.StatusCreated, post.ID, err)
+}
+
+// HandleUpdatePostAPI answers PATCH /api/v1/posts/{slug} by changing the
+// fields of the post given in the body. If the body has updated_at, the
+// post is only changed if it has not been since.
+func (s *Server) HandleUpdatePostAPI(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.apiPostBySlug(w, r, true)
+	if !ok {
+		return
+	}
+	var in struct {
+		apiPostInput
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	postTags, err := dbgen.New(s.DB).GetPostTags(r.Context(), p.ID)
+	if err != nil {
+		slog.Error("get post tags", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to get post"})
+		return
+	}
+	post := postView(p, postTags)
+	in.apply(&post)
+	post.UpdatedAt = in.UpdatedAt
+	if post.Title == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "Title is required"})
+		return
+	}
+	err = s.updatePost(r.Context(), &post, "")
+	s.writePostResult(w, r, http.StatusOK, p.ID, err)
+}
+
+// HandleDeletePostAPI answers DELETE /api/v1/posts/{slug} by moving the
+// post to the trash, from which it can be restored in the admin.
+func (s *Server) HandleDeletePostAPI(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.apiPostBySlug(w, r, true)
+	if !ok {
+		return
+	}
+	if err := dbgen.New(s.DB).TrashPost(r.Context(), p.ID); err != nil {
+		slog.Error("trash post", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to delete post"})
+		return
+	}
+	s.renders.remove(p.ID)
+	s.postChanged(r.Context(), &p, p.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePublishPostAPI answers POST /api/v1/posts/{slug}/publish and
+// /unpublish by publishing the post now, or taking it back to a draft.
+// Either cancels any scheduled publication.
+func (s *Server) HandlePublishPostAPI(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.apiPostBySlug(w, r, true)
+	if !ok {
+		return
+	}
+	publish := strings.HasSuffix(r.URL.Path, "/publish")
+	err := dbgen.New(s.DB).SetPostPublished(r.Context(), dbgen.SetPostPublishedParams{Published: boolToInt(publish), ID: p.ID})
+	if err == nil {
+		s.renders.remove(p.ID)
+		s.postChanged(r.Context(), &p, p.ID)
+		if publish {
+			s.requestAnnounce()
+		}
+	}
+	s.writePostResult(w, r, http.StatusOK, p.ID, err)
+}
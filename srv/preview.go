@@ -0,0 +1,130 @@
+package srv
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// PreviewLinkView is a preview link as listed in the editor.
+type PreviewLinkView struct {
+	ID        int64
+	URL       string
+	ExpiresAt time.Time
+	Expired   bool
+}
+
+// HandlePreview shows the post a preview link was made for, whether or not
+// it is published, until the link expires or is revoked. Previews are kept
+// out of search engines and view counts.
+func (s *Server) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	link, err := q.GetPreviewLink(r.Context(), r.PathValue("token"))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("get preview link", "error", err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if link.ExpiresAt.Before(time.Now()) {
+		http.Error(w, "This preview link has expired", http.StatusGone)
+		return
+	}
+	p, err := q.GetPostByID(r.Context(), link.PostID)
+	if err != nil || p.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("X-Robots-Tag", "noindex")
+	data := s.postPage(r, p)
+	data["Preview"] = true
+	s.render(w, "base.html", data)
+}
+
+// previewLinkDays returns how long new preview links last, in days.
+func (s *Server) previewLinkDays(r *http.Request) int {
+	days, err := strconv.Atoi(s.setting(r.Context(), settingPreviewLinkDays))
+	if err != nil || days < 1 {
+		def, _ := lookupSetting(settingPreviewLinkDays)
+		days, _ = strconv.Atoi(def.Default)
+	}
+	return days
+}
+
+// previewLinks returns the preview links made for post id, newest first.
+func (s *Server) previewLinks(r *http.Request, id int64) []PreviewLinkView {
+	links, err := dbgen.New(s.DB).ListPreviewLinks(r.Context(), id)
+	if err != nil {
+		slog.Error("list preview links", "error", err)
+	}
+	now := time.Now()
+	views := make([]PreviewLinkView, 0, len(links))
+	for _, link := range links {
+		views = append(views, PreviewLinkView{
+			ID:        link.ID,
+			URL:       s.baseURL(r) + "/preview/" + link.Token,
+			ExpiresAt: link.ExpiresAt,
+			Expired:   link.ExpiresAt.Before(now),
+		})
+	}
+	return views
+}
+
+// HandleAdminPreviewCreate makes a new preview link for a post, lasting
+// the number of days in the preview_link_days setting.
+func (s *Server) HandleAdminPreviewCreate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := dbgen.New(s.DB)
+	post, err := q.GetPostByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && post.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err == nil {
+		_, err = q.CreatePreviewLink(r.Context(), dbgen.CreatePreviewLinkParams{
+			PostID:    id,
+			Token:     rand.Text(),
+			ExpiresAt: time.Now().AddDate(0, 0, s.previewLinkDays(r)).UTC(),
+		})
+	}
+	if err != nil {
+		slog.Error("create preview link", "error", err)
+		http.Error(w, "Failed to create preview link", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/edit/"+strconv.FormatInt(id, 10)+"#preview-links", http.StatusSeeOther)
+}
+
+// HandleAdminPreviewRevoke deletes a preview link, so that it stops
+// working straight away.
+func (s *Server) HandleAdminPreviewRevoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	linkID, err := strconv.ParseInt(r.PathValue("link"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = dbgen.New(s.DB).DeletePreviewLink(r.Context(), dbgen.DeletePreviewLinkParams{ID: linkID, PostID: id})
+	if err != nil {
+		slog.Error("delete preview link", "error", err)
+		http.Error(w, "Failed to revoke preview link", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/edit/"+strconv.FormatInt(id, 10)+"#preview-links", http.StatusSeeOther)
+}
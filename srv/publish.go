@@ -0,0 +1,158 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// announceInterval is how often the server looks for newly published posts.
+// Posts published from the admin are announced straight away; the interval
+// picks up posts written directly to the database, such as daily-wiki's.
+const announceInterval = time.Minute
+
+// publishClient makes the outgoing requests of publish hooks.
+var publishClient = &http.Client{Timeout: 10 * time.Second}
+
+// indexNowEndpoint shares submitted URLs with every search engine taking
+// part in IndexNow.
+var indexNowEndpoint = "https://api.indexnow.org/indexnow"
+
+// A publishHook runs once for each post, the first time it is seen
+// published. base is the site URL setting.
+type publishHook struct {
+	name string
+	run  func(ctx context.Context, base string, p dbgen.Post) error
+}
+
+func (s *Server) defaultPublishHooks() []publishHook {
+	return []publishHook{
+		{name: "websub", run: s.notifyWebSub},
+		{name: "indexnow", run: s.pingIndexNow},
+		{name: "activitypub", run: s.federatePost},
+		{name: "mastodon", run: s.tootPost},
+		{name: "bluesky", run: s.queueBlueskyPost},
+		{name: "chat", run: s.notifyChat},
+		{name: "newsletter", run: s.emailSubscribers},
+	}
+}
+
+// announceLoop runs announcePending every announceInterval and whenever
+// the admin publishes a post.
+func (s *Server) announceLoop(ctx context.Context) {
+	ticker := time.NewTicker(announceInterval)
+	defer ticker.Stop()
+	for {
+		s.announcePending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.announce:
+		}
+	}
+}
+
+// requestAnnounce wakes announceLoop without waiting for it.
+func (s *Server) requestAnnounce() {
+	select {
+	case s.announce <- struct{}{}:
+	default:
+	}
+}
+
+// announcePending runs the publish hooks for published posts that have not
+// been announced yet. Hook failures are logged but not retried. Without a
+// site URL there is no public address to announce, so posts are marked
+// announced without running the hooks.
+func (s *Server) announcePending(ctx context.Context) {
+	q := dbgen.New(s.DB)
+	posts, err := q.GetUnannouncedPosts(ctx)
+	if err != nil {
+		slog.Error("get unannounced posts", "error", err)
+		return
+	}
+	if len(posts) == 0 {
+		return
+	}
+
+	base := s.setting(ctx, settingSiteURL)
+	for _, p := range posts {
+		if base != "" {
+			for _, hook := range s.publishHooks {
+				if err := hook.run(ctx, base, p); err != nil {
+					slog.Error("run publish hook", "hook", hook.name, "slug", p.Slug, "error", err)
+				}
+			}
+		}
+		if err := q.MarkPostAnnounced(ctx, p.ID); err != nil {
+			slog.Error("mark post announced", "slug", p.Slug, "error", err)
+		}
+	}
+}
+
+// notifyWebSub tells the configured WebSub hub that the Atom feed changed.
+func (s *Server) notifyWebSub(ctx context.Context, base string, p dbgen.Post) error {
+	hub := s.setting(ctx, settingWebSubHub)
+	if hub == "" {
+		return nil
+	}
+	form := url.Values{
+		"hub.mode": {"publish"},
+		"hub.url":  {base + "/atom.xml"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doHookRequest(req)
+}
+
+// pingIndexNow submits the post's URL to IndexNow so search engines crawl
+// it soon. The key is verified against the file served by HandleIndexNowKey.
+func (s *Server) pingIndexNow(ctx context.Context, base string, p dbgen.Post) error {
+	key := s.setting(ctx, settingIndexNowKey)
+	if key == "" {
+		return nil
+	}
+	params := url.Values{
+		"url":         {base + "/post/" + p.Slug},
+		"key":         {key},
+		"keyLocation": {base + "/indexnow.txt"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexNowEndpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	return doHookRequest(req)
+}
+
+func (s *Server) HandleIndexNowKey(w http.ResponseWriter, r *http.Request) {
+	key := s.setting(r.Context(), settingIndexNowKey)
+	if key == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(key))
+}
+
+// doHookRequest sends req and fails unless it is answered with a 2xx status.
+func doHookRequest(req *http.Request) error {
+	resp, err := publishClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: unexpected status %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,441 @@
+// Package qr encodes short texts, such as authenticator app setup links,
+// as QR codes.
+//
+// Only what those need is supported: text is encoded as bytes at error
+// correction level M, in versions 1 to 10 (up to 213 bytes). The mask is
+// chosen by the penalty rules of ISO/IEC 18004, as scanners expect.
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTooLong is returned for text that does not fit in a version 10 code.
+var ErrTooLong = errors.New("qr: text too long")
+
+// Code is an encoded QR code: a square of dark and light modules.
+type Code struct {
+	Size    int // modules on each side, not counting the quiet zone
+	modules []bool
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y*c.Size+x]
+}
+
+// blocks describes the error correction blocks of a version at level M.
+type blocks struct {
+	ecPerBlock int
+	dataSizes  []int // data codewords in each block
+}
+
+var versions = [...]blocks{
+	1:  {10, []int{16}},
+	2:  {16, []int{28}},
+	3:  {26, []int{44}},
+	4:  {18, []int{32, 32}},
+	5:  {24, []int{43, 43}},
+	6:  {16, []int{27, 27, 27, 27}},
+	7:  {18, []int{31, 31, 31, 31}},
+	8:  {22, []int{38, 38, 39, 39}},
+	9:  {22, []int{36, 36, 36, 37, 37}},
+	10: {26, []int{43, 43, 43, 43, 44}},
+}
+
+// alignment lists the centres of the alignment patterns of each version.
+var alignment = [...][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+func (b blocks) dataCodewords() int {
+	n := 0
+	for _, size := range b.dataSizes {
+		n += size
+	}
+	return n
+}
+
+// Encode returns text as a QR code of the smallest version it fits in.
+func Encode(text string) (*Code, error) {
+	for version := 1; version < len(versions); version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		capacity := versions[version].dataCodewords() * 8
+		if 4+countBits+8*len(text) > capacity {
+			continue
+		}
+		var w bitWriter
+		w.write(0b0100, 4) // byte mode
+		w.write(len(text), countBits)
+		for i := 0; i < len(text); i++ {
+			w.write(int(text[i]), 8)
+		}
+		w.write(0, min(4, capacity-w.n))
+		w.write(0, (8-w.n%8)%8)
+		for pad := 0xec; w.n < capacity; pad ^= 0xec ^ 0x11 {
+			w.write(pad, 8)
+		}
+		return build(version, interleave(versions[version], w.bytes)), nil
+	}
+	return nil, ErrTooLong
+}
+
+type bitWriter struct {
+	bytes []byte
+	n     int
+}
+
+func (w *bitWriter) write(v, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.bytes = append(w.bytes, 0)
+		}
+		w.bytes[len(w.bytes)-1] |= byte(v>>i&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+// interleave splits data into the blocks of b, adds error correction to
+// each and interleaves them in the order they are placed in the code.
+func interleave(b blocks, data []byte) []byte {
+	gen := rsGenerator(b.ecPerBlock)
+	var dataBlocks, ecBlocks [][]byte
+	for _, size := range b.dataSizes {
+		dataBlocks = append(dataBlocks, data[:size])
+		ecBlocks = append(ecBlocks, rsRemainder(data[:size], gen))
+		data = data[size:]
+	}
+	var out []byte
+	for i := 0; i < b.dataSizes[len(b.dataSizes)-1]; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo the QR code polynomial, 0x11d.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsGenerator returns the Reed-Solomon generator polynomial of the given
+// degree, highest power first and without its leading 1.
+func rsGenerator(degree int) []byte {
+	gen := make([]byte, degree)
+	gen[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		for j := range gen {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < len(gen) {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return gen
+}
+
+// rsRemainder returns the error correction codewords for data.
+func rsRemainder(data, gen []byte) []byte {
+	rem := make([]byte, len(gen))
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i := range rem {
+			rem[i] ^= gfMul(gen[i], factor)
+		}
+	}
+	return rem
+}
+
+// grid is a code being built, with a record of which modules are part of
+// the fixed patterns rather than data.
+type grid struct {
+	size     int
+	dark     []bool
+	function []bool
+}
+
+func (g *grid) set(x, y int, dark bool) {
+	g.dark[y*g.size+x] = dark
+	g.function[y*g.size+x] = true
+}
+
+// newGrid returns a grid of the given version with its finder, timing,
+// alignment and version patterns drawn and its format areas reserved.
+func newGrid(version int) *grid {
+	size := 17 + 4*version
+	g := &grid{size: size, dark: make([]bool, size*size), function: make([]bool, size*size)}
+
+	for i := range size {
+		g.set(6, i, i%2 == 0)
+		g.set(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if 0 <= x && x < size && 0 <= y && y < size {
+					d := max(abs(dx), abs(dy))
+					g.set(x, y, d != 2 && d != 4)
+				}
+			}
+		}
+	}
+	pos := alignment[version]
+	for i, cx := range pos {
+		for j, cy := range pos {
+			// Skip the corners taken by finder patterns.
+			if i == 0 && j == 0 || i == 0 && j == len(pos)-1 || i == len(pos)-1 && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					g.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// Reserve the format areas; they are filled in once the mask is chosen.
+	g.drawFormat(0)
+	if version >= 7 {
+		bits := versionBits(version)
+		for i := range 18 {
+			a, b := size-11+i%3, i/3
+			g.set(a, b, bits>>i&1 == 1)
+			g.set(b, a, bits>>i&1 == 1)
+		}
+	}
+	return g
+}
+
+// dataOrder returns the positions of the data modules of g, as y*size+x,
+// in the order codeword bits are placed in them: upwards and downwards in
+// turn in columns two wide, from the right, skipping the timing column.
+func (g *grid) dataOrder() []int {
+	var order []int
+	size := g.size
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if !g.function[y*size+x] {
+					order = append(order, y*size+x)
+				}
+			}
+		}
+	}
+	return order
+}
+
+func build(version int, codewords []byte) *Code {
+	g := newGrid(version)
+	// Modules left over after the last codeword stay light.
+	for i, at := range g.dataOrder()[:len(codewords)*8] {
+		g.dark[at] = codewords[i>>3]>>(7-i&7)&1 == 1
+	}
+
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		g.applyMask(mask)
+		g.drawFormat(mask)
+		if p := g.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		g.applyMask(mask)
+	}
+	g.applyMask(best)
+	g.drawFormat(best)
+	return &Code{Size: g.size, modules: g.dark}
+}
+
+// formatBits returns the 15 bits of format information for level M and
+// the given mask, with their error correction.
+func formatBits(mask int) int {
+	data := 0b00<<3 | mask // 00 is level M
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionBits returns the 18 bits of version information for a version
+// of 7 or more, with their error correction.
+func versionBits(version int) int {
+	rem := version
+	for range 12 {
+		rem = rem<<1 ^ (rem>>11)*0x1f25
+	}
+	return version<<12 | rem
+}
+
+func (g *grid) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := range 6 {
+		g.set(8, i, bit(i))
+	}
+	g.set(8, 7, bit(6))
+	g.set(8, 8, bit(7))
+	g.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		g.set(14-i, 8, bit(i))
+	}
+	for i := range 8 {
+		g.set(g.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		g.set(8, g.size-15+i, bit(i))
+	}
+	g.set(8, g.size-8, true)
+}
+
+// applyMask inverts the data modules selected by mask. Applying the same
+// mask again undoes it.
+func (g *grid) applyMask(mask int) {
+	for y := range g.size {
+		for x := range g.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !g.function[y*g.size+x] {
+				g.dark[y*g.size+x] = !g.dark[y*g.size+x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan; lower is better.
+func (g *grid) penalty() int {
+	at := func(x, y int) bool { return g.dark[y*g.size+x] }
+	p := 0
+	// Runs of five or more modules of one colour, and the finder-like
+	// pattern dark-light-dark-dark-dark-light-dark with four light modules
+	// on one side, along rows and then columns.
+	for _, line := range []func(i, j int) bool{
+		func(i, j int) bool { return at(j, i) },
+		func(i, j int) bool { return at(i, j) },
+	} {
+		for i := range g.size {
+			run := 1
+			var sb strings.Builder
+			for j := range g.size {
+				if line(i, j) {
+					sb.WriteByte('1')
+				} else {
+					sb.WriteByte('0')
+				}
+				if j == 0 {
+					continue
+				}
+				if line(i, j) == line(i, j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				p += run - 2
+			}
+			s := sb.String()
+			p += 40 * (strings.Count(s, "10111010000") + strings.Count(s, "00001011101"))
+		}
+	}
+	// Two by two blocks of one colour.
+	for y := 0; y+1 < g.size; y++ {
+		for x := 0; x+1 < g.size; x++ {
+			if c := at(x, y); at(x+1, y) == c && at(x, y+1) == c && at(x+1, y+1) == c {
+				p += 3
+			}
+		}
+	}
+	// Balance of dark and light: 10 for each 5% away from half.
+	dark := 0
+	for _, d := range g.dark {
+		if d {
+			dark++
+		}
+	}
+	total := len(g.dark)
+	p += ((abs(dark*20-total*10)+total-1)/total - 1) * 10
+	return p
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// SVG returns the code as an SVG image with the quiet zone of four
+// modules around it that scanners need. Each module is one unit; the image
+// scales to the size it is shown at.
+func (c *Code) SVG() string {
+	var path strings.Builder
+	for y := range c.Size {
+		for x := range c.Size {
+			if c.Dark(x, y) {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+4, y+4)
+			}
+		}
+	}
+	n := c.Size + 8
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/></svg>`, n, n, n, n, path.String())
+}
@@ -0,0 +1,165 @@
+package qr
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestRSRemainder checks the error correction of the HELLO WORLD example
+// code at level M from Thonky's QR code tutorial.
+func TestRSRemainder(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if ec := rsRemainder(data, rsGenerator(10)); !bytes.Equal(ec, expected) {
+		t.Errorf("rsRemainder = %v, expected %v", ec, expected)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	expected := []string{
+		"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+	}
+	for mask, want := range expected {
+		if got := strconv.FormatInt(int64(formatBits(mask)), 2); got != want {
+			t.Errorf("formatBits(%d) = %s, expected %s", mask, got, want)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	tests := []struct {
+		version  int
+		expected string
+	}{
+		{7, "111110010010100"},
+		{10, "1010010011010011"},
+	}
+	for _, tt := range tests {
+		if got := strconv.FormatInt(int64(versionBits(tt.version)), 2); got != tt.expected {
+			t.Errorf("versionBits(%d) = %s, expected %s", tt.version, got, tt.expected)
+		}
+	}
+}
+
+// decode reads the text back out of c, as a scanner would if it could
+// ignore the error correction.
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+	version := (c.Size - 17) / 4
+	g := newGrid(version)
+
+	// Format information, from the copy beside the top left finder.
+	var format int
+	for i, pos := range [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}} {
+		if c.Dark(pos[0], pos[1]) {
+			format |= 1 << i
+		}
+	}
+	mask := -1
+	for m := range 8 {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format information %015b is not level M", format)
+	}
+
+	g.dark = append([]bool{}, c.modules...)
+	g.applyMask(mask)
+	sizes := versions[version].dataSizes
+	codewords := len(sizes) * versions[version].ecPerBlock
+	for _, size := range sizes {
+		codewords += size
+	}
+	var w bitWriter
+	for _, at := range g.dataOrder()[:codewords*8] {
+		b := 0
+		if g.dark[at] {
+			b = 1
+		}
+		w.write(b, 1)
+	}
+
+	// Undo the interleaving of the data codewords.
+	blocks := make([][]byte, len(sizes))
+	next := 0
+	for i := 0; i < sizes[len(sizes)-1]; i++ {
+		for b, size := range sizes {
+			if i < size {
+				blocks[b] = append(blocks[b], w.bytes[next])
+				next++
+			}
+		}
+	}
+	data := bytes.Join(blocks, nil)
+
+	bit := 0
+	read := func(n int) int {
+		v := 0
+		for range n {
+			v = v<<1 | int(data[bit/8]>>(7-bit%8)&1)
+			bit++
+		}
+		return v
+	}
+	if m := read(4); m != 0b0100 {
+		t.Fatalf("mode %04b is not bytes", m)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	text := make([]byte, read(countBits))
+	for i := range text {
+		text[i] = byte(read(8))
+	}
+	return string(text)
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		text    string
+		version int
+	}{
+		{"hello", 1},
+		{"otpauth://totp/Blog:jo@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Blog", 5},
+		{"otpauth://totp/Citizen%20of%20the%20World:someone.with.a.long.name@example.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=Citizen%20of%20the%20World", 8},
+		{strings.Repeat("x", 213), 10},
+	}
+	for _, tt := range tests {
+		c, err := Encode(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != 17+4*tt.version {
+			t.Errorf("Encode(%q) is %d modules wide, expected version %d", tt.text, c.Size, tt.version)
+			continue
+		}
+		if got := decode(t, c); got != tt.text {
+			t.Errorf("Encode(%q) decodes as %q", tt.text, got)
+		}
+	}
+	if _, err := Encode(strings.Repeat("x", 214)); err != ErrTooLong {
+		t.Errorf("expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	c, err := Encode("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := c.SVG()
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29"`) {
+		t.Errorf("unexpected SVG: %s", svg)
+	}
+	// The top left corner of the top left finder pattern, inside the
+	// quiet zone.
+	if !strings.Contains(svg, `d="M4 4h1v1h-1z`) {
+		t.Errorf("expected the finder pattern drawn, got %s", svg)
+	}
+}
@@ -0,0 +1,109 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// redirectPost answers a request for a post slug that no longer exists
+// with a permanent redirect, if one is recorded for it. It reports whether
+// it did.
+func (s *Server) redirectPost(w http.ResponseWriter, r *http.Request, slug string) bool {
+	target, err := dbgen.New(s.DB).GetRedirect(r.Context(), slug)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("get redirect", "slug", slug, "error", err)
+		}
+		return false
+	}
+	http.Redirect(w, r, "/post/"+target, http.StatusMovedPermanently)
+	return true
+}
+
+// recordSlugChange makes oldSlug redirect to newSlug. Redirects that led
+// to oldSlug are pointed at newSlug so they never chain, and any redirect
+// away from newSlug is dropped now that a post lives there.
+func recordSlugChange(ctx context.Context, q *dbgen.Queries, oldSlug, newSlug string) error {
+	if err := q.RetargetRedirects(ctx, dbgen.RetargetRedirectsParams{OldSlug: oldSlug, NewSlug: newSlug}); err != nil {
+		return err
+	}
+	if err := q.UpsertRedirect(ctx, dbgen.UpsertRedirectParams{OldSlug: oldSlug, NewSlug: newSlug}); err != nil {
+		return err
+	}
+	return q.DeleteRedirect(ctx, newSlug)
+}
+
+// validSlug reports whether slug is lowercase letters, digits and hyphens.
+func validSlug(slug string) bool {
+	if slug == "" {
+		return false
+	}
+	for _, c := range slug {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) HandleAdminRedirects(w http.ResponseWriter, r *http.Request) {
+	s.renderRedirects(w, r, "")
+}
+
+func (s *Server) renderRedirects(w http.ResponseWriter, r *http.Request, errMsg string) {
+	redirects, err := dbgen.New(s.DB).GetRedirects(r.Context())
+	if err != nil {
+		slog.Error("get redirects", "error", err)
+	}
+	s.render(w, "admin_redirects.html", map[string]any{
+		"Redirects": redirects,
+		"Error":     errMsg,
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+func (s *Server) HandleAdminRedirectCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	oldSlug := strings.TrimSpace(r.FormValue("old_slug"))
+	newSlug := strings.TrimSpace(r.FormValue("new_slug"))
+	if !validSlug(oldSlug) || !validSlug(newSlug) {
+		s.renderRedirects(w, r, "Slugs may only contain lowercase letters, digits and hyphens")
+		return
+	}
+	if oldSlug == newSlug {
+		s.renderRedirects(w, r, "A slug cannot redirect to itself")
+		return
+	}
+
+	q := dbgen.New(s.DB)
+	if _, err := q.GetPostBySlug(r.Context(), oldSlug); err == nil {
+		s.renderRedirects(w, r, "A post already uses the slug "+oldSlug)
+		return
+	}
+	err := q.UpsertRedirect(r.Context(), dbgen.UpsertRedirectParams{OldSlug: oldSlug, NewSlug: newSlug})
+	if err != nil {
+		slog.Error("create redirect", "error", err)
+		s.renderRedirects(w, r, "Failed to create redirect: "+err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/admin/redirects", http.StatusFound)
+}
+
+func (s *Server) HandleAdminRedirectDelete(w http.ResponseWriter, r *http.Request) {
+	if err := dbgen.New(s.DB).DeleteRedirect(r.Context(), r.PathValue("slug")); err != nil {
+		slog.Error("delete redirect", "error", err)
+	}
+	http.Redirect(w, r, "/admin/redirects", http.StatusFound)
+}
@@ -0,0 +1,145 @@
+package srv
+
+import (
+	"container/list"
+	"context"
+	"html/template"
+	"log/slog"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/markdown"
+)
+
+// renderCacheSize is the number of rendered posts kept in memory.
+const renderCacheSize = 256
+
+// renderedPost is the output of rendering a post's content.
+type renderedPost struct {
+	updatedAt time.Time
+	html      template.HTML
+	outHTML   template.HTML // html with links to other sites through /out, when clicks on them are counted
+	toc       []markdown.TOCEntry
+	hasMath   bool
+	summary   string // plain-text description for page metadata
+	image     string // first image in the content, as written
+	words     int
+}
+
+// renderCache is an LRU cache of rendered posts keyed by post ID. An entry
+// is only used while the post's updated_at matches the one it was
+// rendered from.
+type renderCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[int64]*list.Element
+	order   *list.List // front is most recently used
+}
+
+type renderCacheEntry struct {
+	id   int64
+	post renderedPost
+}
+
+func newRenderCache(size int) *renderCache {
+	return &renderCache{size: size, entries: make(map[int64]*list.Element), order: list.New()}
+}
+
+func (c *renderCache) get(id int64, updatedAt time.Time) (renderedPost, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok {
+		return renderedPost{}, false
+	}
+	entry := e.Value.(*renderCacheEntry)
+	if !entry.post.updatedAt.Equal(updatedAt) {
+		return renderedPost{}, false
+	}
+	c.order.MoveToFront(e)
+	return entry.post, true
+}
+
+func (c *renderCache) put(id int64, post renderedPost) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		e.Value.(*renderCacheEntry).post = post
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[id] = c.order.PushFront(&renderCacheEntry{id: id, post: post})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*renderCacheEntry).id)
+	}
+}
+
+// remove drops the entry for id. Saves call it in case updated_at, which
+// has one-second resolution, did not change.
+func (c *renderCache) remove(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		c.order.Remove(e)
+		delete(c.entries, id)
+	}
+}
+
+// clear drops every entry, for changes such as settings that affect how
+// all posts render.
+func (c *renderCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[int64]*list.Element)
+	c.order.Init()
+}
+
+// readingSpeed is the reading speed, in words per minute, that reading
+// times are estimated with.
+const readingSpeed = 200
+
+// readingTime returns the estimated minutes it takes to read words words,
+// rounded up and at least one.
+func readingTime(words int) int {
+	return max(1, (words+readingSpeed-1)/readingSpeed)
+}
+
+// markdownOptions returns the options p is rendered with.
+func (s *Server) markdownOptions(ctx context.Context, p dbgen.Post) markdown.Options {
+	return markdown.Options{
+		Typographer: s.settingBool(ctx, settingTypographer),
+		HTML:        p.AllowHtml == 1,
+		Embed:       s.cachedEmbed(ctx),
+	}
+}
+
+// renderPost returns the rendered content of p, from the cache if it has
+// not been updated since it was last rendered.
+func (s *Server) renderPost(ctx context.Context, p dbgen.Post) renderedPost {
+	if rp, ok := s.renders.get(p.ID, p.UpdatedAt); ok {
+		return rp
+	}
+	doc := s.markdownOptions(ctx, p).Parse(p.Content)
+	rp := renderedPost{
+		updatedAt: p.UpdatedAt,
+		html:      markdown.RenderHTML(doc),
+		toc:       markdown.TOC(doc),
+		hasMath:   markdown.HasMath(doc),
+		summary:   markdown.Summary(doc, 200),
+		image:     markdown.FirstImage(doc),
+		words:     markdown.WordCount(doc),
+	}
+	if s.settingBool(ctx, settingTrackLinks) {
+		if key, err := s.storedKey(ctx, settingOutKey); err != nil {
+			slog.Error("load outbound link key", "error", err)
+		} else {
+			markdown.RewriteLinks(doc, outRewriter(key, p.ID, s.siteHost(ctx)))
+			rp.outHTML = markdown.RenderHTML(doc)
+		}
+	}
+	s.renders.put(p.ID, rp)
+	return rp
+}
@@ -0,0 +1,126 @@
+// Package resize scales images down for thumbnails and responsive pages.
+//
+// Pixels are averaged over the area of the source that each output pixel
+// covers, which is slower than bilinear sampling but does not alias when
+// a large photo is shrunk to a small thumbnail. Images are never enlarged.
+package resize
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Fit scales src down to fit within width by height, keeping its aspect
+// ratio. A width or height of 0 leaves that side unbounded. src is
+// returned unchanged if it already fits.
+func Fit(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return src
+	}
+	// Compare w/width with h/height without dividing.
+	switch {
+	case width > 0 && (height <= 0 || w*height >= h*width):
+		if width >= w {
+			return src
+		}
+		w, h = width, max(1, (h*width+w/2)/w)
+	case height > 0:
+		if height >= h {
+			return src
+		}
+		w, h = max(1, (w*height+h/2)/h), height
+	default:
+		return src
+	}
+	return scale(toRGBA(src, b), w, h)
+}
+
+// Fill scales and crops src to exactly width by height, cutting equally
+// from both edges of the side that is too long. If src is smaller than
+// that, it is only cropped to the same aspect ratio.
+func Fill(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 || w == 0 || h == 0 {
+		return src
+	}
+	crop := b
+	if w*height > h*width {
+		cw := max(1, h*width/height)
+		crop.Min.X += (w - cw) / 2
+		crop.Max.X = crop.Min.X + cw
+	} else {
+		ch := max(1, w*height/width)
+		crop.Min.Y += (h - ch) / 2
+		crop.Max.Y = crop.Min.Y + ch
+	}
+	rgba := toRGBA(src, crop)
+	if width >= crop.Dx() {
+		return rgba
+	}
+	return scale(rgba, width, height)
+}
+
+// toRGBA copies the part r of src into a new image, so that scale can work
+// on bytes rather than calling At for every pixel.
+func toRGBA(src image.Image, r image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, r.Min, draw.Src)
+	return dst
+}
+
+// scale shrinks src to w by h, first horizontally and then vertically.
+// RGBA pixels are premultiplied by alpha, so averaging them directly is
+// correct for transparent images too.
+func scale(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	tmp := image.NewRGBA(image.Rect(0, 0, w, sh))
+	for x := range w {
+		x0, x1 := span(x, w, sw)
+		for y := range sh {
+			row := src.Pix[y*src.Stride:]
+			var sum [4]uint32
+			for sx := x0; sx < x1; sx++ {
+				for c := range 4 {
+					sum[c] += uint32(row[sx*4+c])
+				}
+			}
+			setAverage(tmp.Pix[y*tmp.Stride+x*4:], sum, uint32(x1-x0))
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0, y1 := span(y, h, sh)
+		for x := range w {
+			var sum [4]uint32
+			for sy := y0; sy < y1; sy++ {
+				for c := range 4 {
+					sum[c] += uint32(tmp.Pix[sy*tmp.Stride+x*4+c])
+				}
+			}
+			setAverage(dst.Pix[y*dst.Stride+x*4:], sum, uint32(y1-y0))
+		}
+	}
+	return dst
+}
+
+// span returns the range of the n source pixels that output pixel i of m
+// covers.
+func span(i, m, n int) (from, to int) {
+	from, to = i*n/m, (i+1)*n/m
+	if to == from {
+		to++
+	}
+	return from, to
+}
+
+// setAverage writes the rounded averages of the channel sums of count
+// pixels to the start of pix.
+func setAverage(pix []uint8, sum [4]uint32, count uint32) {
+	for c := range 4 {
+		pix[c] = uint8((sum[c] + count/2) / count)
+	}
+}
@@ -0,0 +1,88 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name          string
+		w, h          int
+		width, height int
+		expectW       int
+		expectH       int
+	}{
+		{"width only", 1000, 500, 400, 0, 400, 200},
+		{"height only", 1000, 500, 0, 100, 200, 100},
+		{"width binds", 1000, 500, 400, 400, 400, 200},
+		{"height binds", 500, 1000, 400, 400, 200, 400},
+		{"rounds", 1000, 333, 100, 0, 100, 33},
+		{"never enlarges", 300, 200, 800, 0, 300, 200},
+		{"already fits", 300, 200, 300, 200, 300, 200},
+		{"thin strip keeps a pixel", 1000, 1, 10, 0, 10, 1},
+	}
+	for _, tt := range tests {
+		src := image.NewRGBA(image.Rect(0, 0, tt.w, tt.h))
+		b := Fit(src, tt.width, tt.height).Bounds()
+		if b.Dx() != tt.expectW || b.Dy() != tt.expectH {
+			t.Errorf("%s: Fit(%dx%d, %d, %d) is %dx%d, expected %dx%d",
+				tt.name, tt.w, tt.h, tt.width, tt.height, b.Dx(), b.Dy(), tt.expectW, tt.expectH)
+		}
+	}
+}
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		name          string
+		w, h          int
+		width, height int
+		expectW       int
+		expectH       int
+	}{
+		{"wide source", 1000, 300, 400, 200, 400, 200},
+		{"tall source", 600, 1000, 400, 200, 400, 200},
+		{"square", 800, 800, 100, 100, 100, 100},
+		{"small source is only cropped", 300, 300, 800, 400, 300, 150},
+	}
+	for _, tt := range tests {
+		src := image.NewRGBA(image.Rect(0, 0, tt.w, tt.h))
+		b := Fill(src, tt.width, tt.height).Bounds()
+		if b.Dx() != tt.expectW || b.Dy() != tt.expectH {
+			t.Errorf("%s: Fill(%dx%d, %d, %d) is %dx%d, expected %dx%d",
+				tt.name, tt.w, tt.h, tt.width, tt.height, b.Dx(), b.Dy(), tt.expectW, tt.expectH)
+		}
+	}
+}
+
+func TestFillCropsCentre(t *testing.T) {
+	// A 3x1 image, red, green and blue, filled into 1x1 keeps the green.
+	src := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	src.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	src.Set(1, 0, color.RGBA{0, 255, 0, 255})
+	src.Set(2, 0, color.RGBA{0, 0, 255, 255})
+	if c := Fill(src, 1, 1).At(0, 0); c != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("centre pixel is %v, expected green", c)
+	}
+}
+
+func TestFitAverages(t *testing.T) {
+	// A black and white checkerboard shrinks to grey.
+	src := image.NewGray(image.Rect(10, 10, 14, 14))
+	for y := 10; y < 14; y++ {
+		for x := 10; x < 14; x++ {
+			if (x+y)%2 == 0 {
+				src.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+	dst := Fit(src, 2, 0)
+	for y := range 2 {
+		for x := range 2 {
+			if r, _, _, _ := dst.At(x, y).RGBA(); r>>8 != 128 {
+				t.Errorf("pixel %d,%d has red %d, expected 128", x, y, r>>8)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package srv
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/diff"
+)
+
+// revisionIDs returns the post and revision IDs in the request path. The
+// revision ID is 0 if the path has none.
+func revisionIDs(r *http.Request) (postID, revID int64, ok bool) {
+	postID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if v := r.PathValue("rev"); v != "" {
+		if revID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return postID, revID, true
+}
+
+func (s *Server) HandleAdminRevisions(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := revisionIDs(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	q := dbgen.New(s.DB)
+	post, err := q.GetPostByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	revisions, err := q.GetPostRevisions(r.Context(), id)
+	if err != nil {
+		slog.Error("get post revisions", "error", err)
+	}
+
+	s.render(w, "admin_revisions.html", map[string]any{
+		"Post":      post,
+		"Revisions": revisions,
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// HandleAdminRevision shows how a revision differs from the current post.
+func (s *Server) HandleAdminRevision(w http.ResponseWriter, r *http.Request) {
+	id, revID, ok := revisionIDs(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	q := dbgen.New(s.DB)
+	post, err := q.GetPostByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	rev, err := q.GetPostRevision(r.Context(), dbgen.GetPostRevisionParams{ID: revID, PostID: id})
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	lines := diff.Lines(rev.Content, post.Content)
+	s.render(w, "admin_revision.html", map[string]any{
+		"Post":      post,
+		"Revision":  rev,
+		"Diff":      lines,
+		"Changed":   diff.Changed(lines) || rev.Title != post.Title,
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// HandleAdminRevisionRestore brings back the title and content of a
+// revision. The version it replaces is saved as a revision in turn.
+func (s *Server) HandleAdminRevisionRestore(w http.ResponseWriter, r *http.Request) {
+	id, revID, ok := revisionIDs(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.Error("begin restore revision", "error", err)
+		http.Error(w, "Failed to restore", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	rev, err := q.GetPostRevision(r.Context(), dbgen.GetPostRevisionParams{ID: revID, PostID: id})
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	post, err := q.GetPostByID(r.Context(), id)
+	if err == nil {
+		err = q.SavePostRevision(r.Context(), dbgen.SavePostRevisionParams{PostID: id, Title: rev.Title, Content: rev.Content})
+	}
+	if err == nil {
+		err = q.RestorePostRevision(r.Context(), dbgen.RestorePostRevisionParams{Title: rev.Title, Content: rev.Content, ID: id})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.Error("restore revision", "error", err)
+		http.Error(w, "Failed to restore", http.StatusInternalServerError)
+		return
+	}
+	s.resolveEmbeds(r.Context(), rev.Content)
+	s.renders.remove(id)
+	s.postChanged(r.Context(), &post, id)
+
+	http.Redirect(w, r, "/admin/edit/"+strconv.FormatInt(id, 10), http.StatusFound)
+}
@@ -0,0 +1,50 @@
+package srv
+
+import (
+	"net/http"
+	"strings"
+)
+
+// aiCrawlers are the user agents of crawlers that gather training data
+// for AI models, blocked when the robots_block_ai setting is on.
+var aiCrawlers = []string{
+	"GPTBot",
+	"ChatGPT-User",
+	"CCBot",
+	"ClaudeBot",
+	"anthropic-ai",
+	"Google-Extended",
+	"Applebot-Extended",
+	"PerplexityBot",
+	"Bytespider",
+	"meta-externalagent",
+}
+
+// HandleRobots serves robots.txt. Since only crawlers ask for it, whoever
+// does is taken for a bot for the rest of the day.
+func (s *Server) HandleRobots(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	s.markBot(r, botRobots)
+	var sb strings.Builder
+	sb.WriteString("User-agent: *\nDisallow: /admin\n")
+	if s.settingBool(ctx, settingTrackLinks) {
+		sb.WriteString("Disallow: /out\n")
+	}
+
+	if s.settingBool(ctx, settingRobotsBlockAI) {
+		sb.WriteString("\n")
+		for _, agent := range aiCrawlers {
+			sb.WriteString("User-agent: " + agent + "\n")
+		}
+		sb.WriteString("Disallow: /\n")
+	}
+	if extra := s.setting(ctx, settingRobotsExtra); extra != "" {
+		sb.WriteString("\n" + extra + "\n")
+	}
+	if sitemap := s.setting(ctx, settingRobotsSitemap); sitemap != "" {
+		sb.WriteString("\nSitemap: " + sitemap + "\n")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(sb.String()))
+}
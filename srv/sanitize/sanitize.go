@@ -0,0 +1,223 @@
+// Package sanitize filters untrusted HTML down to an allow-list of tags
+// and attributes.
+//
+// The input is tokenized and then re-serialized: allowed tags are written
+// back with only their allowed attributes, quoted and escaped, and
+// everything else is escaped as text. Comments are dropped, and so is the
+// content of script, style and similar elements. Because the output is
+// always generated rather than copied, malformed input cannot smuggle
+// markup past the filter.
+package sanitize
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// attrs lists the attributes allowed on each tag, in addition to
+// globalAttrs. Tags not in the map are removed.
+var attrs = map[string][]string{
+	"a":          {"href"},
+	"abbr":       nil,
+	"audio":      {"src", "controls", "preload", "loop"},
+	"b":          nil,
+	"blockquote": {"cite"},
+	"br":         nil,
+	"caption":    nil,
+	"cite":       nil,
+	"code":       nil,
+	"dd":         nil,
+	"del":        nil,
+	"details":    {"open"},
+	"dfn":        nil,
+	"div":        nil,
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"figcaption": nil,
+	"figure":     nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"hr":         nil,
+	"i":          nil,
+	"iframe":     {"src", "width", "height", "allow", "allowfullscreen", "loading", "frameborder"},
+	"img":        {"src", "alt", "width", "height", "loading"},
+	"ins":        nil,
+	"kbd":        nil,
+	"li":         {"value"},
+	"mark":       nil,
+	"ol":         {"start", "reversed"},
+	"p":          nil,
+	"pre":        nil,
+	"q":          {"cite"},
+	"s":          nil,
+	"samp":       nil,
+	"small":      nil,
+	"source":     {"src", "type"},
+	"span":       nil,
+	"strong":     nil,
+	"sub":        nil,
+	"summary":    nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"colspan", "rowspan"},
+	"tfoot":      nil,
+	"th":         {"colspan", "rowspan", "scope"},
+	"thead":      nil,
+	"time":       {"datetime"},
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+	"var":        nil,
+	"video":      {"src", "controls", "width", "height", "poster", "preload", "loop", "muted"},
+}
+
+var globalAttrs = []string{"class", "id", "title", "lang", "dir"}
+
+// urlAttrs hold URLs and must pass SafeURL.
+var urlAttrs = map[string]bool{"href": true, "src": true, "cite": true, "poster": true}
+
+// dropContent lists elements whose content is removed along with them.
+var dropContent = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+	"noscript": true, "template": true, "xmp": true, "plaintext": true,
+}
+
+var (
+	reTag  = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9-]*)((?:\s+[^\s"'>/=]+(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*)\s*(/?)>`)
+	reAttr = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*([^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?`)
+)
+
+// HTML returns s with everything outside the allow-list removed or
+// escaped.
+func HTML(s string) string {
+	var sb strings.Builder
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			text(&sb, s)
+			break
+		}
+		text(&sb, s[:i])
+		s = s[i:]
+
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s[4:], "-->")
+			if end < 0 {
+				break
+			}
+			s = s[4+end+3:]
+			continue
+		}
+		m := reTag.FindStringSubmatch(s)
+		if m == nil {
+			sb.WriteString("&lt;")
+			s = s[1:]
+			continue
+		}
+		s = s[len(m[0]):]
+		closing, name := m[1] == "/", strings.ToLower(m[2])
+
+		if dropContent[name] {
+			if !closing {
+				if end := indexFold(s, "</"+name); end >= 0 {
+					s = s[end:]
+				} else {
+					s = ""
+				}
+			}
+			continue
+		}
+		allowed, ok := attrs[name]
+		if !ok {
+			continue
+		}
+		if closing {
+			sb.WriteString("</" + name + ">")
+			continue
+		}
+		sb.WriteString("<" + name)
+		for _, a := range reAttr.FindAllStringSubmatch(m[3], -1) {
+			attr(&sb, name, strings.ToLower(a[1]), unquote(a[2]), allowed)
+		}
+		sb.WriteString(">")
+	}
+	return sb.String()
+}
+
+func attr(sb *strings.Builder, tag, name, value string, allowed []string) {
+	if !slices.Contains(allowed, name) && !slices.Contains(globalAttrs, name) {
+		return
+	}
+	if urlAttrs[name] {
+		var ok bool
+		if value, ok = SafeURL(value); !ok {
+			return
+		}
+		// Frames and media load immediately, so require https.
+		if name == "src" && tag != "img" && !strings.HasPrefix(value, "https://") && !isLocal(value) {
+			return
+		}
+	}
+	sb.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	if tag == "a" && name == "href" && (strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")) {
+		sb.WriteString(` rel="noopener nofollow"`)
+	}
+}
+
+// SafeURL reports whether u is an http, https, mailto or relative URL,
+// returning it with surrounding whitespace removed.
+func SafeURL(u string) (string, bool) {
+	u = strings.TrimSpace(u)
+	// Strip control characters and spaces browsers ignore inside schemes.
+	scheme := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, u)
+	colon := strings.IndexByte(scheme, ':')
+	if colon < 0 || strings.ContainsAny(scheme[:colon], "/?#") {
+		return u, true
+	}
+	switch strings.ToLower(scheme[:colon]) {
+	case "http", "https", "mailto":
+		return u, true
+	}
+	return "", false
+}
+
+// isLocal reports whether u is a path on this site. Protocol-relative
+// URLs such as //example.com are not.
+func isLocal(u string) bool {
+	return strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//")
+}
+
+func text(sb *strings.Builder, s string) {
+	// Decode first so existing entities are not double-escaped.
+	sb.WriteString(html.EscapeString(html.UnescapeString(s)))
+}
+
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') {
+		v = v[1 : len(v)-1]
+	}
+	return html.UnescapeString(v)
+}
+
+// indexFold is strings.Index ignoring ASCII case.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,66 @@
+package sanitize
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "allowed tags are kept",
+			input:    `<p class="note">Hi <b>there</b><br/></p>`,
+			expected: `<p class="note">Hi <b>there</b><br></p>`,
+		},
+		{
+			name:     "script content is dropped",
+			input:    `a<script>alert("x")</script>b<STYLE>p{}</STYLE>c`,
+			expected: `abc`,
+		},
+		{
+			name:     "event handlers and style are dropped",
+			input:    `<img src="/a.png" onerror="alert(1)" style="x" alt='a "b"'>`,
+			expected: `<img src="/a.png" alt="a &#34;b&#34;">`,
+		},
+		{
+			name:     "javascript urls are dropped",
+			input:    `<a href="java&#x09;script:alert(1)">x</a><a href=" JAVASCRIPT:alert(1)">y</a>`,
+			expected: `<a>x</a><a>y</a>`,
+		},
+		{
+			name:     "external links get rel",
+			input:    `<a href="https://example.com/?a=1&amp;b=2" rel="opener">x</a>`,
+			expected: `<a href="https://example.com/?a=1&amp;b=2" rel="noopener nofollow">x</a>`,
+		},
+		{
+			name:     "iframes need https",
+			input:    `<iframe src="https://player.vimeo.com/video/1" allowfullscreen></iframe><iframe src="http://x.test/"></iframe><iframe src="//x.test/"></iframe>`,
+			expected: `<iframe src="https://player.vimeo.com/video/1" allowfullscreen=""></iframe><iframe></iframe><iframe></iframe>`,
+		},
+		{
+			name:     "unknown tags are removed and comments dropped",
+			input:    `<form action="/x"><input name="q"></form><!-- hidden -->ok`,
+			expected: `ok`,
+		},
+		{
+			name:     "stray brackets are escaped",
+			input:    `1 < 2 && <unknown tag> <b`,
+			expected: `1 &lt; 2 &amp;&amp;  &lt;b`,
+		},
+		{
+			name:     "entities are not double escaped",
+			input:    `caf&eacute; &amp; &lt;b&gt;`,
+			expected: `café &amp; &lt;b&gt;`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := HTML(test.input)
+			if result != test.expected {
+				t.Errorf("HTML(%q) = %q, expected %q", test.input, result, test.expected)
+			}
+		})
+	}
+}
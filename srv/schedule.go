@@ -0,0 +1,45 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// scheduleInterval is how often the server looks for scheduled posts that
+// are due.
+const scheduleInterval = time.Minute
+
+// scheduleLoop runs publishDue every scheduleInterval.
+func (s *Server) scheduleLoop(ctx context.Context) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+	for {
+		s.publishDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDue publishes the drafts whose publish_at has passed, dating them
+// to that time, and hands them to the announce loop.
+func (s *Server) publishDue(ctx context.Context) {
+	ids, err := dbgen.New(s.DB).PublishDuePosts(ctx)
+	if err != nil {
+		slog.Error("publish due posts", "error", err)
+		return
+	}
+	for _, id := range ids {
+		slog.Info("published scheduled post", "id", id)
+		s.renders.remove(id)
+		s.postChanged(ctx, nil, id)
+	}
+	if len(ids) > 0 {
+		s.requestAnnounce()
+	}
+}
@@ -0,0 +1,162 @@
+package srv
+
+import (
+	"context"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// searchLimit is the most results a search returns.
+const searchLimit = 20
+
+// SearchResult is a published post matching a search.
+type SearchResult struct {
+	Slug    string  `json:"slug"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"` // HTML, with matches in <mark>
+	Score   float64 `json:"score"`   // higher is a better match
+}
+
+// searchQuery is written by hand because sqlc cannot parse a MATCH against
+// the FTS5 table itself. Title matches weigh ten times body matches. The
+// snippet of the body marks matches with the control characters STX and
+// ETX, which do not occur in written text, so that markSnippet can escape
+// the text before turning them into tags.
+const searchQuery = `
+SELECT posts.slug, posts.title,
+       snippet(posts_fts, 1, char(2), char(3), '…', 24),
+       -bm25(posts_fts, 10.0, 1.0) AS score
+FROM posts_fts
+JOIN posts ON posts.id = posts_fts.rowid
+WHERE posts_fts MATCH ? AND posts.published = 1 AND posts.deleted_at IS NULL
+ORDER BY score DESC
+LIMIT ?`
+
+// ftsQuery turns what a reader typed into an FTS5 query matching posts
+// that contain every word, the last one as a prefix so partly typed words
+// match. It returns "" if there is nothing to search for.
+func ftsQuery(q string) string {
+	words := searchWords(q)
+	if len(words) == 0 {
+		return ""
+	}
+	for i, w := range words {
+		// Quoting makes FTS5 operators such as AND or NEAR plain words.
+		words[i] = `"` + w + `"`
+	}
+	return strings.Join(words, " ") + "*"
+}
+
+// searchWords splits q into the words to search for, dropping punctuation.
+func searchWords(q string) []string {
+	return strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// snippetMarks turns the match markers of an escaped snippet into tags.
+var snippetMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// markSnippet returns snippet as HTML, escaping its text and wrapping the
+// matches it marks in <mark>.
+func markSnippet(snippet string) string {
+	return snippetMarks.Replace(html.EscapeString(snippet))
+}
+
+// searchPosts returns the published posts matching q, best first.
+func (s *Server) searchPosts(ctx context.Context, q string, limit int) ([]SearchResult, error) {
+	match := ftsQuery(q)
+	if match == "" {
+		return []SearchResult{}, nil
+	}
+	rows, err := s.DB.QueryContext(ctx, searchQuery, match, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.Slug, &r.Title, &r.Snippet, &r.Score); err != nil {
+			return nil, err
+		}
+		r.Snippet = markSnippet(r.Snippet)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// searchResponse is the body of a search API response. Corrected is the
+// query that was searched instead if the one given matched nothing.
+type searchResponse struct {
+	Query     string         `json:"query"`
+	Corrected string         `json:"corrected,omitempty"`
+	Results   []SearchResult `json:"results"`
+}
+
+// HandleSearchAPI answers GET /api/v1/search?q= with the matching posts as
+// JSON. An optional limit caps the number of results.
+func (s *Server) HandleSearchAPI(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "missing query parameter q"})
+		return
+	}
+	limit := searchLimit
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = min(n, searchLimit)
+	}
+
+	resp := searchResponse{Query: q}
+	results, err := s.searchPosts(r.Context(), q, limit)
+	if err == nil && len(results) == 0 {
+		// Nothing matched, so the query may be misspelled. Retry with the
+		// closest words the index knows.
+		var corrected string
+		corrected, err = s.correctQuery(r.Context(), q)
+		if err == nil && corrected != "" {
+			resp.Corrected = corrected
+			results, err = s.searchPosts(r.Context(), corrected, limit)
+		}
+	}
+	if err != nil {
+		slog.Error("search posts", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "search failed"})
+		return
+	}
+	resp.Results = results
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// suggestLimit is the most titles the suggest API returns.
+const suggestLimit = 8
+
+// HandleSuggestAPI answers GET /api/v1/suggest?q= with the published posts
+// whose titles start with q, ignoring case, for a type-ahead search box.
+func (s *Server) HandleSuggestAPI(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimLeft(r.URL.Query().Get("q"), " ")
+	if strings.TrimSpace(q) == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "missing query parameter q"})
+		return
+	}
+
+	// Titles starting with q sort between q and q followed by the highest
+	// code point.
+	rows, err := dbgen.New(s.DB).SuggestPosts(r.Context(), dbgen.SuggestPostsParams{
+		Prefix:    q,
+		PrefixEnd: q + string(unicode.MaxRune),
+		Limit:     suggestLimit,
+	})
+	if err != nil {
+		slog.Error("suggest posts", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "suggest failed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, suggestResponse{Query: q, Suggestions: rows})
+}
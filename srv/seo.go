@@ -0,0 +1,116 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/markdown"
+	"srv.exe.dev/srv/slug"
+)
+
+const (
+	// maxSEOTitle is the longest title, in characters, that search engines
+	// show without cutting it short.
+	maxSEOTitle = 60
+	// maxSEODescription is the longest meta description, in characters,
+	// that search engines show in full.
+	maxSEODescription = 160
+	// minWordsForHeadings is the length of post, in words, above which a
+	// post without headings is hard to skim.
+	minWordsForHeadings = 300
+)
+
+// analyzeRequest is the body of an SEO analysis request: the fields of the
+// edit form as they are, saved or not.
+type analyzeRequest struct {
+	ID              int64  `json:"id"` // 0 for a post not created yet
+	Slug            string `json:"slug"`
+	Title           string `json:"title"`
+	Content         string `json:"content"`
+	MetaDescription string `json:"meta_description"`
+	AllowHTML       bool   `json:"allow_html"`
+}
+
+// seoWarning is a problem found by the SEO analysis. Check names the kind
+// of problem, for scripts, and Message explains it to the author.
+type seoWarning struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// HandleAnalyzeAPI answers POST /admin/api/posts/analyze by checking a
+// draft for common search engine problems, so that the editor can point
+// them out before the post is published.
+func (s *Server) HandleAnalyzeAPI(w http.ResponseWriter, r *http.Request) {
+	var req analyzeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body"})
+		return
+	}
+	warnings, err := s.analyzeSEO(r.Context(), req)
+	if err != nil {
+		slog.Error("analyze post", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "analysis failed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"warnings": warnings})
+}
+
+// analyzeSEO returns the problems with req, in the order the fields appear
+// in the editor.
+func (s *Server) analyzeSEO(ctx context.Context, req analyzeRequest) ([]seoWarning, error) {
+	warnings := []seoWarning{}
+	warn := func(check, format string, args ...any) {
+		warnings = append(warnings, seoWarning{Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if n := utf8.RuneCountInString(title); n == 0 {
+		warn("title", "The post has no title.")
+	} else if n > maxSEOTitle {
+		warn("title", "The title is %d characters long; search engines cut titles off after about %d.", n, maxSEOTitle)
+	}
+
+	if req.Slug != "" {
+		q := dbgen.New(s.DB)
+		other, err := q.GetPostBySlug(ctx, req.Slug)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return nil, err
+		case other.ID != req.ID:
+			free, err := slug.Unique(ctx, q, req.Slug)
+			if err != nil {
+				return nil, err
+			}
+			warn("slug", "Another post, %q, already uses the slug %s; %s is free.", other.Title, req.Slug, free)
+		}
+	}
+
+	doc := s.markdownOptions(ctx, dbgen.Post{AllowHtml: boolToInt(req.AllowHTML)}).Parse(req.Content)
+	if words := markdown.WordCount(doc); words >= minWordsForHeadings && len(markdown.TOC(doc)) == 0 {
+		warn("headings", "The post is %d words long but has no headings to break it up.", words)
+	}
+	for _, dest := range markdown.ImagesWithoutAlt(doc) {
+		if dest == "" {
+			warn("alt_text", "An image has no alt text.")
+		} else {
+			warn("alt_text", "The image %s has no alt text.", dest)
+		}
+	}
+
+	if n := utf8.RuneCountInString(strings.TrimSpace(req.MetaDescription)); n == 0 {
+		warn("meta_description", "There is no meta description, so search engines will show the excerpt or the start of the post.")
+	} else if n > maxSEODescription {
+		warn("meta_description", "The meta description is %d characters long; search engines cut it off after about %d.", n, maxSEODescription)
+	}
+	return warnings, nil
+}
@@ -0,0 +1,132 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// SeriesView is a series with its published parts in reading order.
+type SeriesView struct {
+	Slug        string
+	Title       string
+	Description string
+	Parts       []PostView
+	Current     int // 1-based part number of the post being shown, or 0
+}
+
+// loadSeries returns the series with its published parts. currentID marks
+// the part being shown, if any.
+func (s *Server) loadSeries(ctx context.Context, series dbgen.Series, currentID int64) (*SeriesView, error) {
+	parts, err := dbgen.New(s.DB).GetSeriesPosts(ctx, &series.ID)
+	if err != nil {
+		return nil, err
+	}
+	view := &SeriesView{Slug: series.Slug, Title: series.Title, Description: series.Description}
+	for i, p := range parts {
+		view.Parts = append(view.Parts, PostView{ID: p.ID, Slug: p.Slug, Title: p.Title, CreatedAt: p.CreatedAt})
+		if p.ID == currentID {
+			view.Current = i + 1
+		}
+	}
+	return view, nil
+}
+
+// postSeries returns the series p belongs to, or nil if it is not part of
+// one.
+func (s *Server) postSeries(ctx context.Context, p dbgen.Post) *SeriesView {
+	if p.SeriesID == nil {
+		return nil
+	}
+	series, err := dbgen.New(s.DB).GetSeriesByID(ctx, *p.SeriesID)
+	if err != nil {
+		slog.Error("get series", "error", err)
+		return nil
+	}
+	view, err := s.loadSeries(ctx, series, p.ID)
+	if err != nil {
+		slog.Error("get series posts", "error", err)
+		return nil
+	}
+	return view
+}
+
+func (s *Server) HandleSeries(w http.ResponseWriter, r *http.Request) {
+	series, err := dbgen.New(s.DB).GetSeriesBySlug(r.Context(), r.PathValue("slug"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	view, err := s.loadSeries(r.Context(), series, 0)
+	if err != nil {
+		slog.Error("get series posts", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	s.render(w, "base.html", map[string]any{
+		"Series": view,
+		"Year":   time.Now().Year(),
+		"Page":   "series",
+	})
+}
+
+func (s *Server) HandleAdminSeries(w http.ResponseWriter, r *http.Request) {
+	s.renderAdminSeries(w, r, "")
+}
+
+func (s *Server) renderAdminSeries(w http.ResponseWriter, r *http.Request, errMsg string) {
+	series, err := dbgen.New(s.DB).GetAllSeries(r.Context())
+	if err != nil {
+		slog.Error("get series", "error", err)
+	}
+	s.render(w, "admin_series.html", map[string]any{
+		"Series":    series,
+		"Error":     errMsg,
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+func (s *Server) HandleAdminSeriesCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" || !validSlug(slug) {
+		s.renderAdminSeries(w, r, "A title and a slug of lowercase letters, digits and hyphens are required")
+		return
+	}
+
+	_, err := dbgen.New(s.DB).CreateSeries(r.Context(), dbgen.CreateSeriesParams{
+		Slug:        slug,
+		Title:       title,
+		Description: strings.TrimSpace(r.FormValue("description")),
+	})
+	if err != nil {
+		slog.Error("create series", "error", err)
+		s.renderAdminSeries(w, r, "Failed to create series: "+err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/admin/series", http.StatusFound)
+}
+
+func (s *Server) HandleAdminSeriesDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := dbgen.New(s.DB).DeleteSeries(r.Context(), id); err != nil {
+		slog.Error("delete series", "error", err)
+	}
+	http.Redirect(w, r, "/admin/series", http.StatusFound)
+}
@@ -1,37 +1,105 @@
 package srv
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"database/sql"
+	"errors"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"srv.exe.dev/db"
 	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/mail"
+	"srv.exe.dev/srv/markdown"
+	"srv.exe.dev/srv/oembed"
+)
+
+const (
+	siteTitle   = "Citizen of the World"
+	siteTagline = "Thoughts and stories from everywhere and nowhere."
 )
 
 type Server struct {
-	DB           *sql.DB
-	Hostname     string
-	TemplatesDir string
-	StaticDir    string
-	templates    *template.Template
+	DB            *sql.DB
+	Hostname      string
+	TemplatesDir  string
+	StaticDir     string
+	MediaDir      string            // where uploaded images are stored
+	ContentDir    string            // Markdown files posts are synced from; empty turns syncing off
+	ContentCommit bool              // write changes to posts back to ContentDir, a Git checkout, as commits
+	Encoders      map[string]string // paths of the commands that convert images to mediaFormats, by content type
+	OEmbed        *oembed.Client    // nil disables resolving embeds on save
+	templates     *template.Template
+	renders       *renderCache
+	publishHooks  []publishHook
+	announce      chan struct{}
+	webhooks      chan struct{}
+	webmentions   chan struct{}
+	federation    chan struct{}
+	bluesky       chan struct{}
+	contentSync   chan struct{}
+	variantMu     sync.Mutex // held while making a scaled copy of an image
+	contentMu     sync.Mutex // held while syncing ContentDir or committing to it
+	commentKey    []byte     // signs the comment form's token
+	actorMu       sync.Mutex // held while loading or making actorKey
+	actorKey      *rsa.PrivateKey
+	viewMu        sync.Mutex  // held while counting views or taking them to write
+	views         *viewCounts // views counted but not yet written
+	saltMu        sync.Mutex  // held while loading or replacing visitorSalt
+	saltDay       string      // the day, in UTC, visitorSalt is for
+	visitorSalt   string
+	botMu         sync.Mutex // held while classifying requests as bots' or counting them
+	botMatches    map[string]int64
+	paceDay       string           // the day, in UTC, paces are for
+	paces         map[string]*pace // by visitor
+	mail          chan struct{}
+	mailTemplates *mail.Templates
+	sendMail      func(context.Context, mail.Config, mail.Message) error // mail.Send, unless a test replaces it
 }
 
 type PostView struct {
-	ID          int64
-	Slug        string
-	Title       string
-	Content     string
-	Excerpt     string
-	ContentHTML template.HTML
-	Published   bool
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID              int64
+	Slug            string
+	Title           string
+	Content         string
+	Excerpt         string // as entered in the admin, or in listings the start of the content if that is empty
+	ContentHTML     template.HTML
+	TOC             []markdown.TOCEntry
+	HasMath         bool
+	ReadingTime     int // estimated minutes
+	Published       bool
+	AllowHTML       bool
+	MetaDescription string    // as entered in the admin, possibly empty
+	OGImage         string    // as entered in the admin, possibly empty
+	CoverImage      string    // hero image URL or site path, possibly empty
+	CoverSrc        string    // CoverImage scaled down to the size it is shown at, if it is an upload
+	SeriesID        int64     // 0 if the post is not part of a series
+	SeriesOrder     int64     // part number within the series
+	CategoryID      int64     // 0 if the post is not in a category
+	PublishAt       time.Time // when a draft is due to be published, or zero
+	CommentsClosed  bool      // no more comments are taken, though approved ones are still shown
+	NoCrosspost     bool      // not shared on the blog's social accounts when published
+	Views           int64     // page views of all time in the admin post list, or in the window ranked over in popular posts
+	Category        *dbgen.Category
+	Tags            []dbgen.Tag
+	TagList         string // comma-separated tag names, as edited in the admin
+	URL             string // absolute address of the post page
+	Description     string // MetaDescription, Excerpt, or else a plain-text summary of the content
+	Image           string // absolute address of the image shown in link previews: OGImage, CoverImage, the first image in the content or the site default
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 func New(dbPath, hostname string) (*Server, error) {
@@ -41,7 +109,21 @@ func New(dbPath, hostname string) (*Server, error) {
 		Hostname:     hostname,
 		TemplatesDir: filepath.Join(baseDir, "templates"),
 		StaticDir:    filepath.Join(baseDir, "static"),
+		MediaDir:     filepath.Join(filepath.Dir(dbPath), "media"),
+		OEmbed:       oembed.NewClient(),
+		Encoders:     findImageEncoders(),
+		renders:      newRenderCache(renderCacheSize),
+		announce:     make(chan struct{}, 1),
+		webhooks:     make(chan struct{}, 1),
+		webmentions:  make(chan struct{}, 1),
+		federation:   make(chan struct{}, 1),
+		bluesky:      make(chan struct{}, 1),
+		mail:         make(chan struct{}, 1),
+		contentSync:  make(chan struct{}, 1),
+		commentKey:   []byte(rand.Text()),
 	}
+	srv.publishHooks = srv.defaultPublishHooks()
+	srv.sendMail = mail.Send
 	if err := srv.setUpDatabase(dbPath); err != nil {
 		return nil, err
 	}
@@ -57,93 +139,187 @@ func (s *Server) loadTemplates() error {
 		return err
 	}
 	s.templates = tmpl
-	return nil
+	s.mailTemplates, err = mail.LoadTemplates(filepath.Join(s.TemplatesDir, "mail"))
+	return err
 }
 
 func (s *Server) render(w http.ResponseWriter, name string, data any) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := s.templates.ExecuteTemplate(w, name, data); err != nil {
+	s.renderStatus(w, http.StatusOK, name, data)
+}
+
+// renderStatus renders the template name with data and sends it with the
+// given status. The page is rendered in full first, so that if the
+// template fails a plain 500 goes out instead of half a page.
+func (s *Server) renderStatus(w http.ResponseWriter, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := s.templates.ExecuteTemplate(&buf, name, data); err != nil {
 		slog.Error("render template", "name", name, "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
 }
 
+// homePageSize is the number of posts on each page of the home page.
+const homePageSize = 10
+
 func (s *Server) HandleHome(w http.ResponseWriter, r *http.Request) {
 	q := dbgen.New(s.DB)
-	dbPosts, err := q.GetPublishedPosts(r.Context())
+	total, err := q.CountPublishedPosts(r.Context())
+	if err != nil {
+		slog.Error("count posts", "error", err)
+	}
+	page := pageNumber(r)
+	dbPosts, err := q.GetPublishedPostsPage(r.Context(), dbgen.GetPublishedPostsPageParams{
+		Limit:  homePageSize,
+		Offset: int64(page-1) * homePageSize,
+	})
 	if err != nil {
 		slog.Error("get posts", "error", err)
 	}
 
 	posts := make([]PostView, 0, len(dbPosts))
 	for _, p := range dbPosts {
-		posts = append(posts, PostView{
-			ID:        p.ID,
-			Slug:      p.Slug,
-			Title:     p.Title,
-			Excerpt:   excerpt(p.Content, 200),
-			CreatedAt: p.CreatedAt,
-		})
+		posts = append(posts, previewView(p))
+	}
+
+	var popular []PostView
+	days := s.popularDays(r.Context())
+	if page == 1 {
+		popular = s.popularPosts(r.Context(), days, homePopular)
 	}
 
 	s.render(w, "base.html", map[string]any{
-		"Posts": posts,
-		"Year":  time.Now().Year(),
-		"Page":  "home",
+		"Posts":         posts,
+		"Popular":       popular,
+		"PopularPeriod": popularPeriod(days),
+		"Pagination":    paginate(page, homePageSize, total),
+		"RelMe":         s.relMe(r),
+		"Newsletter":    s.newsletterOn(r.Context()),
+		"Year":          time.Now().Year(),
+		"Page":          "home",
 	})
 }
 
+// previewView returns the fields of p shown in post listings.
+func previewView(p dbgen.Post) PostView {
+	return PostView{
+		ID:         p.ID,
+		Slug:       p.Slug,
+		Title:      p.Title,
+		Excerpt:    postExcerpt(p),
+		CoverImage: p.CoverImage,
+		CoverSrc:   sizedImage(p.CoverImage, 800, 400),
+		CreatedAt:  p.CreatedAt,
+	}
+}
+
 func (s *Server) HandlePost(w http.ResponseWriter, r *http.Request) {
 	slug := r.PathValue("slug")
 	q := dbgen.New(s.DB)
 	p, err := q.GetPostBySlug(r.Context(), slug)
 	if err != nil {
-		http.NotFound(w, r)
+		if !s.redirectPost(w, r, slug) {
+			http.NotFound(w, r)
+		}
 		return
 	}
-	if p.Published == 0 {
+	if p.Published == 0 || p.DeletedAt != nil {
 		http.NotFound(w, r)
 		return
 	}
+	modified := p.UpdatedAt
+	for _, changed := range []time.Time{
+		s.commentsChangedAt(r.Context(), p.ID),
+		s.mentionsChangedAt(r.Context(), p.ID),
+		s.likesChangedAt(r.Context(), p.ID),
+	} {
+		if changed.After(modified) {
+			modified = changed
+		}
+	}
+	w.Header().Set("Link", `</webmention>; rel="webmention"`)
+	if notModified(w, r, "post", p.ID, modified) {
+		return
+	}
+
+	if r.Method != http.MethodHead && !isBot(r) {
+		s.countView(p.ID, s.referrerHost(r))
+	}
 
+	s.render(w, "base.html", s.postPage(r, p))
+}
+
+// postPage returns the template data for the page showing p.
+func (s *Server) postPage(r *http.Request, p dbgen.Post) map[string]any {
+	rp := s.renderPost(r.Context(), p)
+	postURL := s.baseURL(r) + "/post/" + p.Slug
 	post := PostView{
 		ID:          p.ID,
 		Slug:        p.Slug,
 		Title:       p.Title,
 		Content:     p.Content,
-		ContentHTML: renderContent(p.Content),
+		ContentHTML: cmp.Or(rp.outHTML, rp.html),
+		TOC:         rp.toc,
+		HasMath:     rp.hasMath,
+		ReadingTime: readingTime(rp.words),
+		CoverImage:  p.CoverImage,
+		CoverSrc:    sizedImage(p.CoverImage, 1600, 800),
+		URL:         postURL,
+		Description: cmp.Or(p.MetaDescription, p.Excerpt, rp.summary),
 		CreatedAt:   p.CreatedAt,
 		UpdatedAt:   p.UpdatedAt,
 	}
+	post.CommentsClosed = p.CommentsClosed == 1
+	if image := cmp.Or(p.OgImage, p.CoverImage, rp.image, s.setting(r.Context(), settingDefaultOGImage)); image != "" {
+		post.Image = resolveURL(postURL, image)
+	}
 
-	s.render(w, "base.html", map[string]any{
-		"Post": post,
-		"Year": time.Now().Year(),
-		"Page": "post",
-	})
-}
-
-func (s *Server) HandleArchive(w http.ResponseWriter, r *http.Request) {
-	q := dbgen.New(s.DB)
-	dbPosts, err := q.GetPublishedPosts(r.Context())
+	post.Category = s.postCategory(r.Context(), p)
+	tags, err := dbgen.New(s.DB).GetPostTags(r.Context(), p.ID)
 	if err != nil {
-		slog.Error("get posts", "error", err)
+		slog.Error("get post tags", "error", err)
 	}
+	post.Tags = tags
+	prev, next := s.adjacentPosts(r.Context(), p.ID)
+	comments := s.postComments(r.Context(), p.ID)
 
-	posts := make([]PostView, 0, len(dbPosts))
-	for _, p := range dbPosts {
-		posts = append(posts, PostView{
-			Slug:      p.Slug,
-			Title:     p.Title,
-			CreatedAt: p.CreatedAt,
-		})
+	return map[string]any{
+		"Post":         post,
+		"Previous":     prev,
+		"Next":         next,
+		"Series":       s.postSeries(r.Context(), p),
+		"Comments":     comments,
+		"ReplyTo":      replyingTo(r, comments),
+		"Mentions":     s.postMentions(r.Context(), p.ID),
+		"CommentToken": s.commentToken(time.Now()),
+		"CommentHeld":  r.URL.Query().Get("comment") == "held",
+		"Likes":        s.postLikes(r.Context(), p.ID),
+		"Liked":        r.URL.Query().Get("liked") == "1",
+		"JSONLD":       postJSONLD(post),
+		"Newsletter":   s.newsletterOn(r.Context()),
+		"Year":         time.Now().Year(),
+		"Page":         "post",
 	}
+}
 
-	s.render(w, "base.html", map[string]any{
-		"Posts": posts,
-		"Year":  time.Now().Year(),
-		"Page":  "archive",
-	})
+// adjacentPosts returns the published posts just before and after the post
+// with the given ID in chronological order, or nil where there is none.
+func (s *Server) adjacentPosts(ctx context.Context, id int64) (prev, next *PostView) {
+	q := dbgen.New(s.DB)
+	if p, err := q.GetPreviousPost(ctx, id); err == nil {
+		prev = &PostView{Slug: p.Slug, Title: p.Title}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("get previous post", "error", err)
+	}
+	if p, err := q.GetNextPost(ctx, id); err == nil {
+		next = &PostView{Slug: p.Slug, Title: p.Title}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("get next post", "error", err)
+	}
+	return prev, next
 }
 
 func (s *Server) setUpDatabase(dbPath string) error {
@@ -160,172 +336,203 @@ func (s *Server) setUpDatabase(dbPath string) error {
 
 func (s *Server) Serve(addr string) error {
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /{$}", s.HandleHome)
-	mux.HandleFunc("GET /post/{slug}", s.HandlePost)
-	mux.HandleFunc("GET /archive", s.HandleArchive)
-	
+	mux.HandleFunc("GET /{$}", s.track(s.HandleHome))
+	mux.HandleFunc("GET /post/{slug}", s.track(s.HandlePost))
+	mux.HandleFunc("POST /post/{slug}/comments", s.refuseBanned(s.HandleCommentSubmit))
+	mux.HandleFunc("POST /post/{slug}/like", s.refuseBanned(s.HandleLike))
+	mux.HandleFunc("POST /webmention", s.refuseBanned(s.HandleWebmention))
+	mux.HandleFunc("GET /.well-known/webfinger", s.HandleWebFinger)
+	mux.HandleFunc("GET /activitypub/actor", s.HandleActor)
+	mux.HandleFunc("GET /activitypub/outbox", s.HandleOutbox)
+	mux.HandleFunc("GET /activitypub/followers", s.HandleFollowers)
+	mux.HandleFunc("GET /activitypub/posts/{slug}", s.HandleActivityPost)
+	mux.HandleFunc("POST /activitypub/inbox", s.refuseBanned(s.HandleInbox))
+	mux.HandleFunc("GET /archive", s.track(s.HandleArchive))
+	mux.HandleFunc("GET /archive/{year}", s.track(s.HandleArchive))
+	mux.HandleFunc("GET /archive/{year}/{month}", s.track(s.HandleArchive))
+	mux.HandleFunc("GET /series/{slug}", s.track(s.HandleSeries))
+	mux.HandleFunc("GET /category/{slug}", s.track(s.HandleCategory))
+	mux.HandleFunc("GET /tags", s.track(s.HandleTags))
+	mux.HandleFunc("GET /popular", s.track(s.HandlePopular))
+	mux.HandleFunc("GET /tag/{tag}", s.track(s.HandleTag))
+	mux.HandleFunc("GET /atom.xml", s.HandleAtom)
+	mux.HandleFunc("GET /subscribe", s.track(s.HandleSubscribe))
+	mux.HandleFunc("POST /subscribe", s.refuseBanned(s.HandleSubscribeSubmit))
+	mux.HandleFunc("GET /subscribe/confirm", s.HandleSubscribeConfirm)
+	mux.HandleFunc("GET /unsubscribe", s.HandleUnsubscribe)
+	mux.HandleFunc("POST /unsubscribe", s.HandleUnsubscribeSubmit)
+	mux.HandleFunc("GET /contact", s.track(s.HandleContact))
+	mux.HandleFunc("POST /contact", s.refuseBanned(s.HandleContactSubmit))
+	for _, route := range s.apiRoutes() {
+		mux.HandleFunc(route.pattern, s.apiHandler(route))
+	}
+	mux.HandleFunc("GET /api/v1/openapi.json", s.HandleOpenAPI)
+	mux.HandleFunc("GET /api/graphql", s.HandleGraphQL)
+	mux.HandleFunc("POST /api/graphql", s.HandleGraphQL)
+	mux.HandleFunc("GET /api/graphql/schema.graphql", s.HandleGraphQLSchema)
+	mux.HandleFunc("GET /micropub", s.requireMicropubToken(s.HandleMicropubQuery))
+	mux.HandleFunc("POST /micropub", s.requireMicropubToken(s.HandleMicropub))
+	mux.HandleFunc("POST /micropub/media", s.requireMicropubToken(s.HandleMicropubMedia))
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", s.HandleIndieAuthMetadata)
+	mux.HandleFunc("GET /indieauth/auth", s.requireAdmin(s.HandleIndieAuthAuthorize))
+	mux.HandleFunc("POST /indieauth/auth/approve", s.requireAdmin(s.HandleIndieAuthApprove))
+	mux.HandleFunc("POST /indieauth/auth", s.refuseBanned(s.HandleIndieAuthRedeem))
+	mux.HandleFunc("GET /indieauth/token", s.HandleIndieAuthTokenInfo)
+	mux.HandleFunc("POST /indieauth/token", s.refuseBanned(s.HandleIndieAuthToken))
+	mux.HandleFunc("POST /indieauth/revoke", s.refuseBanned(s.HandleIndieAuthRevoke))
+	mux.HandleFunc("GET /media/{name}", s.HandleMedia)
+	mux.HandleFunc("GET /out", s.HandleOut)
+	mux.HandleFunc("GET /preview/{token}", s.HandlePreview)
+	mux.HandleFunc("GET /robots.txt", s.HandleRobots)
+	mux.HandleFunc("GET /indexnow.txt", s.HandleIndexNowKey)
+
+	mux.HandleFunc("GET /login", s.refuseBanned(s.requireCSRF(s.HandleLogin)))
+	mux.HandleFunc("POST /login", s.throttleLogin(s.requireCSRF(s.HandleLoginSubmit)))
+	mux.HandleFunc("POST /login/code", s.throttleLogin(s.requireCSRF(s.HandleLoginCode)))
+	mux.HandleFunc("POST /login/email", s.throttleLogin(s.requireCSRF(s.HandleLoginLinkRequest)))
+	mux.HandleFunc("GET /login/link", s.refuseBanned(s.requireCSRF(s.HandleLoginLink)))
+	mux.HandleFunc("POST /login/link", s.throttleLogin(s.requireCSRF(s.HandleLoginLinkUse)))
+	mux.HandleFunc("POST /logout", s.requireCSRF(s.HandleLogout))
+
 	// Admin routes
-	mux.HandleFunc("GET /admin", s.requireAdmin(s.HandleAdminList))
+	mux.HandleFunc("GET /admin", s.requireAdmin(s.HandleAdminDashboard))
+	mux.HandleFunc("GET /admin/posts", s.requireAdmin(s.HandleAdminList))
 	mux.HandleFunc("GET /admin/new", s.requireAdmin(s.HandleAdminNew))
 	mux.HandleFunc("POST /admin/new", s.requireAdmin(s.HandleAdminCreate))
 	mux.HandleFunc("GET /admin/edit/{id}", s.requireAdmin(s.HandleAdminEdit))
 	mux.HandleFunc("POST /admin/edit/{id}", s.requireAdmin(s.HandleAdminUpdate))
+	mux.HandleFunc("POST /admin/edit/{id}/lock", s.requireAdmin(s.HandleAdminEditLock))
+	mux.HandleFunc("POST /admin/edit/{id}/previews", s.requireAdmin(s.HandleAdminPreviewCreate))
+	mux.HandleFunc("POST /admin/edit/{id}/previews/{link}/revoke", s.requireAdmin(s.HandleAdminPreviewRevoke))
+	mux.HandleFunc("PUT /admin/api/posts/{id}/autosave", s.requireAdmin(s.HandleAutosaveAPI))
+	mux.HandleFunc("DELETE /admin/api/posts/{id}/autosave", s.requireAdmin(s.HandleAutosaveDiscardAPI))
+	mux.HandleFunc("POST /admin/api/posts/analyze", s.requireAdmin(s.HandleAnalyzeAPI))
 	mux.HandleFunc("POST /admin/delete/{id}", s.requireAdmin(s.HandleAdminDelete))
-	
+	mux.HandleFunc("POST /admin/bulk", s.requireAdmin(s.HandleAdminBulk))
+	mux.HandleFunc("GET /admin/trash", s.requireAdmin(s.HandleAdminTrash))
+	mux.HandleFunc("POST /admin/trash/restore/{id}", s.requireAdmin(s.HandleAdminRestore))
+	mux.HandleFunc("POST /admin/trash/purge/{id}", s.requireAdmin(s.HandleAdminPurge))
+	mux.HandleFunc("GET /admin/export", s.requireAdmin(s.HandleAdminExport))
+	mux.HandleFunc("GET /admin/export/markdown", s.requireAdmin(s.HandleAdminExportMarkdown))
+	mux.HandleFunc("GET /admin/export/hugo", s.requireAdmin(s.HandleAdminExportHugo))
+	mux.HandleFunc("GET /admin/revisions/{id}", s.requireAdmin(s.HandleAdminRevisions))
+	mux.HandleFunc("GET /admin/revisions/{id}/{rev}", s.requireAdmin(s.HandleAdminRevision))
+	mux.HandleFunc("POST /admin/revisions/{id}/{rev}/restore", s.requireAdmin(s.HandleAdminRevisionRestore))
+	mux.HandleFunc("POST /admin/upload", s.requireAdmin(s.HandleAdminUpload))
+	mux.HandleFunc("GET /admin/media", s.requireAdmin(s.HandleAdminMedia))
+	mux.HandleFunc("POST /admin/media/delete/{id}", s.requireAdmin(s.HandleAdminMediaDelete))
+	mux.HandleFunc("GET /admin/account", s.requireAdmin(s.HandleAdminAccount))
+	mux.HandleFunc("POST /admin/account/totp/setup", s.requireAdmin(s.HandleAdminTOTPSetup))
+	mux.HandleFunc("POST /admin/account/totp", s.requireAdmin(s.HandleAdminTOTPEnable))
+	mux.HandleFunc("POST /admin/account/totp/disable", s.requireAdmin(s.HandleAdminTOTPDisable))
+	mux.HandleFunc("POST /admin/account/backup-codes", s.requireAdmin(s.HandleAdminBackupCodes))
+	mux.HandleFunc("GET /admin/settings", s.requireAdmin(s.HandleAdminSettings))
+	mux.HandleFunc("POST /admin/settings", s.requireAdmin(s.HandleAdminSettingsUpdate))
+	mux.HandleFunc("GET /admin/comments", s.requireAdmin(s.HandleAdminComments))
+	mux.HandleFunc("POST /admin/comments", s.requireAdmin(s.HandleAdminCommentModerate))
+	mux.HandleFunc("GET /admin/webmentions", s.requireAdmin(s.HandleAdminWebmentions))
+	mux.HandleFunc("POST /admin/webmentions", s.requireAdmin(s.HandleAdminWebmentionModerate))
+	mux.HandleFunc("GET /admin/webmentions/sent/{id}", s.requireAdmin(s.HandleAdminWebmentionSends))
+	mux.HandleFunc("POST /admin/webmentions/sent/{id}", s.requireAdmin(s.HandleAdminWebmentionResend))
+	mux.HandleFunc("GET /admin/messages", s.requireAdmin(s.HandleAdminMessages))
+	mux.HandleFunc("GET /admin/likes", s.requireAdmin(s.HandleAdminLikes))
+	mux.HandleFunc("GET /admin/analytics", s.requireAdmin(s.HandleAdminAnalytics))
+	mux.HandleFunc("POST /admin/messages", s.requireAdmin(s.HandleAdminMessageAction))
+	mux.HandleFunc("GET /admin/categories", s.requireAdmin(s.HandleAdminCategories))
+	mux.HandleFunc("POST /admin/categories", s.requireAdmin(s.HandleAdminCategoryCreate))
+	mux.HandleFunc("POST /admin/categories/delete/{id}", s.requireAdmin(s.HandleAdminCategoryDelete))
+	mux.HandleFunc("GET /admin/series", s.requireAdmin(s.HandleAdminSeries))
+	mux.HandleFunc("POST /admin/series", s.requireAdmin(s.HandleAdminSeriesCreate))
+	mux.HandleFunc("POST /admin/series/delete/{id}", s.requireAdmin(s.HandleAdminSeriesDelete))
+	mux.HandleFunc("GET /admin/redirects", s.requireAdmin(s.HandleAdminRedirects))
+	mux.HandleFunc("POST /admin/redirects", s.requireAdmin(s.HandleAdminRedirectCreate))
+	mux.HandleFunc("POST /admin/redirects/delete/{slug}", s.requireAdmin(s.HandleAdminRedirectDelete))
+	mux.HandleFunc("GET /admin/bans", s.requireAdmin(s.HandleAdminBans))
+	mux.HandleFunc("POST /admin/bans", s.requireAdmin(s.HandleAdminBanCreate))
+	mux.HandleFunc("POST /admin/bans/delete/{id}", s.requireAdmin(s.HandleAdminBanDelete))
+	mux.HandleFunc("POST /admin/bans/unlock", s.requireAdmin(s.HandleAdminUnlock))
+	mux.HandleFunc("GET /admin/bots", s.requireAdmin(s.HandleAdminBots))
+	mux.HandleFunc("POST /admin/bots", s.requireAdmin(s.HandleAdminBotCreate))
+	mux.HandleFunc("POST /admin/bots/delete/{id}", s.requireAdmin(s.HandleAdminBotDelete))
+	mux.HandleFunc("GET /admin/tokens", s.requireAdmin(s.HandleAdminTokens))
+	mux.HandleFunc("POST /admin/tokens", s.requireAdmin(s.HandleAdminTokenCreate))
+	mux.HandleFunc("POST /admin/tokens/revoke/{id}", s.requireAdmin(s.HandleAdminTokenRevoke))
+	mux.HandleFunc("GET /admin/webhooks", s.requireAdmin(s.HandleAdminWebhooks))
+	mux.HandleFunc("POST /admin/webhooks", s.requireAdmin(s.HandleAdminWebhookCreate))
+	mux.HandleFunc("POST /admin/webhooks/delete/{id}", s.requireAdmin(s.HandleAdminWebhookDelete))
+	mux.HandleFunc("POST /admin/webhooks/retry/{id}", s.requireAdmin(s.HandleAdminWebhookRetry))
+
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.StaticDir))))
+	go s.announceLoop(context.Background())
+	go s.scheduleLoop(context.Background())
+	go s.webhookLoop(context.Background())
+	go s.webmentionLoop(context.Background())
+	go s.federationLoop(context.Background())
+	go s.blueskyLoop(context.Background())
+	go s.mailLoop(context.Background())
+	go s.syncLoop(context.Background())
+	go s.viewLoop(context.Background())
+
 	slog.Info("starting server", "addr", addr)
 	return http.ListenAndServe(addr, mux)
 }
 
 // Helper functions
 
-func excerpt(content string, maxLen int) string {
-	// Strip any HTML-like content for excerpt
-	content = strings.TrimSpace(content)
-	if len(content) <= maxLen {
-		return content
+// baseURL returns the site's public address without a trailing slash: the
+// site URL setting if set, otherwise the scheme and host of r.
+func (s *Server) baseURL(r *http.Request) string {
+	if u := s.setting(r.Context(), settingSiteURL); u != "" {
+		return u
 	}
-	// Find last space before maxLen
-	truncated := content[:maxLen]
-	if idx := strings.LastIndex(truncated, " "); idx > 0 {
-		truncated = truncated[:idx]
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
 	}
-	return truncated + "..."
+	return scheme + "://" + r.Host
 }
 
-func renderContent(content string) template.HTML {
-	// Simple markdown-like rendering
-	lines := strings.Split(content, "\n")
-	var sb strings.Builder
-	var inCodeBlock bool
-	var inList bool
-	var paragraph []string
-
-	flushParagraph := func() {
-		if len(paragraph) > 0 {
-			text := strings.Join(paragraph, " ")
-			sb.WriteString("<p>")
-			sb.WriteString(template.HTMLEscapeString(text))
-			sb.WriteString("</p>\n")
-			paragraph = nil
-		}
+// resolveURL resolves ref, which may be relative, against the absolute
+// URL base.
+func resolveURL(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
 	}
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Code blocks (4 spaces indent)
-		if strings.HasPrefix(line, "    ") && !inCodeBlock {
-			flushParagraph()
-			if inList {
-				sb.WriteString("</ul>\n")
-				inList = false
-			}
-			sb.WriteString("<pre><code>")
-			inCodeBlock = true
-		}
-		if inCodeBlock {
-			if strings.HasPrefix(line, "    ") {
-				sb.WriteString(template.HTMLEscapeString(strings.TrimPrefix(line, "    ")))
-				sb.WriteString("\n")
-				continue
-			} else {
-				sb.WriteString("</code></pre>\n")
-				inCodeBlock = false
-			}
-		}
-
-		// Empty line
-		if trimmed == "" {
-			flushParagraph()
-			if inList {
-				sb.WriteString("</ul>\n")
-				inList = false
-			}
-			continue
-		}
-
-		// Headers
-		if strings.HasPrefix(trimmed, "## ") {
-			flushParagraph()
-			if inList {
-				sb.WriteString("</ul>\n")
-				inList = false
-			}
-			sb.WriteString("<h2>")
-			sb.WriteString(template.HTMLEscapeString(strings.TrimPrefix(trimmed, "## ")))
-			sb.WriteString("</h2>\n")
-			continue
-		}
-		if strings.HasPrefix(trimmed, "# ") {
-			flushParagraph()
-			if inList {
-				sb.WriteString("</ul>\n")
-				inList = false
-			}
-			sb.WriteString("<h2>")
-			sb.WriteString(template.HTMLEscapeString(strings.TrimPrefix(trimmed, "# ")))
-			sb.WriteString("</h2>\n")
-			continue
-		}
-
-		// List items
-		if strings.HasPrefix(trimmed, "- ") {
-			flushParagraph()
-			if !inList {
-				sb.WriteString("<ul>\n")
-				inList = true
-			}
-			sb.WriteString("<li>")
-			sb.WriteString(template.HTMLEscapeString(strings.TrimPrefix(trimmed, "- ")))
-			sb.WriteString("</li>\n")
-			continue
-		}
-
-		// Bold text **text**
-		paragraph = append(paragraph, trimmed)
+	u, err := b.Parse(ref)
+	if err != nil {
+		return ref
 	}
+	return u.String()
+}
 
-	if inCodeBlock {
-		sb.WriteString("</code></pre>\n")
-	}
-	if inList {
-		sb.WriteString("</ul>\n")
+// postExcerpt returns the summary of p shown in listings and feeds: its
+// excerpt, or else the start of its content.
+func postExcerpt(p dbgen.Post) string {
+	if p.Excerpt != "" {
+		return p.Excerpt
 	}
-	flushParagraph()
-
-	// Process inline formatting
-	result := sb.String()
-	result = processInlineFormatting(result)
-	return template.HTML(result)
+	return excerpt(p.Content, 200)
 }
 
-func processInlineFormatting(s string) string {
-	// Bold: **text**
-	for {
-		start := strings.Index(s, "**")
-		if start == -1 {
-			break
-		}
-		end := strings.Index(s[start+2:], "**")
-		if end == -1 {
-			break
-		}
-		end += start + 2
-		s = s[:start] + "<strong>" + s[start+2:end] + "</strong>" + s[end+2:]
+// excerpt shortens content to at most maxLen characters, ending at a word
+// boundary where there is one.
+func excerpt(content string, maxLen int) string {
+	// Strip any HTML-like content for excerpt
+	content = strings.TrimSpace(content)
+	runes := []rune(content)
+	if len(runes) <= maxLen {
+		return content
 	}
-	// Inline code: `text`
-	for {
-		start := strings.Index(s, "`")
-		if start == -1 {
-			break
-		}
-		end := strings.Index(s[start+1:], "`")
-		if end == -1 {
+	// Cut at the last space within maxLen characters, or right after them
+	// if a word ends there. Text without spaces, such as Chinese or
+	// Japanese, is cut between characters.
+	cut := maxLen
+	for i := maxLen; i > 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
 			break
 		}
-		end += start + 1
-		s = s[:start] + "<code>" + s[start+1:end] + "</code>" + s[end+1:]
 	}
-	return s
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "..."
 }
@@ -1,15 +1,57 @@
 package srv
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"html"
+	"image"
+	"image/png"
+	"io"
+	"maps"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/activitypub"
+	"srv.exe.dev/srv/atproto"
+	"srv.exe.dev/srv/graphql"
+	"srv.exe.dev/srv/mail"
+	"srv.exe.dev/srv/mediastore"
+	"srv.exe.dev/srv/oembed"
+	"srv.exe.dev/srv/tags"
+	"srv.exe.dev/srv/totp"
 )
 
-func TestServerSetupAndHandlers(t *testing.T) {
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
 	tempDB := filepath.Join(t.TempDir(), "test_server.sqlite3")
 	t.Cleanup(func() { os.Remove(tempDB) })
 
@@ -17,100 +59,5739 @@ func TestServerSetupAndHandlers(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create server: %v", err)
 	}
+	// Tests that convert images install fake encoders of their own.
+	server.Encoders = nil
+	return server
+}
+
+func createTestPost(t *testing.T, server *Server, slug, title, content string, published bool) dbgen.Post {
+	t.Helper()
+	var pub int64
+	if published {
+		pub = 1
+	}
+	p, err := dbgen.New(server.DB).CreatePost(context.Background(), dbgen.CreatePostParams{
+		Slug:      slug,
+		Title:     title,
+		Content:   content,
+		Published: pub,
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	return p
+}
+
+func TestServerSetupAndHandlers(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "hello-world", "Hello World", "Some **bold** words.\n\n- one\n  - nested", true)
+	createTestPost(t, server, "secret-draft", "Secret Draft", "Not yet.", false)
 
-	// Test root endpoint without auth
-	t.Run("root endpoint unauthenticated", func(t *testing.T) {
+	t.Run("home lists published posts", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		w := httptest.NewRecorder()
 
-		server.HandleRoot(w, req)
+		server.HandleHome(w, req)
 
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status 200, got %d", w.Code)
 		}
+		body := w.Body.String()
+		if !strings.Contains(body, "Hello World") {
+			t.Errorf("expected home page to list published post, got body: %s", body)
+		}
+		if strings.Contains(body, "Secret Draft") {
+			t.Errorf("expected home page to hide drafts, got body: %s", body)
+		}
+	})
+
+	t.Run("post renders markdown", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/post/hello-world", nil)
+		req.SetPathValue("slug", "hello-world")
+		w := httptest.NewRecorder()
+
+		server.HandlePost(w, req)
 
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
 		body := w.Body.String()
-		if !strings.Contains(body, "test-hostname") {
-			t.Errorf("expected page to show hostname, got body: %s", body)
+		if !strings.Contains(body, "<strong>bold</strong>") {
+			t.Errorf("expected rendered bold text, got body: %s", body)
 		}
-		if !strings.Contains(body, "Go Template Project") {
-			t.Errorf("expected page to contain headline, got body: %s", body)
+		if !strings.Contains(body, "<li>one\n<ul>\n<li>nested</li>") {
+			t.Errorf("expected rendered nested list, got body: %s", body)
 		}
-		if strings.Contains(body, "Signed in as") {
-			t.Errorf("expected page to not be logged in, got body: %s", body)
+		if !strings.Contains(body, "1 min read") {
+			t.Errorf("expected reading time, got body: %s", body)
 		}
-		if !strings.Contains(body, "Not signed in") {
-			t.Errorf("expected page to show 'Not signed in', got body: %s", body)
+	})
+
+	t.Run("math loads the typesetter only when needed", func(t *testing.T) {
+		createTestPost(t, server, "euler", "Euler", "$$e^{i\\pi} + 1 = 0$$", true)
+		for slug, expected := range map[string]bool{"euler": true, "hello-world": false} {
+			req := httptest.NewRequest(http.MethodGet, "/post/"+slug, nil)
+			req.SetPathValue("slug", slug)
+			w := httptest.NewRecorder()
+
+			server.HandlePost(w, req)
+
+			if got := strings.Contains(w.Body.String(), "katex.min.js"); got != expected {
+				t.Errorf("%s: expected katex script included = %v", slug, expected)
+			}
 		}
 	})
 
-	// Test root endpoint with auth headers
-	t.Run("root endpoint authenticated", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/", nil)
-		req.Header.Set("X-ExeDev-UserID", "user123")
-		req.Header.Set("X-ExeDev-Email", "test@example.com")
+	t.Run("post has link preview metadata", func(t *testing.T) {
+		createTestPost(t, server, "pictured", "Pictured", "A **fine** view.\n\n![View](/static/view.jpg)", true)
+		req := httptest.NewRequest(http.MethodGet, "/post/pictured", nil)
+		req.SetPathValue("slug", "pictured")
 		w := httptest.NewRecorder()
 
-		server.HandleRoot(w, req)
+		server.HandlePost(w, req)
 
-		if w.Code != http.StatusOK {
-			t.Errorf("expected status 200, got %d", w.Code)
+		body := w.Body.String()
+		for _, expected := range []string{
+			`<meta name="description" content="A fine view. View">`,
+			`<meta property="og:title" content="Pictured">`,
+			`<meta property="og:url" content="http://example.com/post/pictured">`,
+			`<meta property="og:image" content="http://example.com/static/view.jpg">`,
+			`<meta name="twitter:card" content="summary_large_image">`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("expected post page to contain %q, got body: %s", expected, body)
+			}
 		}
+	})
+
+	t.Run("post has json-ld structured data", func(t *testing.T) {
+		createTestPost(t, server, "tricky", "</script> & friends", "Body.", true)
+		req := httptest.NewRequest(http.MethodGet, "/post/tricky", nil)
+		req.SetPathValue("slug", "tricky")
+		w := httptest.NewRecorder()
+
+		server.HandlePost(w, req)
 
 		body := w.Body.String()
-		if !strings.Contains(body, "Signed in as") {
-			t.Errorf("expected page to show logged in state, got body: %s", body)
+		start := strings.Index(body, `<script type="application/ld+json">`)
+		if start < 0 {
+			t.Fatalf("expected json-ld script, got body: %s", body)
+		}
+		start += len(`<script type="application/ld+json">`)
+		end := strings.Index(body[start:], "</script>")
+		var data map[string]any
+		if err := json.Unmarshal([]byte(body[start:start+end]), &data); err != nil {
+			t.Fatalf("failed to parse json-ld: %v", err)
 		}
-		if !strings.Contains(body, "test@example.com") {
-			t.Error("expected page to show user email")
+		if data["@type"] != "BlogPosting" || data["headline"] != "</script> & friends" {
+			t.Errorf("unexpected json-ld: %v", data)
+		}
+		if data["url"] != "http://example.com/post/tricky" {
+			t.Errorf("expected json-ld url, got %v", data["url"])
 		}
 	})
 
-	// Test view counter functionality
-	t.Run("view counter increments", func(t *testing.T) {
-		// Make first request
-		req1 := httptest.NewRequest(http.MethodGet, "/", nil)
-		req1.Header.Set("X-ExeDev-UserID", "counter-test")
-		req1.RemoteAddr = "192.168.1.100:12345"
-		w1 := httptest.NewRecorder()
-		server.HandleRoot(w1, req1)
+	t.Run("draft post is not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/post/secret-draft", nil)
+		req.SetPathValue("slug", "secret-draft")
+		w := httptest.NewRecorder()
+
+		server.HandlePost(w, req)
 
-		// Should show "1 times" or similar
-		body1 := w1.Body.String()
-		if !strings.Contains(body1, "1</strong> times") {
-			t.Error("expected first visit to show 1 time")
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
 		}
+	})
 
-		// Make second request with same user
-		req2 := httptest.NewRequest(http.MethodGet, "/", nil)
-		req2.Header.Set("X-ExeDev-UserID", "counter-test")
-		req2.RemoteAddr = "192.168.1.100:12345"
-		w2 := httptest.NewRecorder()
-		server.HandleRoot(w2, req2)
+	t.Run("archive lists published posts", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archive", nil)
+		w := httptest.NewRecorder()
+
+		server.HandleArchive(w, req)
 
-		// Should show "2 times" or similar
-		body2 := w2.Body.String()
-		if !strings.Contains(body2, "2</strong> times") {
-			t.Error("expected second visit to show 2 times")
+		body := w.Body.String()
+		if !strings.Contains(body, `href="/post/hello-world"`) {
+			t.Errorf("expected archive to link published post, got body: %s", body)
 		}
 	})
 }
 
-func TestUtilityFunctions(t *testing.T) {
-	t.Run("mainDomainFromHost function", func(t *testing.T) {
-		tests := []struct {
-			input    string
-			expected string
-		}{
-			{"example.exe.cloud:8080", "exe.cloud:8080"},
-			{"example.exe.dev", "exe.dev"},
-			{"example.exe.cloud", "exe.cloud"},
+func TestHomePagination(t *testing.T) {
+	server := newTestServer(t)
+	for i := range homePageSize + 3 {
+		createTestPost(t, server, "post-"+strconv.Itoa(i), "Post "+strconv.Itoa(i), "Body.", true)
+	}
+
+	getHome := func(page string) string {
+		w := httptest.NewRecorder()
+		server.HandleHome(w, httptest.NewRequest(http.MethodGet, "/?page="+page, nil))
+		return w.Body.String()
+	}
+
+	body := getHome("1")
+	if strings.Count(body, `class="post-preview"`) != homePageSize || !strings.Contains(body, `href="?page=2" rel="next"`) {
+		t.Errorf("expected a full first page with a next link, got body: %s", body)
+	}
+	if !strings.Contains(body, "Welcome") {
+		t.Errorf("expected the intro on the first page, got body: %s", body)
+	}
+	body = getHome("2")
+	if strings.Count(body, `class="post-preview"`) != 3 || !strings.Contains(body, `href="?page=1" rel="prev"`) || strings.Contains(body, `rel="next"`) {
+		t.Errorf("expected a last page with a previous link, got body: %s", body)
+	}
+	if strings.Contains(body, "Welcome") {
+		t.Errorf("expected the intro only on the first page, got body: %s", body)
+	}
+	if body := getHome("5"); !strings.Contains(body, "No posts on this page.") {
+		t.Errorf("expected an empty page past the end, got body: %s", body)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	server := newTestServer(t)
+	for slug, created := range map[string]string{
+		"june-one": "2024-06-03 09:00:00",
+		"june-two": "2024-06-20 09:00:00",
+		"march":    "2024-03-01 09:00:00",
+		"old":      "2023-12-31 09:00:00",
+	} {
+		p := createTestPost(t, server, slug, slug, "Body.", true)
+		if _, err := server.DB.Exec("UPDATE posts SET created_at = ? WHERE id = ?", created, p.ID); err != nil {
+			t.Fatalf("failed to backdate post: %v", err)
 		}
+	}
 
-		for _, test := range tests {
-			result := mainDomainFromHost(test.input)
-			if result != test.expected {
-				t.Errorf("mainDomainFromHost(%q) = %q, expected %q", test.input, result, test.expected)
+	getArchive := func(year, month string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/archive", nil)
+		req.SetPathValue("year", year)
+		req.SetPathValue("month", month)
+		w := httptest.NewRecorder()
+		server.HandleArchive(w, req)
+		return w
+	}
+
+	body := getArchive("", "").Body.String()
+	for _, expected := range []string{
+		`<a href="/archive/2024" class="archive-year">2024</a>`,
+		`<a href="/archive/2024/06" title="2 posts">Jun</a>`,
+		`<a href="/archive/2023/12" title="1 post">Dec</a>`,
+		`<a href="/archive/2024/06">June 2024</a>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected archive to contain %q, got body: %s", expected, body)
+		}
+	}
+	if june, march := strings.Index(body, "June 2024"), strings.Index(body, "March 2024"); june < 0 || march < june {
+		t.Errorf("expected months newest first, got body: %s", body)
+	}
+
+	body = getArchive("2024", "").Body.String()
+	if !strings.Contains(body, "/post/march") || strings.Contains(body, "/post/old") {
+		t.Errorf("expected year archive to list only that year, got body: %s", body)
+	}
+	body = getArchive("2024", "06").Body.String()
+	if !strings.Contains(body, "/post/june-two") || strings.Contains(body, "/post/march") {
+		t.Errorf("expected month archive to list only that month, got body: %s", body)
+	}
+
+	for _, period := range [][2]string{{"2022", ""}, {"2024", "13"}, {"soon", ""}} {
+		if w := getArchive(period[0], period[1]); w.Code != http.StatusNotFound {
+			t.Errorf("expected archive %v to 404, got %d", period, w.Code)
+		}
+	}
+}
+
+func TestAdminStatusFilter(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "live", "Live Post", "Body.", true)
+	createTestPost(t, server, "draft-one", "Draft One", "Body.", false)
+	createTestPost(t, server, "draft-two", "Draft Two", "Body.", false)
+
+	list := func(status string) string {
+		w := httptest.NewRecorder()
+		server.HandleAdminList(w, httptest.NewRequest(http.MethodGet, "/admin/posts?status="+status, nil))
+		return w.Body.String()
+	}
+
+	body := list("")
+	for _, expected := range []string{
+		`class="active">All <span>3</span>`,
+		`Published <span>1</span>`,
+		`Drafts <span>2</span>`,
+		"Live Post",
+		"Draft One",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected admin list to contain %q, got body: %s", expected, body)
+		}
+	}
+	if body := list("draft"); !strings.Contains(body, "Draft Two") || strings.Contains(body, "Live Post") {
+		t.Errorf("expected only drafts, got body: %s", body)
+	}
+	if body := list("published"); !strings.Contains(body, "Live Post") || strings.Contains(body, "Draft One") {
+		t.Errorf("expected only published posts, got body: %s", body)
+	}
+}
+
+func TestAdminListSearchAndSort(t *testing.T) {
+	server := newTestServer(t)
+	for i := range adminPageSize + 2 {
+		createTestPost(t, server, "post-"+strconv.Itoa(i), "Post "+strconv.Itoa(i), "Body.", true)
+	}
+	createTestPost(t, server, "aardvark-notes", "Zebra Crossing", "Body.", false)
+
+	list := func(query string) string {
+		w := httptest.NewRecorder()
+		server.HandleAdminList(w, httptest.NewRequest(http.MethodGet, "/admin/posts?"+query, nil))
+		return w.Body.String()
+	}
+
+	body := list("")
+	if !strings.Contains(body, "Page 1 of 2") || strings.Count(body, "/admin/edit/") != adminPageSize*2 {
+		t.Errorf("expected a first page of %d posts, got body: %s", adminPageSize, body)
+	}
+	if body := list("page=2"); strings.Count(body, "/admin/edit/") != 3*2 {
+		t.Errorf("expected 3 posts on the second page, got body: %s", body)
+	}
+
+	// The search matches titles and slugs, ignoring case.
+	for _, q := range []string{"zebra", "AARDVARK"} {
+		body := list("q=" + q)
+		if !strings.Contains(body, "Zebra Crossing") || strings.Contains(body, "Post 1") {
+			t.Errorf("expected search %q to find only the matching post, got body: %s", q, body)
+		}
+	}
+	if body := list("q=%25"); !strings.Contains(body, "No posts match") {
+		t.Errorf("expected %% to be searched for literally, got body: %s", body)
+	}
+	if body := list("status=published&q=zebra"); !strings.Contains(body, "No posts match") {
+		t.Errorf("expected search to respect the status filter, got body: %s", body)
+	}
+
+	body = list("sort=title")
+	if first, last := strings.Index(body, ">Post 19<"), strings.Index(body, ">Post 2<"); first < 0 || last < first {
+		t.Errorf("expected posts sorted by title, got body: %s", body)
+	}
+	if strings.Contains(body, "Zebra Crossing") {
+		t.Errorf("expected the last title on the second page, got body: %s", body)
+	}
+	if !strings.Contains(body, `sort=title&amp;page=2" rel="next"`) {
+		t.Errorf("expected the next page link to keep the sort, got body: %s", body)
+	}
+}
+
+func TestAdminDashboard(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "popular", "Popular Post", "Read me.", true)
+	createTestPost(t, server, "quiet", "Quiet Post", "Or me.", true)
+	createTestPost(t, server, "unfinished", "Unfinished Draft", "Soon.", false)
+	form := url.Values{"slug": {"queued"}, "title": {"Queued Post"}, "content": {"Later."}, "publish_at": {time.Now().Add(48 * time.Hour).Format("2006-01-02T15:04")}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/new", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.HandleAdminCreate(httptest.NewRecorder(), req)
+
+	view := func(slug string) {
+		req := httptest.NewRequest(http.MethodGet, "/post/"+slug, nil)
+		req.SetPathValue("slug", slug)
+		server.HandlePost(httptest.NewRecorder(), req)
+	}
+	view("popular")
+	view("popular")
+	view("popular")
+	view("quiet")
+	server.flushViews(context.Background())
+
+	w := httptest.NewRecorder()
+	server.HandleAdminDashboard(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	body := w.Body.String()
+	for _, expected := range []string{
+		"<strong>2</strong> published",
+		"<strong>2</strong> drafts",
+		"<strong>1</strong> scheduled",
+		"Queued Post",
+		"Unfinished Draft",
+		"3 views",
+		"1 view<",
+		"<strong>4</strong> views in 30 days",
+		`title="2 in the week of`,
+		`style="height: 100%"`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected dashboard to contain %q, got body: %s", expected, body)
+		}
+	}
+	if _, viewed, _ := strings.Cut(body, "Most viewed"); strings.Index(viewed, ">Popular Post<") > strings.Index(viewed, ">Quiet Post<") {
+		t.Error("expected the most viewed post listed first")
+	}
+}
+
+func TestScheduledPublishing(t *testing.T) {
+	server := newTestServer(t)
+	due := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	later := time.Now().Add(24 * time.Hour)
+	for slug, at := range map[string]time.Time{"due": due, "later": later} {
+		form := url.Values{"slug": {slug}, "title": {slug}, "content": {"Body."}, "publish_at": {at.Format("2006-01-02T15:04")}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/new", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		server.HandleAdminCreate(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleAdminList(w, httptest.NewRequest(http.MethodGet, "/admin/posts", nil))
+	if body := w.Body.String(); strings.Count(body, ">Scheduled</span>") != 2 {
+		t.Errorf("expected both posts shown as scheduled, got body: %s", body)
+	}
+
+	server.publishDue(context.Background())
+	q := dbgen.New(server.DB)
+	p, err := q.GetPostBySlug(context.Background(), "due")
+	if err != nil {
+		t.Fatalf("failed to get post: %v", err)
+	}
+	if p.Published != 1 || p.PublishAt != nil || !p.CreatedAt.Equal(due) {
+		t.Errorf("expected due post published and dated %v, got published=%d publish_at=%v created_at=%v", due, p.Published, p.PublishAt, p.CreatedAt)
+	}
+	p, err = q.GetPostBySlug(context.Background(), "later")
+	if err != nil {
+		t.Fatalf("failed to get post: %v", err)
+	}
+	if p.Published != 0 || p.PublishAt == nil {
+		t.Errorf("expected later post still scheduled, got published=%d publish_at=%v", p.Published, p.PublishAt)
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "draft", "First Title", "one\ntwo\nthree", true)
+	id := strconv.FormatInt(p.ID, 10)
+
+	update := func(title, content string) {
+		t.Helper()
+		form := url.Values{"title": {title}, "content": {content}, "published": {"on"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+id, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", id)
+		server.HandleAdminUpdate(httptest.NewRecorder(), req)
+	}
+	update("First Title", "one\ntwo\nthree")
+	update("Second Title", "one\n2\nthree")
+
+	q := dbgen.New(server.DB)
+	revisions, err := q.GetPostRevisions(context.Background(), p.ID)
+	if err != nil {
+		t.Fatalf("failed to get revisions: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].Title != "First Title" {
+		t.Fatalf("expected one revision for the one real change, got %+v", revisions)
+	}
+	rev := strconv.FormatInt(revisions[0].ID, 10)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/revisions/"+id, nil)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	server.HandleAdminRevisions(w, req)
+	if body := w.Body.String(); !strings.Contains(body, `href="/admin/revisions/`+id+"/"+rev+`"`) {
+		t.Errorf("expected history to link the revision, got body: %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/revisions/"+id+"/"+rev, nil)
+	req.SetPathValue("id", id)
+	req.SetPathValue("rev", rev)
+	w = httptest.NewRecorder()
+	server.HandleAdminRevision(w, req)
+	body := w.Body.String()
+	for _, expected := range []string{
+		`<span class="diff-equal">one</span>`,
+		`<span class="diff-delete">two</span>`,
+		`<span class="diff-insert">2</span>`,
+		`<span class="diff-insert">Second Title</span>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected diff to contain %q, got body: %s", expected, body)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/revisions/"+id+"/"+rev+"/restore", nil)
+	req.SetPathValue("id", id)
+	req.SetPathValue("rev", rev)
+	w = httptest.NewRecorder()
+	server.HandleAdminRevisionRestore(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected redirect after restoring, got %d", w.Code)
+	}
+	restored, err := q.GetPostByID(context.Background(), p.ID)
+	if err != nil {
+		t.Fatalf("failed to get post: %v", err)
+	}
+	if restored.Title != "First Title" || restored.Content != "one\ntwo\nthree" {
+		t.Errorf("expected revision restored, got %q %q", restored.Title, restored.Content)
+	}
+	if revisions, _ := q.GetPostRevisions(context.Background(), p.ID); len(revisions) != 2 || revisions[0].Title != "Second Title" {
+		t.Errorf("expected the replaced version kept as a revision, got %+v", revisions)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/revisions/"+id+"/999", nil)
+	req.SetPathValue("id", id)
+	req.SetPathValue("rev", "999")
+	w = httptest.NewRecorder()
+	server.HandleAdminRevision(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown revision to 404, got %d", w.Code)
+	}
+}
+
+func TestAdminBulk(t *testing.T) {
+	server := newTestServer(t)
+	a := createTestPost(t, server, "first", "First", "Body.", false)
+	b := createTestPost(t, server, "second", "Second", "Body.", false)
+	c := createTestPost(t, server, "third", "Third", "Body.", true)
+	bulk := func(form url.Values) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/bulk", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.HandleAdminBulk(w, req)
+		return w.Code
+	}
+	ids := func(posts ...dbgen.Post) []string {
+		var ids []string
+		for _, p := range posts {
+			ids = append(ids, strconv.FormatInt(p.ID, 10))
+		}
+		return ids
+	}
+	q := dbgen.New(server.DB)
+	ctx := context.Background()
+
+	if code := bulk(url.Values{"action": {"publish"}, "id": ids(a, b)}); code != http.StatusFound {
+		t.Fatalf("expected redirect after publishing, got %d", code)
+	}
+	if n, _ := q.CountPublishedPosts(ctx); n != 3 {
+		t.Errorf("expected 3 published posts, got %d", n)
+	}
+
+	bulk(url.Values{"action": {"tag"}, "tags": {"Travel, Wiki"}, "id": ids(a, c)})
+	for _, p := range []dbgen.Post{a, c} {
+		postTags, err := q.GetPostTags(ctx, p.ID)
+		if err != nil || tags.Join(postTags) != "Travel, Wiki" {
+			t.Errorf("expected post %d tagged Travel, Wiki, got %v (%v)", p.ID, postTags, err)
+		}
+	}
+
+	// An unknown post rolls back the whole action.
+	if code := bulk(url.Values{"action": {"unpublish"}, "id": append(ids(a, b), "9999")}); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown post, got %d", code)
+	}
+	if n, _ := q.CountPublishedPosts(ctx); n != 3 {
+		t.Errorf("expected nothing unpublished, got %d published posts", n)
+	}
+	if code := bulk(url.Values{"action": {"explode"}, "id": ids(a)}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown action, got %d", code)
+	}
+
+	bulk(url.Values{"action": {"delete"}, "id": ids(b, c)})
+	trashed, _ := q.GetTrashedPosts(ctx)
+	if len(trashed) != 2 {
+		t.Errorf("expected 2 posts in the trash, got %d", len(trashed))
+	}
+}
+
+func TestTrash(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "oops", "Oops", "Body.", true)
+	id := strconv.FormatInt(p.ID, 10)
+	post := func(handler http.HandlerFunc, path string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.SetPathValue("id", id)
+		handler(httptest.NewRecorder(), req)
+	}
+	getPost := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/post/oops", nil)
+		req.SetPathValue("slug", "oops")
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		return w.Code
+	}
+
+	post(server.HandleAdminDelete, "/admin/delete/"+id)
+	if code := getPost(); code != http.StatusNotFound {
+		t.Errorf("expected trashed post to 404, got %d", code)
+	}
+	w := httptest.NewRecorder()
+	server.HandleHome(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(w.Body.String(), "Oops") {
+		t.Errorf("expected trashed post hidden from home, got body: %s", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	server.HandleAdminList(w, httptest.NewRequest(http.MethodGet, "/admin/posts", nil))
+	if strings.Contains(w.Body.String(), "Oops") {
+		t.Errorf("expected trashed post hidden from admin list, got body: %s", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	server.HandleAdminTrash(w, httptest.NewRequest(http.MethodGet, "/admin/trash", nil))
+	if !strings.Contains(w.Body.String(), "Oops") {
+		t.Errorf("expected trashed post in trash, got body: %s", w.Body.String())
+	}
+
+	post(server.HandleAdminRestore, "/admin/trash/restore/"+id)
+	if code := getPost(); code != http.StatusOK {
+		t.Errorf("expected restored post to be back, got %d", code)
+	}
+
+	post(server.HandleAdminPurge, "/admin/trash/purge/"+id)
+	if _, err := dbgen.New(server.DB).GetPostByID(context.Background(), p.ID); err != nil {
+		t.Errorf("expected purge to spare a post not in the trash: %v", err)
+	}
+	post(server.HandleAdminDelete, "/admin/delete/"+id)
+	post(server.HandleAdminPurge, "/admin/trash/purge/"+id)
+	if _, err := dbgen.New(server.DB).GetPostByID(context.Background(), p.ID); err == nil {
+		t.Errorf("expected purged post to be gone")
+	}
+}
+
+func TestEditConflict(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "shared", "Shared", "line one\nline two", true)
+	id := strconv.FormatInt(p.ID, 10)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/edit/"+id, nil)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	server.HandleAdminEdit(w, req)
+	loaded := p.UpdatedAt.Format(time.RFC3339Nano)
+	if !strings.Contains(w.Body.String(), `name="updated_at" value="`+loaded+`"`) {
+		t.Fatalf("expected the form to carry the version, got body: %s", w.Body.String())
+	}
+
+	// Someone else saves first.
+	if _, err := server.DB.Exec("UPDATE posts SET content = 'line one\nline 2', updated_at = datetime('now', '+1 minute') WHERE id = ?", p.ID); err != nil {
+		t.Fatalf("failed to update post: %v", err)
+	}
+
+	save := func(version string) *httptest.ResponseRecorder {
+		form := url.Values{"title": {"Shared"}, "content": {"line one\nline two\nline three"}, "published": {"on"}, "updated_at": {version}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+id, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		server.HandleAdminUpdate(w, req)
+		return w
+	}
+	w = save(loaded)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected a conflict, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, expected := range []string{
+		`<span class="diff-delete">line 2</span>`,
+		`<span class="diff-insert">line two</span>`,
+		`<span class="diff-insert">line three</span>`,
+		"line one\nline two\nline three</textarea>",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected conflict page to contain %q, got body: %s", expected, body)
+		}
+	}
+	current, _ := dbgen.New(server.DB).GetPostByID(context.Background(), p.ID)
+	if current.Content != "line one\nline 2" {
+		t.Errorf("expected the other save kept, got %q", current.Content)
+	}
+
+	if w := save(current.UpdatedAt.Format(time.RFC3339Nano)); w.Code != http.StatusFound {
+		t.Errorf("expected saving over the current version to succeed, got %d", w.Code)
+	}
+}
+
+func TestAutosave(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "draft", "Draft", "Saved words.", false)
+	id := strconv.FormatInt(p.ID, 10)
+	autosave := func(id, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, "/admin/api/posts/"+id+"/autosave", strings.NewReader(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		server.HandleAutosaveAPI(w, req)
+		return w.Code
+	}
+	edit := func(query string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/admin/edit/"+id+query, nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		server.HandleAdminEdit(w, req)
+		return w.Body.String()
+	}
+
+	if code := autosave(id, `{"title": "Draft", "content": "Unsaved words.", "excerpt": ""}`); code != http.StatusOK {
+		t.Fatalf("expected autosave to succeed, got %d", code)
+	}
+	if code := autosave("9999", `{"title": "x"}`); code != http.StatusNotFound {
+		t.Errorf("expected 404 autosaving an unknown post, got %d", code)
+	}
+	if code := autosave(id, `not json`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed body, got %d", code)
+	}
+
+	body := edit("")
+	if !strings.Contains(body, "There are unsaved changes to this post") || !strings.Contains(body, ">Saved words.</textarea>") {
+		t.Errorf("expected the saved post with an offer to recover, got body: %s", body)
+	}
+	if body := edit("?recover=1"); !strings.Contains(body, ">Unsaved words.</textarea>") {
+		t.Errorf("expected the autosaved content in the form, got body: %s", body)
+	}
+
+	// Updating the post clears the autosave.
+	form := url.Values{"title": {"Draft"}, "content": {"Unsaved words."}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+id, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", id)
+	server.HandleAdminUpdate(httptest.NewRecorder(), req)
+	if body := edit(""); strings.Contains(body, "autosave-message") {
+		t.Errorf("expected no autosave after updating the post, got body: %s", body)
+	}
+
+	autosave(id, `{"title": "Draft", "content": "Changed again.", "excerpt": ""}`)
+	req = httptest.NewRequest(http.MethodDelete, "/admin/api/posts/"+id+"/autosave", nil)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	server.HandleAutosaveDiscardAPI(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 discarding the autosave, got %d", w.Code)
+	}
+	if body := edit(""); strings.Contains(body, "autosave-message") {
+		t.Errorf("expected no autosave after discarding it, got body: %s", body)
+	}
+}
+
+func TestSEOAnalysis(t *testing.T) {
+	server := newTestServer(t)
+	taken := createTestPost(t, server, "taken", "Taken", "Body.", true)
+	analyze := func(req analyzeRequest) map[string]string {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		server.HandleAnalyzeAPI(w, httptest.NewRequest(http.MethodPost, "/admin/api/posts/analyze", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var result struct{ Warnings []seoWarning }
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		checks := map[string]string{}
+		for _, warning := range result.Warnings {
+			checks[warning.Check] += warning.Message
+		}
+		return checks
+	}
+
+	good := analyzeRequest{
+		Slug:            "fresh",
+		Title:           "A short title",
+		Content:         "## Start\n\n" + strings.Repeat("word ", 400) + "\n\n![A harbour](/media/a.png)",
+		MetaDescription: "What the post is about.",
+	}
+	if checks := analyze(good); len(checks) != 0 {
+		t.Errorf("expected no warnings, got %v", checks)
+	}
+
+	bad := analyzeRequest{
+		Slug:            "taken",
+		Title:           strings.Repeat("Long ", 20),
+		Content:         strings.Repeat("word ", 400) + "\n\n![](/media/b.png)\n\n<img src=\"/media/c.png\">",
+		MetaDescription: "",
+		AllowHTML:       true,
+	}
+	checks := analyze(bad)
+	for check, want := range map[string]string{
+		"title":            "99 characters",
+		"slug":             "taken-2 is free",
+		"headings":         "400 words",
+		"alt_text":         "/media/b.png has no alt text.The image /media/c.png",
+		"meta_description": "no meta description",
+	} {
+		if !strings.Contains(checks[check], want) {
+			t.Errorf("expected a %s warning containing %q, got %q", check, want, checks[check])
+		}
+	}
+
+	// A post keeps its own slug, and a long description is too long.
+	bad.ID = taken.ID
+	bad.MetaDescription = strings.Repeat("x", 200)
+	checks = analyze(bad)
+	if _, ok := checks["slug"]; ok {
+		t.Errorf("expected no slug warning for the post's own slug, got %q", checks["slug"])
+	}
+	if !strings.Contains(checks["meta_description"], "200 characters") {
+		t.Errorf("expected the meta description reported as too long, got %q", checks["meta_description"])
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleAnalyzeAPI(w, httptest.NewRequest(http.MethodPost, "/admin/api/posts/analyze", strings.NewReader("not json")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed body, got %d", w.Code)
+	}
+}
+
+func TestPreviewLinks(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "draft", "Secret Draft", "Not ready yet.", false)
+	id := strconv.FormatInt(p.ID, 10)
+	preview := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetPathValue("token", strings.TrimPrefix(path, "/preview/"))
+		w := httptest.NewRecorder()
+		server.HandlePreview(w, req)
+		return w
+	}
+	links := func() []dbgen.PreviewLink {
+		t.Helper()
+		links, err := dbgen.New(server.DB).ListPreviewLinks(context.Background(), p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return links
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+id+"/previews", nil)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	server.HandleAdminPreviewCreate(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect after creating a preview link, got %d", w.Code)
+	}
+	created := links()
+	if len(created) != 1 {
+		t.Fatalf("expected one preview link, got %d", len(created))
+	}
+	link := created[0]
+	if days := time.Until(link.ExpiresAt).Hours() / 24; days < 6.9 || days > 7 {
+		t.Errorf("expected the link to last the default 7 days, got %.2f", days)
+	}
+
+	w = preview("/preview/" + link.Token)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "Secret Draft") || !strings.Contains(body, "Not ready yet.") {
+		t.Fatalf("expected the draft shown, got %d: %s", w.Code, body)
+	}
+	if !strings.Contains(body, `<meta name="robots" content="noindex">`) || strings.Contains(body, `rel="canonical"`) {
+		t.Error("expected the preview kept out of search engines")
+	}
+	if w := preview("/preview/wrong"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/edit/"+id, nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	server.HandleAdminEdit(w, req)
+	if !strings.Contains(w.Body.String(), "/preview/"+link.Token) {
+		t.Error("expected the editor to list the preview link")
+	}
+
+	// Expired links stop working.
+	if _, err := server.DB.Exec("UPDATE preview_links SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UTC(), link.ID); err != nil {
+		t.Fatal(err)
+	}
+	if w := preview("/preview/" + link.Token); w.Code != http.StatusGone {
+		t.Errorf("expected 410 for an expired link, got %d", w.Code)
+	}
+
+	linkID := strconv.FormatInt(link.ID, 10)
+	req = httptest.NewRequest(http.MethodPost, "/admin/edit/"+id+"/previews/"+linkID+"/revoke", nil)
+	req.SetPathValue("id", id)
+	req.SetPathValue("link", linkID)
+	server.HandleAdminPreviewRevoke(httptest.NewRecorder(), req)
+	if w := preview("/preview/" + link.Token); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a revoked link, got %d", w.Code)
+	}
+	if len(links()) != 0 {
+		t.Error("expected the revoked link deleted")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Setenv("DEV_MODE", "")
+	server := newTestServer(t)
+	ctx := context.Background()
+	var seen string
+	admin := server.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		seen = adminEmail(r)
+	})
+	visit := func(header string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		seen = ""
+		req := httptest.NewRequest(http.MethodGet, "/admin/posts?status=draft", nil)
+		if header != "" {
+			req.Header.Set("X-ExeDev-Email", header)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		admin(w, req)
+		return w
+	}
+	login := func(email, password, redirect string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"email": {email}, "password": {password}, "redirect": {redirect}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.HandleLoginSubmit(w, req)
+		return w
+	}
+
+	// Without accounts, the exe.dev header is trusted.
+	visit("proxy@example.com")
+	if seen != "proxy@example.com" {
+		t.Fatalf("expected the proxy's user let in, got %q", seen)
+	}
+
+	if err := server.SetPassword(ctx, "jo@example.com", "short"); err == nil {
+		t.Error("expected a short password rejected")
+	}
+	if err := server.SetPassword(ctx, "jo@example.com", "correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	w := visit("proxy@example.com")
+	if seen != "" || w.Code != http.StatusFound || w.Header().Get("Location") != "/login?redirect=%2Fadmin%2Fposts%3Fstatus%3Ddraft" {
+		t.Errorf("expected the header ignored once an account exists, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := login("jo@example.com", "wrong password", "/admin"); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Errorf("expected a wrong password refused, got %d", w.Code)
+	}
+	if w := login("nobody@example.com", "correct horse battery", "/admin"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown account refused, got %d", w.Code)
+	}
+	if w := login("jo@example.com", "correct horse battery", "//evil.example/"); w.Header().Get("Location") != "/admin" {
+		t.Errorf("expected a redirect off the site replaced, got %q", w.Header().Get("Location"))
+	}
+	w = login("JO@example.com", "correct horse battery", "/admin/posts")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/posts" {
+		t.Fatalf("expected a redirect after signing in, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	cookie := w.Result().Cookies()[0]
+	if cookie.Name != sessionCookie || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("unexpected session cookie: %+v", cookie)
+	}
+
+	visit("other@example.com", cookie)
+	if seen != "jo@example.com" {
+		t.Errorf("expected the session's user, not the header's, got %q", seen)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(cookie)
+	server.HandleLogout(httptest.NewRecorder(), req)
+	if visit("", cookie); seen != "" {
+		t.Error("expected the session ended by signing out")
+	}
+
+	// Changing the password ends every session.
+	cookie = login("jo@example.com", "correct horse battery", "/admin").Result().Cookies()[0]
+	if err := server.SetPassword(ctx, "jo@example.com", "another long password"); err != nil {
+		t.Fatal(err)
+	}
+	if visit("", cookie); seen != "" {
+		t.Error("expected the session ended by changing the password")
+	}
+}
+
+func TestTwoFactor(t *testing.T) {
+	t.Setenv("DEV_MODE", "")
+	server := newTestServer(t)
+	if err := server.SetPassword(context.Background(), "jo@example.com", "correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	post := func(handler http.HandlerFunc, path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		if form == nil {
+			form = url.Values{}
+		}
+		form.Set(csrfField, "token")
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "token"})
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	login := func() *httptest.ResponseRecorder {
+		t.Helper()
+		return post(server.HandleLoginSubmit, "/login", url.Values{"email": {"jo@example.com"}, "password": {"correct horse battery"}})
+	}
+	challenge := regexp.MustCompile(`name="challenge" value="(\w+)"`)
+	code := func(token, code string) *httptest.ResponseRecorder {
+		t.Helper()
+		return post(server.HandleLoginCode, "/login/code", url.Values{"challenge": {token}, "code": {code}, "redirect": {"/admin/posts"}})
+	}
+
+	session := login().Result().Cookies()[0]
+	w := post(server.requireAdmin(server.HandleAdminTOTPSetup), "/admin/account/totp/setup", nil, session)
+	m := regexp.MustCompile(`name="secret" value="(\w+)"`).FindStringSubmatch(w.Body.String())
+	if m == nil || !strings.Contains(w.Body.String(), "<svg") {
+		t.Fatalf("expected a secret and QR code, got body: %s", w.Body)
+	}
+	secret := m[1]
+	enable := server.requireAdmin(server.HandleAdminTOTPEnable)
+	if w := post(enable, "/admin/account/totp", url.Values{"secret": {secret}, "code": {"000000"}}, session); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a wrong code refused, got %d", w.Code)
+	}
+	step := totp.StepAt(time.Now())
+	current, _ := totp.Code(secret, step)
+	w = post(enable, "/admin/account/totp", url.Values{"secret": {secret}, "code": {current}}, session)
+	backup := regexp.MustCompile(`<code>([a-z2-7]{5}-[a-z2-7]{5})</code>`).FindAllStringSubmatch(w.Body.String(), -1)
+	if len(backup) != backupCodeCount {
+		t.Fatalf("expected %d backup codes, got body: %s", backupCodeCount, w.Body)
+	}
+
+	// The password alone no longer signs in.
+	w = login()
+	if len(w.Result().Cookies()) != 0 || !challenge.MatchString(w.Body.String()) {
+		t.Fatalf("expected to be asked for a code, got body: %s", w.Body)
+	}
+	token := challenge.FindStringSubmatch(w.Body.String())[1]
+	if w := code(token, current); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a code used already refused, got %d", w.Code)
+	}
+	w = code(token, strings.ToUpper(backup[0][1]))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/posts" || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("expected a backup code to sign in, got %d", w.Code)
+	}
+	if w := code(token, backup[1][1]); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a finished sign-in not to be reusable, got %d", w.Code)
+	}
+
+	token = challenge.FindStringSubmatch(login().Body.String())[1]
+	if w := code(token, backup[0][1]); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a used backup code refused, got %d", w.Code)
+	}
+	next, _ := totp.Code(secret, step+1)
+	if w := code(token, next); w.Code != http.StatusSeeOther {
+		t.Errorf("expected the next authenticator code to sign in, got %d", w.Code)
+	}
+
+	// Too many wrong codes end the sign-in.
+	token = challenge.FindStringSubmatch(login().Body.String())[1]
+	for range maxCodeAttempts {
+		code(token, "000000")
+	}
+	if w := code(token, backup[2][1]); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "expired") {
+		t.Errorf("expected the sign-in ended after %d wrong codes, got %d", maxCodeAttempts, w.Code)
+	}
+
+	disable := server.requireAdmin(server.HandleAdminTOTPDisable)
+	if w := post(disable, "/admin/account/totp/disable", url.Values{"code": {"000000"}}, session); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected turning off refused without a code, got %d", w.Code)
+	}
+	post(disable, "/admin/account/totp/disable", url.Values{"code": {backup[3][1]}}, session)
+	if w := login(); len(w.Result().Cookies()) != 1 {
+		t.Error("expected the password alone to sign in after turning two-factor off")
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	t.Setenv("DEV_MODE", "1")
+	server := newTestServer(t)
+	var reached bool
+	handler := server.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.Write([]byte(csrfToken(r)))
+	})
+	send := func(method, body, contentType string, header http.Header, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		reached = false
+		req := httptest.NewRequest(method, "/admin/settings", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	// Viewing a page hands out a token, in a cookie and to the page.
+	w := send(http.MethodGet, "", "", nil)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookie || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected a CSRF cookie, got %+v", cookies)
+	}
+	cookie := cookies[0]
+	if w.Body.String() != cookie.Value {
+		t.Errorf("expected the page given the cookie's token, got %q", w.Body.String())
+	}
+	if w := send(http.MethodGet, "", "", nil, cookie); len(w.Result().Cookies()) != 0 || w.Body.String() != cookie.Value {
+		t.Error("expected the token kept across pages")
+	}
+
+	form := "application/x-www-form-urlencoded"
+	tests := []struct {
+		name    string
+		body    string
+		ctype   string
+		header  http.Header
+		cookies []*http.Cookie
+		ok      bool
+	}{
+		{"cross-site form", "title=x", form, nil, []*http.Cookie{cookie}, false},
+		{"form with token", "title=x&csrf_token=" + cookie.Value, form, nil, []*http.Cookie{cookie}, true},
+		{"form with wrong token", "csrf_token=wrong", form, nil, []*http.Cookie{cookie}, false},
+		{"form without cookie", "csrf_token=" + cookie.Value, form, nil, nil, false},
+		{"script with header", `{"title":"x"}`, "application/json", http.Header{"X-Csrf-Token": {cookie.Value}}, []*http.Cookie{cookie}, true},
+		{"script without header", `{"title":"x"}`, "application/json", nil, []*http.Cookie{cookie}, false},
+		{"multipart field ignored", "csrf_token=" + cookie.Value, "multipart/form-data; boundary=x", nil, []*http.Cookie{cookie}, false},
+	}
+	for _, tt := range tests {
+		w := send(http.MethodPost, tt.body, tt.ctype, tt.header, tt.cookies...)
+		if reached != tt.ok {
+			t.Errorf("%s: reached handler = %v, expected %v", tt.name, reached, tt.ok)
+		}
+		if !tt.ok && w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", tt.name, w.Code)
+		}
+	}
+
+	// Signing in is protected too, so no one can be signed in as someone
+	// else without knowing.
+	login := server.requireCSRF(server.HandleLoginSubmit)
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("email=jo%40example.com&password=x"))
+	req.Header.Set("Content-Type", form)
+	w = httptest.NewRecorder()
+	login(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected a login without a token refused, got %d", w.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remote    string
+		forwarded []string
+		ip        string
+		key       string
+	}{
+		{"192.0.2.1:1234", nil, "192.0.2.1", "192.0.2.1"},
+		{"192.0.2.1:1234", []string{"198.51.100.7"}, "192.0.2.1", "192.0.2.1"},
+		{"127.0.0.1:1234", []string{"198.51.100.7"}, "198.51.100.7", "198.51.100.7"},
+		{"127.0.0.1:1234", []string{"10.0.0.1, 198.51.100.7"}, "198.51.100.7", "198.51.100.7"},
+		{"[::1]:1234", []string{"10.0.0.1", "2001:db8:1:2:3:4:5:6"}, "2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		{"[::ffff:192.0.2.1]:1234", nil, "192.0.2.1", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/login", nil)
+		req.RemoteAddr = tt.remote
+		for _, f := range tt.forwarded {
+			req.Header.Add("X-Forwarded-For", f)
+		}
+		if ip := clientIP(req).String(); ip != tt.ip {
+			t.Errorf("clientIP(%s, %q) = %s, expected %s", tt.remote, tt.forwarded, ip, tt.ip)
+		}
+		if key := throttleKey(req); key != tt.key {
+			t.Errorf("throttleKey(%s, %q) = %s, expected %s", tt.remote, tt.forwarded, key, tt.key)
+		}
+	}
+}
+
+func TestLockoutAfter(t *testing.T) {
+	tests := []struct {
+		failures int64
+		expected time.Duration
+	}{
+		{1, 0},
+		{freeLoginFailures - 1, 0},
+		{freeLoginFailures, time.Minute},
+		{freeLoginFailures + 1, 2 * time.Minute},
+		{freeLoginFailures + 3, 8 * time.Minute},
+		{freeLoginFailures + 20, maxLockout},
+		{1000, maxLockout},
+	}
+	for _, tt := range tests {
+		if got := lockoutAfter(tt.failures); got != tt.expected {
+			t.Errorf("lockoutAfter(%d) = %v, expected %v", tt.failures, got, tt.expected)
+		}
+	}
+}
+
+func TestRenderStatusFailure(t *testing.T) {
+	server := newTestServer(t)
+	if _, err := server.templates.New("broken.html").Parse(`<p>Half a page</p>{{call .Fail}}`); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	server.renderStatus(w, http.StatusUnauthorized, "broken.html", map[string]any{
+		"Fail": func() (string, error) { return "", errors.New("broken") },
+	})
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "Half a page") {
+		t.Errorf("expected a plain 500, got %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	server.renderStatus(w, http.StatusUnauthorized, "login.html", map[string]any{"Error": "Wrong password"})
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Wrong password") || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("expected the page with its status, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	server := newTestServer(t)
+	if err := server.SetPassword(context.Background(), "jo@example.com", "correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	login := server.throttleLogin(server.HandleLoginSubmit)
+	attempt := func(remote, password string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"email": {"jo@example.com"}, "password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		login(w, req)
+		return w
+	}
+
+	for i := range freeLoginFailures {
+		if w := attempt("192.0.2.1:1234", "wrong password"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, w.Code)
+		}
+	}
+	w := attempt("192.0.2.1:1234", "correct horse battery")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected the address locked out, got %d", w.Code)
+	}
+	if w := attempt("198.51.100.7:1234", "correct horse battery"); w.Code != http.StatusSeeOther {
+		t.Errorf("expected another address let in, got %d", w.Code)
+	}
+
+	// The admin can lift the lockout, and signing in forgets the failures.
+	req := httptest.NewRequest(http.MethodPost, "/admin/bans/unlock", strings.NewReader("ip=192.0.2.1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.HandleAdminUnlock(httptest.NewRecorder(), req)
+	if w := attempt("192.0.2.1:1234", "correct horse battery"); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected the address let in once unlocked, got %d", w.Code)
+	}
+	attempt("192.0.2.1:1234", "wrong password")
+	if failure, err := dbgen.New(server.DB).GetLoginFailure(context.Background(), "192.0.2.1"); err != nil || failure.Failures != 1 || failure.LockedUntil != nil {
+		t.Errorf("expected counting to start again after signing in, got %+v, %v", failure, err)
+	}
+}
+
+func TestIPBans(t *testing.T) {
+	t.Setenv("DEV_MODE", "1")
+	server := newTestServer(t)
+	ban := func(prefix string) string {
+		t.Helper()
+		form := url.Values{"prefix": {prefix}, "reason": {"spam"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/bans", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "198.51.100.7:1234"
+		w := httptest.NewRecorder()
+		server.HandleAdminBanCreate(w, req)
+		return w.Body.String()
+	}
+	admin := server.requireAdmin(func(w http.ResponseWriter, r *http.Request) {})
+	visit := func(remote string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		admin(w, req)
+		return w.Code
+	}
+
+	if body := ban("not an address"); !strings.Contains(body, "Enter an address") {
+		t.Errorf("expected a bad address refused, got body: %s", body)
+	}
+	if body := ban("198.51.100.0/24"); !strings.Contains(body, "your own address") {
+		t.Errorf("expected banning yourself refused, got body: %s", body)
+	}
+	ban("192.0.2.99/24")
+	ban("2001:db8::1")
+	bans, err := dbgen.New(server.DB).ListIPBans(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 2 || bans[1].Prefix != "192.0.2.0/24" || bans[0].Prefix != "2001:db8::1/128" {
+		t.Fatalf("unexpected bans: %+v", bans)
+	}
+
+	for remote, code := range map[string]int{
+		"192.0.2.1:1234":     http.StatusForbidden,
+		"[2001:db8::1]:1234": http.StatusForbidden,
+		"[2001:db8::2]:1234": http.StatusOK,
+		"198.51.100.7:1234":  http.StatusOK,
+	} {
+		if got := visit(remote); got != code {
+			t.Errorf("visit from %s: got %d, expected %d", remote, got, code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/bans/delete/"+strconv.FormatInt(bans[1].ID, 10), nil)
+	req.SetPathValue("id", strconv.FormatInt(bans[1].ID, 10))
+	server.HandleAdminBanDelete(httptest.NewRecorder(), req)
+	if got := visit("192.0.2.1:1234"); got != http.StatusOK {
+		t.Errorf("expected a lifted ban to let the address in, got %d", got)
+	}
+}
+
+func TestPostsAPI(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "hello", "Hello", "First post.", true)
+	createTestPost(t, server, "secret", "Secret", "A draft.", false)
+
+	call := func(handler http.HandlerFunc, method, path, slug, body string) (*httptest.ResponseRecorder, apiPost) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if slug != "" {
+			req.SetPathValue("slug", slug)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		var post apiPost
+		if w.Code == http.StatusOK || w.Code == http.StatusCreated {
+			json.Unmarshal(w.Body.Bytes(), &post)
+		}
+		return w, post
+	}
+
+	w := httptest.NewRecorder()
+	server.HandlePostsAPI(w, httptest.NewRequest(http.MethodGet, "/api/v1/posts", nil))
+	var list struct {
+		Posts []apiPost `json:"posts"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Posts) != 1 || list.Posts[0].Slug != "hello" {
+		t.Errorf("expected only the published post listed, got %+v", list)
+	}
+	if w, _ := call(server.HandlePostAPI, http.MethodGet, "/api/v1/posts/secret", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected a draft hidden, got %d", w.Code)
+	}
+
+	w, post := call(server.HandleCreatePostAPI, http.MethodPost, "/api/v1/posts", "", `{"title": "From the API", "content": "Hello *there*.", "tags": ["Go", "api"]}`)
+	if w.Code != http.StatusCreated || post.Slug != "from-the-api" || post.Published || w.Header().Get("Location") != "/api/v1/posts/from-the-api" {
+		t.Fatalf("expected a draft created, got %d: %s", w.Code, w.Body)
+	}
+	if len(post.Tags) != 2 || post.Tags[0] != "api" || post.Tags[1] != "Go" {
+		t.Errorf("expected the tags set, got %q", post.Tags)
+	}
+	if w, _ := call(server.HandleCreatePostAPI, http.MethodPost, "/api/v1/posts", "", `{"title": "Taken", "slug": "hello"}`); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "hello-2 is free") {
+		t.Errorf("expected a taken slug refused, got %d: %s", w.Code, w.Body)
+	}
+	if w, post := call(server.HandleCreatePostAPI, http.MethodPost, "/api/v1/posts", "", `{"title": "Taken", "slug": "hello", "number_slug": true}`); w.Code != http.StatusCreated || post.Slug != "hello-2" {
+		t.Errorf("expected a taken slug numbered, got %d: %s", w.Code, w.Body)
+	}
+	if w, _ := call(server.HandleCreatePostAPI, http.MethodPost, "/api/v1/posts", "", `{"titel": "Typo"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown field refused, got %d", w.Code)
+	}
+
+	// Fields left out of an update are kept.
+	w, updated := call(server.HandleUpdatePostAPI, http.MethodPatch, "/api/v1/posts/from-the-api", "from-the-api", `{"slug": "renamed", "excerpt": "Short."}`)
+	if w.Code != http.StatusOK || updated.Slug != "renamed" || updated.Title != "From the API" || updated.Content != "Hello *there*." || updated.Excerpt != "Short." || len(updated.Tags) != 2 {
+		t.Fatalf("unexpected update: %d: %s", w.Code, w.Body)
+	}
+	if w, _ := call(server.HandleUpdatePostAPI, http.MethodPatch, "/api/v1/posts/renamed", "renamed", `{"number_slug": true}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected number_slug refused on an update, got %d", w.Code)
+	}
+	stale := `{"title": "Stale", "updated_at": "` + post.UpdatedAt.Add(-time.Hour).Format(time.RFC3339Nano) + `"}`
+	if w, _ := call(server.HandleUpdatePostAPI, http.MethodPatch, "/api/v1/posts/renamed", "renamed", stale); w.Code != http.StatusConflict {
+		t.Errorf("expected a stale update refused, got %d", w.Code)
+	}
+	if target, err := dbgen.New(server.DB).GetRedirect(context.Background(), "from-the-api"); err != nil || target != "renamed" {
+		t.Errorf("expected the old slug redirected, got %q, %v", target, err)
+	}
+
+	w, published := call(server.HandlePublishPostAPI, http.MethodPost, "/api/v1/posts/renamed/publish", "renamed", "")
+	if w.Code != http.StatusOK || !published.Published {
+		t.Errorf("expected the post published, got %d: %s", w.Code, w.Body)
+	}
+	if w, post := call(server.HandlePostAPI, http.MethodGet, "/api/v1/posts/renamed", "renamed", ""); w.Code != http.StatusOK || post.URL != "http://example.com/post/renamed" {
+		t.Errorf("expected the published post found, got %d: %s", w.Code, w.Body)
+	}
+	if _, post := call(server.HandlePublishPostAPI, http.MethodPost, "/api/v1/posts/renamed/unpublish", "renamed", ""); post.Published {
+		t.Error("expected the post unpublished")
+	}
+
+	if w, _ := call(server.HandleDeletePostAPI, http.MethodDelete, "/api/v1/posts/renamed", "renamed", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 from delete, got %d", w.Code)
+	}
+	if w, _ := call(server.HandleDeletePostAPI, http.MethodDelete, "/api/v1/posts/renamed", "renamed", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected a trashed post not found, got %d", w.Code)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	t.Setenv("DEV_MODE", "")
+	server := newTestServer(t)
+	createTestPost(t, server, "draft", "Draft", "Not yet.", false)
+
+	newToken := func(name, scope string) string {
+		t.Helper()
+		form := url.Values{"name": {name}, "scope": {scope}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/tokens", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.HandleAdminTokenCreate(w, req)
+		m := regexp.MustCompile(`<p><code>(\w+)</code></p>`).FindStringSubmatch(w.Body.String())
+		if m == nil {
+			t.Fatalf("expected the new token shown, got body: %s", w.Body)
+		}
+		return m[1]
+	}
+	reader, writer := newToken("reader", scopeRead), newToken("bot", scopeWrite)
+
+	create := server.requireWriteToken(server.HandleCreatePostAPI)
+	get := server.allowToken(server.HandlePostAPI)
+	call := func(handler http.HandlerFunc, method, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/posts/draft", strings.NewReader(body))
+		req.SetPathValue("slug", "draft")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	if w := call(get, http.MethodGet, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected a draft hidden without a token, got %d", w.Code)
+	}
+	if w := call(get, http.MethodGet, reader, ""); w.Code != http.StatusOK {
+		t.Errorf("expected a draft shown with a read token, got %d", w.Code)
+	}
+	if w := call(get, http.MethodGet, "wrong", ""); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected an unknown token refused, got %d", w.Code)
+	}
+
+	body := `{"title": "By token"}`
+	if w := call(create, http.MethodPost, "", body); w.Code != http.StatusFound {
+		t.Errorf("expected a request without a token sent to sign in, got %d", w.Code)
+	}
+	if w := call(create, http.MethodPost, reader, body); w.Code != http.StatusForbidden {
+		t.Errorf("expected a read token refused for writing, got %d", w.Code)
+	}
+	if w := call(create, http.MethodPost, writer, body); w.Code != http.StatusCreated {
+		t.Errorf("expected a write token to create a post without a CSRF token, got %d: %s", w.Code, w.Body)
+	}
+
+	tokens, err := dbgen.New(server.DB).ListAPITokens(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.TokenHash == writer || token.TokenHash == reader {
+			t.Error("expected tokens stored hashed")
+		}
+		if token.LastUsedAt == nil {
+			t.Errorf("expected %s marked used", token.Name)
+		}
+		if token.Name == "bot" {
+			req := httptest.NewRequest(http.MethodPost, "/admin/tokens/revoke/"+strconv.FormatInt(token.ID, 10), nil)
+			req.SetPathValue("id", strconv.FormatInt(token.ID, 10))
+			server.HandleAdminTokenRevoke(httptest.NewRecorder(), req)
+		}
+	}
+	if w := call(create, http.MethodPost, writer, body); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a revoked token refused, got %d", w.Code)
+	}
+}
+
+func TestPostsAPIList(t *testing.T) {
+	server := newTestServer(t)
+	for i := range 5 {
+		createTestPost(t, server, fmt.Sprintf("post-%d", i), fmt.Sprintf("Post %d", i), "Body.", i != 2)
+	}
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	for _, slug := range []string{"post-1", "post-2"} {
+		p, err := q.GetPostBySlug(ctx, slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tags.Set(ctx, q, p.ID, []string{"Go Lang"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := server.DB.Exec("UPDATE posts SET updated_at = '2020-01-01 00:00:00' WHERE slug != 'post-3'"); err != nil {
+		t.Fatal(err)
+	}
+	token := "reader-token"
+	if err := q.CreateAPIToken(ctx, dbgen.CreateAPITokenParams{Name: "reader", TokenHash: hashToken(token), Scope: scopeRead}); err != nil {
+		t.Fatal(err)
+	}
+
+	type page struct {
+		Posts      []apiPost `json:"posts"`
+		NextCursor string    `json:"next_cursor"`
+	}
+	list := func(query string, withToken bool) (int, page, []string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/posts?"+query, nil)
+		if withToken {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.allowToken(server.HandlePostsAPI)(w, req)
+		var body page
+		json.Unmarshal(w.Body.Bytes(), &body)
+		var slugs []string
+		for _, p := range body.Posts {
+			slugs = append(slugs, p.Slug)
+		}
+		return w.Code, body, slugs
+	}
+
+	// Posts made in the same second are paged through by ID.
+	var all []string
+	cursor := ""
+	for range 3 {
+		code, body, slugs := list("limit=2&cursor="+cursor, false)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		all = append(all, slugs...)
+		if cursor = body.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if strings.Join(all, " ") != "post-4 post-3 post-1 post-0" {
+		t.Errorf("expected every published post once, newest first, got %q", all)
+	}
+
+	tests := []struct {
+		query     string
+		withToken bool
+		code      int
+		slugs     string
+	}{
+		{"tag=go-lang", false, http.StatusOK, "post-1"},
+		{"tag=Go+Lang&status=all", true, http.StatusOK, "post-2 post-1"},
+		{"status=draft", true, http.StatusOK, "post-2"},
+		{"status=draft", false, http.StatusUnauthorized, ""},
+		{"since=2024-01-01T00:00:00Z", false, http.StatusOK, "post-3"},
+		{"since=2019-12-31T23:00:00-02:00", false, http.StatusOK, "post-3"},
+		{"since=yesterday", false, http.StatusBadRequest, ""},
+		{"limit=0", false, http.StatusBadRequest, ""},
+		{"cursor=nonsense", false, http.StatusBadRequest, ""},
+		{"status=deleted", true, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		code, _, slugs := list(tt.query, tt.withToken)
+		if code != tt.code || strings.Join(slugs, " ") != tt.slugs {
+			t.Errorf("%s: got %d %q, expected %d %q", tt.query, code, slugs, tt.code, tt.slugs)
+		}
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "cached", "Cached", "Body.", true)
+
+	get := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		req.SetPathValue("slug", "cached")
+		w := httptest.NewRecorder()
+		if strings.HasPrefix(path, "/api/") {
+			server.HandlePostAPI(w, req)
+		} else {
+			server.HandlePost(w, req)
+		}
+		return w
+	}
+
+	for _, path := range []string{"/post/cached", "/api/v1/posts/cached"} {
+		w := get(path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, w.Code)
+		}
+		etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+		if etag == "" || lastModified != p.UpdatedAt.UTC().Format(http.TimeFormat) {
+			t.Fatalf("%s: got ETag %q, Last-Modified %q", path, etag, lastModified)
+		}
+
+		if w := get(path, map[string]string{"If-None-Match": `"other", ` + etag}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty 304 for a matching ETag, got %d", path, w.Code)
+		}
+		if w := get(path, map[string]string{"If-None-Match": `"other"`}); w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 for another ETag, got %d", path, w.Code)
+		}
+		if w := get(path, map[string]string{"If-Modified-Since": lastModified}); w.Code != http.StatusNotModified {
+			t.Errorf("%s: expected 304 since Last-Modified, got %d", path, w.Code)
+		}
+		earlier := p.UpdatedAt.Add(-time.Hour).UTC().Format(http.TimeFormat)
+		if w := get(path, map[string]string{"If-Modified-Since": earlier}); w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 when changed since, got %d", path, w.Code)
+		}
+		// If-None-Match wins over If-Modified-Since.
+		if w := get(path, map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}); w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 when the ETag differs, got %d", path, w.Code)
+		}
+	}
+
+	// A change to the post changes its validators.
+	old := get("/post/cached", nil).Header().Get("ETag")
+	if _, err := server.DB.Exec("UPDATE posts SET updated_at = ? WHERE id = ?", p.UpdatedAt.Add(time.Minute).UTC().Format(time.DateTime), p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if w := get("/post/cached", map[string]string{"If-None-Match": old}); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after the post changed, got %d", w.Code)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "described", "Described", "Body.", true)
+
+	w := httptest.NewRecorder()
+	server.HandleOpenAPI(w, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths map[string]map[string]struct {
+			OperationID string           `json:"operationId"`
+			Parameters  []map[string]any `json:"parameters"`
+			Security    []map[string]any `json:"security"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+				Required   []string       `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || len(doc.Servers) != 1 || doc.Servers[0].URL != "http://example.com" {
+		t.Fatalf("unexpected document header: %q %v", doc.OpenAPI, doc.Servers)
+	}
+
+	// Every registered endpoint is described.
+	for _, route := range server.apiRoutes() {
+		method, path, _ := strings.Cut(route.pattern, " ")
+		op, ok := doc.Paths[path][strings.ToLower(method)]
+		if !ok {
+			t.Errorf("%s is not described", route.pattern)
+			continue
+		}
+		if op.OperationID != route.operationID {
+			t.Errorf("%s: got operation %q", route.pattern, op.OperationID)
+		}
+		if (route.auth == authNone) != (op.Security == nil) {
+			t.Errorf("%s: got security %v", route.pattern, op.Security)
+		}
+	}
+	patch := doc.Paths["/api/v1/posts/{slug}"]["patch"]
+	if len(patch.Parameters) != 1 || patch.Parameters[0]["name"] != "slug" || patch.Parameters[0]["in"] != "path" {
+		t.Errorf("unexpected PATCH parameters: %v", patch.Parameters)
+	}
+
+	// The post schema has the fields the API returns.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/posts/described", nil)
+	req.SetPathValue("slug", "described")
+	server.HandlePostAPI(rec, req)
+	var post map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &post); err != nil {
+		t.Fatal(err)
+	}
+	schema := doc.Components.Schemas["Post"]
+	for field := range post {
+		if _, ok := schema.Properties[field]; !ok {
+			t.Errorf("Post schema is missing %q", field)
+		}
+	}
+	if !slices.Contains(schema.Required, "title") || slices.Contains(schema.Required, "publish_at") {
+		t.Errorf("unexpected required fields: %v", schema.Required)
+	}
+	if input, ok := doc.Components.Schemas["PostInput"]; !ok || input.Properties["number_slug"] != nil {
+		t.Error("expected a PostInput schema without number_slug")
+	}
+	if create, ok := doc.Components.Schemas["PostCreate"]; !ok || create.Properties["title"] == nil || create.Properties["number_slug"] == nil {
+		t.Error("expected a PostCreate schema with the input fields and number_slug")
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	for i := 1; i <= 3; i++ {
+		p := createTestPost(t, server, fmt.Sprintf("post-%d", i), fmt.Sprintf("Post %d", i), "Some *graph* words.", true)
+		if err := tags.Set(ctx, q, p.ID, []string{"Graphs"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	draft := createTestPost(t, server, "draft", "Draft", "Secret graph.", false)
+	tags.Set(ctx, q, draft.ID, []string{"Graphs"})
+
+	query := func(method, body string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if method == http.MethodGet {
+			req = httptest.NewRequest(method, "/api/graphql?"+body, nil)
+		} else {
+			req = httptest.NewRequest(method, "/api/graphql", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		server.HandleGraphQL(w, req)
+		return w
+	}
+
+	w := query(http.MethodPost, `{"query": "query($slug: String!) { post(slug: $slug) { title html url tags { name postCount } } draft: post(slug: \"draft\") { title } }", "variables": {"slug": "post-2"}}`)
+	expected := `{"data":{"post":{"title":"Post 2","html":"\u003cp\u003eSome \u003cem\u003egraph\u003c/em\u003e words.\u003c/p\u003e\n","url":"http://example.com/post/post-2","tags":[{"name":"Graphs","postCount":3}]},"draft":null}}`
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != expected {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+
+	// Posts come a page at a time, whether listed directly or by tag.
+	var page struct {
+		Data struct {
+			Posts struct {
+				Posts      []struct{ Slug string }
+				NextCursor *string
+			}
+			Tag struct {
+				Posts struct {
+					Posts []struct{ Slug string }
+				}
+			}
+		}
+		Errors []graphql.Error
+	}
+	w = query(http.MethodGet, url.Values{"query": {`{ posts(first: 2) { posts { slug } nextCursor } tag(slug: "graphs") { posts { posts { slug } } } }`}}.Encode())
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Errors) > 0 || len(page.Data.Posts.Posts) != 2 || page.Data.Posts.NextCursor == nil || len(page.Data.Tag.Posts.Posts) != 3 {
+		t.Fatalf("unexpected first page: %s", w.Body.String())
+	}
+	w = query(http.MethodPost, fmt.Sprintf(`{"query": "{ posts(after: \"%s\") { posts { slug } nextCursor } }"}`, *page.Data.Posts.NextCursor))
+	if body := strings.TrimSpace(w.Body.String()); body != `{"data":{"posts":{"posts":[{"slug":"post-1"}],"nextCursor":null}}}` {
+		t.Errorf("unexpected last page: %s", body)
+	}
+
+	w = query(http.MethodPost, `{"query": "{ search(query: \"graph\") { slug post { id } } tags { slug postCount } }"}`)
+	if body := w.Body.String(); !strings.Contains(body, `"slug":"post-1","post":{"id":"`) || !strings.Contains(body, `"tags":[{"slug":"graphs","postCount":3}]`) || strings.Contains(body, "draft") {
+		t.Errorf("unexpected search: %s", body)
+	}
+
+	w = query(http.MethodPost, `{"query": "{ post(slug: \"post-1\") { tags { posts { posts { tags { posts { posts { title } } } } } } } }"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "nested more than") {
+		t.Errorf("expected deep queries to be refused, got %s", w.Body.String())
+	}
+	if w := query(http.MethodPost, `{"query": ""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a query, got %d", w.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	server.HandleGraphQLSchema(rec, httptest.NewRequest(http.MethodGet, "/api/graphql/schema.graphql", nil))
+	if !strings.Contains(rec.Body.String(), "  posts(first: Int = 20, after: String, tag: String): PostPage!\n") {
+		t.Errorf("unexpected schema:\n%s", rec.Body.String())
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	t.Setenv("DEV_MODE", "1")
+	server := newTestServer(t)
+	defer func(retries []time.Duration) { webhookRetries = retries }(webhookRetries)
+	webhookRetries = []time.Duration{0}
+
+	type delivery struct {
+		event string
+		body  webhookPayload
+	}
+	var received []delivery
+	failing := true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("hook-secret"))
+		mac.Write([]byte(r.Header.Get("X-Webhook-Timestamp") + "."))
+		mac.Write(body)
+		if r.Header.Get("X-Webhook-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("bad signature %q", r.Header.Get("X-Webhook-Signature"))
+		}
+		var d delivery
+		d.event = r.Header.Get("X-Webhook-Event")
+		if err := json.Unmarshal(body, &d.body); err != nil {
+			t.Errorf("bad payload %s: %v", body, err)
+		}
+		received = append(received, d)
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"})
+	if err := q.CreateWebhook(ctx, dbgen.CreateWebhookParams{Url: receiver.URL, Secret: "hook-secret", Events: "post.published,post.deleted"}); err != nil {
+		t.Fatal(err)
+	}
+	events := func() []string {
+		var got []string
+		for _, d := range received {
+			got = append(got, d.event+" "+d.body.Post.Slug)
+		}
+		return got
+	}
+
+	draft := &PostView{Slug: "hooked", Title: "Hooked", Content: "Draft."}
+	if err := server.createPost(ctx, draft); err != nil {
+		t.Fatal(err)
+	}
+	draft.Published = true
+	if err := server.updatePost(ctx, draft, ""); err != nil {
+		t.Fatal(err)
+	}
+	draft.Content = "Edited."
+	if err := server.updatePost(ctx, draft, ""); err != nil {
+		t.Fatal(err)
+	}
+	server.deliverWebhooks(ctx)
+	if got := events(); !slices.Equal(got, []string{"post.published hooked"}) {
+		t.Fatalf("expected only the publication to be sent, got %v", got)
+	}
+	if d := received[0].body; d.Event != eventPostPublished || d.Post.URL != "https://blog.example/post/hooked" {
+		t.Errorf("unexpected payload %+v", d)
+	}
+
+	failing = false
+	server.deliverWebhooks(ctx)
+	server.deliverWebhooks(ctx)
+	if got := events(); len(got) != 2 || got[1] != "post.published hooked" {
+		t.Errorf("expected the failed delivery to be retried once, got %v", got)
+	}
+
+	failing = true
+	req := httptest.NewRequest(http.MethodPost, "/admin/delete/"+strconv.FormatInt(draft.ID, 10), nil)
+	req.SetPathValue("id", strconv.FormatInt(draft.ID, 10))
+	server.HandleAdminDelete(httptest.NewRecorder(), req)
+	server.deliverWebhooks(ctx)
+	server.deliverWebhooks(ctx)
+	server.deliverWebhooks(ctx)
+	if got := events(); len(got) != 4 || got[3] != "post.deleted hooked" {
+		t.Fatalf("expected the deletion to be tried twice and given up on, got %v", got)
+	}
+
+	page := func() string {
+		w := httptest.NewRecorder()
+		server.HandleAdminWebhooks(w, httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil))
+		return w.Body.String()
+	}
+	body := page()
+	for _, expected := range []string{receiver.URL, "hook-secret", "Delivered (200)", "unexpected status 503", "/admin/webhooks/retry/"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected webhooks page to contain %q", expected)
+		}
+	}
+
+	deliveries, err := q.ListWebhookDeliveries(ctx, webhookLogSize)
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("expected two deliveries, got %v, %v", deliveries, err)
+	}
+	failing = false
+	req = httptest.NewRequest(http.MethodPost, "/admin/webhooks/retry/1", nil)
+	req.SetPathValue("id", strconv.FormatInt(deliveries[0].ID, 10))
+	server.HandleAdminWebhookRetry(httptest.NewRecorder(), req)
+	server.deliverWebhooks(ctx)
+	if got := events(); len(got) != 5 || got[4] != "post.deleted hooked" {
+		t.Errorf("expected the retried deletion to be sent, got %v", got)
+	}
+	if body := page(); strings.Contains(body, "/admin/webhooks/retry/") {
+		t.Errorf("expected nothing left to retry")
+	}
+
+	form := url.Values{"url": {"ftp://example.com"}, "events": {eventPostUpdated}}
+	req = httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.HandleAdminWebhookCreate(w, req)
+	if !strings.Contains(w.Body.String(), "http:// or https://") {
+		t.Errorf("expected an ftp URL to be rejected, got %d", w.Code)
+	}
+	form.Set("url", "https://hooks.example/purge")
+	req = httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	server.HandleAdminWebhookCreate(w, req)
+	if w.Code != http.StatusFound {
+		t.Errorf("expected redirect after adding a webhook, got %d: %s", w.Code, w.Body.String())
+	}
+	if hooks, _ := q.ListWebhooks(ctx); len(hooks) != 2 || hooks[1].Events != eventPostUpdated || len(hooks[1].Secret) < 16 {
+		t.Errorf("expected a second webhook with a generated secret, got %+v", hooks)
+	}
+}
+
+func TestContentSync(t *testing.T) {
+	server := newTestServer(t)
+	server.ContentDir = t.TempDir()
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	write := func(name, src string) {
+		t.Helper()
+		path := filepath.Join(server.ContentDir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("Lost Cities.md", "---\ntitle: Lost Cities\ndate: 2024-05-01\ntags: [travel, History]\n---\n\nAtlantis, mostly.\n")
+	write("drafts/next.md", "---\ntitle: Next\nslug: coming-soon\ndraft: true\n---\nSoon.\n")
+	write("notes.txt", "not a post")
+	write(".hidden/skip.md", "---\ntitle: Skipped\n---\n")
+	write("broken.md", "no front matter")
+
+	result, err := server.syncContent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ImportResult{Created: 2, Failed: 1}) {
+		t.Errorf("unexpected first sync %+v", result)
+	}
+	p, err := q.GetPostBySlug(ctx, "lost-cities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Lost Cities" || p.Content != "Atlantis, mostly.\n" || p.Published != 1 || p.CreatedAt.Format(time.DateOnly) != "2024-05-01" {
+		t.Errorf("unexpected synced post %+v", p)
+	}
+	if postTags, _ := q.GetPostTags(ctx, p.ID); len(postTags) != 2 {
+		t.Errorf("expected two tags, got %v", postTags)
+	}
+	if draft, err := q.GetPostBySlug(ctx, "coming-soon"); err != nil || draft.Published != 0 {
+		t.Errorf("expected a draft, got %+v, %v", draft, err)
+	}
+
+	write("drafts/next.md", "---\ntitle: Next Up\nslug: coming-soon\n---\nNow.\n")
+	result, err = server.syncContent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ImportResult{Updated: 1, Unchanged: 1, Failed: 1}) {
+		t.Errorf("unexpected second sync %+v", result)
+	}
+	if next, _ := q.GetPostBySlug(ctx, "coming-soon"); next.Title != "Next Up" || next.Published != 1 {
+		t.Errorf("expected the edit to be synced, got %+v", next)
+	}
+	if revisions, _ := q.GetPostRevisions(ctx, p.ID); len(revisions) != 0 {
+		t.Errorf("expected an unchanged post to be left alone, got %d revisions", len(revisions))
+	}
+
+	// The hook only queues a sync, for syncLoop to run.
+	hook := func(header, value, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/hooks/sync", strings.NewReader(body))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		server.requireSyncAuth(server.HandleSyncHook)(w, req)
+		return w
+	}
+	body := `{"ref":"refs/heads/main"}`
+	mac := hmac.New(sha256.New, []byte("push-secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if w := hook("X-Hub-Signature-256", signature, body); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a sync secret, got %d", w.Code)
+	}
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSyncSecret, Value: "push-secret"})
+	if w := hook("X-Hub-Signature-256", signature, body+" "); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a bad signature, got %d", w.Code)
+	}
+	if w := hook("X-Gitlab-Token", "wrong", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong token, got %d", w.Code)
+	}
+	if w := hook("", "", body); w.Code == http.StatusAccepted {
+		t.Errorf("expected a request without credentials to be refused")
+	}
+	if len(server.contentSync) != 0 {
+		t.Fatalf("expected no sync to be queued yet")
+	}
+	if w := hook("X-Hub-Signature-256", signature, body); w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), `"queued"`) {
+		t.Errorf("expected a signed push to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := hook("X-Gitlab-Token", "push-secret", body); w.Code != http.StatusAccepted {
+		t.Errorf("expected a GitLab push to be accepted, got %d", w.Code)
+	}
+	if len(server.contentSync) != 1 {
+		t.Errorf("expected a sync to be queued")
+	}
+
+	server.ContentDir = ""
+	if w := hook("X-Gitlab-Token", "push-secret", body); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a content directory, got %d", w.Code)
+	}
+}
+
+func TestContentCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	server := newTestServer(t)
+	ctx := context.Background()
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+		return string(output)
+	}
+	remote, elsewhere := t.TempDir(), t.TempDir()
+	server.ContentDir = t.TempDir()
+	git(remote, "init", "--quiet", "--bare", "--initial-branch=main")
+	git(elsewhere, "clone", "--quiet", remote, ".")
+	os.WriteFile(filepath.Join(elsewhere, "first.md"), []byte("---\ntitle: First\ntags: [b, a]\n---\nHello.\n"), 0o644)
+	git(elsewhere, "add", ".")
+	git(elsewhere, "commit", "--quiet", "-m", "First")
+	git(elsewhere, "push", "--quiet", "origin", "HEAD:main")
+	git(server.ContentDir, "clone", "--quiet", remote, ".")
+	server.ContentCommit = true
+
+	if result, err := server.syncContent(ctx); err != nil || result.Created != 1 {
+		t.Fatalf("expected the first post to be imported, got %+v, %v", result, err)
+	}
+	if log := git(server.ContentDir, "log", "--format=%s"); log != "First\n" {
+		t.Errorf("expected the sync not to commit, got log %q", log)
+	}
+
+	first, err := dbgen.New(server.DB).GetPostBySlug(ctx, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := PostView{ID: first.ID, Slug: "first", Title: "First Post", Content: "Hello again.\n", Published: true, TagList: "a, b"}
+	if err := server.updatePost(ctx, &post, ""); err != nil {
+		t.Fatal(err)
+	}
+	draft := PostView{Title: "Second Thoughts", Content: "Not yet."}
+	if err := server.createPost(ctx, &draft); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/delete/"+strconv.FormatInt(first.ID, 10), nil)
+	req.SetPathValue("id", strconv.FormatInt(first.ID, 10))
+	server.HandleAdminDelete(httptest.NewRecorder(), req)
+	if log := git(server.ContentDir, "log", "--format=%s"); log != "Delete First Post\nAdd Second Thoughts\nUpdate First Post\nFirst\n" {
+		t.Errorf("unexpected log %q", log)
+	}
+	src, err := os.ReadFile(filepath.Join(server.ContentDir, "second-thoughts.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "title: Second Thoughts\nslug: second-thoughts\n") || !strings.Contains(string(src), "published: false\n---\n\nNot yet.") {
+		t.Errorf("unexpected file for the draft:\n%s", src)
+	}
+	if _, err := os.Stat(filepath.Join(server.ContentDir, "first.md")); !os.IsNotExist(err) {
+		t.Errorf("expected the trashed post's file to be removed, got %v", err)
+	}
+
+	// A sync pushes the commits, and brings in those made elsewhere.
+	os.WriteFile(filepath.Join(elsewhere, "third.md"), []byte("---\ntitle: Third\n---\nOffline.\n"), 0o644)
+	git(elsewhere, "add", ".")
+	git(elsewhere, "commit", "--quiet", "-m", "Third")
+	git(elsewhere, "push", "--quiet", "origin", "HEAD:main")
+	if result, err := server.syncContent(ctx); err != nil || result != (ImportResult{Created: 1, Unchanged: 1}) {
+		t.Fatalf("unexpected sync %+v, %v", result, err)
+	}
+	if log := git(remote, "log", "--format=%s", "main"); !strings.HasPrefix(log, "Delete First Post\n") || !strings.Contains(log, "Third\n") {
+		t.Errorf("expected the commits to be pushed after the one made elsewhere, got %q", log)
+	}
+	if log := git(server.ContentDir, "log", "--format=%s", "-1"); log != "Delete First Post\n" {
+		t.Errorf("expected the sync itself not to commit, got %q", log)
+	}
+}
+
+func TestMarkdownExport(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	post := createTestPost(t, server, "lost-cities", `Lost Cities: "Atlantis"`, "Mostly *myth*.\n", true)
+	tags.Set(ctx, q, post.ID, []string{"history", "travel"})
+	q.SetPostCreatedAt(ctx, dbgen.SetPostCreatedAtParams{CreatedAt: "2024-05-01 09:30:00", ID: post.ID})
+	createTestPost(t, server, "draft", "Draft", "Not yet.", false)
+	trashed := createTestPost(t, server, "gone", "Gone", "Bye.", true)
+	q.TrashPost(ctx, trashed.ID)
+
+	dir := t.TempDir()
+	if n, err := server.ExportMarkdown(ctx, dir); err != nil || n != 2 {
+		t.Fatalf("expected two posts exported, got %d, %v", n, err)
+	}
+	src, err := os.ReadFile(filepath.Join(dir, "lost-cities.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "---\ntitle: \"Lost Cities: \\\"Atlantis\\\"\"\nslug: lost-cities\ndate: 2024-05-01T09:30:00Z\npublished: true\ntags: [history, travel]\n---\n\nMostly *myth*.\n"
+	if string(src) != expected {
+		t.Errorf("unexpected export:\n%s\nexpected:\n%s", src, expected)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.md")); !os.IsNotExist(err) {
+		t.Errorf("expected the trashed post not to be exported")
+	}
+
+	// Importing an export into another blog makes the same posts.
+	other := newTestServer(t)
+	result, err := other.ImportMarkdown(ctx, dir)
+	if err != nil || result != (ImportResult{Created: 2}) {
+		t.Fatalf("unexpected import %+v, %v", result, err)
+	}
+	imported, err := dbgen.New(other.DB).GetPostBySlug(ctx, "lost-cities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Title != post.Title || imported.Content != post.Content || !imported.CreatedAt.Equal(time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected imported post %+v", imported)
+	}
+	if draft, _ := dbgen.New(other.DB).GetPostBySlug(ctx, "draft"); draft.Published != 0 {
+		t.Errorf("expected the draft to stay a draft")
+	}
+	if result, err := other.ImportMarkdown(ctx, dir); err != nil || result != (ImportResult{Unchanged: 2}) {
+		t.Errorf("expected a second import to change nothing, got %+v, %v", result, err)
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleAdminExportMarkdown(w, httptest.NewRequest(http.MethodGet, "/admin/export/markdown", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Disposition"), `attachment; filename="posts-`) {
+		t.Errorf("expected a download, got %q", w.Header().Get("Content-Disposition"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if !slices.Equal(names, []string{"lost-cities.md", "draft.md"}) {
+		t.Errorf("unexpected files in zip %v", names)
+	}
+}
+
+func TestHugoExport(t *testing.T) {
+	server := newTestServer(t)
+	server.MediaDir = t.TempDir()
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"})
+	category, err := q.CreateCategory(ctx, dbgen.CreateCategoryParams{Slug: "places", Name: "Places"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp uploadResponse
+	json.Unmarshal(uploadTestFile(t, server, "map.png", testPNG(t, 4, 4)).Body.Bytes(), &resp)
+
+	post := PostView{Title: "Lost Cities", Content: "![Map](" + resp.URL + ")\n", Excerpt: "Where they went.", Published: true, TagList: "history", CategoryID: category.ID}
+	if err := server.createPost(ctx, &post); err != nil {
+		t.Fatal(err)
+	}
+	post.Slug = "vanished-cities"
+	if err := server.updatePost(ctx, &post, ""); err != nil {
+		t.Fatal(err)
+	}
+	createTestPost(t, server, "draft", "Draft", "Not yet.", false)
+
+	dir := t.TempDir()
+	if n, err := server.ExportHugo(ctx, dir); err != nil || n != 4 {
+		t.Fatalf("expected four files written, got %d, %v", n, err)
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if config := read("hugo.toml"); !strings.Contains(config, `baseURL = "https://blog.example/"`) || !strings.Contains(config, `posts = "/post/:slug/"`) {
+		t.Errorf("unexpected config:\n%s", config)
+	}
+	page := read("content/posts/vanished-cities.md")
+	for _, expected := range []string{
+		"title: Lost Cities\nslug: vanished-cities\n",
+		"draft: false\ntags: [history]\ncategories: [Places]\nsummary: Where they went.\n",
+		"aliases: [/post/lost-cities]\n---\n\n![Map](" + resp.URL + ")\n",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected page to contain %q, got:\n%s", expected, page)
+		}
+	}
+	if draft := read("content/posts/draft.md"); !strings.Contains(draft, "draft: true\n") {
+		t.Errorf("expected the draft to be a Hugo draft, got:\n%s", draft)
+	}
+	if image := read("static/" + strings.TrimPrefix(resp.URL, "/")); len(image) == 0 {
+		t.Errorf("expected the uploaded image under static")
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	server := newTestServer(t)
+	server.MediaDir = t.TempDir()
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"})
+	category, err := q.CreateCategory(ctx, dbgen.CreateCategoryParams{Slug: "places", Name: "Places"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := q.CreateSeries(ctx, dbgen.CreateSeriesParams{Slug: "travels", Title: "Travels"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadTestFile(t, server, "map.png", testPNG(t, 4, 4))
+	post := PostView{Title: "Lost Cities", Content: "Gone.", Published: true, TagList: "history, maps", CategoryID: category.ID, SeriesID: series.ID, SeriesOrder: 1}
+	if err := server.createPost(ctx, &post); err != nil {
+		t.Fatal(err)
+	}
+	post.Slug = "vanished-cities"
+	if err := server.updatePost(ctx, &post, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.CreateComment(ctx, dbgen.CreateCommentParams{PostID: post.ID, AuthorName: "Ann", Body: "Nice.", Status: commentApproved}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.ReceiveWebmention(ctx, dbgen.ReceiveWebmentionParams{PostID: post.ID, Source: "https://elsewhere.example/", Target: "https://blog.example/post/" + post.Slug}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.UpsertFollower(ctx, dbgen.UpsertFollowerParams{Actor: "https://social.example/users/alice", Inbox: "https://social.example/users/alice/inbox", SharedInbox: "https://social.example/inbox"}); err != nil {
+		t.Fatal(err)
+	}
+	trashed := createTestPost(t, server, "old", "Old", "Binned.", true)
+	if err := q.TrashPost(ctx, trashed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/export", nil)
+	w := httptest.NewRecorder()
+	server.HandleAdminExport(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), "backup-") {
+		t.Fatalf("expected a backup download, got %d %v", w.Code, w.Header())
+	}
+	archive := w.Body.Bytes()
+
+	restored := newTestServer(t)
+	if err := restored.Restore(ctx, bytes.NewReader(archive)); err != nil {
+		t.Fatal(err)
+	}
+	before, err := server.backup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := restored.backup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after.CreatedAt = before.CreatedAt
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("expected the restored blog to back up the same:\n%+v\n%+v", before, after)
+	}
+	if len(after.Posts) != 2 || len(after.Tags) != 2 || len(after.Redirects) != 1 || len(after.Comments) != 1 || len(after.Mentions) != 1 || len(after.Followers) != 1 || len(after.Media) != 1 {
+		t.Errorf("expected 2 posts, 2 tags, a redirect, a comment and a medium, got %+v", after)
+	}
+	results, err := restored.searchPosts(ctx, "lost", 10)
+	if err != nil || len(results) != 1 {
+		t.Errorf("expected the restored post to be searchable, got %v, %v", results, err)
+	}
+
+	if err := restored.Restore(ctx, bytes.NewReader(archive)); err == nil {
+		t.Errorf("expected restoring over content to fail")
+	}
+	if err := newTestServer(t).Restore(ctx, strings.NewReader(`{"version": 99}`)); err == nil {
+		t.Errorf("expected an unknown backup version to be refused")
+	}
+}
+
+func TestComments(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "wiki", "Wiki Discovery", "Today's article.", true)
+	comment := func(slug string, form url.Values) *httptest.ResponseRecorder {
+		form.Set("token", server.commentToken(time.Now().Add(-time.Minute)))
+		req := httptest.NewRequest("POST", "/post/"+slug+"/comments", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		server.HandleCommentSubmit(w, req)
+		return w
+	}
+	view := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/post/wiki", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.SetPathValue("slug", "wiki")
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		return w
+	}
+
+	// Times are kept to the second, so make the post older than comments.
+	if _, err := server.DB.Exec("UPDATE posts SET updated_at = datetime(updated_at, '-1 minute')"); err != nil {
+		t.Fatal(err)
+	}
+	before := view(nil)
+	if !strings.Contains(before.Body.String(), `action="/post/wiki/comments#comment-form"`) {
+		t.Fatalf("expected a comment form on the post page")
+	}
+
+	w := comment("wiki", url.Values{"name": {"Ann"}, "body": {"Fascinating <b>stuff</b>.\n\nThanks!"}, "url": {"ann.example"}})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/post/wiki?comment=held#comments" {
+		t.Fatalf("expected a redirect back to the post, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if body := view(nil).Body.String(); strings.Contains(body, "Fascinating") {
+		t.Errorf("expected a pending comment not to be shown")
+	}
+
+	t.Setenv("DEV_MODE", "1")
+	queue := httptest.NewRecorder()
+	server.HandleAdminComments(queue, httptest.NewRequest("GET", "/admin/comments", nil))
+	match := regexp.MustCompile(`name="id" value="(\d+)" form="bulk-form"`).FindStringSubmatch(queue.Body.String())
+	if match == nil || !strings.Contains(queue.Body.String(), "Pending <span>1</span>") {
+		t.Fatalf("expected the comment in the moderation queue, got:\n%s", queue.Body.String())
+	}
+	moderate(t, server, "approve", match[1])
+
+	// The ETag follows the post's comments, so approving one shows it.
+	if again := view(http.Header{"If-None-Match": {before.Header().Get("ETag")}}); again.Code != http.StatusOK {
+		t.Errorf("expected the page to change with an approved comment, got %d", again.Code)
+	}
+	body := view(nil).Body.String()
+	for _, expected := range []string{
+		`<a href="https://ann.example" rel="nofollow ugc">Ann</a>`,
+		"<p>Fascinating &lt;b&gt;stuff&lt;/b&gt;.</p>\n<p>Thanks!</p>",
+		"1 comment</h2>",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected the post page to contain %q", expected)
+		}
+	}
+
+	w = comment("wiki", url.Values{"body": {"No name."}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Please give your name") || !strings.Contains(w.Body.String(), "No name.</textarea>") {
+		t.Errorf("expected the form back with an error and the comment, got %d", w.Code)
+	}
+	if w := comment("wiki", url.Values{"name": {"Bo"}, "body": {"Hi"}, "email": {"not an address"}}); !strings.Contains(w.Body.String(), "email address") {
+		t.Errorf("expected a bad email address to be refused")
+	}
+	if w := comment("nope", url.Values{"name": {"Bo"}, "body": {"Hi"}}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a comment on a missing post, got %d", w.Code)
+	}
+	createTestPost(t, server, "draft", "Draft", "Not yet.", false)
+	if w := comment("draft", url.Values{"name": {"Bo"}, "body": {"Hi"}}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a comment on a draft, got %d", w.Code)
+	}
+
+	// Closing comments in the editor keeps those already approved.
+	form := url.Values{"slug": {"wiki"}, "title": {"Wiki Discovery"}, "content": {"Today's article."}, "published": {"on"}}
+	req := httptest.NewRequest("POST", "/admin/edit/"+strconv.FormatInt(p.ID, 10), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+	server.HandleAdminUpdate(httptest.NewRecorder(), req)
+	if w := comment("wiki", url.Values{"name": {"Bo"}, "body": {"Hi"}}); w.Code != http.StatusForbidden {
+		t.Errorf("expected comments on a closed post to be refused, got %d", w.Code)
+	}
+	body = view(nil).Body.String()
+	if strings.Contains(body, "comment-form") || !strings.Contains(body, "Comments are closed.") || !strings.Contains(body, "Fascinating") {
+		t.Errorf("expected the closed post to show its comment and no form")
+	}
+}
+
+// moderate applies a moderation action to the comments with the given IDs.
+func moderate(t *testing.T, server *Server, action string, ids ...string) {
+	t.Helper()
+	form := url.Values{"action": {action}, "id": ids, "status": {"pending"}}
+	req := httptest.NewRequest("POST", "/admin/comments", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.HandleAdminCommentModerate(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("%s: expected a redirect, got %d: %s", action, w.Code, w.Body.String())
+	}
+}
+
+func TestCommentModeration(t *testing.T) {
+	t.Setenv("DEV_MODE", "1")
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	p := createTestPost(t, server, "wiki", "Wiki Discovery", "Today's article.", true)
+	var ids []string
+	for _, name := range []string{"Ann", "Bo", "Cy"} {
+		c, err := q.CreateComment(ctx, dbgen.CreateCommentParams{PostID: p.ID, AuthorName: name, Body: "By " + name, Status: commentPending})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, strconv.FormatInt(c.ID, 10))
+	}
+	dashboard := httptest.NewRecorder()
+	server.HandleAdminDashboard(dashboard, httptest.NewRequest("GET", "/admin", nil))
+	if !strings.Contains(dashboard.Body.String(), "<strong>3</strong> comments to moderate") {
+		t.Errorf("expected the dashboard to count pending comments")
+	}
+
+	moderate(t, server, "approve", ids[0])
+	moderate(t, server, "spam", ids[1])
+	moderate(t, server, "reject", ids[2])
+	if counts := commentCounts(ctx, q); counts != (CommentCounts{Approved: 1, Spam: 1, Rejected: 1}) {
+		t.Errorf("unexpected counts after moderating: %+v", counts)
+	}
+	spam := httptest.NewRecorder()
+	server.HandleAdminComments(spam, httptest.NewRequest("GET", "/admin/comments?status=spam", nil))
+	if body := spam.Body.String(); !strings.Contains(body, "By Bo") || strings.Contains(body, "By Ann") {
+		t.Errorf("expected only the spam comment on the spam tab")
+	}
+	if comments := server.postComments(ctx, p.ID); len(comments) != 1 || comments[0].AuthorName != "Ann" {
+		t.Errorf("expected only the approved comment on the post, got %+v", comments)
+	}
+
+	moderate(t, server, "delete", ids...)
+	if counts := commentCounts(ctx, q); counts != (CommentCounts{}) {
+		t.Errorf("expected bulk delete to remove every comment, got %+v", counts)
+	}
+	form := url.Values{"action": {"publish"}, "id": {"1"}}
+	req := httptest.NewRequest("POST", "/admin/comments", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.HandleAdminCommentModerate(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown action to be refused, got %d", w.Code)
+	}
+}
+
+func TestCommentReplies(t *testing.T) {
+	t.Setenv("DEV_MODE", "1")
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	p := createTestPost(t, server, "wiki", "Wiki Discovery", "Today's article.", true)
+	ann, err := q.CreateComment(ctx, dbgen.CreateCommentParams{PostID: p.ID, AuthorName: "Ann", Body: "First!", Status: commentApproved})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reply submits a reply to the comment with the given ID and approves
+	// it, returning the new comment's ID.
+	reply := func(name string, to int64) int64 {
+		t.Helper()
+		form := url.Values{"name": {name}, "body": {"Reply from " + name}, "reply": {strconv.FormatInt(to, 10)}}
+		form.Set("token", server.commentToken(time.Now().Add(-time.Minute)))
+		req := httptest.NewRequest("POST", "/post/wiki/comments", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("slug", "wiki")
+		w := httptest.NewRecorder()
+		server.HandleCommentSubmit(w, req)
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected %s's reply to be taken, got %d: %s", name, w.Code, w.Body.String())
+		}
+		var id int64
+		if err := server.DB.QueryRow("SELECT MAX(id) FROM comments").Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		moderate(t, server, "approve", strconv.FormatInt(id, 10))
+		return id
+	}
+	bo := reply("Bo", ann.ID)
+	cy := reply("Cy", bo)
+	// Replies are nested at most two deep, so Di's reply to Cy is filed
+	// beside Cy's, under Bo's.
+	reply("Di", cy)
+	if _, err := q.CreateComment(ctx, dbgen.CreateCommentParams{PostID: p.ID, AuthorName: "Ed", Body: "Second", Status: commentApproved}); err != nil {
+		t.Fatal(err)
+	}
+	var thread []string
+	for _, c := range server.postComments(ctx, p.ID) {
+		thread = append(thread, fmt.Sprintf("%s:%d", c.AuthorName, c.Depth))
+	}
+	if want := []string{"Ann:0", "Bo:1", "Cy:2", "Di:2", "Ed:0"}; !reflect.DeepEqual(thread, want) {
+		t.Errorf("expected comments in thread order %v, got %v", want, thread)
+	}
+
+	req := httptest.NewRequest("GET", "/post/wiki?reply="+strconv.FormatInt(bo, 10), nil)
+	req.SetPathValue("slug", "wiki")
+	w := httptest.NewRecorder()
+	server.HandlePost(w, req)
+	body := w.Body.String()
+	if !strings.Contains(body, "Reply to Bo") || !strings.Contains(body, fmt.Sprintf(`name="reply" value="%d"`, bo)) {
+		t.Errorf("expected the reply link to fill in the comment being replied to")
+	}
+	if !strings.Contains(body, `class="comment comment-reply comment-depth-2"`) {
+		t.Errorf("expected replies to be nested on the page")
+	}
+
+	// Only approved comments on the same post can be replied to.
+	pending, err := q.CreateComment(ctx, dbgen.CreateCommentParams{PostID: p.ID, AuthorName: "Flo", Body: "Hmm", Status: commentPending})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := url.Values{"name": {"Gus"}, "body": {"Agreed"}, "reply": {strconv.FormatInt(pending.ID, 10)}}
+	req = httptest.NewRequest("POST", "/post/wiki/comments", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("slug", "wiki")
+	w = httptest.NewRecorder()
+	server.HandleCommentSubmit(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "no longer here") {
+		t.Errorf("expected a reply to a pending comment to be turned away, got %d", w.Code)
+	}
+
+	// Deleting a comment deletes the replies to it.
+	moderate(t, server, "delete", strconv.FormatInt(bo, 10))
+	if comments := server.postComments(ctx, p.ID); len(comments) != 2 {
+		t.Errorf("expected Bo's replies to go with it, got %+v", comments)
+	}
+}
+
+func TestCommentSpam(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	createTestPost(t, server, "wiki", "Wiki Discovery", "Today's article.", true)
+	var checked url.Values
+	spamCheck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		checked = r.PostForm
+		switch {
+		case r.URL.Path != "/1.1/comment-check":
+			http.NotFound(w, r)
+		case r.PostForm.Get("comment_author") == "viagra-test-123":
+			io.WriteString(w, "true")
+		case r.PostForm.Get("api_key") != "secret":
+			w.Header().Set("X-akismet-debug-help", "Bad key")
+			io.WriteString(w, "invalid")
+		default:
+			io.WriteString(w, "false")
+		}
+	}))
+	defer spamCheck.Close()
+
+	served := server.commentToken(time.Now().Add(-time.Minute))
+	for _, tt := range []struct {
+		name   string
+		form   url.Values
+		key    string
+		status string
+	}{
+		{"plain", url.Values{"token": {served}}, "", commentPending},
+		{"honeypot", url.Values{"token": {served}, "website": {"https://spam.example"}}, "", commentSpam},
+		{"no token", url.Values{}, "", commentSpam},
+		{"forged token", url.Values{"token": {strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10) + ".00"}}, "", commentSpam},
+		{"too soon", url.Values{"token": {server.commentToken(time.Now())}}, "", commentSpam},
+		{"checked", url.Values{"token": {served}}, "secret", commentPending},
+		{"checked spam", url.Values{"token": {served}, "name": {"viagra-test-123"}}, "secret", commentSpam},
+		// A service that cannot answer leaves the comment to moderation.
+		{"check failed", url.Values{"token": {served}}, "wrong", commentPending},
+	} {
+		if err := dbgen.New(server.DB).UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSpamCheckKey, Value: tt.key}); err != nil {
+			t.Fatal(err)
+		}
+		if err := dbgen.New(server.DB).UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSpamCheckURL, Value: spamCheck.URL + "/1.1/"}); err != nil {
+			t.Fatal(err)
+		}
+		checked = nil
+		if !tt.form.Has("name") {
+			tt.form.Set("name", "Ann")
+		}
+		tt.form.Set("body", "Comment "+tt.name)
+		req := httptest.NewRequest("POST", "/post/wiki/comments", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("slug", "wiki")
+		w := httptest.NewRecorder()
+		server.HandleCommentSubmit(w, req)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "/post/wiki?comment=held#comments" {
+			t.Errorf("%s: expected the usual redirect, got %d %q", tt.name, w.Code, w.Header().Get("Location"))
+		}
+		var status string
+		if err := server.DB.QueryRow("SELECT status FROM comments WHERE body = ?", "Comment "+tt.name).Scan(&status); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if status != tt.status {
+			t.Errorf("%s: expected the comment to be %s, got %s", tt.name, tt.status, status)
+		}
+		if (tt.key != "") != (checked != nil) {
+			t.Errorf("%s: expected the spam check service to be asked only with a key", tt.name)
+		}
+		if checked != nil && (checked.Get("comment_content") != "Comment "+tt.name || checked.Get("permalink") != "http://example.com/post/wiki") {
+			t.Errorf("%s: unexpected spam check request %v", tt.name, checked)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/post/wiki", nil)
+	req.SetPathValue("slug", "wiki")
+	w := httptest.NewRecorder()
+	server.HandlePost(w, req)
+	if token := regexp.MustCompile(`name="token" value="([^"]+)"`).FindStringSubmatch(w.Body.String()); token == nil {
+		t.Errorf("expected the comment form to carry a token")
+	} else if _, ok := server.commentTokenTime(token[1]); !ok {
+		t.Errorf("expected the post page's token to verify")
+	}
+}
+
+func TestWebmentions(t *testing.T) {
+	t.Setenv("DEV_MODE", "1")
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	p := createTestPost(t, server, "wiki", "Wiki Discovery", "Today's article.", true)
+	createTestPost(t, server, "draft", "Draft", "Not yet.", false)
+	if _, err := server.DB.Exec("UPDATE posts SET updated_at = datetime(updated_at, '-1 minute')"); err != nil {
+		t.Fatal(err)
+	}
+	// The test's source pages are on this machine, which the real client
+	// refuses to fetch.
+	defer func(c *http.Client) { webmentionClient = c }(webmentionClient)
+	webmentionClient = &http.Client{Timeout: 5 * time.Second}
+
+	pages := map[string]string{
+		"/linking":  `<html><head><title>A  reply &amp; more</title></head><body><a href='http://EXAMPLE.com/post/wiki#intro'>this post</a></body></html>`,
+		"/unquoted": `<title>Unquoted</title><a class=x href=http://example.com/post/wiki>elsewhere</a>`,
+		"/unlinked": `<title>Unrelated</title><a href="http://example.com/post/other">another post</a>`,
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.Error(w, "Gone", http.StatusGone)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+	}))
+	defer source.Close()
+
+	send := func(source, target string) int {
+		form := url.Values{"source": {source}, "target": {target}}
+		req := httptest.NewRequest("POST", "/webmention", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.HandleWebmention(w, req)
+		return w.Code
+	}
+	view := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/post/wiki", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.SetPathValue("slug", "wiki")
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		return w
+	}
+
+	for _, tt := range []struct{ source, target string }{
+		{"", "http://example.com/post/wiki"},
+		{"ftp://elsewhere.example/", "http://example.com/post/wiki"},
+		{"http://example.com/post/wiki", "http://example.com/post/wiki"},
+		{source.URL + "/linking", "http://other.example/post/wiki"},
+		{source.URL + "/linking", "http://example.com/tags"},
+		{source.URL + "/linking", "http://example.com/post/draft"},
+		{source.URL + "/linking", "http://example.com/post/missing"},
+	} {
+		if code := send(tt.source, tt.target); code != http.StatusBadRequest {
+			t.Errorf("%q -> %q: expected 400, got %d", tt.source, tt.target, code)
+		}
+	}
+
+	before := view(nil)
+	if !strings.Contains(before.Header().Get("Link"), `</webmention>; rel="webmention"`) || !strings.Contains(before.Body.String(), `<link rel="webmention" href="/webmention">`) {
+		t.Errorf("expected the post page to advertise the Webmention endpoint")
+	}
+	for _, path := range []string{"/linking", "/unquoted", "/unlinked", "/gone"} {
+		if code := send(source.URL+path, "http://example.com/post/wiki"); code != http.StatusAccepted {
+			t.Fatalf("%s: expected 202, got %d", path, code)
+		}
+	}
+	server.checkWebmentions(ctx)
+	if counts := mentionCounts(ctx, q); counts != (MentionCounts{Pending: 2}) {
+		t.Errorf("expected only the sources that link to the post to be kept, got %+v", counts)
+	}
+	if strings.Contains(view(nil).Body.String(), "Mentioned elsewhere") {
+		t.Errorf("expected mentions not to be shown before they are approved")
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleAdminWebmentions(w, httptest.NewRequest("GET", "/admin/webmentions", nil))
+	ids := regexp.MustCompile(`name="id" value="(\d+)"`).FindAllStringSubmatch(w.Body.String(), -1)
+	if len(ids) != 2 || !strings.Contains(w.Body.String(), "A reply &amp; more") {
+		t.Fatalf("expected both checked mentions in the queue, got:\n%s", w.Body.String())
+	}
+	for _, id := range ids {
+		form := url.Values{"id": {id[1]}, "action": {"approve"}, "status": {"pending"}}
+		req := httptest.NewRequest("POST", "/admin/webmentions", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.HandleAdminWebmentionModerate(w, req)
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected approving to redirect, got %d", w.Code)
+		}
+	}
+	if again := view(http.Header{"If-None-Match": {before.Header().Get("ETag")}}); again.Code != http.StatusOK {
+		t.Errorf("expected the page to change with an approved mention, got %d", again.Code)
+	}
+	body := view(nil).Body.String()
+	if !strings.Contains(body, "Mentioned elsewhere") || !strings.Contains(body, `>A reply &amp; more</a> <span class="mention-host">127.0.0.1</span>`) {
+		t.Errorf("expected the approved mentions under the post, got:\n%s", body)
+	}
+
+	// A source that no longer links to the post takes its mention away
+	// when it is sent again.
+	pages["/unquoted"] = `<title>Unquoted</title><p>Nothing to see.</p>`
+	send(source.URL+"/unquoted", "http://example.com/post/wiki")
+	server.checkWebmentions(ctx)
+	if mentions := server.postMentions(ctx, p.ID); len(mentions) != 1 || mentions[0].Title != "A reply & more" {
+		t.Errorf("expected only the mention that still links, got %+v", mentions)
+	}
+}
+
+func TestSendWebmentions(t *testing.T) {
+	t.Setenv("DEV_MODE", "1")
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	defer func(c *http.Client) { webmentionClient = c }(webmentionClient)
+	webmentionClient = &http.Client{Timeout: 5 * time.Second}
+
+	received := map[string]url.Values{}
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			received[r.URL.Path] = r.PostForm
+			if r.URL.Path == "/broken-endpoint" {
+				http.Error(w, "oops", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/header":
+			w.Header().Add("Link", `<https://cdn.example/style.css>; rel="preload", </header-endpoint?x=1>; rel="webmention"`)
+			io.WriteString(w, `<link rel="webmention" href="/not-this-one">`)
+		case "/html":
+			io.WriteString(w, `<html><head><link href="/html-endpoint" REL="Webmention"></head></html>`)
+		case "/self":
+			io.WriteString(w, `<a rel="me webmention" href="">Send here</a>`)
+		case "/broken":
+			io.WriteString(w, `<link rel=webmention href=/broken-endpoint>`)
+		default:
+			io.WriteString(w, `<p>No endpoint.</p>`)
+		}
+	}))
+	defer elsewhere.Close()
+
+	content := fmt.Sprintf("See [a](%[1]s/header), [b](%[1]s/html#part), [c](%[1]s/self), [d](%[1]s/broken), [e](%[1]s/none), "+
+		"[my other post](https://blog.example/post/other), [about](/about) and [mail](mailto:me@blog.example).", elsewhere.URL)
+	p := createTestPost(t, server, "wiki", "Wiki Discovery", content, true)
+
+	// Without a site URL, the post has no address to send.
+	server.postChanged(ctx, nil, p.ID)
+	if sends, _ := q.ListPostWebmentionSends(ctx, p.ID); len(sends) != 0 {
+		t.Fatalf("expected nothing queued without a site URL, got %d", len(sends))
+	}
+	if err := q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"}); err != nil {
+		t.Fatal(err)
+	}
+	server.postChanged(ctx, nil, p.ID)
+	server.sendWebmentions(ctx)
+
+	for page, endpoint := range map[string]string{"/header": "/header-endpoint", "/html": "/html-endpoint", "/self": "/self", "/broken": "/broken-endpoint"} {
+		form := received[endpoint]
+		if form.Get("source") != "https://blog.example/post/wiki" || form.Get("target") != elsewhere.URL+page {
+			t.Errorf("%s: expected a Webmention at %s, got %v", page, endpoint, form)
+		}
+	}
+	if len(received) != 4 {
+		t.Errorf("expected four Webmentions sent, got %v", received)
+	}
+	sends, err := q.ListPostWebmentionSends(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := map[string]string{}
+	for _, m := range sends {
+		outcome := "sent"
+		switch {
+		case m.Error == errNoEndpoint.Error() && m.NextAttemptAt == nil:
+			outcome = "no endpoint"
+		case m.StatusCode == http.StatusInternalServerError && m.NextAttemptAt != nil:
+			outcome = "retrying"
+		case m.SentAt == nil:
+			outcome = "unexpected: " + m.Error
+		}
+		outcomes[strings.TrimPrefix(m.Target, elsewhere.URL)] = outcome
+	}
+	want := map[string]string{"/header": "sent", "/html": "sent", "/self": "sent", "/broken": "retrying", "/none": "no endpoint"}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("expected outcomes %v, got %v", want, outcomes)
+	}
+
+	// Every page the post linked to is told again when it changes,
+	// including those it no longer links to, so they can drop the mention.
+	if _, err := server.DB.Exec("UPDATE posts SET content = ? WHERE id = ?", fmt.Sprintf("Only [a](%s/header) now.", elsewhere.URL), p.ID); err != nil {
+		t.Fatal(err)
+	}
+	clear(received)
+	server.postChanged(ctx, &p, p.ID)
+	server.sendWebmentions(ctx)
+	if _, ok := received["/html-endpoint"]; !ok || len(received) != 4 {
+		t.Errorf("expected the removed links to be sent again, got %v", received)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/webmentions/sent/1", nil)
+	req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+	server.HandleAdminWebmentionSends(w, req)
+	if body := w.Body.String(); !strings.Contains(body, "Sent (202)") || !strings.Contains(body, "Not sent") || !strings.Contains(body, "Retrying at") {
+		t.Errorf("expected the log to show each outcome, got:\n%s", body)
+	}
+}
+
+func TestActivityPub(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	defer func(c *http.Client) { federationClient = c }(federationClient)
+	federationClient = &http.Client{Timeout: 5 * time.Second}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /.well-known/webfinger", server.HandleWebFinger)
+		mux.HandleFunc("GET /activitypub/actor", server.HandleActor)
+		mux.HandleFunc("GET /activitypub/outbox", server.HandleOutbox)
+		mux.HandleFunc("GET /activitypub/followers", server.HandleFollowers)
+		mux.HandleFunc("GET /activitypub/posts/{slug}", server.HandleActivityPost)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	// The fediverse needs the site URL to address the blog by.
+	if w := get("/.well-known/webfinger?resource=acct:blog@blog.example"); w.Code != http.StatusNotFound {
+		t.Fatalf("webfinger without a site URL: expected 404, got %d", w.Code)
+	}
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"})
+
+	w := get("/.well-known/webfinger?resource=acct:blog@blog.example")
+	var jrd activitypub.JRD
+	if err := json.Unmarshal(w.Body.Bytes(), &jrd); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("webfinger: %d %s", w.Code, w.Body)
+	}
+	if jrd.Subject != "acct:blog@blog.example" || len(jrd.Links) == 0 || jrd.Links[0].Href != "https://blog.example/activitypub/actor" {
+		t.Errorf("unexpected webfinger answer %+v", jrd)
+	}
+	for _, resource := range []string{"acct:someone@blog.example", "acct:blog@elsewhere.example"} {
+		if w := get("/.well-known/webfinger?resource=" + resource); w.Code != http.StatusNotFound {
+			t.Errorf("webfinger for %s: expected 404, got %d", resource, w.Code)
+		}
+	}
+
+	w = get("/activitypub/actor")
+	var actor activitypub.Actor
+	if err := json.Unmarshal(w.Body.Bytes(), &actor); err != nil {
+		t.Fatalf("actor: %v\n%s", err, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != activitypub.ContentType {
+		t.Errorf("actor served as %q", ct)
+	}
+	if actor.PreferredUsername != "blog" || actor.Inbox != "https://blog.example/activitypub/inbox" || actor.PublicKey == nil {
+		t.Fatalf("unexpected actor %+v", actor)
+	}
+	blogKey, err := activitypub.ParsePublicKey(actor.PublicKey.PublicKeyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A fediverse server with one user, alice, who follows the blog.
+	aliceKey, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alicePem, _ := activitypub.MarshalPublicKey(&aliceKey.PublicKey)
+	var received []activitypub.Activity
+	social := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			_, err := activitypub.Verify(r, body, func(keyID string) (*rsa.PublicKey, error) {
+				if keyID != "https://blog.example/activitypub/actor#main-key" {
+					return nil, fmt.Errorf("unexpected key %s", keyID)
+				}
+				return blogKey, nil
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			var a activitypub.Activity
+			json.Unmarshal(body, &a)
+			received = append(received, a)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		id := "http://" + r.Host + "/users/alice"
+		w.Header().Set("Content-Type", activitypub.ContentType)
+		json.NewEncoder(w).Encode(activitypub.Actor{
+			ID:        id,
+			Type:      "Person",
+			Inbox:     id + "/inbox",
+			Endpoints: &activitypub.Endpoints{SharedInbox: "http://" + r.Host + "/inbox"},
+			PublicKey: &activitypub.PublicKey{ID: id + "#main-key", Owner: id, PublicKeyPem: alicePem},
+		})
+	}))
+	defer social.Close()
+	alice := social.URL + "/users/alice"
+
+	deliver := func(activity activitypub.Activity, sign bool) int {
+		t.Helper()
+		body, _ := json.Marshal(activity)
+		req := httptest.NewRequest(http.MethodPost, "/activitypub/inbox", bytes.NewReader(body))
+		req.Host = "blog.example"
+		if sign {
+			if err := activitypub.Sign(req, body, alice+"#main-key", aliceKey); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w := httptest.NewRecorder()
+		server.HandleInbox(w, req)
+		return w.Code
+	}
+	follow := activitypub.Activity{ID: alice + "#follows/1", Type: "Follow", Actor: alice, Object: "https://blog.example/activitypub/actor"}
+
+	if code := deliver(follow, false); code != http.StatusUnauthorized {
+		t.Errorf("unsigned follow: expected 401, got %d", code)
+	}
+	forged := follow
+	forged.Actor = social.URL + "/users/bob"
+	if code := deliver(forged, true); code != http.StatusUnauthorized {
+		t.Errorf("follow signed by someone else: expected 401, got %d", code)
+	}
+	if n, _ := q.CountFollowers(ctx); n != 0 {
+		t.Fatalf("expected no followers yet, got %d", n)
+	}
+
+	if code := deliver(follow, true); code != http.StatusAccepted {
+		t.Fatalf("follow: expected 202, got %d", code)
+	}
+	if n, _ := q.CountFollowers(ctx); n != 1 {
+		t.Fatalf("expected 1 follower, got %d", n)
+	}
+	server.deliverActivities(ctx)
+	if len(received) != 1 || received[0].Type != "Accept" {
+		t.Fatalf("expected the follow to be accepted, got %+v", received)
+	}
+	if id, typ := received[0].ObjectRef(); id != follow.ID || typ != "Follow" {
+		t.Errorf("accepted %q (%s), expected the follow", id, typ)
+	}
+	w = get("/activitypub/followers")
+	if !strings.Contains(w.Body.String(), `"totalItems":1`) {
+		t.Errorf("followers collection does not count alice: %s", w.Body)
+	}
+
+	// Publishing a post delivers it to alice's server's shared inbox.
+	p := createTestPost(t, server, "hello-fediverse", "Hello, Fediverse", "Posts can be followed now.", true)
+	createTestPost(t, server, "draft", "Not Yet", "Unfinished.", false)
+	if err := tags.Set(ctx, q, p.ID, []string{"Go"}); err != nil {
+		t.Fatal(err)
+	}
+	received = nil
+	server.announcePending(ctx)
+	server.deliverActivities(ctx)
+	if len(received) != 1 || received[0].Type != "Create" {
+		t.Fatalf("expected the post to be delivered, got %+v", received)
+	}
+	article, _ := received[0].Object.(map[string]any)
+	if article["type"] != "Article" || article["name"] != "Hello, Fediverse" || article["url"] != "https://blog.example/post/hello-fediverse" {
+		t.Errorf("unexpected article %v", article)
+	}
+	if !strings.Contains(fmt.Sprint(article["tag"]), "#Go") {
+		t.Errorf("article is missing its hashtag: %v", article["tag"])
+	}
+
+	w = get("/activitypub/outbox")
+	var outbox activitypub.Collection
+	if err := json.Unmarshal(w.Body.Bytes(), &outbox); err != nil {
+		t.Fatal(err)
+	}
+	if outbox.TotalItems != 1 || len(outbox.OrderedItems) != 1 || outbox.OrderedItems[0].ID != "https://blog.example/activitypub/posts/hello-fediverse#create" {
+		t.Errorf("unexpected outbox %s", w.Body)
+	}
+	if w := get("/activitypub/posts/hello-fediverse"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"Article"`) {
+		t.Errorf("post as an article: %d %s", w.Code, w.Body)
+	}
+	if w := get("/activitypub/posts/draft"); w.Code != http.StatusNotFound {
+		t.Errorf("draft as an article: expected 404, got %d", w.Code)
+	}
+
+	// Undoing the follow removes alice, and nothing more is delivered.
+	undo := activitypub.Activity{ID: alice + "#undo/1", Type: "Undo", Actor: alice, Object: follow}
+	if code := deliver(undo, true); code != http.StatusAccepted {
+		t.Fatalf("undo: expected 202, got %d", code)
+	}
+	if n, _ := q.CountFollowers(ctx); n != 0 {
+		t.Errorf("expected alice to stop following, got %d followers", n)
+	}
+}
+
+func TestMicropub(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	for name, scope := range map[string]string{"writer": scopeWrite, "reader": scopeRead} {
+		if err := q.CreateAPIToken(ctx, dbgen.CreateAPITokenParams{Name: name, TokenHash: hashToken(name + "-token"), Scope: scope}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /micropub", server.requireMicropubToken(server.HandleMicropubQuery))
+	mux.HandleFunc("POST /micropub", server.requireMicropubToken(server.HandleMicropub))
+	mux.HandleFunc("POST /micropub/media", server.requireMicropubToken(server.HandleMicropubMedia))
+	call := func(method, target, contentType, body, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	const form = "application/x-www-form-urlencoded"
+	post := func(slug string) dbgen.Post {
+		t.Helper()
+		p, err := q.GetPostBySlug(ctx, slug)
+		if err != nil {
+			t.Fatalf("get %s: %v", slug, err)
+		}
+		return p
+	}
+	postTags := func(p dbgen.Post) string {
+		t.Helper()
+		list, err := q.GetPostTags(ctx, p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tags.Join(list)
+	}
+
+	if w := call("GET", "/micropub?q=config", "", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("query without a token: expected 401, got %d", w.Code)
+	}
+	if w := call("POST", "/micropub", form, "h=entry&content=Hi", "reader-token"); w.Code != http.StatusForbidden {
+		t.Errorf("post with a read token: expected 403, got %d", w.Code)
+	}
+	w := call("GET", "/micropub?q=config", "", "", "reader-token")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"media-endpoint":"http://example.com/micropub/media"`) {
+		t.Errorf("config: %d %s", w.Code, w.Body)
+	}
+
+	// A note, as a form with the token in it, is titled from its content.
+	note := "Landed in Lisbon after a long night of delays, and the city was worth every minute of the wait.\n\nMore soon."
+	body := url.Values{"h": {"entry"}, "content": {note}, "category[]": {"travel", "portugal"}, "access_token": {"writer-token"}}
+	w = call("POST", "/micropub", form, body.Encode(), "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create note: expected 201, got %d %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	slug := strings.TrimPrefix(location, "http://example.com/post/")
+	p := post(slug)
+	if p.Title != "Landed in Lisbon after a long night of delays, and the city…" || p.Content != note || p.Published != 1 {
+		t.Errorf("unexpected note post %q %q %d", p.Title, p.Content, p.Published)
+	}
+	if got := postTags(p); got != "portugal, travel" {
+		t.Errorf("note tags = %q", got)
+	}
+
+	// An article, as JSON, can choose its slug and be a draft.
+	w = call("POST", "/micropub", "application/json", `{"type":["h-entry"],"properties":{
+		"name":["Hello, Micropub"],"content":[{"html":"<p>Written in <em>Quill</em>.</p>"}],
+		"summary":["A first post from a client."],"mp-slug":["hello-micropub"],"post-status":["draft"]}}`, "writer-token")
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "http://example.com/post/hello-micropub" {
+		t.Fatalf("create article: %d %v %s", w.Code, w.Header(), w.Body)
+	}
+	if a := post("hello-micropub"); a.Title != "Hello, Micropub" || a.AllowHtml != 1 || a.Published != 0 || a.Excerpt != "A first post from a client." {
+		t.Errorf("unexpected article %+v", a)
+	}
+	if w := call("POST", "/micropub", "application/json", `{"type":["h-event"],"properties":{"name":["Party"]}}`, "writer-token"); w.Code != http.StatusBadRequest {
+		t.Errorf("create event: expected 400, got %d", w.Code)
+	}
+
+	w = call("GET", "/micropub?q=source&url=http://example.com/post/hello-micropub", "", "", "reader-token")
+	var source struct {
+		Type       []string `json:"type"`
+		Properties mpProps  `json:"properties"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &source); err != nil {
+		t.Fatal(err)
+	}
+	if source.Properties.str("name") != "Hello, Micropub" || source.Properties.str("post-status") != "draft" || len(source.Type) != 1 {
+		t.Errorf("unexpected source %s", w.Body)
+	}
+	w = call("GET", "/micropub?q=source&properties[]=category&url="+url.QueryEscape(location), "", "", "reader-token")
+	if w.Body.String() != `{"properties":{"category":["portugal","travel"]}}`+"\n" {
+		t.Errorf("source with properties: %s", w.Body)
+	}
+
+	// Updates replace, add to and delete properties.
+	w = call("POST", "/micropub", "application/json", fmt.Sprintf(`{"action":"update","url":%q,
+		"replace":{"name":["Lisbon"]},"add":{"category":["food"]},"delete":{"category":["travel"]}}`, location), "writer-token")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("update: expected 204, got %d %s", w.Code, w.Body)
+	}
+	p = post(slug)
+	if p.Title != "Lisbon" || p.Content != note || postTags(p) != "food, portugal" {
+		t.Errorf("after update: %q %q %q", p.Title, p.Content, postTags(p))
+	}
+	w = call("POST", "/micropub", "application/json", `{"action":"update","url":"http://example.com/post/hello-micropub","delete":["summary"],"replace":{"post-status":["published"]}}`, "writer-token")
+	if a := post("hello-micropub"); w.Code != http.StatusNoContent || a.Excerpt != "" || a.Published != 1 {
+		t.Errorf("after deleting the summary and publishing: %d %q %d", w.Code, a.Excerpt, a.Published)
+	}
+	if w := call("POST", "/micropub", "application/json", `{"action":"update","url":"http://example.com/post/nowhere","replace":{"name":["X"]}}`, "writer-token"); w.Code != http.StatusBadRequest {
+		t.Errorf("update of a missing post: expected 400, got %d", w.Code)
+	}
+
+	w = call("POST", "/micropub", form, url.Values{"action": {"delete"}, "url": {location}}.Encode(), "writer-token")
+	if p := post(slug); w.Code != http.StatusNoContent || p.DeletedAt == nil {
+		t.Errorf("delete: %d, deleted at %v", w.Code, p.DeletedAt)
+	}
+	w = call("POST", "/micropub", "application/json", fmt.Sprintf(`{"action":"undelete","url":%q}`, location), "writer-token")
+	if p := post(slug); w.Code != http.StatusNoContent || p.DeletedAt != nil {
+		t.Errorf("undelete: %d, deleted at %v", w.Code, p.DeletedAt)
+	}
+
+	// Images go to the media endpoint, or along with the post.
+	upload := func(target, field string, fields map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for name, value := range fields {
+			mw.WriteField(name, value)
+		}
+		fw, _ := mw.CreateFormFile(field, "sunset.png")
+		fw.Write(testPNG(t, 4, 3))
+		mw.Close()
+		return call("POST", target, mw.FormDataContentType(), buf.String(), "writer-token")
+	}
+	w = upload("/micropub/media", "file", nil)
+	if w.Code != http.StatusCreated || !strings.HasPrefix(w.Header().Get("Location"), "http://example.com/media/") {
+		t.Errorf("media: %d %v %s", w.Code, w.Header(), w.Body)
+	}
+	w = upload("/micropub", "photo", map[string]string{"h": "entry", "name": "Sunset", "content": "Over the river."})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create with a photo: %d %s", w.Code, w.Body)
+	}
+	if p := post("sunset"); !regexp.MustCompile(`^Over the river\.\n\n!\[\]\(/media/[^)]+\)$`).MatchString(p.Content) {
+		t.Errorf("photo not added to the content: %q", p.Content)
+	}
+}
+
+func TestIndieAuth(t *testing.T) {
+	server := newTestServer(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", server.HandleIndieAuthMetadata)
+	mux.HandleFunc("GET /indieauth/auth", server.HandleIndieAuthAuthorize)
+	mux.HandleFunc("POST /indieauth/auth/approve", server.HandleIndieAuthApprove)
+	mux.HandleFunc("POST /indieauth/auth", server.HandleIndieAuthRedeem)
+	mux.HandleFunc("GET /indieauth/token", server.HandleIndieAuthTokenInfo)
+	mux.HandleFunc("POST /indieauth/token", server.HandleIndieAuthToken)
+	mux.HandleFunc("POST /indieauth/revoke", server.HandleIndieAuthRevoke)
+	mux.HandleFunc("POST /micropub", server.requireMicropubToken(server.HandleMicropub))
+	mux.HandleFunc("POST /micropub/media", server.requireMicropubToken(server.HandleMicropubMedia))
+	call := func(method, target string, form url.Values, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call(http.MethodGet, "/.well-known/oauth-authorization-server", nil, "")
+	var meta map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta["issuer"] != "http://example.com/" || meta["token_endpoint"] != "http://example.com/indieauth/token" {
+		t.Errorf("unexpected metadata: %v", meta)
+	}
+
+	const verifier = "a-verifier-long-enough-to-be-a-real-pkce-code-verifier-0123"
+	sum := sha256.Sum256([]byte(verifier))
+	request := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"https://app.example/"},
+		"redirect_uri":          {"https://app.example/callback"},
+		"state":                 {"xyz"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+		"scope":                 {"profile create media follow"},
+	}
+	with := func(name, value string) url.Values {
+		v := maps.Clone(request)
+		v.Set(name, value)
+		return v
+	}
+	for _, bad := range []url.Values{
+		with("redirect_uri", "https://evil.example/callback"),
+		with("code_challenge_method", "plain"),
+		with("response_type", "token"),
+		with("state", ""),
+		with("client_id", "app.example"),
+	} {
+		if w := call(http.MethodGet, "/indieauth/auth?"+bad.Encode(), nil, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("request %v: got %d, expected 400", bad, w.Code)
+		}
+	}
+	w = call(http.MethodGet, "/indieauth/auth?"+request.Encode(), nil, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Sign in to app.example") || !strings.Contains(w.Body.String(), `value="media" checked`) {
+		t.Fatalf("consent page: got %d, body: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), `value="follow"`) {
+		t.Error("offered a scope that cannot be granted")
+	}
+
+	// approve grants the request with the scopes left ticked and returns
+	// the code sent back to the client.
+	approve := func(decision string, scopes ...string) url.Values {
+		t.Helper()
+		form := maps.Clone(request)
+		form["scope"] = scopes
+		form.Set("decision", decision)
+		w := call(http.MethodPost, "/indieauth/auth/approve", form, "")
+		loc, err := url.Parse(w.Header().Get("Location"))
+		if w.Code != http.StatusFound || err != nil || !strings.HasPrefix(loc.String(), "https://app.example/callback?") {
+			t.Fatalf("approve: got %d, Location %q", w.Code, w.Header().Get("Location"))
+		}
+		if loc.Query().Get("state") != "xyz" || loc.Query().Get("iss") != "http://example.com/" {
+			t.Errorf("redirect lacks state or iss: %s", loc)
+		}
+		return loc.Query()
+	}
+	if back := approve("deny", "create"); back.Get("error") != "access_denied" || back.Get("code") != "" {
+		t.Errorf("denying sent back %v", back)
+	}
+
+	redeem := func(code, verifier string) url.Values {
+		return url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"client_id":     {"https://app.example/"},
+			"redirect_uri":  {"https://app.example/callback"},
+			"code_verifier": {verifier},
+		}
+	}
+	code := approve("approve", "profile").Get("code")
+	if w := call(http.MethodPost, "/indieauth/token", redeem(code, verifier), ""); w.Code != http.StatusBadRequest {
+		t.Errorf("profile-only code got a token: %d %s", w.Code, w.Body.String())
+	}
+	code = approve("approve", "profile").Get("code")
+	w = call(http.MethodPost, "/indieauth/auth", redeem(code, verifier), "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"me":"http://example.com/"`) || !strings.Contains(w.Body.String(), siteTitle) {
+		t.Errorf("profile redemption: got %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPost, "/indieauth/auth", redeem(code, verifier), ""); w.Code != http.StatusBadRequest {
+		t.Errorf("a code was redeemed twice: %d", w.Code)
+	}
+
+	code = approve("approve", "create", "profile").Get("code")
+	if w := call(http.MethodPost, "/indieauth/token", redeem(code, "wrong-verifier"), ""); w.Code != http.StatusBadRequest {
+		t.Errorf("redeemed a code without its verifier: %d", w.Code)
+	}
+	code = approve("approve", "create", "profile").Get("code")
+	w = call(http.MethodPost, "/indieauth/token", redeem(code, verifier), "")
+	var granted struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		Scope       string `json:"scope"`
+		Me          string `json:"me"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &granted); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("token: got %d %s", w.Code, w.Body.String())
+	}
+	if granted.AccessToken == "" || granted.TokenType != "Bearer" || granted.Scope != "profile create" || granted.Me != "http://example.com/" {
+		t.Errorf("unexpected token answer: %+v", granted)
+	}
+	token := granted.AccessToken
+
+	w = call(http.MethodGet, "/indieauth/token", nil, token)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"client_id":"https://app.example/"`) {
+		t.Errorf("token info: got %d %s", w.Code, w.Body.String())
+	}
+	w = call(http.MethodPost, "/micropub", url.Values{"h": {"entry"}, "name": {"From an app"}, "content": {"Posted over IndieAuth"}}, token)
+	if w.Code != http.StatusCreated {
+		t.Errorf("create with the token: got %d %s", w.Code, w.Body.String())
+	}
+	w = call(http.MethodPost, "/micropub", url.Values{"action": {"delete"}, "url": {w.Header().Get("Location")}}, token)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "insufficient_scope") {
+		t.Errorf("delete without the delete scope: got %d %s", w.Code, w.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/posts?published=0", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if req, ok := server.checkToken(httptest.NewRecorder(), req, token); !ok || tokenAllows(apiToken(req), scopeRead) || tokenAllows(apiToken(req), scopeWrite) {
+		t.Error("an IndieAuth token grants the API's scopes")
+	}
+
+	if w := call(http.MethodPost, "/indieauth/revoke", url.Values{"token": {token}}, ""); w.Code != http.StatusOK {
+		t.Errorf("revoke: got %d", w.Code)
+	}
+	if w := call(http.MethodGet, "/indieauth/token", nil, token); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token still works: %d", w.Code)
+	}
+}
+
+func TestRelMe(t *testing.T) {
+	server := newTestServer(t)
+	if _, err := normalizeRelMe("github.com/someone"); err == nil {
+		t.Error("accepted a profile without a scheme")
+	}
+	v, err := normalizeRelMe(" https://github.com/someone \n\nhttps://social.example/@someone\n")
+	if err != nil || v != "https://github.com/someone\nhttps://social.example/@someone" {
+		t.Fatalf("normalizeRelMe = %q, %v", v, err)
+	}
+	if err := dbgen.New(server.DB).UpsertSetting(context.Background(), dbgen.UpsertSettingParams{Key: settingRelMe, Value: v}); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	server.HandleHome(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, want := range []string{`<link rel="me" href="https://github.com/someone">`, `<link rel="me" href="https://social.example/@someone">`, `<link rel="authorization_endpoint" href="/indieauth/auth">`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("home page lacks %s", want)
+		}
+	}
+}
+
+func TestPublicAddressOnly(t *testing.T) {
+	for address, public := range map[string]bool{
+		"93.184.215.14:443":          true,
+		"[2606:2800:21f:cb07::1]:80": true,
+		"127.0.0.1:8000":             false,
+		"[::1]:80":                   false,
+		"10.1.2.3:80":                false,
+		"192.168.0.10:80":            false,
+		"169.254.169.254:80":         false,
+		"0.0.0.0:80":                 false,
+		"[::ffff:127.0.0.1]:80":      false,
+	} {
+		if err := publicAddressOnly("tcp", address, nil); (err == nil) != public {
+			t.Errorf("%s: expected public %v, got error %v", address, public, err)
+		}
+	}
+}
+
+func TestEditLocks(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "locked", "Locked", "Body.", true)
+	id := strconv.FormatInt(p.ID, 10)
+
+	open := func(email, query string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/admin/edit/"+id+query, nil)
+		req.Header.Set("X-ExeDev-Email", email)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		server.HandleAdminEdit(w, req)
+		return w.Body.String()
+	}
+	renew := func(email string) int {
+		req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+id+"/lock", nil)
+		req.Header.Set("X-ExeDev-Email", email)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		server.HandleAdminEditLock(w, req)
+		return w.Code
+	}
+
+	if body := open("ann@example.com", ""); strings.Contains(body, "currently being edited") {
+		t.Errorf("expected the first editor to get the lock, got body: %s", body)
+	}
+	if body := open("bob@example.com", ""); !strings.Contains(body, "currently being edited by ann@example.com") {
+		t.Errorf("expected the second editor to be warned, got body: %s", body)
+	}
+	if code := renew("ann@example.com"); code != http.StatusNoContent {
+		t.Errorf("expected the holder to renew the lock, got %d", code)
+	}
+	if body := open("bob@example.com", "?takeover=1"); strings.Contains(body, "currently being edited") {
+		t.Errorf("expected take over to get the lock, got body: %s", body)
+	}
+	if code := renew("ann@example.com"); code != http.StatusConflict {
+		t.Errorf("expected the old holder to lose the lock, got %d", code)
+	}
+
+	if _, err := server.DB.Exec("UPDATE post_locks SET expires_at = datetime('now', '-1 minute')"); err != nil {
+		t.Fatalf("failed to expire lock: %v", err)
+	}
+	if body := open("ann@example.com", ""); strings.Contains(body, "currently being edited") {
+		t.Errorf("expected an expired lock to be taken, got body: %s", body)
+	}
+}
+
+func TestAdminEdit(t *testing.T) {
+	server := newTestServer(t)
+	first := createTestPost(t, server, "first", "First Post", "One.", true)
+	createTestPost(t, server, "second", "Second Post", "Two.", true)
+
+	edit := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/edit/"+id, nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		server.HandleAdminEdit(w, req)
+		return w
+	}
+
+	w := edit(strconv.FormatInt(first.ID, 10))
+	if body := w.Body.String(); !strings.Contains(body, `value="First Post"`) || strings.Contains(body, "Second Post") {
+		t.Errorf("expected the editor to load the requested post, got body: %s", body)
+	}
+	for _, id := range []string{"999", "abc"} {
+		if w := edit(id); w.Code != http.StatusNotFound {
+			t.Errorf("expected editing post %s to 404, got %d", id, w.Code)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "quotes", "Quotes", `He said "hi" -- twice.`, true)
+
+	getPost := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/post/quotes", nil)
+		req.SetPathValue("slug", "quotes")
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		return w.Body.String()
+	}
+
+	if body := getPost(); !strings.Contains(body, "He said &#34;hi&#34; -- twice.") {
+		t.Errorf("expected straight quotes by default, got body: %s", body)
+	}
+
+	form := url.Values{settingTypographer: {"on"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.HandleAdminSettingsUpdate(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected redirect after saving settings, got %d", w.Code)
+	}
+
+	if body := getPost(); !strings.Contains(body, "He said “hi” – twice.") {
+		t.Errorf("expected smart typography once enabled, got body: %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/settings", nil)
+	w = httptest.NewRecorder()
+	server.HandleAdminSettings(w, req)
+	if body := w.Body.String(); !strings.Contains(body, `name="typographer" checked`) {
+		t.Errorf("expected settings page to show the saved value, got body: %s", body)
+	}
+}
+
+func TestOEmbed(t *testing.T) {
+	server := newTestServer(t)
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type":"video","title":"Clip","html":"<iframe src=\"https://player.vimeo.com/video/1\"></iframe>"}`))
+	}))
+	defer provider.Close()
+	server.OEmbed = &oembed.Client{
+		HTTP:      provider.Client(),
+		Providers: []oembed.Provider{{Name: "vimeo", Endpoint: provider.URL, Hosts: []string{"vimeo.com"}, Players: []string{"player.vimeo.com"}}},
+	}
+
+	form := url.Values{"slug": {"clip"}, "title": {"Clip"}, "content": {"Watch this:\n\nhttps://vimeo.com/1"}, "published": {"on"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/new", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.HandleAdminCreate(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected redirect after creating post, got %d", w.Code)
+	}
+
+	// Rendering uses the cached embed even once the provider is gone.
+	provider.Close()
+	req = httptest.NewRequest(http.MethodGet, "/post/clip", nil)
+	req.SetPathValue("slug", "clip")
+	w = httptest.NewRecorder()
+	server.HandlePost(w, req)
+	if body := w.Body.String(); !strings.Contains(body, `<div class="embed embed-vimeo"><iframe src="https://player.vimeo.com/video/1" title="Clip"`) {
+		t.Errorf("expected cached vimeo embed, got body: %s", body)
+	}
+}
+
+func TestAdminCreateSlug(t *testing.T) {
+	server := newTestServer(t)
+	create := func(slug, title string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"slug": {slug}, "title": {title}, "content": {"Body."}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/new", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.HandleAdminCreate(w, req)
+		return w
+	}
+	q := dbgen.New(server.DB)
+
+	for _, expected := range []string{"hello-world", "hello-world-2", "hello-world-3"} {
+		if w := create("", "Hello, World!"); w.Code != http.StatusFound {
+			t.Fatalf("expected redirect after creating post, got %d: %s", w.Code, w.Body.String())
+		}
+		if _, err := q.GetPostBySlug(context.Background(), expected); err != nil {
+			t.Errorf("expected a post with slug %q: %v", expected, err)
+		}
+	}
+
+	w := create("hello-world", "Another")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "hello-world-4 is free") || !strings.Contains(body, `value="hello-world-4"`) {
+		t.Errorf("expected a free slug suggested for a taken one, got %d: %s", w.Code, body)
+	}
+	if w := create("", "???"); !strings.Contains(w.Body.String(), "please enter one") {
+		t.Errorf("expected an error for a title without slug characters, got body: %s", w.Body.String())
+	}
+}
+
+func TestAllowHTML(t *testing.T) {
+	server := newTestServer(t)
+	content := "H<sub>2</sub>O <script>alert(1)</script>"
+	for _, allow := range []string{"", "on"} {
+		slug := "water" + allow
+		form := url.Values{"slug": {slug}, "title": {"Water"}, "content": {content}, "published": {"on"}, "allow_html": {allow}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/new", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		server.HandleAdminCreate(httptest.NewRecorder(), req)
+
+		req = httptest.NewRequest(http.MethodGet, "/post/"+slug, nil)
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		body := w.Body.String()
+
+		if strings.Contains(body, "<script>alert") {
+			t.Errorf("allow_html=%q: expected script to be removed or escaped, got body: %s", allow, body)
+		}
+		if got := strings.Contains(body, "H<sub>2</sub>O"); got != (allow == "on") {
+			t.Errorf("allow_html=%q: expected raw <sub> = %v, got body: %s", allow, allow == "on", body)
+		}
+	}
+}
+
+func TestAtomFeed(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "hello-world", "Hello World", "Some **bold** words.", true)
+	createTestPost(t, server, "secret-draft", "Secret Draft", "Not yet.", false)
+
+	req := httptest.NewRequest(http.MethodGet, "https://blog.example/atom.xml", nil)
+	w := httptest.NewRecorder()
+	server.HandleAtom(w, req)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("expected atom content type, got %q", ct)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("failed to parse feed: %v\n%s", err, w.Body.String())
+	}
+	if len(feed.Entries) != 1 {
+		t.Fatalf("expected 1 published entry, got %d", len(feed.Entries))
+	}
+	entry := feed.Entries[0]
+	expectedID := "tag:blog.example," + time.Now().UTC().Format("2006-01-02") + ":/post/hello-world"
+	if entry.ID != expectedID {
+		t.Errorf("expected entry id %q, got %q", expectedID, entry.ID)
+	}
+	if entry.Link.Href != "https://blog.example/post/hello-world" {
+		t.Errorf("expected absolute entry link, got %q", entry.Link.Href)
+	}
+	if !strings.Contains(entry.Content.Body, "<strong>bold</strong>") {
+		t.Errorf("expected rendered content, got %q", entry.Content.Body)
+	}
+	if _, err := time.Parse(time.RFC3339, feed.Updated); err != nil {
+		t.Errorf("expected RFC 3339 feed updated time, got %q", feed.Updated)
+	}
+
+	t.Run("site url setting", func(t *testing.T) {
+		form := url.Values{settingSiteURL: {"https://citizen.example/"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		server.HandleAdminSettingsUpdate(httptest.NewRecorder(), req)
+
+		req = httptest.NewRequest(http.MethodGet, "http://localhost:8000/atom.xml", nil)
+		w := httptest.NewRecorder()
+		server.HandleAtom(w, req)
+		if body := w.Body.String(); !strings.Contains(body, `href="https://citizen.example/atom.xml"`) {
+			t.Errorf("expected self link to use the site url, got body: %s", body)
+		}
+
+		form = url.Values{settingSiteURL: {"not a url"}}
+		req = httptest.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		server.HandleAdminSettingsUpdate(w, req)
+		if body := w.Body.String(); !strings.Contains(body, "site URL must be") {
+			t.Errorf("expected invalid site url to be rejected, got body: %s", body)
+		}
+	})
+}
+
+func TestRobots(t *testing.T) {
+	server := newTestServer(t)
+	getRobots := func() string {
+		w := httptest.NewRecorder()
+		server.HandleRobots(w, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+		return w.Body.String()
+	}
+
+	if body := getRobots(); body != "User-agent: *\nDisallow: /admin\n" {
+		t.Errorf("unexpected default robots.txt: %q", body)
+	}
+
+	form := url.Values{
+		settingRobotsBlockAI: {"on"},
+		settingRobotsSitemap: {"https://blog.example/sitemap.xml"},
+		settingRobotsExtra:   {"User-agent: BadBot\r\nDisallow: /"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.HandleAdminSettingsUpdate(httptest.NewRecorder(), req)
+
+	body := getRobots()
+	for _, expected := range []string{
+		"User-agent: GPTBot\n",
+		"User-agent: BadBot\nDisallow: /\n",
+		"\nSitemap: https://blog.example/sitemap.xml\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected robots.txt to contain %q, got %q", expected, body)
+		}
+	}
+}
+
+func TestWebSub(t *testing.T) {
+	server := newTestServer(t)
+	var topics []string
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.FormValue("hub.mode") == "publish" {
+			topics = append(topics, r.FormValue("hub.url"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hub.Close()
+
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	createTestPost(t, server, "before-hub", "Before Hub", "Old news.", true)
+	server.announcePending(ctx)
+	if len(topics) != 0 {
+		t.Errorf("expected no notifications without a site URL, got %v", topics)
+	}
+
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"})
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingWebSubHub, Value: hub.URL})
+	createTestPost(t, server, "draft", "Draft", "Not yet.", false)
+	createTestPost(t, server, "fresh", "Fresh", "News.", true)
+	server.announcePending(ctx)
+	server.announcePending(ctx)
+	if len(topics) != 1 || topics[0] != "https://blog.example/atom.xml" {
+		t.Errorf("expected one notification for the feed, got %v", topics)
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleAtom(w, httptest.NewRequest(http.MethodGet, "/atom.xml", nil))
+	if expected := `<link rel="hub" href="` + hub.URL + `"></link>`; !strings.Contains(w.Body.String(), expected) {
+		t.Errorf("expected feed to contain %q, got %s", expected, w.Body.String())
+	}
+}
+
+func TestIndexNow(t *testing.T) {
+	server := newTestServer(t)
+	var pinged []url.Values
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pinged = append(pinged, r.URL.Query())
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer engine.Close()
+	defer func(endpoint string) { indexNowEndpoint = endpoint }(indexNowEndpoint)
+	indexNowEndpoint = engine.URL
+
+	getKey := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.HandleIndexNowKey(w, httptest.NewRequest(http.MethodGet, "/indexnow.txt", nil))
+		return w
+	}
+	if w := getKey(); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a key, got %d", w.Code)
+	}
+
+	form := url.Values{settingSiteURL: {"https://blog.example"}, settingIndexNowKey: {"short"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.HandleAdminSettingsUpdate(w, req)
+	if !strings.Contains(w.Body.String(), "IndexNow key") {
+		t.Errorf("expected a short key to be rejected, got %s", w.Body.String())
+	}
+
+	form.Set(settingIndexNowKey, "0123456789abcdef")
+	req = httptest.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.HandleAdminSettingsUpdate(httptest.NewRecorder(), req)
+	if w := getKey(); w.Body.String() != "0123456789abcdef" {
+		t.Errorf("expected key file to contain the key, got %q", w.Body.String())
+	}
+
+	createTestPost(t, server, "wiki-discovery", "Wiki Discovery", "Something new.", true)
+	server.announcePending(context.Background())
+	if len(pinged) != 1 {
+		t.Fatalf("expected one IndexNow ping, got %d", len(pinged))
+	}
+	if got := pinged[0].Get("url"); got != "https://blog.example/post/wiki-discovery" {
+		t.Errorf("expected post URL to be submitted, got %q", got)
+	}
+	if got := pinged[0].Get("keyLocation"); got != "https://blog.example/indexnow.txt" {
+		t.Errorf("expected key location to point at the key file, got %q", got)
+	}
+}
+
+func TestMastodon(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var toots []url.Values
+	var auth, keys []string
+	mastodon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/statuses" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		toots = append(toots, r.PostForm)
+		auth = append(auth, r.Header.Get("Authorization"))
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer mastodon.Close()
+
+	q := dbgen.New(server.DB)
+	for key, value := range map[string]string{
+		settingSiteURL:         "https://blog.example",
+		settingMastodonServer:  mastodon.URL,
+		settingMastodonToken:   "secret",
+		settingMastodonExcerpt: "1",
+	} {
+		if err := q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: key, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create := func(form url.Values) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/new", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		server.HandleAdminCreate(httptest.NewRecorder(), req)
+	}
+	create(url.Values{"slug": {"shared"}, "title": {"Shared"}, "content": {"Worth *reading*."}, "published": {"on"}, "crosspost": {"on"}})
+	create(url.Values{"slug": {"kept-quiet"}, "title": {"Kept Quiet"}, "content": {"Not for Mastodon."}, "published": {"on"}})
+	if p, err := q.GetPostBySlug(ctx, "kept-quiet"); err != nil || p.NoCrosspost != 1 {
+		t.Fatalf("unticking sharing did not turn it off: %+v, %v", p, err)
+	}
+	server.announcePending(ctx)
+
+	if len(toots) != 1 {
+		t.Fatalf("expected one toot, got %v", toots)
+	}
+	if want := "Shared\n\nWorth reading.\n\nhttps://blog.example/post/shared"; toots[0].Get("status") != want {
+		t.Errorf("status = %q, expected %q", toots[0].Get("status"), want)
+	}
+	if auth[0] != "Bearer secret" || keys[0] == "" {
+		t.Errorf("sent Authorization %q and Idempotency-Key %q", auth[0], keys[0])
+	}
+
+	long := tootText(strings.Repeat("Title ", 10), strings.Repeat("word ", 200), "https://blog.example/post/a-very-long-slug-that-is-still-counted-as-23")
+	if n := utf8.RuneCountInString(long) - len("https://blog.example/post/a-very-long-slug-that-is-still-counted-as-23") + mastodonLinkLength; n > mastodonStatusLimit {
+		t.Errorf("status counts as %d characters, more than %d", n, mastodonStatusLimit)
+	}
+	if !strings.Contains(long, "word...\n\nhttps://") {
+		t.Errorf("long description was not shortened: %q", long)
+	}
+}
+
+func TestBluesky(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var records []atproto.Post
+	var thumbs int
+	failNext := 0
+	pds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			w.Write([]byte(`{"did":"did:plc:blog","handle":"blog.example","accessJwt":"access"}`))
+		case "/xrpc/com.atproto.repo.uploadBlob":
+			thumbs++
+			w.Write([]byte(`{"blob":{"$type":"blob","ref":{"$link":"bafk"},"mimeType":"image/png","size":100}}`))
+		case "/xrpc/com.atproto.repo.createRecord":
+			if failNext > 0 {
+				w.WriteHeader(failNext)
+				w.Write([]byte(`{"error":"Failed"}`))
+				return
+			}
+			var in struct {
+				Record atproto.Post `json:"record"`
+			}
+			json.NewDecoder(r.Body).Decode(&in)
+			records = append(records, in.Record)
+			fmt.Fprintf(w, `{"uri":"at://did:plc:blog/app.bsky.feed.post/%d"}`, len(records))
+		}
+	}))
+	defer pds.Close()
+
+	q := dbgen.New(server.DB)
+	for key, value := range map[string]string{
+		settingSiteURL:         "https://blog.example",
+		settingBlueskyHandle:   "blog.example",
+		settingBlueskyPassword: "app-password",
+		settingBlueskyService:  pds.URL,
+	} {
+		if err := q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: key, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var upload struct {
+		URL string `json:"url"`
+	}
+	json.Unmarshal(uploadTestFile(t, server, "cover.png", testPNG(t, 1600, 900)).Body.Bytes(), &upload)
+	p := createTestPost(t, server, "on-bluesky", "On Bluesky", "![Cover]("+upload.URL+")\n\nSomething to *share*.", true)
+	due := func() {
+		t.Helper()
+		if _, err := server.DB.Exec("UPDATE bluesky_posts SET next_attempt_at = ? WHERE next_attempt_at IS NOT NULL", time.Now().Add(-time.Second).UTC()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failNext = http.StatusBadGateway
+	server.announcePending(ctx)
+	server.postToBluesky(ctx)
+	bp, err := q.GetBlueskyPost(ctx, p.ID)
+	if err != nil || bp.Attempts != 1 || bp.NextAttemptAt == nil || bp.Uri != "" {
+		t.Fatalf("after a 502: %+v, %v", bp, err)
+	}
+
+	failNext = 0
+	due()
+	server.postToBluesky(ctx)
+	if bp, err = q.GetBlueskyPost(ctx, p.ID); err != nil || bp.Uri != "at://did:plc:blog/app.bsky.feed.post/1" || bp.NextAttemptAt != nil {
+		t.Fatalf("after posting: %+v, %v", bp, err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected one post, got %d", len(records))
+	}
+	post := records[0]
+	if post.Text != "On Bluesky\n\nhttps://blog.example/post/on-bluesky" || len(post.Facets) != 1 || post.Facets[0].Features[0].URI != "https://blog.example/post/on-bluesky" {
+		t.Errorf("unexpected post: %+v", post)
+	}
+	// The image is uploaded again for each attempt.
+	if card := post.Embed; card == nil || card.External.Title != "On Bluesky" || !strings.HasSuffix(card.External.Description, "Something to share.") || card.External.Thumb == nil || thumbs != 2 {
+		t.Errorf("unexpected link card: %+v", post.Embed)
+	}
+
+	// A refusal is not retried, and a post taken down is given up on.
+	failNext = http.StatusBadRequest
+	refused := createTestPost(t, server, "refused", "Refused", "No.", true)
+	server.announcePending(ctx)
+	server.postToBluesky(ctx)
+	if bp, err := q.GetBlueskyPost(ctx, refused.ID); err != nil || bp.NextAttemptAt != nil || !strings.Contains(bp.Error, "400") {
+		t.Errorf("refused post: %+v, %v", bp, err)
+	}
+	failNext = 0
+	gone := createTestPost(t, server, "gone", "Gone", "Soon gone.", true)
+	server.announcePending(ctx)
+	if err := q.TrashPost(ctx, gone.ID); err != nil {
+		t.Fatal(err)
+	}
+	server.postToBluesky(ctx)
+	if bp, err := q.GetBlueskyPost(ctx, gone.ID); err != nil || bp.NextAttemptAt != nil || bp.Error != errNotOnSite.Error() {
+		t.Errorf("trashed post: %+v, %v", bp, err)
+	}
+	if len(records) != 1 {
+		t.Errorf("expected no more posts, got %d", len(records))
+	}
+
+	if got := blueskyText(strings.Repeat("long title ", 40), "https://blog.example/post/x"); utf8.RuneCountInString(got) > atproto.MaxPostLength || !strings.HasSuffix(got, "...\n\nhttps://blog.example/post/x") {
+		t.Errorf("long title gave %q", got)
+	}
+}
+
+func TestChatNotifications(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var messages []map[string]string
+	chat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]string
+		json.NewDecoder(r.Body).Decode(&m)
+		messages = append(messages, m)
+	}))
+	defer chat.Close()
+	q := dbgen.New(server.DB)
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"})
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingChatWebhook, Value: chat.URL + "/services/T0/B0/x"})
+
+	// daily-wiki writes its posts straight to the database.
+	if _, err := q.CreatePost(ctx, dbgen.CreatePostParams{Slug: "wiki-2026-10-15", Title: "Wiki: <Ada> & co", Content: "Today.", Published: 1}); err != nil {
+		t.Fatal(err)
+	}
+	createTestPost(t, server, "draft", "Draft", "Not yet.", false)
+	server.announcePending(ctx)
+	if len(messages) != 1 || messages[0]["text"] != "Published: <https://blog.example/post/wiki-2026-10-15|Wiki: &lt;Ada&gt; &amp; co>" {
+		t.Fatalf("expected one Slack message, got %v", messages)
+	}
+
+	got := chatMessage("https://discord.com/api/webhooks/1/abc", "Arrays [part 2]", "https://blog.example/post/arrays")
+	if want := `Published: [Arrays \[part 2\]](https://blog.example/post/arrays)`; got["content"] != want {
+		t.Errorf("Discord message = %v, expected content %q", got, want)
+	}
+}
+
+func TestNewsletter(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var sent []mail.Message
+	server.sendMail = func(ctx context.Context, c mail.Config, m mail.Message) error {
+		sent = append(sent, m)
+		return nil
+	}
+	q := dbgen.New(server.DB)
+	for k, v := range map[string]string{
+		settingSiteURL:    "https://blog.example",
+		settingSMTPServer: "smtp.example.com:587",
+		settingMailFrom:   "Blog <blog@example.com>",
+		settingNewsletter: "1",
+	} {
+		q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: k, Value: v})
+	}
+	handler := func(h http.HandlerFunc, method, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	home := handler(server.HandleHome, http.MethodGet, "/", nil)
+	if !strings.Contains(home.Body.String(), `action="/subscribe"`) {
+		t.Fatal("expected a subscribe form on the home page")
+	}
+	if w := handler(server.HandleSubscribeSubmit, http.MethodPost, "/subscribe", url.Values{"email": {"Reader <reader@example.net>"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("subscribing a named address: status %d", w.Code)
+	}
+	for range 2 {
+		if w := handler(server.HandleSubscribeSubmit, http.MethodPost, "/subscribe", url.Values{"email": {"reader@example.net"}}); w.Code != http.StatusSeeOther {
+			t.Fatalf("subscribe: status %d, body: %s", w.Code, w.Body.String())
+		}
+	}
+	server.sendQueuedMail(ctx)
+	if len(sent) != 1 || sent[0].To != "reader@example.net" {
+		t.Fatalf("expected one confirmation email, sent %+v", sent)
+	}
+	sub, err := q.GetSubscriberByEmail(ctx, "READER@example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirm := "/subscribe/confirm?token=" + url.QueryEscape(sub.Token)
+	if !strings.Contains(sent[0].Text, "https://blog.example"+confirm) {
+		t.Errorf("confirmation email has no link to %s: %s", confirm, sent[0].Text)
+	}
+
+	// Posts go only to confirmed subscribers.
+	createTestPost(t, server, "before", "Before", "Too soon.", true)
+	server.announcePending(ctx)
+	server.sendQueuedMail(ctx)
+	if len(sent) != 1 {
+		t.Fatalf("emailed an unconfirmed subscriber: %+v", sent[1:])
+	}
+	if w := handler(server.HandleSubscribeConfirm, http.MethodGet, "/subscribe/confirm?token=wrong", nil); w.Code != http.StatusNotFound {
+		t.Errorf("confirming with a wrong token: status %d", w.Code)
+	}
+	if w := handler(server.HandleSubscribeConfirm, http.MethodGet, confirm, nil); !strings.Contains(w.Body.String(), "subscribed") {
+		t.Fatalf("confirm: status %d, body: %s", w.Code, w.Body.String())
+	}
+	createTestPost(t, server, "after", "After", "Just in time.", true)
+	server.announcePending(ctx)
+	server.sendQueuedMail(ctx)
+	if len(sent) != 2 || sent[1].Subject != "After" || !strings.Contains(sent[1].Text, "https://blog.example/post/after") || !strings.Contains(sent[1].HTML, `href="https://blog.example/post/after"`) {
+		t.Fatalf("expected the new post to be emailed, sent %+v", sent[1:])
+	}
+	unsubscribe := "https://blog.example/unsubscribe?token=" + url.QueryEscape(sub.Token)
+	if sent[1].Header["List-Unsubscribe"] != "<"+unsubscribe+">" || sent[1].Header["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("unsubscribe headers = %v", sent[1].Header)
+	}
+
+	// A mail program unsubscribes in one click.
+	if w := handler(server.HandleUnsubscribeSubmit, http.MethodPost, "/unsubscribe?token="+url.QueryEscape(sub.Token), url.Values{"List-Unsubscribe": {"One-Click"}}); w.Code != http.StatusOK {
+		t.Fatalf("unsubscribe: status %d", w.Code)
+	}
+	if _, err := q.GetSubscriberByToken(ctx, sub.Token); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("subscriber still there after unsubscribing: %v", err)
+	}
+	if w := handler(server.HandleUnsubscribe, http.MethodGet, "/unsubscribe?token="+url.QueryEscape(sub.Token), nil); w.Code != http.StatusNotFound {
+		t.Errorf("unsubscribe page for an ended subscription: status %d", w.Code)
+	}
+}
+
+func TestMailQueue(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var sent []mail.Message
+	busy := true
+	server.sendMail = func(ctx context.Context, c mail.Config, m mail.Message) error {
+		if c.Server != "smtp.example.com:465" || c.TLS != mail.Implicit {
+			t.Errorf("sent with config %+v", c)
+		}
+		switch {
+		case m.To == "gone@example.com":
+			return &textproto.Error{Code: 550, Msg: "no such user"}
+		case busy:
+			busy = false
+			return &textproto.Error{Code: 451, Msg: "try again later"}
+		}
+		sent = append(sent, m)
+		return nil
+	}
+	q := dbgen.New(server.DB)
+	createTestPost(t, server, "wiki", "Wiki Discovery", "Today's article.", true)
+	comment := func() {
+		form := url.Values{"name": {"Ann"}, "email": {"ann@example.net"}, "body": {"Fascinating <stuff>."}, "token": {server.commentToken(time.Now().Add(-time.Minute))}}
+		req := httptest.NewRequest("POST", "/post/wiki/comments", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("slug", "wiki")
+		server.HandleCommentSubmit(httptest.NewRecorder(), req)
+	}
+
+	// Nothing is emailed about comments until an address is set.
+	comment()
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingCommentEmail, Value: "mod@example.com"})
+	comment()
+	if err := server.queueMail(ctx, mail.Message{To: "gone@example.com", Subject: "Hello", Text: "Hi."}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mail waits for the mail server to be set.
+	server.sendQueuedMail(ctx)
+	pending, _ := q.ListPendingMail(ctx)
+	if len(pending) != 2 || pending[0].Attempts != 0 {
+		t.Fatalf("expected two emails waiting, got %+v", pending)
+	}
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSMTPServer, Value: "smtp.example.com:465"})
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSMTPTLS, Value: "tls"})
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingMailFrom, Value: "blog@example.com"})
+
+	// A 4xx answer is tried again later; a 5xx one is given up on.
+	server.sendQueuedMail(ctx)
+	pending, _ = q.ListPendingMail(ctx)
+	if len(sent) != 0 || len(pending) != 1 || pending[0].Recipient != "mod@example.com" || !strings.HasPrefix(pending[0].Error, "451") {
+		t.Fatalf("after the first attempt, sent %+v and pending %+v", sent, pending)
+	}
+	if !pending[0].NextAttemptAt.After(time.Now().Add(mail.Retries[0] - time.Second)) {
+		t.Errorf("retry due at %v", pending[0].NextAttemptAt)
+	}
+	if _, err := server.DB.Exec("UPDATE mail_queue SET next_attempt_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Second), pending[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	server.sendQueuedMail(ctx)
+	if pending, _ = q.ListPendingMail(ctx); len(pending) != 0 || len(sent) != 1 {
+		t.Fatalf("after the retry, sent %+v and pending %+v", sent, pending)
+	}
+	m := sent[0]
+	if m.Subject != "New comment on Wiki Discovery" || !strings.Contains(m.Text, "Ann <ann@example.net> commented on Wiki Discovery:\n\nFascinating <stuff>.") {
+		t.Errorf("comment email = %+v", m)
+	}
+	if !strings.Contains(m.HTML, "Fascinating &lt;stuff&gt;.") || !strings.Contains(m.HTML, `href="http://example.com/admin/comments"`) {
+		t.Errorf("comment email HTML = %s", m.HTML)
+	}
+}
+
+func TestLoginLinks(t *testing.T) {
+	t.Setenv("DEV_MODE", "")
+	server := newTestServer(t)
+	ctx := context.Background()
+	var sent []mail.Message
+	server.sendMail = func(ctx context.Context, c mail.Config, m mail.Message) error {
+		sent = append(sent, m)
+		return nil
+	}
+	q := dbgen.New(server.DB)
+	if err := server.SetPassword(ctx, "jo@example.com", "correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	post := func(h http.HandlerFunc, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+	page := httptest.NewRecorder()
+	server.HandleLogin(page, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if strings.Contains(page.Body.String(), `action="/login/email"`) {
+		t.Error("offered sign-in links with no mail server")
+	}
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSMTPServer, Value: "smtp.example.com:587"})
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingMailFrom, Value: "blog@example.com"})
+	page = httptest.NewRecorder()
+	server.HandleLogin(page, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if !strings.Contains(page.Body.String(), `action="/login/email"`) {
+		t.Error("expected a form to ask for a sign-in link")
+	}
+
+	for _, email := range []string{"nobody@example.com", "jo@example.com"} {
+		w := post(server.HandleLoginLinkRequest, "/login/email", url.Values{"email": {email}, "redirect": {"/admin/posts"}})
+		if !strings.Contains(w.Body.String(), "an email is on its way") {
+			t.Errorf("asking for a link for %s: %s", email, w.Body.String())
+		}
+	}
+	server.sendQueuedMail(ctx)
+	if len(sent) != 1 || sent[0].To != "jo@example.com" {
+		t.Fatalf("expected one sign-in link sent, got %+v", sent)
+	}
+	match := regexp.MustCompile(`http://example\.com/login/link\?token=(\w+)`).FindStringSubmatch(sent[0].Text)
+	if match == nil {
+		t.Fatalf("no sign-in link in %s", sent[0].Text)
+	}
+
+	// Following the link only shows a button; pressing it signs in, once.
+	w := httptest.NewRecorder()
+	server.HandleLoginLink(w, httptest.NewRequest(http.MethodGet, "/login/link?token="+match[1], nil))
+	if !strings.Contains(w.Body.String(), `name="token" value="`+match[1]+`"`) {
+		t.Fatalf("expected a button to sign in, got %s", w.Body.String())
+	}
+	w = post(server.HandleLoginLinkUse, "/login/link", url.Values{"token": {match[1]}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/posts" || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("expected to be signed in, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := post(server.HandleLoginLinkUse, "/login/link", url.Values{"token": {match[1]}}); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a used link refused, got %d", w.Code)
+	}
+
+	// Only a few links work at once.
+	for range maxLoginLinks + 2 {
+		post(server.HandleLoginLinkRequest, "/login/email", url.Values{"email": {"jo@example.com"}})
+	}
+	if n, _ := q.CountLoginLinks(ctx, dbgen.CountLoginLinksParams{UserID: 1, ExpiresAt: time.Now().UTC()}); n != maxLoginLinks {
+		t.Errorf("%d links working", n)
+	}
+}
+
+func TestContact(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var sent []mail.Message
+	server.sendMail = func(ctx context.Context, c mail.Config, m mail.Message) error {
+		sent = append(sent, m)
+		return nil
+	}
+	q := dbgen.New(server.DB)
+	for k, v := range map[string]string{
+		settingSMTPServer:   "smtp.example.com:587",
+		settingMailFrom:     "Blog <blog@example.com>",
+		settingContactEmail: "me@example.com",
+	} {
+		q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: k, Value: v})
+	}
+	post := func(h http.HandlerFunc, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+	message := func(subject string) url.Values {
+		return url.Values{
+			"name":    {"Ann Reader"},
+			"email":   {"ann@example.net"},
+			"subject": {subject},
+			"body":    {"Hello there.\nI liked the post."},
+			"token":   {server.commentToken(time.Now().Add(-time.Minute))},
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleContact(w, httptest.NewRequest(http.MethodGet, "/contact", nil))
+	if body := w.Body.String(); !strings.Contains(body, `action="/contact"`) || !strings.Contains(body, `name="website"`) {
+		t.Fatalf("expected the contact form with its honeypot, got %s", body)
+	}
+	form := message("Hi")
+	form.Del("email")
+	if w := post(server.HandleContactSubmit, "/contact", form); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Ann Reader") {
+		t.Errorf("sending without an email address: status %d", w.Code)
+	}
+
+	// Bots are answered as if their messages were sent.
+	form = message("Buy now")
+	form.Set("website", "https://spam.example")
+	fresh := message("Buy now")
+	fresh.Set("token", server.commentToken(time.Now()))
+	for _, form := range []url.Values{form, fresh} {
+		if w := post(server.HandleContactSubmit, "/contact", form); w.Code != http.StatusSeeOther {
+			t.Errorf("bot message: status %d", w.Code)
+		}
+	}
+	if counts, _ := q.CountContactMessages(ctx); counts.Total != 0 {
+		t.Fatalf("kept %d messages from bots", counts.Total)
+	}
+
+	for i := range maxContactMessages {
+		w := post(server.HandleContactSubmit, "/contact", message(fmt.Sprint("Hi ", i)))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/contact?sent=1" {
+			t.Fatalf("send message %d: status %d, body: %s", i, w.Code, w.Body.String())
+		}
+	}
+	if w := post(server.HandleContactSubmit, "/contact", message("Once more")); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a message over the limit refused, got %d", w.Code)
+	}
+	server.sendQueuedMail(ctx)
+	if len(sent) != maxContactMessages || sent[0].To != "me@example.com" || sent[0].Subject != "Hi 0" {
+		t.Fatalf("sent %+v", sent)
+	}
+	if sent[0].Header["Reply-To"] != `"Ann Reader" <ann@example.net>` || !strings.Contains(sent[0].Text, "I liked the post.") {
+		t.Errorf("email = %+v", sent[0])
+	}
+
+	w = httptest.NewRecorder()
+	server.HandleAdminMessages(w, httptest.NewRequest(http.MethodGet, "/admin/messages", nil))
+	if body := w.Body.String(); strings.Count(body, `class="unread"`) != maxContactMessages || !strings.Contains(body, "I liked the post.") {
+		t.Fatalf("expected the unread messages listed, got %s", body)
+	}
+	messages, err := q.ListContactMessages(ctx, dbgen.ListContactMessagesParams{MaxMessages: 10})
+	if err != nil || len(messages) != maxContactMessages {
+		t.Fatalf("messages = %+v, %v", messages, err)
+	}
+	id := fmt.Sprint(messages[0].ID)
+	if w := post(server.HandleAdminMessageAction, "/admin/messages", url.Values{"id": {id}, "action": {"read"}}); w.Code != http.StatusFound {
+		t.Fatalf("mark read: status %d", w.Code)
+	}
+	if counts, _ := q.CountContactMessages(ctx); counts.Unread != maxContactMessages-1 {
+		t.Errorf("%d unread after marking one read", counts.Unread)
+	}
+	post(server.HandleAdminMessageAction, "/admin/messages", url.Values{"id": {id}, "action": {"delete"}})
+	if counts, _ := q.CountContactMessages(ctx); counts.Total != maxContactMessages-1 {
+		t.Errorf("%d messages after deleting one", counts.Total)
+	}
+}
+
+func TestLikes(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	p := createTestPost(t, server, "liked", "Liked", "Worth a like.", true)
+	createTestPost(t, server, "draft", "Draft", "Not yet.", false)
+	like := func(slug, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/post/"+slug+"/like", nil)
+		req.SetPathValue("slug", slug)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		server.HandleLike(w, req)
+		return w
+	}
+
+	for _, remote := range []string{"198.51.100.7:1234", "198.51.100.7:5678", "[2001:db8::1]:443", "[2001:db8::2]:443", "203.0.113.9:80"} {
+		if w := like("liked", remote); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/post/liked?liked=1#likes" {
+			t.Fatalf("like from %s: status %d to %q", remote, w.Code, w.Header().Get("Location"))
+		}
+	}
+	if n := server.postLikes(ctx, p.ID); n != 3 {
+		t.Errorf("%d likes, want one from each of 3 readers", n)
+	}
+	if w := like("draft", "198.51.100.7:1234"); w.Code != http.StatusNotFound {
+		t.Errorf("liking a draft: status %d", w.Code)
+	}
+	if key := server.setting(ctx, settingLikeKey); key == "" {
+		t.Error("expected the like key stored")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/post/liked?liked=1", nil)
+	req.SetPathValue("slug", "liked")
+	w := httptest.NewRecorder()
+	server.HandlePost(w, req)
+	if body := w.Body.String(); !strings.Contains(body, `<span aria-hidden="true">♥</span> 3</button>`) || !strings.Contains(body, "Thanks for the like!") {
+		t.Errorf("expected the like count on the post page, got %s", body)
+	}
+
+	createTestPost(t, server, "also", "Also liked", "Another.", true)
+	like("also", "198.51.100.7:1234")
+	for sort, first := range map[string]string{"likes": "Liked", "title": "Also liked", "": "Liked"} {
+		w := httptest.NewRecorder()
+		server.HandleAdminLikes(w, httptest.NewRequest(http.MethodGet, "/admin/likes?sort="+sort, nil))
+		body := w.Body.String()
+		if i, j := strings.Index(body, ">Liked</a>"), strings.Index(body, ">Also liked</a>"); i < 0 || j < 0 || (first == "Liked") != (i < j) {
+			t.Errorf("sort %q: expected %q first, got %s", sort, first, body)
+		}
+	}
+}
+
+func TestPostViews(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	p := createTestPost(t, server, "read", "Read Me", "Worth reading.", true)
+	view := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/post/read", nil)
+		req.SetPathValue("slug", "read")
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		return w
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			view(http.MethodGet)
+		}()
+	}
+	wg.Wait()
+	view(http.MethodHead)
+	if n, _ := q.CountViewsSince(ctx, "2000-01-01"); n != 0 {
+		t.Errorf("%d views written before a flush", n)
+	}
+	server.flushViews(ctx)
+	server.countView(p.ID, "")
+	server.flushViews(ctx)
+	server.flushViews(ctx)
+	if totals := postViewTotals(ctx, q); totals[p.ID] != 11 {
+		t.Errorf("views = %v, want 11 for post %d", totals, p.ID)
+	}
+
+	// Views of a post deleted before they are written are dropped.
+	gone := createTestPost(t, server, "gone", "Gone", "Soon gone.", true)
+	server.countView(gone.ID, "example.net")
+	if _, err := server.DB.Exec("DELETE FROM posts WHERE id = ?", gone.ID); err != nil {
+		t.Fatal(err)
+	}
+	server.countView(p.ID, "")
+	server.flushViews(ctx)
+	if totals := postViewTotals(ctx, q); totals[p.ID] != 12 || len(totals) != 1 || server.views != nil {
+		t.Errorf("views = %v, unwritten %v", totals, server.views)
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleAdminList(w, httptest.NewRequest(http.MethodGet, "/admin/posts", nil))
+	if !strings.Contains(w.Body.String(), "<td>12</td>") {
+		t.Errorf("expected the views in the post list, got %s", w.Body.String())
+	}
+}
+
+func TestPopular(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	today := time.Now().UTC()
+	for _, v := range []struct {
+		slug      string
+		published bool
+		daysAgo   int
+		views     int64
+	}{
+		{"steady", true, 0, 3},
+		{"steady", true, 5, 2},
+		{"viral", true, 1, 8},
+		{"old-news", true, 20, 50},
+		{"hidden", false, 0, 100},
+	} {
+		p, err := q.GetPostBySlug(ctx, v.slug)
+		if err != nil {
+			p = createTestPost(t, server, v.slug, strings.ToUpper(v.slug), "Text.", v.published)
+		}
+		day := today.AddDate(0, 0, -v.daysAgo).Format(time.DateOnly)
+		if err := q.AddPostViews(ctx, dbgen.AddPostViewsParams{PostID: p.ID, Day: day, Views: v.views}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingPopularDays, Value: "7"})
+
+	w := httptest.NewRecorder()
+	server.HandlePopular(w, httptest.NewRequest(http.MethodGet, "/popular", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "<h1>Popular this week</h1>") {
+		t.Errorf("expected the window in the heading, got %s", body)
+	}
+	if viral, steady := strings.Index(body, ">VIRAL<"), strings.Index(body, ">STEADY<"); viral < 0 || steady < viral {
+		t.Errorf("expected VIRAL before STEADY, got %s", body)
+	}
+	if strings.Contains(body, "OLD-NEWS") || strings.Contains(body, "HIDDEN") {
+		t.Errorf("expected only published posts viewed this week, got %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	server.HandleHome(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := w.Body.String(); !strings.Contains(body, "<h2>Popular this week</h2>") || !strings.Contains(body, `<li><a href="/post/viral">VIRAL</a></li>`) {
+		t.Errorf("expected popular posts on the home page, got %s", body)
+	}
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingPopularDays, Value: "30"})
+	w = httptest.NewRecorder()
+	server.HandlePopular(w, httptest.NewRequest(http.MethodGet, "/popular", nil))
+	if body := w.Body.String(); !strings.Contains(body, "<h1>Popular this month</h1>") || strings.Index(body, ">OLD-NEWS<") > strings.Index(body, ">VIRAL<") {
+		t.Errorf("expected a month's views ranked, got %s", body)
+	}
+	if got := popularPeriod(14); got != "in the last 14 days" {
+		t.Errorf("popularPeriod(14) = %q", got)
+	}
+}
+
+func TestReferrers(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	dbgen.New(server.DB).UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"})
+	createTestPost(t, server, "linked", "Linked Post", "Linked to.", true)
+	createTestPost(t, server, "other", "Other Post", "Also linked to.", true)
+	view := func(slug, referer string) {
+		req := httptest.NewRequest(http.MethodGet, "/post/"+slug, nil)
+		req.SetPathValue("slug", slug)
+		req.Host = "localhost:8000"
+		if referer != "" {
+			req.Header.Set("Referer", referer)
+		}
+		server.HandlePost(httptest.NewRecorder(), req)
+	}
+	for _, referer := range []string{
+		"https://en.wikipedia.org/wiki/Citizen",
+		"https://en.wikipedia.org/wiki/World",
+		"https://www.Example.net/links",
+		"https://example.net/",
+		"https://news.example.org/item?id=1",
+		"https://blog.example/",
+		"http://localhost:8000/tags",
+		"android-app://com.example.reader/",
+		"",
+	} {
+		view("linked", referer)
+	}
+	view("other", "https://news.example.org/item?id=2")
+	server.flushViews(ctx)
+
+	w := httptest.NewRecorder()
+	server.HandleAdminAnalytics(w, httptest.NewRequest(http.MethodGet, "/admin/analytics?days=7", nil))
+	body := w.Body.String()
+	_, byPost, _ := strings.Cut(body, "Referrers by post")
+	for _, want := range []string{
+		"<td>en.wikipedia.org</td>\n                        <td>2</td>",
+		"<td>example.net</td>\n                        <td>2</td>",
+		"<td>news.example.org</td>\n                        <td>2</td>",
+		`class="active">Last 7 days</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q, got %s", want, body)
+		}
+	}
+	if !strings.Contains(byPost, `<td><a href="/post/other">Other Post</a></td>
+                        <td>news.example.org</td>
+                        <td>1</td>`) {
+		t.Errorf("expected the referrers of each post, got %s", byPost)
+	}
+	for _, own := range []string{"blog.example<", "localhost<", "com.example.reader"} {
+		if strings.Contains(body, own) {
+			t.Errorf("expected %s not counted", own)
+		}
+	}
+}
+
+func TestAnalytics(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	createTestPost(t, server, "counted", "Counted Post", "Counted.", true)
+	visit := func(method, path, addr string, h http.HandlerFunc, values map[string]string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = addr + ":1234"
+		req.Header.Set("User-Agent", "Test Browser")
+		req.Header.Set("Accept-Language", "en")
+		for k, v := range values {
+			req.SetPathValue(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.track(h)(w, req)
+		return w.Code
+	}
+	visit(http.MethodGet, "/", "192.0.2.1", server.HandleHome, nil)
+	visit(http.MethodGet, "/", "192.0.2.1", server.HandleHome, nil)
+	visit(http.MethodGet, "/", "192.0.2.2", server.HandleHome, nil)
+	visit(http.MethodGet, "/tags", "192.0.2.2", server.HandleTags, nil)
+	visit(http.MethodGet, "/post/counted", "192.0.2.1", server.HandlePost, map[string]string{"slug": "counted"})
+	visit(http.MethodHead, "/tags", "192.0.2.3", server.HandleTags, nil)
+	if code := visit(http.MethodGet, "/tag/missing", "192.0.2.3", server.HandleTag, map[string]string{"tag": "missing"}); code != http.StatusNotFound {
+		t.Errorf("unknown tag status = %d", code)
+	}
+	server.flushViews(ctx)
+
+	w := httptest.NewRecorder()
+	server.HandleAdminAnalytics(w, httptest.NewRequest(http.MethodGet, "/admin/analytics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"<strong>5</strong> page views",
+		"<strong>2</strong> daily visitors",
+		"<td><a href=\"/\">/</a></td>\n                        <td>3</td>",
+		"<td><a href=\"/post/counted\">/post/counted</a></td>\n                        <td>1</td>",
+		"<td><a href=\"/tags\">/tags</a></td>\n                        <td>1</td>",
+		`height: 100%`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q, got %s", want, body)
+		}
+	}
+	if strings.Contains(body, "/tag/missing") {
+		t.Error("expected a page not found not counted")
+	}
+	var stored int
+	server.DB.QueryRow("SELECT COUNT(*) FROM daily_visitors WHERE visitor LIKE '%192.0.2%'").Scan(&stored)
+	if stored != 0 {
+		t.Error("expected visitors' addresses not stored")
+	}
+
+	// The salt is replaced on a new day, and the old one forgotten.
+	today := time.Now().UTC().Format(time.DateOnly)
+	salt, err := server.dailySalt(ctx, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.GetSetting(ctx, settingVisitorSalt); got != today+" "+salt {
+		t.Errorf("stored salt = %q, want today's %q", got, salt)
+	}
+	if again, _ := server.dailySalt(ctx, today); again != salt {
+		t.Errorf("salt changed within a day: %q, then %q", salt, again)
+	}
+	next, err := server.dailySalt(ctx, "2999-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.GetSetting(ctx, settingVisitorSalt); next == salt || strings.Contains(got, salt) {
+		t.Errorf("expected the old salt replaced, stored %q", got)
+	}
+}
+
+func TestBots(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	p := createTestPost(t, server, "crawled", "Crawled Post", "Crawled.", true)
+	const browser = "Mozilla/5.0 (X11; Linux x86_64; rv:140.0) Gecko/20100101 Firefox/140.0"
+	request := func(method, target, addr, agent, language string) *http.Request {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = addr + ":1234"
+		req.Header.Set("User-Agent", agent)
+		if language != "" {
+			req.Header.Set("Accept-Language", language)
+		}
+		return req
+	}
+	view := func(addr, agent, language string) {
+		req := request(http.MethodGet, "/post/crawled", addr, agent, language)
+		req.SetPathValue("slug", "crawled")
+		server.track(server.HandlePost)(httptest.NewRecorder(), req)
+	}
+	views := func() (post, pages int64) {
+		server.flushViews(ctx)
+		server.DB.QueryRow("SELECT COALESCE(SUM(views), 0) FROM page_views").Scan(&pages)
+		return postViewTotals(ctx, dbgen.New(server.DB))[p.ID], pages
+	}
+
+	view("192.0.2.1", browser, "en-GB,en;q=0.8")
+	view("192.0.2.2", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "")
+	view("192.0.2.3", "curl/8.5.0", "en")
+	view("192.0.2.4", "", "en")
+	view("192.0.2.5", browser, "")
+	if post, pages := views(); post != 1 || pages != 1 {
+		t.Errorf("views = %d of the post and %d of pages, want only the browser's", post, pages)
+	}
+
+	// Asking for robots.txt marks the sender as a bot for the day.
+	server.HandleRobots(httptest.NewRecorder(), request(http.MethodGet, "/robots.txt", "192.0.2.6", browser, "en"))
+	view("192.0.2.6", browser, "en")
+	// As does going through pages faster than anyone reads.
+	for range botPageRate + 5 {
+		view("192.0.2.7", browser, "en")
+	}
+	if post, pages := views(); post != botPageRate+1 || pages != botPageRate+1 {
+		t.Errorf("views = %d of the post and %d of pages, want %d", post, pages, botPageRate+1)
+	}
+
+	// The admin can add patterns, but not one matching their own browser.
+	add := func(pattern string) *httptest.ResponseRecorder {
+		req := request(http.MethodPost, "/admin/bots", "192.0.2.1", browser, "en")
+		req.Form = url.Values{"pattern": {pattern}, "note": {"A feed reader"}}
+		w := httptest.NewRecorder()
+		server.HandleAdminBotCreate(w, req)
+		return w
+	}
+	if w := add("Firefox"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "your own browser") {
+		t.Errorf("expected a pattern matching the admin's browser refused, got %d", w.Code)
+	}
+	if w := add("NewsReader"); w.Code != http.StatusFound {
+		t.Fatalf("add pattern status = %d", w.Code)
+	}
+	view("192.0.2.8", "NewsReader/2.0 (like Gecko)", "en")
+	if post, _ := views(); post != botPageRate+1 {
+		t.Errorf("views = %d, want the new pattern's request left out", post)
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleAdminBots(w, request(http.MethodGet, "/admin/bots", "192.0.2.1", browser, "en"))
+	body := w.Body.String()
+	for _, want := range []string{
+		"<td><code>NewsReader</code></td>\n                    <td>A feed reader</td>\n                    <td>1</td>",
+		"<td><code>curl/</code></td>",
+		"<td>asked for robots.txt</td>\n                    <td>1</td>",
+		"<td>too many pages a minute</td>\n                    <td>5</td>",
+		"<td>no Accept-Language header</td>\n                    <td>1</td>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q, got %s", want, body)
+		}
+	}
+
+	patterns, err := dbgen.New(server.DB).ListBotPatterns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bp := range patterns {
+		if bp.Pattern != "NewsReader" {
+			continue
+		}
+		req := httptest.NewRequest(http.MethodPost, "/admin/bots/delete/"+strconv.FormatInt(bp.ID, 10), nil)
+		req.SetPathValue("id", strconv.FormatInt(bp.ID, 10))
+		server.HandleAdminBotDelete(httptest.NewRecorder(), req)
+	}
+	view("192.0.2.8", "NewsReader/2.0 (like Gecko)", "en")
+	if post, _ := views(); post != botPageRate+2 {
+		t.Errorf("views = %d, want the request counted once its pattern is removed", post)
+	}
+}
+
+func TestOutboundLinks(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingSiteURL, Value: "https://blog.example"})
+	p := createTestPost(t, server, "linked", "Linking Post", "See [citizenship](https://en.wikipedia.org/wiki/Citizenship), [about](/about) and [home](https://www.blog.example/).", true)
+	const browser = "Mozilla/5.0 (X11; Linux x86_64; rv:140.0) Gecko/20100101 Firefox/140.0"
+	page := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/post/linked", nil)
+		req.SetPathValue("slug", "linked")
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		return w.Body.String()
+	}
+	follow := func(target, agent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("User-Agent", agent)
+		req.Header.Set("Accept-Language", "en")
+		w := httptest.NewRecorder()
+		server.HandleOut(w, req)
+		return w
+	}
+
+	if body := page(); !strings.Contains(body, `href="https://en.wikipedia.org/wiki/Citizenship"`) || strings.Contains(body, "/out?") {
+		t.Errorf("expected links left as they are while clicks are not counted, got %s", body)
+	}
+
+	q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingTrackLinks, Value: "1"})
+	server.renders.clear()
+	body := page()
+	m := regexp.MustCompile(`href="(/out\?[^"]+)"`).FindAllStringSubmatch(body, -1)
+	if len(m) != 1 {
+		t.Fatalf("expected only the link to another site through /out, got %s", body)
+	}
+	for _, kept := range []string{`href="/about"`, `href="https://www.blog.example/"`} {
+		if !strings.Contains(body, kept) {
+			t.Errorf("expected %s left as it is", kept)
+		}
+	}
+	out := html.UnescapeString(m[0][1])
+
+	w := follow(out, browser)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://en.wikipedia.org/wiki/Citizenship" {
+		t.Errorf("follow = %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	follow(out, browser)
+	if w := follow(out, "Mozilla/5.0 (compatible; Googlebot/2.1)"); w.Code != http.StatusFound {
+		t.Errorf("bot follow = %d, want it sent on but not counted", w.Code)
+	}
+	for _, bad := range []string{
+		strings.Replace(out, "Citizenship", "Phishing", 1),
+		strings.Replace(out, "p="+strconv.FormatInt(p.ID, 10), "p=999", 1),
+		"/out?u=https%3A%2F%2Fevil.example%2F&p=1&s=",
+	} {
+		if w := follow(bad, browser); w.Code != http.StatusBadRequest {
+			t.Errorf("follow %s = %d, want it refused", bad, w.Code)
+		}
+	}
+	server.flushViews(ctx)
+
+	w = httptest.NewRecorder()
+	server.HandleAdminAnalytics(w, httptest.NewRequest(http.MethodGet, "/admin/analytics", nil))
+	analytics := w.Body.String()
+	for _, want := range []string{
+		"<td><a href=\"https://en.wikipedia.org/wiki/Citizenship\" rel=\"noopener\">https://en.wikipedia.org/wiki/Citizenship</a></td>\n                        <td>2</td>",
+		"<td><a href=\"/post/linked\">Linking Post</a></td>\n                        <td><a href=\"https://en.wikipedia.org/wiki/Citizenship\"",
+	} {
+		if !strings.Contains(analytics, want) {
+			t.Errorf("expected %q, got %s", want, analytics)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	server.HandleRobots(w, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+	if !strings.Contains(w.Body.String(), "Disallow: /out\n") {
+		t.Errorf("expected /out kept from crawlers, got %s", w.Body.String())
+	}
+}
+
+func TestMetaDescription(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "described", "Described", "The *first* words.", true)
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/post/described", nil)
+		req.SetPathValue("slug", "described")
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		return w.Body.String()
+	}
+
+	if body := get(); !strings.Contains(body, `<meta name="description" content="The first words.">`) {
+		t.Errorf("expected description to fall back to the content, got body: %s", body)
+	}
+
+	form := url.Values{"title": {"Described"}, "content": {"The *first* words."}, "published": {"on"}, "meta_description": {" A hand-written summary. "}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+strconv.FormatInt(p.ID, 10), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+	server.HandleAdminUpdate(httptest.NewRecorder(), req)
+
+	body := get()
+	for _, expected := range []string{
+		`<meta name="description" content="A hand-written summary.">`,
+		`<meta property="og:description" content="A hand-written summary.">`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected post page to contain %q, got body: %s", expected, body)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "summed", "Summed Up", "The opening words of the post.", true)
+	update := func(excerpt string) {
+		t.Helper()
+		form := url.Values{"title": {"Summed Up"}, "content": {"The opening words of the post."}, "published": {"on"}, "excerpt": {excerpt}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+strconv.FormatInt(p.ID, 10), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+		server.HandleAdminUpdate(httptest.NewRecorder(), req)
+	}
+	get := func(handler http.HandlerFunc, path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetPathValue("slug", "summed")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w.Body.String()
+	}
+
+	update(" In short: a test. ")
+	if body := get(server.HandleHome, "/"); !strings.Contains(body, "<p>In short: a test.</p>") || strings.Contains(body, "opening words") {
+		t.Errorf("expected the excerpt on the home page, got body: %s", body)
+	}
+	if body := get(server.HandleAtom, "/atom.xml"); !strings.Contains(body, "<summary>In short: a test.</summary>") {
+		t.Errorf("expected the excerpt as the feed summary, got body: %s", body)
+	}
+	if body := get(server.HandlePost, "/post/summed"); !strings.Contains(body, `<meta name="description" content="In short: a test.">`) {
+		t.Errorf("expected the excerpt as the description, got body: %s", body)
+	}
+
+	update("")
+	if body := get(server.HandleHome, "/"); !strings.Contains(body, "<p>The opening words of the post.</p>") {
+		t.Errorf("expected the start of the content without an excerpt, got body: %s", body)
+	}
+}
+
+// uploadTestFile posts data to the upload handler as the file field of a
+// multipart form, with the given file name.
+func uploadTestFile(t *testing.T, server *Server, name string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/admin/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	server.HandleAdminUpload(w, req)
+	return w
+}
+
+// testPNG returns a PNG image of the given size.
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMediaUpload(t *testing.T) {
+	server := newTestServer(t)
+	data := testPNG(t, 40, 30)
+
+	w := uploadTestFile(t, server, "Harbour view.png", data)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for an image upload, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp uploadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(resp.URL, "/media/") || !strings.HasSuffix(resp.URL, ".png") {
+		t.Errorf("expected a /media/ URL ending in .png, got %q", resp.URL)
+	}
+	if expected := "![Harbour view](" + resp.URL + ")"; resp.Markdown != expected {
+		t.Errorf("expected markdown %q, got %q", expected, resp.Markdown)
+	}
+	m, err := dbgen.New(server.DB).GetMediaByFilename(context.Background(), strings.TrimPrefix(resp.URL, "/media/"))
+	if err != nil || m.Width != 40 || m.Height != 30 || m.ContentType != "image/png" {
+		t.Errorf("expected a 40x30 PNG recorded, got %+v (%v)", m, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, resp.URL, nil)
+	req.SetPathValue("name", strings.TrimPrefix(resp.URL, "/media/"))
+	w = httptest.NewRecorder()
+	server.HandleMedia(w, req)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) || w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("expected the uploaded image served, got %d with type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	// The same image again, pasted into the editor as a data: URL, is
+	// not stored twice.
+	body, _ := json.Marshal(uploadRequest{Filename: "image.png", Data: "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), Alt: "Pasted"})
+	req = httptest.NewRequest(http.MethodPost, "/admin/upload", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	server.HandleAdminUpload(w, req)
+	var again uploadResponse
+	json.Unmarshal(w.Body.Bytes(), &again)
+	if w.Code != http.StatusOK || again.URL != resp.URL || again.Markdown != "![Pasted]("+resp.URL+")" {
+		t.Errorf("expected the earlier upload for the same image, got %d: %s", w.Code, w.Body.String())
+	}
+	other, _ := json.Marshal(uploadRequest{Data: base64.StdEncoding.EncodeToString(testPNG(t, 4, 4))})
+	req = httptest.NewRequest(http.MethodPost, "/admin/upload", bytes.NewReader(other))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	server.HandleAdminUpload(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected 201 for a new base64 image, got %d: %s", w.Code, w.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodPost, "/admin/upload", strings.NewReader(`{"data": "not base64!"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	server.HandleAdminUpload(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad base64, got %d", w.Code)
+	}
+
+	if w := uploadTestFile(t, server, "notes.txt", []byte("just some text")); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for a text file, got %d", w.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/media/missing.png", nil)
+	req.SetPathValue("name", "missing.png")
+	w = httptest.NewRecorder()
+	server.HandleMedia(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown file, got %d", w.Code)
+	}
+}
+
+func TestMediaUploadStripsMetadata(t *testing.T) {
+	server := newTestServer(t)
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 6, 4)))
+	clean := buf.Bytes()
+	// A text chunk after the signature and IHDR, as photo tools add.
+	text := "Location\x0051.5N 0.1W"
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(chunk, "tEXt"+text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE([]byte("tEXt"+text)))
+	data := slices.Concat(clean[:33], chunk, clean[33:])
+
+	stored := func(w *httptest.ResponseRecorder) []byte {
+		t.Helper()
+		var resp uploadResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode upload response: %v", err)
+		}
+		b, err := os.ReadFile(filepath.Join(server.MediaDir, strings.TrimPrefix(resp.URL, "/media/")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if b := stored(uploadTestFile(t, server, "photo.png", data)); !bytes.Equal(b, clean) {
+		t.Errorf("expected the metadata removed from the stored image")
+	}
+
+	body, _ := json.Marshal(uploadRequest{Filename: "photo.png", Data: base64.StdEncoding.EncodeToString(data), KeepMetadata: true})
+	req := httptest.NewRequest(http.MethodPost, "/admin/upload", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.HandleAdminUpload(w, req)
+	if b := stored(w); !bytes.Equal(b, data) {
+		t.Errorf("expected the metadata kept when asked to")
+	}
+
+	if w := uploadTestFile(t, server, "broken.png", data[:40]); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a truncated image, got %d", w.Code)
+	}
+}
+
+func TestMediaVariants(t *testing.T) {
+	server := newTestServer(t)
+	var resp uploadResponse
+	if err := json.Unmarshal(uploadTestFile(t, server, "wide.png", testPNG(t, 1000, 500)).Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode upload response: %v", err)
+	}
+	name := strings.TrimPrefix(resp.URL, "/media/")
+	get := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, resp.URL+query, nil)
+		req.SetPathValue("name", name)
+		w := httptest.NewRecorder()
+		server.HandleMedia(w, req)
+		return w
+	}
+
+	tests := []struct {
+		query   string
+		expectW int
+		expectH int
+	}{
+		{"", 1000, 500},
+		{"?w=300", 400, 200},
+		{"?w=400&h=400", 400, 400},
+		{"?h=96", 192, 96},
+		{"?w=2000", 1000, 500},
+	}
+	for _, tt := range tests {
+		w := get(tt.query)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("%q: expected a PNG, got %d with type %q", tt.query, w.Code, w.Header().Get("Content-Type"))
+			continue
+		}
+		cfg, err := png.DecodeConfig(w.Body)
+		if err != nil || cfg.Width != tt.expectW || cfg.Height != tt.expectH {
+			t.Errorf("%q: expected %dx%d, got %dx%d (%v)", tt.query, tt.expectW, tt.expectH, cfg.Width, cfg.Height, err)
+		}
+	}
+	if w := get("?w=wide"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad width, got %d", w.Code)
+	}
+
+	variants, _ := filepath.Glob(filepath.Join(server.MediaDir, "variants", "*"))
+	if len(variants) != 3 {
+		t.Errorf("expected 3 cached variants, got %v", variants)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/media/delete/"+strconv.FormatInt(resp.ID, 10), nil)
+	req.SetPathValue("id", strconv.FormatInt(resp.ID, 10))
+	server.HandleAdminMediaDelete(httptest.NewRecorder(), req)
+	if variants, _ := filepath.Glob(filepath.Join(server.MediaDir, "variants", "*")); len(variants) != 0 {
+		t.Errorf("expected variants removed with the image, got %v", variants)
+	}
+
+	if got := sizedImage(resp.URL, 800, 400); got != resp.URL+"?w=800&h=400" {
+		t.Errorf("sizedImage(%q) = %q", resp.URL, got)
+	}
+	if got := sizedImage("https://example.com/a.jpg", 800, 400); got != "https://example.com/a.jpg" {
+		t.Errorf("expected other images left alone, got %q", got)
+	}
+}
+
+// fakeEncoder returns the path of a script that stands in for an image
+// encoder by writing n bytes to the last of its arguments.
+func fakeEncoder(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "encode")
+	script := "#!/bin/sh\nfor out; do :; done\nhead -c " + strconv.Itoa(n) + " /dev/zero > \"$out\"\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMediaFormats(t *testing.T) {
+	server := newTestServer(t)
+	data := testPNG(t, 1000, 500)
+	var resp uploadResponse
+	if err := json.Unmarshal(uploadTestFile(t, server, "wide.png", data).Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode upload response: %v", err)
+	}
+	// The AVIF copy comes out larger than the PNG, so it is not used.
+	server.Encoders = map[string]string{
+		"image/webp": fakeEncoder(t, 10),
+		"image/avif": fakeEncoder(t, len(data)+1),
+	}
+	get := func(query, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, resp.URL+query, nil)
+		req.SetPathValue("name", strings.TrimPrefix(resp.URL, "/media/"))
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		server.HandleMedia(w, req)
+		return w
+	}
+
+	tests := []struct {
+		query    string
+		accept   string
+		expected string
+	}{
+		{"", "image/avif,image/webp,image/*,*/*;q=0.8", "image/webp"},
+		{"?w=400", "image/webp,*/*", "image/webp"},
+		{"", "image/png,image/*;q=0.8", "image/png"},
+		{"", "image/webp;q=0,*/*", "image/png"},
+	}
+	for _, tt := range tests {
+		w := get(tt.query, tt.accept)
+		if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || ct != tt.expected {
+			t.Errorf("%q accepting %q: expected %s, got %d with type %q", tt.query, tt.accept, tt.expected, w.Code, ct)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("%q accepting %q: expected Vary: Accept, got %q", tt.query, tt.accept, vary)
+		}
+	}
+	if w := get("", "image/webp"); w.Body.Len() != 10 {
+		t.Errorf("expected the WebP copy served, got %d bytes", w.Body.Len())
+	}
+	if variants, _ := filepath.Glob(filepath.Join(server.MediaDir, "variants", "*.webp")); len(variants) != 2 {
+		t.Errorf("expected WebP copies of the image and its variant, got %v", variants)
+	}
+}
+
+func TestMediaStoreSetting(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	if store, ok := server.mediaStore(ctx).(mediastore.Disk); !ok || store.Dir != server.MediaDir {
+		t.Errorf("expected the media directory by default, got %#v", server.mediaStore(ctx))
+	}
+
+	q := dbgen.New(server.DB)
+	for key, value := range map[string]string{
+		settingS3Bucket:    "blog-media",
+		settingS3Endpoint:  "http://minio:9000",
+		settingS3AccessKey: "access",
+		settingS3SecretKey: "secret",
+	} {
+		if err := q.UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: key, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := mediastore.S3{Endpoint: "http://minio:9000", Bucket: "blog-media", AccessKey: "access", SecretKey: "secret"}
+	if store := server.mediaStore(ctx); store != expected {
+		t.Errorf("expected %#v, got %#v", expected, store)
+	}
+
+	if _, err := normalizeBucket("Blog_Media"); err == nil {
+		t.Error("expected an invalid bucket name rejected")
+	}
+}
+
+func TestMediaLibrary(t *testing.T) {
+	server := newTestServer(t)
+	upload := func(name string, size int) uploadResponse {
+		t.Helper()
+		var resp uploadResponse
+		if err := json.Unmarshal(uploadTestFile(t, server, name, testPNG(t, size, size)).Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode upload response: %v", err)
+		}
+		return resp
+	}
+	used, unused := upload("used.png", 2), upload("unused.png", 3)
+	createTestPost(t, server, "pictured", "Pictured Post", "Look:\n\n"+used.Markdown, true)
+	library := func() string {
+		w := httptest.NewRecorder()
+		server.HandleAdminMedia(w, httptest.NewRequest(http.MethodGet, "/admin/media", nil))
+		return w.Body.String()
+	}
+	del := func(id int64) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/media/delete/"+strconv.FormatInt(id, 10), nil)
+		req.SetPathValue("id", strconv.FormatInt(id, 10))
+		server.HandleAdminMediaDelete(httptest.NewRecorder(), req)
+	}
+
+	body := library()
+	for _, expected := range []string{`src="` + used.URL + `"`, "Pictured Post", "Not used", "unused.png"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected media library to contain %q, got body: %s", expected, body)
+		}
+	}
+
+	// The editor offers uploaded images to insert.
+	w := httptest.NewRecorder()
+	server.HandleAdminNew(w, httptest.NewRequest(http.MethodGet, "/admin/new", nil))
+	if body := w.Body.String(); !strings.Contains(body, `data-markdown="![unused](`+unused.URL+`)"`) {
+		t.Errorf("expected the editor to offer uploaded images, got body: %s", body)
+	}
+
+	del(used.ID)
+	del(unused.ID)
+	body = library()
+	if !strings.Contains(body, "used.png") || strings.Contains(body, "unused.png") {
+		t.Errorf("expected only the unused image deleted, got body: %s", body)
+	}
+	if _, err := os.Stat(filepath.Join(server.MediaDir, strings.TrimPrefix(unused.URL, "/media/"))); !os.IsNotExist(err) {
+		t.Errorf("expected the deleted image's file removed, got %v", err)
+	}
+}
+
+func TestShareImage(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	createTestPost(t, server, "plain", "Plain", "No pictures.", true)
+	createTestPost(t, server, "pictured", "Pictured", "![A view](view.jpg)", true)
+	p, err := dbgen.New(server.DB).CreatePost(ctx, dbgen.CreatePostParams{
+		Slug: "chosen", Title: "Chosen", Content: "![A view](view.jpg)", Published: 1, OgImage: "/static/share.png",
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+	dbgen.New(server.DB).UpsertSetting(ctx, dbgen.UpsertSettingParams{Key: settingDefaultOGImage, Value: "https://cdn.example/default.png"})
+
+	tests := []struct {
+		slug     string
+		expected string
+	}{
+		{"plain", "https://cdn.example/default.png"},
+		{"pictured", "http://example.com/post/view.jpg"},
+		{p.Slug, "http://example.com/static/share.png"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/post/"+tt.slug, nil)
+		req.SetPathValue("slug", tt.slug)
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		if expected := `<meta property="og:image" content="` + tt.expected + `">`; !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("%s: expected %q, got body: %s", tt.slug, expected, w.Body.String())
+		}
+	}
+
+	form := url.Values{"title": {"Chosen"}, "og_image": {"javascript:alert(1)"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+strconv.FormatInt(p.ID, 10), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+	w := httptest.NewRecorder()
+	server.HandleAdminUpdate(w, req)
+	if !strings.Contains(w.Body.String(), "Share image must be") {
+		t.Errorf("expected invalid share image to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCoverImage(t *testing.T) {
+	server := newTestServer(t)
+	_, err := dbgen.New(server.DB).CreatePost(context.Background(), dbgen.CreatePostParams{
+		Slug: "covered", Title: "Covered", Content: "Body.", Published: 1, CoverImage: "/static/cover.jpg",
+	})
+	if err != nil {
+		t.Fatalf("failed to create post: %v", err)
+	}
+
+	pages := map[string]func(http.ResponseWriter, *http.Request){
+		"home":    server.HandleHome,
+		"archive": server.HandleArchive,
+		"post":    server.HandlePost,
+	}
+	for name, handler := range pages {
+		req := httptest.NewRequest(http.MethodGet, "/post/covered", nil)
+		req.SetPathValue("slug", "covered")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if !strings.Contains(w.Body.String(), `src="/static/cover.jpg"`) {
+			t.Errorf("%s: expected cover image, got body: %s", name, w.Body.String())
+		}
+		if name == "post" && !strings.Contains(w.Body.String(), `<meta property="og:image" content="http://example.com/static/cover.jpg">`) {
+			t.Errorf("expected cover image to be the share image, got body: %s", w.Body.String())
+		}
+	}
+}
+
+func TestPostNavigation(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "first", "First", "One.", true)
+	createTestPost(t, server, "hidden", "Hidden", "Draft.", false)
+	createTestPost(t, server, "second", "Second", "Two.", true)
+	createTestPost(t, server, "third", "Third", "Three.", true)
+
+	tests := []struct {
+		slug       string
+		prev, next string
+	}{
+		{"first", "", "second"},
+		{"second", "first", "third"},
+		{"third", "second", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/post/"+tt.slug, nil)
+		req.SetPathValue("slug", tt.slug)
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		body := w.Body.String()
+
+		for rel, slug := range map[string]string{"prev": tt.prev, "next": tt.next} {
+			link := `rel="` + rel + `"`
+			if slug == "" {
+				if strings.Contains(body, link) {
+					t.Errorf("%s: expected no %s link, got body: %s", tt.slug, rel, body)
+				}
+				continue
+			}
+			if !strings.Contains(body, `<a href="/post/`+slug+`" class="post-nav-`+rel+`" `+link+`>`) {
+				t.Errorf("%s: expected %s link to %s, got body: %s", tt.slug, rel, slug, body)
+			}
+		}
+	}
+}
+
+func TestSeries(t *testing.T) {
+	server := newTestServer(t)
+	form := url.Values{"title": {"Road Trip"}, "slug": {"road-trip"}, "description": {"Coast to coast."}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/series", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.HandleAdminSeriesCreate(httptest.NewRecorder(), req)
+	series, err := dbgen.New(server.DB).GetSeriesBySlug(context.Background(), "road-trip")
+	if err != nil {
+		t.Fatalf("expected series to be created: %v", err)
+	}
+
+	parts := []dbgen.Post{
+		createTestPost(t, server, "day-two", "Day Two", "Two.", true),
+		createTestPost(t, server, "day-one", "Day One", "One.", true),
+		createTestPost(t, server, "day-three", "Day Three", "Three.", false),
+	}
+	for i, order := range []string{"2", "1", "3"} {
+		p := parts[i]
+		form := url.Values{"title": {p.Title}, "content": {p.Content}, "series_id": {strconv.FormatInt(series.ID, 10)}, "series_order": {order}}
+		if p.Published == 1 {
+			form.Set("published", "on")
+		}
+		req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+strconv.FormatInt(p.ID, 10), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+		server.HandleAdminUpdate(httptest.NewRecorder(), req)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/post/day-two", nil)
+	req.SetPathValue("slug", "day-two")
+	w := httptest.NewRecorder()
+	server.HandlePost(w, req)
+	body := w.Body.String()
+	for _, expected := range []string{
+		`Part 2 of 2 in <a href="/series/road-trip">Road Trip</a>`,
+		`<li><a href="/post/day-one">Day One</a></li>`,
+		`<li class="current">Day Two</li>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected post page to contain %q, got body: %s", expected, body)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/series/road-trip", nil)
+	req.SetPathValue("slug", "road-trip")
+	w = httptest.NewRecorder()
+	server.HandleSeries(w, req)
+	body = w.Body.String()
+	one, two := strings.Index(body, "Day One"), strings.Index(body, "Day Two")
+	if one < 0 || two < one || strings.Contains(body, "Day Three") {
+		t.Errorf("expected series page to list published parts in order, got body: %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/series/missing", nil)
+	req.SetPathValue("slug", "missing")
+	w = httptest.NewRecorder()
+	server.HandleSeries(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown series to 404, got %d", w.Code)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	server := newTestServer(t)
+	form := url.Values{"name": {"Essays"}, "slug": {"essays"}, "description": {"Longer pieces."}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/categories", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.HandleAdminCategoryCreate(httptest.NewRecorder(), req)
+	category, err := dbgen.New(server.DB).GetCategoryBySlug(context.Background(), "essays")
+	if err != nil {
+		t.Fatalf("expected category to be created: %v", err)
+	}
+
+	for _, p := range []dbgen.Post{
+		createTestPost(t, server, "on-walking", "On Walking", "Body.", true),
+		createTestPost(t, server, "unfinished", "Unfinished", "Body.", false),
+	} {
+		form := url.Values{"title": {p.Title}, "content": {p.Content}, "category_id": {strconv.FormatInt(category.ID, 10)}}
+		if p.Published == 1 {
+			form.Set("published", "on")
+		}
+		req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+strconv.FormatInt(p.ID, 10), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+		server.HandleAdminUpdate(httptest.NewRecorder(), req)
+	}
+	createTestPost(t, server, "elsewhere", "Elsewhere", "Body.", true)
+
+	req = httptest.NewRequest(http.MethodGet, "/post/on-walking", nil)
+	req.SetPathValue("slug", "on-walking")
+	w := httptest.NewRecorder()
+	server.HandlePost(w, req)
+	if body := w.Body.String(); !strings.Contains(body, `<a href="/category/essays">Essays</a>`) {
+		t.Errorf("expected post page to link its category, got body: %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/category/essays", nil)
+	req.SetPathValue("slug", "essays")
+	w = httptest.NewRecorder()
+	server.HandleCategory(w, req)
+	body := w.Body.String()
+	if !strings.Contains(body, "On Walking") || strings.Contains(body, "Unfinished") || strings.Contains(body, "Elsewhere") {
+		t.Errorf("expected category page to list only its published posts, got body: %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/category/missing", nil)
+	req.SetPathValue("slug", "missing")
+	w = httptest.NewRecorder()
+	server.HandleCategory(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown category to 404, got %d", w.Code)
+	}
+}
+
+func TestSearchAPI(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "volcano", "Climbing the Volcano", "We reached the crater at dawn.", true)
+	createTestPost(t, server, "beach", "Beach Days", "Sand, sun and a distant volcano.", true)
+	createTestPost(t, server, "draft", "Volcano Draft", "Unpublished.", false)
+
+	search := func(query string) (*httptest.ResponseRecorder, []SearchResult) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.HandleSearchAPI(w, httptest.NewRequest(http.MethodGet, "/api/v1/search?"+query, nil))
+		var body struct {
+			Results []SearchResult `json:"results"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+		}
+		return w, body.Results
+	}
+
+	w, results := search("q=volcano")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("expected JSON response, got %q", ct)
+	}
+	if len(results) != 2 || results[0].Slug != "volcano" || results[1].Slug != "beach" {
+		t.Fatalf("expected title match ranked first and drafts hidden, got %+v", results)
+	}
+	if results[0].Title != "Climbing the Volcano" || results[0].Score <= results[1].Score || results[0].Snippet == "" {
+		t.Errorf("unexpected result fields: %+v", results[0])
+	}
+	if got := results[1].Snippet; got != "Sand, sun and a distant <mark>volcano</mark>." {
+		t.Errorf("expected the match marked in the snippet, got %q", got)
+	}
+	createTestPost(t, server, "lava", "Lava", "<script>alert(1)</script> & lava flows", true)
+	if _, results := search("q=lava+flows"); len(results) != 1 || results[0].Snippet != "&lt;script&gt;alert(1)&lt;/script&gt; &amp; <mark>lava</mark> <mark>flows</mark>" {
+		t.Errorf("expected an escaped snippet, got %+v", results)
+	}
+	if _, results := search("q=volc"); len(results) != 2 {
+		t.Errorf("expected prefix match on the last word, got %+v", results)
+	}
+	if _, results := search("q=climbed"); len(results) != 1 {
+		t.Errorf("expected stemmed match, got %+v", results)
+	}
+	if _, results := search("q=volcano&limit=1"); len(results) != 1 {
+		t.Errorf("expected limit to cap results, got %+v", results)
+	}
+	if _, results := search("q=%22+OR"); len(results) != 0 {
+		t.Errorf("expected operators to be searched as words, got %+v", results)
+	}
+	if _, err := server.DB.Exec("UPDATE posts SET content = 'Sand and sun.' WHERE slug = 'beach'"); err != nil {
+		t.Fatalf("failed to update post: %v", err)
+	}
+	if _, results := search("q=volcano"); len(results) != 1 {
+		t.Errorf("expected edits to update the index, got %+v", results)
+	}
+	if w, results := search("q=volcna"); len(results) != 1 || results[0].Slug != "volcano" || !strings.Contains(w.Body.String(), `"corrected":"volcano"`) {
+		t.Errorf("expected a misspelled query to be corrected, got body: %s", w.Body.String())
+	}
+	if w, results := search("q=crater+xylophone"); len(results) != 0 || strings.Contains(w.Body.String(), "corrected") {
+		t.Errorf("expected no correction for a word unlike any other, got body: %s", w.Body.String())
+	}
+	if w, _ := search("q=+"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected empty query to be rejected, got %d", w.Code)
+	}
+}
+
+func TestSuggestAPI(t *testing.T) {
+	server := newTestServer(t)
+	createTestPost(t, server, "volcano", "Volcano Diary", "Body.", true)
+	createTestPost(t, server, "volleyball", "volleyball on the beach", "Body.", true)
+	createTestPost(t, server, "draft", "Volunteering", "Body.", false)
+	createTestPost(t, server, "other", "A Volcano", "Body.", true)
+
+	suggest := func(q string) []dbgen.SuggestPostsRow {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.HandleSuggestAPI(w, httptest.NewRequest(http.MethodGet, "/api/v1/suggest?q="+url.QueryEscape(q), nil))
+		var body struct {
+			Suggestions []dbgen.SuggestPostsRow `json:"suggestions"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		return body.Suggestions
+	}
+
+	got := suggest("VOL")
+	if len(got) != 2 || got[0].Slug != "volcano" || got[1].Slug != "volleyball" {
+		t.Errorf("expected published title prefix matches in order, got %+v", got)
+	}
+	if got := suggest("volc"); len(got) != 1 || got[0].Title != "Volcano Diary" {
+		t.Errorf("expected one match, got %+v", got)
+	}
+	if got := suggest("%"); len(got) != 0 {
+		t.Errorf("expected no matches, got %+v", got)
+	}
+}
+
+func TestTags(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	form := url.Values{"slug": {"tagged"}, "title": {"Tagged"}, "content": {"Body."}, "published": {"on"}, "tags": {"Travel, new york, travel"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/new", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.HandleAdminCreate(httptest.NewRecorder(), req)
+
+	p, err := dbgen.New(server.DB).GetPostBySlug(ctx, "tagged")
+	if err != nil {
+		t.Fatalf("expected post to be created: %v", err)
+	}
+	postTags, _ := dbgen.New(server.DB).GetPostTags(ctx, p.ID)
+	if len(postTags) != 2 || postTags[0].Slug != "new-york" || postTags[1].Name != "Travel" {
+		t.Errorf("unexpected tags: %+v", postTags)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/post/tagged", nil)
+	req.SetPathValue("slug", "tagged")
+	w := httptest.NewRecorder()
+	server.HandlePost(w, req)
+	if !strings.Contains(w.Body.String(), `<li><a href="/tag/new-york" rel="tag">new york</a></li><li><a href="/tag/travel" rel="tag">Travel</a></li>`) {
+		t.Errorf("expected tags on post page, got body: %s", w.Body.String())
+	}
+
+	id := strconv.FormatInt(p.ID, 10)
+	req = httptest.NewRequest(http.MethodGet, "/admin/edit/"+id, nil)
+	req.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	server.HandleAdminEdit(w, req)
+	if !strings.Contains(w.Body.String(), `value="new york, Travel"`) {
+		t.Errorf("expected tags in edit form, got body: %s", w.Body.String())
+	}
+
+	form = url.Values{"title": {"Tagged"}, "content": {"Body."}, "tags": {"Travel"}}
+	req = httptest.NewRequest(http.MethodPost, "/admin/edit/"+id, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", id)
+	server.HandleAdminUpdate(httptest.NewRecorder(), req)
+	var count int
+	server.DB.QueryRow("SELECT COUNT(*) FROM tags").Scan(&count)
+	if count != 1 {
+		t.Errorf("expected unused tags to be removed, got %d tags", count)
+	}
+}
+
+func TestTagPages(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	for i := range tagPageSize + 1 {
+		p := createTestPost(t, server, "wiki-"+strconv.Itoa(i), "Wiki "+strconv.Itoa(i), "Body.", true)
+		tags.Set(ctx, q, p.ID, []string{"wikipedia"})
+	}
+	p := createTestPost(t, server, "trip", "Trip", "Body.", true)
+	tags.Set(ctx, q, p.ID, []string{"Travel", "wikipedia"})
+	draft := createTestPost(t, server, "draft", "Draft", "Body.", false)
+	tags.Set(ctx, q, draft.ID, []string{"secret"})
+
+	getTag := func(slug, page string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/tag/"+slug+"?page="+page, nil)
+		req.SetPathValue("tag", slug)
+		w := httptest.NewRecorder()
+		server.HandleTag(w, req)
+		return w
+	}
+
+	body := getTag("wikipedia", "1").Body.String()
+	if strings.Count(body, `class="post-preview"`) != tagPageSize || !strings.Contains(body, `href="?page=2" rel="next"`) {
+		t.Errorf("expected a full first page with a next link, got body: %s", body)
+	}
+	body = getTag("wikipedia", "2").Body.String()
+	if strings.Count(body, `class="post-preview"`) != 2 || !strings.Contains(body, `href="?page=1" rel="prev"`) || strings.Contains(body, `rel="next"`) {
+		t.Errorf("expected a last page with a previous link, got body: %s", body)
+	}
+	if w := getTag("missing", "1"); w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown tag to 404, got %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleTags(w, httptest.NewRequest(http.MethodGet, "/tags", nil))
+	body = w.Body.String()
+	for _, expected := range []string{
+		`<li class="tag-weight-1"><a href="/tag/travel" title="1 post">Travel</a></li>`,
+		`<li class="tag-weight-5"><a href="/tag/wikipedia" title="22 posts">wikipedia</a></li>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected tag cloud to contain %q, got body: %s", expected, body)
+		}
+	}
+	if strings.Contains(body, "secret") {
+		t.Errorf("expected tags of drafts to be hidden, got body: %s", body)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	server := newTestServer(t)
+	p := createTestPost(t, server, "first-name", "Renamed", "Body.", true)
+
+	rename := func(slug string) {
+		t.Helper()
+		form := url.Values{"slug": {slug}, "title": {"Renamed"}, "content": {"Body."}, "published": {"on"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+strconv.FormatInt(p.ID, 10), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+		w := httptest.NewRecorder()
+		server.HandleAdminUpdate(w, req)
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected rename to %q to succeed, got %d: %s", slug, w.Code, w.Body.String())
+		}
+	}
+	get := func(slug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/post/"+slug, nil)
+		req.SetPathValue("slug", slug)
+		w := httptest.NewRecorder()
+		server.HandlePost(w, req)
+		return w
+	}
+
+	rename("second-name")
+	rename("third-name")
+	for _, slug := range []string{"first-name", "second-name"} {
+		w := get(slug)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/post/third-name" {
+			t.Errorf("expected %s to redirect to /post/third-name, got %d %q", slug, w.Code, w.Header().Get("Location"))
+		}
+	}
+	w := get("third-name")
+	if !strings.Contains(w.Body.String(), `<link rel="canonical" href="http://example.com/post/third-name">`) {
+		t.Errorf("expected canonical link, got body: %s", w.Body.String())
+	}
+
+	rename("first-name")
+	if w := get("first-name"); w.Code != http.StatusOK {
+		t.Errorf("expected renaming back to serve the post, got %d", w.Code)
+	}
+	if w := get("third-name"); w.Header().Get("Location") != "/post/first-name" {
+		t.Errorf("expected third-name to redirect to first-name, got %q", w.Header().Get("Location"))
+	}
+
+	createTestPost(t, server, "taken", "Taken", "Body.", true)
+	form := url.Values{"slug": {"taken"}, "title": {"Renamed"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+strconv.FormatInt(p.ID, 10), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", strconv.FormatInt(p.ID, 10))
+	w = httptest.NewRecorder()
+	server.HandleAdminUpdate(w, req)
+	if !strings.Contains(w.Body.String(), "Another post already uses the slug taken") {
+		t.Errorf("expected slug collision to be reported, got %d: %s", w.Code, w.Body.String())
+	}
+
+	form = url.Values{"old_slug": {"Bad Slug"}, "new_slug": {"first-name"}}
+	req = httptest.NewRequest(http.MethodPost, "/admin/redirects", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	server.HandleAdminRedirectCreate(w, req)
+	if !strings.Contains(w.Body.String(), "lowercase letters") {
+		t.Errorf("expected invalid slug to be rejected, got %s", w.Body.String())
+	}
+
+	form.Set("old_slug", "legacy")
+	req = httptest.NewRequest(http.MethodPost, "/admin/redirects", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server.HandleAdminRedirectCreate(httptest.NewRecorder(), req)
+	if w := get("legacy"); w.Header().Get("Location") != "/post/first-name" {
+		t.Errorf("expected manual redirect, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/redirects/delete/legacy", nil)
+	req.SetPathValue("slug", "legacy")
+	server.HandleAdminRedirectDelete(httptest.NewRecorder(), req)
+	if w := get("legacy"); w.Code != http.StatusNotFound {
+		t.Errorf("expected deleted redirect to 404, got %d", w.Code)
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	t.Run("lru", func(t *testing.T) {
+		c := newRenderCache(2)
+		t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		c.put(1, renderedPost{updatedAt: t0, html: "one"})
+		c.put(2, renderedPost{updatedAt: t0, html: "two"})
+		c.get(1, t0)
+		c.put(3, renderedPost{updatedAt: t0, html: "three"})
+
+		if _, ok := c.get(2, t0); ok {
+			t.Errorf("expected least recently used entry to be evicted")
+		}
+		if rp, ok := c.get(1, t0); !ok || rp.html != "one" {
+			t.Errorf("expected entry 1 to be cached, got %q %v", rp.html, ok)
+		}
+		if _, ok := c.get(1, t0.Add(time.Second)); ok {
+			t.Errorf("expected entry with a different updated_at to miss")
+		}
+	})
+
+	t.Run("post is re-rendered after an update", func(t *testing.T) {
+		server := newTestServer(t)
+		p := createTestPost(t, server, "draft-one", "Draft", "First *version*", true)
+		getPost := func() string {
+			req := httptest.NewRequest(http.MethodGet, "/post/draft-one", nil)
+			req.SetPathValue("slug", "draft-one")
+			w := httptest.NewRecorder()
+			server.HandlePost(w, req)
+			return w.Body.String()
+		}
+		if body := getPost(); !strings.Contains(body, "First <em>version</em>") {
+			t.Fatalf("expected first version, got body: %s", body)
+		}
+
+		id := strconv.FormatInt(p.ID, 10)
+		form := url.Values{"title": {"Draft"}, "content": {"Second *version*"}, "published": {"on"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/edit/"+id, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", id)
+		server.HandleAdminUpdate(httptest.NewRecorder(), req)
+
+		if body := getPost(); !strings.Contains(body, "Second <em>version</em>") {
+			t.Errorf("expected updated content, got body: %s", body)
+		}
+	})
+}
+
+func TestUtilityFunctions(t *testing.T) {
+	t.Run("excerpt function", func(t *testing.T) {
+		tests := []struct {
+			input    string
+			maxLen   int
+			expected string
+		}{
+			{"short", 10, "short"},
+			{"  padded  ", 10, "padded"},
+			{"the quick brown fox", 12, "the quick..."},
+			{"the quick brown fox", 9, "the quick..."},
+			{"🎉🎉🎉 party time", 3, "🎉🎉🎉..."},
+			{"🎉🎉🎉🎉🎉", 3, "🎉🎉🎉..."},
+			{"日本語のテキストです", 4, "日本語の..."},
+			{"Zoë Ångström writes", 13, "Zoë Ångström..."},
+		}
+
+		for _, test := range tests {
+			result := excerpt(test.input, test.maxLen)
+			if result != test.expected {
+				t.Errorf("excerpt(%q, %d) = %q, expected %q", test.input, test.maxLen, result, test.expected)
+			}
+		}
+	})
+	t.Run("ftsQuery function", func(t *testing.T) {
+		tests := []struct {
+			input    string
+			expected string
+		}{
+			{"", ""},
+			{"  ?! ", ""},
+			{"volcano", `"volcano"*`},
+			{`lava AND "ash`, `"lava" "AND" "ash"*`},
+		}
+
+		for _, test := range tests {
+			result := ftsQuery(test.input)
+			if result != test.expected {
+				t.Errorf("ftsQuery(%q) = %q, expected %q", test.input, result, test.expected)
+			}
+		}
+	})
+	t.Run("editDistance function", func(t *testing.T) {
+		tests := []struct {
+			a, b     string
+			expected int
+		}{
+			{"", "", 0},
+			{"lava", "", 4},
+			{"volcna", "volcano", 2},
+			{"kitten", "sitting", 3},
+			{"café", "cafe", 1},
+		}
+
+		for _, test := range tests {
+			result := editDistance(test.a, test.b)
+			if result != test.expected {
+				t.Errorf("editDistance(%q, %q) = %d, expected %d", test.a, test.b, result, test.expected)
+			}
+		}
+	})
+	t.Run("readingTime function", func(t *testing.T) {
+		tests := []struct {
+			words    int
+			expected int
+		}{
+			{0, 1},
+			{200, 1},
+			{201, 2},
+			{1000, 5},
+		}
+
+		for _, test := range tests {
+			if result := readingTime(test.words); result != test.expected {
+				t.Errorf("readingTime(%d) = %d, expected %d", test.words, result, test.expected)
 			}
 		}
 	})
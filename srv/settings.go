@@ -0,0 +1,576 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Keys of the site-wide settings stored in the settings table.
+const (
+	settingSiteURL         = "site_url"
+	settingTypographer     = "typographer"
+	settingDefaultOGImage  = "default_og_image"
+	settingRobotsBlockAI   = "robots_block_ai"
+	settingRobotsSitemap   = "robots_sitemap"
+	settingRobotsExtra     = "robots_extra"
+	settingWebSubHub       = "websub_hub"
+	settingIndexNowKey     = "indexnow_key"
+	settingS3Bucket        = "s3_bucket"
+	settingS3Endpoint      = "s3_endpoint"
+	settingS3Region        = "s3_region"
+	settingS3AccessKey     = "s3_access_key"
+	settingS3SecretKey     = "s3_secret_key"
+	settingPreviewLinkDays = "preview_link_days"
+	settingPopularDays     = "popular_days"
+	settingTrackLinks      = "track_links"
+	settingSyncSecret      = "sync_secret"
+	settingSpamCheckKey    = "spam_check_key"
+	settingSpamCheckURL    = "spam_check_url"
+	settingFediverseUser   = "fediverse_username"
+	settingRelMe           = "rel_me"
+	settingMastodonServer  = "mastodon_server"
+	settingMastodonToken   = "mastodon_token"
+	settingMastodonExcerpt = "mastodon_excerpt"
+	settingBlueskyHandle   = "bluesky_handle"
+	settingBlueskyPassword = "bluesky_app_password"
+	settingBlueskyService  = "bluesky_service"
+	settingChatWebhook     = "chat_webhook_url"
+	settingSMTPServer      = "smtp_server"
+	settingSMTPUsername    = "smtp_username"
+	settingSMTPPassword    = "smtp_password"
+	settingSMTPTLS         = "smtp_tls"
+	settingMailFrom        = "mail_from"
+	settingNewsletter      = "newsletter"
+	settingCommentEmail    = "comment_email"
+	settingContactEmail    = "contact_email"
+)
+
+// settingDef describes a setting shown on the admin settings page.
+type settingDef struct {
+	Key       string
+	Label     string
+	Help      string
+	Bool      bool // edited as a checkbox and stored as "1" or "0"
+	Multiline bool // edited in a textarea
+	Secret    bool // edited in a password field
+	Default   string
+
+	// normalize, if set, cleans up a submitted value or rejects it.
+	normalize func(string) (string, error)
+}
+
+var settingDefs = []settingDef{
+	{
+		Key:       settingSiteURL,
+		Label:     "Site URL",
+		Help:      "Public address of the blog, such as https://example.com, used for absolute links in feeds. Leave empty to use the address each request arrives on.",
+		normalize: normalizeSiteURL,
+	},
+	{
+		Key:       settingDefaultOGImage,
+		Label:     "Default share image",
+		Help:      "Image shown in link previews for posts with no share image of their own and no images in their content. An http or https URL, or a path on this site such as /static/cover.jpg.",
+		normalize: normalizeImageRef,
+	},
+	{
+		Key:     settingTypographer,
+		Label:   "Smart typography",
+		Help:    `Render "quotes" as curly quotes, -- and --- as dashes, and ... as an ellipsis in posts.`,
+		Bool:    true,
+		Default: "0",
+	},
+	{
+		Key:     settingRobotsBlockAI,
+		Label:   "Block AI crawlers",
+		Help:    "Ask crawlers that collect training data for AI models (GPTBot, CCBot, ClaudeBot, Google-Extended and others) not to crawl the site in robots.txt.",
+		Bool:    true,
+		Default: "0",
+	},
+	{
+		Key:       settingRobotsSitemap,
+		Label:     "Sitemap URL",
+		Help:      "Sitemap advertised in robots.txt. Leave empty to leave it out.",
+		normalize: normalizeOptionalURL,
+	},
+	{
+		Key:       settingRobotsExtra,
+		Label:     "Extra robots.txt rules",
+		Help:      "Appended to robots.txt as written, after the built-in rules.",
+		Multiline: true,
+	},
+	{
+		Key:       settingWebSubHub,
+		Label:     "WebSub hub",
+		Help:      "Hub to notify when a post is published, such as https://pubsubhubbub.appspot.com/, so feed readers subscribed through it update straight away. Needs the site URL. Leave empty to turn notifications off.",
+		normalize: normalizeOptionalURL,
+	},
+	{
+		Key:       settingIndexNowKey,
+		Label:     "IndexNow key",
+		Help:      "Key used to submit new posts to search engines through IndexNow (Bing, Yandex and others). Any 8 to 128 letters, digits and dashes; it is published at /indexnow.txt. Needs the site URL. Leave empty to turn submissions off.",
+		normalize: normalizeIndexNowKey,
+	},
+	{
+		Key:       settingS3Bucket,
+		Label:     "Media bucket",
+		Help:      "S3 or S3-compatible bucket, such as one on MinIO, to store uploaded images in instead of the media directory, for hosts whose disk does not last. Images already uploaded are not moved. Leave empty to use the media directory.",
+		normalize: normalizeBucket,
+	},
+	{
+		Key:       settingS3Endpoint,
+		Label:     "Media bucket endpoint",
+		Help:      "Address of the S3 service, such as https://minio.example.com. Leave empty for Amazon S3.",
+		normalize: normalizeOptionalURL,
+	},
+	{
+		Key:   settingS3Region,
+		Label: "Media bucket region",
+		Help:  "Region of the bucket, such as eu-west-1. Leave empty for us-east-1, which is also what most other services expect.",
+	},
+	{
+		Key:   settingS3AccessKey,
+		Label: "Media bucket access key",
+	},
+	{
+		Key:    settingS3SecretKey,
+		Label:  "Media bucket secret key",
+		Secret: true,
+	},
+	{
+		Key:       settingPreviewLinkDays,
+		Label:     "Preview link lifetime",
+		Help:      "Days that a new preview link for a draft keeps working. Links already made keep the lifetime they were made with. Leave empty for a week.",
+		Default:   "7",
+		normalize: normalizePreviewLinkDays,
+	},
+	{
+		Key:       settingPopularDays,
+		Label:     "Popular posts window",
+		Help:      "Days of views, up to today, that the popular posts on the home page and at /popular are ranked by. Leave empty for a month.",
+		Default:   "30",
+		normalize: normalizePopularDays,
+	},
+	{
+		Key:     settingTrackLinks,
+		Label:   "Count clicks on links to other sites",
+		Help:    "Send readers who follow a link in a post to another site through /out, which counts the click for the post and link before sending them on. The counts are on the Analytics page.",
+		Bool:    true,
+		Default: "0",
+	},
+	{
+		Key:    settingSyncSecret,
+		Label:  "Sync webhook secret",
+		Help:   "Secret of the push webhook that a Git host calls at /api/v1/hooks/sync to sync posts from the content directory. Leave empty to only accept write API tokens.",
+		Secret: true,
+	},
+	{
+		Key:    settingSpamCheckKey,
+		Label:  "Spam check key",
+		Help:   "API key for Akismet, or a service compatible with it, to check new comments with. Comments it takes for spam are filed as spam rather than waiting for moderation. Leave empty to not check.",
+		Secret: true,
+	},
+	{
+		Key:       settingSpamCheckURL,
+		Label:     "Spam check service",
+		Help:      "Address of the spam check service's API, under which it answers /comment-check. Leave empty for Akismet, https://rest.akismet.com/1.1.",
+		normalize: normalizeOptionalURL,
+	},
+	{
+		Key:       settingFediverseUser,
+		Label:     "Fediverse username",
+		Help:      "Name the blog can be followed by from Mastodon and other fediverse servers, as @NAME@ and the site URL's host. Followers are kept if it changes, but the old name stops working. Needs the site URL. Leave empty for blog.",
+		Default:   "blog",
+		normalize: normalizeFediverseUser,
+	},
+	{
+		Key:       settingRelMe,
+		Label:     "Your profiles",
+		Help:      "Addresses of your profiles elsewhere, such as on GitHub or Mastodon, one to a line. The home page links them with rel=me, so IndieWeb sign-in and Mastodon's verified links can tell the site is yours; each should link back to the site.",
+		Multiline: true,
+		normalize: normalizeRelMe,
+	},
+	{
+		Key:       settingMastodonServer,
+		Label:     "Mastodon server",
+		Help:      "Server of the Mastodon account to share new posts on, such as https://mastodon.social. Each post is tooted with its title and link when it is first published, unless sharing is turned off in its editor. Needs the site URL. Leave empty to not share.",
+		normalize: normalizeMastodonServer,
+	},
+	{
+		Key:    settingMastodonToken,
+		Label:  "Mastodon access token",
+		Help:   "Access token of an application made under Preferences, Development on the Mastodon server, with the write:statuses scope.",
+		Secret: true,
+	},
+	{
+		Key:   settingMastodonExcerpt,
+		Label: "Include the excerpt in toots",
+		Help:  "Adds the post's description between its title and link, shortened to fit.",
+		Bool:  true,
+	},
+	{
+		Key:   settingBlueskyHandle,
+		Label: "Bluesky handle",
+		Help:  "Handle of the Bluesky account to share new posts on, such as you.bsky.social. Each post is posted with its title and link, and a link card, when it is first published, unless sharing is turned off in its editor. Needs the site URL. Leave empty to not share.",
+	},
+	{
+		Key:    settingBlueskyPassword,
+		Label:  "Bluesky app password",
+		Help:   "App password made under Settings, Privacy and security, App passwords on Bluesky. Not the account's own password.",
+		Secret: true,
+	},
+	{
+		Key:       settingBlueskyService,
+		Label:     "Bluesky server",
+		Help:      "Address of the account's PDS, for an account not hosted by Bluesky. Leave empty for https://bsky.social.",
+		normalize: normalizeOptionalURL,
+	},
+	{
+		Key:       settingChatWebhook,
+		Label:     "Slack or Discord webhook",
+		Help:      "Incoming webhook URL of a Slack or Discord channel to post a message to whenever a post is published, including those published on a schedule or by daily-wiki. Needs the site URL. Leave empty for no messages.",
+		Secret:    true,
+		normalize: normalizeOptionalURL,
+	},
+	{
+		Key:       settingSMTPServer,
+		Label:     "Mail server",
+		Help:      "Host and port of the SMTP server that sends the blog's email, such as smtp.example.com:587. The port defaults to 587. Leave empty to send no email; email already queued waits until it is set.",
+		normalize: normalizeSMTPServer,
+	},
+	{
+		Key:   settingSMTPUsername,
+		Label: "Mail server username",
+		Help:  "Username to sign in to the mail server with. Leave empty if it takes mail without signing in.",
+	},
+	{
+		Key:    settingSMTPPassword,
+		Label:  "Mail server password",
+		Secret: true,
+	},
+	{
+		Key:       settingSMTPTLS,
+		Label:     "Mail server security",
+		Help:      "starttls to upgrade the connection with STARTTLS, as servers on port 587 do; tls for servers that take TLS from the start, usually on port 465; none for a relay on this machine or network. Leave empty for starttls.",
+		normalize: normalizeSMTPTLS,
+	},
+	{
+		Key:       settingMailFrom,
+		Label:     "Send email from",
+		Help:      "Address the blog's email comes from, such as Citizen of the World <blog@example.com>.",
+		normalize: normalizeMailFrom,
+	},
+	{
+		Key:     settingNewsletter,
+		Label:   "Newsletter",
+		Help:    "Let readers subscribe by email to new posts, with a form at the foot of the home page and post pages. Needs the mail server and the address to send from.",
+		Bool:    true,
+		Default: "0",
+	},
+	{
+		Key:       settingCommentEmail,
+		Label:     "Email new comments to",
+		Help:      "Address to tell of each comment held for moderation. Comments filed as spam are not sent. Leave empty for no emails.",
+		normalize: normalizeEmail,
+	},
+	{
+		Key:       settingContactEmail,
+		Label:     "Email contact messages to",
+		Help:      "Address to forward messages from the contact page to, with replies going to their senders. Messages are kept in the admin either way. Leave empty for no emails.",
+		normalize: normalizeEmail,
+	},
+}
+
+func normalizeSiteURL(v string) (string, error) {
+	if !isHTTPURL(v) {
+		return "", errors.New("site URL must be an http or https address, such as https://example.com")
+	}
+	return strings.TrimRight(v, "/"), nil
+}
+
+func normalizeOptionalURL(v string) (string, error) {
+	if !isHTTPURL(v) {
+		return "", errors.New("URLs must be absolute http or https addresses")
+	}
+	return v, nil
+}
+
+func normalizeMastodonServer(v string) (string, error) {
+	if !isHTTPURL(v) {
+		return "", errors.New("the Mastodon server must be an http or https address, such as https://mastodon.social")
+	}
+	return strings.TrimRight(v, "/"), nil
+}
+
+func normalizeSMTPServer(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	if _, _, err := net.SplitHostPort(v); err != nil {
+		v = net.JoinHostPort(v, "587")
+	}
+	if host, port, err := net.SplitHostPort(v); err != nil || host == "" || strings.ContainsAny(host, "/ ") {
+		return "", errors.New("the mail server must be a host name and port, such as smtp.example.com:587")
+	} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", errors.New("the mail server's port must be a number from 1 to 65535")
+	}
+	return v, nil
+}
+
+func normalizeSMTPTLS(v string) (string, error) {
+	switch v = strings.ToLower(v); v {
+	case "", "starttls", "tls", "none":
+		return v, nil
+	}
+	return "", errors.New("mail server security must be starttls, tls or none")
+}
+
+func normalizeEmail(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	if addr, err := mail.ParseAddress(v); err != nil || addr.Name != "" {
+		return "", errors.New("give a plain email address, such as you@example.com")
+	}
+	return v, nil
+}
+
+func normalizeMailFrom(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	if _, err := mail.ParseAddress(v); err != nil {
+		return "", errors.New("the address to send from must be an email address, such as blog@example.com")
+	}
+	return v, nil
+}
+
+func normalizeIndexNowKey(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	valid := len(v) >= 8 && len(v) <= 128
+	for _, c := range v {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+			valid = false
+		}
+	}
+	if !valid {
+		return "", errors.New("the IndexNow key must be 8 to 128 letters, digits and dashes")
+	}
+	return v, nil
+}
+
+func normalizeFediverseUser(v string) (string, error) {
+	v = strings.ToLower(strings.TrimPrefix(v, "@"))
+	valid := len(v) <= 30
+	for _, c := range v {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '_') {
+			valid = false
+		}
+	}
+	if !valid {
+		return "", errors.New("the fediverse username must be up to 30 letters, digits and underscores")
+	}
+	return v, nil
+}
+
+func normalizeBucket(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	valid := len(v) >= 3 && len(v) <= 63
+	for _, c := range v {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.') {
+			valid = false
+		}
+	}
+	if !valid {
+		return "", errors.New("bucket names are 3 to 63 lowercase letters, digits, dots and dashes")
+	}
+	return v, nil
+}
+
+func normalizePreviewLinkDays(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || days < 1 || days > 365 {
+		return "", errors.New("preview links must last a whole number of days from 1 to 365")
+	}
+	return strconv.Itoa(days), nil
+}
+
+func normalizePopularDays(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || days < 1 || days > 365 {
+		return "", errors.New("the popular posts window must be a whole number of days from 1 to 365")
+	}
+	return strconv.Itoa(days), nil
+}
+
+func normalizeImageRef(v string) (string, error) {
+	if !isImageRef(v) {
+		return "", errors.New("images must be http or https URLs or paths on this site")
+	}
+	return v, nil
+}
+
+// isImageRef reports whether v is empty, an absolute http or https URL or
+// a path on this site.
+func isImageRef(v string) bool {
+	return isHTTPURL(v) || strings.HasPrefix(v, "/") && !strings.HasPrefix(v, "//")
+}
+
+// isHTTPURL reports whether v is empty or an absolute http or https URL.
+func isHTTPURL(v string) bool {
+	if v == "" {
+		return true
+	}
+	u, err := url.Parse(v)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// SettingView is a setting definition with its current value.
+type SettingView struct {
+	settingDef
+	Value string
+}
+
+func lookupSetting(key string) (settingDef, bool) {
+	for _, def := range settingDefs {
+		if def.Key == key {
+			return def, true
+		}
+	}
+	return settingDef{}, false
+}
+
+// setting returns the stored value for key, or its default if it has not
+// been set.
+func (s *Server) setting(ctx context.Context, key string) string {
+	value, err := dbgen.New(s.DB).GetSetting(ctx, key)
+	if err == nil {
+		return value
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("get setting", "key", key, "error", err)
+	}
+	def, _ := lookupSetting(key)
+	return def.Default
+}
+
+// storedKey returns the secret held in the setting key, which is not in
+// settingDefs, making and storing one if there is none yet.
+func (s *Server) storedKey(ctx context.Context, key string) ([]byte, error) {
+	q := dbgen.New(s.DB)
+	secret, err := q.GetSetting(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = q.InsertSettingIfAbsent(ctx, dbgen.InsertSettingIfAbsentParams{Key: key, Value: rand.Text()})
+		if err == nil {
+			secret, err = q.GetSetting(ctx, key)
+		}
+	}
+	return []byte(secret), err
+}
+
+func (s *Server) settingBool(ctx context.Context, key string) bool {
+	return s.setting(ctx, key) == "1"
+}
+
+func (s *Server) HandleAdminSettings(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	rows, err := q.GetSettings(r.Context())
+	if err != nil {
+		slog.Error("get settings", "error", err)
+	}
+	values := make(map[string]string, len(rows))
+	for _, row := range rows {
+		values[row.Key] = row.Value
+	}
+
+	s.render(w, "admin_settings.html", map[string]any{
+		"Settings":  settingViews(values),
+		"Saved":     r.URL.Query().Get("saved") == "1",
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// settingViews pairs each setting with its value in values, or its
+// default if it has none.
+func settingViews(values map[string]string) []SettingView {
+	views := make([]SettingView, 0, len(settingDefs))
+	for _, def := range settingDefs {
+		value, ok := values[def.Key]
+		if !ok {
+			value = def.Default
+		}
+		views = append(views, SettingView{settingDef: def, Value: value})
+	}
+	return views
+}
+
+func (s *Server) HandleAdminSettingsUpdate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	values := make(map[string]string, len(settingDefs))
+	for _, def := range settingDefs {
+		value := strings.TrimSpace(r.FormValue(def.Key))
+		if def.Multiline {
+			value = strings.ReplaceAll(value, "\r\n", "\n")
+		}
+		if def.Bool {
+			value = "0"
+			if r.FormValue(def.Key) == "on" {
+				value = "1"
+			}
+		}
+		values[def.Key] = value
+		if def.normalize == nil {
+			continue
+		}
+		normalized, err := def.normalize(value)
+		if err != nil {
+			s.render(w, "admin_settings.html", map[string]any{
+				"Settings":  settingViews(values),
+				"Error":     "Could not save settings: " + err.Error(),
+				"CSRFToken": csrfToken(r),
+				"Year":      time.Now().Year(),
+			})
+			return
+		}
+		values[def.Key] = normalized
+	}
+
+	q := dbgen.New(s.DB)
+	for _, def := range settingDefs {
+		err := q.UpsertSetting(r.Context(), dbgen.UpsertSettingParams{Key: def.Key, Value: values[def.Key]})
+		if err != nil {
+			slog.Error("update setting", "key", def.Key, "error", err)
+			http.Error(w, "Failed to save settings", http.StatusInternalServerError)
+			return
+		}
+	}
+	s.renders.clear()
+
+	http.Redirect(w, r, "/admin/settings?saved=1", http.StatusFound)
+}
@@ -0,0 +1,75 @@
+// Package slug makes the URL identifiers of posts.
+package slug
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+var (
+	invalidChars = regexp.MustCompile(`[^a-z0-9-]`)
+	hyphenRuns   = regexp.MustCompile(`-+`)
+)
+
+// maxLen is the longest slug Generate returns, in characters.
+const maxLen = 50
+
+// Generate returns a slug for a post with the given title: lowercase
+// letters, digits and single hyphens. Latin letters with diacritics, Greek
+// and Cyrillic are transliterated to ASCII. A title in another script,
+// with letters but none that can be transliterated, gets a slug made from
+// a hash of the title. Generate returns "" if the title has no letters or
+// digits at all.
+func Generate(title string) string {
+	s := transliterate(strings.ToLower(title))
+	s = strings.ReplaceAll(s, " ", "-")
+	s = invalidChars.ReplaceAllString(s, "")
+	s = hyphenRuns.ReplaceAllString(s, "-")
+	s = strings.Trim(s, "-")
+	if len(s) > maxLen {
+		s = truncate(s)
+	}
+	if s == "" && strings.ContainsFunc(title, unicode.IsLetter) {
+		sum := sha256.Sum256([]byte(title))
+		s = "post-" + hex.EncodeToString(sum[:4])
+	}
+	return s
+}
+
+// truncate shortens a slug to maxLen characters, dropping any word that
+// would be cut short unless it is the first. Slugs are ASCII by the time
+// they are truncated, so characters and bytes are the same.
+func truncate(s string) string {
+	if s[maxLen] == '-' {
+		return s[:maxLen]
+	}
+	if i := strings.LastIndexByte(s[:maxLen], '-'); i > 0 {
+		return s[:i]
+	}
+	return s[:maxLen]
+}
+
+// Unique returns base if no post uses it as its slug yet, and otherwise
+// the first of base-2, base-3 and so on that is free.
+func Unique(ctx context.Context, q *dbgen.Queries, base string) (string, error) {
+	s := base
+	for n := 2; ; n++ {
+		_, err := q.GetPostBySlug(ctx, s)
+		if errors.Is(err, sql.ErrNoRows) {
+			return s, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		s = base + "-" + strconv.Itoa(n)
+	}
+}
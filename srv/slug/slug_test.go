@@ -0,0 +1,44 @@
+package slug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func hashPrefix(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Hello World", "hello-world"},
+		{"  Leading and trailing  ", "leading-and-trailing"},
+		{"What's new?", "whats-new"},
+		{"Rock & Roll", "rock-roll"},
+		{"!!!", ""},
+		{"Łódź", "lodz"},
+		{"Crème brûlée à São Paulo", "creme-brulee-a-sao-paulo"},
+		{"Straße", "strasse"},
+		{"Αθήνα", "athina"},
+		{"Москва́", "moskva"},
+		{"Санкт-Петербург", "sankt-peterburg"},
+		{"日本語", "post-" + hashPrefix("日本語")},
+		{"日本語 Wikipedia", "wikipedia"},
+		{"🎉 Party time 🎉", "party-time"},
+		{"A very long title that goes on and on well past the fifty character limit", "a-very-long-title-that-goes-on-and-on-well-past"},
+		{"Exactly fifty characters long with a hyphen at 50 -x", "exactly-fifty-characters-long-with-a-hyphen-at-50"},
+		{strings.Repeat("x", 60), strings.Repeat("x", 50)},
+		{"Ωραία " + strings.Repeat("ελληνικά ", 6), "oraia-ellinika-ellinika-ellinika-ellinika-ellinika"},
+	}
+	for _, tt := range tests {
+		if result := Generate(tt.input); result != tt.expected {
+			t.Errorf("Generate(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package slug
+
+import "strings"
+
+// translit maps lowercase letters of the Latin, Greek and Cyrillic scripts
+// to the ASCII letters they are usually written with in URLs.
+var translit = map[rune]string{}
+
+func init() {
+	for _, g := range []struct{ ascii, letters string }{
+		// Latin letters with diacritics, and ligatures.
+		{"a", "àáâãäåāăą"}, {"ae", "æ"}, {"c", "çćĉċč"}, {"d", "ďđð"},
+		{"e", "èéêëēĕėęě"}, {"g", "ĝğġģ"}, {"h", "ĥħ"}, {"i", "ìíîïĩīĭįı"},
+		{"ij", "ĳ"}, {"j", "ĵ"}, {"k", "ķĸ"}, {"l", "ĺļľŀł"}, {"n", "ñńņňŉŋ"},
+		{"o", "òóôõöøōŏő"}, {"oe", "œ"}, {"r", "ŕŗř"}, {"s", "śŝşšſș"},
+		{"ss", "ß"}, {"t", "ţťŧț"}, {"th", "þ"}, {"u", "ùúûüũūŭůűų"},
+		{"w", "ŵ"}, {"y", "ýÿŷ"}, {"z", "źżž"},
+
+		// Greek.
+		{"a", "αά"}, {"v", "β"}, {"g", "γ"}, {"d", "δ"}, {"e", "εέ"},
+		{"z", "ζ"}, {"i", "ηήιίϊΐ"}, {"th", "θ"}, {"k", "κ"}, {"l", "λ"},
+		{"m", "μ"}, {"n", "ν"}, {"x", "ξ"}, {"o", "οόωώ"}, {"p", "π"},
+		{"r", "ρ"}, {"s", "σς"}, {"t", "τ"}, {"y", "υύϋΰ"}, {"f", "φ"},
+		{"ch", "χ"}, {"ps", "ψ"},
+
+		// Cyrillic, for Russian, Ukrainian, Belarusian and Serbian.
+		{"a", "а"}, {"b", "б"}, {"v", "в"}, {"g", "гґ"}, {"d", "д"},
+		{"e", "еёэ"}, {"zh", "ж"}, {"z", "з"}, {"i", "иі"}, {"y", "йы"},
+		{"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"}, {"o", "о"},
+		{"p", "п"}, {"r", "р"}, {"s", "с"}, {"t", "т"}, {"u", "уў"},
+		{"f", "ф"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+		{"shch", "щ"}, {"", "ъь"}, {"yu", "ю"}, {"ya", "я"}, {"yi", "ї"},
+		{"ye", "є"}, {"dj", "ђ"}, {"j", "ј"}, {"lj", "љ"}, {"nj", "њ"},
+		{"c", "ћ"}, {"dz", "џ"},
+	} {
+		for _, r := range g.letters {
+			translit[r] = g.ascii
+		}
+	}
+}
+
+// transliterate replaces the letters of s that translit knows with ASCII.
+// Other characters are kept.
+func transliterate(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if ascii, ok := translit[r]; ok {
+			sb.WriteString(ascii)
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
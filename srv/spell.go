@@ -0,0 +1,103 @@
+package srv
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// correctQuery returns q with each word that matches no post replaced by
+// the most similar term in the search index, or "" if no word could be
+// corrected. Index terms are stemmed, so a correction may be a word stem.
+func (s *Server) correctQuery(ctx context.Context, q string) (string, error) {
+	words := searchWords(q)
+	var unknown []int
+	for i, w := range words {
+		match := `"` + w + `"`
+		if i == len(words)-1 {
+			match += "*"
+		}
+		var found int
+		err := s.DB.QueryRowContext(ctx, "SELECT count(*) FROM (SELECT 1 FROM posts_fts WHERE posts_fts MATCH ? LIMIT 1)", match).Scan(&found)
+		if err != nil {
+			return "", err
+		}
+		if found == 0 {
+			unknown = append(unknown, i)
+		}
+	}
+	if len(unknown) == 0 {
+		return "", nil
+	}
+
+	// Terms are listed most widely used first, so that among equally close
+	// terms the one more posts use wins.
+	rows, err := s.DB.QueryContext(ctx, "SELECT term FROM posts_fts_vocab ORDER BY doc DESC, term")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var terms []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return "", err
+		}
+		terms = append(terms, term)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	corrected := false
+	for _, i := range unknown {
+		if term := closestTerm(strings.ToLower(words[i]), terms); term != "" {
+			words[i] = term
+			corrected = true
+		}
+	}
+	if !corrected {
+		return "", nil
+	}
+	return strings.Join(words, " "), nil
+}
+
+// closestTerm returns the term nearest to word by edit distance, or "" if
+// none is near enough to be a likely typo: one edit for words of up to
+// four letters, two for longer ones.
+func closestTerm(word string, terms []string) string {
+	limit := 1
+	if utf8.RuneCountInString(word) > 4 {
+		limit = 2
+	}
+	best, bestDist := "", limit+1
+	for _, term := range terms {
+		if d := editDistance(word, term); d < bestDist {
+			best, bestDist = term, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b, counting
+// runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
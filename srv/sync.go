@@ -0,0 +1,446 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/frontmatter"
+	"srv.exe.dev/srv/slug"
+	"srv.exe.dev/srv/tags"
+)
+
+// syncResponse is the body of a sync hook response.
+type syncResponse struct {
+	Status string `json:"status"`
+}
+
+// ImportResult counts what an import or sync of Markdown files did with
+// the files it read.
+type ImportResult struct {
+	Created, Updated, Unchanged, Failed int
+}
+
+// contentSyncInterval is how often the content directory is synced
+// without being asked to.
+const contentSyncInterval = 5 * time.Minute
+
+// syncingKey marks the context of a sync, so that the posts it saves are
+// not written back to the files they were read from.
+type syncingKey struct{}
+
+// syncLoop runs syncContent every contentSyncInterval and whenever a sync
+// is requested. Syncs run one at a time; requests made during one are
+// answered by the next.
+func (s *Server) syncLoop(ctx context.Context) {
+	ticker := time.NewTicker(contentSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.contentSync:
+		}
+		if s.ContentDir == "" {
+			continue
+		}
+		result, err := s.syncContent(ctx)
+		if err != nil {
+			slog.Error("sync content", "dir", s.ContentDir, "error", err)
+			continue
+		}
+		if result.Created+result.Updated+result.Failed > 0 {
+			slog.Info("synced content", "dir", s.ContentDir, "created", result.Created, "updated", result.Updated, "unchanged", result.Unchanged, "failed", result.Failed)
+		}
+	}
+}
+
+// requestSync wakes syncLoop without waiting for it.
+func (s *Server) requestSync() {
+	select {
+	case s.contentSync <- struct{}{}:
+	default:
+	}
+}
+
+// syncContent imports the Markdown files under s.ContentDir, first
+// pulling it if it is a Git checkout. Each file is the post with its
+// slug, which is given in its front matter or else made from its name;
+// posts whose file has not changed are left alone, and posts with no
+// file are not touched at all.
+func (s *Server) syncContent(ctx context.Context) (ImportResult, error) {
+	s.contentMu.Lock()
+	defer s.contentMu.Unlock()
+	ctx = context.WithValue(ctx, syncingKey{}, true)
+	if _, err := os.Stat(filepath.Join(s.ContentDir, ".git")); err == nil {
+		if err := s.pullContent(ctx); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	return s.importPostFiles(ctx, s.ContentDir)
+}
+
+// importPostFiles saves each Markdown file under dir over the post with
+// its slug, or as a new post, unless they already agree.
+func (s *Server) importPostFiles(ctx context.Context, dir string) (ImportResult, error) {
+	var result ImportResult
+	err := walkPostFiles(dir, func(path string) error {
+		changed, created, err := s.syncFile(ctx, path)
+		switch {
+		case err != nil:
+			slog.Error("import post file", "path", path, "error", err)
+			result.Failed++
+		case created:
+			result.Created++
+		case changed:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+		return ctx.Err()
+	})
+	return result, err
+}
+
+// walkPostFiles calls fn with the path of each Markdown file under dir,
+// leaving out hidden directories such as .git.
+func walkPostFiles(dir string, fn func(path string) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// pullContent brings the Git checkout in s.ContentDir up to date with its
+// upstream, if it has one. When changes are committed back, those not
+// pushed yet are rebased onto what was pulled and pushed; otherwise the
+// pull must need no merge.
+func (s *Server) pullContent(ctx context.Context) error {
+	if err := runGit(ctx, s.ContentDir, "rev-parse", "--verify", "--quiet", "@{upstream}"); err != nil {
+		return nil
+	}
+	if !s.ContentCommit {
+		return runGit(ctx, s.ContentDir, "pull", "--ff-only", "--quiet")
+	}
+	if err := runGit(ctx, s.ContentDir, append(s.gitIdentity(ctx), "pull", "--rebase", "--quiet")...); err != nil {
+		return err
+	}
+	return runGit(ctx, s.ContentDir, "push", "--quiet")
+}
+
+// runGit runs a git command in dir, failing with its output.
+func runGit(ctx context.Context, dir string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if output, err := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// commitPost writes a post changed on the server back to its file in the
+// content directory and commits it, if s.ContentCommit is set. A post in
+// the trash has its file removed. The commit is pushed by a sync, which
+// is requested straight away.
+func (s *Server) commitPost(ctx context.Context, before *dbgen.Post, after dbgen.Post) {
+	if !s.ContentCommit || s.ContentDir == "" || ctx.Value(syncingKey{}) != nil {
+		return
+	}
+	s.contentMu.Lock()
+	defer s.contentMu.Unlock()
+	if err := s.writePostFile(ctx, before, after); err != nil {
+		slog.Error("commit post", "slug", after.Slug, "error", err)
+		return
+	}
+	s.requestSync()
+}
+
+// writePostFile writes after to the file of the post, found by the slug
+// it had before, or to a new file named for its slug, and commits the
+// file if that changed it.
+func (s *Server) writePostFile(ctx context.Context, before *dbgen.Post, after dbgen.Post) error {
+	oldSlug := after.Slug
+	if before != nil {
+		oldSlug = before.Slug
+	}
+	path, err := findPostFile(s.ContentDir, oldSlug)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		if after.DeletedAt != nil {
+			return nil
+		}
+		path = filepath.Join(s.ContentDir, after.Slug+".md")
+	}
+	var message string
+	switch {
+	case after.DeletedAt != nil:
+		message = "Delete " + after.Title
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	default:
+		switch {
+		case !onSite(before) && onSite(&after):
+			message = "Publish " + after.Title
+		case before == nil:
+			message = "Add " + after.Title
+		default:
+			message = "Update " + after.Title
+		}
+		postTags, err := dbgen.New(s.DB).GetPostTags(ctx, after.ID)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(postFile(after, postTags)), 0o644); err != nil {
+			return err
+		}
+	}
+
+	rel, err := filepath.Rel(s.ContentDir, path)
+	if err != nil {
+		return err
+	}
+	if err := runGit(ctx, s.ContentDir, "add", "--all", "--", rel); err != nil {
+		return err
+	}
+	if runGit(ctx, s.ContentDir, "diff", "--cached", "--quiet", "--", rel) == nil {
+		return nil
+	}
+	return runGit(ctx, s.ContentDir, append(s.gitIdentity(ctx), "commit", "--quiet", "-m", message, "--", rel)...)
+}
+
+// gitIdentity returns the options that name the blog as the committer,
+// unless the checkout names someone already.
+func (s *Server) gitIdentity(ctx context.Context) []string {
+	if runGit(ctx, s.ContentDir, "config", "user.email") == nil {
+		return nil
+	}
+	return []string{"-c", "user.name=" + siteTitle, "-c", "user.email=blog@" + s.Hostname}
+}
+
+// findPostFile returns the path of the file under dir with the post with
+// the given slug, or "" if there is none.
+func findPostFile(dir, slug string) (string, error) {
+	var found string
+	err := walkPostFiles(dir, func(path string) error {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if post, _, err := readPostFile(filepath.Base(path), string(src)); err == nil && post.Slug == slug {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, err
+}
+
+// postFile returns the Markdown file of a post, as syncFile reads it.
+func postFile(p dbgen.Post, postTags []dbgen.Tag) string {
+	fields := []frontmatter.Field{
+		{Key: "title", Value: p.Title},
+		{Key: "slug", Value: p.Slug},
+		{Key: "date", Value: p.CreatedAt.UTC().Format(time.RFC3339)},
+		{Key: "published", Value: p.Published == 1},
+	}
+	if len(postTags) > 0 {
+		names := make([]string, len(postTags))
+		for i, t := range postTags {
+			names[i] = t.Name
+		}
+		fields = append(fields, frontmatter.Field{Key: "tags", Value: names})
+	}
+	return frontmatter.Format(fields, p.Content)
+}
+
+// syncFile saves the post in the Markdown file at path over the one with
+// its slug, or as a new post, unless they already agree.
+func (s *Server) syncFile(ctx context.Context, path string) (changed, created bool, err error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, false, err
+	}
+	in, date, err := readPostFile(filepath.Base(path), string(src))
+	if err != nil {
+		return false, false, err
+	}
+
+	q := dbgen.New(s.DB)
+	existing, err := q.GetPostBySlug(ctx, in.Slug)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if err := s.createPost(ctx, &in); err != nil {
+			return false, false, err
+		}
+		created = true
+	case err != nil:
+		return false, false, err
+	default:
+		postTags, err := q.GetPostTags(ctx, existing.ID)
+		if err != nil {
+			return false, false, err
+		}
+		post := postView(existing, postTags)
+		if post.Title == in.Title && post.Content == in.Content && post.Published == in.Published &&
+			sameTags(post.TagList, in.TagList) &&
+			(date.IsZero() || date.Equal(existing.CreatedAt)) {
+			return false, false, nil
+		}
+		post.Title, post.Content, post.Published, post.TagList = in.Title, in.Content, in.Published, in.TagList
+		post.UpdatedAt = time.Time{}
+		if err := s.updatePost(ctx, &post, ""); err != nil {
+			return false, false, err
+		}
+		in.ID = post.ID
+	}
+	if !date.IsZero() {
+		if err := q.SetPostCreatedAt(ctx, dbgen.SetPostCreatedAtParams{CreatedAt: date.UTC().Format(time.DateTime), ID: in.ID}); err != nil {
+			return true, created, err
+		}
+	}
+	return true, created, nil
+}
+
+// sameTags reports whether two tag lists name the same tags, in any
+// order and spelling.
+func sameTags(a, b string) bool {
+	slugs := func(list string) []string {
+		names := tags.Parse(list)
+		for i, name := range names {
+			names[i] = tags.Slug(name)
+		}
+		slices.Sort(names)
+		return names
+	}
+	return slices.Equal(slugs(a), slugs(b))
+}
+
+// readPostFile reads a post from a Markdown file with front matter giving
+// its title and optionally its slug, date, tags and whether it is
+// published, which it is unless it says published: false or draft: true.
+// Without a slug, one is made from the file name.
+func readPostFile(name, src string) (PostView, time.Time, error) {
+	fields, body, err := frontmatter.Parse(src)
+	if err != nil {
+		return PostView{}, time.Time{}, err
+	}
+	str := func(key string) string {
+		v, _ := fields[key].(string)
+		return strings.TrimSpace(v)
+	}
+	post := PostView{
+		Slug:      str("slug"),
+		Title:     str("title"),
+		Content:   strings.TrimLeft(body, "\n"),
+		Published: fields["published"] != false && fields["draft"] != true,
+	}
+	if post.Title == "" {
+		return post, time.Time{}, errors.New("no title in front matter")
+	}
+	if post.Slug == "" {
+		post.Slug = slug.Generate(strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+	switch v := fields["tags"].(type) {
+	case []string:
+		post.TagList = strings.Join(v, ", ")
+	case string:
+		post.TagList = v
+	}
+	var date time.Time
+	if v := str("date"); v != "" {
+		date, err = parsePostDate(v)
+		if err != nil {
+			return post, date, fmt.Errorf("date %q is not a date, such as 2024-05-01 or 2024-05-01T09:30:00Z", v)
+		}
+	}
+	return post, date, nil
+}
+
+// parsePostDate reads a date as front matter writes it, in UTC unless it
+// says otherwise.
+func parsePostDate(v string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", time.DateTime, time.DateOnly} {
+		var t time.Time
+		if t, err = time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// requireSyncAuth protects the sync hook. Besides a write token or an
+// admin, as for requireWriteToken, it lets in requests signed with the
+// sync secret in X-Hub-Signature-256, as GitHub, Gitea and Forgejo sign
+// their webhooks, or carrying it in X-Gitlab-Token, as GitLab does.
+func (s *Server) requireSyncAuth(next http.HandlerFunc) http.HandlerFunc {
+	write := s.requireWriteToken(next)
+	return s.refuseBanned(func(w http.ResponseWriter, r *http.Request) {
+		signature, token := r.Header.Get("X-Hub-Signature-256"), r.Header.Get("X-Gitlab-Token")
+		if signature == "" && token == "" {
+			write(w, r)
+			return
+		}
+		secret := s.setting(r.Context(), settingSyncSecret)
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 25<<20))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "failed to read body"})
+			return
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		valid := hmac.Equal([]byte(signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+		if signature == "" {
+			valid = hmac.Equal([]byte(token), []byte(secret))
+		}
+		if secret == "" || !valid {
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "invalid webhook signature"})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r)
+	})
+}
+
+// HandleSyncHook answers POST /api/v1/hooks/sync by starting a sync of the
+// content directory, such as after a push to the Git repository it is
+// checked out from. The sync runs after the response.
+func (s *Server) HandleSyncHook(w http.ResponseWriter, r *http.Request) {
+	if s.ContentDir == "" {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "content sync is not set up"})
+		return
+	}
+	s.requestSync()
+	writeJSON(w, http.StatusAccepted, syncResponse{Status: "queued"})
+}
@@ -0,0 +1,90 @@
+package srv
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// tagPageSize is the number of posts on each page of a tag listing.
+const tagPageSize = 20
+
+// TagCount is a tag with the number of published posts that have it.
+type TagCount struct {
+	Name   string
+	Slug   string
+	Posts  int64
+	Weight int // 1 to 5, for sizing the tag in the tag cloud
+}
+
+func (s *Server) HandleTag(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	tag, err := q.GetTagBySlug(r.Context(), r.PathValue("tag"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	total, err := q.CountTagPosts(r.Context(), tag.ID)
+	if err != nil {
+		slog.Error("count tag posts", "error", err)
+	}
+	page := pageNumber(r)
+	dbPosts, err := q.GetTagPosts(r.Context(), dbgen.GetTagPostsParams{
+		TagID:  tag.ID,
+		Limit:  tagPageSize,
+		Offset: int64(page-1) * tagPageSize,
+	})
+	if err != nil {
+		slog.Error("get tag posts", "error", err)
+	}
+
+	posts := make([]PostView, 0, len(dbPosts))
+	for _, p := range dbPosts {
+		posts = append(posts, previewView(p))
+	}
+
+	s.render(w, "base.html", map[string]any{
+		"Tag":        tag,
+		"Posts":      posts,
+		"Pagination": paginate(page, tagPageSize, total),
+		"Year":       time.Now().Year(),
+		"Page":       "tag",
+	})
+}
+
+func (s *Server) HandleTags(w http.ResponseWriter, r *http.Request) {
+	rows, err := dbgen.New(s.DB).GetTagCounts(r.Context())
+	if err != nil {
+		slog.Error("get tag counts", "error", err)
+	}
+
+	s.render(w, "base.html", map[string]any{
+		"Tags": tagCloud(rows),
+		"Year": time.Now().Year(),
+		"Page": "tags",
+	})
+}
+
+// tagCloud weights each tag from 1 to 5 by its post count, relative to
+// the least and most used tags.
+func tagCloud(rows []dbgen.GetTagCountsRow) []TagCount {
+	if len(rows) == 0 {
+		return nil
+	}
+	least, most := rows[0].PostCount, rows[0].PostCount
+	for _, row := range rows {
+		least = min(least, row.PostCount)
+		most = max(most, row.PostCount)
+	}
+	tags := make([]TagCount, len(rows))
+	for i, row := range rows {
+		weight := 3
+		if most > least {
+			weight = 1 + int(4*(row.PostCount-least)/(most-least))
+		}
+		tags[i] = TagCount{Name: row.Name, Slug: row.Slug, Posts: row.PostCount, Weight: weight}
+	}
+	return tags
+}
@@ -0,0 +1,85 @@
+// Package tags parses and stores the tags attached to posts.
+package tags
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Parse splits a comma-separated list of tag names, trimming and
+// collapsing whitespace. Names whose slugs repeat an earlier one, or are
+// empty, are dropped.
+func Parse(list string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.Join(strings.Fields(name), " ")
+		slug := Slug(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// Slug returns the URL form of a tag name: lowercase letters and digits
+// with hyphens between words.
+func Slug(name string) string {
+	var sb strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if hyphen && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			hyphen = false
+			sb.WriteRune(r)
+		default:
+			hyphen = true
+		}
+	}
+	return sb.String()
+}
+
+// Set replaces the tags of the post with the given names, creating tags
+// that do not exist yet and removing tags no post uses any more. A tag
+// keeps the spelling it was first created with.
+func Set(ctx context.Context, q *dbgen.Queries, postID int64, names []string) error {
+	if err := q.DeletePostTags(ctx, postID); err != nil {
+		return err
+	}
+	if err := Add(ctx, q, postID, names); err != nil {
+		return err
+	}
+	return q.DeleteUnusedTags(ctx)
+}
+
+// Add attaches the tags with the given names to the post, keeping the
+// tags it already has and creating tags that do not exist yet.
+func Add(ctx context.Context, q *dbgen.Queries, postID int64, names []string) error {
+	for _, name := range names {
+		tag, err := q.UpsertTag(ctx, dbgen.UpsertTagParams{Name: name, Slug: Slug(name)})
+		if err != nil {
+			return err
+		}
+		if err := q.AddPostTag(ctx, dbgen.AddPostTagParams{PostID: postID, TagID: tag.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Join formats tags as a comma-separated list that Parse reads back.
+func Join(tags []dbgen.Tag) string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ", ")
+}
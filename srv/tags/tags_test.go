@@ -0,0 +1,42 @@
+package tags
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"travel", []string{"travel"}},
+		{" travel ,  New   York,, ", []string{"travel", "New York"}},
+		{"Go, go, GO!", []string{"Go"}},
+		{"!!!, ok", []string{"ok"}},
+	}
+	for _, tt := range tests {
+		if result := Parse(tt.input); !slices.Equal(result, tt.expected) {
+			t.Errorf("Parse(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"wikipedia", "wikipedia"},
+		{"New York", "new-york"},
+		{"  C++ & Go  ", "c-go"},
+		{"Zürich 2024", "zürich-2024"},
+		{"---", ""},
+	}
+	for _, tt := range tests {
+		if result := Slug(tt.input); result != tt.expected {
+			t.Errorf("Slug(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
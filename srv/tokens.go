@@ -0,0 +1,167 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// scopeRead lets a token read drafts as well as published posts.
+	scopeRead = "read"
+	// scopeWrite lets a token also create, change and delete posts.
+	scopeWrite = "write"
+)
+
+// tokenAllows reports whether token, which may be nil, grants scope. A
+// write token grants everything; other tokens, such as those granted over
+// IndieAuth, grant the space-separated scopes they list.
+func tokenAllows(token *dbgen.ApiToken, scope string) bool {
+	return token != nil && (token.Scope == scopeWrite || slices.Contains(strings.Fields(token.Scope), scope))
+}
+
+// tokenKey is the context key under which API token middleware stores the
+// token a request was made with.
+type tokenKey struct{}
+
+// bearerToken returns the token in r's Authorization header, if any.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// apiToken returns the API token r was made with, or nil if it was made
+// without one.
+func apiToken(r *http.Request) *dbgen.ApiToken {
+	token, _ := r.Context().Value(tokenKey{}).(*dbgen.ApiToken)
+	return token
+}
+
+// checkToken looks up the bearer token of r. It writes a 401 and returns
+// false if the token is not known.
+func (s *Server) checkToken(w http.ResponseWriter, r *http.Request, bearer string) (*http.Request, bool) {
+	q := dbgen.New(s.DB)
+	token, err := q.GetAPIToken(r.Context(), hashToken(bearer))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("get API token", "error", err)
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "invalid API token"})
+		return r, false
+	}
+	now := time.Now().UTC()
+	if err := q.TouchAPIToken(r.Context(), dbgen.TouchAPITokenParams{LastUsedAt: &now, ID: token.ID}); err != nil {
+		slog.Error("touch API token", "error", err)
+	}
+	return r.WithContext(context.WithValue(r.Context(), tokenKey{}, &token)), true
+}
+
+// allowToken lets requests to a public API endpoint through, checking
+// their token if they have one so that the endpoint can show them more.
+func (s *Server) allowToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if bearer, ok := bearerToken(r); ok {
+			if r, ok = s.checkToken(w, r, bearer); !ok {
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// requireWriteToken protects an API endpoint that changes posts. Scripts
+// use a token with the write scope; without a token, the request must
+// come from an admin as for the admin pages.
+func (s *Server) requireWriteToken(next http.HandlerFunc) http.HandlerFunc {
+	admin := s.requireAdmin(next)
+	return s.refuseBanned(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := bearerToken(r)
+		if !ok {
+			admin(w, r)
+			return
+		}
+		if r, ok = s.checkToken(w, r, bearer); !ok {
+			return
+		}
+		if !tokenAllows(apiToken(r), scopeWrite) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			writeJSON(w, http.StatusForbidden, apiError{Error: "this API token cannot change posts"})
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) HandleAdminTokens(w http.ResponseWriter, r *http.Request) {
+	s.renderTokens(w, r, map[string]any{})
+}
+
+// renderTokens shows the API tokens page, with data added to what it
+// always shows.
+func (s *Server) renderTokens(w http.ResponseWriter, r *http.Request, data map[string]any) {
+	tokens, err := dbgen.New(s.DB).ListAPITokens(r.Context())
+	if err != nil {
+		slog.Error("list API tokens", "error", err)
+	}
+	data["Tokens"] = tokens
+	data["BaseURL"] = s.baseURL(r)
+	data["CSRFToken"] = csrfToken(r)
+	data["Year"] = time.Now().Year()
+	s.render(w, "admin_tokens.html", data)
+}
+
+// HandleAdminTokenCreate makes a new API token and shows it, the only time
+// it can be seen.
+func (s *Server) HandleAdminTokenCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	scope := r.FormValue("scope")
+	if name == "" {
+		s.renderTokens(w, r, map[string]any{"Error": "Name the token after what will use it"})
+		return
+	}
+	if scope != scopeRead && scope != scopeWrite {
+		s.renderTokens(w, r, map[string]any{"Error": "Unknown scope " + scope})
+		return
+	}
+	token := rand.Text()
+	err := dbgen.New(s.DB).CreateAPIToken(r.Context(), dbgen.CreateAPITokenParams{
+		Name:      name,
+		TokenHash: hashToken(token),
+		Scope:     scope,
+	})
+	if err != nil {
+		slog.Error("create API token", "error", err)
+		s.renderTokens(w, r, map[string]any{"Error": "Failed to create token: " + err.Error()})
+		return
+	}
+	s.renderTokens(w, r, map[string]any{"NewToken": token, "NewTokenName": name})
+}
+
+func (s *Server) HandleAdminTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := dbgen.New(s.DB).DeleteAPIToken(r.Context(), id); err != nil {
+		slog.Error("delete API token", "error", err)
+	}
+	http.Redirect(w, r, "/admin/tokens", http.StatusFound)
+}
@@ -0,0 +1,85 @@
+// Package totp implements the time-based one-time passwords of RFC 6238
+// that authenticator apps generate: six digits from HMAC-SHA1 of a shared
+// secret and the number of 30 second steps since the Unix epoch.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Step is how long each code lasts.
+	Step = 30 * time.Second
+	// Skew is how many steps either side of the current one codes are
+	// accepted from, to allow for clocks that are out.
+	Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160-bit secret, base32 encoded as
+// authenticator apps expect.
+func NewSecret() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return encoding.EncodeToString(b)
+}
+
+// StepAt returns the step that t falls in.
+func StepAt(t time.Time) int64 {
+	return t.Unix() / int64(Step/time.Second)
+}
+
+// Code returns the code for secret in the given step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.ReplaceAll(secret, " ", "")))
+	if err != nil {
+		return "", fmt.Errorf("totp: invalid secret: %w", err)
+	}
+	if len(key) == 0 {
+		return "", errors.New("totp: empty secret")
+	}
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", n%1_000_000), nil
+}
+
+// Validate reports whether code is the code for secret at t, or within
+// Skew steps of it, and if so which step it was for. Callers should
+// refuse codes for steps no later than the last one accepted, so that a
+// code cannot be used twice.
+func Validate(secret, code string, t time.Time) (step int64, ok bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	now := StepAt(t)
+	for s := now - Skew; s <= now+Skew; s++ {
+		want, err := Code(secret, s)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(want), []byte(code)) {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth link that sets up an authenticator app for
+// secret, labelled with issuer and account.
+func URI(secret, issuer, account string) string {
+	// Some apps show + in the issuer as it is, so spaces are escaped as
+	// %20 in the query too.
+	return "otpauth://totp/" + url.PathEscape(issuer) + ":" + url.PathEscape(account) +
+		"?secret=" + secret + "&issuer=" + strings.ReplaceAll(url.QueryEscape(issuer), "+", "%20")
+}
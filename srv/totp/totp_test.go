@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCode checks the SHA-1 test vectors of RFC 6238, which give eight
+// digits; six-digit codes are their last six.
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, StepAt(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.expected {
+			t.Errorf("code at %d is %s, expected %s", tt.unix, code, tt.expected)
+		}
+	}
+	for _, secret := range []string{"not base32!", ""} {
+		if _, err := Code(secret, 1); err == nil {
+			t.Errorf("expected an error for the secret %q", secret)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	tests := []struct {
+		code string
+		ok   bool
+		step int64
+	}{
+		{"050471", true, StepAt(now)},
+		{"050 471", true, StepAt(now)},
+		{"081804", true, StepAt(now) - 1}, // 1111111109 is in the step before
+		{"000000", false, 0},
+		{"", false, 0},
+	}
+	for _, tt := range tests {
+		step, ok := Validate(rfcSecret, tt.code, now)
+		if ok != tt.ok || step != tt.step {
+			t.Errorf("Validate(%q) = %d, %v, expected %d, %v", tt.code, step, ok, tt.step, tt.ok)
+		}
+	}
+
+	// Codes from the steps either side are accepted, and no further.
+	for offset, ok := range map[time.Duration]bool{-Step: true, Step: true, -2 * Step: false, 2 * Step: false} {
+		code, _ := Code(rfcSecret, StepAt(now.Add(offset)))
+		if _, got := Validate(rfcSecret, code, now); got != ok {
+			t.Errorf("code from %v away: Validate = %v, expected %v", offset, got, ok)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, b := NewSecret(), NewSecret()
+	if len(a) != 32 || a == b {
+		t.Errorf("expected distinct 32 character secrets, got %q and %q", a, b)
+	}
+	if _, err := Code(a, 1); err != nil {
+		t.Errorf("new secret does not decode: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("JBSWY3DPEHPK3PXP", "Citizen of the World", "jo@example.com")
+	expected := "otpauth://totp/Citizen%20of%20the%20World:jo@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Citizen%20of%20the%20World"
+	if uri != expected {
+		t.Errorf("URI = %q, expected %q", uri, expected)
+	}
+}
@@ -0,0 +1,58 @@
+package srv
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func (s *Server) HandleAdminTrash(w http.ResponseWriter, r *http.Request) {
+	posts, err := dbgen.New(s.DB).GetTrashedPosts(r.Context())
+	if err != nil {
+		slog.Error("get trashed posts", "error", err)
+	}
+
+	s.render(w, "admin_trash.html", map[string]any{
+		"Posts":     posts,
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// HandleAdminRestore takes a post out of the trash, as it was when it was
+// deleted.
+func (s *Server) HandleAdminRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := dbgen.New(s.DB)
+	post, err := q.GetPostByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := q.RestorePost(r.Context(), id); err != nil {
+		slog.Error("restore post", "error", err)
+	} else {
+		s.postChanged(r.Context(), &post, id)
+	}
+	http.Redirect(w, r, "/admin/trash", http.StatusFound)
+}
+
+// HandleAdminPurge deletes a post in the trash for good.
+func (s *Server) HandleAdminPurge(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := dbgen.New(s.DB).PurgePost(r.Context(), id); err != nil {
+		slog.Error("purge post", "error", err)
+	}
+	http.Redirect(w, r, "/admin/trash", http.StatusFound)
+}
@@ -0,0 +1,302 @@
+package srv
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/qr"
+	"srv.exe.dev/srv/totp"
+)
+
+const (
+	// loginChallengeTTL is how long someone who has given their password
+	// has to give a code as well.
+	loginChallengeTTL = 5 * time.Minute
+	// maxCodeAttempts is how many wrong codes end a sign-in.
+	maxCodeAttempts = 5
+	// backupCodeCount is how many backup codes are made at a time.
+	backupCodeCount = 10
+	// totpIssuer labels the account in authenticator apps with the site.
+	totpIssuer = siteTitle
+)
+
+// startLoginChallenge asks a user who has given the right password for a
+// code too, before signing them in.
+func (s *Server) startLoginChallenge(w http.ResponseWriter, r *http.Request, userID int64, redirect string) {
+	token := rand.Text()
+	err := dbgen.New(s.DB).CreateLoginChallenge(r.Context(), dbgen.CreateLoginChallengeParams{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(loginChallengeTTL).UTC(),
+	})
+	if err != nil {
+		slog.Error("create login challenge", "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	s.render(w, "login.html", map[string]any{
+		"Challenge": token,
+		"Redirect":  redirect,
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// HandleLoginCode finishes a sign-in started with a password, given a
+// code from the user's authenticator app or one of their backup codes.
+func (s *Server) HandleLoginCode(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	token := r.PostFormValue("challenge")
+	redirect := localRedirect(r.PostFormValue("redirect"))
+	fail := func(status int, data map[string]any) {
+		data["Redirect"] = redirect
+		data["CSRFToken"] = csrfToken(r)
+		data["Year"] = time.Now().Year()
+		s.renderStatus(w, status, "login.html", data)
+	}
+
+	q := dbgen.New(s.DB)
+	challenge, err := q.GetLoginChallenge(r.Context(), hashToken(token))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("get login challenge", "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || challenge.ExpiresAt.Before(time.Now()) || challenge.Attempts >= maxCodeAttempts {
+		if err == nil {
+			if err := q.DeleteLoginChallenge(r.Context(), challenge.TokenHash); err != nil {
+				slog.Error("delete login challenge", "error", err)
+			}
+		}
+		fail(http.StatusUnauthorized, map[string]any{"Error": "That sign-in has expired. Please start again."})
+		return
+	}
+
+	ok, err := s.checkSecondFactor(r, challenge.UserID, r.PostFormValue("code"))
+	if err != nil {
+		slog.Error("check second factor", "error", err)
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		s.loginFailed(r)
+		if err := q.CountLoginChallengeAttempt(r.Context(), challenge.TokenHash); err != nil {
+			slog.Error("count login challenge attempt", "error", err)
+		}
+		fail(http.StatusUnauthorized, map[string]any{
+			"Challenge": token,
+			"Error":     "That code is not right.",
+		})
+		return
+	}
+	if err := q.DeleteLoginChallenge(r.Context(), challenge.TokenHash); err != nil {
+		slog.Error("delete login challenge", "error", err)
+	}
+	s.startSession(w, r, challenge.UserID, redirect)
+}
+
+// checkSecondFactor reports whether code is a current authenticator code
+// or an unused backup code of the user, using it up if so.
+func (s *Server) checkSecondFactor(r *http.Request, userID int64, code string) (bool, error) {
+	q := dbgen.New(s.DB)
+	user, err := q.GetUserByID(r.Context(), userID)
+	if err != nil || user.TotpSecret == "" {
+		return false, err
+	}
+	if step, ok := totp.Validate(user.TotpSecret, code, time.Now()); ok {
+		// Only the first use of a code counts, and no earlier code after it.
+		n, err := q.UseTOTPStep(r.Context(), dbgen.UseTOTPStepParams{ID: userID, Step: step})
+		return n == 1, err
+	}
+	n, err := q.UseBackupCode(r.Context(), dbgen.UseBackupCodeParams{UserID: userID, CodeHash: hashToken(normalizeBackupCode(code))})
+	return n == 1, err
+}
+
+// normalizeBackupCode returns a backup code as it is stored, whatever the
+// case and spacing it was typed in.
+func normalizeBackupCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+// newBackupCodes replaces the backup codes of the user with new ones and
+// returns them, formatted for display.
+func newBackupCodes(r *http.Request, q *dbgen.Queries, userID int64) ([]string, error) {
+	if err := q.DeleteBackupCodes(r.Context(), userID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, backupCodeCount)
+	for i := range codes {
+		code := strings.ToLower(rand.Text()[:10])
+		err := q.CreateBackupCode(r.Context(), dbgen.CreateBackupCodeParams{UserID: userID, CodeHash: hashToken(code)})
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// currentUser returns the user signed in to r with a session.
+func (s *Server) currentUser(r *http.Request) (dbgen.User, bool) {
+	email := sessionEmail(r)
+	if email == "" {
+		return dbgen.User{}, false
+	}
+	user, err := dbgen.New(s.DB).GetUserByEmail(r.Context(), email)
+	if err != nil {
+		slog.Error("get user", "error", err)
+		return dbgen.User{}, false
+	}
+	return user, true
+}
+
+// renderAccount shows the account page for user with the given status,
+// with data added to what it always shows.
+func (s *Server) renderAccount(w http.ResponseWriter, r *http.Request, status int, user dbgen.User, data map[string]any) {
+	remaining, err := dbgen.New(s.DB).CountBackupCodes(r.Context(), user.ID)
+	if err != nil {
+		slog.Error("count backup codes", "error", err)
+	}
+	data["User"] = user
+	data["TwoFactor"] = user.TotpSecret != ""
+	data["BackupCodesLeft"] = remaining
+	data["CSRFToken"] = csrfToken(r)
+	data["Year"] = time.Now().Year()
+	s.renderStatus(w, status, "admin_account.html", data)
+}
+
+func (s *Server) HandleAdminAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.currentUser(r)
+	if !ok {
+		s.render(w, "admin_account.html", map[string]any{"Year": time.Now().Year()})
+		return
+	}
+	s.renderAccount(w, r, http.StatusOK, user, map[string]any{})
+}
+
+// renderTOTPSetup shows the QR code and secret for setting up an
+// authenticator app, and asks for a code from it, with the given status.
+func (s *Server) renderTOTPSetup(w http.ResponseWriter, r *http.Request, status int, user dbgen.User, secret, errMsg string) {
+	uri := totp.URI(secret, totpIssuer, user.Email)
+	var qrSVG template.HTML
+	if code, err := qr.Encode(uri); err == nil {
+		qrSVG = template.HTML(code.SVG())
+	} else {
+		slog.Error("encode QR code", "error", err)
+	}
+	s.renderAccount(w, r, status, user, map[string]any{
+		"Setup": map[string]any{
+			"Secret": secret,
+			"URI":    uri,
+			"QR":     qrSVG,
+		},
+		"Error": errMsg,
+	})
+}
+
+// HandleAdminTOTPSetup starts setting up two-factor authentication with a
+// new secret. Nothing is stored until a code from it is confirmed.
+func (s *Server) HandleAdminTOTPSetup(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.currentUser(r)
+	if !ok {
+		http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+		return
+	}
+	s.renderTOTPSetup(w, r, http.StatusOK, user, totp.NewSecret(), "")
+}
+
+// HandleAdminTOTPEnable turns two-factor authentication on once the user
+// has shown their app generates the right codes, and gives them their
+// backup codes.
+func (s *Server) HandleAdminTOTPEnable(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.currentUser(r)
+	if !ok {
+		http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+		return
+	}
+	secret := r.PostFormValue("secret")
+	step, ok := totp.Validate(secret, r.PostFormValue("code"), time.Now())
+	if !ok {
+		s.renderTOTPSetup(w, r, http.StatusUnprocessableEntity, user, secret, "That code is not right. Check the time on your device and try the next one.")
+		return
+	}
+
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.Error("begin enable two-factor", "error", err)
+		http.Error(w, "Failed to turn on two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	err = q.SetUserTOTP(r.Context(), dbgen.SetUserTOTPParams{ID: user.ID, TotpSecret: secret, TotpLastStep: step})
+	var codes []string
+	if err == nil {
+		codes, err = newBackupCodes(r, q, user.ID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.Error("enable two-factor", "error", err)
+		http.Error(w, "Failed to turn on two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	user.TotpSecret = secret
+	s.renderAccount(w, r, http.StatusOK, user, map[string]any{"BackupCodes": codes})
+}
+
+// HandleAdminBackupCodes replaces the user's backup codes, for when they
+// have used or lost them.
+func (s *Server) HandleAdminBackupCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.currentUser(r)
+	if !ok || user.TotpSecret == "" {
+		http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+		return
+	}
+	codes, err := newBackupCodes(r, dbgen.New(s.DB), user.ID)
+	if err != nil {
+		slog.Error("create backup codes", "error", err)
+		http.Error(w, "Failed to make backup codes", http.StatusInternalServerError)
+		return
+	}
+	s.renderAccount(w, r, http.StatusOK, user, map[string]any{"BackupCodes": codes})
+}
+
+// HandleAdminTOTPDisable turns two-factor authentication off, given a
+// current code, so that a session left open cannot do it alone.
+func (s *Server) HandleAdminTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.currentUser(r)
+	if !ok || user.TotpSecret == "" {
+		http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+		return
+	}
+	ok, err := s.checkSecondFactor(r, user.ID, r.PostFormValue("code"))
+	if err == nil && !ok {
+		s.renderAccount(w, r, http.StatusUnprocessableEntity, user, map[string]any{"Error": "That code is not right."})
+		return
+	}
+	q := dbgen.New(s.DB)
+	if err == nil {
+		err = q.SetUserTOTP(r.Context(), dbgen.SetUserTOTPParams{ID: user.ID})
+	}
+	if err == nil {
+		err = q.DeleteBackupCodes(r.Context(), user.ID)
+	}
+	if err != nil {
+		slog.Error("disable two-factor", "error", err)
+		http.Error(w, "Failed to turn off two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+}
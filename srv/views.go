@@ -0,0 +1,198 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// viewFlushInterval is how often the views of posts counted since are
+// written to the database. Views not yet written are lost if the server
+// stops.
+const viewFlushInterval = 10 * time.Second
+
+// viewKey is what views are counted by: a post and a day, in UTC.
+type viewKey struct {
+	postID int64
+	day    string
+}
+
+// referralKey is what visits from other sites are counted by: a post, a
+// day, in UTC, and the host of the page linking to the post.
+type referralKey struct {
+	viewKey
+	host string
+}
+
+// viewCounts are views counted in memory and not yet written.
+type viewCounts struct {
+	posts     map[viewKey]int64
+	referrals map[referralKey]int64
+	pages     map[pageKey]int64
+	visitors  map[visitorKey]bool
+	clicks    map[clickKey]int64
+}
+
+// viewBuffer returns the views counted and not yet written, making it if
+// none have been. s.viewMu must be held.
+func (s *Server) viewBuffer() *viewCounts {
+	if s.views == nil {
+		s.views = &viewCounts{
+			posts:     make(map[viewKey]int64),
+			referrals: make(map[referralKey]int64),
+			pages:     make(map[pageKey]int64),
+			visitors:  make(map[visitorKey]bool),
+			clicks:    make(map[clickKey]int64),
+		}
+	}
+	return s.views
+}
+
+// countView counts a view of the post with the given ID, and a visit to
+// it from referrer, the host that linked to it, unless that is "". They
+// are kept in memory for viewLoop to write, so that serving the page does
+// not wait on the database.
+func (s *Server) countView(postID int64, referrer string) {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+	views := s.viewBuffer()
+	k := viewKey{postID, time.Now().UTC().Format(time.DateOnly)}
+	views.posts[k]++
+	if referrer != "" {
+		views.referrals[referralKey{k, referrer}]++
+	}
+}
+
+// referrerHost returns the host of the page r's Referer header names, as
+// bareHost gives it, or "" if there is none or it is the blog's own.
+func (s *Server) referrerHost(r *http.Request) string {
+	ref, err := url.Parse(r.Header.Get("Referer"))
+	if err != nil || ref.Scheme != "http" && ref.Scheme != "https" {
+		return ""
+	}
+	host := bareHost(ref.Hostname())
+	if host == "" || host == bareHost((&url.URL{Host: r.Host}).Hostname()) {
+		return ""
+	}
+	if host == s.siteHost(r.Context()) {
+		return ""
+	}
+	return host
+}
+
+// bareHost returns host in lower case and without a leading "www.", so
+// that the two names most sites go by count as one.
+func bareHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// viewLoop runs flushViews every viewFlushInterval.
+func (s *Server) viewLoop(ctx context.Context) {
+	ticker := time.NewTicker(viewFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.flushViews(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			s.flushViews(ctx)
+		}
+	}
+}
+
+// flushViews writes the views of posts and pages, visitors, visits from
+// other sites and clicks on links to them counted since it last ran. If
+// they cannot be written at all they are kept to try again; those of a
+// post that cannot be written, as the post is gone, are dropped.
+func (s *Server) flushViews(ctx context.Context) {
+	s.viewMu.Lock()
+	views := s.views
+	s.views = nil
+	s.viewMu.Unlock()
+	if views == nil {
+		return
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("write views", "error", err)
+		s.keepViews(views)
+		return
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	for k, n := range views.posts {
+		err := q.AddPostViews(ctx, dbgen.AddPostViewsParams{PostID: k.postID, Day: k.day, Views: n})
+		if err != nil {
+			slog.Warn("write post views", "post", k.postID, "error", err)
+		}
+	}
+	for k, n := range views.referrals {
+		err := q.AddPostReferrals(ctx, dbgen.AddPostReferralsParams{PostID: k.postID, Day: k.day, Host: k.host, Visits: n})
+		if err != nil {
+			slog.Warn("write post referrals", "post", k.postID, "error", err)
+		}
+	}
+	for k, n := range views.pages {
+		if err := q.AddPageViews(ctx, dbgen.AddPageViewsParams{Day: k.day, Path: k.path, Views: n}); err != nil {
+			slog.Warn("write page views", "path", k.path, "error", err)
+		}
+	}
+	for k := range views.visitors {
+		if err := q.AddDailyVisitor(ctx, dbgen.AddDailyVisitorParams{Day: k.day, Visitor: k.visitor}); err != nil {
+			slog.Warn("write daily visitor", "error", err)
+		}
+	}
+	for k, n := range views.clicks {
+		err := q.AddLinkClicks(ctx, dbgen.AddLinkClicksParams{PostID: k.postID, Day: k.day, Target: k.target, Clicks: n})
+		if err != nil {
+			slog.Warn("write link clicks", "post", k.postID, "error", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("write views", "error", err)
+		s.keepViews(views)
+	}
+}
+
+// keepViews puts back views that could not be written, adding them to any
+// counted since.
+func (s *Server) keepViews(views *viewCounts) {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+	buf := s.viewBuffer()
+	for k, n := range views.posts {
+		buf.posts[k] += n
+	}
+	for k, n := range views.referrals {
+		buf.referrals[k] += n
+	}
+	for k, n := range views.pages {
+		buf.pages[k] += n
+	}
+	for k := range views.visitors {
+		buf.visitors[k] = true
+	}
+	for k, n := range views.clicks {
+		buf.clicks[k] += n
+	}
+}
+
+// postViewTotals returns every post's views of all time, by post ID.
+func postViewTotals(ctx context.Context, q *dbgen.Queries) map[int64]int64 {
+	rows, err := q.ListPostViewTotals(ctx)
+	if err != nil {
+		slog.Error("list post view totals", "error", err)
+	}
+	totals := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		totals[row.PostID] = row.Views
+	}
+	return totals
+}
@@ -0,0 +1,319 @@
+package srv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The events webhooks can be sent: a post appearing on the site, changing
+// while it is on it, and leaving it, whether for the trash or as a draft.
+const (
+	eventPostPublished = "post.published"
+	eventPostUpdated   = "post.updated"
+	eventPostDeleted   = "post.deleted"
+)
+
+var webhookEvents = []string{eventPostPublished, eventPostUpdated, eventPostDeleted}
+
+const (
+	// webhookInterval is how often the server looks for deliveries due to
+	// be retried.
+	webhookInterval = 30 * time.Second
+	// webhookLogAge is how long deliveries are kept once they are done
+	// with.
+	webhookLogAge = 30 * 24 * time.Hour
+	// webhookLogSize is the number of recent deliveries the admin shows.
+	webhookLogSize = 50
+)
+
+// webhookRetries are the waits before each retry of a failed delivery.
+// Once they are used up, the delivery is given up on.
+var webhookRetries = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// webhookClient sends webhook deliveries.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookPayload is the body of a webhook delivery. The post is as the
+// posts API gives it; its URL is only absolute if the site URL is set.
+type webhookPayload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Post       apiPost   `json:"post"`
+}
+
+// onSite reports whether p can be seen on the site.
+func onSite(p *dbgen.Post) bool {
+	return p != nil && p.Published == 1 && p.DeletedAt == nil
+}
+
+// postChanged follows up a change to the post with the given ID: it is
+// committed back to the content directory, and the webhook event for it
+// and Webmentions to the pages it links to are queued if the post was on
+// the site before the change or is after it. before is the post as it
+// was, or nil if it is new.
+func (s *Server) postChanged(ctx context.Context, before *dbgen.Post, id int64) {
+	after, err := dbgen.New(s.DB).GetPostByID(ctx, id)
+	if err != nil {
+		slog.Error("get changed post", "id", id, "error", err)
+		return
+	}
+	s.commitPost(ctx, before, after)
+	s.queueWebmentionSends(ctx, before, after)
+	var event string
+	switch was, is := onSite(before), onSite(&after); {
+	case !was && is:
+		event = eventPostPublished
+	case was && is:
+		event = eventPostUpdated
+	case was && !is:
+		event = eventPostDeleted
+	default:
+		return
+	}
+	s.queueWebhooks(ctx, event, after)
+}
+
+// queueWebhooks queues a delivery of event about p to each webhook that
+// wants it, and wakes webhookLoop to send them.
+func (s *Server) queueWebhooks(ctx context.Context, event string, p dbgen.Post) {
+	q := dbgen.New(s.DB)
+	hooks, err := q.ListWebhooks(ctx)
+	if err != nil {
+		slog.Error("list webhooks", "error", err)
+		return
+	}
+	now := time.Now().UTC()
+	var payload []byte
+	queued := false
+	for _, h := range hooks {
+		if !slices.Contains(strings.Split(h.Events, ","), event) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(webhookPayload{
+				Event:      event,
+				OccurredAt: now,
+				Post:       s.apiPostAt(ctx, s.setting(ctx, settingSiteURL), p),
+			})
+			if err != nil {
+				slog.Error("encode webhook payload", "error", err)
+				return
+			}
+		}
+		err := q.CreateWebhookDelivery(ctx, dbgen.CreateWebhookDeliveryParams{
+			WebhookID:     h.ID,
+			Event:         event,
+			Payload:       string(payload),
+			NextAttemptAt: &now,
+		})
+		if err != nil {
+			slog.Error("queue webhook delivery", "error", err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		s.requestWebhooks()
+	}
+}
+
+// webhookLoop runs deliverWebhooks every webhookInterval and whenever a
+// delivery is queued.
+func (s *Server) webhookLoop(ctx context.Context) {
+	ticker := time.NewTicker(webhookInterval)
+	defer ticker.Stop()
+	for {
+		s.deliverWebhooks(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.webhooks:
+		}
+	}
+}
+
+// requestWebhooks wakes webhookLoop without waiting for it.
+func (s *Server) requestWebhooks() {
+	select {
+	case s.webhooks <- struct{}{}:
+	default:
+	}
+}
+
+// deliverWebhooks makes the delivery attempts that are due, scheduling a
+// retry of each that fails until webhookRetries are used up. Deliveries
+// done with for longer than webhookLogAge are then deleted.
+func (s *Server) deliverWebhooks(ctx context.Context) {
+	q := dbgen.New(s.DB)
+	pending, err := q.ListPendingWebhookDeliveries(ctx)
+	if err != nil {
+		slog.Error("list pending webhook deliveries", "error", err)
+		return
+	}
+	for _, d := range pending {
+		if d.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		status, err := sendWebhook(ctx, d)
+		now := time.Now().UTC()
+		params := dbgen.RecordWebhookAttemptParams{StatusCode: int64(status), ID: d.ID}
+		if err == nil {
+			params.DeliveredAt = &now
+		} else {
+			slog.Error("deliver webhook", "url", d.Url, "event", d.Event, "attempt", d.Attempts+1, "error", err)
+			params.Error = err.Error()
+			if int(d.Attempts) < len(webhookRetries) {
+				next := now.Add(webhookRetries[d.Attempts])
+				params.NextAttemptAt = &next
+			}
+		}
+		if err := q.RecordWebhookAttempt(ctx, params); err != nil {
+			slog.Error("record webhook attempt", "error", err)
+		}
+	}
+	before := time.Now().UTC().Add(-webhookLogAge).Format(time.DateTime)
+	if err := q.PruneWebhookDeliveries(ctx, before); err != nil {
+		slog.Error("prune webhook deliveries", "error", err)
+	}
+}
+
+// sendWebhook POSTs a delivery, returning the status it was answered with.
+// It fails unless that is a 2xx status.
+func sendWebhook(ctx context.Context, d dbgen.ListPendingWebhookDeliveriesRow) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(d.Secret, now, []byte(d.Payload)))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhook returns the signature of a delivery of body sent at t: the
+// hex HMAC-SHA256, keyed with the webhook's secret, of t as a Unix time,
+// a dot and the body. Receivers can check it, and the time, to know the
+// delivery is genuine and fresh.
+func signWebhook(secret string, t time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", t.Unix())
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) HandleAdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	s.renderWebhooks(w, r, "")
+}
+
+func (s *Server) renderWebhooks(w http.ResponseWriter, r *http.Request, errMsg string) {
+	q := dbgen.New(s.DB)
+	hooks, err := q.ListWebhooks(r.Context())
+	if err != nil {
+		slog.Error("list webhooks", "error", err)
+	}
+	deliveries, err := q.ListWebhookDeliveries(r.Context(), webhookLogSize)
+	if err != nil {
+		slog.Error("list webhook deliveries", "error", err)
+	}
+	s.render(w, "admin_webhooks.html", map[string]any{
+		"Webhooks":   hooks,
+		"Deliveries": deliveries,
+		"Events":     webhookEvents,
+		"Error":      errMsg,
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
+	})
+}
+
+// HandleAdminWebhookCreate adds a webhook for the URL and events in the
+// form, with a new secret to sign its deliveries with.
+func (s *Server) HandleAdminWebhookCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	target := strings.TrimSpace(r.FormValue("url"))
+	if u, err := url.Parse(target); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		s.renderWebhooks(w, r, "Enter the full http:// or https:// address to send events to")
+		return
+	}
+	var events []string
+	for _, event := range r.Form["events"] {
+		if !slices.Contains(webhookEvents, event) {
+			s.renderWebhooks(w, r, "Unknown event "+event)
+			return
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		s.renderWebhooks(w, r, "Choose at least one event to send")
+		return
+	}
+	err := dbgen.New(s.DB).CreateWebhook(r.Context(), dbgen.CreateWebhookParams{
+		Url:    target,
+		Secret: rand.Text(),
+		Events: strings.Join(events, ","),
+	})
+	if err != nil {
+		slog.Error("create webhook", "error", err)
+		s.renderWebhooks(w, r, "Failed to add webhook: "+err.Error())
+		return
+	}
+	http.Redirect(w, r, "/admin/webhooks", http.StatusFound)
+}
+
+// HandleAdminWebhookDelete removes a webhook along with its deliveries.
+func (s *Server) HandleAdminWebhookDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := dbgen.New(s.DB).DeleteWebhook(r.Context(), id); err != nil {
+		slog.Error("delete webhook", "error", err)
+	}
+	http.Redirect(w, r, "/admin/webhooks", http.StatusFound)
+}
+
+// HandleAdminWebhookRetry tries a delivery that was given up on once more.
+func (s *Server) HandleAdminWebhookRetry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now().UTC()
+	if err := dbgen.New(s.DB).RetryWebhookDelivery(r.Context(), dbgen.RetryWebhookDeliveryParams{NextAttemptAt: &now, ID: id}); err != nil {
+		slog.Error("retry webhook delivery", "error", err)
+	}
+	s.requestWebhooks()
+	http.Redirect(w, r, "/admin/webhooks", http.StatusFound)
+}
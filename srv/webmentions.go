@@ -0,0 +1,394 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The states a checked Webmention can be in. Only approved ones are shown.
+const (
+	mentionPending  = "pending"
+	mentionApproved = "approved"
+	mentionRejected = "rejected"
+)
+
+const (
+	// webmentionInterval is how often the server looks for Webmentions
+	// to check or send, besides when one is received or queued.
+	webmentionInterval = time.Minute
+	// webmentionBatch is the most Webmentions checked at a time.
+	webmentionBatch = 20
+	// maxWebmentionSource is the most of a source page that is read
+	// looking for the link to the post.
+	maxWebmentionSource = 1 << 20
+	// maxMentionTitle is the longest title, in characters, kept for a
+	// source.
+	maxMentionTitle = 200
+	// mentionPageSize is the number of Webmentions on each page of the
+	// admin.
+	mentionPageSize = 50
+)
+
+// webmentionClient fetches the sources of Webmentions received, and the
+// pages and endpoints of those sent. Anyone can send one, and any page
+// can name an endpoint, so it only connects to public addresses, not to
+// the server itself or others on its network.
+var webmentionClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: publicAddressOnly}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// publicAddressOnly refuses connections to loopback, private, link-local
+// and other addresses that are not on the public internet.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	addr := ap.Addr().Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return fmt.Errorf("refusing to connect to %s, which is not a public address", addr)
+	}
+	return nil
+}
+
+// MentionView is a Webmention as the post page shows it.
+type MentionView struct {
+	Source    string
+	Title     string // the source page's title, or its address if it has none
+	Host      string
+	CreatedAt time.Time
+}
+
+// HandleWebmention receives a Webmention: a source page telling the blog
+// that it links to target, one of the blog's posts. It only checks that
+// the target is a post here, answering 202 Accepted, and leaves fetching
+// the source to webmentionLoop.
+func (s *Server) HandleWebmention(w http.ResponseWriter, r *http.Request) {
+	source := strings.TrimSpace(r.PostFormValue("source"))
+	target := strings.TrimSpace(r.PostFormValue("target"))
+	switch {
+	case source == "" || target == "":
+		http.Error(w, "source and target are both needed", http.StatusBadRequest)
+		return
+	case !isHTTPURL(source) || !isHTTPURL(target):
+		http.Error(w, "source and target must be http or https URLs", http.StatusBadRequest)
+		return
+	case source == target:
+		http.Error(w, "source and target must differ", http.StatusBadRequest)
+		return
+	}
+	p, ok := s.mentionTarget(r, target)
+	if !ok {
+		http.Error(w, "target is not a post on this site", http.StatusBadRequest)
+		return
+	}
+	err := dbgen.New(s.DB).ReceiveWebmention(r.Context(), dbgen.ReceiveWebmentionParams{
+		PostID: p.ID,
+		Source: source,
+		Target: target,
+	})
+	if err != nil {
+		slog.Error("receive webmention", "error", err)
+		http.Error(w, "Failed to save the mention", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("webmention received", "source", source, "post", p.Slug)
+	s.requestWebmentions()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	io.WriteString(w, "The mention will be checked shortly.\n")
+}
+
+// mentionTarget returns the post that target, a URL on this site, is the
+// address of, if it is a post that is on the site.
+func (s *Server) mentionTarget(r *http.Request, target string) (dbgen.Post, bool) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return dbgen.Post{}, false
+	}
+	base, err := url.Parse(s.baseURL(r))
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return dbgen.Post{}, false
+	}
+	slug, ok := strings.CutPrefix(u.Path, strings.TrimSuffix(base.Path, "/")+"/post/")
+	if !ok || slug == "" || strings.Contains(slug, "/") {
+		return dbgen.Post{}, false
+	}
+	p, err := dbgen.New(s.DB).GetPostBySlug(r.Context(), slug)
+	if err != nil || !onSite(&p) {
+		return dbgen.Post{}, false
+	}
+	return p, true
+}
+
+// webmentionLoop runs checkWebmentions and sendWebmentions every
+// webmentionInterval and whenever a Webmention is received or queued to
+// be sent.
+func (s *Server) webmentionLoop(ctx context.Context) {
+	ticker := time.NewTicker(webmentionInterval)
+	defer ticker.Stop()
+	for {
+		s.checkWebmentions(ctx)
+		s.sendWebmentions(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.webmentions:
+		}
+	}
+}
+
+// requestWebmentions wakes webmentionLoop without waiting for it.
+func (s *Server) requestWebmentions() {
+	select {
+	case s.webmentions <- struct{}{}:
+	default:
+	}
+}
+
+// checkWebmentions fetches the source of each Webmention received since
+// it was last checked. One whose source links to its target is kept, with
+// the source's title, for the admin to approve; one whose source does not,
+// or is gone, is deleted, even if it was approved before. If the source
+// cannot be fetched at all, a Webmention already checked once is left as
+// it was and a new one is deleted.
+func (s *Server) checkWebmentions(ctx context.Context) {
+	q := dbgen.New(s.DB)
+	for {
+		mentions, err := q.ListWebmentionsToCheck(ctx, webmentionBatch)
+		if err != nil {
+			slog.Error("list webmentions to check", "error", err)
+			return
+		}
+		if len(mentions) == 0 {
+			return
+		}
+		for _, m := range mentions {
+			title, links, err := fetchMentionSource(ctx, m.Source, m.Target)
+			switch {
+			case err != nil && m.VerifiedAt != nil:
+				slog.Warn("check webmention", "source", m.Source, "error", err)
+				err = q.SetWebmentionChecked(ctx, m.ID)
+			case err != nil || !links:
+				slog.Info("webmention dropped", "source", m.Source, "target", m.Target, "error", err)
+				err = q.DeleteWebmention(ctx, m.ID)
+			default:
+				err = q.SetWebmentionVerified(ctx, dbgen.SetWebmentionVerifiedParams{Title: title, ID: m.ID})
+			}
+			if err != nil {
+				slog.Error("update webmention", "id", m.ID, "error", err)
+				return
+			}
+		}
+	}
+}
+
+var (
+	reMentionHref  = regexp.MustCompile(`(?i)<(?:a|link|area)\b[^>]*?\shref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	reMentionTitle = regexp.MustCompile(`(?is)<title\b[^>]*>(.*?)</title>`)
+)
+
+// fetchMentionSource fetches source and reports whether it links to
+// target, with its title. An HTML page must have a link to target; any
+// other document need only contain target's address. A source that
+// answers with an error status, such as 410 Gone, links to nothing.
+func fetchMentionSource(ctx context.Context, source, target string) (title string, links bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+	resp, err := webmentionClient.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", false, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebmentionSource))
+	if err != nil {
+		return "", false, err
+	}
+	page := string(body)
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != "text/html" && mt != "application/xhtml+xml" {
+		return "", strings.Contains(page, target), nil
+	}
+	want := sameMentionURL(target)
+	for _, m := range reMentionHref.FindAllStringSubmatch(page, -1) {
+		href := html.UnescapeString(m[1] + m[2] + m[3])
+		if u, err := resp.Request.URL.Parse(strings.TrimSpace(href)); err == nil && sameMentionURL(u.String()) == want {
+			links = true
+			break
+		}
+	}
+	if m := reMentionTitle.FindStringSubmatch(page); m != nil {
+		title = strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+		if utf8.RuneCountInString(title) > maxMentionTitle {
+			title = string([]rune(title)[:maxMentionTitle-1]) + "…"
+		}
+	}
+	return title, links, nil
+}
+
+// sameMentionURL returns rawURL in a form that is the same for addresses
+// of the same page: without its fragment, and with the scheme and host in
+// lower case.
+func sameMentionURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+// postMentions returns the approved Webmentions of the post with the
+// given ID, oldest first.
+func (s *Server) postMentions(ctx context.Context, postID int64) []MentionView {
+	mentions, err := dbgen.New(s.DB).ListPostWebmentions(ctx, postID)
+	if err != nil {
+		slog.Error("list post webmentions", "error", err)
+		return nil
+	}
+	views := make([]MentionView, len(mentions))
+	for i, m := range mentions {
+		views[i] = MentionView{Source: m.Source, Title: m.Title, CreatedAt: m.CreatedAt}
+		if u, err := url.Parse(m.Source); err == nil {
+			views[i].Host = strings.TrimPrefix(u.Hostname(), "www.")
+		}
+		if views[i].Title == "" {
+			views[i].Title = m.Source
+		}
+	}
+	return views
+}
+
+// mentionsChangedAt returns when a Webmention of the post with the given
+// ID last changed, or the zero time if it has none.
+func (s *Server) mentionsChangedAt(ctx context.Context, postID int64) time.Time {
+	changed, err := dbgen.New(s.DB).GetPostWebmentionsChangedAt(ctx, postID)
+	if err != nil {
+		slog.Error("get post webmentions changed at", "error", err)
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.DateTime, changed)
+	return t
+}
+
+// MentionCounts is the number of checked Webmentions in each state.
+type MentionCounts struct {
+	Pending  int64
+	Approved int64
+	Rejected int64
+}
+
+// mentionCounts counts the checked Webmentions in each state.
+func mentionCounts(ctx context.Context, q *dbgen.Queries) MentionCounts {
+	var counts MentionCounts
+	rows, err := q.CountWebmentionsByStatus(ctx)
+	if err != nil {
+		slog.Error("count webmentions", "error", err)
+	}
+	for _, row := range rows {
+		switch row.Status {
+		case mentionPending:
+			counts.Pending = row.MentionCount
+		case mentionApproved:
+			counts.Approved = row.MentionCount
+		case mentionRejected:
+			counts.Rejected = row.MentionCount
+		}
+	}
+	return counts
+}
+
+// HandleAdminWebmentions shows the checked Webmentions in the state given
+// by the status query parameter, newest first, pending ones by default.
+func (s *Server) HandleAdminWebmentions(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case mentionApproved, mentionRejected:
+	default:
+		status = mentionPending
+	}
+	q := dbgen.New(s.DB)
+	counts := mentionCounts(r.Context(), q)
+	total := map[string]int64{
+		mentionPending:  counts.Pending,
+		mentionApproved: counts.Approved,
+		mentionRejected: counts.Rejected,
+	}[status]
+	page := pageNumber(r)
+	mentions, err := q.ListWebmentionsByStatus(r.Context(), dbgen.ListWebmentionsByStatusParams{
+		Status: status,
+		Limit:  mentionPageSize,
+		Offset: int64((page - 1) * mentionPageSize),
+	})
+	if err != nil {
+		slog.Error("list webmentions", "error", err)
+	}
+	s.render(w, "admin_webmentions.html", map[string]any{
+		"Mentions":   mentions,
+		"Counts":     counts,
+		"Status":     status,
+		"Pagination": paginate(page, mentionPageSize, total),
+		"CSRFToken":  csrfToken(r),
+		"Year":       time.Now().Year(),
+	})
+}
+
+// HandleAdminWebmentionModerate approves, rejects or deletes the
+// Webmention with the ID in the id form value, as the action form value
+// says. A deleted Webmention comes back if it is sent again.
+func (s *Server) HandleAdminWebmentionModerate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PostFormValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad mention ID", http.StatusBadRequest)
+		return
+	}
+	q := dbgen.New(s.DB)
+	switch action := r.PostFormValue("action"); action {
+	case "approve":
+		err = q.SetWebmentionStatus(r.Context(), dbgen.SetWebmentionStatusParams{Status: mentionApproved, ID: id})
+	case "reject":
+		err = q.SetWebmentionStatus(r.Context(), dbgen.SetWebmentionStatusParams{Status: mentionRejected, ID: id})
+	case "delete":
+		err = q.DeleteWebmention(r.Context(), id)
+	default:
+		http.Error(w, "Unknown action "+action, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("moderate webmention", "id", id, "error", err)
+		http.Error(w, "Failed to update", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/webmentions?status="+url.QueryEscape(r.PostFormValue("status")), http.StatusFound)
+}
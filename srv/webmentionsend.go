@@ -0,0 +1,270 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+	"srv.exe.dev/srv/markdown"
+)
+
+// webmentionRetries are the waits before each retry of a Webmention that
+// could not be sent. Once they are used up, it is given up on.
+var webmentionRetries = []time.Duration{5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// errNoEndpoint is the error of a Webmention to a page that does not take
+// them. It is not retried.
+var errNoEndpoint = errors.New("the page has no Webmention endpoint")
+
+// queueWebmentionSends queues Webmentions from a post that has changed to
+// the pages it links to and, if it was on the site before the change, to
+// those it linked to then, so that pages it no longer links to, or all of
+// them once it is taken down, can drop their mention of it. Links within
+// the site are left out. Without a site URL nothing is queued, as the post
+// has no public address for the pages to check.
+func (s *Server) queueWebmentionSends(ctx context.Context, before *dbgen.Post, after dbgen.Post) {
+	base := s.setting(ctx, settingSiteURL)
+	if base == "" {
+		return
+	}
+	var targets []string
+	for _, p := range []*dbgen.Post{before, &after} {
+		if !onSite(p) {
+			continue
+		}
+		for _, link := range s.postLinks(ctx, *p, base) {
+			if !slices.Contains(targets, link) {
+				targets = append(targets, link)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	q := dbgen.New(s.DB)
+	now := time.Now().UTC()
+	for _, target := range targets {
+		err := q.QueueWebmentionSend(ctx, dbgen.QueueWebmentionSendParams{
+			PostID:        after.ID,
+			Source:        base + "/post/" + after.Slug,
+			Target:        target,
+			NextAttemptAt: &now,
+		})
+		if err != nil {
+			slog.Error("queue webmention", "target", target, "error", err)
+		}
+	}
+	s.requestWebmentions()
+}
+
+// postLinks returns the absolute http and https addresses that p links
+// to outside the site at base.
+func (s *Server) postLinks(ctx context.Context, p dbgen.Post, base string) []string {
+	page, err := url.Parse(base + "/post/" + p.Slug)
+	if err != nil {
+		return nil
+	}
+	var links []string
+	for _, dest := range markdown.Links(s.markdownOptions(ctx, p).Parse(p.Content)) {
+		u, err := page.Parse(dest)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || strings.EqualFold(u.Host, page.Host) {
+			continue
+		}
+		u.Fragment = ""
+		if link := u.String(); !slices.Contains(links, link) {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// sendWebmentions sends the queued Webmentions that are due, scheduling a
+// retry of each that fails until webmentionRetries are used up.
+func (s *Server) sendWebmentions(ctx context.Context) {
+	q := dbgen.New(s.DB)
+	pending, err := q.ListPendingWebmentionSends(ctx)
+	if err != nil {
+		slog.Error("list pending webmentions", "error", err)
+		return
+	}
+	for _, m := range pending {
+		if m.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		endpoint, status, err := sendWebmention(ctx, m.Source, m.Target)
+		now := time.Now().UTC()
+		params := dbgen.RecordWebmentionSendParams{Endpoint: endpoint, StatusCode: int64(status), ID: m.ID}
+		switch {
+		case err == nil:
+			params.SentAt = &now
+		case errors.Is(err, errNoEndpoint):
+			params.Error = err.Error()
+		default:
+			slog.Warn("send webmention", "target", m.Target, "attempt", m.Attempts+1, "error", err)
+			params.Error = err.Error()
+			if int(m.Attempts) < len(webmentionRetries) {
+				next := now.Add(webmentionRetries[m.Attempts])
+				params.NextAttemptAt = &next
+			}
+		}
+		if err := q.RecordWebmentionSend(ctx, params); err != nil {
+			slog.Error("record webmention send", "error", err)
+		}
+	}
+}
+
+// sendWebmention tells target that source links to it, at the Webmention
+// endpoint target advertises. It returns the endpoint, if one was found,
+// and the status it answered with, failing unless that is a 2xx status.
+func sendWebmention(ctx context.Context, source, target string) (endpoint string, status int, err error) {
+	endpoint, err = discoverWebmentionEndpoint(ctx, target)
+	if err != nil {
+		return "", 0, err
+	}
+	form := url.Values{"source": {source}, "target": {target}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return endpoint, 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := webmentionClient.Do(req)
+	if err != nil {
+		return endpoint, 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return endpoint, resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return endpoint, resp.StatusCode, nil
+}
+
+var (
+	reLinkHeader = regexp.MustCompile(`<([^>]*)>([^,]*)`)
+	reLinkRel    = regexp.MustCompile(`(?i);\s*rel\s*=\s*(?:"([^"]*)"|([^\s;"]+))`)
+	reRelTag     = regexp.MustCompile(`(?i)<(?:link|a)\b[^>]*>`)
+	reTagAttr    = regexp.MustCompile(`(?i)\s(rel|href)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// discoverWebmentionEndpoint fetches target and returns the Webmention
+// endpoint it advertises: the first in its Link headers or, failing that,
+// the first <link> or <a> element in it with a rel of webmention.
+func discoverWebmentionEndpoint(ctx context.Context, target string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+	resp, err := webmentionClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("fetching the page: unexpected status %s", resp.Status)
+	}
+	href, found := "", false
+	for _, header := range resp.Header.Values("Link") {
+		for _, link := range reLinkHeader.FindAllStringSubmatch(header, -1) {
+			if m := reLinkRel.FindStringSubmatch(link[2]); m != nil && hasWebmentionRel(m[1]+m[2]) {
+				href, found = link[1], true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); !found && mt == "text/html" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebmentionSource))
+		if err != nil {
+			return "", err
+		}
+		for _, tag := range reRelTag.FindAllString(string(body), -1) {
+			attrs := map[string]string{}
+			for _, m := range reTagAttr.FindAllStringSubmatch(tag, -1) {
+				if name := strings.ToLower(m[1]); attrs[name] == "" {
+					attrs[name] = html.UnescapeString(m[2] + m[3] + m[4])
+				}
+			}
+			if _, ok := attrs["href"]; ok && hasWebmentionRel(attrs["rel"]) {
+				href, found = attrs["href"], true
+				break
+			}
+		}
+	}
+	if !found {
+		return "", errNoEndpoint
+	}
+	// The endpoint may be relative to the page, or even the page itself.
+	endpoint, err := resp.Request.URL.Parse(strings.TrimSpace(href))
+	if err != nil || endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return "", fmt.Errorf("the page's Webmention endpoint %q is not an http or https address", href)
+	}
+	endpoint.Fragment = ""
+	return endpoint.String(), nil
+}
+
+// hasWebmentionRel reports whether the space-separated rel values include
+// webmention.
+func hasWebmentionRel(rel string) bool {
+	return slices.ContainsFunc(strings.Fields(rel), func(v string) bool {
+		return strings.EqualFold(v, "webmention")
+	})
+}
+
+// HandleAdminWebmentionSends shows the Webmentions sent for a post, with
+// the outcome of the latest attempt at each.
+func (s *Server) HandleAdminWebmentionSends(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := dbgen.New(s.DB)
+	post, err := q.GetPostByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	sends, err := q.ListPostWebmentionSends(r.Context(), id)
+	if err != nil {
+		slog.Error("list post webmention sends", "error", err)
+	}
+	s.render(w, "admin_webmention_sends.html", map[string]any{
+		"Post":      post,
+		"Sends":     sends,
+		"SiteURL":   s.setting(r.Context(), settingSiteURL),
+		"CSRFToken": csrfToken(r),
+		"Year":      time.Now().Year(),
+	})
+}
+
+// HandleAdminWebmentionResend sends Webmentions from a post on the site to
+// all the pages it links to again.
+func (s *Server) HandleAdminWebmentionResend(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	post, err := dbgen.New(s.DB).GetPostByID(r.Context(), id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.queueWebmentionSends(r.Context(), nil, post)
+	http.Redirect(w, r, "/admin/webmentions/sent/"+strconv.FormatInt(id, 10), http.StatusFound)
+}